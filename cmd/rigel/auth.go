@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mizzy/rigel/internal/credentials"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Manage API keys stored in the OS keychain",
+	Long: `Store provider API keys in the macOS Keychain, the Secret Service on Linux or
+the Windows Credential Manager instead of .env files. Keys in the keychain take
+precedence; the environment variables are used when it has none or is unavailable.`,
+}
+
+var authLoginCmd = &cobra.Command{
+	Use:          "login <provider>",
+	Short:        "Store a provider's API key in the OS keychain",
+	Args:         cobra.ExactArgs(1),
+	ValidArgs:    providerNames(),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		provider, err := findProvider(args[0])
+		if err != nil {
+			return err
+		}
+
+		key, err := readAPIKey(cmd, provider)
+		if err != nil {
+			return err
+		}
+		if err := credentials.Keychain().Set(provider.Name, key); err != nil {
+			return fmt.Errorf("couldn't store the key: %w; set %s instead", err, provider.EnvVar)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Stored the %s API key (%s) in the keychain.\n", provider.Name, credentials.Mask(key))
+		return nil
+	},
+}
+
+var authLogoutCmd = &cobra.Command{
+	Use:          "logout <provider>",
+	Short:        "Remove a provider's API key from the OS keychain",
+	Args:         cobra.ExactArgs(1),
+	ValidArgs:    providerNames(),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		provider, err := findProvider(args[0])
+		if err != nil {
+			return err
+		}
+
+		err = credentials.Keychain().Delete(provider.Name)
+		switch {
+		case errors.Is(err, credentials.ErrNotFound):
+			fmt.Fprintf(cmd.OutOrStdout(), "No %s API key is stored in the keychain.\n", provider.Name)
+		case err != nil:
+			return fmt.Errorf("couldn't remove the key: %w", err)
+		default:
+			fmt.Fprintf(cmd.OutOrStdout(), "Removed the %s API key from the keychain.\n", provider.Name)
+		}
+		return nil
+	},
+}
+
+var authStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show where each provider's API key comes from",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		out := cmd.OutOrStdout()
+		keychain := credentials.Keychain()
+
+		var keychainErr error
+		for _, provider := range credentials.Providers {
+			key, source, err := credentials.Resolve(keychain, provider)
+			if err != nil {
+				keychainErr = err
+			}
+			switch source {
+			case credentials.SourceKeychain:
+				fmt.Fprintf(out, "  %-17s  keychain (%s)\n", provider.Name, credentials.Mask(key))
+			case credentials.SourceEnvironment:
+				fmt.Fprintf(out, "  %-17s  %s (%s)\n", provider.Name, provider.EnvVar, credentials.Mask(key))
+			default:
+				fmt.Fprintf(out, "  %-17s  not set\n", provider.Name)
+			}
+		}
+		if keychainErr != nil {
+			fmt.Fprintf(out, "\nThe keychain couldn't be read (%v); keys come from the environment only.\n", keychainErr)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(authCmd)
+	authCmd.AddCommand(authLoginCmd, authLogoutCmd, authStatusCmd)
+}
+
+// providerNames returns the names of the providers that use API keys
+func providerNames() []string {
+	names := make([]string, len(credentials.Providers))
+	for i, p := range credentials.Providers {
+		names[i] = p.Name
+	}
+	return names
+}
+
+// findProvider looks up a provider that uses an API key by name
+func findProvider(name string) (credentials.Provider, error) {
+	provider, ok := credentials.Find(name)
+	if !ok {
+		return provider, fmt.Errorf("unknown provider %q: must be one of %s", name, strings.Join(providerNames(), ", "))
+	}
+	return provider, nil
+}
+
+// readAPIKey prompts for a key without echoing it, or reads it from stdin
+// when that isn't a terminal
+func readAPIKey(cmd *cobra.Command, provider credentials.Provider) (string, error) {
+	var key string
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		fmt.Fprintf(cmd.OutOrStdout(), "API key for %s: ", provider.Name)
+		b, err := term.ReadPassword(fd)
+		fmt.Fprintln(cmd.OutOrStdout())
+		if err != nil {
+			return "", fmt.Errorf("failed to read the key: %w", err)
+		}
+		key = string(b)
+	} else {
+		line, err := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", fmt.Errorf("failed to read the key: %w", err)
+		}
+		key = line
+	}
+
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return "", errors.New("no key entered")
+	}
+	return key, nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zalando/go-keyring"
+)
+
+func runAuth(t *testing.T, stdin string, args ...string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	rootCmd.SetArgs(append([]string{"auth"}, args...))
+	rootCmd.SetIn(strings.NewReader(stdin))
+	rootCmd.SetOut(&out)
+	rootCmd.SetErr(&out)
+	defer func() {
+		rootCmd.SetArgs(nil)
+		rootCmd.SetIn(nil)
+		rootCmd.SetOut(nil)
+		rootCmd.SetErr(nil)
+	}()
+	err := rootCmd.Execute()
+	return out.String(), err
+}
+
+func TestAuthCommands(t *testing.T) {
+	keyring.MockInit()
+	for _, name := range []string{"ANTHROPIC_API_KEY", "OPENAI_API_KEY", "GOOGLE_API_KEY", "AZURE_OPENAI_API_KEY"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("OPENAI_API_KEY", "sk-openai-from-environment")
+
+	out, err := runAuth(t, "sk-ant-api03-secretkey1234\n", "login", "anthropic")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Stored the anthropic API key (sk-ant-...1234)")
+
+	out, err = runAuth(t, "", "status")
+	require.NoError(t, err)
+	assert.Contains(t, out, "anthropic          keychain (sk-ant-...1234)")
+	assert.Contains(t, out, "openai             OPENAI_API_KEY (sk-open...ment)")
+	assert.Contains(t, out, "google             not set")
+
+	out, err = runAuth(t, "", "logout", "anthropic")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Removed the anthropic API key")
+
+	_, err = runAuth(t, "key\n", "login", "acme")
+	assert.ErrorContains(t, err, `unknown provider "acme"`)
+}
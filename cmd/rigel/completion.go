@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/mizzy/rigel/internal/llm"
+	"github.com/spf13/cobra"
+)
+
+// modelCompletionTimeout bounds how long completing --model waits for the
+// provider's model list
+const modelCompletionTimeout = 3 * time.Second
+
+// completionProviders are the providers --provider completes to
+var completionProviders = []string{"anthropic", "ollama", "openai-compatible"}
+
+var completionCmd = &cobra.Command{
+	Use:   "completion bash|zsh|fish|powershell",
+	Short: "Generate the shell completion script",
+	Long: `Print the completion script of rigel for a shell. Completions include the
+models of the configured provider for --model and the settings for rigel config.`,
+	Example: `  # bash, for the current session
+  source <(rigel completion bash)
+
+  # zsh
+  rigel completion zsh > "${fpath[1]}/_rigel"
+
+  # fish
+  rigel completion fish > ~/.config/fish/completions/rigel.fish
+
+  # PowerShell
+  rigel completion powershell | Out-String | Invoke-Expression`,
+	Args:                  cobra.ExactArgs(1),
+	ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+	DisableFlagsInUseLine: true,
+	SilenceUsage:          true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := cmd.OutOrStdout()
+		switch args[0] {
+		case "bash":
+			return cmd.Root().GenBashCompletionV2(out, true)
+		case "zsh":
+			return cmd.Root().GenZshCompletion(out)
+		case "fish":
+			return cmd.Root().GenFishCompletion(out, true)
+		case "powershell":
+			return cmd.Root().GenPowerShellCompletionWithDesc(out)
+		default:
+			return fmt.Errorf("unsupported shell %q: must be bash, zsh, fish or powershell", args[0])
+		}
+	},
+}
+
+// registerFlagCompletions completes the values of every --model and
+// --provider flag of cmd and its subcommands
+func registerFlagCompletions(cmd *cobra.Command) {
+	if hasFlag(cmd, "model") {
+		_ = cmd.RegisterFlagCompletionFunc("model", completeModels)
+	}
+	if hasFlag(cmd, "provider") {
+		_ = cmd.RegisterFlagCompletionFunc("provider", cobra.FixedCompletions(completionProviders, cobra.ShellCompDirectiveNoFileComp))
+	}
+	for _, sub := range cmd.Commands() {
+		registerFlagCompletions(sub)
+	}
+}
+
+// hasFlag reports whether cmd defines a flag, locally or for its subcommands
+func hasFlag(cmd *cobra.Command, name string) bool {
+	return cmd.Flags().Lookup(name) != nil || cmd.PersistentFlags().Lookup(name) != nil
+}
+
+// completeModels completes the models of the configured provider, or of the
+// one given with --provider, by asking it for its model list
+func completeModels(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	c, err := loadConfig(workingDirTrusted(io.Discard))
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	if flag := cmd.Flags().Lookup("provider"); flag != nil && flag.Changed {
+		c.Provider = flag.Value.String()
+		c.Model = c.ModelFor(c.Provider)
+	}
+	// Completion must not answer from the cache or wait on fallbacks
+	c.CacheEnabled = false
+	c.FallbackProviders = nil
+
+	provider, err := llm.NewProvider(c)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), modelCompletionTimeout)
+	defer cancel()
+	models, err := provider.ListModels(ctx)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	var names []string
+	for _, model := range models {
+		if strings.HasPrefix(model.Name, toComplete) {
+			names = append(names, model.Name)
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	rootCmd.CompletionOptions.DisableDefaultCmd = true
+	rootCmd.AddCommand(completionCmd)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runRigel(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	rootCmd.SetArgs(args)
+	rootCmd.SetOut(&out)
+	rootCmd.SetErr(&out)
+	defer func() {
+		rootCmd.SetArgs(nil)
+		rootCmd.SetOut(nil)
+		rootCmd.SetErr(nil)
+	}()
+	err := rootCmd.Execute()
+	return out.String(), err
+}
+
+func TestCompletionCommand(t *testing.T) {
+	for shell, marker := range map[string]string{
+		"bash":       "# bash completion V2 for rigel",
+		"zsh":        "#compdef rigel",
+		"fish":       "# fish completion for rigel",
+		"powershell": "# powershell completion for rigel",
+	} {
+		out, err := runRigel(t, "completion", shell)
+		require.NoError(t, err, shell)
+		assert.Contains(t, out, marker, shell)
+	}
+
+	_, err := runRigel(t, "completion", "tcsh")
+	assert.ErrorContains(t, err, `unsupported shell "tcsh"`)
+}
+
+func TestConfigCommand(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("RIGEL_KEYCHAIN", "off")
+	t.Setenv("PROVIDER", "ollama")
+	t.Setenv("RIGEL_THEME", "light")
+	t.Setenv("GITHUB_TOKEN", "ghp_abcdefghijklmnop1234")
+
+	out, err := runRigel(t, "config")
+	require.NoError(t, err)
+	assert.Contains(t, out, "RIGEL_THEME                 light\n")
+	assert.Contains(t, out, "GITHUB_TOKEN                ghp_abc...1234\n")
+	assert.NotContains(t, out, "abcdefghijklmnop")
+
+	out, err = runRigel(t, "config", "rigel_theme")
+	require.NoError(t, err)
+	assert.Equal(t, "light\n", out)
+
+	_, err = runRigel(t, "config", "NOPE")
+	assert.ErrorContains(t, err, `unknown setting "NOPE"`)
+
+	out, err = runRigel(t, "__complete", "config", "RIGEL_MAX_")
+	require.NoError(t, err)
+	assert.Contains(t, out, "RIGEL_MAX_SUBAGENTS\tSub-agents working at once\n")
+	assert.NotContains(t, out, "RIGEL_THEME")
+}
+
+func TestCompleteModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/tags" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"models":[{"model":"llama3.2:latest"},{"model":"qwen2.5-coder:7b"},{"model":"llama3.1:8b"}]}`))
+	}))
+	defer server.Close()
+
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("RIGEL_KEYCHAIN", "off")
+	t.Setenv("PROVIDER", "anthropic")
+	t.Setenv("OLLAMA_BASE_URL", server.URL)
+
+	cmd := &cobra.Command{Use: "test"}
+	cmd.Flags().String("provider", "", "")
+	cmd.Flags().String("model", "", "")
+	require.NoError(t, cmd.Flags().Set("provider", "ollama"))
+
+	models, directive := completeModels(cmd, nil, "llama")
+	assert.Equal(t, []string{"llama3.2:latest", "llama3.1:8b"}, models)
+	assert.Equal(t, cobra.ShellCompDirectiveNoFileComp, directive)
+
+	// Through the --model and --provider flags of rigel
+	registerFlagCompletions(rootCmd)
+	t.Cleanup(func() { providerFlag, modelFlag = "", "" })
+	out, err := runRigel(t, "__complete", "--provider", "ollama", "--model", "qwen")
+	require.NoError(t, err)
+	assert.Contains(t, out, "qwen2.5-coder:7b\n")
+	assert.NotContains(t, out, "llama")
+
+	out, err = runRigel(t, "__complete", "--provider", "")
+	require.NoError(t, err)
+	assert.Contains(t, out, "anthropic\nollama\nopenai-compatible\n")
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mizzy/rigel/internal/config"
+	"github.com/mizzy/rigel/internal/credentials"
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config [key]",
+	Short: "Show the effective configuration",
+	Long: `Show the value of each setting read from .env, the environment and the
+keychain, or of one setting. API keys and tokens are masked. The .env of a
+directory that isn't trusted (see rigel trust) isn't read.`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeConfigKeys,
+	SilenceUsage:      true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := loadConfig(workingDirTrusted(io.Discard))
+		if err != nil {
+			return err
+		}
+		if err := applyModelFlags(c); err != nil {
+			return err
+		}
+		out := cmd.OutOrStdout()
+
+		if len(args) == 1 {
+			setting, ok := config.FindSetting(strings.ToUpper(args[0]))
+			if !ok {
+				return fmt.Errorf("unknown setting %q; run `rigel config` to list them", args[0])
+			}
+			fmt.Fprintln(out, settingValue(setting, c))
+			return nil
+		}
+
+		for _, setting := range config.Settings {
+			value := settingValue(setting, c)
+			if value == "" {
+				value = "(not set)"
+			}
+			fmt.Fprintf(out, "%-27s %s\n", setting.Name, value)
+		}
+		return nil
+	},
+}
+
+// settingValue returns a setting's value, masked if it is a secret
+func settingValue(setting config.Setting, c *config.Config) string {
+	value := setting.Value(c)
+	if setting.Secret && value != "" {
+		return credentials.Mask(value)
+	}
+	return value
+}
+
+// completeConfigKeys completes the names of settings, with their
+// descriptions
+func completeConfigKeys(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var keys []string
+	for _, setting := range config.Settings {
+		if strings.HasPrefix(setting.Name, strings.ToUpper(toComplete)) {
+			keys = append(keys, setting.Name+"\t"+setting.Description)
+		}
+	}
+	return keys, cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+}
@@ -2,101 +2,165 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"os"
+	"os/signal"
 	"runtime"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/mizzy/rigel/internal/agent"
 	"github.com/mizzy/rigel/internal/config"
+	"github.com/mizzy/rigel/internal/doctor"
 	"github.com/mizzy/rigel/internal/llm"
+	"github.com/mizzy/rigel/internal/logging"
+	"github.com/mizzy/rigel/internal/privacy"
+	"github.com/mizzy/rigel/internal/recovery"
+	"github.com/mizzy/rigel/internal/rpc"
 	"github.com/mizzy/rigel/internal/sandbox"
+	"github.com/mizzy/rigel/internal/server"
 	"github.com/mizzy/rigel/internal/tools"
+	"github.com/mizzy/rigel/internal/ui/chat"
+	"github.com/mizzy/rigel/internal/ui/plain"
 	termflowui "github.com/mizzy/rigel/internal/ui/termflow"
 	"github.com/mizzy/rigel/internal/ui/terminal"
 	"github.com/mizzy/rigel/internal/version"
+	"github.com/mizzy/rigel/internal/workspace"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 var (
-	cfg           *config.Config
-	sandboxFlag   bool
-	noSandboxFlag bool
-	termflowFlag  bool
+	cfg               *config.Config
+	sandboxFlag       bool
+	noSandboxFlag     bool
+	termflowFlag      bool
+	uiFlag            string
+	stdioFlag         bool
+	noColorFlag       bool
+	dryRunFlag        bool
+	workspaceFlag     []string
+	providerFlag      string
+	modelFlag         string
+	deterministicFlag bool
+	plainFlag         bool
+	serveHost         string
+	servePort         int
 )
 
+// modelCheckTimeout bounds how long checking --model waits for the
+// provider's model list
+const modelCheckTimeout = 3 * time.Second
+
 func main() {
+	registerFlagCompletions(rootCmd)
 	if err := rootCmd.Execute(); err != nil {
+		if errors.Is(err, errStepFailed) {
+			os.Exit(1)
+		}
 		log.Fatal(err)
 	}
 }
 
 var rootCmd = &cobra.Command{
-	Use:   "rigel",
+	Use:   "rigel [prompt]",
 	Short: "AI Coding Agent - Your intelligent coding assistant",
 	Long: `Rigel is an AI-powered coding assistant that helps developers write,
-review, and improve code through natural language interactions.`,
+review, and improve code through natural language interactions.
+
+Given a prompt as arguments or on stdin, rigel answers it and exits; without
+one it starts an interactive chat.`,
+	Example: `  rigel "explain this error: undefined: foo"
+  go test ./... 2>&1 | rigel "why do these tests fail?"
+  rigel --plain "what does HTTP 418 mean?"`,
+	Args: cobra.ArbitraryArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		// Handle sandbox mode (default enabled on macOS)
-		if !noSandboxFlag && (sandboxFlag || shouldEnableSandboxByDefault()) {
-			if !sandbox.IsSandboxed() {
-				if err := sandbox.EnableSandbox("."); err != nil {
-					log.Printf("Warning: Failed to enable sandbox: %v", err)
-					log.Println("Running without sandbox restrictions.")
-				}
-				// If EnableSandbox succeeds, it will re-exec and exit
-			}
+		// Both lipgloss and termflow honor NO_COLOR
+		if noColorFlag {
+			os.Setenv("NO_COLOR", "1")
 		}
 
-		// Show sandbox status
-		if sandbox.IsSandboxed() {
-			fmt.Fprintln(os.Stderr, "🔒 Sandbox enabled: File writes restricted to current directory")
-		} else if noSandboxFlag {
-			fmt.Fprintln(os.Stderr, "⚠️  Running without sandbox. File operations are unrestricted.")
+		enableSandbox()
+
+		// A prompt given as arguments or piped in is answered without the
+		// interactive UI (the pipe check is skipped in test mode)
+		stat, _ := os.Stdin.Stat()
+		isTestMode := os.Getenv("RIGEL_TEST_MODE") == "1"
+		piped := !isTestMode && (stat.Mode()&os.ModeCharDevice) == 0
+		oneShot := len(args) > 0 || piped
+
+		// A chat started in a directory not trusted yet asks whether to
+		// trust it; runs that can't ask take it as untrusted. An untrusted
+		// directory's .env isn't loaded and it is read-only.
+		trusted := isTestMode
+		if !trusted {
+			if oneShot || stdioFlag {
+				trusted = workingDirTrusted(os.Stderr)
+			} else {
+				trusted = trustWorkingDir()
+			}
 		}
+
 		var err error
-		cfg, err = config.Load("")
+		cfg, err = loadConfig(trusted)
 		if err != nil {
 			log.Printf("Warning: Failed to load config: %v", err)
 		}
+		if cfg != nil {
+			cfg.Workspaces = workspaceFlag
+			cfg.DryRun = dryRunFlag
+		}
+		if err := applyModelFlags(cfg); err != nil {
+			log.Fatal(err)
+		}
+		if err := applyUIFlag(cfg); err != nil {
+			log.Fatal(err)
+		}
+		defer initLogging()()
+		initPrivacy()
 
 		provider, err := llm.NewProvider(cfg)
 		if err != nil {
 			log.Fatalf("Failed to initialize LLM provider: %v", err)
 		}
+		if err := checkModelFlag(provider); err != nil {
+			log.Fatal(err)
+		}
 
-		// Check if input is piped (skip check in test mode)
-		stat, _ := os.Stdin.Stat()
-		isTestMode := os.Getenv("RIGEL_TEST_MODE") == "1"
-		if !isTestMode && (stat.Mode()&os.ModeCharDevice) == 0 {
-			// Handle piped input - no interactive commands in pipe mode
-			input, err := io.ReadAll(os.Stdin)
-			if err != nil {
-				log.Fatalf("Failed to read from stdin: %v", err)
-			}
+		// Headless JSON-RPC mode for editor integrations owns stdin/stdout
+		if stdioFlag {
+			runStdioMode(provider)
+			return
+		}
 
-			prompt := strings.TrimSpace(string(input))
-			if prompt == "" {
+		if oneShot {
+			prompt := strings.Join(args, " ")
+			if piped {
+				input, err := io.ReadAll(os.Stdin)
+				if err != nil {
+					log.Fatalf("Failed to read from stdin: %v", err)
+				}
+				prompt = oneShotPrompt(prompt, string(input))
+			}
+			if strings.TrimSpace(prompt) == "" {
 				log.Fatal("No input provided")
 			}
 
-			// In pipe mode, slash commands are not supported
+			// No interactive commands in one-shot mode
 			if strings.HasPrefix(prompt, "/") {
-				fmt.Fprintf(os.Stderr, "Slash commands like %s are only available in interactive mode.\n", prompt)
-				fmt.Fprintf(os.Stderr, "Run 'rigel' without piping input to use interactive mode.\n")
+				fmt.Fprintf(os.Stderr, "Slash commands like %s are only available in interactive mode.\n", strings.Fields(prompt)[0])
+				fmt.Fprintf(os.Stderr, "Run 'rigel' without a prompt to use interactive mode.\n")
 				os.Exit(1)
 			}
 
-			// Create intelligent agent with file tools for pipe mode
-			intelligentAgent := agent.New(provider)
-			fileTool := tools.NewFileTool()
-			intelligentAgent.RegisterTool(fileTool)
-
-			// Generate response using agent
-			response, err := intelligentAgent.Execute(context.Background(), prompt)
+			response, err := answerOnce(provider, prompt)
 			if err != nil {
 				log.Fatalf("Failed to generate response: %v", err)
 			}
@@ -104,10 +168,21 @@ review, and improve code through natural language interactions.`,
 			fmt.Print(response)
 			os.Stdout.Sync() // Ensure output is flushed
 		} else {
-			// Choose chat mode based on flag
-			if termflowFlag {
+			announceUpdate(cfg)
+
+			// Run the interface chosen with --ui or RIGEL_UI; without a
+			// terminal to draw on, as in rigel | tee log, the chat falls back
+			// to plain lines
+			ui := config.UITUI
+			if cfg != nil {
+				ui = cfg.UI
+			}
+			switch {
+			case ui == config.UIPlain || !isTestMode && !term.IsTerminal(int(os.Stdout.Fd())):
+				runPlainChatMode(provider)
+			case ui == config.UITermflow:
 				runTermflowChatMode(provider)
-			} else {
+			default:
 				// Run interactive chat mode (inline, no alternate screen)
 				runChatMode(provider)
 			}
@@ -115,13 +190,216 @@ review, and improve code through natural language interactions.`,
 	},
 }
 
+// enableSandbox restricts file writes to the workspace by re-executing rigel
+// in the sandbox, by default on macOS, and tells whether it is restricted
+func enableSandbox() {
+	if !noSandboxFlag && (sandboxFlag || shouldEnableSandboxByDefault()) {
+		if !sandbox.IsSandboxed() {
+			if err := sandbox.EnableSandbox(".", writableWorkspaces()...); err != nil {
+				log.Printf("Warning: Failed to enable sandbox: %v", err)
+				log.Println("Running without sandbox restrictions.")
+			}
+			// If EnableSandbox succeeds, it will re-exec and exit
+		}
+	}
+
+	if sandbox.IsSandboxed() {
+		fmt.Fprintln(os.Stderr, "🔒 Sandbox enabled: File writes restricted to the workspace")
+	} else if noSandboxFlag {
+		fmt.Fprintln(os.Stderr, "⚠️  Running without sandbox. File operations are unrestricted.")
+	}
+}
+
+// loadConfig loads the configuration, without the .env of a working
+// directory that isn't trusted, which is then read-only
+func loadConfig(trusted bool) (*config.Config, error) {
+	var c *config.Config
+	var err error
+	if trusted {
+		c, err = config.Load("")
+	} else {
+		c, err = config.LoadEnv()
+	}
+	if c != nil {
+		c.ReadOnly = !trusted
+	}
+	return c, err
+}
+
+// headlessWorkspace returns the workspace of the non-interactive modes: the
+// working directory, read-only if it isn't trusted, and the --workspace
+// directories
+func headlessWorkspace() (*workspace.Workspace, error) {
+	newWorkspace := workspace.New
+	if cfg != nil && cfg.ReadOnly {
+		newWorkspace = workspace.NewReadOnly
+	}
+	ws, err := newWorkspace(".")
+	if err != nil {
+		return nil, err
+	}
+	if err := ws.AddSpecs(workspaceFlag); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	return ws, nil
+}
+
+// oneShotPrompt combines a prompt given as arguments with piped input, which
+// follows it, as in: go test 2>&1 | rigel "why do these tests fail?"
+func oneShotPrompt(args, input string) string {
+	args, input = strings.TrimSpace(args), strings.TrimSpace(input)
+	switch {
+	case args == "":
+		return input
+	case input == "":
+		return args
+	default:
+		return args + "\n\n" + input
+	}
+}
+
+// answerOnce answers a one-shot prompt with the agent and its tools, or with
+// the model alone given --plain
+func answerOnce(provider llm.Provider, prompt string) (string, error) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if cfg != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = llm.WithTimeout(ctx, cfg.GenerationTimeout)
+		defer cancel()
+	}
+	if plainFlag {
+		response, err := provider.Generate(ctx, prompt)
+		if err != nil {
+			return "", err
+		}
+		if !strings.HasSuffix(response, "\n") {
+			response += "\n"
+		}
+		return response, nil
+	}
+	intelligentAgent, _ := newHeadlessAgent(provider)
+	return intelligentAgent.Execute(ctx, prompt)
+}
+
+// newHeadlessAgent creates the agent of the non-interactive modes with the
+// file, test, check and web search tools, returning it with its tools
+func newHeadlessAgent(provider llm.Provider) (*agent.Agent, []tools.Tool) {
+	intelligentAgent := agent.New(provider)
+	fileTool := tools.NewFileTool()
+	if ws, err := headlessWorkspace(); err == nil {
+		fileTool.SetWorkspace(ws)
+	}
+	registered := []tools.Tool{fileTool}
+	intelligentAgent.SetDryRun(dryRunFlag)
+	if cfg != nil {
+		fileTool.SetSizeLimits(cfg.MaxReadSize, cfg.MaxWriteSize)
+		if !cfg.ReadOnly {
+			// A directory that isn't trusted only has its files read
+			testTool, checkTool := tools.NewTestRunnerTool(".", cfg.TestCommand), tools.NewCheckTool(".", cfg.CheckCommands)
+			testTool.SetTimeout(cfg.ToolTimeout)
+			checkTool.SetTimeout(cfg.ToolTimeout)
+			registered = append(registered, testTool, checkTool)
+		}
+		if cfg.WebSearch != "" {
+			if backend, err := tools.NewWebSearchBackend(cfg.WebSearch, cfg.WebSearchURL, cfg.BraveAPIKey); err == nil {
+				registered = append(registered, tools.NewWebSearchTool(backend))
+			}
+		}
+		intelligentAgent.SetMaxFixIterations(cfg.MaxFixIterations)
+		intelligentAgent.SetMaxSubagents(cfg.MaxSubagents)
+		intelligentAgent.SetToolTimeout(cfg.ToolTimeout)
+	}
+	for _, tool := range registered {
+		intelligentAgent.RegisterTool(tool)
+	}
+	return intelligentAgent, registered
+}
+
 func runChatMode(provider llm.Provider) {
 	model := terminal.NewModel(provider, cfg)
-	p := tea.NewProgram(model, tea.WithInput(os.Stdin), tea.WithOutput(os.Stdout))
+	saveRecovery := func() {
+		if err := model.Core().SaveRecovery(); err != nil {
+			slog.Error("failed to save recovery snapshot", "error", err)
+		}
+	}
+	defer recovery.Guard(saveRecovery)()
+
+	opts := []tea.ProgramOption{tea.WithInput(os.Stdin), tea.WithOutput(os.Stdout)}
+	fullScreen := cfg.AltScreen || cfg.Mouse
+	if fullScreen {
+		opts = append(opts, tea.WithAltScreen())
+	}
+	if cfg.Mouse {
+		// Mouse positions are only meaningful on a screen the UI owns
+		opts = append(opts, tea.WithMouseCellMotion())
+	}
+	if len(cfg.Notify) > 0 {
+		// Focus reports tell whether to notify when a long response is ready
+		opts = append(opts, tea.WithReportFocus())
+	}
+	// SIGTERM and SIGHUP are handled below, not by Bubbletea, to save the
+	// conversation before exiting
+	opts = append(opts, tea.WithoutSignalHandler())
+	p := tea.NewProgram(model, opts...)
+
+	// The conversation is logged and saved once, on exit or on a signal,
+	// whichever comes first
+	var exitOnce sync.Once
+	saveOnExit := func() {
+		exitOnce.Do(func() {
+			appendTranscript(model.Core())
+			saveSession(model.Core())
+		})
+	}
 
-	if _, err := p.Run(); err != nil {
+	stopped := make(chan struct{})
+	defer recovery.OnTerminate(func() {
+		p.Kill()
+		<-stopped
+	}, saveOnExit)()
+
+	_, err := p.Run()
+	close(stopped)
+	if err != nil {
+		if errors.Is(err, tea.ErrProgramKilled) {
+			// Killed on SIGTERM or SIGHUP, whose handler saves and exits
+			select {}
+		}
+		if errors.Is(err, tea.ErrProgramPanic) {
+			// Bubbletea already restored the terminal and printed the panic
+			slog.Error("chat UI panicked", "error", err)
+			saveRecovery()
+			recovery.Report("the chat UI panicked; your conversation can be recovered with /restore")
+			os.Exit(2)
+		}
 		log.Fatalf("Error running chat: %v", err)
 	}
+
+	// The alternate screen is gone with the chat on it; leave the
+	// conversation in the terminal's scrollback instead
+	if fullScreen {
+		fmt.Print(model.Transcript())
+	}
+	saveOnExit()
+}
+
+// appendTranscript appends the conversation to RIGEL_TRANSCRIPT_LOG, if set
+func appendTranscript(core *chat.Core) {
+	if cfg.TranscriptLog == "" {
+		return
+	}
+	if err := core.AppendTranscript(cfg.TranscriptLog); err != nil {
+		slog.Error("failed to write the transcript", "error", err)
+	}
+}
+
+// saveSession saves the conversation so that it can be resumed, and the
+// input history
+func saveSession(core *chat.Core) {
+	if err := core.Close(); err != nil {
+		slog.Error("failed to save the session", "error", err)
+	}
 }
 
 func runTermflowChatMode(provider llm.Provider) {
@@ -129,17 +407,172 @@ func runTermflowChatMode(provider llm.Provider) {
 	if err != nil {
 		log.Fatalf("Failed to create termflow chat session: %v", err)
 	}
+	defer recovery.Guard(func() {
+		if err := session.Core().SaveRecovery(); err != nil {
+			slog.Error("failed to save recovery snapshot", "error", err)
+		}
+	})()
+	defer recovery.OnTerminate(nil, func() { saveSession(session.Core()) })()
 
 	if err := session.Run(); err != nil {
 		log.Fatalf("Error running termflow chat: %v", err)
 	}
 }
 
+func runPlainChatMode(provider llm.Provider) {
+	repl := plain.NewREPL(provider, cfg, os.Stdin, os.Stdout)
+	defer recovery.Guard(func() {
+		if err := repl.Core().SaveRecovery(); err != nil {
+			slog.Error("failed to save recovery snapshot", "error", err)
+		}
+	})()
+	defer recovery.OnTerminate(nil, func() { saveSession(repl.Core()) })()
+
+	if err := repl.Run(); err != nil {
+		log.Fatalf("Error running plain chat: %v", err)
+	}
+}
+
+func runStdioMode(provider llm.Provider) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := rpc.NewServer(provider, os.Stdin, os.Stdout)
+	if ws, err := headlessWorkspace(); err == nil {
+		srv.SetWorkspace(ws)
+	}
+	if err := srv.Serve(ctx); err != nil && err != context.Canceled {
+		log.Fatalf("Error running stdio mode: %v", err)
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(versionCmd)
+	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(doctorCmd)
+	rootCmd.AddCommand(runCmd)
+	rootCmd.PersistentFlags().StringVar(&providerFlag, "provider", "", "Use this provider for this run instead of the configured one")
+	rootCmd.PersistentFlags().StringVar(&modelFlag, "model", "", "Use this model for this run instead of the configured one")
+	rootCmd.PersistentFlags().BoolVar(&deterministicFlag, "deterministic", false, "Sample at temperature 0 with a fixed seed and cache responses, for reproducible runs")
 	rootCmd.Flags().BoolVar(&sandboxFlag, "sandbox", false, "Force enable sandbox mode (default on macOS)")
 	rootCmd.Flags().BoolVar(&noSandboxFlag, "no-sandbox", false, "Disable sandbox mode explicitly")
-	rootCmd.Flags().BoolVar(&termflowFlag, "termflow", false, "Use termflow UI instead of bubbletea (preserves terminal scrollback)")
+	rootCmd.Flags().StringVar(&uiFlag, "ui", "", "Chat interface: tui, termflow (inline, preserves terminal scrollback) or plain (default from RIGEL_UI, else tui)")
+	rootCmd.Flags().BoolVar(&termflowFlag, "termflow", false, "Same as --ui termflow")
+	rootCmd.Flags().BoolVar(&noColorFlag, "no-color", false, "Disable colored output (same as setting NO_COLOR)")
+	rootCmd.Flags().StringArrayVar(&workspaceFlag, "workspace", nil, "Add a directory to the workspace (repeatable; append :ro to make it read-only)")
+	rootCmd.Flags().BoolVar(&dryRunFlag, "dry-run", false, "Show the file writes and commands the agent would perform without running them")
+	rootCmd.Flags().BoolVar(&plainFlag, "plain", false, "Answer a one-shot prompt with the model alone, without the agent's tools")
+	rootCmd.Flags().BoolVar(&stdioFlag, "stdio", false, "Run headless, speaking JSON-RPC over stdin/stdout for editor integrations")
+
+	serveCmd.Flags().StringVar(&serveHost, "host", "127.0.0.1", "Address to bind the API server to")
+	serveCmd.Flags().IntVar(&servePort, "port", 8080, "Port to listen on")
+	serveCmd.Flags().BoolVar(&sandboxFlag, "sandbox", false, "Force enable sandbox mode (default on macOS)")
+	serveCmd.Flags().BoolVar(&noSandboxFlag, "no-sandbox", false, "Disable sandbox mode explicitly")
+}
+
+// initLogging sets up the log file and returns a function that closes it
+func initLogging() func() {
+	c := cfg
+	if c == nil {
+		c = &config.Config{}
+	}
+
+	closer, err := logging.Init(c.LogLevel, c.AnthropicAPIKey, c.OpenAIAPIKey, c.GoogleAPIKey, c.AzureAPIKey)
+	if err != nil {
+		log.Printf("Warning: Failed to initialize logging: %v", err)
+		return func() {}
+	}
+
+	slog.Info("rigel starting", "version", version.String(), "provider", c.Provider, "model", c.Model)
+	return func() { closer.Close() }
+}
+
+// initPrivacy sets up redaction and encryption of the history and sessions
+func initPrivacy() {
+	if cfg == nil {
+		return
+	}
+	if err := privacy.Configure(cfg.Redact, cfg.Encrypt); err != nil {
+		log.Printf("Warning: History and sessions will be saved unencrypted: %v", err)
+	}
+}
+
+// applyModelFlags overrides the configured provider and model with
+// --provider and --model for this run, without remembering them, and turns
+// on deterministic mode with --deterministic. A provider alone uses its
+// default model.
+func applyModelFlags(c *config.Config) error {
+	if c == nil {
+		return nil
+	}
+	if providerFlag != "" {
+		c.Provider = providerFlag
+		c.Model = c.ModelFor(providerFlag)
+		if err := c.Validate(); err != nil {
+			return fmt.Errorf("invalid --provider: %w", err)
+		}
+	}
+	if modelFlag != "" {
+		c.Model = modelFlag
+	}
+	if deterministicFlag {
+		c.Deterministic = true
+	}
+	return nil
+}
+
+// applyUIFlag sets the chat interface chosen with --ui, or with --termflow,
+// over the configured one
+func applyUIFlag(c *config.Config) error {
+	if c == nil {
+		return nil
+	}
+	switch {
+	case uiFlag != "":
+		if !config.ValidUI(uiFlag) {
+			return fmt.Errorf("invalid --ui %q: must be tui, termflow or plain", uiFlag)
+		}
+		c.UI = uiFlag
+	case termflowFlag:
+		c.UI = config.UITermflow
+	}
+	return nil
+}
+
+// checkModelFlag verifies that the model given with --model exists, for the
+// local providers whose model list is cheap to fetch. The check is skipped
+// if the list can't be fetched, which the first request will report anyway.
+func checkModelFlag(provider llm.Provider) error {
+	if modelFlag == "" || cfg == nil || (cfg.Provider != "ollama" && cfg.Provider != "openai-compatible") {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), modelCheckTimeout)
+	defer cancel()
+	models, err := provider.ListModels(ctx)
+	if err != nil || len(models) == 0 {
+		return nil
+	}
+
+	names := make([]string, len(models))
+	for i, m := range models {
+		if m.Name == modelFlag || m.Name == modelFlag+":latest" {
+			return nil
+		}
+		names[i] = m.Name
+	}
+	return fmt.Errorf("invalid --model: %s has no model %q; available: %s", cfg.Provider, modelFlag, strings.Join(names, ", "))
+}
+
+// writableWorkspaces returns the --workspace directories the sandbox should
+// allow writes to
+func writableWorkspaces() []string {
+	var dirs []string
+	for _, spec := range workspaceFlag {
+		if path, readOnly := workspace.ParseSpec(spec); !readOnly {
+			dirs = append(dirs, path)
+		}
+	}
+	return dirs
 }
 
 func shouldEnableSandboxByDefault() bool {
@@ -155,3 +588,72 @@ var versionCmd = &cobra.Command{
 		fmt.Println(version.String())
 	},
 }
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check configuration and connectivity",
+	Long: `Verify API keys, ping the configured providers, check that the Ollama model
+is installed, and report the sandbox status and history file permissions.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+		cfg, err = loadConfig(workingDirTrusted(os.Stderr))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+			os.Exit(1)
+		}
+		if err := applyModelFlags(cfg); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		report := doctor.Run(context.Background(), cfg, nil)
+		fmt.Println(report)
+		if report.Failed() {
+			os.Exit(1)
+		}
+	},
+}
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run rigel as an HTTP API server",
+	Long: `Start a long-running HTTP server exposing generate, streaming chat,
+session and tool endpoints so editors and other processes can integrate with rigel.
+Requests other than the health check need the bearer token printed at startup.
+In a directory that isn't trusted (see rigel trust), the file tool only reads.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		enableSandbox()
+
+		var err error
+		cfg, err = loadConfig(workingDirTrusted(os.Stderr))
+		if err != nil {
+			log.Printf("Warning: Failed to load config: %v", err)
+		}
+		if err := applyModelFlags(cfg); err != nil {
+			log.Fatal(err)
+		}
+		defer initLogging()()
+		initPrivacy()
+
+		provider, err := llm.NewProvider(cfg)
+		if err != nil {
+			log.Fatalf("Failed to initialize LLM provider: %v", err)
+		}
+		if err := checkModelFlag(provider); err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		srv := server.New(provider, cfg)
+		addr := fmt.Sprintf("%s:%d", serveHost, servePort)
+		fmt.Fprintf(os.Stderr, "rigel API server listening on http://%s (provider: %s, model: %s)\n",
+			addr, provider.GetName(), provider.GetCurrentModel().Name)
+		fmt.Fprintf(os.Stderr, "Send requests with the header: Authorization: Bearer %s\n", srv.Token())
+
+		if err := srv.ListenAndServe(ctx, addr); err != nil {
+			log.Fatalf("Server error: %v", err)
+		}
+	},
+}
@@ -2,13 +2,19 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/mizzy/rigel/internal/config"
+	"github.com/mizzy/rigel/internal/llm"
 )
 
 func TestMainCommand(t *testing.T) {
@@ -117,3 +123,109 @@ func captureOutput(f func()) string {
 	os.Stdout = old
 	return <-done
 }
+
+func TestApplyModelFlags(t *testing.T) {
+	t.Cleanup(func() { providerFlag, modelFlag, deterministicFlag = "", "", false })
+	base := config.Config{
+		Provider:        "anthropic",
+		Model:           "claude-sonnet-4-20250514",
+		AnthropicAPIKey: "key",
+		ProviderModels:  map[string]string{"ollama": "qwen2.5-coder"},
+	}
+
+	c := base
+	require.NoError(t, applyModelFlags(&c))
+	assert.Equal(t, base, c, "nothing changes without the flags")
+
+	modelFlag = "claude-3-5-haiku"
+	c = base
+	require.NoError(t, applyModelFlags(&c))
+	assert.Equal(t, "anthropic", c.Provider)
+	assert.Equal(t, "claude-3-5-haiku", c.Model)
+
+	providerFlag, modelFlag = "ollama", ""
+	c = base
+	require.NoError(t, applyModelFlags(&c))
+	assert.Equal(t, "ollama", c.Provider)
+	assert.Equal(t, "qwen2.5-coder", c.Model, "the provider's default model")
+
+	providerFlag = "acme"
+	c = base
+	assert.EqualError(t, applyModelFlags(&c), "invalid --provider: unsupported provider: acme")
+
+	providerFlag, deterministicFlag = "", true
+	c = base
+	require.NoError(t, applyModelFlags(&c))
+	assert.True(t, c.Deterministic)
+
+	assert.NoError(t, applyModelFlags(nil))
+}
+
+func TestApplyUIFlag(t *testing.T) {
+	t.Cleanup(func() { uiFlag, termflowFlag = "", false })
+
+	c := config.Config{UI: config.UIPlain}
+	require.NoError(t, applyUIFlag(&c))
+	assert.Equal(t, config.UIPlain, c.UI, "the configured interface without the flags")
+
+	termflowFlag = true
+	require.NoError(t, applyUIFlag(&c))
+	assert.Equal(t, config.UITermflow, c.UI)
+
+	uiFlag = "tui"
+	require.NoError(t, applyUIFlag(&c))
+	assert.Equal(t, config.UITUI, c.UI, "--ui wins over --termflow")
+
+	uiFlag = "gui"
+	assert.EqualError(t, applyUIFlag(&c), `invalid --ui "gui": must be tui, termflow or plain`)
+}
+
+func TestCheckModelFlag(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"models":[{"model":"llama3.2:latest"},{"model":"qwen2.5-coder:7b"}]}`))
+	}))
+	defer server.Close()
+
+	oldCfg := cfg
+	t.Cleanup(func() { cfg, modelFlag = oldCfg, "" })
+	cfg = &config.Config{Provider: "ollama"}
+	provider, err := llm.NewOllamaProvider(server.URL, "llama3.2")
+	require.NoError(t, err)
+
+	for _, model := range []string{"", "llama3.2", "qwen2.5-coder:7b"} {
+		modelFlag = model
+		assert.NoError(t, checkModelFlag(provider), model)
+	}
+
+	modelFlag = "mistral"
+	assert.EqualError(t, checkModelFlag(provider), `invalid --model: ollama has no model "mistral"; available: llama3.2:latest, qwen2.5-coder:7b`)
+
+	// Other providers aren't checked
+	cfg = &config.Config{Provider: "anthropic"}
+	assert.NoError(t, checkModelFlag(provider))
+}
+
+func TestOneShotPrompt(t *testing.T) {
+	assert.Equal(t, "explain this", oneShotPrompt("explain this", ""))
+	assert.Equal(t, "piped prompt", oneShotPrompt("", "piped prompt\n"))
+	assert.Equal(t, "why do these tests fail?\n\n--- FAIL: TestFoo", oneShotPrompt("why do these tests fail?", "--- FAIL: TestFoo\n"))
+	assert.Empty(t, oneShotPrompt(" ", "\n"))
+}
+
+// plainProvider answers every prompt by quoting it
+type plainProvider struct {
+	llm.Provider
+}
+
+func (plainProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	return "you said: " + prompt, nil
+}
+
+func TestAnswerOncePlain(t *testing.T) {
+	plainFlag = true
+	t.Cleanup(func() { plainFlag = false })
+
+	response, err := answerOnce(plainProvider{}, "hello")
+	require.NoError(t, err)
+	assert.Equal(t, "you said: hello\n", response)
+}
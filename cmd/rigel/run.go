@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/mizzy/rigel/internal/batch"
+	"github.com/mizzy/rigel/internal/llm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	runVars   []string
+	runReport string
+)
+
+// errStepFailed is returned by rigel run when a step failed, for main to
+// exit with status 1 once the deferred cleanup has run
+var errStepFailed = errors.New("a step failed")
+
+var runCmd = &cobra.Command{
+	Use:   "run <file>",
+	Short: "Run the prompts, tools and commands of a YAML file non-interactively",
+	Long: `Run the steps of a YAML batch file in order: prompts for the agent, calls of
+its tools and shell commands, with {{variables}} and conditions on the outcome
+of earlier steps. Progress goes to stderr and a Markdown report to stdout, or
+to the --report file (JSON if it ends in .json). Exits with status 1 if a step
+not marked continue_on_error failed. In a directory that isn't trusted (see
+rigel trust), the agent only reads files and doesn't run tests or builds.`,
+	Example: `  # tasks.yaml
+  name: Maintenance
+  vars:
+    packages: ./...
+  steps:
+    - name: Regenerate AGENTS.md
+      prompt: Update AGENTS.md to describe the current layout of the repository
+    - id: tests
+      run: go test {{packages}}
+      continue_on_error: true
+    - name: Summarize failures
+      if: tests.failure
+      prompt: "Summarize why these tests fail: {{steps.tests.output}}"
+
+  rigel run tasks.yaml --var packages=./internal/... --report report.md`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	// The report tells which step failed; main prints the other errors
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, err := batch.Load(args[0])
+		if err != nil {
+			return err
+		}
+		vars := map[string]string{}
+		for _, v := range runVars {
+			name, value, ok := strings.Cut(v, "=")
+			if !ok || name == "" {
+				return fmt.Errorf("invalid --var %q: use name=value", v)
+			}
+			vars[name] = value
+		}
+
+		enableSandbox()
+		cfg, err = loadConfig(workingDirTrusted(cmd.ErrOrStderr()))
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		if err := applyModelFlags(cfg); err != nil {
+			return err
+		}
+		defer initLogging()()
+		initPrivacy()
+
+		provider, err := llm.NewProvider(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to initialize LLM provider: %w", err)
+		}
+		if err := checkModelFlag(provider); err != nil {
+			return err
+		}
+		intelligentAgent, registered := newHeadlessAgent(provider)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		runner := &batch.Runner{Agent: intelligentAgent, Tools: registered, Dir: ".", DryRun: dryRunFlag, Progress: cmd.ErrOrStderr()}
+		report := runner.Run(ctx, file, vars)
+
+		if err := writeReport(cmd, report); err != nil {
+			return err
+		}
+		if report.Failed() {
+			return errStepFailed
+		}
+		return nil
+	},
+}
+
+// writeReport writes the report of a batch run to --report, or to stdout
+func writeReport(cmd *cobra.Command, report *batch.Report) error {
+	if runReport == "" {
+		fmt.Fprint(cmd.OutOrStdout(), report.Markdown())
+		return nil
+	}
+
+	out, err := os.Create(runReport)
+	if err != nil {
+		return fmt.Errorf("failed to write the report: %w", err)
+	}
+	defer out.Close()
+	if strings.EqualFold(filepath.Ext(runReport), ".json") {
+		err = report.WriteJSON(out)
+	} else {
+		_, err = out.WriteString(report.Markdown())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write the report: %w", err)
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "Report written to %s\n", runReport)
+	return nil
+}
+
+func init() {
+	runCmd.Flags().StringArrayVar(&runVars, "var", nil, "Set a variable, overriding the file's (repeatable; name=value)")
+	runCmd.Flags().StringVar(&runReport, "report", "", "Write the report to this file instead of stdout (JSON if it ends in .json)")
+	runCmd.Flags().BoolVar(&dryRunFlag, "dry-run", false, "Show the file writes and commands the agent would perform without running them")
+	runCmd.Flags().BoolVar(&sandboxFlag, "sandbox", false, "Force enable sandbox mode (default on macOS)")
+	runCmd.Flags().BoolVar(&noSandboxFlag, "no-sandbox", false, "Disable sandbox mode explicitly")
+	runCmd.Flags().StringArrayVar(&workspaceFlag, "workspace", nil, "Add a directory to the workspace (repeatable; append :ro to make it read-only)")
+}
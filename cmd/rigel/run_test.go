@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runBatch(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	defer func() { runVars = nil }()
+	return runRigel(t, append([]string{"run"}, args...)...)
+}
+
+func TestRunCommandErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := runBatch(t, filepath.Join(dir, "missing.yaml"))
+	assert.ErrorContains(t, err, "no such file")
+
+	invalid := filepath.Join(dir, "invalid.yaml")
+	require.NoError(t, os.WriteFile(invalid, []byte("steps:\n  - prompt: a\n    run: b\n"), 0o644))
+	_, err = runBatch(t, invalid)
+	assert.EqualError(t, err, "step 1: needs exactly one of prompt, tool and run")
+
+	valid := filepath.Join(dir, "tasks.yaml")
+	require.NoError(t, os.WriteFile(valid, []byte("steps:\n  - run: echo {{name}}\n"), 0o644))
+	_, err = runBatch(t, valid, "--var", "name")
+	assert.EqualError(t, err, `invalid --var "name": use name=value`)
+
+	failing := filepath.Join(dir, "failing.yaml")
+	require.NoError(t, os.WriteFile(failing, []byte("steps:\n  - run: exit 3\n"), 0o644))
+	out, err := runBatch(t, failing)
+	assert.ErrorIs(t, err, errStepFailed)
+	assert.NotContains(t, out, errStepFailed.Error(), "the report is the only output")
+}
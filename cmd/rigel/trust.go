@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mizzy/rigel/internal/trust"
+	"github.com/spf13/cobra"
+)
+
+var revokeFlag bool
+
+var trustCmd = &cobra.Command{
+	Use:   "trust [dir]",
+	Short: "Trust a directory, or forget whether it is trusted",
+	Long: `Trust a directory, the current one by default, and the directories below it:
+rigel then loads its AGENTS.md, memory notes and .env, and the agent may write
+files and run its tests and build there. A chat started in a directory nothing
+was decided about asks first; one-shot prompts, rigel run and rigel serve
+treat it as untrusted. Untrusted directories are read-only.
+
+With --revoke the decision about the directory is forgotten, so that the next
+chat started there asks again.`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := "."
+		if len(args) == 1 {
+			dir = args[0]
+		}
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		store, err := trust.NewStore()
+		if err != nil {
+			return err
+		}
+
+		out := cmd.OutOrStdout()
+		if revokeFlag {
+			forgot, err := store.Forget(abs)
+			if err != nil {
+				return err
+			}
+			if !forgot {
+				fmt.Fprintf(out, "Nothing was decided about %s.\n", abs)
+				return nil
+			}
+			fmt.Fprintf(out, "Forgot whether %s is trusted; the next chat started there asks again.\n", abs)
+			return nil
+		}
+		if info, err := os.Stat(abs); err != nil || !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", abs)
+		}
+		if err := store.Set(abs, true); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Trusted %s.\n", abs)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(trustCmd)
+	trustCmd.Flags().BoolVar(&revokeFlag, "revoke", false, "Forget whether the directory is trusted instead")
+}
+
+// trustWorkingDir returns whether the working directory is trusted, asking
+// the user the first time a chat starts in it. If the decisions can't be
+// read or written, the user is asked each time.
+func trustWorkingDir() bool {
+	dir, store, ok := workingDirStore()
+	if !ok {
+		return false
+	}
+	return askTrust(store, dir, os.Stdin, os.Stderr)
+}
+
+// workingDirTrusted returns whether the working directory was trusted,
+// without asking, for runs that can't ask, such as one-shot prompts, rigel
+// run and rigel serve. Untrusted directories are announced on out.
+func workingDirTrusted(out io.Writer) bool {
+	dir, store, ok := workingDirStore()
+	if !ok {
+		return false
+	}
+	return lookupTrust(store, dir, out)
+}
+
+// workingDirStore returns the working directory and the trust decisions,
+// which are nil if they can't be read
+func workingDirStore() (string, *trust.Store, bool) {
+	dir, err := os.Getwd()
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return "", nil, false
+	}
+	store, err := trust.NewStore()
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return dir, nil, true
+	}
+	return dir, store, true
+}
+
+// lookupTrust returns whether dir was trusted, without asking: a directory
+// nothing was decided about isn't. Untrusted directories are announced as
+// read-only.
+func lookupTrust(store *trust.Store, dir string, out io.Writer) bool {
+	trusted := false
+	if store != nil {
+		var err error
+		if trusted, _, err = store.Lookup(dir); err != nil {
+			fmt.Fprintf(out, "Warning: %v\n", err)
+		}
+	}
+	if !trusted {
+		announceReadOnly(dir, out)
+	}
+	return trusted
+}
+
+// askTrust returns whether dir is trusted as decided before or, if nothing
+// was, as the user answers on in, remembering the answer. Untrusted
+// directories are announced as read-only.
+func askTrust(store *trust.Store, dir string, in io.Reader, out io.Writer) bool {
+	trusted, known := false, false
+	if store != nil {
+		var err error
+		if trusted, known, err = store.Lookup(dir); err != nil {
+			fmt.Fprintf(out, "Warning: %v\n", err)
+		}
+	}
+
+	if !known {
+		fmt.Fprintf(out, "Do you trust the files in %s?\n", dir)
+		fmt.Fprintln(out, "rigel would load its AGENTS.md and .env, and the agent could write files and run commands there.")
+		fmt.Fprint(out, "Trust this directory? [y/N] ")
+		answer, _ := bufio.NewReader(in).ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		trusted = answer == "y" || answer == "yes"
+		if store != nil {
+			if err := store.Set(dir, trusted); err != nil {
+				fmt.Fprintf(out, "Warning: the answer won't be remembered: %v\n", err)
+			}
+		}
+	}
+
+	if !trusted {
+		announceReadOnly(dir, out)
+	}
+	return trusted
+}
+
+// announceReadOnly tells that dir isn't trusted and how to trust it
+func announceReadOnly(dir string, out io.Writer) {
+	fmt.Fprintf(out, "🔒 Read-only: %s isn't trusted, so the agent only reads its files. Run 'rigel trust' to trust it.\n", dir)
+}
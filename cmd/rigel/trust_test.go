@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mizzy/rigel/internal/trust"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAskTrust(t *testing.T) {
+	store := trust.NewStoreAt(filepath.Join(t.TempDir(), "trust.json"))
+	projects := t.TempDir()
+	app := filepath.Join(projects, "app")
+	scratch := filepath.Join(projects, "scratch")
+
+	var out bytes.Buffer
+	assert.True(t, askTrust(store, app, strings.NewReader("y\n"), &out))
+	assert.Contains(t, out.String(), "Trust this directory? [y/N]")
+	assert.NotContains(t, out.String(), "Read-only")
+
+	// The answer is remembered
+	out.Reset()
+	assert.True(t, askTrust(store, app, strings.NewReader(""), &out))
+	assert.Empty(t, out.String())
+
+	out.Reset()
+	assert.False(t, askTrust(store, scratch, strings.NewReader("\n"), &out), "no is the default")
+	assert.Contains(t, out.String(), "🔒 Read-only: "+scratch)
+	out.Reset()
+	assert.False(t, askTrust(store, scratch, strings.NewReader("y\n"), &out))
+	assert.NotContains(t, out.String(), "Trust this directory?")
+
+	// Without a store the user is asked each time
+	out.Reset()
+	assert.True(t, askTrust(nil, scratch, strings.NewReader("Yes\n"), &out))
+	assert.Contains(t, out.String(), "Trust this directory?")
+}
+
+func TestLookupTrust(t *testing.T) {
+	store := trust.NewStoreAt(filepath.Join(t.TempDir(), "trust.json"))
+	projects := t.TempDir()
+	app := filepath.Join(projects, "app")
+	require.NoError(t, store.Set(app, true))
+
+	var out bytes.Buffer
+	assert.True(t, lookupTrust(store, filepath.Join(app, "cmd"), &out))
+	assert.Empty(t, out.String())
+
+	// Without anyone to ask, a directory nothing was decided about isn't
+	// trusted
+	scratch := filepath.Join(projects, "scratch")
+	assert.False(t, lookupTrust(store, scratch, &out))
+	assert.Contains(t, out.String(), "🔒 Read-only: "+scratch)
+	_, known, err := store.Lookup(scratch)
+	require.NoError(t, err)
+	assert.False(t, known, "nothing is decided without asking")
+
+	out.Reset()
+	assert.False(t, lookupTrust(nil, app, &out))
+	assert.Contains(t, out.String(), "🔒 Read-only: "+app)
+}
+
+func TestTrustCommand(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	t.Cleanup(func() { revokeFlag = false })
+
+	out, err := runRigel(t, "trust", dir)
+	require.NoError(t, err)
+	assert.Equal(t, "Trusted "+dir+".\n", out)
+	store, err := trust.NewStore()
+	require.NoError(t, err)
+	trusted, known, err := store.Lookup(dir)
+	require.NoError(t, err)
+	assert.True(t, known)
+	assert.True(t, trusted)
+
+	out, err = runRigel(t, "trust", "--revoke", dir)
+	require.NoError(t, err)
+	assert.Contains(t, out, "Forgot whether "+dir+" is trusted")
+	_, known, err = store.Lookup(dir)
+	require.NoError(t, err)
+	assert.False(t, known)
+
+	out, err = runRigel(t, "trust", "--revoke", dir)
+	require.NoError(t, err)
+	assert.Equal(t, "Nothing was decided about "+dir+".\n", out)
+
+	revokeFlag = false
+	_, err = runRigel(t, "trust", filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mizzy/rigel/internal/config"
+	"github.com/mizzy/rigel/internal/github"
+	"github.com/mizzy/rigel/internal/update"
+	"github.com/mizzy/rigel/internal/version"
+	"github.com/spf13/cobra"
+)
+
+// updateCheckTimeout bounds the background check for a new release at
+// startup
+const updateCheckTimeout = 5 * time.Second
+
+var (
+	checkOnlyFlag bool
+
+	// releasesAPIURL is the API rigel's releases are fetched from; tests
+	// point it at a fake server
+	releasesAPIURL = github.DefaultAPIURL
+)
+
+var updateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Update rigel to the latest release",
+	Long: `Check GitHub for a newer release of rigel and replace the running executable
+with it. The download is verified against the release's checksums, and their
+signature when rigel was built with a release key, before the executable is
+replaced.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := cmd.OutOrStdout()
+		ctx := cmd.Context()
+		if ctx == nil {
+			ctx = context.Background()
+		}
+
+		release, newer, err := update.Latest(ctx, github.NewClient(releasesAPIURL, ""), version.Version)
+		if err != nil {
+			return err
+		}
+		if !newer {
+			fmt.Fprintf(out, "rigel %s is up to date\n", version.Version)
+			return nil
+		}
+		fmt.Fprintf(out, "rigel %s is available (you have %s): %s\n", release.Version, version.Version, release.URL)
+		if checkOnlyFlag {
+			return nil
+		}
+
+		exe, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("failed to find the rigel executable: %w", err)
+		}
+		if exe, err = filepath.EvalSymlinks(exe); err != nil {
+			return fmt.Errorf("failed to find the rigel executable: %w", err)
+		}
+		if err := update.Apply(ctx, release, exe); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Updated %s to %s\n", exe, release.Version)
+		return nil
+	},
+}
+
+// announceUpdate prints a notice on stderr if an earlier check found a newer
+// release, and checks again in the background once a day
+func announceUpdate(c *config.Config) {
+	if c == nil || !c.UpdateCheck || os.Getenv("RIGEL_TEST_MODE") == "1" {
+		return
+	}
+	notice, due := update.Notice(version.Version)
+	if notice != "" {
+		fmt.Fprintln(os.Stderr, notice)
+	}
+	if due {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), updateCheckTimeout)
+			defer cancel()
+			_ = update.Check(ctx, github.NewClient(releasesAPIURL, ""))
+		}()
+	}
+}
+
+func init() {
+	updateCmd.Flags().BoolVar(&checkOnlyFlag, "check-only", false, "Only report whether a newer release is available")
+	rootCmd.AddCommand(updateCmd)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mizzy/rigel/internal/version"
+)
+
+func TestUpdateCheckOnly(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/repos/mizzy/rigel/releases/latest", r.URL.Path)
+		w.Write([]byte(`{"tag_name":"v99.0.0","html_url":"https://github.com/mizzy/rigel/releases/v99.0.0"}`))
+	}))
+	defer server.Close()
+
+	apiURL, current := releasesAPIURL, version.Version
+	releasesAPIURL = server.URL
+	t.Cleanup(func() {
+		releasesAPIURL, version.Version = apiURL, current
+		checkOnlyFlag = false
+	})
+
+	out, err := runRigel(t, "update", "--check-only")
+	require.NoError(t, err)
+	assert.Equal(t, "rigel 99.0.0 is available (you have "+version.Version+"): https://github.com/mizzy/rigel/releases/v99.0.0\n", out)
+
+	version.Version = "99.0.0"
+	out, err = runRigel(t, "update", "--check-only")
+	require.NoError(t, err)
+	assert.Equal(t, "rigel 99.0.0 is up to date\n", out)
+}
@@ -242,6 +242,10 @@ func (m *MockLLMProvider) Stream(ctx context.Context, prompt string) (<-chan llm
 	return ch, nil
 }
 
+func (m *MockLLMProvider) StreamWithHistory(ctx context.Context, messages []llm.Message, opts llm.GenerateOptions) (<-chan llm.StreamResponse, error) {
+	return m.Stream(ctx, messages[len(messages)-1].Content)
+}
+
 func (m *MockLLMProvider) ListModels(ctx context.Context) ([]llm.Model, error) {
 	return []llm.Model{
 		{Name: "test-model-1"},
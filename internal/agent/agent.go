@@ -3,24 +3,52 @@ package agent
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/mizzy/rigel/internal/llm"
+	"github.com/mizzy/rigel/internal/persona"
+	"github.com/mizzy/rigel/internal/quarantine"
 	"github.com/mizzy/rigel/internal/tools"
 )
 
 type Agent struct {
+	// Guards memory, autoToolEnabled, fastIntent, dryRun, streaming,
+	// streamHandler, persona, images and jobs, which frontends change while
+	// requests run
+	mu sync.RWMutex
+
 	provider        llm.Provider
 	tools           []tools.Tool
-	memory          *Memory
+	memory          Memory
 	promptAnalyzer  *PromptAnalyzer
 	autoToolEnabled bool
+	fastIntent      bool // Skip or fold in intent analysis where possible
+	dryRun          bool // Describe tool operations instead of running them
+	streaming       bool // Stream responses, keeping the part received when interrupted
+	streamHandler   func(chunk string)
 	progressDisplay ProgressDisplay
+	toolRecorder    ToolRecorder
+	planReviewer    PlanReviewer
+	persona         *persona.Persona // How the agent answers; nil for the default
+	images          []llm.Image      // Attached to the next prompt
+	jobs            []*Job           // Started in the background, numbered from 1
+
+	maxFixIterations int           // Attempts to fix build and lint problems in written files
+	toolTimeout      time.Duration // How long each tool may run; 0 for no limit
+	contextProviders []ContextProvider
+
+	maxSubagents int  // Sub-agents working on delegated subtasks at once
+	subagent     bool // Working on a subtask, which it can't delegate
 }
 
-type Memory struct {
-	conversationHistory []Message
-	context             map[string]interface{}
+// ContextProvider supplies context for a prompt, such as the definitions of
+// the symbols it mentions
+type ContextProvider interface {
+	ProvideContext(ctx context.Context, prompt string) (string, error)
 }
 
 type Message struct {
@@ -30,15 +58,15 @@ type Message struct {
 
 func New(provider llm.Provider) *Agent {
 	return &Agent{
-		provider: provider,
-		memory: &Memory{
-			conversationHistory: []Message{},
-			context:             make(map[string]interface{}),
-		},
+		provider:        provider,
+		memory:          NewInMemory(),
 		tools:           []tools.Tool{},
 		promptAnalyzer:  NewPromptAnalyzer(provider),
 		autoToolEnabled: true,
 		progressDisplay: &ConsoleProgressDisplay{},
+
+		maxFixIterations: defaultMaxFixIterations,
+		maxSubagents:     defaultMaxSubagents,
 	}
 }
 
@@ -46,85 +74,161 @@ func (a *Agent) RegisterTool(tool tools.Tool) {
 	a.tools = append(a.tools, tool)
 }
 
+// AddContextProvider adds a source of context included with each prompt
+func (a *Agent) AddContextProvider(provider ContextProvider) {
+	a.contextProviders = append(a.contextProviders, provider)
+}
+
+// gatherContext collects the context the providers supply for a prompt.
+// Providers that fail are skipped.
+func (a *Agent) gatherContext(ctx context.Context, prompt string) string {
+	var sections []string
+	for _, provider := range a.contextProviders {
+		section, err := provider.ProvideContext(ctx, prompt)
+		if err != nil {
+			slog.Debug("context provider failed", "error", err)
+		}
+		if section = strings.TrimSpace(section); section != "" {
+			sections = append(sections, section)
+		}
+	}
+	return strings.Join(sections, "\n\n")
+}
+
 func (a *Agent) Execute(ctx context.Context, task string) (string, error) {
 	var toolResults []ToolExecutionResult
 	var finalResponse strings.Builder
 
-	// Phase 1: Analyze prompt for file operations with conversation history
-	if a.autoToolEnabled {
-		matches := a.promptAnalyzer.AnalyzePromptWithHistory(task, a.memory.conversationHistory)
+	// Settings changed while the request runs apply to the next one
+	memory := a.getMemory()
+	dryRun := a.IsDryRun()
+	streaming, streamHandler := a.streamSettings()
+	images := a.takeImages()
+	a.refreshContextFiles(memory)
+
+	// Phase 1: Analyze prompt for file operations with conversation history.
+	// A model that can use tools may answer right away instead.
+	var answer *reply
+	if a.IsAutoToolEnabled() {
+		var matches []FileOperationMatch
+		fastIntent := a.IsFastIntent()
+		switch {
+		case fastIntent && !mayNeedTools(task):
+			// Nothing in the prompt calls for files, tests or the web
+		case fastIntent && a.supportsTools():
+			matches, answer = a.answerOrRequestTools(ctx, task, images, streaming, streamHandler)
+		default:
+			matches = a.promptAnalyzer.AnalyzePromptWithHistory(task, memory.Messages())
+		}
+		if a.subagent {
+			matches = slices.DeleteFunc(matches, func(match FileOperationMatch) bool {
+				return match.Intent == IntentDelegate
+			})
+		}
+		// Web searches are dropped unless web search is enabled
+		if a.findTool("web_search") == nil {
+			matches = slices.DeleteFunc(matches, func(match FileOperationMatch) bool {
+				return match.Intent == IntentWebSearch
+			})
+		}
 		if len(matches) > 0 {
-			// Phase 2: Create structured tasks from intents
-			tasks := CreateTasksFromMatches(matches)
-
-			finalResponse.WriteString("I'll help you with that. Here's what I need to do:\n\n")
-
-			// Show task list to user
-			for i, taskItem := range tasks {
-				finalResponse.WriteString(fmt.Sprintf("%d. %s\n", i+1, taskItem.Description))
+			// Phase 2: Plan the tasks, letting the user review the plan. A
+			// plan left without steps is as good as cancelled.
+			plan := NewPlan(CreateTasksFromMatches(matches))
+			if !dryRun && a.planReviewer != nil && (!a.planReviewer.ReviewPlan(ctx, plan) || plan.Len() == 0) {
+				if err := ctx.Err(); err != nil {
+					return "", err
+				}
+				response := "Plan cancelled: nothing was changed."
+				memory.AddMessages(
+					Message{Role: "user", Content: task},
+					Message{Role: "assistant", Content: response},
+				)
+				return response, nil
 			}
-			finalResponse.WriteString("\nExecuting tasks...\n\n")
 
 			// Phase 3: Process tasks and generate content if needed
-			for i, taskItem := range tasks {
+			for i, taskItem := range plan.tasks() {
 				if taskItem.Match.Intent == IntentWrite && taskItem.Match.Content == "<GENERATE_TEXT>" {
 					// Generate content using LLM with conversation context
-					contentPrompt := a.buildContentGenerationPrompt(task, a.memory.conversationHistory)
+					contentPrompt := a.buildContentGenerationPrompt(task, memory.Messages())
 					generatedContent, err := a.provider.Generate(ctx, contentPrompt)
 					if err == nil {
-						tasks[i].Match.Content = strings.TrimSpace(generatedContent)
+						plan.setContent(i, strings.TrimSpace(generatedContent))
 					} else {
-						tasks[i].Match.Content = "Sample text generated for user request."
+						plan.setContent(i, "Sample text generated for user request.")
 					}
 				}
 			}
 
-			// Phase 4: Execute tasks with progress tracking
-			if uiDisplay, ok := a.progressDisplay.(*UIProgressDisplay); ok {
-				// Execute with progress tracking
-				toolResults = a.ExecuteTasksWithProgress(ctx, tasks, a.progressDisplay)
+			if dryRun {
+				response := describeDryRun(plan.tasks())
+				memory.AddMessages(
+					Message{Role: "user", Content: task},
+					Message{Role: "assistant", Content: response},
+				)
+				return response, nil
+			}
+
+			// Phase 4: Execute the plan step by step, then build and lint,
+			// fixing problems in the files written
+			toolResults = a.runPlan(ctx, plan)
+			toolResults = append(toolResults, a.fixWrittenFiles(ctx, task, toolResults)...)
+			if a.toolRecorder != nil {
+				for _, result := range toolResults {
+					a.toolRecorder.RecordTool(result)
+				}
+			}
+
+			finalResponse.WriteString("I'll help you with that. Here's what I did:\n\n")
+			finalResponse.WriteString(plan.Checklist())
+			finalResponse.WriteString("\n")
 
+			if uiDisplay, ok := a.progressDisplay.(*UIProgressDisplay); ok {
 				// Add progress messages
 				progressMessages := uiDisplay.GetAllMessages()
 				if len(progressMessages) > 0 {
 					finalResponse.WriteString(strings.Join(progressMessages, "\n"))
 					finalResponse.WriteString("\n\n")
 				}
-			} else {
-				// Execute without UI progress display
-				toolResults = a.ExecuteTasksWithProgress(ctx, tasks, a.progressDisplay)
 			}
 
 			// Build response with tool results (detailed output)
 			finalResponse.WriteString("Results:\n")
 			for _, result := range toolResults {
-				if result.Error == nil && result.Output != "" {
+				switch {
+				case result.Error != nil:
+				case result.Diff != "":
+					finalResponse.WriteString(fmt.Sprintf("📝 %s changes:\n```diff\n%s```\n\n", result.Tool, result.Diff))
+				case result.Output != "":
 					finalResponse.WriteString(fmt.Sprintf("📄 %s output:\n%s\n\n", result.Tool, result.Output))
 				}
 			}
+			// Pages from the web may try to instruct the model
+			for _, result := range toolResults {
+				if result.Tool == "web_search" && result.Error == nil {
+					if warning := quarantine.Warning("web search results", result.Output); warning != "" {
+						finalResponse.WriteString(warning + "\n\n")
+					}
+				}
+			}
 		}
 	}
 
-	// Generate AI response
-	systemPrompt := a.buildSystemPrompt()
-	opts := llm.GenerateOptions{
-		SystemPrompt: systemPrompt,
-		Temperature:  0.7,
-	}
-
-	// Include tool results in the prompt if available
-	var userPrompt string
-	if len(toolResults) > 0 {
-		toolContext := a.buildToolContext(toolResults)
-		userPrompt = fmt.Sprintf("%s\n\nTool execution results:\n%s", a.buildUserPrompt(task), toolContext)
-	} else {
-		userPrompt = a.buildUserPrompt(task)
-	}
+	// Generate AI response, unless the model already gave it
+	citations := Citations(toolResults)
+	if answer == nil {
+		opts := a.generateOptions(a.buildSystemPrompt())
+		userPrompt := a.buildPrompt(ctx, task, toolResults)
 
-	response, err := a.provider.GenerateWithOptions(ctx, userPrompt, opts)
-	if err != nil {
-		return "", fmt.Errorf("failed to execute task: %w", err)
+		// Frontends showing the response as it streams show the tool results first
+		if streaming && streamHandler != nil && finalResponse.Len() > 0 {
+			streamHandler(finalResponse.String() + "---\n\n")
+		}
+		response, err := a.generate(ctx, userPrompt, images, opts, streaming, streamHandler)
+		answer = &reply{response: response, err: err}
 	}
+	response, err := answer.response, answer.err
 
 	// Combine tool results and AI response
 	if finalResponse.Len() > 0 {
@@ -133,7 +237,26 @@ func (a *Agent) Execute(ctx context.Context, task string) (string, error) {
 		response = finalResponse.String()
 	}
 
-	a.memory.conversationHistory = append(a.memory.conversationHistory,
+	if err != nil {
+		// Keep what the model wrote before the request was cancelled
+		if ctx.Err() != nil && strings.TrimSpace(response) != "" {
+			interrupted := &Interrupted{Partial: response, Cause: ctx.Err()}
+			memory.AddMessages(
+				Message{Role: "user", Content: task},
+				Message{Role: "assistant", Content: interrupted.Response()},
+			)
+			return "", interrupted
+		}
+		return "", fmt.Errorf("failed to execute task: %w", err)
+	}
+
+	// End with the sources the tools provided, which the model's markers
+	// refer to
+	if footnotes := Footnotes(citations); footnotes != "" {
+		response = strings.TrimRight(response, "\n") + "\n\n" + footnotes
+	}
+
+	memory.AddMessages(
 		Message{Role: "user", Content: task},
 		Message{Role: "assistant", Content: response},
 	)
@@ -155,15 +278,56 @@ func (a *Agent) buildSystemPrompt() string {
 		for _, tool := range a.tools {
 			prompts = append(prompts, fmt.Sprintf("- %s: %s", tool.Name(), tool.Description()))
 		}
+		prompts = append(prompts, "\n"+quarantine.Instruction)
+	}
+
+	if p := a.Persona(); p != nil && p.Prompt != "" {
+		prompts = append(prompts, "\n"+p.Prompt)
 	}
 
 	return strings.Join(prompts, "\n")
 }
 
+// generateOptions returns the options of a response, as the persona sets
+// them
+func (a *Agent) generateOptions(systemPrompt string) llm.GenerateOptions {
+	opts := llm.GenerateOptions{
+		SystemPrompt: systemPrompt,
+		Temperature:  0.7,
+	}
+	if p := a.Persona(); p != nil {
+		if p.Temperature != nil {
+			opts.Temperature = *p.Temperature
+		}
+		opts.MaxTokens = p.MaxTokens
+		opts.TopP = p.TopP
+	}
+	return opts
+}
+
+// buildPrompt builds the prompt asking for the response to a task, with the
+// results of the tools run for it and the code context of the task
+func (a *Agent) buildPrompt(ctx context.Context, task string, toolResults []ToolExecutionResult) string {
+	var userPrompt string
+	if len(toolResults) > 0 {
+		toolContext := a.buildToolContext(toolResults)
+		userPrompt = fmt.Sprintf("%s\n\nTool execution results:\n%s", a.buildUserPrompt(task), toolContext)
+		if len(Citations(toolResults)) > 0 {
+			userPrompt += "\n\n" + CitationInstruction
+		}
+	} else {
+		userPrompt = a.buildUserPrompt(task)
+	}
+	if codeContext := a.gatherContext(ctx, task); codeContext != "" {
+		userPrompt = fmt.Sprintf("%s\n\nRelevant code:\n%s", userPrompt, quarantine.Wrap("code", codeContext))
+	}
+	return userPrompt
+}
+
 func (a *Agent) buildUserPrompt(task string) string {
-	if len(a.memory.conversationHistory) > 0 {
+	if messages := a.getMemory().Messages(); len(messages) > 0 {
 		var history []string
-		for _, msg := range a.memory.conversationHistory {
+		for _, msg := range messages {
 			history = append(history, fmt.Sprintf("%s: %s", msg.Role, msg.Content))
 		}
 		return fmt.Sprintf("Previous conversation:\n%s\n\nCurrent task: %s",
@@ -173,26 +337,70 @@ func (a *Agent) buildUserPrompt(task string) string {
 }
 
 func (a *Agent) ClearMemory() {
-	a.memory.conversationHistory = []Message{}
-	a.memory.context = make(map[string]interface{})
+	a.getMemory().Clear()
+}
+
+// SetMemory replaces where the agent keeps its memory
+func (a *Agent) SetMemory(memory Memory) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.memory = memory
+}
+
+// getMemory returns where the agent keeps its memory
+func (a *Agent) getMemory() Memory {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.memory
+}
+
+// History returns a copy of the conversation history
+func (a *Agent) History() []Message {
+	return a.getMemory().Messages()
+}
+
+// SetHistory replaces the conversation history, e.g. when restoring a session
+func (a *Agent) SetHistory(messages []Message) {
+	a.getMemory().SetMessages(messages)
+}
+
+// RemoveExchange drops the most recent exchange for prompt, the prompt and
+// the answer to it, from the conversation history, e.g. before the prompt
+// is retried. It reports whether there was one.
+func (a *Agent) RemoveExchange(prompt string) bool {
+	removed := false
+	a.getMemory().UpdateMessages(func(messages []Message) []Message {
+		for i := len(messages) - 2; i >= 0; i-- {
+			if messages[i].Role == "user" && messages[i].Content == prompt && messages[i+1].Role == "assistant" {
+				removed = true
+				return append(messages[:i:i], messages[i+2:]...)
+			}
+		}
+		return messages
+	})
+	return removed
 }
 
 func (a *Agent) SetContext(key string, value interface{}) {
-	a.memory.context[key] = value
+	a.getMemory().SetContext(key, value)
 }
 
 func (a *Agent) GetContext(key string) (interface{}, bool) {
-	val, ok := a.memory.context[key]
+	val, ok := a.getMemory().Context()[key]
 	return val, ok
 }
 
 // SetAutoToolEnabled enables or disables automatic tool execution
 func (a *Agent) SetAutoToolEnabled(enabled bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 	a.autoToolEnabled = enabled
 }
 
 // IsAutoToolEnabled returns whether automatic tool execution is enabled
 func (a *Agent) IsAutoToolEnabled() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
 	return a.autoToolEnabled
 }
 
@@ -201,20 +409,74 @@ func (a *Agent) SetProgressDisplay(display ProgressDisplay) {
 	a.progressDisplay = display
 }
 
+// SetToolRecorder sets where the tools the agent runs are recorded
+func (a *Agent) SetToolRecorder(recorder ToolRecorder) {
+	a.toolRecorder = recorder
+}
+
+// SetPersona sets how the agent answers; nil restores the default
+func (a *Agent) SetPersona(p *persona.Persona) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.persona = p
+}
+
+// Persona returns how the agent answers, nil for the default
+func (a *Agent) Persona() *persona.Persona {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.persona
+}
+
+// AttachImages attaches images, such as screenshots, to the next prompt for
+// models that support vision. They are sent with that prompt only.
+func (a *Agent) AttachImages(images ...llm.Image) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.images = append(a.images, images...)
+}
+
+// AttachedImages returns how many images are attached to the next prompt
+func (a *Agent) AttachedImages() int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return len(a.images)
+}
+
+// takeImages returns the images attached to the next prompt, detaching them
+func (a *Agent) takeImages() []llm.Image {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	images := a.images
+	a.images = nil
+	return images
+}
+
 // GetProgressDisplay returns the current progress display implementation
 func (a *Agent) GetProgressDisplay() ProgressDisplay {
 	return a.progressDisplay
 }
 
-// buildToolContext builds context from tool execution results
+// buildToolContext builds context from tool execution results, numbering
+// those that are sources as Citations does
 func (a *Agent) buildToolContext(results []ToolExecutionResult) string {
 	var context []string
+	sources := 0
 	for _, result := range results {
 		if result.Error != nil {
 			context = append(context, fmt.Sprintf("Tool %s failed: %v", result.Tool, result.Error))
-		} else {
-			context = append(context, fmt.Sprintf("Tool %s succeeded: %s", result.Tool, result.Output))
+			continue
+		}
+		wrap := quarantine.Wrap
+		if result.Tool == "web_search" {
+			wrap = quarantine.WrapFetched
+		}
+		line := fmt.Sprintf("Tool %s succeeded:\n%s", result.Tool, wrap(result.Tool, result.Output))
+		if _, ok := citationSource(result); ok {
+			sources++
+			line = fmt.Sprintf("[%d] %s", sources, line)
 		}
+		context = append(context, line)
 	}
 	return strings.Join(context, "\n")
 }
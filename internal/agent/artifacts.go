@@ -0,0 +1,213 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// CodeArtifact is a file a response gives in full in a code block that
+// names it
+type CodeArtifact struct {
+	Path    string
+	Content string
+}
+
+// artifactNames are files without an extension that code blocks are named
+// for
+var artifactNames = map[string]bool{
+	"Dockerfile":    true,
+	"Containerfile": true,
+	"Makefile":      true,
+	"Justfile":      true,
+	"Procfile":      true,
+	"Gemfile":       true,
+	"Vagrantfile":   true,
+}
+
+// artifactAttr matches a file named in a fence's info string, as in
+// ```go title="main.go"
+var artifactAttr = regexp.MustCompile(`^(?:file|filename|path|title)=["']?([^"']+)["']?$`)
+
+// artifactLabel matches what may surround a file named on the line before a
+// code block, as in **`main.go`**: or ### File: main.go
+var artifactLabel = regexp.MustCompile(`(?i)^(?:#+\s*|[-*]\s+)?(?:file(?:name)?:\s*)?[*_` + "`" + `]*([^\s*_` + "`" + `]+?)[*_` + "`" + `]*:?$`)
+
+// artifactComment matches a file named in a comment on the first line of a
+// code block, as in // main.go or # file: Dockerfile
+var artifactComment = regexp.MustCompile(`(?i)^(?://|#|--|;|/\*|<!--)\s*(?:file(?:name)?:\s*)?(\S+?)\s*(?:\*/|-->)?$`)
+
+// ExtractArtifacts returns the code blocks of a response that name the file
+// they hold: in the fence's info string (```go:main.go, ```Dockerfile or
+// ```go title="main.go"), on the line before the block, or in a comment on
+// its first line. A later block for the same file replaces an earlier one.
+func ExtractArtifacts(response string) []CodeArtifact {
+	var artifacts []CodeArtifact
+	seen := map[string]int{}
+	lines := strings.Split(response, "\n")
+	for i := 0; i < len(lines); i++ {
+		fence, info, ok := openingFence(lines[i])
+		if !ok {
+			continue
+		}
+		end := i + 1
+		for end < len(lines) && !closesFence(lines[end], fence) {
+			end++
+		}
+		if end == len(lines) {
+			// A block cut short, as by an interrupted response, isn't whole
+			break
+		}
+
+		body := lines[i+1 : end]
+		if isDiff(info) {
+			// Changes to a file aren't its content
+			i = end
+			continue
+		}
+		path := artifactFromInfo(info)
+		if path == "" && i > 0 {
+			path = artifactFromLabel(lines[i-1])
+		}
+		if path == "" && len(body) > 0 {
+			path = artifactFromComment(body[0])
+		}
+		if path != "" {
+			artifact := CodeArtifact{Path: path, Content: strings.Join(body, "\n") + "\n"}
+			if j, ok := seen[path]; ok {
+				artifacts[j] = artifact
+			} else {
+				seen[path] = len(artifacts)
+				artifacts = append(artifacts, artifact)
+			}
+		}
+		i = end
+	}
+	return artifacts
+}
+
+// openingFence returns the backticks opening a code block and its info
+// string
+func openingFence(line string) (string, string, bool) {
+	trimmed := strings.TrimSpace(line)
+	n := len(trimmed) - len(strings.TrimLeft(trimmed, "`"))
+	if n < 3 {
+		return "", "", false
+	}
+	return trimmed[:n], strings.TrimSpace(trimmed[n:]), true
+}
+
+// closesFence reports whether line closes a code block opened with fence
+func closesFence(line, fence string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, "`") == ""
+}
+
+// isDiff reports whether a fence's info string marks a diff
+func isDiff(info string) bool {
+	lang, _, _ := strings.Cut(info, " ")
+	lang, _, _ = strings.Cut(lang, ":")
+	return lang == "diff" || lang == "patch"
+}
+
+// artifactFromInfo returns the file named in a fence's info string
+func artifactFromInfo(info string) string {
+	fields := strings.Fields(info)
+	for i, field := range fields {
+		if m := artifactAttr.FindStringSubmatch(field); m != nil && artifactPath(m[1]) {
+			return m[1]
+		}
+		if i == 0 {
+			if _, path, ok := strings.Cut(field, ":"); ok && artifactPath(path) {
+				return path
+			}
+		}
+		if looksLikeFile(field) && artifactPath(field) {
+			return field
+		}
+	}
+	return ""
+}
+
+// artifactFromLabel returns the file named alone on the line before a code
+// block
+func artifactFromLabel(line string) string {
+	m := artifactLabel.FindStringSubmatch(strings.TrimSpace(line))
+	if m == nil || !looksLikeFile(m[1]) || !artifactPath(m[1]) {
+		return ""
+	}
+	return m[1]
+}
+
+// artifactFromComment returns the file named in a comment on the first line
+// of a code block
+func artifactFromComment(line string) string {
+	m := artifactComment.FindStringSubmatch(strings.TrimSpace(line))
+	if m == nil || !looksLikeFile(m[1]) || !artifactPath(m[1]) {
+		return ""
+	}
+	return m[1]
+}
+
+// looksLikeFile reports whether name is a file name rather than a language
+// or a word: it has an extension or a directory, or is a well-known file
+// like Dockerfile
+func looksLikeFile(name string) bool {
+	base := filepath.Base(name)
+	if artifactNames[base] {
+		return true
+	}
+	return strings.Contains(name, "/") || (strings.Contains(base, ".") && !strings.HasSuffix(base, "."))
+}
+
+// artifactChars are the characters of the files code blocks are saved to
+var artifactChars = regexp.MustCompile(`^[\w.][\w./-]*$`)
+
+// artifactPath reports whether a file may be written where a code block
+// names it: a plain name within the working directory
+func artifactPath(path string) bool {
+	return artifactChars.MatchString(path) && filepath.IsLocal(path)
+}
+
+// SaveArtifacts writes the files of code blocks as a plan, which the user
+// reviews first if plans are reviewed, and describes what it wrote, with
+// the changes to the files it overwrote. In dry-run mode it only describes
+// the writes.
+func (a *Agent) SaveArtifacts(ctx context.Context, artifacts []CodeArtifact) (string, error) {
+	matches := make([]FileOperationMatch, len(artifacts))
+	for i, artifact := range artifacts {
+		matches[i] = FileOperationMatch{Intent: IntentWrite, FilePath: artifact.Path, Content: artifact.Content}
+	}
+	plan := NewPlan(CreateTasksFromMatches(matches))
+	if a.IsDryRun() {
+		return describeDryRun(plan.tasks()), nil
+	}
+	if a.planReviewer != nil && (!a.planReviewer.ReviewPlan(ctx, plan) || plan.Len() == 0) {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		return "Not saved: nothing was changed.", nil
+	}
+
+	results := a.runPlan(ctx, plan)
+	if a.toolRecorder != nil {
+		for _, result := range results {
+			a.toolRecorder.RecordTool(result)
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Saved the code blocks of the last response:\n\n")
+	sb.WriteString(plan.Checklist())
+	for _, result := range results {
+		switch {
+		case result.Error != nil:
+			sb.WriteString(fmt.Sprintf("\n❌ %s failed: %v\n", result.Tool, result.Error))
+		case result.Diff != "":
+			sb.WriteString(fmt.Sprintf("\n📝 %s changes:\n```diff\n%s```\n", result.Tool, result.Diff))
+		}
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,87 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mizzy/rigel/internal/tools"
+)
+
+func TestExtractArtifacts(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     []CodeArtifact
+	}{
+		{"file in the info string", "Here it is:\n\n```go:cmd/main.go\npackage main\n```\n",
+			[]CodeArtifact{{Path: "cmd/main.go", Content: "package main\n"}}},
+		{"file as the language", "```Dockerfile\nFROM golang:1.25\n```",
+			[]CodeArtifact{{Path: "Dockerfile", Content: "FROM golang:1.25\n"}}},
+		{"title attribute", "```yaml title=\"config/app.yaml\"\nport: 80\n```",
+			[]CodeArtifact{{Path: "config/app.yaml", Content: "port: 80\n"}}},
+		{"file on the line before", "**`Makefile`**:\n```make\nall:\n\tgo build\n```",
+			[]CodeArtifact{{Path: "Makefile", Content: "all:\n\tgo build\n"}}},
+		{"heading before", "### File: web/index.html\n```html\n<p>hi</p>\n```",
+			[]CodeArtifact{{Path: "web/index.html", Content: "<p>hi</p>\n"}}},
+		{"comment on the first line", "```python\n# file: app.py\nprint(1)\n```",
+			[]CodeArtifact{{Path: "app.py", Content: "# file: app.py\nprint(1)\n"}}},
+		{"later block replaces earlier", "```go:a.go\nv1\n```\n\n```go:b.go\nb\n```\n\n```go:a.go\nv2\n```",
+			[]CodeArtifact{{Path: "a.go", Content: "v2\n"}, {Path: "b.go", Content: "b\n"}}},
+		{"no file named", "Run this:\n```sh\n#!/bin/sh\ngo test ./...\n```\nThen:\n```go\nfmt.Println()\n```", nil},
+		{"sentence before", "Here's the Dockerfile:\n```\nFROM alpine\n```", nil},
+		{"diffs skipped", "main.go\n```diff\n-a\n+b\n```", nil},
+		{"outside the working directory", "```go:../x.go\nx\n```\n```go:/etc/passwd\nx\n```", nil},
+		{"unclosed block", "```go:main.go\npackage main", nil},
+		{"longer fence", "````md:README.md\n```sh\nmake\n```\n````",
+			[]CodeArtifact{{Path: "README.md", Content: "```sh\nmake\n```\n"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ExtractArtifacts(tt.response))
+		})
+	}
+}
+
+func TestSaveArtifacts(t *testing.T) {
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.WriteFile("main.go", []byte("package old\n"), 0644))
+
+	a := New(&MockProvider{})
+	a.SetProgressDisplay(NewUIProgressDisplay())
+	a.RegisterTool(tools.NewFileTool())
+	var recorded recordedTools
+	a.SetToolRecorder(&recorded)
+	artifacts := []CodeArtifact{
+		{Path: "main.go", Content: "package main\n"},
+		{Path: "Dockerfile", Content: "FROM alpine\n\nRUN  apk add git\n"},
+	}
+
+	// Cancelling the review writes nothing
+	a.SetPlanReviewer(&scriptedReviewer{edit: func(plan *Plan) bool { return false }})
+	resp, err := a.SaveArtifacts(context.Background(), artifacts)
+	require.NoError(t, err)
+	assert.Equal(t, "Not saved: nothing was changed.", resp)
+	assert.NoFileExists(t, "Dockerfile")
+
+	a.SetDryRun(true)
+	resp, err = a.SaveArtifacts(context.Background(), artifacts)
+	require.NoError(t, err)
+	assert.Contains(t, resp, "Write 30 bytes to 'Dockerfile'")
+	assert.NoFileExists(t, "Dockerfile")
+	a.SetDryRun(false)
+
+	a.SetPlanReviewer(&scriptedReviewer{edit: func(plan *Plan) bool { return true }})
+	resp, err = a.SaveArtifacts(context.Background(), artifacts)
+	require.NoError(t, err)
+	assert.Contains(t, resp, "[x] 1. Write content to 'main.go'\n[x] 2. Write content to 'Dockerfile'\n")
+	assert.Contains(t, resp, "-package old\n+package main\n")
+	assert.Len(t, recorded, 2)
+
+	content, err := os.ReadFile("Dockerfile")
+	require.NoError(t, err)
+	assert.Equal(t, "FROM alpine\n\nRUN  apk add git\n", string(content), "written as is")
+}
@@ -0,0 +1,131 @@
+package agent
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// CitationInstruction asks the model to mark what it draws from the tool
+// results with their numbers
+const CitationInstruction = "When your answer relies on a numbered tool result, cite it with its number in brackets, like [1]."
+
+// searchMatchPattern matches a line of the search tool's output, as
+// path:line: content
+var searchMatchPattern = regexp.MustCompile(`^(.+?):(\d+): `)
+
+// Citation is a source a response was grounded in: the output of a tool the
+// agent ran, described from the tool's input and output rather than from what
+// the model says it used
+type Citation struct {
+	Number int
+	Source string // What was read or run, e.g. "main.go, lines 1-42"
+}
+
+// Citations numbers the results of tools that informed the response: files
+// read, searches, listings, web searches, test runs and checks. Failed tools
+// and changes such as writes aren't sources.
+func Citations(results []ToolExecutionResult) []Citation {
+	var citations []Citation
+	for _, result := range results {
+		if source, ok := citationSource(result); ok {
+			citations = append(citations, Citation{Number: len(citations) + 1, Source: source})
+		}
+	}
+	return citations
+}
+
+// citationSource describes what a tool read or ran, if it is a source
+func citationSource(result ToolExecutionResult) (string, bool) {
+	if result.Error != nil {
+		return "", false
+	}
+	arg := strings.TrimSpace(strings.TrimPrefix(result.Input, result.Tool))
+
+	switch result.Tool {
+	case "read":
+		lines := strings.Count(strings.TrimSuffix(result.Output, "\n"), "\n") + 1
+		if result.Output == "" || strings.HasPrefix(result.Output, "Binary file: ") {
+			return arg, true
+		}
+		return fmt.Sprintf("%s, lines 1-%d", arg, lines), true
+	case "search":
+		return fmt.Sprintf("search for %q: %s", arg, searchLocations(result.Output)), true
+	case "list":
+		return "listing of " + arg, true
+	case "tree":
+		path, glob, _ := strings.Cut(arg, " --glob ")
+		if glob != "" {
+			return fmt.Sprintf("directory tree of %s (%s)", path, glob), true
+		}
+		return "directory tree of " + path, true
+	case "web_search":
+		source := fmt.Sprintf("web search for %q", result.Input)
+		if urls := webResultURLs(result.Output); len(urls) > 0 {
+			source += ": " + strings.Join(urls, ", ")
+		}
+		return source, true
+	case "test":
+		if result.Input != "" {
+			return "test run of " + result.Input, true
+		}
+		return "test run", true
+	case "check":
+		return "build and lint", true
+	}
+	return "", false
+}
+
+// searchLocations lists the lines the search tool matched, grouped by file,
+// e.g. "a.go:12,40; b.go:3"
+func searchLocations(output string) string {
+	var files []string
+	lines := map[string][]string{}
+	for _, line := range strings.Split(output, "\n") {
+		match := searchMatchPattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		file, n := match[1], match[2]
+		if _, ok := lines[file]; !ok {
+			files = append(files, file)
+		}
+		lines[file] = append(lines[file], n)
+	}
+	if len(files) == 0 {
+		return "no matches"
+	}
+
+	locations := make([]string, len(files))
+	for i, file := range files {
+		locations[i] = file + ":" + strings.Join(lines[file], ",")
+	}
+	return strings.Join(locations, "; ")
+}
+
+// webResultURLs returns the URLs of the web search tool's results
+func webResultURLs(output string) []string {
+	var urls []string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "http://") || strings.HasPrefix(line, "https://") {
+			urls = append(urls, line)
+		}
+	}
+	return urls
+}
+
+// Footnotes renders the sources a response was grounded in, numbered as
+// their markers in the response
+func Footnotes(citations []Citation) string {
+	if len(citations) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("Sources:")
+	for _, c := range citations {
+		sb.WriteString("\n[" + strconv.Itoa(c.Number) + "] " + c.Source)
+	}
+	return sb.String()
+}
@@ -0,0 +1,81 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCitations(t *testing.T) {
+	results := []ToolExecutionResult{
+		{Tool: "read", Input: "read main.go", Output: "package main\n\nfunc main() {}\n"},
+		{Tool: "write", Input: "write notes.txt hello", Output: "File written"},
+		{Tool: "search", Input: "search ParseConfig", Output: "config.go:12: func ParseConfig() {\nconfig.go:40: ParseConfig()\ncmd/main.go:7: ParseConfig()"},
+		{Tool: "read", Input: "read missing.go", Error: errors.New("no such file")},
+		{Tool: "tree", Input: "tree internal --glob *.go", Output: "internal/\n  a.go"},
+		{Tool: "web_search", Input: "bubbletea releases", Output: "1. Releases\n   https://github.com/charmbracelet/bubbletea/releases\n   Notes"},
+		{Tool: "test", Input: "./internal/...", Output: "ok"},
+		{Tool: "check", Input: "check", Output: "OK"},
+		{Tool: "search", Input: "search Nowhere", Output: `No matches for "Nowhere"`},
+	}
+
+	assert.Equal(t, []Citation{
+		{Number: 1, Source: "main.go, lines 1-3"},
+		{Number: 2, Source: `search for "ParseConfig": config.go:12,40; cmd/main.go:7`},
+		{Number: 3, Source: "directory tree of internal (*.go)"},
+		{Number: 4, Source: `web search for "bubbletea releases": https://github.com/charmbracelet/bubbletea/releases`},
+		{Number: 5, Source: "test run of ./internal/..."},
+		{Number: 6, Source: "build and lint"},
+		{Number: 7, Source: `search for "Nowhere": no matches`},
+	}, Citations(results))
+
+	assert.Empty(t, Citations([]ToolExecutionResult{{Tool: "delete", Input: "delete a.txt"}}))
+}
+
+func TestFootnotes(t *testing.T) {
+	assert.Equal(t, "", Footnotes(nil))
+	assert.Equal(t, "Sources:\n[1] main.go, lines 1-3\n[2] build and lint",
+		Footnotes([]Citation{{Number: 1, Source: "main.go, lines 1-3"}, {Number: 2, Source: "build and lint"}}))
+}
+
+func TestExecuteCitesTools(t *testing.T) {
+	mockProvider := new(MockProvider)
+	mockProvider.On("GenerateWithOptions", mock.Anything, analyzing("what does main.go do?"), mock.Anything).
+		Return(`[{"intent":"read","filepath":"main.go","content":""}]`, nil)
+	mockProvider.On("GenerateWithOptions", mock.Anything, mock.MatchedBy(func(prompt string) bool {
+		return strings.Contains(prompt, "[1] Tool read succeeded:\n<untrusted source=\"read\">\npackage main\n") && strings.Contains(prompt, CitationInstruction)
+	}), mock.Anything).Return("It's the entry point [1].", nil)
+
+	fileTool := &MockTool{}
+	fileTool.On("Name").Return("file_operations")
+	fileTool.On("Description").Return("File operations")
+	fileTool.On("Execute", mock.Anything, "read main.go").Return("package main\n\nfunc main() {}", nil)
+
+	a := New(mockProvider)
+	a.SetProgressDisplay(NewUIProgressDisplay())
+	a.RegisterTool(fileTool)
+
+	resp, err := a.Execute(context.Background(), "what does main.go do?")
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(resp, "It's the entry point [1].\n\nSources:\n[1] main.go, lines 1-3"), resp)
+	mockProvider.AssertExpectations(t)
+}
+
+func TestExecuteWithoutToolsHasNoFootnotes(t *testing.T) {
+	mockProvider := new(MockProvider)
+	mockProvider.On("GenerateWithOptions", mock.Anything, mock.MatchedBy(func(prompt string) bool {
+		return !strings.Contains(prompt, CitationInstruction)
+	}), mock.Anything).Return("Hello!", nil)
+
+	a := New(mockProvider)
+	a.SetAutoToolEnabled(false)
+
+	resp, err := a.Execute(context.Background(), "hi")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello!", resp)
+}
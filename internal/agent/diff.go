@@ -0,0 +1,211 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// diffContext is how many unchanged lines are shown around each change
+	diffContext = 3
+
+	// maxDiffCells bounds the table used to match the changed lines of a
+	// file; larger changes are shown as all their old lines replaced by the
+	// new ones
+	maxDiffCells = 4_000_000
+
+	// maxDiffLines is how many lines of a diff are shown in a response
+	maxDiffLines = 200
+)
+
+// diffLine is a line of a diff: unchanged (' '), removed ('-') or added ('+')
+type diffLine struct {
+	kind byte
+	text string
+}
+
+// unifiedDiff returns the changes from old to new content of path as a
+// unified diff, or an empty string if they are the same
+func unifiedDiff(path, old, new string) string {
+	if old == new {
+		return ""
+	}
+	lines := diffLines(splitLines(old), splitLines(new))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- a/%s\n+++ b/%s\n", path, path)
+	shown := 0
+	for _, hunk := range diffHunks(lines) {
+		sb.WriteString(hunk.header)
+		for i, line := range lines[hunk.start:hunk.end] {
+			if shown == maxDiffLines {
+				fmt.Fprintf(&sb, "... %d more changed lines\n", countChanges(lines[hunk.start+i:]))
+				return sb.String()
+			}
+			sb.WriteByte(line.kind)
+			sb.WriteString(line.text)
+			sb.WriteByte('\n')
+			shown++
+		}
+	}
+	return sb.String()
+}
+
+// splitLines splits content into lines without their line breaks
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// countChanges counts the removed and added lines
+func countChanges(lines []diffLine) int {
+	n := 0
+	for _, line := range lines {
+		if line.kind != ' ' {
+			n++
+		}
+	}
+	return n
+}
+
+// diffLines matches the lines of old and new, keeping the longest common
+// subsequence unchanged
+func diffLines(old, new []string) []diffLine {
+	// The common prefix and suffix need no matching
+	prefix := 0
+	for prefix < len(old) && prefix < len(new) && old[prefix] == new[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(new)-prefix && old[len(old)-1-suffix] == new[len(new)-1-suffix] {
+		suffix++
+	}
+
+	var lines []diffLine
+	for _, text := range old[:prefix] {
+		lines = append(lines, diffLine{' ', text})
+	}
+	lines = append(lines, matchLines(old[prefix:len(old)-suffix], new[prefix:len(new)-suffix])...)
+	for _, text := range old[len(old)-suffix:] {
+		lines = append(lines, diffLine{' ', text})
+	}
+	return lines
+}
+
+// matchLines diffs lines with no common prefix or suffix
+func matchLines(old, new []string) []diffLine {
+	var lines []diffLine
+	if len(old)*len(new) > maxDiffCells {
+		for _, text := range old {
+			lines = append(lines, diffLine{'-', text})
+		}
+		for _, text := range new {
+			lines = append(lines, diffLine{'+', text})
+		}
+		return lines
+	}
+
+	// common[i][j] is the length of the longest common subsequence of
+	// old[i:] and new[j:]
+	common := make([][]int, len(old)+1)
+	for i := range common {
+		common[i] = make([]int, len(new)+1)
+	}
+	for i := len(old) - 1; i >= 0; i-- {
+		for j := len(new) - 1; j >= 0; j-- {
+			if old[i] == new[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(old) && j < len(new) {
+		switch {
+		case old[i] == new[j]:
+			lines = append(lines, diffLine{' ', old[i]})
+			i++
+			j++
+		case common[i+1][j] >= common[i][j+1]:
+			lines = append(lines, diffLine{'-', old[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', new[j]})
+			j++
+		}
+	}
+	for ; i < len(old); i++ {
+		lines = append(lines, diffLine{'-', old[i]})
+	}
+	for ; j < len(new); j++ {
+		lines = append(lines, diffLine{'+', new[j]})
+	}
+	return lines
+}
+
+// diffHunk is a run of lines of a diff around one or more changes
+type diffHunk struct {
+	start, end int // Lines of the diff in the hunk
+	header     string
+}
+
+// diffHunks groups the changes of a diff with diffContext unchanged lines
+// around them, merging groups that would overlap
+func diffHunks(lines []diffLine) []diffHunk {
+	var hunks []diffHunk
+	for i := 0; i < len(lines); i++ {
+		if lines[i].kind == ' ' {
+			continue
+		}
+		start := max(i-diffContext, 0)
+		end := i + 1
+		// Extend the hunk while the next change is close enough
+		for next := end; next < len(lines); next++ {
+			if lines[next].kind == ' ' {
+				continue
+			}
+			if next-end > 2*diffContext {
+				break
+			}
+			end = next + 1
+		}
+		end = min(end+diffContext, len(lines))
+		hunks = append(hunks, diffHunk{start: start, end: end, header: hunkHeader(lines, start, end)})
+		i = end - 1
+	}
+	return hunks
+}
+
+// hunkHeader returns the "@@ -l,s +l,s @@" line of a hunk
+func hunkHeader(lines []diffLine, start, end int) string {
+	oldLine, newLine := 0, 0
+	for _, line := range lines[:start] {
+		if line.kind != '+' {
+			oldLine++
+		}
+		if line.kind != '-' {
+			newLine++
+		}
+	}
+	oldCount, newCount := 0, 0
+	for _, line := range lines[start:end] {
+		if line.kind != '+' {
+			oldCount++
+		}
+		if line.kind != '-' {
+			newCount++
+		}
+	}
+	// An empty range starts at the line before it
+	if oldCount > 0 {
+		oldLine++
+	}
+	if newCount > 0 {
+		newLine++
+	}
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@\n", oldLine, oldCount, newLine, newCount)
+}
@@ -0,0 +1,111 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func numberedLines(from, to int) string {
+	var sb strings.Builder
+	for i := from; i <= to; i++ {
+		fmt.Fprintf(&sb, "line %d\n", i)
+	}
+	return sb.String()
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		old  string
+		new  string
+		want string
+	}{
+		{
+			name: "same content",
+			old:  "a\nb\n",
+			new:  "a\nb\n",
+			want: "",
+		},
+		{
+			name: "changed line",
+			old:  "package main\n\nfunc main() {\n\tprintln(\"hi\")\n}\n",
+			new:  "package main\n\nfunc main() {\n\tprintln(\"hello\")\n}\n",
+			want: "--- a/main.go\n+++ b/main.go\n@@ -1,5 +1,5 @@\n package main\n \n func main() {\n-\tprintln(\"hi\")\n+\tprintln(\"hello\")\n }\n",
+		},
+		{
+			name: "emptied file",
+			old:  "a\nb\n",
+			new:  "",
+			want: "--- a/main.go\n+++ b/main.go\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			name: "distant changes",
+			old:  numberedLines(1, 20),
+			new:  strings.Replace(strings.Replace(numberedLines(1, 20), "line 2\n", "line two\n", 1), "line 18\n", "", 1),
+			want: "--- a/main.go\n+++ b/main.go\n" +
+				"@@ -1,5 +1,5 @@\n line 1\n-line 2\n+line two\n line 3\n line 4\n line 5\n" +
+				"@@ -15,6 +15,5 @@\n line 15\n line 16\n line 17\n-line 18\n line 19\n line 20\n",
+		},
+		{
+			name: "nearby changes share a hunk",
+			old:  numberedLines(1, 12),
+			new:  strings.Replace(strings.Replace(numberedLines(1, 12), "line 2\n", "", 1), "line 9\n", "", 1),
+			want: "--- a/main.go\n+++ b/main.go\n@@ -1,12 +1,10 @@\n line 1\n-line 2\n line 3\n line 4\n line 5\n line 6\n line 7\n line 8\n-line 9\n line 10\n line 11\n line 12\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, unifiedDiff("main.go", tt.old, tt.new))
+		})
+	}
+}
+
+func TestUnifiedDiffCutsLongDiffs(t *testing.T) {
+	diff := unifiedDiff("big.txt", "", numberedLines(1, maxDiffLines+50))
+
+	lines := strings.Split(strings.TrimSuffix(diff, "\n"), "\n")
+	assert.Len(t, lines, 3+maxDiffLines+1)
+	assert.Equal(t, "+line 200", lines[len(lines)-2])
+	assert.Equal(t, "... 50 more changed lines", lines[len(lines)-1])
+}
+
+func TestExecuteShowsWhatAWriteChanged(t *testing.T) {
+	mockProvider := new(MockProvider)
+	mockProvider.On("GenerateWithOptions", mock.Anything, mock.MatchedBy(func(prompt string) bool {
+		return strings.Contains(prompt, "intent analyzer")
+	}), mock.Anything).Return(`[{"intent":"write","filepath":"notes.txt","content":"b c"},{"intent":"write","filepath":"new.txt","content":"x"}]`, nil)
+	mockProvider.On("GenerateWithOptions", mock.Anything, mock.Anything, mock.Anything).Return("Done.", nil)
+
+	fileTool := &MockTool{}
+	fileTool.On("Name").Return("file_operations")
+	fileTool.On("Description").Return("File operations")
+	fileTool.On("Execute", mock.Anything, "read notes.txt").Return("a b", nil).Once()
+	fileTool.On("Execute", mock.Anything, "write notes.txt\nb c").Return("File written successfully: notes.txt", nil)
+	fileTool.On("Execute", mock.Anything, "read notes.txt").Return("b c", nil).Once()
+	fileTool.On("Execute", mock.Anything, "read new.txt").Return("", assert.AnError).Once()
+	fileTool.On("Execute", mock.Anything, "write new.txt\nx").Return("File written successfully: new.txt", nil)
+
+	a := New(mockProvider)
+	a.SetProgressDisplay(NewUIProgressDisplay())
+	a.RegisterTool(fileTool)
+	var recorded recordedTools
+	a.SetToolRecorder(&recorded)
+
+	resp, err := a.Execute(context.Background(), "update the notes and add new.txt")
+	require.NoError(t, err)
+
+	require.Len(t, recorded, 2)
+	assert.Equal(t, "--- a/notes.txt\n+++ b/notes.txt\n@@ -1,1 +1,1 @@\n-a b\n+b c\n", recorded[0].Diff)
+	assert.Empty(t, recorded[1].Diff, "a new file has no diff")
+	assert.Contains(t, resp, "📝 write changes:\n```diff\n--- a/notes.txt\n+++ b/notes.txt\n@@ -1,1 +1,1 @@\n-a b\n+b c\n```\n")
+	assert.NotContains(t, resp, "File written successfully: notes.txt")
+	assert.Contains(t, resp, "📄 write output:\nFile written successfully: new.txt")
+	fileTool.AssertExpectations(t)
+}
@@ -0,0 +1,72 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxDryRunPreviewLines limits how much of the content a write would put in
+// a file is shown in a dry run
+const maxDryRunPreviewLines = 20
+
+// SetDryRun makes the agent plan the tool operations a prompt needs and
+// describe them instead of running them
+func (a *Agent) SetDryRun(enabled bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.dryRun = enabled
+}
+
+// IsDryRun reports whether the agent only describes tool operations
+func (a *Agent) IsDryRun() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.dryRun
+}
+
+// describeDryRun lists the operations tasks would perform, with the content
+// of the files they would write
+func describeDryRun(tasks []Task) string {
+	var sb strings.Builder
+	sb.WriteString("Dry run: nothing was changed. Here's what I would do:\n\n")
+	for i, task := range tasks {
+		sb.WriteString(fmt.Sprintf("%d. %s\n", i+1, dryRunDescription(task.Match)))
+		if task.Match.Intent == IntentWrite {
+			sb.WriteString(preview(task.Match.Content))
+		}
+	}
+	sb.WriteString("\nTurn dry-run mode off with /dryrun off to let me do it.")
+	return sb.String()
+}
+
+// dryRunDescription describes what an operation would do
+func dryRunDescription(match FileOperationMatch) string {
+	switch match.Intent {
+	case IntentWrite:
+		return fmt.Sprintf("Write %d bytes to '%s'", len(match.Content), match.FilePath)
+	case IntentDelete:
+		return fmt.Sprintf("Delete file '%s'", match.FilePath)
+	case IntentTest:
+		return generateTaskDescription(match) + ", running the project's test command"
+	case IntentCheck:
+		return "Run the project's build and lint commands"
+	default:
+		return generateTaskDescription(match)
+	}
+}
+
+// preview returns the first lines of content, indented as a code block
+func preview(content string) string {
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	var sb strings.Builder
+	sb.WriteString("   ```\n")
+	for i, line := range lines {
+		if i == maxDryRunPreviewLines {
+			sb.WriteString(fmt.Sprintf("   ... (%d more lines)\n", len(lines)-i))
+			break
+		}
+		sb.WriteString("   " + line + "\n")
+	}
+	sb.WriteString("   ```\n")
+	return sb.String()
+}
@@ -0,0 +1,137 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mizzy/rigel/internal/tools"
+)
+
+// defaultMaxFixIterations is how many times the agent tries to fix build
+// and lint problems in files it wrote before reporting them
+const defaultMaxFixIterations = 3
+
+// SetMaxFixIterations sets how many times the agent may rewrite a file it
+// wrote to fix build and lint problems. 0 disables the fix loop.
+func (a *Agent) SetMaxFixIterations(n int) {
+	a.maxFixIterations = max(n, 0)
+}
+
+// fixWrittenFiles builds and lints the project after files were written
+// and asks the model to fix the problems reported in those files, checking
+// again after each fix, at most maxFixIterations times. It returns the
+// results of the checks and rewrites.
+func (a *Agent) fixWrittenFiles(ctx context.Context, task string, results []ToolExecutionResult) []ToolExecutionResult {
+	checker, ok := a.findTool("check_code").(*tools.CheckTool)
+	if !ok {
+		return nil
+	}
+	fileTool, ok := a.findTool("file_operations").(*tools.FileTool)
+	if !ok {
+		return nil
+	}
+
+	var written []string
+	for _, result := range results {
+		if result.Tool != "write" || result.Error != nil {
+			continue
+		}
+		if fields := strings.Fields(result.Input); len(fields) > 1 {
+			if path, err := fileTool.AbsPath(fields[1]); err == nil {
+				written = append(written, path)
+			}
+		}
+	}
+	if len(written) == 0 {
+		return nil
+	}
+
+	var fixResults []ToolExecutionResult
+	for attempt := 0; ; attempt++ {
+		a.progressDisplay.ShowProgress("check", "Building and linting")
+		startTime := time.Now()
+		check, err := checker.Run(ctx)
+		result := ToolExecutionResult{
+			Tool:      "check",
+			Input:     "check",
+			Error:     err,
+			Duration:  time.Since(startTime),
+			StartTime: startTime,
+		}
+		if err == nil {
+			result.Output = check.String()
+		}
+		a.progressDisplay.ShowResult(result)
+		fixResults = append(fixResults, result)
+		if err != nil || check.OK() {
+			return fixResults
+		}
+
+		// Only fix problems in the files just written; the others aren't ours
+		var fixes []ToolExecutionResult
+		for _, path := range written {
+			if diagnostics := check.ForFile(path); len(diagnostics) > 0 && attempt < a.maxFixIterations {
+				fixes = append(fixes, a.fixFile(ctx, fileTool, task, path, diagnostics))
+			}
+		}
+		if len(fixes) == 0 {
+			return fixResults
+		}
+		fixResults = append(fixResults, fixes...)
+	}
+}
+
+// fixFile asks the model to fix the problems in a file and rewrites it
+func (a *Agent) fixFile(ctx context.Context, fileTool *tools.FileTool, task, path string, diagnostics []tools.Diagnostic) ToolExecutionResult {
+	a.progressDisplay.ShowProgress("fix", fmt.Sprintf("Fixing %d problem(s) in '%s'", len(diagnostics), path))
+	result := ToolExecutionResult{Tool: "fix", Input: path, StartTime: time.Now()}
+	defer func() {
+		result.Duration = time.Since(result.StartTime)
+		a.progressDisplay.ShowResult(result)
+	}()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		result.Error = fmt.Errorf("failed to read file: %w", err)
+		return result
+	}
+
+	fixed, err := a.provider.Generate(ctx, buildFixPrompt(task, path, string(content), diagnostics))
+	if err != nil {
+		result.Error = fmt.Errorf("failed to generate fix: %w", err)
+		return result
+	}
+
+	result.Output, result.Error = fileTool.Write(path, stripCodeFence(fixed))
+	return result
+}
+
+// buildFixPrompt asks for a corrected version of a file
+func buildFixPrompt(task, path, content string, diagnostics []tools.Diagnostic) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("The file %s, written for the request below, has problems reported by the build and linters.\n\n", path))
+	sb.WriteString(fmt.Sprintf("Request: %s\n\nProblems:\n", task))
+	for _, d := range diagnostics {
+		sb.WriteString(fmt.Sprintf("- %s\n", d))
+	}
+	sb.WriteString(fmt.Sprintf("\nCurrent content:\n%s\n\n", content))
+	sb.WriteString("Provide ONLY the corrected file content, no explanations, no markdown code blocks.")
+	return sb.String()
+}
+
+// stripCodeFence removes a markdown code block around content, which models
+// add despite being asked not to
+func stripCodeFence(content string) string {
+	trimmed := strings.TrimSpace(content)
+	if !strings.HasPrefix(trimmed, "```") || !strings.HasSuffix(trimmed, "```") {
+		return content
+	}
+	trimmed = strings.TrimSuffix(trimmed, "```")
+	if i := strings.Index(trimmed, "\n"); i >= 0 {
+		return strings.TrimSpace(trimmed[i+1:]) + "\n"
+	}
+	return content
+}
@@ -0,0 +1,109 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mizzy/rigel/internal/tools"
+	"github.com/mizzy/rigel/internal/workspace"
+)
+
+const (
+	brokenMain = "package main\n\nfunc main() {\n\tundefinedFunc()\n}\n"
+	fixedMain  = "package main\n\nfunc main() {}\n"
+)
+
+// newFixLoopAgent creates an agent with file and check tools for a Go
+// module containing a main.go that doesn't build
+func newFixLoopAgent(t *testing.T, provider *MockProvider) (*Agent, string) {
+	t.Helper()
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go is not installed")
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/demo\n\ngo 1.21\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte(brokenMain), 0o644))
+
+	ws, err := workspace.New(dir)
+	require.NoError(t, err)
+	fileTool := tools.NewFileTool()
+	fileTool.SetWorkspace(ws)
+
+	a := New(provider)
+	a.SetProgressDisplay(NewUIProgressDisplay())
+	a.RegisterTool(fileTool)
+	a.RegisterTool(tools.NewCheckTool(dir, []string{"go build ./..."}))
+	return a, dir
+}
+
+func writeResult() []ToolExecutionResult {
+	return []ToolExecutionResult{{Tool: "write", Input: "write main.go package main"}}
+}
+
+func TestFixWrittenFiles(t *testing.T) {
+	provider := &MockProvider{}
+	provider.On("Generate", mock.Anything, mock.MatchedBy(func(prompt string) bool {
+		return strings.Contains(prompt, "undefined: undefinedFunc") && strings.Contains(prompt, "add a main function")
+	})).Return("```go\n"+fixedMain+"```", nil).Once()
+
+	a, dir := newFixLoopAgent(t, provider)
+	results := a.fixWrittenFiles(context.Background(), "add a main function", writeResult())
+
+	var steps []string
+	for _, result := range results {
+		require.NoError(t, result.Error)
+		steps = append(steps, result.Tool)
+	}
+	assert.Equal(t, []string{"check", "fix", "check"}, steps)
+	assert.Equal(t, "No problems found", results[2].Output)
+
+	content, err := os.ReadFile(filepath.Join(dir, "main.go"))
+	require.NoError(t, err)
+	assert.Equal(t, fixedMain, string(content))
+	provider.AssertExpectations(t)
+}
+
+func TestFixWrittenFiles_StopsAfterMaxIterations(t *testing.T) {
+	provider := &MockProvider{}
+	provider.On("Generate", mock.Anything, mock.Anything).Return(brokenMain, nil)
+
+	a, _ := newFixLoopAgent(t, provider)
+	a.SetMaxFixIterations(2)
+	results := a.fixWrittenFiles(context.Background(), "add a main function", writeResult())
+
+	var steps []string
+	for _, result := range results {
+		steps = append(steps, result.Tool)
+	}
+	assert.Equal(t, []string{"check", "fix", "check", "fix", "check"}, steps)
+	assert.Contains(t, results[len(results)-1].Output, "undefinedFunc", "the remaining problems are reported")
+	provider.AssertNumberOfCalls(t, "Generate", 2)
+}
+
+func TestFixWrittenFiles_Disabled(t *testing.T) {
+	a, _ := newFixLoopAgent(t, &MockProvider{})
+	a.SetMaxFixIterations(0)
+
+	results := a.fixWrittenFiles(context.Background(), "add a main function", writeResult())
+	require.Len(t, results, 1, "problems are still checked and reported")
+	assert.Equal(t, "check", results[0].Tool)
+}
+
+func TestFixWrittenFiles_NothingWritten(t *testing.T) {
+	a, _ := newFixLoopAgent(t, &MockProvider{})
+	assert.Empty(t, a.fixWrittenFiles(context.Background(), "read main.go", []ToolExecutionResult{{Tool: "read", Input: "read main.go"}}))
+}
+
+func TestStripCodeFence(t *testing.T) {
+	assert.Equal(t, "package main\n", stripCodeFence("```go\npackage main\n```"))
+	assert.Equal(t, "package main\n", stripCodeFence("package main\n"))
+}
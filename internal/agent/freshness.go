@@ -0,0 +1,194 @@
+package agent
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mizzy/rigel/internal/quarantine"
+	"github.com/mizzy/rigel/internal/tools"
+)
+
+// contextFilesKey is the memory context key of the files the conversation
+// holds, which keeps them with the conversation when it is saved
+const contextFilesKey = "files"
+
+// maxRefreshedFileSize limits the files whose changed content is given to
+// the model again; larger ones are only reported as changed
+const maxRefreshedFileSize = 32 * 1024
+
+// fileStamp records a file as the conversation last saw it, read or written
+// by the agent
+type fileStamp struct {
+	Path    string    `json:"path"` // As the agent was asked for it
+	ModTime time.Time `json:"mod_time"`
+	Size    int64     `json:"size"`
+	Hash    string    `json:"hash"` // SHA-256 of the content
+}
+
+// ContextFile is a file whose content the conversation holds
+type ContextFile struct {
+	Path string
+	// Changed on disk since the conversation saw it; the agent gives the
+	// model the current content with the next prompt
+	Changed bool
+}
+
+// stampFile records the current state of a file, returning its content too
+func stampFile(path, abs string) (fileStamp, []byte, error) {
+	info, err := os.Stat(abs)
+	if err != nil {
+		return fileStamp{}, nil, err
+	}
+	content, err := os.ReadFile(abs)
+	if err != nil {
+		return fileStamp{}, nil, err
+	}
+	sum := sha256.Sum256(content)
+	return fileStamp{Path: path, ModTime: info.ModTime(), Size: info.Size(), Hash: hex.EncodeToString(sum[:])}, content, nil
+}
+
+// changed reports whether the file differs from the stamp. Its content is
+// only compared when its modification time or size changed.
+func (s fileStamp) changed(abs string) bool {
+	info, err := os.Stat(abs)
+	if err != nil {
+		return true
+	}
+	if info.ModTime().Equal(s.ModTime) && info.Size() == s.Size {
+		return false
+	}
+	current, _, err := stampFile(s.Path, abs)
+	return err != nil || current.Hash != s.Hash
+}
+
+// contextFiles returns the files the conversation in memory holds, by
+// absolute path
+func contextFiles(memory Memory) map[string]fileStamp {
+	value, ok := memory.Context()[contextFilesKey]
+	if !ok {
+		return map[string]fileStamp{}
+	}
+	if stamps, ok := value.(map[string]fileStamp); ok {
+		return maps.Clone(stamps)
+	}
+	// A restored session has them decoded from JSON
+	stamps := map[string]fileStamp{}
+	if data, err := json.Marshal(value); err == nil {
+		_ = json.Unmarshal(data, &stamps)
+	}
+	return stamps
+}
+
+// trackFile records that the conversation holds a file's current content,
+// after the agent read or wrote it, or forgets it once deleted
+func trackFile(memory Memory, tool tools.Tool, match FileOperationMatch) {
+	fileTool, ok := tool.(*tools.FileTool)
+	if !ok {
+		return
+	}
+	abs, err := fileTool.AbsPath(match.FilePath)
+	if err != nil {
+		return
+	}
+
+	stamps := contextFiles(memory)
+	if match.Intent == IntentDelete {
+		delete(stamps, abs)
+	} else if stamp, _, err := stampFile(match.FilePath, abs); err == nil {
+		stamps[abs] = stamp
+	}
+	memory.SetContext(contextFilesKey, stamps)
+}
+
+// AddFiles adds the current content of files to the conversation, as if the
+// agent had read them, so that the next prompts can refer to them. It
+// returns the files added; those that can't be read are reported in the
+// error.
+func (a *Agent) AddFiles(paths ...string) ([]string, error) {
+	fileTool, ok := a.findTool("file_operations").(*tools.FileTool)
+	if !ok {
+		return nil, errors.New("no file tool to read the files with")
+	}
+
+	memory := a.getMemory()
+	var sections, added []string
+	var errs []error
+	for _, path := range paths {
+		content, err := fileTool.Read(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		sections = append(sections, quarantine.Wrap(path, content))
+		added = append(added, path)
+		trackFile(memory, fileTool, FileOperationMatch{Intent: IntentRead, FilePath: path})
+	}
+	if len(added) > 0 {
+		memory.AddMessages(
+			Message{Role: "user", Content: "Here are files for the context of this conversation.\n\n" + strings.Join(sections, "\n\n")},
+			Message{Role: "assistant", Content: fmt.Sprintf("Noted: I have %s.", strings.Join(added, ", "))},
+		)
+	}
+	return added, errors.Join(errs...)
+}
+
+// ContextFiles returns the files the agent read or wrote in the
+// conversation, and whether each changed on disk since
+func (a *Agent) ContextFiles() []ContextFile {
+	stamps := contextFiles(a.getMemory())
+	files := make([]ContextFile, 0, len(stamps))
+	for _, abs := range slices.Sorted(maps.Keys(stamps)) {
+		files = append(files, ContextFile{Path: stamps[abs].Path, Changed: stamps[abs].changed(abs)})
+	}
+	return files
+}
+
+// refreshContextFiles gives the model the current content of the files
+// that changed on disk since the conversation saw them, so it doesn't
+// reason about outdated code. The content is added to the conversation,
+// which then holds it instead.
+func (a *Agent) refreshContextFiles(memory Memory) {
+	stamps := contextFiles(memory)
+	var sections, paths []string
+	for _, abs := range slices.Sorted(maps.Keys(stamps)) {
+		stamp := stamps[abs]
+		if !stamp.changed(abs) {
+			continue
+		}
+		paths = append(paths, stamp.Path)
+
+		current, content, err := stampFile(stamp.Path, abs)
+		if err != nil {
+			delete(stamps, abs)
+			sections = append(sections, fmt.Sprintf("%s was deleted.", stamp.Path))
+			continue
+		}
+		stamps[abs] = current
+		switch {
+		case len(content) > maxRefreshedFileSize:
+			sections = append(sections, fmt.Sprintf("%s changed (%d bytes); read it again before relying on it.", stamp.Path, len(content)))
+		case bytes.IndexByte(content, 0) >= 0:
+			sections = append(sections, fmt.Sprintf("%s changed and is now a binary file.", stamp.Path))
+		default:
+			sections = append(sections, fmt.Sprintf("%s now contains:\n%s", stamp.Path, quarantine.Wrap(stamp.Path, string(content))))
+		}
+	}
+	if len(sections) == 0 {
+		return
+	}
+
+	memory.SetContext(contextFilesKey, stamps)
+	memory.AddMessages(
+		Message{Role: "user", Content: "These files changed on disk since you last saw them; what this conversation showed of them before is outdated.\n\n" + strings.Join(sections, "\n\n")},
+		Message{Role: "assistant", Content: fmt.Sprintf("Noted: I'll go by the current state of %s.", strings.Join(paths, ", "))},
+	)
+}
@@ -0,0 +1,85 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mizzy/rigel/internal/session"
+	"github.com/mizzy/rigel/internal/tools"
+)
+
+func TestContextFilesFreshness(t *testing.T) {
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.WriteFile("main.go", []byte("package main\n"), 0644))
+	require.NoError(t, os.WriteFile("old.go", []byte("package main\n"), 0644))
+
+	a := New(&MockProvider{})
+	a.RegisterTool(tools.NewFileTool())
+	results := a.ExecuteFileOperationsWithProgress(context.Background(), []FileOperationMatch{
+		{Intent: IntentRead, FilePath: "main.go"},
+		{Intent: IntentRead, FilePath: "old.go"},
+		{Intent: IntentWrite, FilePath: "new.go", Content: "package main"},
+		{Intent: IntentRead, FilePath: "missing.go"},
+	}, NewUIProgressDisplay())
+	require.Len(t, results, 4)
+	assert.Equal(t, []ContextFile{{Path: "main.go"}, {Path: "new.go"}, {Path: "old.go"}}, a.ContextFiles())
+
+	// The agent's own deletions are forgotten, others' are reported
+	a.ExecuteFileOperationsWithProgress(context.Background(), []FileOperationMatch{
+		{Intent: IntentDelete, FilePath: "new.go"},
+	}, NewUIProgressDisplay())
+	require.NoError(t, os.WriteFile("main.go", []byte("package main\n\nfunc main() {}\n"), 0644))
+	require.NoError(t, os.Remove("old.go"))
+	assert.Equal(t, []ContextFile{{Path: "main.go", Changed: true}, {Path: "old.go", Changed: true}}, a.ContextFiles())
+
+	a.refreshContextFiles(a.getMemory())
+	history := a.History()
+	require.Len(t, history, 2)
+	assert.Equal(t, "These files changed on disk since you last saw them; what this conversation showed of them before is outdated.\n\n"+
+		"main.go now contains:\n<untrusted source=\"main.go\">\npackage main\n\nfunc main() {}\n</untrusted>\n\nold.go was deleted.", history[0].Content)
+	assert.Equal(t, "Noted: I'll go by the current state of main.go, old.go.", history[1].Content)
+	assert.Equal(t, []ContextFile{{Path: "main.go"}}, a.ContextFiles())
+
+	// Nothing changed since
+	a.refreshContextFiles(a.getMemory())
+	assert.Len(t, a.History(), 2)
+
+	// The files stay tracked when the conversation is saved and restored
+	store, err := session.NewStoreAt(t.TempDir())
+	require.NoError(t, err)
+	sess := session.New()
+	sess.Memory = a.SaveMemory()
+	require.NoError(t, store.Save(sess))
+	loaded, err := store.Load(sess.ID)
+	require.NoError(t, err)
+	restored := New(&MockProvider{})
+	restored.RestoreMemory(loaded.Memory)
+	assert.Equal(t, []ContextFile{{Path: "main.go"}}, restored.ContextFiles())
+
+	require.NoError(t, os.WriteFile("main.go", []byte("package main\n"), 0644))
+	assert.Equal(t, []ContextFile{{Path: "main.go", Changed: true}}, restored.ContextFiles())
+}
+
+func TestAddFiles(t *testing.T) {
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.WriteFile("main.go", []byte("package main\n"), 0644))
+
+	a := New(&MockProvider{})
+	_, err := a.AddFiles("main.go")
+	assert.EqualError(t, err, "no file tool to read the files with")
+
+	a.RegisterTool(tools.NewFileTool())
+	added, err := a.AddFiles("main.go", "missing.go")
+	assert.Equal(t, []string{"main.go"}, added)
+	assert.ErrorContains(t, err, "failed to read file")
+
+	history := a.History()
+	require.Len(t, history, 2)
+	assert.Equal(t, "Here are files for the context of this conversation.\n\n<untrusted source=\"main.go\">\npackage main\n</untrusted>", history[0].Content)
+	assert.Equal(t, "Noted: I have main.go.", history[1].Content)
+	assert.Equal(t, []ContextFile{{Path: "main.go"}}, a.ContextFiles())
+}
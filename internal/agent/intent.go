@@ -0,0 +1,160 @@
+package agent
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/mizzy/rigel/internal/llm"
+)
+
+// toolRequestInstruction lets a model that can use tools ask for them in the
+// request answering the prompt, saving the separate intent analysis
+const toolRequestInstruction = `If answering needs any of the operations below, such as reading or writing files, searching the project, running the tests or searching the web, respond with only the JSON array of the operations and no other text; you will then be given their results. Otherwise, answer directly.
+
+` + intentFormat
+
+// toolWords start the words of prompts that may need tools: files and the
+// project, changing them, running the tests and checks, remembering facts
+// and looking things up on the web
+var toolWords = []string{
+	"file", "folder", "director", "path", "project", "repo", "codebase", "package", "module",
+	"readme", "config", "makefile", "dockerfile", "sample", "dummy",
+	"read", "open", "show", "print", "list", "ls", "tree", "layout", "laid", "structur",
+	"writ", "creat", "generat", "draft", "save", "edit", "updat", "modif", "chang",
+	"add", "append", "insert", "replac", "delet", "remov", "renam", "move", "copy",
+	"search", "find", "grep", "where", "locat", "lookup", "exist",
+	"test", "build", "compil", "lint", "vet", "check", "fix", "run", "execut",
+	"implement", "refactor", "review", "debug", "fail", "error", "bug", "crash", "broke",
+	"remember", "memor", "delegat", "subtask",
+	"web", "internet", "online", "google", "latest", "news", "docs", "documentation",
+}
+
+var (
+	// fileNamePattern matches names such as main.go or README.md, but not
+	// abbreviations such as e.g.
+	fileNamePattern = regexp.MustCompile(`[\w-]{2,}\.[A-Za-z][A-Za-z0-9]{0,4}\b`)
+	wordPattern     = regexp.MustCompile(`[a-z]+`)
+)
+
+// mayNeedTools reports whether a prompt may need tools, telling the prompts
+// that plainly don't, such as greetings and general questions, apart without
+// asking the model. Prompts it can't judge, such as those written in other
+// languages, may.
+func mayNeedTools(prompt string) bool {
+	for _, r := range prompt {
+		if r > unicode.MaxASCII && unicode.IsLetter(r) {
+			return true
+		}
+	}
+	if strings.ContainsAny(prompt, "/\\`~") || fileNamePattern.MatchString(prompt) {
+		return true
+	}
+	for _, word := range wordPattern.FindAllString(strings.ToLower(prompt), -1) {
+		for _, prefix := range toolWords {
+			if strings.HasPrefix(word, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SetFastIntent makes the agent skip working out which tools a prompt needs
+// when the prompt plainly needs none, and ask a model that can use tools in
+// the request answering the prompt instead of in one of its own
+func (a *Agent) SetFastIntent(enabled bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.fastIntent = enabled
+}
+
+// IsFastIntent returns whether the agent takes the shortcuts of
+// SetFastIntent
+func (a *Agent) IsFastIntent() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.fastIntent
+}
+
+// supportsTools reports whether the current model can use tools, and so
+// reliably asks for them in the format it is given
+func (a *Agent) supportsTools() bool {
+	return a.provider != nil && a.provider.GetCurrentModel().Details.SupportsTools
+}
+
+// reply is the model's response to a prompt, or the error that stopped it
+// along with what was received before
+type reply struct {
+	response string
+	err      error
+}
+
+// answerOrRequestTools asks the model for the response to a prompt, letting
+// it request the operations it needs instead. It returns either the
+// operations or the response; a response that was a request is not streamed.
+func (a *Agent) answerOrRequestTools(ctx context.Context, task string, images []llm.Image, streaming bool, handler func(chunk string)) ([]FileOperationMatch, *reply) {
+	opts := a.generateOptions(a.buildSystemPrompt() + "\n\n" + toolRequestInstruction)
+	if !streaming {
+		handler = nil
+	}
+	stream := &toolRequestStream{handler: handler}
+	response, err := a.generate(ctx, a.buildPrompt(ctx, task, nil), images, opts, streaming, stream.write)
+	if err != nil {
+		if stream.held {
+			// Part of a request is not an answer to keep
+			return nil, &reply{err: err}
+		}
+		return nil, &reply{response: response, err: err}
+	}
+	if matches, ok := parseIntents(response); ok {
+		return matches, nil
+	}
+	stream.flush(response)
+	return nil, &reply{response: response}
+}
+
+// toolRequestStream passes the pieces of a streamed response on to handler,
+// unless the response starts like a request for tools, which is held back
+type toolRequestStream struct {
+	handler func(chunk string)
+	pending strings.Builder // Received before it was clear what the response is
+	decided bool
+	held    bool
+}
+
+func (s *toolRequestStream) write(chunk string) {
+	if s.decided {
+		if !s.held {
+			s.pass(chunk)
+		}
+		return
+	}
+
+	s.pending.WriteString(chunk)
+	start := strings.TrimLeft(s.pending.String(), " \t\r\n")
+	if start == "" || strings.HasPrefix("```json", start) {
+		// Can't tell yet
+		return
+	}
+	s.decided = true
+	s.held = strings.HasPrefix(start, "[") || strings.HasPrefix(start, "```json")
+	if !s.held {
+		s.pass(s.pending.String())
+	}
+}
+
+// flush passes on a response that turned out to be an answer, if it was
+// held back
+func (s *toolRequestStream) flush(response string) {
+	if (!s.decided || s.held) && response != "" {
+		s.pass(response)
+	}
+}
+
+func (s *toolRequestStream) pass(chunk string) {
+	if s.handler != nil {
+		s.handler(chunk)
+	}
+}
@@ -0,0 +1,133 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mizzy/rigel/internal/llm"
+)
+
+func TestMayNeedTools(t *testing.T) {
+	tests := []struct {
+		prompt string
+		want   bool
+	}{
+		{"how are you today?", false},
+		{"Thanks, that's great!", false},
+		{"explain goroutines", false},
+		{"What's the difference between a slice and an array, i.e. when should I use each?", false},
+		{"what does main.go do?", true},
+		{"summarize README.md", true},
+		{"look at internal/agent", true},
+		{"why does `NewServer` panic?", true},
+		{"create a hello world program", true},
+		{"run the tests", true},
+		{"Show me the project layout", true},
+		{"remember that we use tabs", true},
+		{"what's the latest release of bubbletea?", true},
+		{"適当な文章をファイルに書いて", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.prompt, func(t *testing.T) {
+			assert.Equal(t, tt.want, mayNeedTools(tt.prompt))
+		})
+	}
+}
+
+// withToolRequests matches the options of a request letting the model ask
+// for tools
+func withToolRequests(want bool) interface{} {
+	return mock.MatchedBy(func(opts llm.GenerateOptions) bool {
+		return strings.Contains(opts.SystemPrompt, toolRequestInstruction) == want
+	})
+}
+
+func TestExecuteFastIntentSkipsAnalysis(t *testing.T) {
+	mockProvider := new(MockProvider)
+	mockProvider.On("GenerateWithOptions", mock.Anything, "how are you today?", withToolRequests(false)).Return("Fine, thanks.", nil)
+
+	a := New(mockProvider)
+	a.SetFastIntent(true)
+	assert.True(t, a.IsFastIntent())
+
+	resp, err := a.Execute(context.Background(), "how are you today?")
+	require.NoError(t, err)
+	assert.Equal(t, "Fine, thanks.", resp)
+	mockProvider.AssertExpectations(t)
+}
+
+func TestExecuteFastIntentWithToolUse(t *testing.T) {
+	toolModel := llm.Model{Name: "claude", Details: llm.ModelDetails{SupportsTools: true}}
+
+	t.Run("answers in the same request", func(t *testing.T) {
+		mockProvider := new(MockProvider)
+		mockProvider.On("GetCurrentModel").Return(toolModel)
+		mockProvider.On("GenerateWithOptions", mock.Anything, "fix this loop: for i := range 10 {}", withToolRequests(true)).
+			Return("[It already works.]", nil).Once()
+
+		a := New(mockProvider)
+		a.SetFastIntent(true)
+
+		resp, err := a.Execute(context.Background(), "fix this loop: for i := range 10 {}")
+		require.NoError(t, err)
+		assert.Equal(t, "[It already works.]", resp)
+		assert.Len(t, a.History(), 2)
+		mockProvider.AssertExpectations(t)
+	})
+
+	t.Run("runs the tools it requests", func(t *testing.T) {
+		mockProvider := new(MockProvider)
+		mockProvider.On("GetCurrentModel").Return(toolModel)
+		mockProvider.On("GenerateWithOptions", mock.Anything, "what does main.go do?", withToolRequests(true)).
+			Return("```json\n[{\"intent\":\"read\",\"filepath\":\"main.go\",\"content\":\"\"}]\n```", nil).Once()
+		mockProvider.On("GenerateWithOptions", mock.Anything, mock.MatchedBy(func(prompt string) bool {
+			return strings.Contains(prompt, "Tool read succeeded:\n<untrusted source=\"read\">\npackage main\n</untrusted>")
+		}), withToolRequests(false)).Return("It's the entry point.", nil).Once()
+
+		fileTool := &MockTool{}
+		fileTool.On("Name").Return("file_operations")
+		fileTool.On("Description").Return("File operations")
+		fileTool.On("Execute", mock.Anything, "read main.go").Return("package main", nil)
+
+		a := New(mockProvider)
+		a.SetFastIntent(true)
+		a.SetProgressDisplay(NewUIProgressDisplay())
+		a.RegisterTool(fileTool)
+
+		resp, err := a.Execute(context.Background(), "what does main.go do?")
+		require.NoError(t, err)
+		assert.Contains(t, resp, "[x] 1. Read file 'main.go'")
+		assert.True(t, strings.HasSuffix(resp, "It's the entry point.\n\nSources:\n[1] main.go, lines 1-1"), resp)
+		mockProvider.AssertExpectations(t)
+		fileTool.AssertExpectations(t)
+	})
+}
+
+func TestToolRequestStream(t *testing.T) {
+	var passed []string
+	s := &toolRequestStream{handler: func(chunk string) { passed = append(passed, chunk) }}
+	for _, chunk := range []string{"\n``", "`json\n[{\"intent\":", "\"read\"}]\n```"} {
+		s.write(chunk)
+	}
+	assert.Empty(t, passed, "a tool request is held back")
+
+	passed = nil
+	s = &toolRequestStream{handler: func(chunk string) { passed = append(passed, chunk) }}
+	for _, chunk := range []string{"``", "`go\n", "x := 1\n```"} {
+		s.write(chunk)
+	}
+	s.flush("```go\nx := 1\n```")
+	assert.Equal(t, []string{"```go\n", "x := 1\n```"}, passed)
+
+	passed = nil
+	s = &toolRequestStream{handler: func(chunk string) { passed = append(passed, chunk) }}
+	s.write("[1] Go's ")
+	s.write("spec says")
+	s.flush("[1] Go's spec says")
+	assert.Equal(t, []string{"[1] Go's spec says"}, passed, "an answer held back is passed on whole")
+}
@@ -0,0 +1,107 @@
+package agent
+
+import (
+	"context"
+	"strings"
+
+	"github.com/mizzy/rigel/internal/llm"
+)
+
+// InterruptedMarker ends a response that was cancelled before the model
+// finished it
+const InterruptedMarker = "(interrupted)"
+
+// ContinuePrompt asks the model to finish an interrupted response
+const ContinuePrompt = "Your previous response was interrupted. Continue it from exactly where it stopped, without repeating what you already wrote."
+
+// Interrupted is returned by Execute when the request is cancelled, or runs
+// past its deadline, after the model started answering. The partial
+// response is kept in the agent's memory, marked as interrupted.
+type Interrupted struct {
+	Partial string
+	Cause   error // context.Canceled or context.DeadlineExceeded; Canceled if nil
+}
+
+func (e *Interrupted) Error() string {
+	return "response interrupted"
+}
+
+func (e *Interrupted) Unwrap() error {
+	if e.Cause != nil {
+		return e.Cause
+	}
+	return context.Canceled
+}
+
+// Response returns the partial response marked as interrupted
+func (e *Interrupted) Response() string {
+	return strings.TrimRight(e.Partial, " \n") + "\n\n" + InterruptedMarker
+}
+
+// IsInterrupted reports whether a response was cut short by cancelling it
+func IsInterrupted(response string) bool {
+	return strings.HasSuffix(response, InterruptedMarker)
+}
+
+// SetStreaming makes the agent stream responses from the model, so that the
+// part received before a request is cancelled can be kept
+func (a *Agent) SetStreaming(enabled bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.streaming = enabled
+}
+
+// SetStreamHandler sets a function receiving each piece of the model's
+// response as it is streamed, for frontends to show it as it is written. It
+// is called from the goroutine running Execute; nil removes it.
+func (a *Agent) SetStreamHandler(handler func(chunk string)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.streamHandler = handler
+}
+
+// streamSettings returns whether the agent streams responses and the
+// function receiving the pieces
+func (a *Agent) streamSettings() (bool, func(chunk string)) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.streaming, a.streamHandler
+}
+
+// generate asks the model for the response to a prompt and the images
+// attached to it, passing its pieces on to handler when streaming. When
+// streaming, it returns what was received so far along with the error that
+// stopped it.
+func (a *Agent) generate(ctx context.Context, prompt string, images []llm.Image, opts llm.GenerateOptions, streaming bool, handler func(chunk string)) (string, error) {
+	messages := []llm.Message{{Role: "user", Content: prompt, Images: images}}
+	if !streaming {
+		if len(images) > 0 {
+			return a.provider.GenerateWithHistory(ctx, messages, opts)
+		}
+		return a.provider.GenerateWithOptions(ctx, prompt, opts)
+	}
+
+	stream, err := a.provider.StreamWithHistory(ctx, messages, opts)
+	if err != nil {
+		return "", err
+	}
+	var response strings.Builder
+	for {
+		select {
+		case <-ctx.Done():
+			return response.String(), ctx.Err()
+		case chunk, ok := <-stream:
+			if !ok || chunk.Done {
+				// A stream closed by cancelling it is not a complete response
+				return response.String(), ctx.Err()
+			}
+			response.WriteString(chunk.Content)
+			if handler != nil && chunk.Content != "" {
+				handler(chunk.Content)
+			}
+			if chunk.Error != nil {
+				return response.String(), chunk.Error
+			}
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mizzy/rigel/internal/llm"
+)
+
+func TestExecuteStreaming(t *testing.T) {
+	stream := make(chan llm.StreamResponse, 3)
+	stream <- llm.StreamResponse{Content: "Channels "}
+	stream <- llm.StreamResponse{Content: "are pipes."}
+	stream <- llm.StreamResponse{Done: true}
+	mockProvider := new(MockProvider)
+	mockProvider.On("StreamWithHistory", mock.Anything, mock.Anything, mock.Anything).Return((<-chan llm.StreamResponse)(stream), nil)
+
+	a := New(mockProvider)
+	a.SetAutoToolEnabled(false)
+	a.SetStreaming(true)
+	var chunks []string
+	a.SetStreamHandler(func(chunk string) {
+		chunks = append(chunks, chunk)
+	})
+
+	resp, err := a.Execute(context.Background(), "explain channels")
+	require.NoError(t, err)
+	assert.Equal(t, "Channels are pipes.", resp)
+	assert.Equal(t, []string{"Channels ", "are pipes."}, chunks)
+	mockProvider.AssertExpectations(t)
+}
+
+func TestExecuteInterrupted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := make(chan llm.StreamResponse)
+	go func() {
+		stream <- llm.StreamResponse{Content: "Channels are"}
+		cancel()
+	}()
+	mockProvider := new(MockProvider)
+	mockProvider.On("StreamWithHistory", mock.Anything, mock.Anything, mock.Anything).Return((<-chan llm.StreamResponse)(stream), nil)
+
+	a := New(mockProvider)
+	a.SetAutoToolEnabled(false)
+	a.SetStreaming(true)
+
+	_, err := a.Execute(ctx, "explain channels")
+	var interrupted *Interrupted
+	require.ErrorAs(t, err, &interrupted)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, "Channels are", interrupted.Partial)
+	assert.Equal(t, "Channels are\n\n(interrupted)", interrupted.Response())
+	assert.True(t, IsInterrupted(interrupted.Response()))
+
+	assert.Equal(t, []Message{
+		{Role: "user", Content: "explain channels"},
+		{Role: "assistant", Content: "Channels are\n\n(interrupted)"},
+	}, a.History())
+}
+
+func TestExecuteTimedOut(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	stream := make(chan llm.StreamResponse, 1)
+	stream <- llm.StreamResponse{Content: "Channels are"}
+	mockProvider := new(MockProvider)
+	mockProvider.On("StreamWithHistory", mock.Anything, mock.Anything, mock.Anything).Return((<-chan llm.StreamResponse)(stream), nil)
+
+	a := New(mockProvider)
+	a.SetAutoToolEnabled(false)
+	a.SetStreaming(true)
+
+	_, err := a.Execute(ctx, "explain channels")
+	var interrupted *Interrupted
+	require.ErrorAs(t, err, &interrupted)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.NotErrorIs(t, err, context.Canceled)
+	assert.Equal(t, "Channels are", interrupted.Partial)
+}
+
+func TestExecuteCancelledBeforeOutput(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	mockProvider := new(MockProvider)
+	mockProvider.On("StreamWithHistory", mock.Anything, mock.Anything, mock.Anything).Return((<-chan llm.StreamResponse)(make(chan llm.StreamResponse)), nil)
+
+	a := New(mockProvider)
+	a.SetAutoToolEnabled(false)
+	a.SetStreaming(true)
+
+	_, err := a.Execute(ctx, "explain channels")
+	require.Error(t, err)
+	var interrupted *Interrupted
+	assert.False(t, errors.As(err, &interrupted))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, a.History())
+}
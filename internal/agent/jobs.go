@@ -0,0 +1,259 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JobStatus is how far a background job got
+type JobStatus int
+
+const (
+	JobRunning JobStatus = iota
+	JobDone
+	JobFailed
+	JobCancelled
+)
+
+// String returns the status as /jobs lists it
+func (s JobStatus) String() string {
+	switch s {
+	case JobRunning:
+		return "running"
+	case JobDone:
+		return "done"
+	case JobFailed:
+		return "failed"
+	case JobCancelled:
+		return "cancelled"
+	}
+	return ""
+}
+
+// Job is a task an agent of its own carries out in the background, with
+// its own memory, while the conversation goes on
+type Job struct {
+	ID      int
+	Task    string
+	Started time.Time
+
+	cancel context.CancelFunc
+	done   chan struct{} // Closed when the job ends
+
+	// Guards the fields below, which the job's agent updates as it works
+	mu       sync.Mutex
+	status   JobStatus
+	activity string          // The tool it last used, e.g. "file_operations: read main.go"
+	output   strings.Builder // The response so far
+	err      error
+	refused  bool // Whether a plan was refused for want of review
+	finished time.Time
+	updated  chan struct{} // Closed, and replaced, on each update
+}
+
+// StartJob runs task in the background with an agent that has the tools,
+// settings, context providers and tool recorder of this one but a memory of
+// its own. Nobody is there to approve its plans, so with reviewed set, as
+// when the user reviews plans before they run, it only carries out plans
+// that read: one that writes or deletes files, runs tests or checks, saves
+// a note or delegates is refused.
+func (a *Agent) StartJob(task string, reviewed bool) *Job {
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &Job{
+		Task:    task,
+		Started: time.Now(),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		updated: make(chan struct{}),
+	}
+
+	a.mu.Lock()
+	job.ID = len(a.jobs) + 1
+	a.jobs = append(a.jobs, job)
+	a.mu.Unlock()
+
+	worker := a.spawn()
+	worker.tools = slices.Clone(a.tools)
+	worker.maxSubagents = a.maxSubagents
+	worker.progressDisplay = jobProgress{job}
+	worker.streaming = true
+	worker.streamHandler = job.write
+	if reviewed {
+		worker.planReviewer = jobReviewer{job}
+	}
+
+	go func() {
+		defer cancel()
+		defer close(job.done)
+		response, err := worker.Execute(ctx, task)
+		job.finish(response, err, ctx.Err() != nil)
+	}()
+	return job
+}
+
+// CancelJobs stops the background jobs still running and waits for them to
+// end, e.g. before exiting, until ctx is done. It returns an error naming the
+// jobs that haven't ended by then.
+func (a *Agent) CancelJobs(ctx context.Context) error {
+	jobs := a.Jobs()
+	for _, job := range jobs {
+		job.Cancel()
+	}
+	var running []string
+	for _, job := range jobs {
+		select {
+		case <-job.Done():
+		case <-ctx.Done():
+			select {
+			case <-job.Done():
+			default:
+				running = append(running, "#"+strconv.Itoa(job.ID))
+			}
+		}
+	}
+	if len(running) > 0 {
+		return fmt.Errorf("background jobs %s didn't stop: %w", strings.Join(running, ", "), ctx.Err())
+	}
+	return nil
+}
+
+// Jobs returns the background jobs started so far, the first first
+func (a *Agent) Jobs() []*Job {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return slices.Clone(a.jobs)
+}
+
+// FindJob returns the background job numbered id
+func (a *Agent) FindJob(id int) (*Job, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if id < 1 || id > len(a.jobs) {
+		return nil, false
+	}
+	return a.jobs[id-1], true
+}
+
+// Status returns how far the job got and the tool it last used
+func (j *Job) Status() (JobStatus, string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.status, j.activity
+}
+
+// Output returns the job's response so far, or in full once it is done
+func (j *Job) Output() string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.output.String()
+}
+
+// Err returns why the job failed, if it did
+func (j *Job) Err() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.err
+}
+
+// Elapsed returns how long the job has run, or ran
+func (j *Job) Elapsed() time.Duration {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if !j.finished.IsZero() {
+		return j.finished.Sub(j.Started)
+	}
+	return time.Since(j.Started)
+}
+
+// Done returns a channel closed when the job ends
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+// Updated returns a channel closed when the job's status, activity or
+// output next changes
+func (j *Job) Updated() <-chan struct{} {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.updated
+}
+
+// Cancel stops the job, if it is still running
+func (j *Job) Cancel() {
+	j.cancel()
+}
+
+// update applies change and tells those waiting for an update
+func (j *Job) update(change func()) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	change()
+	close(j.updated)
+	j.updated = make(chan struct{})
+}
+
+// write adds a streamed piece of the response to the output
+func (j *Job) write(chunk string) {
+	j.update(func() { j.output.WriteString(chunk) })
+}
+
+// finish records the outcome of the job's agent, which failed on being
+// cancelled if cancelled is set
+func (j *Job) finish(response string, err error, cancelled bool) {
+	j.update(func() {
+		j.finished = time.Now()
+		j.activity = ""
+		switch {
+		case err != nil && cancelled:
+			j.status = JobCancelled
+		case err != nil:
+			j.status = JobFailed
+			j.err = err
+		default:
+			j.status = JobDone
+			// The complete response replaces the streamed one, which tool
+			// results don't show in
+			j.output.Reset()
+			if j.refused {
+				j.output.WriteString("Background jobs only read while plans are reviewed, and this plan would change files or run commands. Send the task without & to review its plan.\n\n")
+			}
+			j.output.WriteString(response)
+		}
+	})
+}
+
+// jobProgress records the tools a job's agent uses as its activity
+type jobProgress struct {
+	job *Job
+}
+
+func (p jobProgress) ShowProgress(toolName, operation string) {
+	p.job.update(func() { p.job.activity = strings.TrimSpace(toolName + ": " + operation) })
+}
+
+func (p jobProgress) ShowResult(result ToolExecutionResult) {}
+
+// jobReviewer stands in for the user reviewing a job's plans, refusing
+// those with steps that do more than read
+type jobReviewer struct {
+	job *Job
+}
+
+func (r jobReviewer) ReviewPlan(ctx context.Context, plan *Plan) bool {
+	for _, step := range plan.Steps() {
+		switch step.Task.Match.Intent {
+		case IntentRead, IntentList, IntentTree, IntentExists, IntentSearch, IntentWebSearch:
+		default:
+			r.job.update(func() { r.job.refused = true })
+			return false
+		}
+	}
+	return true
+}
+
+func (r jobReviewer) PlanUpdated(plan *Plan) {}
@@ -0,0 +1,146 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mizzy/rigel/internal/llm"
+)
+
+// streamOf returns a stream that sends chunks and ends
+func streamOf(chunks ...string) <-chan llm.StreamResponse {
+	stream := make(chan llm.StreamResponse, len(chunks)+1)
+	for _, chunk := range chunks {
+		stream <- llm.StreamResponse{Content: chunk}
+	}
+	stream <- llm.StreamResponse{Done: true}
+	close(stream)
+	return stream
+}
+
+func TestStartJob(t *testing.T) {
+	mockProvider := new(MockProvider)
+	mockProvider.On("StreamWithHistory", mock.Anything, mock.MatchedBy(func(messages []llm.Message) bool {
+		return len(messages) == 1 && messages[0].Content == "refactor package X"
+	}), mock.Anything).Return(streamOf("Refactored ", "package X."), nil).Once()
+
+	a := New(mockProvider)
+	a.SetAutoToolEnabled(false)
+	job := a.StartJob("refactor package X", false)
+	assert.Equal(t, 1, job.ID)
+
+	<-job.Done()
+	status, activity := job.Status()
+	assert.Equal(t, JobDone, status)
+	assert.Empty(t, activity)
+	assert.Equal(t, "Refactored package X.", job.Output())
+	assert.NoError(t, job.Err())
+
+	found, ok := a.FindJob(1)
+	require.True(t, ok)
+	assert.Same(t, job, found)
+	_, ok = a.FindJob(2)
+	assert.False(t, ok)
+	assert.Len(t, a.Jobs(), 1)
+
+	// The job's exchange stays in its own memory
+	assert.Empty(t, a.History())
+	mockProvider.AssertExpectations(t)
+}
+
+func TestCancelJob(t *testing.T) {
+	mockProvider := new(MockProvider)
+	mockProvider.On("StreamWithHistory", mock.Anything, mock.Anything, mock.Anything).
+		Return((<-chan llm.StreamResponse)(make(chan llm.StreamResponse)), nil)
+
+	a := New(mockProvider)
+	a.SetAutoToolEnabled(false)
+	job := a.StartJob("watch forever", false)
+	updated := job.Updated()
+	job.Cancel()
+
+	<-job.Done()
+	<-updated
+	status, _ := job.Status()
+	assert.Equal(t, JobCancelled, status)
+	assert.Equal(t, "cancelled", status.String())
+
+	again := a.StartJob("again", false)
+	assert.Equal(t, 2, again.ID)
+	again.Cancel()
+	<-again.Done()
+}
+
+// newJobAgent creates an agent whose plans have the given steps, as JSON,
+// with a file tool that answers reads
+func newJobAgent(steps string) (*Agent, *MockTool) {
+	mockProvider := new(MockProvider)
+	mockProvider.On("GenerateWithOptions", mock.Anything, mock.MatchedBy(func(prompt string) bool {
+		return strings.Contains(prompt, "intent analyzer")
+	}), mock.Anything).Return(steps, nil)
+	mockProvider.On("StreamWithHistory", mock.Anything, mock.Anything, mock.Anything).Return(streamOf("It's the entry point."), nil)
+
+	fileTool := &MockTool{}
+	fileTool.On("Name").Return("file_operations")
+	fileTool.On("Description").Return("File operations")
+	fileTool.On("Execute", mock.Anything, "read main.go").Return("package main", nil)
+
+	a := New(mockProvider)
+	a.RegisterTool(fileTool)
+	return a, fileTool
+}
+
+func TestReviewedJobOnlyReads(t *testing.T) {
+	a, fileTool := newJobAgent(`[{"intent":"write","filepath":"main.go","content":"package main"}]`)
+	job := a.StartJob("empty main.go", true)
+	<-job.Done()
+
+	status, _ := job.Status()
+	assert.Equal(t, JobDone, status)
+	assert.Contains(t, job.Output(), "Background jobs only read while plans are reviewed")
+	assert.Contains(t, job.Output(), "Plan cancelled: nothing was changed.")
+	fileTool.AssertNotCalled(t, "Execute", mock.Anything, mock.Anything)
+
+	a, fileTool = newJobAgent(`[{"intent":"read","filepath":"main.go","content":""}]`)
+	var recorded recordedTools
+	a.SetToolRecorder(&recorded)
+	job = a.StartJob("what does main.go do?", true)
+	<-job.Done()
+
+	assert.NotContains(t, job.Output(), "Background jobs only read")
+	fileTool.AssertCalled(t, "Execute", mock.Anything, "read main.go")
+	require.Len(t, recorded, 1, "the job's tools are recorded")
+	assert.Equal(t, "read main.go", recorded[0].Input)
+}
+
+func TestCancelJobs(t *testing.T) {
+	mockProvider := new(MockProvider)
+	mockProvider.On("StreamWithHistory", mock.Anything, mock.Anything, mock.Anything).
+		Return((<-chan llm.StreamResponse)(make(chan llm.StreamResponse)), nil)
+
+	a := New(mockProvider)
+	a.SetAutoToolEnabled(false)
+	first, second := a.StartJob("watch forever", false), a.StartJob("and this", false)
+	require.NoError(t, a.CancelJobs(context.Background()))
+
+	for _, job := range []*Job{first, second} {
+		status, _ := job.Status()
+		assert.Equal(t, JobCancelled, status)
+	}
+
+	// A job that ignores being cancelled is reported rather than waited for
+	a.mu.Lock()
+	a.jobs = append(a.jobs, &Job{ID: 3, cancel: func() {}, done: make(chan struct{})})
+	a.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := a.CancelJobs(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), "background jobs #3 didn't stop")
+}
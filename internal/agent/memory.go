@@ -0,0 +1,112 @@
+package agent
+
+import (
+	"maps"
+	"sync"
+
+	"github.com/mizzy/rigel/internal/session"
+)
+
+// Memory holds what the agent remembers of a conversation: the messages
+// exchanged and the values set with SetContext. The agent keeps it in
+// process by default; other stores, such as a database, can be plugged in
+// with SetMemory.
+type Memory interface {
+	Messages() []Message
+	SetMessages(messages []Message)
+	AddMessages(messages ...Message)
+
+	// UpdateMessages replaces the messages with those update returns for
+	// them, with no other change to the messages in between
+	UpdateMessages(update func([]Message) []Message)
+
+	// Context returns a copy of the values set with SetContext
+	Context() map[string]interface{}
+	SetContext(key string, value interface{})
+
+	Clear()
+}
+
+// InMemory is a Memory kept in process and lost when rigel exits, unless
+// saved with a session
+type InMemory struct {
+	mu                  sync.Mutex
+	conversationHistory []Message
+	context             map[string]interface{}
+}
+
+// NewInMemory creates an empty in-process memory
+func NewInMemory() *InMemory {
+	return &InMemory{
+		conversationHistory: []Message{},
+		context:             make(map[string]interface{}),
+	}
+}
+
+func (m *InMemory) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message{}, m.conversationHistory...)
+}
+
+func (m *InMemory) SetMessages(messages []Message) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.conversationHistory = append([]Message{}, messages...)
+}
+
+func (m *InMemory) AddMessages(messages ...Message) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.conversationHistory = append(m.conversationHistory, messages...)
+}
+
+func (m *InMemory) UpdateMessages(update func([]Message) []Message) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.conversationHistory = update(m.conversationHistory)
+}
+
+func (m *InMemory) Context() map[string]interface{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return maps.Clone(m.context)
+}
+
+func (m *InMemory) SetContext(key string, value interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.context[key] = value
+}
+
+func (m *InMemory) Clear() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.conversationHistory = []Message{}
+	m.context = make(map[string]interface{})
+}
+
+// SaveMemory returns the agent's memory in the form sessions store it.
+// Context values must be encodable as JSON to survive a restart.
+func (a *Agent) SaveMemory() *session.Memory {
+	memory := a.getMemory()
+	saved := &session.Memory{Messages: []session.Message{}, Context: memory.Context()}
+	for _, msg := range memory.Messages() {
+		saved.Messages = append(saved.Messages, session.Message{Role: msg.Role, Content: msg.Content})
+	}
+	return saved
+}
+
+// RestoreMemory replaces the agent's memory with one saved by SaveMemory
+func (a *Agent) RestoreMemory(saved *session.Memory) {
+	memory := a.getMemory()
+	memory.Clear()
+	messages := make([]Message, len(saved.Messages))
+	for i, msg := range saved.Messages {
+		messages[i] = Message{Role: msg.Role, Content: msg.Content}
+	}
+	memory.SetMessages(messages)
+	for key, value := range saved.Context {
+		memory.SetContext(key, value)
+	}
+}
@@ -0,0 +1,51 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mizzy/rigel/internal/session"
+)
+
+func TestSaveAndRestoreMemory(t *testing.T) {
+	agent := New(nil)
+	agent.SetHistory([]Message{
+		{Role: "user", Content: "read main.go"},
+		{Role: "assistant", Content: "Tool read_file succeeded: package main"},
+	})
+	agent.SetContext("project", "rigel")
+
+	// Round trip through the session store, as across a restart
+	store, err := session.NewStoreAt(t.TempDir())
+	require.NoError(t, err)
+	sess := session.New()
+	sess.Memory = agent.SaveMemory()
+	require.NoError(t, store.Save(sess))
+	loaded, err := store.Load(sess.ID)
+	require.NoError(t, err)
+
+	restored := New(nil)
+	restored.SetContext("stale", true)
+	restored.RestoreMemory(loaded.Memory)
+
+	assert.Equal(t, agent.History(), restored.History())
+	project, ok := restored.GetContext("project")
+	assert.True(t, ok)
+	assert.Equal(t, "rigel", project)
+	_, ok = restored.GetContext("stale")
+	assert.False(t, ok)
+}
+
+func TestSetMemory(t *testing.T) {
+	agent := New(nil)
+	memory := NewInMemory()
+	agent.SetMemory(memory)
+
+	agent.SetHistory([]Message{{Role: "user", Content: "hi"}})
+	agent.SetContext("key", "value")
+
+	assert.Equal(t, []Message{{Role: "user", Content: "hi"}}, memory.Messages())
+	assert.Equal(t, map[string]interface{}{"key": "value"}, memory.Context())
+}
@@ -0,0 +1,196 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// StepStatus is how far a step of a plan has got
+type StepStatus int
+
+const (
+	StepPending StepStatus = iota
+	StepRunning
+	StepDone
+	StepFailed
+)
+
+// Mark returns the checkbox shown for a step with the status
+func (s StepStatus) Mark() string {
+	switch s {
+	case StepRunning:
+		return "[~]"
+	case StepDone:
+		return "[x]"
+	case StepFailed:
+		return "[!]"
+	default:
+		return "[ ]"
+	}
+}
+
+// Step is a task of a plan and how far it has got
+type Step struct {
+	Task   Task
+	Status StepStatus
+}
+
+// Plan is the list of tasks the agent will carry out for a prompt. The user
+// may remove or reorder steps before it runs; the agent then updates each
+// step's status as it goes, so frontends can show it as a checklist.
+type Plan struct {
+	mu    sync.Mutex
+	steps []Step
+}
+
+// NewPlan creates a plan carrying out tasks in order
+func NewPlan(tasks []Task) *Plan {
+	steps := make([]Step, len(tasks))
+	for i, task := range tasks {
+		steps[i] = Step{Task: task}
+	}
+	return &Plan{steps: steps}
+}
+
+// Steps returns a copy of the steps
+func (p *Plan) Steps() []Step {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Step(nil), p.steps...)
+}
+
+// Len returns the number of steps
+func (p *Plan) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.steps)
+}
+
+// Remove removes the step at index i, reporting whether there was one
+func (p *Plan) Remove(i int) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if i < 0 || i >= len(p.steps) {
+		return false
+	}
+	p.steps = append(p.steps[:i], p.steps[i+1:]...)
+	return true
+}
+
+// Move moves the step at index i by offset places, stopping at either end,
+// and returns where it ended up
+func (p *Plan) Move(i, offset int) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if i < 0 || i >= len(p.steps) {
+		return i
+	}
+	to := min(max(i+offset, 0), len(p.steps)-1)
+	step := p.steps[i]
+	if to < i {
+		copy(p.steps[to+1:i+1], p.steps[to:i])
+	} else {
+		copy(p.steps[i:to], p.steps[i+1:to+1])
+	}
+	p.steps[to] = step
+	return to
+}
+
+// Checklist lists the steps with a checkbox showing their status
+func (p *Plan) Checklist() string {
+	var sb strings.Builder
+	for i, step := range p.Steps() {
+		sb.WriteString(fmt.Sprintf("%s %d. %s\n", step.Status.Mark(), i+1, step.Task.Description))
+	}
+	return sb.String()
+}
+
+// setStatus updates the status of the step at index i
+func (p *Plan) setStatus(i int, status StepStatus) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if i >= 0 && i < len(p.steps) {
+		p.steps[i].Status = status
+	}
+}
+
+// setContent sets what the write of the step at index i puts in its file
+func (p *Plan) setContent(i int, content string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if i >= 0 && i < len(p.steps) {
+		p.steps[i].Task.Match.Content = content
+	}
+}
+
+// tasks returns the tasks of the steps, in order
+func (p *Plan) tasks() []Task {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	tasks := make([]Task, len(p.steps))
+	for i, step := range p.steps {
+		tasks[i] = step.Task
+	}
+	return tasks
+}
+
+// PlanReviewer lets the user review the agent's plans before they run
+type PlanReviewer interface {
+	// ReviewPlan shows a plan the user may edit, and reports whether they
+	// approved it. It returns false if the user cancelled it or ctx is done.
+	ReviewPlan(ctx context.Context, plan *Plan) bool
+
+	// PlanUpdated is called each time a step of an approved plan starts or
+	// finishes
+	PlanUpdated(plan *Plan)
+}
+
+// SetPlanReviewer sets who approves plans before the agent carries them
+// out. Without one, plans run as soon as they are made.
+func (a *Agent) SetPlanReviewer(reviewer PlanReviewer) {
+	a.planReviewer = reviewer
+}
+
+// runPlan carries out the steps of a plan in order, updating their status.
+// Consecutive subtasks handed to sub-agents run at the same time.
+func (a *Agent) runPlan(ctx context.Context, plan *Plan) []ToolExecutionResult {
+	var results []ToolExecutionResult
+	tasks := plan.tasks()
+	for i := 0; i < len(tasks); {
+		if tasks[i].Match.Intent == IntentDelegate {
+			end := i + 1
+			for end < len(tasks) && tasks[end].Match.Intent == IntentDelegate {
+				end++
+			}
+			results = append(results, a.runSubagents(ctx, plan, i, tasks[i:end])...)
+			i = end
+			continue
+		}
+
+		plan.setStatus(i, StepRunning)
+		a.planUpdated(plan)
+
+		stepResults := a.ExecuteTasksWithProgress(ctx, []Task{tasks[i]}, a.progressDisplay)
+		status := StepDone
+		for _, result := range stepResults {
+			if result.Error != nil {
+				status = StepFailed
+			}
+		}
+		results = append(results, stepResults...)
+
+		plan.setStatus(i, status)
+		a.planUpdated(plan)
+		i++
+	}
+	return results
+}
+
+// planUpdated tells the reviewer, if any, that a step's status changed
+func (a *Agent) planUpdated(plan *Plan) {
+	if a.planReviewer != nil {
+		a.planReviewer.PlanUpdated(plan)
+	}
+}
@@ -0,0 +1,165 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func planOf(paths ...string) *Plan {
+	var tasks []Task
+	for _, path := range paths {
+		match := FileOperationMatch{Intent: IntentRead, FilePath: path}
+		tasks = append(tasks, Task{Description: generateTaskDescription(match), Match: match})
+	}
+	return NewPlan(tasks)
+}
+
+func stepPaths(plan *Plan) []string {
+	var paths []string
+	for _, step := range plan.Steps() {
+		paths = append(paths, step.Task.Match.FilePath)
+	}
+	return paths
+}
+
+func TestPlanRemove(t *testing.T) {
+	plan := planOf("a", "b", "c")
+
+	assert.True(t, plan.Remove(1))
+	assert.Equal(t, []string{"a", "c"}, stepPaths(plan))
+	assert.False(t, plan.Remove(2))
+	assert.False(t, plan.Remove(-1))
+	assert.Equal(t, 2, plan.Len())
+}
+
+func TestPlanMove(t *testing.T) {
+	plan := planOf("a", "b", "c", "d")
+
+	assert.Equal(t, 2, plan.Move(0, 2))
+	assert.Equal(t, []string{"b", "c", "a", "d"}, stepPaths(plan))
+
+	assert.Equal(t, 2, plan.Move(3, -1))
+	assert.Equal(t, []string{"b", "c", "d", "a"}, stepPaths(plan))
+	assert.Equal(t, 0, plan.Move(2, -5))
+	assert.Equal(t, []string{"d", "b", "c", "a"}, stepPaths(plan))
+
+	// Moves past the end stop at the last step
+	assert.Equal(t, 3, plan.Move(1, 10))
+	assert.Equal(t, []string{"d", "c", "a", "b"}, stepPaths(plan))
+}
+
+func TestPlanChecklist(t *testing.T) {
+	plan := planOf("a", "b", "c")
+	plan.setStatus(0, StepDone)
+	plan.setStatus(1, StepFailed)
+
+	assert.Equal(t, "[x] 1. Read file 'a'\n[!] 2. Read file 'b'\n[ ] 3. Read file 'c'\n", plan.Checklist())
+}
+
+// scriptedReviewer edits the plans it's given and records their checklists
+type scriptedReviewer struct {
+	edit    func(plan *Plan) bool
+	updates []string
+}
+
+func (r *scriptedReviewer) ReviewPlan(ctx context.Context, plan *Plan) bool {
+	return r.edit(plan)
+}
+
+func (r *scriptedReviewer) PlanUpdated(plan *Plan) {
+	r.updates = append(r.updates, plan.Checklist())
+}
+
+func newPlanningAgent(fileTool *MockTool) (*Agent, *MockProvider) {
+	mockProvider := new(MockProvider)
+	mockProvider.On("GenerateWithOptions", mock.Anything, mock.MatchedBy(func(prompt string) bool {
+		return strings.Contains(prompt, "intent analyzer")
+	}), mock.Anything).Return(`[{"intent":"read","filepath":"a.go","content":""},{"intent":"read","filepath":"b.go","content":""},{"intent":"read","filepath":"c.go","content":""}]`, nil)
+
+	fileTool.On("Name").Return("file_operations")
+	fileTool.On("Description").Return("File operations")
+
+	a := New(mockProvider)
+	a.SetProgressDisplay(NewUIProgressDisplay())
+	a.RegisterTool(fileTool)
+	return a, mockProvider
+}
+
+func TestExecuteRunsReviewedPlan(t *testing.T) {
+	fileTool := &MockTool{}
+	a, mockProvider := newPlanningAgent(fileTool)
+	mockProvider.On("GenerateWithOptions", mock.Anything, mock.Anything, mock.Anything).Return("Done.", nil)
+	var order []string
+	fileTool.On("Execute", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		order = append(order, args.String(1))
+	}).Return("contents", nil)
+
+	reviewer := &scriptedReviewer{edit: func(plan *Plan) bool {
+		plan.Remove(1)
+		plan.Move(1, -1)
+		return true
+	}}
+	a.SetPlanReviewer(reviewer)
+
+	resp, err := a.Execute(context.Background(), "read a.go, b.go and c.go")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"read c.go", "read a.go"}, order)
+	assert.Contains(t, resp, "[x] 1. Read file 'c.go'\n[x] 2. Read file 'a.go'\n")
+	assert.Equal(t, []string{
+		"[~] 1. Read file 'c.go'\n[ ] 2. Read file 'a.go'\n",
+		"[x] 1. Read file 'c.go'\n[ ] 2. Read file 'a.go'\n",
+		"[x] 1. Read file 'c.go'\n[~] 2. Read file 'a.go'\n",
+		"[x] 1. Read file 'c.go'\n[x] 2. Read file 'a.go'\n",
+	}, reviewer.updates)
+}
+
+func TestExecuteCancelledPlan(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(plan *Plan) bool
+	}{
+		{"cancelled", func(plan *Plan) bool { return false }},
+		{"every step removed", func(plan *Plan) bool {
+			for plan.Remove(0) {
+			}
+			return true
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileTool := &MockTool{}
+			a, mockProvider := newPlanningAgent(fileTool)
+			a.SetPlanReviewer(&scriptedReviewer{edit: tt.edit})
+
+			resp, err := a.Execute(context.Background(), "read a.go, b.go and c.go")
+			require.NoError(t, err)
+
+			assert.Equal(t, "Plan cancelled: nothing was changed.", resp)
+			assert.Len(t, a.History(), 2)
+			fileTool.AssertNotCalled(t, "Execute", mock.Anything, mock.Anything)
+			// Only the prompt was analyzed, no response generated
+			mockProvider.AssertNumberOfCalls(t, "GenerateWithOptions", 1)
+		})
+	}
+}
+
+func TestExecuteCancelledWhileReviewing(t *testing.T) {
+	fileTool := &MockTool{}
+	a, _ := newPlanningAgent(fileTool)
+	ctx, cancel := context.WithCancel(context.Background())
+	a.SetPlanReviewer(&scriptedReviewer{edit: func(plan *Plan) bool {
+		cancel()
+		return false
+	}})
+
+	_, err := a.Execute(ctx, "read a.go, b.go and c.go")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, a.History())
+}
@@ -0,0 +1,141 @@
+package agent
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// defaultMaxSubagents is how many sub-agents run at once unless set with
+// SetMaxSubagents
+const defaultMaxSubagents = 3
+
+// SetMaxSubagents sets how many sub-agents may work on delegated subtasks at
+// the same time, at least one
+func (a *Agent) SetMaxSubagents(n int) {
+	a.maxSubagents = max(n, 1)
+}
+
+// newSubagent creates an agent for a delegated subtask. It has its own
+// memory and only the tools the subtask may use, shares the provider,
+// settings and context providers, reports progress prefixed with the
+// subtask's name, and can't delegate further.
+func (a *Agent) newSubagent(match FileOperationMatch, progress *subagentProgress) *Agent {
+	sub := a.spawn()
+	sub.subagent = true
+	sub.progressDisplay = progress
+	if a.toolRecorder != nil {
+		// Recorded with the subtask's name
+		sub.toolRecorder = progress
+	}
+	for _, tool := range a.tools {
+		if len(match.Tools) == 0 || slices.Contains(match.Tools, tool.Name()) {
+			sub.tools = append(sub.tools, tool)
+		}
+	}
+	return sub
+}
+
+// spawn creates an agent with its own empty memory and no tools that shares
+// the provider, settings, context providers and tool recorder
+func (a *Agent) spawn() *Agent {
+	sub := New(a.provider)
+	sub.dryRun = a.IsDryRun()
+	sub.persona = a.Persona()
+	sub.autoToolEnabled = a.IsAutoToolEnabled()
+	sub.fastIntent = a.IsFastIntent()
+	sub.maxFixIterations = a.maxFixIterations
+	sub.toolTimeout = a.toolTimeout
+	sub.contextProviders = a.contextProviders
+	sub.toolRecorder = a.toolRecorder
+	return sub
+}
+
+// runSubagents carries out delegated subtasks, the steps of plan from index
+// first on, each with its own sub-agent, running at most maxSubagents at
+// once. It returns a result per subtask, in order, with the sub-agent's
+// response as its output.
+func (a *Agent) runSubagents(ctx context.Context, plan *Plan, first int, tasks []Task) []ToolExecutionResult {
+	results := make([]ToolExecutionResult, len(tasks))
+	slots := make(chan struct{}, max(a.maxSubagents, 1))
+	var mu sync.Mutex // Serializes progress reports and plan updates
+	var wg sync.WaitGroup
+
+	for i, task := range tasks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			case <-ctx.Done():
+				results[i] = ToolExecutionResult{Tool: "delegate", Input: task.Match.Content, Error: ctx.Err(), StartTime: time.Now()}
+				mu.Lock()
+				plan.setStatus(first+i, StepFailed)
+				a.planUpdated(plan)
+				mu.Unlock()
+				return
+			}
+
+			mu.Lock()
+			plan.setStatus(first+i, StepRunning)
+			a.planUpdated(plan)
+			a.progressDisplay.ShowProgress("delegate", task.Match.FilePath)
+			mu.Unlock()
+
+			progress := &subagentProgress{name: task.Match.FilePath, mu: &mu, display: a.progressDisplay, recorder: a.toolRecorder}
+			start := time.Now()
+			response, err := a.newSubagent(task.Match, progress).Execute(ctx, task.Match.Content)
+			result := ToolExecutionResult{
+				Tool:      "delegate",
+				Input:     task.Match.Content,
+				Output:    response,
+				Error:     err,
+				Duration:  time.Since(start),
+				StartTime: start,
+			}
+			results[i] = result
+
+			status := StepDone
+			if err != nil {
+				status = StepFailed
+			}
+			mu.Lock()
+			plan.setStatus(first+i, status)
+			a.planUpdated(plan)
+			a.progressDisplay.ShowResult(result)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// subagentProgress passes on what a sub-agent does to its parent's progress
+// display and tool recorder, naming the subtask, one sub-agent at a time
+type subagentProgress struct {
+	name     string
+	mu       *sync.Mutex
+	display  ProgressDisplay
+	recorder ToolRecorder
+}
+
+func (p *subagentProgress) ShowProgress(toolName, operation string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.display.ShowProgress("["+p.name+"] "+toolName, operation)
+}
+
+func (p *subagentProgress) ShowResult(result ToolExecutionResult) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	result.Tool = "[" + p.name + "] " + result.Tool
+	p.display.ShowResult(result)
+}
+
+func (p *subagentProgress) RecordTool(result ToolExecutionResult) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.recorder.RecordTool(result)
+}
@@ -0,0 +1,133 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// analyzing matches the intent analyzer's prompt for a user message
+func analyzing(message string) interface{} {
+	return mock.MatchedBy(func(prompt string) bool {
+		return strings.Contains(prompt, "intent analyzer") && strings.Contains(prompt, "Current user message: "+message+"\n")
+	})
+}
+
+// concurrency tracks how many tool calls run at once
+type concurrency struct {
+	mu      sync.Mutex
+	running int
+	peak    int
+	wait    time.Duration // How long each call takes
+}
+
+func (c *concurrency) run(mock.Arguments) {
+	c.mu.Lock()
+	c.running++
+	c.peak = max(c.peak, c.running)
+	c.mu.Unlock()
+
+	time.Sleep(c.wait)
+
+	c.mu.Lock()
+	c.running--
+	c.mu.Unlock()
+}
+
+func newDelegatingAgent(t *testing.T, calls *concurrency) (*Agent, *UIProgressDisplay) {
+	t.Helper()
+	mockProvider := new(MockProvider)
+	mockProvider.On("GenerateWithOptions", mock.Anything, analyzing("update the docs and fix the tests"), mock.Anything).
+		Return(`[{"intent":"delegate","filepath":"docs","content":"read docs.md","tools":["file_operations"]},{"intent":"delegate","filepath":"tests","content":"read tests.go"}]`, nil)
+	// Sub-agents can't delegate again
+	mockProvider.On("GenerateWithOptions", mock.Anything, analyzing("read docs.md"), mock.Anything).
+		Return(`[{"intent":"read","filepath":"docs.md","content":""},{"intent":"delegate","filepath":"more","content":"read more.md"}]`, nil)
+	mockProvider.On("GenerateWithOptions", mock.Anything, analyzing("read tests.go"), mock.Anything).
+		Return(`[{"intent":"read","filepath":"tests.go","content":""}]`, nil)
+	mockProvider.On("GenerateWithOptions", mock.Anything, mock.Anything, mock.Anything).Return("Done.", nil)
+
+	fileTool := &MockTool{}
+	fileTool.On("Name").Return("file_operations")
+	fileTool.On("Description").Return("File operations")
+	fileTool.On("Execute", mock.Anything, mock.Anything).Run(calls.run).Return("contents", nil)
+
+	a := New(mockProvider)
+	display := NewUIProgressDisplay()
+	a.SetProgressDisplay(display)
+	a.RegisterTool(fileTool)
+	return a, display
+}
+
+func TestExecuteRunsSubagentsConcurrently(t *testing.T) {
+	calls := &concurrency{wait: 50 * time.Millisecond}
+	a, display := newDelegatingAgent(t, calls)
+	var recorded recordedTools
+	a.SetToolRecorder(&recorded)
+	reviewer := &scriptedReviewer{edit: func(plan *Plan) bool { return true }}
+	a.SetPlanReviewer(reviewer)
+
+	resp, err := a.Execute(context.Background(), "update the docs and fix the tests")
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, calls.peak)
+	assert.Contains(t, resp, "[x] 1. Hand 'docs' to a sub-agent: read docs.md\n[x] 2. Hand 'tests' to a sub-agent: read tests.go\n")
+	assert.Equal(t, "[x] 1. Hand 'docs' to a sub-agent: read docs.md\n[x] 2. Hand 'tests' to a sub-agent: read tests.go\n",
+		reviewer.updates[len(reviewer.updates)-1])
+
+	messages := strings.Join(display.GetAllMessages(), "\n")
+	assert.Contains(t, messages, "[docs] read")
+	assert.Contains(t, messages, "[tests] read")
+	assert.NotContains(t, messages, "more", "a sub-agent shouldn't delegate")
+
+	// The sub-agents' reads and the delegations themselves
+	var tools []string
+	for _, result := range recorded {
+		tools = append(tools, result.Tool)
+	}
+	assert.ElementsMatch(t, []string{"read", "read", "delegate", "delegate"}, tools)
+	assert.Len(t, a.History(), 2, "sub-agents have their own memory")
+}
+
+func TestExecuteLimitsSubagents(t *testing.T) {
+	calls := &concurrency{wait: 20 * time.Millisecond}
+	a, _ := newDelegatingAgent(t, calls)
+	a.SetMaxSubagents(0)
+
+	_, err := a.Execute(context.Background(), "update the docs and fix the tests")
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls.peak)
+}
+
+func TestNewSubagentScopesTools(t *testing.T) {
+	a := New(new(MockProvider))
+	for _, name := range []string{"file_operations", "run_tests", "check_code"} {
+		tool := &MockTool{}
+		tool.On("Name").Return(name)
+		a.RegisterTool(tool)
+	}
+	a.SetMaxFixIterations(1)
+	progress := &subagentProgress{name: "docs", mu: &sync.Mutex{}, display: NewUIProgressDisplay()}
+
+	toolNames := func(agent *Agent) []string {
+		var names []string
+		for _, tool := range agent.tools {
+			names = append(names, tool.Name())
+		}
+		return names
+	}
+
+	sub := a.newSubagent(FileOperationMatch{Intent: IntentDelegate, Tools: []string{"run_tests", "check_code"}}, progress)
+	assert.Equal(t, []string{"run_tests", "check_code"}, toolNames(sub))
+	assert.True(t, sub.subagent)
+	assert.Equal(t, 1, sub.maxFixIterations)
+	assert.NotSame(t, a.memory, sub.memory)
+
+	sub = a.newSubagent(FileOperationMatch{Intent: IntentDelegate}, progress)
+	assert.Equal(t, []string{"file_operations", "run_tests", "check_code"}, toolNames(sub))
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/mizzy/rigel/internal/llm"
 	"github.com/mizzy/rigel/internal/tools"
 )
 
@@ -18,6 +19,13 @@ type ToolExecutionResult struct {
 	Error     error
 	Duration  time.Duration
 	StartTime time.Time
+	Diff      string // What a write changed in an existing file, as a unified diff
+}
+
+// ToolRecorder records the tools the agent ran, e.g. in a session's
+// transcript
+type ToolRecorder interface {
+	RecordTool(result ToolExecutionResult)
 }
 
 // ProgressDisplay interface for showing tool execution progress
@@ -46,6 +54,7 @@ func (c *ConsoleProgressDisplay) ShowResult(result ToolExecutionResult) {
 type UIProgressDisplay struct {
 	progressMessages []string
 	resultMessages   []string
+	updates          chan<- string // Receives each message as it happens, if set
 }
 
 func NewUIProgressDisplay() *UIProgressDisplay {
@@ -65,9 +74,28 @@ func (u *UIProgressDisplay) Initialize() {
 	}
 }
 
+// SetUpdates sends each progress and result message to updates as the tools
+// run, so a UI can show them live. Messages are dropped rather than blocking
+// the agent when updates is full.
+func (u *UIProgressDisplay) SetUpdates(updates chan<- string) {
+	u.updates = updates
+}
+
+// publish sends a message to the updates channel without blocking
+func (u *UIProgressDisplay) publish(msg string) {
+	if u.updates == nil {
+		return
+	}
+	select {
+	case u.updates <- msg:
+	default:
+	}
+}
+
 func (u *UIProgressDisplay) ShowProgress(toolName, operation string) {
 	msg := fmt.Sprintf("🔧 Executing %s: %s...", toolName, operation)
 	u.progressMessages = append(u.progressMessages, msg)
+	u.publish(msg)
 }
 
 func (u *UIProgressDisplay) ShowResult(result ToolExecutionResult) {
@@ -79,6 +107,7 @@ func (u *UIProgressDisplay) ShowResult(result ToolExecutionResult) {
 		msg = fmt.Sprintf("✅ %s completed (%v)", result.Tool, duration)
 	}
 	u.resultMessages = append(u.resultMessages, msg)
+	u.publish(msg)
 }
 
 func (u *UIProgressDisplay) GetProgressMessages() []string {
@@ -103,15 +132,22 @@ const (
 	IntentRead FileOperationIntent = iota
 	IntentWrite
 	IntentList
+	IntentTree
 	IntentExists
 	IntentDelete
+	IntentSearch
+	IntentWebSearch
+	IntentTest
+	IntentCheck
+	IntentRemember
+	IntentDelegate
 	IntentNone
 )
 
 // PromptAnalyzer analyzes user prompts using LLM to determine if file operations are needed
 type PromptAnalyzer struct {
 	llmProvider interface {
-		Generate(ctx context.Context, prompt string) (string, error)
+		GenerateWithOptions(ctx context.Context, prompt string, opts llm.GenerateOptions) (string, error)
 	}
 }
 
@@ -125,7 +161,7 @@ type Task struct {
 
 // NewPromptAnalyzer creates a new LLM-based prompt analyzer
 func NewPromptAnalyzer(llmProvider interface {
-	Generate(ctx context.Context, prompt string) (string, error)
+	GenerateWithOptions(ctx context.Context, prompt string, opts llm.GenerateOptions) (string, error)
 }) *PromptAnalyzer {
 	return &PromptAnalyzer{
 		llmProvider: llmProvider,
@@ -137,18 +173,15 @@ func (pa *PromptAnalyzer) AnalyzePrompt(prompt string) []FileOperationMatch {
 	return pa.AnalyzePromptWithHistory(prompt, []Message{})
 }
 
-// AnalyzePromptWithHistory analyzes a user prompt with conversation history context
-func (pa *PromptAnalyzer) AnalyzePromptWithHistory(prompt string, history []Message) []FileOperationMatch {
-	ctx := context.Background()
-
-	systemPrompt := `You are a file operation intent analyzer with access to conversation history. Analyze the given user prompt in context and determine if it contains any file operation requests.
-
-You have access to previous conversation context to resolve references like "it", "that file", "the config", etc.
+// intentFormat describes the JSON array of operations the model answers
+// with when a prompt needs tools
+const intentFormat = `Respond with a JSON array containing file operations. Each operation should have:
+- "intent": one of "read", "write", "list", "tree", "exists", "delete", "search", "web_search", "test", "check", "remember", "delegate", "none"
+- "filepath": the target file path (resolve references using context, use "sample.txt" if not specified for write operations). Files in another workspace root are written as label:path. For tree operations, the directory whose layout to show, "." for the whole project. For search operations, the text to search for in the project's files. For web_search operations, the query to search the web for. For test operations, the packages or test filter to run, or "" for all tests. For remember operations, the fact to remember in future sessions, stated on its own. For delegate operations, a short name for the subtask.
+- "content": the content to write (only for write operations), for tree operations an optional glob such as "*.go" to show only the matching files, or for delegate operations the subtask's instructions, complete enough to be carried out on their own. Use "<GENERATE_TEXT>" when the user asks to generate content like "sample text", "dummy content", "適当な文章", "some text", etc.
+- "tools": only for delegate operations, the tools the subtask needs among "file_operations", "run_tests", "check_code" and "web_search"; omit it to allow them all
 
-Respond with a JSON array containing file operations. Each operation should have:
-- "intent": one of "read", "write", "list", "exists", "delete", "none"
-- "filepath": the target file path (resolve references using context, use "sample.txt" if not specified for write operations)
-- "content": the content to write (only for write operations). Use "<GENERATE_TEXT>" when the user asks to generate content like "sample text", "dummy content", "適当な文章", "some text", etc.
+Use delegate operations only when the prompt asks for several independent pieces of work that can be done at the same time; each is carried out by a sub-agent.
 
 Examples with context:
 Conversation: [User: "create config.json", Assistant: "Created config.json"]
@@ -162,11 +195,69 @@ Response: [{"intent":"delete","filepath":"test.txt","content":""}]
 User: "適当な文章をファイルに書き出して"
 Response: [{"intent":"write","filepath":"sample.txt","content":"<GENERATE_TEXT>"}]
 
+User: "how is this project laid out?"
+Response: [{"intent":"tree","filepath":".","content":""}]
+
+User: "where is ParseConfig used?"
+Response: [{"intent":"search","filepath":"ParseConfig","content":""}]
+
+User: "search the web for the latest bubbletea release notes"
+Response: [{"intent":"web_search","filepath":"bubbletea release notes","content":""}]
+
+User: "fix the failing tests"
+Response: [{"intent":"test","filepath":"","content":""}]
+
+User: "does it build? fix the lint errors"
+Response: [{"intent":"check","filepath":"","content":""}]
+
+User: "remember that we use uber-fx for DI"
+Response: [{"intent":"remember","filepath":"We use uber-fx for dependency injection","content":""}]
+
+User: "update the README for the new --verbose flag and fix the failing tests"
+Response: [{"intent":"delegate","filepath":"docs","content":"Update README.md to document the new --verbose flag","tools":["file_operations"]},{"intent":"delegate","filepath":"tests","content":"Run the tests and fix the ones that fail"}]
+
 User: "how are you today?"
-Response: [{"intent":"none","filepath":"","content":""}]
+Response: [{"intent":"none","filepath":"","content":""}]`
+
+// intentAnalyzerPrompt asks the model for the operations a prompt needs
+const intentAnalyzerPrompt = `You are a file operation intent analyzer with access to conversation history. Analyze the given user prompt in context and determine if it contains any file operation requests.
+
+You have access to previous conversation context to resolve references like "it", "that file", "the config", etc.
+
+` + intentFormat + `
 
 Only respond with the JSON array, nothing else.`
 
+// intentResponseFormat makes the provider return the operations as
+// parseable JSON, an object holding the array since that is what the APIs
+// enforce
+var intentResponseFormat = llm.JSONSchema("operations", map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"operations": map[string]any{
+			"type": "array",
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"intent": map[string]any{
+						"type": "string",
+						"enum": []string{"read", "write", "list", "tree", "exists", "delete", "search", "web_search", "test", "check", "remember", "delegate", "none"},
+					},
+					"filepath": map[string]any{"type": "string"},
+					"content":  map[string]any{"type": "string"},
+					"tools":    map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+				},
+				"required": []string{"intent", "filepath", "content"},
+			},
+		},
+	},
+	"required": []string{"operations"},
+})
+
+// AnalyzePromptWithHistory analyzes a user prompt with conversation history context
+func (pa *PromptAnalyzer) AnalyzePromptWithHistory(prompt string, history []Message) []FileOperationMatch {
+	ctx := context.Background()
+
 	// Build conversation context
 	var contextBuilder strings.Builder
 	if len(history) > 0 {
@@ -176,20 +267,35 @@ Only respond with the JSON array, nothing else.`
 		}
 	}
 
-	fullPrompt := fmt.Sprintf("%s%s\n\nCurrent user message: %s\nResponse:", systemPrompt, contextBuilder.String(), prompt)
+	fullPrompt := fmt.Sprintf("%s%s\n\nCurrent user message: %s\nResponse:", intentAnalyzerPrompt, contextBuilder.String(), prompt)
 
-	response, err := pa.llmProvider.Generate(ctx, fullPrompt)
+	response, err := pa.llmProvider.GenerateWithOptions(ctx, fullPrompt, llm.GenerateOptions{ResponseFormat: intentResponseFormat})
 	if err != nil {
 		// Fallback to no matches if LLM fails
 		return []FileOperationMatch{}
 	}
 
-	// Parse JSON response
-	var rawMatches []struct {
-		Intent   string `json:"intent"`
-		FilePath string `json:"filepath"`
-		Content  string `json:"content"`
+	matches, ok := parseIntents(response)
+	if !ok {
+		// If JSON parsing fails, return no matches
+		return []FileOperationMatch{}
 	}
+	return matches
+}
+
+// rawIntent is an operation of a response in intentFormat
+type rawIntent struct {
+	Intent   string   `json:"intent"`
+	FilePath string   `json:"filepath"`
+	Content  string   `json:"content"`
+	Tools    []string `json:"tools"`
+}
+
+// parseIntents reads the operations of a response in intentFormat, or in
+// intentResponseFormat. It reports whether the response was such an array,
+// which may hold no operations.
+func parseIntents(response string) ([]FileOperationMatch, bool) {
+	var rawMatches []rawIntent
 
 	// Clean response (remove markdown code blocks if present)
 	cleanResponse := strings.TrimSpace(response)
@@ -200,8 +306,13 @@ Only respond with the JSON array, nothing else.`
 	}
 
 	if err := json.Unmarshal([]byte(cleanResponse), &rawMatches); err != nil {
-		// If JSON parsing fails, return no matches
-		return []FileOperationMatch{}
+		var structured struct {
+			Operations *[]rawIntent `json:"operations"`
+		}
+		if err := json.Unmarshal([]byte(cleanResponse), &structured); err != nil || structured.Operations == nil {
+			return nil, false
+		}
+		rawMatches = *structured.Operations
 	}
 
 	// Convert to FileOperationMatch
@@ -219,10 +330,24 @@ Only respond with the JSON array, nothing else.`
 			intent = IntentWrite
 		case "list":
 			intent = IntentList
+		case "tree":
+			intent = IntentTree
 		case "exists":
 			intent = IntentExists
 		case "delete":
 			intent = IntentDelete
+		case "search":
+			intent = IntentSearch
+		case "web_search":
+			intent = IntentWebSearch
+		case "test":
+			intent = IntentTest
+		case "check":
+			intent = IntentCheck
+		case "remember":
+			intent = IntentRemember
+		case "delegate":
+			intent = IntentDelegate
 		default:
 			continue
 		}
@@ -231,10 +356,11 @@ Only respond with the JSON array, nothing else.`
 			Intent:   intent,
 			FilePath: raw.FilePath,
 			Content:  raw.Content,
+			Tools:    raw.Tools,
 		})
 	}
 
-	return matches
+	return matches, true
 }
 
 // FileOperationMatch represents a matched file operation from the prompt
@@ -242,6 +368,7 @@ type FileOperationMatch struct {
 	Intent   FileOperationIntent
 	FilePath string
 	Content  string
+	Tools    []string // Tools a delegated subtask may use; all if empty
 }
 
 // CreateTasksFromMatches converts file operation matches into structured tasks
@@ -276,10 +403,34 @@ func generateTaskDescription(match FileOperationMatch) string {
 			return "List files in current directory"
 		}
 		return fmt.Sprintf("List files in '%s'", match.FilePath)
+	case IntentTree:
+		desc := "Show the directory tree"
+		if match.FilePath != "." && match.FilePath != "" {
+			desc += fmt.Sprintf(" of '%s'", match.FilePath)
+		}
+		if match.Content != "" {
+			desc += fmt.Sprintf(" with files matching '%s'", match.Content)
+		}
+		return desc
 	case IntentExists:
 		return fmt.Sprintf("Check if '%s' exists", match.FilePath)
 	case IntentDelete:
 		return fmt.Sprintf("Delete file '%s'", match.FilePath)
+	case IntentSearch:
+		return fmt.Sprintf("Search files for '%s'", match.FilePath)
+	case IntentWebSearch:
+		return fmt.Sprintf("Search the web for '%s'", match.FilePath)
+	case IntentTest:
+		if match.FilePath == "" {
+			return "Run the tests"
+		}
+		return fmt.Sprintf("Run the tests in '%s'", match.FilePath)
+	case IntentCheck:
+		return "Build and lint the project"
+	case IntentRemember:
+		return fmt.Sprintf("Remember '%s'", match.FilePath)
+	case IntentDelegate:
+		return fmt.Sprintf("Hand '%s' to a sub-agent: %s", match.FilePath, match.Content)
 	default:
 		return "Unknown task"
 	}
@@ -310,30 +461,11 @@ func (a *Agent) ExecuteTasksWithProgress(ctx context.Context, tasks []Task, prog
 func (a *Agent) ExecuteFileOperationsWithProgress(ctx context.Context, matches []FileOperationMatch, progressDisplay ProgressDisplay) []ToolExecutionResult {
 	var results []ToolExecutionResult
 
-	// Find the file tool
-	var fileTool tools.Tool
-	for _, tool := range a.tools {
-		if tool.Name() == "file_operations" {
-			fileTool = tool
-			break
-		}
-	}
-
-	if fileTool == nil {
-		result := ToolExecutionResult{
-			Tool:      "file_operations",
-			Error:     fmt.Errorf("file tool not registered"),
-			StartTime: time.Now(),
-		}
-		result.Duration = time.Since(result.StartTime)
-		results = append(results, result)
-		return results
-	}
-
 	for _, match := range matches {
 		var input string
 		var operation string
 		var operationDesc string
+		toolName := "file_operations"
 
 		switch match.Intent {
 		case IntentRead:
@@ -343,7 +475,8 @@ func (a *Agent) ExecuteFileOperationsWithProgress(ctx context.Context, matches [
 		case IntentWrite:
 			operation = "write"
 			operationDesc = fmt.Sprintf("Writing to file '%s'", match.FilePath)
-			input = fmt.Sprintf("write %s %s", match.FilePath, match.Content)
+			// The content follows the path's line verbatim
+			input = fmt.Sprintf("write %s\n%s", match.FilePath, match.Content)
 		case IntentList:
 			operation = "list"
 			if match.FilePath == "" || match.FilePath == "." {
@@ -353,6 +486,17 @@ func (a *Agent) ExecuteFileOperationsWithProgress(ctx context.Context, matches [
 				operationDesc = fmt.Sprintf("Listing directory '%s'", match.FilePath)
 				input = fmt.Sprintf("list %s", match.FilePath)
 			}
+		case IntentTree:
+			operation = "tree"
+			path := match.FilePath
+			if path == "" {
+				path = "."
+			}
+			operationDesc = fmt.Sprintf("Showing the directory tree of '%s'", path)
+			input = "tree " + path
+			if match.Content != "" {
+				input += " --glob " + match.Content
+			}
 		case IntentExists:
 			operation = "exists"
 			operationDesc = fmt.Sprintf("Checking existence of '%s'", match.FilePath)
@@ -361,16 +505,61 @@ func (a *Agent) ExecuteFileOperationsWithProgress(ctx context.Context, matches [
 			operation = "delete"
 			operationDesc = fmt.Sprintf("Deleting file '%s'", match.FilePath)
 			input = fmt.Sprintf("delete %s", match.FilePath)
+		case IntentSearch:
+			operation = "search"
+			operationDesc = fmt.Sprintf("Searching files for '%s'", match.FilePath)
+			input = fmt.Sprintf("search %s", match.FilePath)
+		case IntentWebSearch:
+			operation = "web_search"
+			toolName = "web_search"
+			operationDesc = fmt.Sprintf("Searching the web for '%s'", match.FilePath)
+			input = match.FilePath
+		case IntentTest:
+			operation = "test"
+			toolName = "run_tests"
+			operationDesc = "Running tests"
+			input = match.FilePath
+		case IntentCheck:
+			operation = "check"
+			toolName = "check_code"
+			operationDesc = "Building and linting"
+			input = "check"
+		case IntentRemember:
+			operation = "remember"
+			toolName = "remember"
+			operationDesc = "Remembering for future sessions"
+			input = match.FilePath
 		default:
 			continue
 		}
 
+		tool := a.findTool(toolName)
+		if tool == nil {
+			result := ToolExecutionResult{
+				Tool:      operation,
+				Input:     input,
+				Error:     fmt.Errorf("%s tool not registered", toolName),
+				StartTime: time.Now(),
+			}
+			progressDisplay.ShowResult(result)
+			results = append(results, result)
+			continue
+		}
+
+		// Keep what a write overwrites, to show what changed
+		var before string
+		var overwrites bool
+		if match.Intent == IntentWrite {
+			content, err := tool.Execute(ctx, "read "+match.FilePath)
+			before, overwrites = content, err == nil
+		}
+
 		// Show progress before execution
 		progressDisplay.ShowProgress(operation, operationDesc)
 
 		// Execute with timing
 		startTime := time.Now()
-		output, err := fileTool.Execute(ctx, input)
+		output, err := a.runTool(ctx, tool, input)
 		duration := time.Since(startTime)
 
 		result := ToolExecutionResult{
@@ -381,6 +570,14 @@ func (a *Agent) ExecuteFileOperationsWithProgress(ctx context.Context, matches [
 			Duration:  duration,
 			StartTime: startTime,
 		}
+		if overwrites && err == nil {
+			if after, err := tool.Execute(ctx, "read "+match.FilePath); err == nil {
+				result.Diff = unifiedDiff(match.FilePath, before, after)
+			}
+		}
+		if err == nil && (match.Intent == IntentRead || match.Intent == IntentWrite || match.Intent == IntentDelete) {
+			trackFile(a.getMemory(), tool, match)
+		}
 
 		// Show result after execution
 		progressDisplay.ShowResult(result)
@@ -391,6 +588,38 @@ func (a *Agent) ExecuteFileOperationsWithProgress(ctx context.Context, matches [
 	return results
 }
 
+// SetToolTimeout sets how long each tool the agent runs may take; 0
+// removes the limit
+func (a *Agent) SetToolTimeout(timeout time.Duration) {
+	a.toolTimeout = max(timeout, 0)
+}
+
+// runTool runs a tool, cancelling it when it takes longer than the tool
+// timeout
+func (a *Agent) runTool(ctx context.Context, tool tools.Tool, input string) (string, error) {
+	if a.toolTimeout <= 0 {
+		return tool.Execute(ctx, input)
+	}
+	timedOut := fmt.Errorf("%s timed out after %v", tool.Name(), a.toolTimeout)
+	ctx, cancel := context.WithTimeoutCause(ctx, a.toolTimeout, timedOut)
+	defer cancel()
+	output, err := tool.Execute(ctx, input)
+	if err != nil && context.Cause(ctx) == timedOut {
+		return output, timedOut
+	}
+	return output, err
+}
+
+// findTool returns the registered tool with the given name, or nil
+func (a *Agent) findTool(name string) tools.Tool {
+	for _, tool := range a.tools {
+		if tool.Name() == name {
+			return tool
+		}
+	}
+	return nil
+}
+
 // IntentToString converts FileOperationIntent to string
 func IntentToString(intent FileOperationIntent) string {
 	switch intent {
@@ -400,10 +629,24 @@ func IntentToString(intent FileOperationIntent) string {
 		return "write"
 	case IntentList:
 		return "list"
+	case IntentTree:
+		return "tree"
 	case IntentExists:
 		return "exists"
 	case IntentDelete:
 		return "delete"
+	case IntentSearch:
+		return "search"
+	case IntentWebSearch:
+		return "web_search"
+	case IntentTest:
+		return "test"
+	case IntentCheck:
+		return "check"
+	case IntentRemember:
+		return "remember"
+	case IntentDelegate:
+		return "delegate"
 	default:
 		return "none"
 	}
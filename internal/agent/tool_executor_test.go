@@ -3,12 +3,19 @@ package agent
 import (
 	"context"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mizzy/rigel/internal/llm"
 )
 
 // MockLLMProvider for testing
 type MockLLMProvider struct{}
 
-func (m *MockLLMProvider) Generate(ctx context.Context, prompt string) (string, error) {
+func (m *MockLLMProvider) GenerateWithOptions(ctx context.Context, prompt string, opts llm.GenerateOptions) (string, error) {
 	// Return simple mock response for testing
 	return `[{"intent":"none","filepath":"","content":""}]`, nil
 }
@@ -22,6 +29,31 @@ func TestNewPromptAnalyzer(t *testing.T) {
 	}
 }
 
+func TestAnalyzePromptStructured(t *testing.T) {
+	mockProvider := new(MockProvider)
+	mockProvider.On("GenerateWithOptions", mock.Anything, analyzing("what does main.go do?"), llm.GenerateOptions{ResponseFormat: intentResponseFormat}).
+		Return(`{"operations":[{"intent":"read","filepath":"main.go","content":""}]}`, nil)
+
+	matches := NewPromptAnalyzer(mockProvider).AnalyzePrompt("what does main.go do?")
+	assert.Equal(t, []FileOperationMatch{{Intent: IntentRead, FilePath: "main.go"}}, matches)
+	mockProvider.AssertExpectations(t)
+}
+
+func TestParseIntents(t *testing.T) {
+	matches, ok := parseIntents("```json\n[{\"intent\":\"list\",\"filepath\":\".\",\"content\":\"\"}]\n```")
+	assert.True(t, ok)
+	assert.Equal(t, []FileOperationMatch{{Intent: IntentList, FilePath: "."}}, matches)
+
+	matches, ok = parseIntents(`{"operations":[{"intent":"none","filepath":"","content":""}]}`)
+	assert.True(t, ok)
+	assert.Empty(t, matches)
+
+	_, ok = parseIntents(`{"answer":"main.go starts the server"}`)
+	assert.False(t, ok)
+	_, ok = parseIntents("[1] main.go starts the server")
+	assert.False(t, ok)
+}
+
 func TestIntentToString(t *testing.T) {
 	testCases := []struct {
 		intent   FileOperationIntent
@@ -30,8 +62,15 @@ func TestIntentToString(t *testing.T) {
 		{IntentRead, "read"},
 		{IntentWrite, "write"},
 		{IntentList, "list"},
+		{IntentTree, "tree"},
 		{IntentExists, "exists"},
 		{IntentDelete, "delete"},
+		{IntentSearch, "search"},
+		{IntentWebSearch, "web_search"},
+		{IntentTest, "test"},
+		{IntentCheck, "check"},
+		{IntentRemember, "remember"},
+		{IntentDelegate, "delegate"},
 		{IntentNone, "none"},
 	}
 
@@ -42,3 +81,57 @@ func TestIntentToString(t *testing.T) {
 		}
 	}
 }
+
+func TestExecuteFileOperationsRunsTests(t *testing.T) {
+	testTool := &MockTool{}
+	testTool.On("Name").Return("run_tests")
+	testTool.On("Execute", mock.Anything, "./internal/llm/...").Return("Tests failed: 3 passed, 1 failed, 0 skipped", nil)
+
+	a := New(&MockProvider{})
+	a.RegisterTool(testTool)
+
+	results := a.ExecuteFileOperationsWithProgress(context.Background(), []FileOperationMatch{
+		{Intent: IntentTest, FilePath: "./internal/llm/..."},
+		{Intent: IntentRead, FilePath: "main.go"},
+	}, NewUIProgressDisplay())
+
+	require.Len(t, results, 2)
+	assert.Equal(t, "test", results[0].Tool)
+	assert.Equal(t, "Tests failed: 3 passed, 1 failed, 0 skipped", results[0].Output)
+	assert.ErrorContains(t, results[1].Error, "file_operations tool not registered")
+	testTool.AssertExpectations(t)
+}
+
+func TestExecuteFileOperationsToolTimeout(t *testing.T) {
+	testTool := &MockTool{}
+	testTool.On("Name").Return("run_tests")
+	testTool.On("Execute", mock.Anything, "./...").Run(func(args mock.Arguments) {
+		<-args.Get(0).(context.Context).Done()
+	}).Return("", context.DeadlineExceeded)
+
+	a := New(&MockProvider{})
+	a.RegisterTool(testTool)
+	a.SetToolTimeout(10 * time.Millisecond)
+
+	results := a.ExecuteFileOperationsWithProgress(context.Background(), []FileOperationMatch{
+		{Intent: IntentTest, FilePath: "./..."},
+	}, NewUIProgressDisplay())
+
+	require.Len(t, results, 1)
+	assert.EqualError(t, results[0].Error, "run_tests timed out after 10ms")
+}
+
+func TestUIProgressDisplayPublishesUpdates(t *testing.T) {
+	updates := make(chan string, 1)
+	display := NewUIProgressDisplay()
+	display.SetUpdates(updates)
+
+	display.ShowProgress("read", "Reading file 'main.go'")
+	assert.Equal(t, "🔧 Executing read: Reading file 'main.go'...", <-updates)
+
+	// A full channel drops updates instead of blocking the agent
+	display.ShowResult(ToolExecutionResult{Tool: "read"})
+	display.ShowResult(ToolExecutionResult{Tool: "write"})
+	assert.Equal(t, "✅ read completed (0s)", <-updates)
+	assert.Len(t, display.GetAllMessages(), 3)
+}
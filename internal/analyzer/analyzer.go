@@ -1,21 +1,33 @@
 package analyzer
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"go/parser"
+	"go/token"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strings"
+	"sync"
 
 	"github.com/mizzy/rigel/internal/llm"
+	"github.com/mizzy/rigel/internal/workspace"
 )
 
 type RepoAnalyzer struct {
 	rootPath string
+	extra    []workspace.Root // Additional workspace roots, scanned with label-prefixed paths
 	files    []FileInfo
 	dirs     []string
 	provider llm.Provider
+	workers  int
+
+	tokenBudget int // Approximate tokens of file listings sent in a single prompt
 }
 
 type FileInfo struct {
@@ -23,9 +35,53 @@ type FileInfo struct {
 	RelativePath string
 	Extension    string
 	Size         int64
+	Lines        int
+	Hash         string
 	IsTest       bool
+	Summary      string // Package and doc comment of key files, filled in while summarizing
+}
+
+// Stages reported through Progress
+const (
+	StageScanning    = "scanning"
+	StageSummarizing = "summarizing"
+	StageChunks      = "chunks"
+	StageGenerating  = "generating"
+	StageUpdating    = "updating"
+)
+
+// Progress describes how far an analysis has got
+type Progress struct {
+	Stage   string
+	Current int
+	Total   int
+	Path    string // File being summarized
 }
 
+func (p Progress) String() string {
+	switch p.Stage {
+	case StageScanning:
+		return fmt.Sprintf("scanning files %d/%d", p.Current, p.Total)
+	case StageSummarizing:
+		return fmt.Sprintf("summarizing %s (%d/%d)", p.Path, p.Current, p.Total)
+	case StageChunks:
+		return fmt.Sprintf("summarizing directories %d/%d", p.Current, p.Total)
+	case StageGenerating:
+		return "generating AGENTS.md"
+	case StageUpdating:
+		return "updating AGENTS.md"
+	}
+	return p.Stage
+}
+
+// ProgressFunc receives progress updates. It is never called concurrently.
+type ProgressFunc func(Progress)
+
+const agentsFile = "AGENTS.md"
+
+// maxKeyFiles limits how many files are summarized for the prompt
+const maxKeyFiles = 20
+
 func NewRepoAnalyzer(provider llm.Provider) *RepoAnalyzer {
 	cwd, _ := os.Getwd()
 	return &RepoAnalyzer{
@@ -33,17 +89,90 @@ func NewRepoAnalyzer(provider llm.Provider) *RepoAnalyzer {
 		files:    []FileInfo{},
 		dirs:     []string{},
 		provider: provider,
+		workers:  min(runtime.NumCPU(), 8),
+
+		tokenBudget: defaultTokenBudget,
+	}
+}
+
+// SetWorkspace includes the workspace's other roots in the analysis. Their
+// files are listed as label:path.
+func (r *RepoAnalyzer) SetWorkspace(ws *workspace.Workspace) {
+	r.extra = nil
+	for _, root := range ws.Roots() {
+		if root.Path != r.rootPath {
+			r.extra = append(r.extra, root)
+		}
 	}
 }
 
 func (r *RepoAnalyzer) Analyze() (string, error) {
-	// Walk through the repository
-	err := filepath.Walk(r.rootPath, func(path string, info os.FileInfo, err error) error {
+	return r.AnalyzeContext(context.Background(), nil)
+}
+
+// AnalyzeContext scans and summarizes the repository with a pool of workers,
+// reporting each stage to progress (which may be nil), and generates the
+// AGENTS.md content. Cancelling ctx stops the analysis.
+func (r *RepoAnalyzer) AnalyzeContext(ctx context.Context, progress ProgressFunc) (string, error) {
+	report := newReporter(progress)
+	if err := r.scan(ctx, report); err != nil {
+		return "", err
+	}
+
+	return r.generateAgentsContentWithLLM(ctx, report)
+}
+
+// scan collects, reads and summarizes the repository's files
+func (r *RepoAnalyzer) scan(ctx context.Context, report ProgressFunc) error {
+	if err := r.collectFiles(ctx); err != nil {
+		return err
+	}
+
+	// Read every file to count its lines and hash its content
+	total := len(r.files)
+	err := r.forEach(ctx, r.workers, total, func(i int) {
+		r.files[i].Lines, r.files[i].Hash = readFile(r.files[i].Path)
+		report(Progress{Stage: StageScanning, Total: total})
+	})
+	if err != nil {
+		return err
+	}
+
+	goFiles := r.goFiles()
+	return r.forEach(ctx, r.workers, len(goFiles), func(i int) {
+		file := &r.files[goFiles[i]]
+		file.Summary = summarizeGoFile(file.Path)
+		report(Progress{Stage: StageSummarizing, Total: len(goFiles), Path: file.RelativePath})
+	})
+}
+
+// collectFiles walks the repository and any other workspace roots and
+// records their directories and source files
+func (r *RepoAnalyzer) collectFiles(ctx context.Context) error {
+	if err := r.walkRoot(ctx, r.rootPath, ""); err != nil {
+		return err
+	}
+	for _, root := range r.extra {
+		if err := r.walkRoot(ctx, root.Path, root.Label+":"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkRoot records the directories and source files under rootPath, with
+// relative paths starting with prefix
+func (r *RepoAnalyzer) walkRoot(ctx context.Context, rootPath, prefix string) error {
+	return filepath.Walk(rootPath, func(path string, info os.FileInfo, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
 		if err != nil {
 			return nil // Skip files that can't be accessed
 		}
 
-		relPath, _ := filepath.Rel(r.rootPath, path)
+		relPath, _ := filepath.Rel(rootPath, path)
+		relPath = prefix + relPath
 
 		// Skip hidden directories and vendor/node_modules
 		if info.IsDir() {
@@ -54,7 +183,7 @@ func (r *RepoAnalyzer) Analyze() (string, error) {
 			if base == "vendor" || base == "node_modules" || base == ".git" {
 				return filepath.SkipDir
 			}
-			if path != r.rootPath {
+			if path != rootPath {
 				r.dirs = append(r.dirs, relPath)
 			}
 			return nil
@@ -65,6 +194,11 @@ func (r *RepoAnalyzer) Analyze() (string, error) {
 			return nil
 		}
 
+		// Skip the generated file itself
+		if prefix == "" && relPath == agentsFile {
+			return nil
+		}
+
 		ext := filepath.Ext(path)
 		// Only include source code files
 		if isSourceFile(ext) {
@@ -79,19 +213,129 @@ func (r *RepoAnalyzer) Analyze() (string, error) {
 
 		return nil
 	})
+}
+
+// goFiles returns the indexes of the Go source files, excluding tests
+func (r *RepoAnalyzer) goFiles() []int {
+	var indexes []int
+	for i, file := range r.files {
+		if file.Extension == ".go" && !file.IsTest {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// keyFiles returns the indexes of the files listed in the prompt
+func (r *RepoAnalyzer) keyFiles() []int {
+	indexes := r.goFiles()
+	if len(indexes) > maxKeyFiles {
+		indexes = indexes[:maxKeyFiles]
+	}
+	return indexes
+}
+
+// forEach runs fn for indexes 0..n-1 on a pool of workers. Each index is
+// handled by exactly one worker, so fn may write to its own slice element.
+func (r *RepoAnalyzer) forEach(ctx context.Context, workers int, n int, fn func(i int)) error {
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range max(workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+			}
+		}()
+	}
+
+	var err error
+feed:
+	for i := range n {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			err = ctx.Err()
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return err
+}
+
+// newReporter serializes progress updates from the workers and numbers them
+func newReporter(progress ProgressFunc) ProgressFunc {
+	var mu sync.Mutex
+	done := map[string]int{}
+	return func(p Progress) {
+		if progress == nil {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if p.Total > 0 {
+			done[p.Stage]++
+			p.Current = done[p.Stage]
+		}
+		progress(p)
+	}
+}
 
+// readFile returns the number of lines in a file and a hash of its content,
+// or zero values if it can't be read
+func readFile(path string) (int, string) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return "", err
+		return 0, ""
 	}
 
-	// Generate the AGENTS.md content using LLM
-	return r.generateAgentsContentWithLLM()
+	sum := sha256.Sum256(data)
+	lines := bytes.Count(data, []byte("\n"))
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		lines++
+	}
+	return lines, hex.EncodeToString(sum[:])
 }
 
-func (r *RepoAnalyzer) generateAgentsContentWithLLM() (string, error) {
+// summarizeGoFile returns the package name and the first sentence of the
+// package or file doc comment
+func summarizeGoFile(path string) string {
+	file, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.PackageClauseOnly|parser.ParseComments)
+	if err != nil {
+		return ""
+	}
+
+	summary := "package " + file.Name.Name
+	if file.Doc != nil {
+		doc := strings.Join(strings.Fields(file.Doc.Text()), " ")
+		if i := strings.Index(doc, ". "); i >= 0 {
+			doc = doc[:i+1]
+		}
+		summary += ": " + doc
+	}
+	return summary
+}
+
+func (r *RepoAnalyzer) generateAgentsContentWithLLM(ctx context.Context, report ProgressFunc) (string, error) {
 	// Collect repository information
 	info := r.collectRepositoryInfo()
 
+	// List every directory if it fits the budget, otherwise summarize the
+	// directories in chunks first and work from the summaries
+	listings := r.directoryListings()
+	if estimateTokens(strings.Join(listings, "\n")) <= r.tokenBudget {
+		info += "\nFiles by Directory:\n" + strings.Join(listings, "\n")
+	} else {
+		summaries, err := r.summarizeChunks(ctx, chunkListings(listings, r.tokenBudget), report)
+		if err != nil {
+			return "", err
+		}
+		info += "\nDirectory Summaries:\n" + strings.Join(summaries, "\n\n")
+	}
+	report(Progress{Stage: StageGenerating})
+
 	// Create prompt for LLM
 	prompt := fmt.Sprintf(`Analyze the following repository structure and generate an AGENTS.md file that provides an AI-friendly overview of the codebase.
 
@@ -110,7 +354,6 @@ Format the output as a proper Markdown file starting with "# AGENTS.md".
 Make sure the content is well-structured, informative, and helps AI agents understand the codebase quickly.`, info)
 
 	// Generate content using LLM
-	ctx := context.Background()
 	response, err := r.provider.Generate(ctx, prompt)
 	if err != nil {
 		return "", fmt.Errorf("failed to generate AGENTS.md content: %w", err)
@@ -130,14 +373,16 @@ func (r *RepoAnalyzer) collectRepositoryInfo() string {
 
 	// Add key Go files only (limit to important ones)
 	sb.WriteString("Key Files:\n")
-	count := 0
-	for _, file := range r.files {
-		if file.Extension == ".go" && !file.IsTest && count < 20 {
+	keyFiles := r.keyFiles()
+	for _, i := range keyFiles {
+		file := r.files[i]
+		if file.Summary != "" {
+			sb.WriteString(fmt.Sprintf("- %s (%s)\n", file.RelativePath, file.Summary))
+		} else {
 			sb.WriteString(fmt.Sprintf("- %s\n", file.RelativePath))
-			count++
 		}
 	}
-	if count == 20 {
+	if len(keyFiles) == maxKeyFiles {
 		sb.WriteString("- ... (more files)\n")
 	}
 	sb.WriteString("\n")
@@ -147,7 +392,7 @@ func (r *RepoAnalyzer) collectRepositoryInfo() string {
 	sb.WriteString("Statistics:\n")
 	sb.WriteString(fmt.Sprintf("- Total Go files: %d\n", stats.GoFiles))
 	sb.WriteString(fmt.Sprintf("- Test files: %d\n", stats.TestFiles))
-	sb.WriteString(fmt.Sprintf("- Estimated lines of code: %d\n", stats.EstimatedLOC))
+	sb.WriteString(fmt.Sprintf("- Lines of Go code: %d\n", stats.Lines))
 
 	return sb.String()
 }
@@ -179,9 +424,9 @@ func (r *RepoAnalyzer) writeSimplifiedTree(sb *strings.Builder) {
 }
 
 type Stats struct {
-	GoFiles      int
-	TestFiles    int
-	EstimatedLOC int
+	GoFiles   int
+	TestFiles int
+	Lines     int
 }
 
 func (r *RepoAnalyzer) calculateStats() Stats {
@@ -192,16 +437,17 @@ func (r *RepoAnalyzer) calculateStats() Stats {
 			if file.IsTest {
 				stats.TestFiles++
 			}
-			// Rough estimate: 40 lines per KB
-			stats.EstimatedLOC += int(file.Size / 25)
+			stats.Lines += file.Lines
 		}
 	}
 	return stats
 }
 
+// WriteAgentsFile writes AGENTS.md, ending it with the metadata that tells
+// which format and version of rigel generated it
 func (r *RepoAnalyzer) WriteAgentsFile(content string) error {
-	filePath := filepath.Join(r.rootPath, "AGENTS.md")
-	return os.WriteFile(filePath, []byte(content), 0644)
+	filePath := filepath.Join(r.rootPath, agentsFile)
+	return os.WriteFile(filePath, []byte(withMetadata(content)), 0644)
 }
 
 func isSourceFile(ext string) bool {
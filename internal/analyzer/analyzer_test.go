@@ -0,0 +1,111 @@
+package analyzer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mizzy/rigel/internal/llm/llmtest"
+	"github.com/mizzy/rigel/internal/workspace"
+)
+
+func writeRepo(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.go":               "package main\n\nfunc main() {}\n",
+		"cmd/tool/tool.go":      "// Package tool runs the tool. It has flags.\npackage tool\n",
+		"cmd/tool/tool_test.go": "package tool\n",
+		"README.md":             "# Repo\n",
+		".hidden/skip.go":       "package skip\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	t.Chdir(dir)
+}
+
+func TestAnalyzeContext(t *testing.T) {
+	writeRepo(t)
+	provider := &llmtest.Provider{Response: "# AGENTS.md"}
+
+	var updates []Progress
+	content, err := NewRepoAnalyzer(provider).AnalyzeContext(context.Background(), func(p Progress) {
+		updates = append(updates, p)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "# AGENTS.md", content)
+
+	assert.Contains(t, provider.Prompt(), "- cmd/tool/tool.go (package tool: Package tool runs the tool.)")
+	assert.Contains(t, provider.Prompt(), "- main.go (package main)")
+	assert.Contains(t, provider.Prompt(), "- Test files: 1")
+	assert.Contains(t, provider.Prompt(), "- Lines of Go code: 6")
+	assert.NotContains(t, provider.Prompt(), "skip.go")
+
+	// Workers finish in any order, but updates are numbered sequentially
+	var stages []string
+	summarized := map[string]bool{}
+	for _, p := range updates {
+		stages = append(stages, p.String())
+		if p.Stage == StageSummarizing {
+			summarized[p.Path] = true
+		}
+	}
+	assert.Contains(t, stages, "scanning files 4/4")
+	assert.Equal(t, map[string]bool{"main.go": true, "cmd/tool/tool.go": true}, summarized)
+	assert.Equal(t, "generating AGENTS.md", stages[len(stages)-1])
+}
+
+func TestAnalyzeContext_Workspace(t *testing.T) {
+	writeRepo(t)
+	lib := filepath.Join(t.TempDir(), "lib")
+	require.NoError(t, os.MkdirAll(filepath.Join(lib, "greet"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(lib, "greet", "greet.go"), []byte("package greet\n"), 0644))
+
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+	ws, err := workspace.New(cwd)
+	require.NoError(t, err)
+	_, err = ws.Add(lib, true)
+	require.NoError(t, err)
+
+	provider := &llmtest.Provider{Response: "# AGENTS.md"}
+	repoAnalyzer := NewRepoAnalyzer(provider)
+	repoAnalyzer.SetWorkspace(ws)
+	_, err = repoAnalyzer.AnalyzeContext(context.Background(), nil)
+	require.NoError(t, err)
+
+	assert.Contains(t, provider.Prompt(), "- lib:greet/greet.go (package greet)")
+	assert.Contains(t, provider.Prompt(), "- main.go (package main)")
+}
+
+func TestAnalyzeContext_Cancelled(t *testing.T) {
+	writeRepo(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := NewRepoAnalyzer(&llmtest.Provider{Response: "# AGENTS.md"}).AnalyzeContext(ctx, nil)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestProgressString(t *testing.T) {
+	tests := []struct {
+		progress Progress
+		want     string
+	}{
+		{Progress{Stage: StageScanning, Current: 120, Total: 500}, "scanning files 120/500"},
+		{Progress{Stage: StageSummarizing, Current: 1, Total: 3, Path: "cmd/rigel/main.go"}, "summarizing cmd/rigel/main.go (1/3)"},
+		{Progress{Stage: StageGenerating}, "generating AGENTS.md"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.progress.String())
+	}
+}
@@ -0,0 +1,141 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// defaultTokenBudget is the approximate number of tokens of file
+	// listings sent in one prompt. Larger repositories are summarized in
+	// chunks of this size.
+	defaultTokenBudget = 8000
+
+	// maxConcurrentSummaries limits parallel LLM calls while summarizing chunks
+	maxConcurrentSummaries = 4
+)
+
+// estimateTokens approximates the number of tokens in s (~4 characters per token)
+func estimateTokens(s string) int {
+	return len(s) / 4
+}
+
+// fileDir returns the directory of a relative path, keeping the label of
+// paths in other workspace roots (label:path)
+func fileDir(relPath string) string {
+	label, rest, found := strings.Cut(relPath, ":")
+	if !found || strings.ContainsRune(label, filepath.Separator) {
+		return filepath.ToSlash(filepath.Dir(relPath))
+	}
+	return label + ":" + filepath.ToSlash(filepath.Dir(rest))
+}
+
+// directoryListings describes the files of each directory, one listing per
+// directory in path order
+func (r *RepoAnalyzer) directoryListings() []string {
+	byDir := make(map[string][]FileInfo)
+	for _, file := range r.files {
+		dir := fileDir(file.RelativePath)
+		byDir[dir] = append(byDir[dir], file)
+	}
+
+	dirs := make([]string, 0, len(byDir))
+	for dir := range byDir {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	listings := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		files := byDir[dir]
+		lines := 0
+		for _, file := range files {
+			lines += file.Lines
+		}
+
+		var sb strings.Builder
+		sb.WriteString(fmt.Sprintf("%s/ (%d files, %d lines)\n", dir, len(files), lines))
+		for _, file := range files {
+			sb.WriteString(fmt.Sprintf("- %s: %d lines", filepath.Base(file.RelativePath), file.Lines))
+			if file.Summary != "" {
+				sb.WriteString(", " + file.Summary)
+			}
+			sb.WriteString("\n")
+		}
+		listings = append(listings, sb.String())
+	}
+	return listings
+}
+
+// chunkListings packs directory listings into chunks of roughly budget
+// tokens. A listing larger than the budget is split across chunks by line,
+// repeating its header line.
+func chunkListings(listings []string, budget int) []string {
+	var chunks []string
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			chunks = append(chunks, current.String())
+			current.Reset()
+		}
+	}
+	add := func(text string) {
+		if current.Len() > 0 && estimateTokens(current.String()+text) > budget {
+			flush()
+		}
+		current.WriteString(text)
+	}
+
+	for _, listing := range listings {
+		if estimateTokens(listing) <= budget {
+			add(listing + "\n")
+			continue
+		}
+
+		lines := strings.Split(strings.TrimSuffix(listing, "\n"), "\n")
+		header := lines[0] + " (continued)\n"
+		flush()
+		current.WriteString(lines[0] + "\n")
+		for _, line := range lines[1:] {
+			if estimateTokens(current.String()+line) > budget {
+				flush()
+				current.WriteString(header)
+			}
+			current.WriteString(line + "\n")
+		}
+		flush()
+	}
+	flush()
+	return chunks
+}
+
+// summarizeChunks asks the LLM to summarize each chunk of directory listings
+// in parallel and returns the summaries in chunk order
+func (r *RepoAnalyzer) summarizeChunks(ctx context.Context, chunks []string, report ProgressFunc) ([]string, error) {
+	summaries := make([]string, len(chunks))
+	errs := make([]error, len(chunks))
+
+	err := r.forEach(ctx, maxConcurrentSummaries, len(chunks), func(i int) {
+		prompt := fmt.Sprintf(`The following lists part of a repository's files, grouped by directory, with line counts and Go package documentation.
+
+%s
+For each directory, describe in a few sentences its purpose, its main components and its most important files.
+Be concise: your summary will be combined with summaries of the rest of the repository to write an AGENTS.md file.`, chunks[i])
+
+		summaries[i], errs[i] = r.provider.Generate(ctx, prompt)
+		report(Progress{Stage: StageChunks, Total: len(chunks)})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("failed to summarize repository: %w", err)
+		}
+	}
+	return summaries, nil
+}
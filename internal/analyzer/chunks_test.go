@@ -0,0 +1,75 @@
+package analyzer
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mizzy/rigel/internal/llm/llmtest"
+)
+
+func TestChunkListings(t *testing.T) {
+	small := "a/ (1 files, 1 lines)\n- a.go: 1 lines\n"
+	large := "big/ (3 files, 3 lines)\n" + strings.Repeat("- file_with_a_long_name.go: 1 lines\n", 3)
+
+	tests := []struct {
+		name     string
+		listings []string
+		budget   int
+		want     []string
+	}{
+		{
+			name:     "fits in one chunk",
+			listings: []string{small, small},
+			budget:   1000,
+			want:     []string{small + "\n" + small + "\n"},
+		},
+		{
+			name:     "one listing per chunk",
+			listings: []string{small, small},
+			budget:   10,
+			want:     []string{small + "\n", small + "\n"},
+		},
+		{
+			name:     "large listing is split",
+			listings: []string{large},
+			budget:   16,
+			want: []string{
+				"big/ (3 files, 3 lines)\n- file_with_a_long_name.go: 1 lines\n",
+				"big/ (3 files, 3 lines) (continued)\n- file_with_a_long_name.go: 1 lines\n",
+				"big/ (3 files, 3 lines) (continued)\n- file_with_a_long_name.go: 1 lines\n",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, chunkListings(tt.listings, tt.budget))
+		})
+	}
+}
+
+func TestAnalyzeContext_Chunked(t *testing.T) {
+	writeRepo(t)
+	provider := &llmtest.Provider{Response: "# AGENTS.md"}
+
+	repoAnalyzer := NewRepoAnalyzer(provider)
+	repoAnalyzer.tokenBudget = 10
+
+	var chunks int
+	_, err := repoAnalyzer.AnalyzeContext(context.Background(), func(p Progress) {
+		if p.Stage == StageChunks {
+			chunks = p.Total
+		}
+	})
+	require.NoError(t, err)
+
+	// One call per directory chunk, then one to synthesize AGENTS.md
+	assert.Greater(t, chunks, 1)
+	assert.Equal(t, chunks+1, provider.Requests())
+	assert.Contains(t, provider.Prompt(), "Directory Summaries:\n# AGENTS.md\n\n# AGENTS.md")
+	assert.NotContains(t, provider.Prompt(), "Files by Directory:")
+}
@@ -0,0 +1,136 @@
+package analyzer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mizzy/rigel/internal/version"
+)
+
+// FormatVersion is the version of the AGENTS.md layout rigel generates. It
+// is bumped when the layout changes, so that files generated before are
+// reported as stale.
+const FormatVersion = 1
+
+// metadataPattern matches the comment WriteAgentsFile ends AGENTS.md with
+var metadataPattern = regexp.MustCompile(`\n*<!-- rigel:agents (.*?) -->\s*$`)
+
+// Metadata records how AGENTS.md was generated
+type Metadata struct {
+	FormatVersion   int
+	AnalyzerVersion string // Version of rigel that generated the file
+	GeneratedAt     time.Time
+	Checksum        string // SHA-256 of the content, to tell hand edits
+}
+
+func (m Metadata) String() string {
+	return fmt.Sprintf("<!-- rigel:agents format=%d analyzer=%s generated=%s sha256=%s -->",
+		m.FormatVersion, m.AnalyzerVersion, m.GeneratedAt.UTC().Format(time.RFC3339), m.Checksum)
+}
+
+// ParseMetadata splits AGENTS.md into its content and the metadata rigel
+// recorded in it. The metadata is nil if the file has none, as when it was
+// written by hand or by rigel before the format was versioned.
+func ParseMetadata(data string) (string, *Metadata) {
+	match := metadataPattern.FindStringSubmatchIndex(data)
+	if match == nil {
+		return data, nil
+	}
+	content := data[:match[0]]
+
+	var m Metadata
+	for _, field := range strings.Fields(data[match[2]:match[3]]) {
+		key, value, _ := strings.Cut(field, "=")
+		switch key {
+		case "format":
+			m.FormatVersion, _ = strconv.Atoi(value)
+		case "analyzer":
+			m.AnalyzerVersion = value
+		case "generated":
+			m.GeneratedAt, _ = time.Parse(time.RFC3339, value)
+		case "sha256":
+			m.Checksum = value
+		}
+	}
+	return content, &m
+}
+
+// StripMetadata returns AGENTS.md without the metadata comment
+func StripMetadata(data string) string {
+	content, _ := ParseMetadata(data)
+	return content
+}
+
+func checksum(content string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(content)))
+	return hex.EncodeToString(sum[:])
+}
+
+// withMetadata appends the metadata of content generated now
+func withMetadata(content string) string {
+	content = strings.TrimRight(StripMetadata(content), "\n")
+	m := Metadata{
+		FormatVersion:   FormatVersion,
+		AnalyzerVersion: version.Version,
+		GeneratedAt:     time.Now(),
+		Checksum:        checksum(content),
+	}
+	return content + "\n\n" + m.String() + "\n"
+}
+
+// AgentsFileStatus describes the AGENTS.md of a repository
+type AgentsFileStatus struct {
+	Exists   bool
+	Metadata *Metadata // Nil if the file wasn't generated by this version of the format
+
+	// Stale is set when the file was generated with an older format, or
+	// before formats were versioned, and Edited when its content changed
+	// since it was generated
+	Stale  bool
+	Edited bool
+}
+
+// CheckAgentsFile reads the AGENTS.md of the repository at rootPath and
+// tells whether it should be refreshed. A file without metadata is only
+// stale if an analysis snapshot shows rigel generated it; otherwise it was
+// written by hand and is left alone.
+func CheckAgentsFile(rootPath string) AgentsFileStatus {
+	data, err := os.ReadFile(filepath.Join(rootPath, agentsFile))
+	if err != nil {
+		return AgentsFileStatus{}
+	}
+	status := AgentsFileStatus{Exists: true}
+
+	content, m := ParseMetadata(string(data))
+	if m == nil {
+		_, err := LoadSnapshot(rootPath)
+		status.Stale = err == nil
+		return status
+	}
+	status.Metadata = m
+	status.Stale = m.FormatVersion < FormatVersion
+	status.Edited = m.Checksum != checksum(content)
+	return status
+}
+
+// Warning returns a message asking to refresh a stale or edited AGENTS.md,
+// or an empty string if it needs nothing
+func (s AgentsFileStatus) Warning() string {
+	switch {
+	case s.Stale && s.Metadata == nil:
+		return "AGENTS.md was generated by an older version of rigel. Run /init --force to regenerate it."
+	case s.Stale:
+		return fmt.Sprintf("AGENTS.md was generated in format %d by rigel %s; the current format is %d. Run /init --force to regenerate it.",
+			s.Metadata.FormatVersion, s.Metadata.AnalyzerVersion, FormatVersion)
+	case s.Edited:
+		return "AGENTS.md was edited since rigel generated it. Run /init to refresh the analysis; your edits are kept."
+	}
+	return ""
+}
@@ -0,0 +1,73 @@
+package analyzer
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mizzy/rigel/internal/llm/llmtest"
+	"github.com/mizzy/rigel/internal/version"
+)
+
+func TestParseMetadata(t *testing.T) {
+	data := withMetadata("# AGENTS.md\n\nContext.\n")
+	assert.True(t, strings.HasPrefix(data, "# AGENTS.md\n\nContext.\n\n<!-- rigel:agents format=1 analyzer="+version.Version+" generated="))
+	assert.True(t, strings.HasSuffix(data, " -->\n"))
+
+	content, m := ParseMetadata(data)
+	assert.Equal(t, "# AGENTS.md\n\nContext.", content)
+	require.NotNil(t, m)
+	assert.Equal(t, FormatVersion, m.FormatVersion)
+	assert.Equal(t, version.Version, m.AnalyzerVersion)
+	assert.WithinDuration(t, time.Now(), m.GeneratedAt, time.Minute)
+	assert.Equal(t, checksum(content), m.Checksum)
+
+	assert.Equal(t, 1, strings.Count(withMetadata(data), "<!-- rigel:agents"), "the metadata is replaced, not repeated")
+
+	content, m = ParseMetadata("# AGENTS.md\nWritten by hand\n")
+	assert.Equal(t, "# AGENTS.md\nWritten by hand\n", content)
+	assert.Nil(t, m)
+}
+
+func TestCheckAgentsFile(t *testing.T) {
+	writeRepo(t)
+	repoAnalyzer := NewRepoAnalyzer(&llmtest.Provider{Response: "# AGENTS.md"})
+
+	assert.Equal(t, AgentsFileStatus{}, CheckAgentsFile("."))
+
+	// Written by hand: not rigel's to refresh
+	require.NoError(t, os.WriteFile("AGENTS.md", []byte("# AGENTS.md\n"), 0644))
+	status := CheckAgentsFile(".")
+	assert.True(t, status.Exists)
+	assert.False(t, status.Stale)
+	assert.Empty(t, status.Warning())
+
+	// Generated before the format was versioned
+	require.NoError(t, repoAnalyzer.SaveSnapshot())
+	status = CheckAgentsFile(".")
+	assert.True(t, status.Stale)
+	assert.Equal(t, "AGENTS.md was generated by an older version of rigel. Run /init --force to regenerate it.", status.Warning())
+
+	require.NoError(t, repoAnalyzer.WriteAgentsFile("# AGENTS.md\n\nContext.\n"))
+	status = CheckAgentsFile(".")
+	require.NotNil(t, status.Metadata)
+	assert.False(t, status.Stale)
+	assert.False(t, status.Edited)
+	assert.Empty(t, status.Warning())
+
+	data, err := os.ReadFile("AGENTS.md")
+	require.NoError(t, err)
+	edited := strings.Replace(string(data), "Context.", "Context, edited.", 1)
+	require.NoError(t, os.WriteFile("AGENTS.md", []byte(edited), 0644))
+	status = CheckAgentsFile(".")
+	assert.True(t, status.Edited)
+	assert.Equal(t, "AGENTS.md was edited since rigel generated it. Run /init to refresh the analysis; your edits are kept.", status.Warning())
+
+	old := strings.Replace(edited, "format=1", "format=0", 1)
+	require.NoError(t, os.WriteFile("AGENTS.md", []byte(old), 0644))
+	assert.Equal(t, "AGENTS.md was generated in format 0 by rigel "+version.Version+"; the current format is 1. Run /init --force to regenerate it.", CheckAgentsFile(".").Warning())
+}
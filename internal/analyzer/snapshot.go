@@ -0,0 +1,197 @@
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SnapshotPath is where the analysis snapshot is stored, relative to the
+// repository root
+var SnapshotPath = filepath.Join(".rigel", "analysis.json")
+
+// Snapshot records the repository structure AGENTS.md was generated from, so
+// later runs can tell what changed
+type Snapshot struct {
+	GeneratedAt time.Time               `json:"generated_at"`
+	Dirs        []string                `json:"dirs"`
+	Files       map[string]FileSnapshot `json:"files"`
+}
+
+// FileSnapshot is the recorded state of a single file
+type FileSnapshot struct {
+	Size  int64  `json:"size"`
+	Lines int    `json:"lines"`
+	Hash  string `json:"hash"`
+}
+
+// Changes lists the differences between two snapshots
+type Changes struct {
+	Added       []string
+	Removed     []string
+	Modified    []string
+	AddedDirs   []string
+	RemovedDirs []string
+}
+
+// Empty reports whether nothing changed
+func (c Changes) Empty() bool {
+	return len(c.Added)+len(c.Removed)+len(c.Modified)+len(c.AddedDirs)+len(c.RemovedDirs) == 0
+}
+
+// Files returns the number of added, removed and modified files
+func (c Changes) Files() int {
+	return len(c.Added) + len(c.Removed) + len(c.Modified)
+}
+
+func (c Changes) String() string {
+	var sb strings.Builder
+	write := func(title string, paths []string) {
+		if len(paths) == 0 {
+			return
+		}
+		sb.WriteString(title + ":\n")
+		for _, path := range paths {
+			sb.WriteString(fmt.Sprintf("- %s\n", path))
+		}
+	}
+	write("Added directories", c.AddedDirs)
+	write("Removed directories", c.RemovedDirs)
+	write("Added files", c.Added)
+	write("Removed files", c.Removed)
+	write("Modified files", c.Modified)
+	return sb.String()
+}
+
+// Diff returns what changed from the previous snapshot to s
+func (s *Snapshot) Diff(previous *Snapshot) Changes {
+	var changes Changes
+	for path, file := range s.Files {
+		old, ok := previous.Files[path]
+		switch {
+		case !ok:
+			changes.Added = append(changes.Added, path)
+		case old.Hash != file.Hash:
+			changes.Modified = append(changes.Modified, path)
+		}
+	}
+	for path := range previous.Files {
+		if _, ok := s.Files[path]; !ok {
+			changes.Removed = append(changes.Removed, path)
+		}
+	}
+	changes.AddedDirs = missing(s.Dirs, previous.Dirs)
+	changes.RemovedDirs = missing(previous.Dirs, s.Dirs)
+
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Removed)
+	sort.Strings(changes.Modified)
+	return changes
+}
+
+// missing returns the entries of a that are not in b
+func missing(a, b []string) []string {
+	seen := make(map[string]bool, len(b))
+	for _, s := range b {
+		seen[s] = true
+	}
+	var out []string
+	for _, s := range a {
+		if !seen[s] {
+			out = append(out, s)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// LoadSnapshot reads the snapshot of the repository at rootPath. It returns
+// an error satisfying os.IsNotExist if the repository has no snapshot.
+func LoadSnapshot(rootPath string) (*Snapshot, error) {
+	data, err := os.ReadFile(filepath.Join(rootPath, SnapshotPath))
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", SnapshotPath, err)
+	}
+	return &snapshot, nil
+}
+
+// Snapshot returns the state of the scanned repository
+func (r *RepoAnalyzer) Snapshot() *Snapshot {
+	snapshot := &Snapshot{
+		GeneratedAt: time.Now(),
+		Dirs:        make([]string, len(r.dirs)),
+		Files:       make(map[string]FileSnapshot, len(r.files)),
+	}
+	for i, dir := range r.dirs {
+		snapshot.Dirs[i] = filepath.ToSlash(dir)
+	}
+	for _, file := range r.files {
+		snapshot.Files[filepath.ToSlash(file.RelativePath)] = FileSnapshot{
+			Size:  file.Size,
+			Lines: file.Lines,
+			Hash:  file.Hash,
+		}
+	}
+	return snapshot
+}
+
+// SaveSnapshot records the scanned repository in .rigel/analysis.json
+func (r *RepoAnalyzer) SaveSnapshot() error {
+	data, err := json.MarshalIndent(r.Snapshot(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(r.rootPath, SnapshotPath)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create .rigel directory: %w", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// UpdateContext rescans the repository, compares it with the previous
+// snapshot and asks the LLM to revise only the sections of the existing
+// AGENTS.md content affected by the changes. If nothing changed, the
+// existing content is returned without calling the LLM.
+func (r *RepoAnalyzer) UpdateContext(ctx context.Context, existing string, previous *Snapshot, progress ProgressFunc) (string, Changes, error) {
+	report := newReporter(progress)
+	if err := r.scan(ctx, report); err != nil {
+		return "", Changes{}, err
+	}
+
+	changes := r.Snapshot().Diff(previous)
+	if changes.Empty() {
+		return existing, changes, nil
+	}
+
+	report(Progress{Stage: StageUpdating})
+
+	prompt := fmt.Sprintf(`The repository described by the AGENTS.md file below has changed since the file was generated.
+
+Current AGENTS.md:
+%s
+
+Changes since it was generated:
+%s
+Current repository information:
+%s
+
+Revise only the sections of AGENTS.md affected by these changes and keep everything else exactly as it is.
+Output the complete updated file starting with "# AGENTS.md".`, existing, changes, r.collectRepositoryInfo())
+
+	response, err := r.provider.Generate(ctx, prompt)
+	if err != nil {
+		return "", changes, fmt.Errorf("failed to update AGENTS.md content: %w", err)
+	}
+	return response, changes, nil
+}
@@ -0,0 +1,82 @@
+package analyzer
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mizzy/rigel/internal/llm/llmtest"
+)
+
+func TestSnapshotDiff(t *testing.T) {
+	previous := &Snapshot{
+		Dirs: []string{"cmd", "old"},
+		Files: map[string]FileSnapshot{
+			"main.go":    {Hash: "a"},
+			"cmd/run.go": {Hash: "b"},
+			"old/x.go":   {Hash: "c"},
+		},
+	}
+	current := &Snapshot{
+		Dirs: []string{"cmd", "pkg"},
+		Files: map[string]FileSnapshot{
+			"main.go":    {Hash: "a"},
+			"cmd/run.go": {Hash: "changed"},
+			"pkg/y.go":   {Hash: "d"},
+		},
+	}
+
+	changes := current.Diff(previous)
+
+	assert.Equal(t, Changes{
+		Added:       []string{"pkg/y.go"},
+		Removed:     []string{"old/x.go"},
+		Modified:    []string{"cmd/run.go"},
+		AddedDirs:   []string{"pkg"},
+		RemovedDirs: []string{"old"},
+	}, changes)
+	assert.False(t, changes.Empty())
+	assert.Equal(t, 3, changes.Files())
+	assert.True(t, current.Diff(current).Empty())
+}
+
+func TestUpdateContext(t *testing.T) {
+	writeRepo(t)
+	provider := &llmtest.Provider{Response: "# AGENTS.md"}
+
+	repoAnalyzer := NewRepoAnalyzer(provider)
+	_, err := repoAnalyzer.AnalyzeContext(context.Background(), nil)
+	require.NoError(t, err)
+	require.NoError(t, repoAnalyzer.WriteAgentsFile("# AGENTS.md\nold"))
+	require.NoError(t, repoAnalyzer.SaveSnapshot())
+
+	previous, err := LoadSnapshot(".")
+	require.NoError(t, err)
+
+	// Nothing changed: no LLM call and the content is kept
+	requests := provider.Requests()
+	content, changes, err := NewRepoAnalyzer(provider).UpdateContext(context.Background(), "# AGENTS.md\nold", previous, nil)
+	require.NoError(t, err)
+	assert.True(t, changes.Empty())
+	assert.Equal(t, "# AGENTS.md\nold", content)
+	assert.Equal(t, requests, provider.Requests())
+
+	require.NoError(t, os.WriteFile("main.go", []byte("package main\n\nfunc main() { run() }\n"), 0644))
+	require.NoError(t, os.WriteFile("extra.go", []byte("package main\n"), 0644))
+
+	content, changes, err = NewRepoAnalyzer(provider).UpdateContext(context.Background(), "# AGENTS.md\nold", previous, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "# AGENTS.md", content)
+	assert.Equal(t, []string{"extra.go"}, changes.Added)
+	assert.Equal(t, []string{"main.go"}, changes.Modified)
+	assert.Contains(t, provider.Prompt(), "# AGENTS.md\nold")
+	assert.Contains(t, provider.Prompt(), "Modified files:\n- main.go")
+}
+
+func TestLoadSnapshot_Missing(t *testing.T) {
+	_, err := LoadSnapshot(t.TempDir())
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,323 @@
+// Package batch runs a YAML file of prompts, tools and shell commands
+// non-interactively, for `rigel run`, and reports how each step went.
+package batch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/mizzy/rigel/internal/tools"
+)
+
+// Conditions a step can be run on, besides <id>.success and <id>.failure
+const (
+	IfSuccess = "success" // No step has failed so far; the default
+	IfFailure = "failure" // A step has failed
+	IfAlways  = "always"
+)
+
+// Statuses of a step
+const (
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusSkipped   = "skipped"
+)
+
+// File is a batch file: variables and the steps to run in order
+type File struct {
+	Name  string            `yaml:"name"`
+	Vars  map[string]string `yaml:"vars"`
+	Steps []Step            `yaml:"steps"`
+}
+
+// Step is one thing to do: a prompt for the agent, a tool to call or a
+// shell command to run. Prompt, input and run may use {{variables}} and the
+// {{steps.<id>.output}} of earlier steps.
+type Step struct {
+	ID     string `yaml:"id"`   // Name later steps refer to it by
+	Name   string `yaml:"name"` // Shown in the progress and report
+	Prompt string `yaml:"prompt"`
+	Tool   string `yaml:"tool"`
+	Input  string `yaml:"input"` // Input of the tool
+	Run    string `yaml:"run"`
+	If     string `yaml:"if"`
+
+	// A failed step with ContinueOnError doesn't fail the run or skip the
+	// next steps
+	ContinueOnError bool `yaml:"continue_on_error"`
+}
+
+// stepID matches the IDs of steps, which are used in variables
+var stepID = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// Load reads and checks a batch file
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse parses and checks the YAML of a batch file
+func Parse(data []byte) (*File, error) {
+	var file File
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid batch file: %w", err)
+	}
+	if len(file.Steps) == 0 {
+		return nil, fmt.Errorf("invalid batch file: no steps")
+	}
+
+	ids := map[string]bool{}
+	for i, step := range file.Steps {
+		where := fmt.Sprintf("step %d", i+1)
+		if step.ID != "" {
+			where = fmt.Sprintf("step %d (%s)", i+1, step.ID)
+		}
+
+		kinds := 0
+		for _, set := range []bool{step.Prompt != "", step.Tool != "", step.Run != ""} {
+			if set {
+				kinds++
+			}
+		}
+		if kinds != 1 {
+			return nil, fmt.Errorf("%s: needs exactly one of prompt, tool and run", where)
+		}
+		if step.Input != "" && step.Tool == "" {
+			return nil, fmt.Errorf("%s: input is only for tool steps", where)
+		}
+
+		switch {
+		case step.If == "", step.If == IfSuccess, step.If == IfFailure, step.If == IfAlways:
+		default:
+			id, outcome, _ := strings.Cut(step.If, ".")
+			if outcome != "success" && outcome != "failure" {
+				return nil, fmt.Errorf("%s: invalid if %q: must be success, failure, always, <id>.success or <id>.failure", where, step.If)
+			}
+			if !ids[id] {
+				return nil, fmt.Errorf("%s: if refers to %q, which isn't the id of an earlier step", where, id)
+			}
+		}
+
+		if step.ID != "" {
+			if !stepID.MatchString(step.ID) {
+				return nil, fmt.Errorf("%s: invalid id: use letters, digits, _ and -", where)
+			}
+			if ids[step.ID] {
+				return nil, fmt.Errorf("%s: duplicate id", where)
+			}
+			ids[step.ID] = true
+		}
+	}
+	return &file, nil
+}
+
+// Agent answers prompts, as agent.Agent does
+type Agent interface {
+	Execute(ctx context.Context, prompt string) (string, error)
+}
+
+// Runner runs the steps of batch files
+type Runner struct {
+	Agent    Agent
+	Tools    []tools.Tool
+	Dir      string    // Directory shell commands run in
+	DryRun   bool      // Describe shell commands instead of running them
+	Progress io.Writer // Where to report each step as it starts and ends; may be nil
+}
+
+// StepResult is how a step went
+type StepResult struct {
+	ID              string
+	Name            string
+	Status          string
+	Output          string
+	Error           string
+	Duration        time.Duration
+	ContinueOnError bool
+}
+
+// Run runs the steps of a batch file in order. vars override the file's
+// variables.
+func (r *Runner) Run(ctx context.Context, file *File, vars map[string]string) *Report {
+	values := map[string]string{}
+	for name, value := range file.Vars {
+		values[name] = value
+	}
+	for name, value := range vars {
+		values[name] = value
+	}
+
+	report := &Report{Name: file.Name, Started: time.Now()}
+	statuses := map[string]string{} // By step ID
+	failed := false
+	for i, step := range file.Steps {
+		result := StepResult{ID: step.ID, Name: stepName(step), ContinueOnError: step.ContinueOnError}
+		r.progress("[%d/%d] %s", i+1, len(file.Steps), result.Name)
+
+		switch {
+		case ctx.Err() != nil:
+			result.Status = StatusSkipped
+			result.Error = "cancelled"
+		case !shouldRun(step.If, failed, statuses):
+			result.Status = StatusSkipped
+		default:
+			start := time.Now()
+			output, err := r.runStep(ctx, step, values)
+			result.Duration = time.Since(start).Round(time.Millisecond)
+			result.Output = strings.TrimRight(output, "\n")
+			result.Status = StatusSucceeded
+			if err != nil {
+				result.Status = StatusFailed
+				result.Error = err.Error()
+				failed = failed || !step.ContinueOnError
+			}
+		}
+
+		switch result.Status {
+		case StatusSucceeded:
+			r.progress("      ✓ %s", result.Duration)
+		case StatusFailed:
+			r.progress("      ✗ %s: %s", result.Duration, firstLine(result.Error))
+		default:
+			r.progress("      - skipped")
+		}
+
+		report.Steps = append(report.Steps, result)
+		if step.ID != "" {
+			statuses[step.ID] = result.Status
+			values["steps."+step.ID+".output"] = result.Output
+			values["steps."+step.ID+".status"] = result.Status
+		}
+	}
+	report.Duration = time.Since(report.Started).Round(time.Millisecond)
+	return report
+}
+
+// shouldRun reports whether a step with the given condition runs, given
+// whether a step has failed so far and the statuses of the steps with IDs
+func shouldRun(condition string, failed bool, statuses map[string]string) bool {
+	switch condition {
+	case "", IfSuccess:
+		return !failed
+	case IfFailure:
+		return failed
+	case IfAlways:
+		return true
+	}
+	id, outcome, _ := strings.Cut(condition, ".")
+	if outcome == "failure" {
+		return statuses[id] == StatusFailed
+	}
+	return statuses[id] == StatusSucceeded
+}
+
+// runStep runs a prompt, tool or command, returning its output
+func (r *Runner) runStep(ctx context.Context, step Step, values map[string]string) (string, error) {
+	switch {
+	case step.Prompt != "":
+		prompt, err := expand(step.Prompt, values)
+		if err != nil {
+			return "", err
+		}
+		if r.Agent == nil {
+			return "", fmt.Errorf("no agent to send the prompt to")
+		}
+		return r.Agent.Execute(ctx, prompt)
+
+	case step.Tool != "":
+		input, err := expand(step.Input, values)
+		if err != nil {
+			return "", err
+		}
+		for _, tool := range r.Tools {
+			if tool.Name() == step.Tool {
+				return tool.Execute(ctx, input)
+			}
+		}
+		names := make([]string, len(r.Tools))
+		for i, tool := range r.Tools {
+			names[i] = tool.Name()
+		}
+		return "", fmt.Errorf("unknown tool %q: must be one of %s", step.Tool, strings.Join(names, ", "))
+
+	default:
+		command, err := expand(step.Run, values)
+		if err != nil {
+			return "", err
+		}
+		if r.DryRun {
+			return "[dry run] would run: " + command, nil
+		}
+		cmd := exec.CommandContext(ctx, "sh", "-c", command)
+		cmd.Dir = r.Dir
+		var output bytes.Buffer
+		cmd.Stdout = &output
+		cmd.Stderr = &output
+		if err := cmd.Run(); err != nil {
+			return output.String(), fmt.Errorf("%s: %w", command, err)
+		}
+		return output.String(), nil
+	}
+}
+
+// variable matches a {{name}} in a prompt, input or command
+var variable = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.-]+)\s*\}\}`)
+
+// expand replaces the variables in s with their values
+func expand(s string, values map[string]string) (string, error) {
+	var missing []string
+	expanded := variable.ReplaceAllStringFunc(s, func(match string) string {
+		name := variable.FindStringSubmatch(match)[1]
+		value, ok := values[name]
+		if !ok {
+			missing = append(missing, name)
+		}
+		return value
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("undefined variable %s", strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
+
+// stepName returns the name of a step, or describes it if it has none
+func stepName(step Step) string {
+	switch {
+	case step.Name != "":
+		return step.Name
+	case step.ID != "":
+		return step.ID
+	case step.Tool != "":
+		return "tool " + step.Tool
+	case step.Run != "":
+		return "run " + firstLine(step.Run)
+	default:
+		return "prompt " + firstLine(step.Prompt)
+	}
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return line
+}
+
+func (r *Runner) progress(format string, args ...any) {
+	if r.Progress != nil {
+		fmt.Fprintf(r.Progress, format+"\n", args...)
+	}
+}
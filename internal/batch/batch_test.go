@@ -0,0 +1,182 @@
+package batch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mizzy/rigel/internal/tools"
+)
+
+// fakeAgent answers every prompt by repeating it, and fails on "fail"
+type fakeAgent struct {
+	prompts []string
+}
+
+func (a *fakeAgent) Execute(ctx context.Context, prompt string) (string, error) {
+	a.prompts = append(a.prompts, prompt)
+	if prompt == "fail" {
+		return "", errors.New("model unavailable")
+	}
+	return "answer to " + prompt, nil
+}
+
+// upperTool upper-cases its input
+type upperTool struct{}
+
+func (upperTool) Name() string        { return "upper" }
+func (upperTool) Description() string { return "Upper-cases its input" }
+func (upperTool) Execute(ctx context.Context, input string) (string, error) {
+	return strings.ToUpper(input), nil
+}
+
+func TestParse(t *testing.T) {
+	file, err := Parse([]byte(`
+name: Maintenance
+vars:
+  pkg: ./...
+steps:
+  - id: tests
+    run: go test {{pkg}}
+    continue_on_error: true
+  - prompt: Summarize {{steps.tests.output}}
+    if: tests.failure
+`))
+	require.NoError(t, err)
+	assert.Equal(t, "Maintenance", file.Name)
+	assert.Equal(t, map[string]string{"pkg": "./..."}, file.Vars)
+	require.Len(t, file.Steps, 2)
+	assert.True(t, file.Steps[0].ContinueOnError)
+	assert.Equal(t, "tests.failure", file.Steps[1].If)
+
+	for yaml, message := range map[string]string{
+		`name: x`:                                              "no steps",
+		"steps:\n  - prompt: a\n    run: b":                    "step 1: needs exactly one of prompt, tool and run",
+		"steps:\n  - prompt: a\n    input: b":                  "step 1: input is only for tool steps",
+		"steps:\n  - prompt: a\n    if: sometimes":             `step 1: invalid if "sometimes"`,
+		"steps:\n  - prompt: a\n    if: tests.failure":         `step 1: if refers to "tests"`,
+		"steps:\n  - id: a\n    run: x\n  - id: a\n    run: y": "step 2 (a): duplicate id",
+		"steps:\n  - id: a b\n    run: x":                      "step 1 (a b): invalid id",
+		"steps:\n  - promt: a":                                 "field promt not found",
+	} {
+		_, err := Parse([]byte(yaml))
+		assert.ErrorContains(t, err, message, yaml)
+	}
+}
+
+func TestRun(t *testing.T) {
+	file, err := Parse([]byte(`
+name: Maintenance
+vars:
+  greeting: hello
+steps:
+  - id: echo
+    name: Say hello
+    run: echo {{greeting}}
+  - id: shout
+    tool: upper
+    input: "{{steps.echo.output}}"
+  - id: broken
+    run: echo oops; exit 3
+    continue_on_error: true
+  - prompt: Why did it fail? {{steps.broken.output}}
+    if: broken.failure
+  - prompt: Only when echo failed
+    if: echo.failure
+  - prompt: fail
+  - prompt: After the failure
+  - prompt: Clean up
+    if: always
+  - prompt: Report the failure
+    if: failure
+`))
+	require.NoError(t, err)
+
+	agent := &fakeAgent{}
+	var progress bytes.Buffer
+	runner := &Runner{Agent: agent, Tools: []tools.Tool{upperTool{}}, Dir: t.TempDir(), Progress: &progress}
+	report := runner.Run(context.Background(), file, map[string]string{"greeting": "hi"})
+
+	var statuses []string
+	for _, step := range report.Steps {
+		statuses = append(statuses, step.Status)
+	}
+	assert.Equal(t, []string{
+		StatusSucceeded, StatusSucceeded, StatusFailed, StatusSucceeded, StatusSkipped,
+		StatusFailed, StatusSkipped, StatusSucceeded, StatusSucceeded,
+	}, statuses)
+
+	assert.Equal(t, "Say hello", report.Steps[0].Name)
+	assert.Equal(t, "hi", report.Steps[0].Output, "--var overrides the file's variables")
+	assert.Equal(t, "HI", report.Steps[1].Output)
+	assert.Equal(t, "oops", report.Steps[2].Output)
+	assert.Contains(t, report.Steps[2].Error, "exit status 3")
+	assert.Equal(t, []string{"Why did it fail? oops", "fail", "Clean up", "Report the failure"}, agent.prompts)
+	assert.True(t, report.Failed())
+
+	assert.Contains(t, progress.String(), "[1/9] Say hello\n      ✓ ")
+	assert.Contains(t, progress.String(), "[7/9] prompt After the failure\n      - skipped\n")
+
+	markdown := report.Markdown()
+	assert.Contains(t, markdown, "# Maintenance\n")
+	assert.Contains(t, markdown, "2 failed, 2 skipped.")
+	assert.Contains(t, markdown, "| 3 | broken | failed (allowed) |")
+	assert.Contains(t, markdown, "| 7 | prompt After the failure | skipped | - |")
+	assert.Contains(t, markdown, "## 6. prompt fail\n\nError: model unavailable\n")
+	assert.NotContains(t, markdown, "## 7.")
+
+	var out bytes.Buffer
+	require.NoError(t, report.WriteJSON(&out))
+	var decoded struct {
+		Failed bool `json:"failed"`
+		Steps  []struct {
+			ID     string `json:"id"`
+			Status string `json:"status"`
+		} `json:"steps"`
+	}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+	assert.True(t, decoded.Failed)
+	assert.Equal(t, "echo", decoded.Steps[0].ID)
+	assert.Equal(t, StatusFailed, decoded.Steps[2].Status)
+}
+
+func TestRunErrors(t *testing.T) {
+	file, err := Parse([]byte(`
+steps:
+  - prompt: Hello {{name}}
+    continue_on_error: true
+  - tool: missing
+    continue_on_error: true
+  - run: touch created
+`))
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	runner := &Runner{Agent: &fakeAgent{}, Tools: []tools.Tool{upperTool{}}, Dir: dir, DryRun: true}
+	report := runner.Run(context.Background(), file, nil)
+
+	assert.Equal(t, "undefined variable name", report.Steps[0].Error)
+	assert.Equal(t, `unknown tool "missing": must be one of upper`, report.Steps[1].Error)
+	assert.Equal(t, "[dry run] would run: touch created", report.Steps[2].Output)
+	assert.NoFileExists(t, filepath.Join(dir, "created"))
+	assert.False(t, report.Failed(), "the failed steps may fail")
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("steps:\n  - run: true\n"), 0o644))
+	file, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, "true", file.Steps[0].Run)
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
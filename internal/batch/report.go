@@ -0,0 +1,117 @@
+package batch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Report is the outcome of running a batch file
+type Report struct {
+	Name     string
+	Started  time.Time
+	Duration time.Duration
+	Steps    []StepResult
+}
+
+// Failed reports whether a step failed that wasn't allowed to
+func (r *Report) Failed() bool {
+	for _, step := range r.Steps {
+		if step.Status == StatusFailed && !step.ContinueOnError {
+			return true
+		}
+	}
+	return false
+}
+
+// counts returns how many steps succeeded, failed and were skipped
+func (r *Report) counts() (succeeded, failed, skipped int) {
+	for _, step := range r.Steps {
+		switch step.Status {
+		case StatusSucceeded:
+			succeeded++
+		case StatusFailed:
+			failed++
+		default:
+			skipped++
+		}
+	}
+	return succeeded, failed, skipped
+}
+
+// Markdown renders the report as a table of the steps followed by the
+// output of each step that ran
+func (r *Report) Markdown() string {
+	var sb strings.Builder
+	name := r.Name
+	if name == "" {
+		name = "Batch run"
+	}
+	succeeded, failed, skipped := r.counts()
+	fmt.Fprintf(&sb, "# %s\n\n", name)
+	fmt.Fprintf(&sb, "Started %s, took %s: %d succeeded, %d failed, %d skipped.\n\n", r.Started.Format(time.RFC3339), r.Duration, succeeded, failed, skipped)
+
+	sb.WriteString("| # | Step | Status | Duration |\n|---|------|--------|----------|\n")
+	for i, step := range r.Steps {
+		status := step.Status
+		if step.Status == StatusFailed && step.ContinueOnError {
+			status += " (allowed)"
+		}
+		duration := "-"
+		if step.Status != StatusSkipped {
+			duration = step.Duration.String()
+		}
+		fmt.Fprintf(&sb, "| %d | %s | %s | %s |\n", i+1, strings.ReplaceAll(step.Name, "|", `\|`), status, duration)
+	}
+
+	for i, step := range r.Steps {
+		if step.Status == StatusSkipped {
+			continue
+		}
+		fmt.Fprintf(&sb, "\n## %d. %s\n", i+1, step.Name)
+		if step.Error != "" {
+			fmt.Fprintf(&sb, "\nError: %s\n", step.Error)
+		}
+		if step.Output != "" {
+			fmt.Fprintf(&sb, "\n````\n%s\n````\n", step.Output)
+		}
+	}
+	return sb.String()
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	type step struct {
+		ID              string  `json:"id,omitempty"`
+		Name            string  `json:"name"`
+		Status          string  `json:"status"`
+		Output          string  `json:"output,omitempty"`
+		Error           string  `json:"error,omitempty"`
+		Seconds         float64 `json:"seconds"`
+		ContinueOnError bool    `json:"continue_on_error,omitempty"`
+	}
+	out := struct {
+		Name    string    `json:"name,omitempty"`
+		Started time.Time `json:"started"`
+		Seconds float64   `json:"seconds"`
+		Failed  bool      `json:"failed"`
+		Steps   []step    `json:"steps"`
+	}{Name: r.Name, Started: r.Started, Seconds: r.Duration.Seconds(), Failed: r.Failed()}
+	for _, s := range r.Steps {
+		out.Steps = append(out.Steps, step{
+			ID:              s.ID,
+			Name:            s.Name,
+			Status:          s.Status,
+			Output:          s.Output,
+			Error:           s.Error,
+			Seconds:         s.Duration.Seconds(),
+			ContinueOnError: s.ContinueOnError,
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(out)
+}
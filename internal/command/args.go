@@ -0,0 +1,128 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Flag describes a --flag accepted by a command
+type Flag struct {
+	Name        string // Without the leading dashes
+	Description string
+	HasValue    bool // Whether the flag takes a value (--name value or --name=value)
+}
+
+// Tokenize splits a command line into words. Single and double quotes group
+// words containing spaces and a backslash escapes the next character.
+func Tokenize(line string) ([]string, error) {
+	var (
+		tokens  []string
+		current strings.Builder
+		quote   rune
+		escaped bool
+		inToken bool
+	)
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inToken = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inToken = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inToken {
+				tokens = append(tokens, current.String())
+				current.Reset()
+				inToken = false
+			}
+		default:
+			current.WriteRune(r)
+			inToken = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in command")
+	}
+	if escaped {
+		current.WriteRune('\\')
+	}
+	if inToken {
+		tokens = append(tokens, current.String())
+	}
+	return tokens, nil
+}
+
+// parseArgs separates positional arguments from flags declared in the spec.
+// Arguments after a bare "--" are always positional.
+func parseArgs(spec *Spec, words []string) ([]string, map[string]string, error) {
+	args := []string{}
+	flags := map[string]string{}
+
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+		if word == "--" {
+			args = append(args, words[i+1:]...)
+			break
+		}
+		if !strings.HasPrefix(word, "--") || len(word) == 2 {
+			args = append(args, word)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(word[2:], "=")
+		flag := spec.flag(name)
+		if flag == nil {
+			return nil, nil, fmt.Errorf("unknown flag --%s for %s", name, spec.Name)
+		}
+
+		switch {
+		case flag.HasValue && !hasValue:
+			if i+1 >= len(words) {
+				return nil, nil, fmt.Errorf("flag --%s requires a value", name)
+			}
+			i++
+			value = words[i]
+		case !flag.HasValue && hasValue:
+			return nil, nil, fmt.Errorf("flag --%s does not take a value", name)
+		case !flag.HasValue:
+			value = "true"
+		}
+		flags[name] = value
+	}
+
+	return args, flags, nil
+}
+
+// flag returns the flag spec with the given name
+func (s *Spec) flag(name string) *Flag {
+	for i := range s.Flags {
+		if s.Flags[i].Name == name {
+			return &s.Flags[i]
+		}
+	}
+	return nil
+}
+
+// Flag returns the value of a flag and whether it was given
+func (ctx *Context) Flag(name string) (string, bool) {
+	value, ok := ctx.Flags[name]
+	return value, ok
+}
+
+// Bool reports whether a boolean flag was given
+func (ctx *Context) Bool(name string) bool {
+	_, ok := ctx.Flags[name]
+	return ok
+}
@@ -0,0 +1,128 @@
+package command
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mizzy/rigel/internal/config"
+	"github.com/mizzy/rigel/internal/llm"
+	"github.com/mizzy/rigel/internal/state"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{input: "/model claude-3-5-haiku", want: []string{"/model", "claude-3-5-haiku"}},
+		{input: "/cmd   a\tb", want: []string{"/cmd", "a", "b"}},
+		{input: `/cmd "two words" 'single quoted'`, want: []string{"/cmd", "two words", "single quoted"}},
+		{input: `/cmd a\ b`, want: []string{"/cmd", "a b"}},
+		{input: `/cmd ""`, want: []string{"/cmd", ""}},
+		{input: `/cmd "open`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Tokenize(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	spec := &Spec{
+		Name: "/test",
+		Flags: []Flag{
+			{Name: "force"},
+			{Name: "output", HasValue: true},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		words     []string
+		wantArgs  []string
+		wantFlags map[string]string
+		wantErr   bool
+	}{
+		{name: "positional only", words: []string{"a", "b"}, wantArgs: []string{"a", "b"}, wantFlags: map[string]string{}},
+		{name: "bool flag", words: []string{"--force", "a"}, wantArgs: []string{"a"}, wantFlags: map[string]string{"force": "true"}},
+		{name: "value flag", words: []string{"--output", "x.md"}, wantArgs: []string{}, wantFlags: map[string]string{"output": "x.md"}},
+		{name: "value flag with equals", words: []string{"--output=x.md"}, wantArgs: []string{}, wantFlags: map[string]string{"output": "x.md"}},
+		{name: "double dash", words: []string{"--", "--force"}, wantArgs: []string{"--force"}, wantFlags: map[string]string{}},
+		{name: "unknown flag", words: []string{"--nope"}, wantErr: true},
+		{name: "missing value", words: []string{"--output"}, wantErr: true},
+		{name: "bool with value", words: []string{"--force=yes"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, flags, err := parseArgs(spec, tt.words)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantArgs, args)
+			assert.Equal(t, tt.wantFlags, flags)
+		})
+	}
+}
+
+type modelProvider struct {
+	llm.Provider
+	models  []llm.Model
+	current llm.Model
+}
+
+func (p *modelProvider) ListModels(ctx context.Context) ([]llm.Model, error) { return p.models, nil }
+func (p *modelProvider) GetCurrentModel() llm.Model                          { return p.current }
+func (p *modelProvider) SetModel(model llm.Model)                            { p.current = model }
+func (p *modelProvider) GetName() string                                     { return "ollama" }
+
+func TestModelCommandWithName(t *testing.T) {
+	provider := &modelProvider{
+		models:  []llm.Model{{Name: "big"}, {Name: "small"}},
+		current: llm.Model{Name: "big"},
+	}
+	llmState := state.NewLLMState()
+	llmState.SetCurrentProvider(provider)
+
+	as[Response](t, HandleCommand("/model small", llmState, state.NewChatState(), nil, nil, nil))
+	assert.Equal(t, "small", provider.current.Name)
+	assert.Equal(t, "small", llmState.GetCurrentModel().Name)
+
+	as[Failure](t, HandleCommand("/model missing", llmState, state.NewChatState(), nil, nil, nil))
+	as[ShowModelSelector](t, HandleCommand("/model", llmState, state.NewChatState(), nil, nil, nil))
+}
+
+func TestModelCommandRemembersModel(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	provider := &modelProvider{
+		models:  []llm.Model{{Name: "big"}, {Name: "small"}},
+		current: llm.Model{Name: "big"},
+	}
+	llmState := state.NewLLMState()
+	llmState.SetCurrentProvider(provider)
+	cfg := &config.Config{Provider: "ollama"}
+
+	as[Response](t, HandleCommand("/model small", llmState, state.NewChatState(), cfg, nil, nil))
+	assert.Equal(t, "small", cfg.ModelFor("ollama"))
+
+	path, err := config.ModelsFilePath()
+	require.NoError(t, err)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"ollama": "small"}`, string(data))
+}
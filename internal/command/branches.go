@@ -0,0 +1,172 @@
+package command
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mizzy/rigel/internal/agent"
+	"github.com/mizzy/rigel/internal/session"
+	"github.com/mizzy/rigel/internal/state"
+)
+
+// SaveCurrentBranch writes the active branch's conversation, and what the
+// agent remembers of it, to the session store and returns the saved session
+func SaveCurrentBranch(branches *state.BranchState, chatState *state.ChatState, llmState *state.LLMState, ag *agent.Agent) (*session.Session, error) {
+	store, err := session.NewStore()
+	if err != nil {
+		return nil, err
+	}
+	return saveBranch(store, branches.GetCurrent(), chatState, llmState, ag, "")
+}
+
+// saveBranch writes a branch's conversation. A session saved without a
+// title gets one from the model, unless title is given.
+func saveBranch(store *session.Store, branch state.Branch, chatState *state.ChatState, llmState *state.LLMState, ag *agent.Agent, title string) (*session.Session, error) {
+	// Keep the creation time and fork information of a branch saved before
+	sess, err := store.Load(branch.ID)
+	if err != nil {
+		sess = session.New()
+		sess.ID = branch.ID
+	}
+
+	sess.Name = branch.Name
+	if provider := llmState.GetCurrentProvider(); provider != nil {
+		sess.Provider = provider.GetName()
+		sess.Model = llmState.GetCurrentModel().Name
+	}
+	sess.Exchanges = []session.Exchange{}
+	for _, ex := range chatState.GetHistory() {
+		sess.Exchanges = append(sess.Exchanges, session.Exchange{Prompt: ex.Prompt, Response: ex.Response, Meta: ex.Meta})
+	}
+	if ag != nil {
+		sess.Memory = ag.SaveMemory()
+	}
+	if title != "" {
+		sess.Title = title
+	} else if sess.Title == "" {
+		sess.Title = generateTitle(llmState.GetCurrentProvider(), sess.Exchanges)
+	}
+
+	if err := store.Save(sess); err != nil {
+		return nil, err
+	}
+	return sess, nil
+}
+
+// forkConversation copies the current conversation into a new branch and
+// makes it the active one
+func forkConversation(branches *state.BranchState, chatState *state.ChatState, llmState *state.LLMState, ag *agent.Agent, name string) Result {
+	if branches == nil {
+		return Response{Content: "Branching is not available in this session."}
+	}
+	if name == "" {
+		name = fmt.Sprintf("branch-%d", len(branches.GetBranches())+1)
+	}
+	if branches.Find(name) >= 0 {
+		return Response{Content: fmt.Sprintf("A branch named %q already exists.", name)}
+	}
+
+	store, err := session.NewStore()
+	if err != nil {
+		return Failure{Err: err}
+	}
+
+	parent, err := saveBranch(store, branches.GetCurrent(), chatState, llmState, ag, "")
+	if err != nil {
+		return Failure{Err: fmt.Errorf("failed to save branch: %w", err)}
+	}
+
+	fork := session.New()
+	fork.Name = name
+	fork.ParentID = parent.ID
+	fork.ForkPoint = len(parent.Exchanges)
+	fork.Provider, fork.Model = parent.Provider, parent.Model
+	fork.Exchanges = append(fork.Exchanges, parent.Exchanges...)
+	fork.Memory = parent.Memory
+	fork.Title = parent.Title
+	if err := store.Save(fork); err != nil {
+		return Failure{Err: fmt.Errorf("failed to save branch: %w", err)}
+	}
+
+	parentIndex := branches.GetCurrentIndex()
+	index := branches.Add(state.Branch{ID: fork.ID, Name: name})
+	return Response{
+		Content: fmt.Sprintf("Forked the conversation into branch %d (%s). Use /switch %d to return to %s.",
+			index+1, name, parentIndex+1, parent.Name),
+	}
+}
+
+// listBranches shows the branches of the current conversation
+func listBranches(branches *state.BranchState) Result {
+	if branches == nil {
+		return Response{Content: "Branching is not available in this session."}
+	}
+	if !branches.IsForked() {
+		return Response{Content: "This conversation has no branches. Use /fork to create one."}
+	}
+
+	store, err := session.NewStore()
+	if err != nil {
+		return Failure{Err: err}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Branches:\n")
+	for i, branch := range branches.GetBranches() {
+		marker := " "
+		if i == branches.GetCurrentIndex() {
+			marker = "*"
+		}
+		sb.WriteString(fmt.Sprintf("%s %d. %s", marker, i+1, branch.Name))
+		if sess, err := store.Load(branch.ID); err == nil {
+			sb.WriteString(fmt.Sprintf(" (%d messages", len(sess.Exchanges)))
+			if sess.ParentID != "" {
+				sb.WriteString(fmt.Sprintf(", forked at message %d", sess.ForkPoint))
+			}
+			sb.WriteString(")")
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\nUse /switch <n> to move between branches.")
+
+	return Response{Content: sb.String()}
+}
+
+// switchBranch saves the active branch and loads another one by number or name
+func switchBranch(branches *state.BranchState, chatState *state.ChatState, llmState *state.LLMState, ag *agent.Agent, target string) Result {
+	if branches == nil {
+		return Response{Content: "Branching is not available in this session."}
+	}
+
+	index := branches.Find(target)
+	if n, err := strconv.Atoi(target); err == nil {
+		index = n - 1
+	}
+	if index < 0 || index >= len(branches.GetBranches()) {
+		return Response{Content: fmt.Sprintf("Unknown branch %q. Use /branches to list branches.", target)}
+	}
+	if index == branches.GetCurrentIndex() {
+		return Response{Content: fmt.Sprintf("Already on branch %d (%s).", index+1, branches.GetCurrent().Name)}
+	}
+
+	store, err := session.NewStore()
+	if err != nil {
+		return Failure{Err: err}
+	}
+	if _, err := saveBranch(store, branches.GetCurrent(), chatState, llmState, ag, ""); err != nil {
+		return Failure{Err: fmt.Errorf("failed to save branch: %w", err)}
+	}
+
+	branch := branches.GetBranches()[index]
+	sess, err := store.Load(branch.ID)
+	if err != nil {
+		return Failure{Err: fmt.Errorf("failed to load branch %s: %w", branch.Name, err)}
+	}
+	branches.SetCurrent(index)
+
+	return Restore{
+		Notice:  fmt.Sprintf("Switched to branch %d (%s) with %d messages.", index+1, branch.Name, len(sess.Exchanges)),
+		Session: sess,
+	}
+}
@@ -0,0 +1,67 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mizzy/rigel/internal/state"
+)
+
+func TestForkAndSwitchBranches(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	chatState := state.NewChatState()
+	llmState := state.NewLLMState()
+	branches := state.NewBranchState("main-id")
+	chatState.AddExchange("first", "one")
+
+	forked := as[Response](t, forkConversation(branches, chatState, llmState, nil, ""))
+	assert.Equal(t, "Forked the conversation into branch 2 (branch-2). Use /switch 1 to return to main.", forked.Content)
+	assert.Equal(t, 1, branches.GetCurrentIndex())
+
+	// Diverge on the new branch, then go back to main
+	chatState.AddExchange("second", "two")
+	result := as[Restore](t, switchBranch(branches, chatState, llmState, nil, "1"))
+	require.NotNil(t, result.Session)
+	assert.Len(t, result.Session.Exchanges, 1)
+	assert.Equal(t, 0, branches.GetCurrentIndex())
+
+	// The frontend loads the restored session
+	chatState.ClearHistory()
+	for _, ex := range result.Session.Exchanges {
+		chatState.AddExchange(ex.Prompt, ex.Response)
+	}
+
+	list := as[Response](t, listBranches(branches))
+	assert.Contains(t, list.Content, "* 1. main (1 messages)")
+	assert.Contains(t, list.Content, "  2. branch-2 (2 messages, forked at message 1)")
+
+	// Switching by name restores the diverged conversation
+	result = as[Restore](t, switchBranch(branches, chatState, llmState, nil, "branch-2"))
+	assert.Len(t, result.Session.Exchanges, 2)
+}
+
+func TestSwitchBranch_Errors(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	branches := state.NewBranchState("main-id")
+
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"1", "Already on branch 1 (main)."},
+		{"5", `Unknown branch "5". Use /branches to list branches.`},
+		{"nope", `Unknown branch "nope". Use /branches to list branches.`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			result := switchBranch(branches, state.NewChatState(), state.NewLLMState(), nil, tt.target)
+			assert.Equal(t, Response{Content: tt.want}, result)
+		})
+	}
+
+	assert.Equal(t, Response{Content: "Branching is not available in this session."}, listBranches(nil))
+}
@@ -2,106 +2,243 @@ package command
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"strings"
 	"time"
 
+	"github.com/mizzy/rigel/internal/agent"
 	"github.com/mizzy/rigel/internal/analyzer"
 	"github.com/mizzy/rigel/internal/config"
+	"github.com/mizzy/rigel/internal/draft"
 	"github.com/mizzy/rigel/internal/history"
 	"github.com/mizzy/rigel/internal/llm"
+	"github.com/mizzy/rigel/internal/logging"
+	"github.com/mizzy/rigel/internal/session"
 	"github.com/mizzy/rigel/internal/state"
+	"github.com/mizzy/rigel/internal/ui/styles"
+	"github.com/mizzy/rigel/internal/workspace"
 )
 
-// showHelp displays the help message
-func showHelp() Result {
+// interfaceHelp describes a UI for /help: its name for --ui and RIGEL_UI, what
+// sets it apart and its keys
+type interfaceHelp struct {
+	mode     string
+	name     string
+	features string
+	keys     [][2]string
+}
+
+// interfaces are the UIs /help compares, in the order listed
+var interfaces = []interfaceHelp{
+	{
+		mode:     ModeBubbletea,
+		name:     "tui",
+		features: "Sidebar, file explorer, command palette, themes and mouse support",
+		keys: [][2]string{
+			{"Tab", "Complete command"},
+			{"↑/↓", "Navigate completions or history"},
+			{"Enter", "Send message or select completion"},
+			{"Alt+Enter", "New line"},
+			{"Ctrl+_/Ctrl+Z", "Undo/redo input changes"},
+			{"Ctrl+E", "Browse files"},
+			{"Ctrl+P", "Command palette"},
+			{"Ctrl+O", "Toggle the sidebar"},
+			{"PgUp/PgDn", "Scroll the chat (full screen)"},
+			{"Ctrl+C", "Cancel, or exit when pressed twice"},
+		},
+	},
+	{
+		mode:     ModeTermflow,
+		name:     "termflow",
+		features: "Inline chat that keeps the terminal's scrollback, with a status bar",
+		keys: [][2]string{
+			{"↑/↓", "Navigate history"},
+			{"Enter", "Send message"},
+			{"Ctrl+J", "New line"},
+			{"Ctrl+_/Ctrl+Z", "Undo/redo input changes"},
+			{"Ctrl+C", "Cancel, or exit when pressed twice"},
+		},
+	},
+	{
+		mode:     ModePlain,
+		name:     "plain",
+		features: "Plain lines without colors or line editing, for pipes and logs",
+		keys: [][2]string{
+			{"Enter", "Send message"},
+			{"Ctrl+D", "Exit"},
+		},
+	},
+}
+
+// showHelp displays the help message: the commands and keys of the UI in
+// mode, and how the UIs differ
+func showHelp(registry *Registry, mode string) Result {
 	var help strings.Builder
 	help.WriteString("Available commands:\n\n")
-	for _, cmd := range AvailableCommands {
-		help.WriteString(fmt.Sprintf("  %s - %s\n", cmd.Command, cmd.Description))
+	for _, spec := range registry.Specs(mode) {
+		names := append([]string{spec.Usage()}, spec.Aliases...)
+		help.WriteString(fmt.Sprintf("  %s - %s\n", strings.Join(names, ", "), spec.Description))
 	}
+
 	help.WriteString("\nKeyboard shortcuts:\n")
-	help.WriteString("  Tab       - Complete command\n")
-	help.WriteString("  ↑/↓       - Navigate completions\n")
-	help.WriteString("  Enter     - Send message or select completion\n")
-	help.WriteString("  Alt+Enter - New line\n")
-	help.WriteString("  Ctrl+C    - Exit\n")
-
-	return Result{
-		Type:    "response",
+	for _, ui := range interfaces {
+		if ui.mode == mode || (mode == "" && ui.mode == ModeBubbletea) {
+			for _, key := range ui.keys {
+				help.WriteString(fmt.Sprintf("  %-13s - %s\n", key[0], key[1]))
+			}
+		}
+	}
+
+	help.WriteString("\nInterfaces (rigel --ui <name>, or RIGEL_UI):\n")
+	for _, ui := range interfaces {
+		current := ""
+		if ui.mode == mode {
+			current = " (current)"
+		}
+		help.WriteString(fmt.Sprintf("  %-8s - %s%s\n", ui.name, ui.features, current))
+	}
+
+	return Response{
 		Content: help.String(),
 	}
 }
 
-// analyzeRepository analyzes the repository and generates AGENTS.md
-func analyzeRepository(llmState *state.LLMState) Result {
-	// Check if AGENTS.md already exists
-	if _, err := os.Stat("AGENTS.md"); err == nil {
-		return Result{
-			Type:    "response",
-			Content: "AGENTS.md already exists. Repository has been analyzed previously.",
+// analyzeRepository analyzes the repository and generates AGENTS.md. If
+// AGENTS.md was generated before, it is updated with the changes since then
+// unless force is set. Other roots of ws, if any, are analyzed too.
+func analyzeRepository(llmState *state.LLMState, ws *workspace.Workspace, force bool) Result {
+	var existing string
+	var previous *analyzer.Snapshot
+	if data, err := os.ReadFile("AGENTS.md"); err == nil && !force {
+		snapshot, err := analyzer.LoadSnapshot(".")
+		if err != nil {
+			return Response{
+				Content: "AGENTS.md already exists but there is no analysis snapshot to update it from. Use /init --force to regenerate it.",
+			}
 		}
+		existing, previous = analyzer.StripMetadata(string(data)), snapshot
 	}
 
 	provider := llmState.GetCurrentProvider()
 	if provider == nil {
-		return Result{
-			Type:    "response",
-			Content: "No LLM provider available. Please configure a provider first.",
-			Error:   fmt.Errorf("no provider available"),
+		return Failure{
+			Err: fmt.Errorf("no provider available"),
 		}
 	}
 
-	// Return async result to show spinner while processing
-	return Result{
-		Type: "async",
-		AsyncFn: func() Result {
+	if previous != nil {
+		return runAnalysis(provider, ws, func(ctx context.Context, repoAnalyzer *analyzer.RepoAnalyzer, progress analyzer.ProgressFunc) (string, error) {
 			start := time.Now()
-
-			// Create analyzer and run analysis
-			repoAnalyzer := analyzer.NewRepoAnalyzer(provider)
-			content, err := repoAnalyzer.Analyze()
+			content, changes, err := repoAnalyzer.UpdateContext(ctx, existing, previous, progress)
 			if err != nil {
-				return Result{
-					Type:    "response",
-					Content: fmt.Sprintf("Failed to analyze repository: %v", err),
-					Error:   err,
+				return "", fmt.Errorf("failed to update repository analysis: %w", err)
+			}
+			if changes.Empty() {
+				// Hand edits are accepted as the new content
+				if analyzer.CheckAgentsFile(".").Edited {
+					if err := repoAnalyzer.WriteAgentsFile(existing); err != nil {
+						return "", fmt.Errorf("failed to write AGENTS.md: %w", err)
+					}
+					return "AGENTS.md is up to date with the repository; your edits are kept.", nil
 				}
+				return "AGENTS.md is up to date. Use /init --force to regenerate it.", nil
 			}
+			if err := saveAnalysis(repoAnalyzer, content); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("AGENTS.md updated in %v (%d files changed).", time.Since(start), changes.Files()), nil
+		})
+	}
 
-			// Write AGENTS.md file
-			err = repoAnalyzer.WriteAgentsFile(content)
+	return runAnalysis(provider, ws, func(ctx context.Context, repoAnalyzer *analyzer.RepoAnalyzer, progress analyzer.ProgressFunc) (string, error) {
+		start := time.Now()
+		content, err := repoAnalyzer.AnalyzeContext(ctx, progress)
+		if err != nil {
+			return "", fmt.Errorf("failed to analyze repository: %w", err)
+		}
+		if err := saveAnalysis(repoAnalyzer, content); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Repository analysis completed in %v.\nAGENTS.md has been generated with project context.", time.Since(start)), nil
+	})
+}
+
+// runAnalysis returns an async result that runs an analysis with progress
+// reporting and cancellation. run returns the message to show on success.
+func runAnalysis(provider llm.Provider, ws *workspace.Workspace, run func(context.Context, *analyzer.RepoAnalyzer, analyzer.ProgressFunc) (string, error)) Result {
+	ctx, cancel := context.WithCancel(context.Background())
+	progress := make(chan string, 16)
+
+	// Return async result to show spinner and progress while processing
+	return Async{
+		Progress: progress,
+		Cancel:   cancel,
+		Fn: func() Result {
+			defer cancel()
+			defer close(progress)
+
+			repoAnalyzer := analyzer.NewRepoAnalyzer(provider)
+			if ws != nil {
+				repoAnalyzer.SetWorkspace(ws)
+			}
+			message, err := run(ctx, repoAnalyzer, func(p analyzer.Progress) {
+				// Drop updates the UI hasn't caught up with rather than stall the workers
+				select {
+				case progress <- p.String():
+				default:
+				}
+			})
+			if errors.Is(err, context.Canceled) {
+				return Response{
+					Content: "Repository analysis cancelled.",
+				}
+			}
 			if err != nil {
-				return Result{
-					Type:    "response",
-					Content: fmt.Sprintf("Failed to write AGENTS.md: %v", err),
-					Error:   err,
+				return Failure{
+					Err: err,
 				}
 			}
-
-			duration := time.Since(start)
-
-			return Result{
-				Type:    "response",
-				Content: fmt.Sprintf("Repository analysis completed in %v.\nAGENTS.md has been generated with project context.", duration),
+			return Response{
+				Content: message,
 			}
 		},
 	}
 }
 
+// saveAnalysis writes AGENTS.md and the snapshot it was generated from
+func saveAnalysis(repoAnalyzer *analyzer.RepoAnalyzer, content string) error {
+	if err := repoAnalyzer.WriteAgentsFile(content); err != nil {
+		return fmt.Errorf("failed to write AGENTS.md: %w", err)
+	}
+	if err := repoAnalyzer.SaveSnapshot(); err != nil {
+		return fmt.Errorf("failed to save analysis snapshot: %w", err)
+	}
+	return nil
+}
+
+// connectTimeout returns how long quick requests to the provider, such as
+// listing its models, may take
+func connectTimeout(cfg *config.Config) time.Duration {
+	if cfg == nil {
+		return config.DefaultConnectTimeout
+	}
+	return cfg.ConnectTimeout
+}
+
 // showModelSelector shows the model selector interface
-func showModelSelector(llmState *state.LLMState) Result {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func showModelSelector(llmState *state.LLMState, cfg *config.Config) Result {
+	ctx, cancel := llm.WithTimeout(context.Background(), connectTimeout(cfg))
 	defer cancel()
 
 	currentModel := llmState.GetCurrentModel()
 
 	provider := llmState.GetCurrentProvider()
 	if provider == nil {
-		return Result{
-			Type: "model_selector",
-			ModelSelector: &ModelSelectorMsg{
+		return ShowModelSelector{
+			Selector: &ModelSelectorMsg{
 				CurrentModel: currentModel.Name,
 				Error:        fmt.Errorf("no provider available"),
 			},
@@ -110,23 +247,71 @@ func showModelSelector(llmState *state.LLMState) Result {
 
 	models, err := provider.ListModels(ctx)
 	if err != nil {
-		return Result{
-			Type: "model_selector",
-			ModelSelector: &ModelSelectorMsg{
+		return ShowModelSelector{
+			Selector: &ModelSelectorMsg{
 				CurrentModel: currentModel.Name,
 				Error:        err,
 			},
 		}
 	}
-	return Result{
-		Type: "model_selector",
-		ModelSelector: &ModelSelectorMsg{
+	return ShowModelSelector{
+		Selector: &ModelSelectorMsg{
 			CurrentModel: currentModel.Name,
 			Models:       models,
 		},
 	}
 }
 
+// switchModel switches directly to the named model without the selector UI,
+// remembering it for the provider
+func switchModel(llmState *state.LLMState, cfg *config.Config, name string) Result {
+	provider := llmState.GetCurrentProvider()
+	if provider == nil {
+		return Failure{
+			Err: fmt.Errorf("no provider available"),
+		}
+	}
+
+	ctx, cancel := llm.WithTimeout(context.Background(), connectTimeout(cfg))
+	defer cancel()
+
+	models, err := provider.ListModels(ctx)
+	if err != nil {
+		return Failure{
+			Err: fmt.Errorf("failed to list models: %w", err),
+		}
+	}
+
+	for _, model := range models {
+		if model.Name == name {
+			SetModel(llmState, cfg, model)
+			return Response{
+				Content: fmt.Sprintf("Switched to model: %s", model.Name),
+			}
+		}
+	}
+
+	return Failure{
+		Err: fmt.Errorf("unknown model: %s, run /model to see available models", name),
+	}
+}
+
+// SetModel switches the current provider to model and remembers it for the
+// provider, as choosing it in the model selector does
+func SetModel(llmState *state.LLMState, cfg *config.Config, model llm.Model) {
+	provider := llmState.GetCurrentProvider()
+	if provider == nil {
+		return
+	}
+	provider.SetModel(model)
+	llmState.SetCurrentModel(model)
+	if cfg != nil {
+		if err := cfg.RememberModel(provider.GetName(), model.Name); err != nil {
+			slog.Warn("failed to remember model", "model", model.Name, "error", err)
+		}
+	}
+}
+
 // showProviderSelector shows the provider selector interface
 func showProviderSelector(llmState *state.LLMState) Result {
 	// For now, we need to create provider instances to show them
@@ -135,9 +320,8 @@ func showProviderSelector(llmState *state.LLMState) Result {
 	currentProvider := llmState.GetCurrentProvider()
 	providers := []llm.Provider{currentProvider} // Only show current provider for now
 
-	return Result{
-		Type: "provider_selector",
-		ProviderSelector: &ProviderSelectorMsg{
+	return ShowProviderSelector{
+		Selector: &ProviderSelectorMsg{
 			CurrentProvider: currentProvider,
 			Providers:       providers,
 		},
@@ -145,7 +329,7 @@ func showProviderSelector(llmState *state.LLMState) Result {
 }
 
 // showStatus returns session status information
-func showStatus(llmState *state.LLMState, chatState *state.ChatState, config *config.Config, historyManager *history.Manager, inputHistory []string) Result {
+func showStatus(llmState *state.LLMState, chatState *state.ChatState, config *config.Config, historyManager *history.Manager, inputHistory []string, ws *workspace.Workspace, ag *agent.Agent) Result {
 	provider := llmState.GetCurrentProvider()
 	model := llmState.GetCurrentModel()
 
@@ -153,6 +337,9 @@ func showStatus(llmState *state.LLMState, chatState *state.ChatState, config *co
 	if config != nil {
 		logLevel = config.LogLevel
 	}
+	if logging.DebugEnabled() {
+		logLevel = "debug"
+	}
 
 	// Calculate token usage
 	totalUserChars := 0
@@ -165,15 +352,12 @@ func showStatus(llmState *state.LLMState, chatState *state.ChatState, config *co
 	approxAssistantTokens := totalAssistantChars / 4
 	totalTokens := approxUserTokens + approxAssistantTokens
 
-	// Check if AGENTS.md exists
-	repositoryInitialized := false
-	if _, err := os.Stat("AGENTS.md"); err == nil {
-		repositoryInitialized = true
-	}
+	agents := analyzer.CheckAgentsFile(".")
 
 	statusInfo := StatusInfo{
 		Provider:              provider.GetName(),
 		Model:                 model.Name,
+		Persona:               personaName(ag),
 		MessageCount:          chatState.GetMessageCount(),
 		UserTokens:            approxUserTokens,
 		AssistantTokens:       approxAssistantTokens,
@@ -181,20 +365,46 @@ func showStatus(llmState *state.LLMState, chatState *state.ChatState, config *co
 		CommandsCount:         len(inputHistory),
 		PersistenceEnabled:    historyManager != nil,
 		LogLevel:              logLevel,
-		RepositoryInitialized: repositoryInitialized,
+		RepositoryInitialized: agents.Exists,
+		AgentsMetadata:        agents.Metadata,
+		AgentsWarning:         agents.Warning(),
+	}
+	if ws != nil {
+		statusInfo.Workspace = ws.Roots()
+	}
+	if stats, ok := llm.FindPromptCacheStats(provider); ok && stats.Requests > 0 {
+		statusInfo.PromptCache = &stats
 	}
 
-	return Result{
-		Type:       "status",
-		StatusInfo: &statusInfo,
+	return ShowStatus{
+		Info: &statusInfo,
 	}
 }
 
 // clearChatHistory clears the chat history
 func clearChatHistory(chatState *state.ChatState) Result {
 	chatState.ClearHistory()
-	return Result{
-		Type: "clear",
+	return ClearChat{}
+}
+
+// showHistory opens the history picker with the past prompts, newest first,
+// leaving out /history itself
+func showHistory(historyManager *history.Manager) Result {
+	if historyManager == nil {
+		return Failure{
+			Err: fmt.Errorf("history is not available"),
+		}
+	}
+
+	var entries []history.Entry
+	all := historyManager.Entries()
+	for i := len(all) - 1; i >= 0; i-- {
+		if strings.TrimSpace(all[i].Command) != "/history" {
+			entries = append(entries, all[i])
+		}
+	}
+	return ShowHistory{
+		Picker: &HistoryPickerMsg{Entries: entries},
 	}
 }
 
@@ -203,15 +413,195 @@ func clearCommandHistory(historyManager *history.Manager) Result {
 	// Clear persistent history if available
 	if historyManager != nil {
 		if err := historyManager.Clear(); err != nil {
-			return Result{
-				Type:  "response",
-				Error: fmt.Errorf("failed to clear history: %w", err),
+			return Failure{
+				Err: fmt.Errorf("failed to clear history: %w", err),
 			}
 		}
 	}
 
 	// Return result indicating input history should be cleared
-	return Result{
-		Type: "clear_input_history",
+	return ClearInputHistory{}
+}
+
+// listThemes shows the available themes and marks the active one
+func listThemes() Result {
+	current := styles.Current().Name
+
+	var sb strings.Builder
+	sb.WriteString("Available themes:\n\n")
+	for _, name := range styles.ThemeNames() {
+		marker := "  "
+		if name == current {
+			marker = "* "
+		}
+		sb.WriteString(fmt.Sprintf("  %s%s\n", marker, name))
+	}
+	sb.WriteString("\nCustom themes are loaded from ~/.rigel/themes/<name>.yaml")
+
+	return Response{
+		Content: sb.String(),
+	}
+}
+
+// switchTheme activates the named theme
+func switchTheme(name string) Result {
+	theme, err := styles.ResolveTheme(name)
+	if err != nil {
+		return Failure{
+			Err: err,
+		}
+	}
+
+	styles.Apply(theme)
+	return ThemeChanged{
+		Content: fmt.Sprintf("Switched to theme: %s", theme.Name),
+	}
+}
+
+// toggleDebug switches verbose logging on or off. With no argument it toggles.
+func toggleDebug(arg string) Result {
+	var enable bool
+	switch arg {
+	case "":
+		enable = !logging.DebugEnabled()
+	case "on":
+		enable = true
+	case "off":
+		enable = false
+	default:
+		return Failure{
+			Err: fmt.Errorf("usage: /debug [on|off]"),
+		}
+	}
+
+	logging.SetDebug(enable)
+
+	path, err := logging.Path()
+	if err != nil {
+		path = "~/.rigel/logs/rigel.log"
+	}
+	state := "disabled"
+	if enable {
+		state = "enabled"
+	}
+	return Response{
+		Content: fmt.Sprintf("Debug logging %s (log file: %s)", state, path),
+	}
+}
+
+// toggleDryRun switches dry-run mode, in which the agent describes the tool
+// operations it would perform instead of running them
+func toggleDryRun(ag *agent.Agent, arg string) Result {
+	if ag == nil {
+		return Response{Content: "Dry-run mode is not available in this session."}
+	}
+
+	var enable bool
+	switch arg {
+	case "":
+		enable = !ag.IsDryRun()
+	case "on":
+		enable = true
+	case "off":
+		enable = false
+	default:
+		return Failure{
+			Err: fmt.Errorf("usage: /dryrun [on|off]"),
+		}
+	}
+
+	ag.SetDryRun(enable)
+	if enable {
+		return Response{Content: "Dry-run mode on: the agent will describe file writes and commands instead of running them."}
+	}
+	return Response{Content: "Dry-run mode off: the agent runs tools again."}
+}
+
+// restoreDraft puts the draft an earlier session left unsent back in the
+// input
+func restoreDraft() Result {
+	text, err := draft.Load()
+	if err != nil {
+		return Failure{
+			Err: err,
+		}
+	}
+	if strings.TrimSpace(text) == "" {
+		return Response{
+			Content: "No draft to restore.",
+		}
+	}
+	return RestoreDraft{
+		Draft: text,
+	}
+}
+
+// restoreCrashedSession loads the conversation saved when rigel last crashed
+func restoreCrashedSession() Result {
+	store, err := session.NewStore()
+	if err != nil {
+		return Failure{
+			Err: err,
+		}
+	}
+
+	sess, err := store.LoadRecovery()
+	if errors.Is(err, session.ErrNotFound) {
+		return Response{
+			Content: "No crashed session to restore.",
+		}
+	}
+	if err != nil {
+		return Failure{
+			Err: err,
+		}
+	}
+
+	if err := store.ClearRecovery(); err != nil {
+		return Failure{
+			Err: fmt.Errorf("failed to remove recovery snapshot: %w", err),
+		}
+	}
+
+	content := fmt.Sprintf("Restored %d messages from the crashed session.", len(sess.Exchanges))
+	if sess.Pending != "" {
+		content += fmt.Sprintf("\nThe last prompt was interrupted:\n  %s", sess.Pending)
+	}
+	return Restore{
+		Notice:  content,
+		Session: sess,
+	}
+}
+
+// manageCache shows response cache statistics or clears the cache
+func manageCache(llmState *state.LLMState, action string) Result {
+	cache := llm.FindCache(llmState.GetCurrentProvider())
+	if cache == nil {
+		return Response{
+			Content: "Response cache is disabled. Set RIGEL_CACHE=true to enable it.",
+		}
+	}
+
+	switch action {
+	case "":
+		stats := cache.Stats()
+		return Response{
+			Content: fmt.Sprintf("Response cache\n  Location: %s\n  Entries: %d (%.1f KB)\n  This session: %d hits, %d misses",
+				stats.Dir, stats.Entries, float64(stats.Bytes)/1024, stats.Hits, stats.Misses),
+		}
+	case "clear":
+		removed, err := cache.Clear()
+		if err != nil {
+			return Failure{
+				Err: fmt.Errorf("failed to clear cache: %w", err),
+			}
+		}
+		return Response{
+			Content: fmt.Sprintf("Cleared %d cached responses.", removed),
+		}
+	default:
+		return Failure{
+			Err: fmt.Errorf("usage: /cache [clear]"),
+		}
 	}
 }
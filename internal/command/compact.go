@@ -0,0 +1,96 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mizzy/rigel/internal/llm"
+	"github.com/mizzy/rigel/internal/session"
+	"github.com/mizzy/rigel/internal/state"
+)
+
+const (
+	// defaultCompactKeep is the number of recent exchanges /compact keeps verbatim
+	defaultCompactKeep = 2
+
+	// compactSummaryPrompt is the prompt recorded for the summary exchange
+	compactSummaryPrompt = "(Summary of the earlier conversation)"
+)
+
+// conversationTokens approximates the tokens of a conversation (~4 characters per token)
+func conversationTokens(exchanges []session.Exchange) int {
+	chars := 0
+	for _, ex := range exchanges {
+		chars += len(ex.Prompt) + len(ex.Response)
+	}
+	return chars / 4
+}
+
+// compactConversation asks the model to summarize all but the last keep
+// exchanges and replaces them with the summary
+func compactConversation(llmState *state.LLMState, chatState *state.ChatState, keepArg string) Result {
+	keep := defaultCompactKeep
+	if keepArg != "" {
+		n, err := strconv.Atoi(keepArg)
+		if err != nil || n < 0 {
+			return Response{Content: fmt.Sprintf("Invalid number of exchanges to keep: %s", keepArg)}
+		}
+		keep = n
+	}
+
+	provider := llmState.GetCurrentProvider()
+	if provider == nil {
+		return Failure{Err: fmt.Errorf("no provider available")}
+	}
+
+	// Command output isn't part of the conversation the model remembers
+	var exchanges []session.Exchange
+	for _, ex := range chatState.GetHistory() {
+		if !strings.HasPrefix(ex.Prompt, "/") {
+			exchanges = append(exchanges, session.Exchange{Prompt: ex.Prompt, Response: ex.Response, Meta: ex.Meta})
+		}
+	}
+	if len(exchanges) <= keep {
+		return Response{Content: "Nothing to compact: the conversation is already short."}
+	}
+	older, recent := exchanges[:len(exchanges)-keep], exchanges[len(exchanges)-keep:]
+
+	var transcript strings.Builder
+	for _, ex := range older {
+		transcript.WriteString(fmt.Sprintf("user: %s\nassistant: %s\n\n", ex.Prompt, ex.Response))
+	}
+	prompt := fmt.Sprintf(`Summarize the following conversation between a user and a coding assistant.
+Keep the decisions made, the files and code discussed, open questions and anything the user asked to remember.
+Be concise: the summary replaces the conversation in the assistant's memory.
+
+%s`, transcript.String())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return Async{
+		Cancel: cancel,
+		Fn: func() Result {
+			defer cancel()
+
+			summary, err := provider.GenerateWithOptions(ctx, prompt, llm.GenerateOptions{})
+			if errors.Is(err, context.Canceled) {
+				return Response{Content: "Compaction cancelled."}
+			}
+			if err != nil {
+				return Failure{Err: fmt.Errorf("failed to summarize the conversation: %w", err)}
+			}
+
+			sess := session.New()
+			sess.Exchanges = append([]session.Exchange{{Prompt: compactSummaryPrompt, Response: strings.TrimSpace(summary)}}, recent...)
+
+			before, after := conversationTokens(exchanges), conversationTokens(sess.Exchanges)
+			return Restore{
+				Notice: fmt.Sprintf("Compacted %d messages into a summary, keeping the last %d. Reclaimed ~%d tokens (~%d → ~%d).",
+					len(older), len(recent), max(before-after, 0), before, after),
+				Session: sess,
+			}
+		},
+	}
+}
@@ -0,0 +1,61 @@
+package command
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mizzy/rigel/internal/llm"
+	"github.com/mizzy/rigel/internal/session"
+	"github.com/mizzy/rigel/internal/state"
+)
+
+// summaryProvider answers every prompt with a fixed summary
+type summaryProvider struct {
+	echoProvider
+	prompt string
+}
+
+func (p *summaryProvider) GenerateWithOptions(ctx context.Context, prompt string, opts llm.GenerateOptions) (string, error) {
+	p.prompt = prompt
+	return "We discussed the config loader.\n", nil
+}
+
+func TestCompactConversation(t *testing.T) {
+	provider := &summaryProvider{}
+	llmState := state.NewLLMState()
+	llmState.SetCurrentProvider(provider)
+	chatState := state.NewChatState()
+	chatState.AddExchange("explain config.go", strings.Repeat("long answer ", 100))
+	chatState.AddExchange("/status", "ok")
+	chatState.AddExchange("and the tests?", strings.Repeat("more detail ", 100))
+	chatState.AddExchange("thanks", "you're welcome")
+
+	async := as[Async](t, HandleCommand("/compact 1", llmState, chatState, nil, nil, nil))
+	result := as[Restore](t, async.Fn())
+	assert.Equal(t, []session.Exchange{
+		{Prompt: compactSummaryPrompt, Response: "We discussed the config loader."},
+		{Prompt: "thanks", Response: "you're welcome"},
+	}, result.Session.Exchanges)
+	assert.Contains(t, result.Notice, "Compacted 2 messages")
+	assert.Contains(t, result.Notice, "Reclaimed ~")
+
+	assert.Contains(t, provider.prompt, "user: explain config.go")
+	assert.NotContains(t, provider.prompt, "/status")
+	assert.NotContains(t, provider.prompt, "thanks")
+}
+
+func TestCompactConversationTooShort(t *testing.T) {
+	llmState := state.NewLLMState()
+	llmState.SetCurrentProvider(&summaryProvider{})
+	chatState := state.NewChatState()
+	chatState.AddExchange("hi", "hello")
+
+	result := as[Response](t, HandleCommand("/compact", llmState, chatState, nil, nil, nil))
+	assert.Contains(t, result.Content, "Nothing to compact")
+
+	result = as[Response](t, HandleCommand("/compact many", llmState, chatState, nil, nil, nil))
+	assert.Contains(t, result.Content, "Invalid number")
+}
@@ -0,0 +1,154 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mizzy/rigel/internal/config"
+	"github.com/mizzy/rigel/internal/llm"
+	"github.com/mizzy/rigel/internal/state"
+)
+
+// compareProviders are the provider names accepted as a "provider/model" prefix
+var compareProviders = []string{"anthropic", "ollama", "openai-compatible"}
+
+// ComparisonResponse is one model's answer to a /compare prompt
+type ComparisonResponse struct {
+	Provider string
+	Model    string
+	Content  string
+	Error    error
+	Latency  time.Duration
+	Tokens   int // Approximate output tokens (~4 characters per token)
+}
+
+// Label returns "provider/model"
+func (r ComparisonResponse) Label() string {
+	return r.Provider + "/" + r.Model
+}
+
+// Stats returns the latency and token count, e.g. "1.2s, ~340 tokens"
+func (r ComparisonResponse) Stats() string {
+	if r.Error != nil {
+		return fmt.Sprintf("failed after %s", r.Latency.Round(100*time.Millisecond))
+	}
+	return fmt.Sprintf("%s, ~%d tokens", r.Latency.Round(100*time.Millisecond), r.Tokens)
+}
+
+type compareTarget struct {
+	provider llm.Provider
+	model    string
+}
+
+// resolveCompareTargets turns "model" or "provider/model" names into
+// providers. Plain model names use the current provider.
+func resolveCompareTargets(llmState *state.LLMState, cfg *config.Config, names []string) ([]compareTarget, error) {
+	current := llmState.GetCurrentProvider()
+
+	var targets []compareTarget
+	for _, name := range names {
+		providerName, model := "", name
+		if prefix, rest, ok := strings.Cut(name, "/"); ok {
+			for _, known := range compareProviders {
+				if prefix == known {
+					providerName, model = prefix, rest
+				}
+			}
+		}
+
+		if providerName == "" || (current != nil && providerName == current.GetName()) {
+			if current == nil {
+				return nil, fmt.Errorf("no provider available")
+			}
+			targets = append(targets, compareTarget{provider: current, model: model})
+			continue
+		}
+
+		if cfg == nil {
+			return nil, fmt.Errorf("cannot create provider %s without configuration", providerName)
+		}
+		providerCfg := *cfg
+		providerCfg.Provider = providerName
+		providerCfg.Model = model
+		provider, err := llm.NewProvider(&providerCfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create provider for %s: %w", name, err)
+		}
+		targets = append(targets, compareTarget{provider: provider, model: model})
+	}
+	return targets, nil
+}
+
+// compareModels sends the same prompt to several models concurrently
+func compareModels(llmState *state.LLMState, cfg *config.Config, prompt string, models string) Result {
+	var names []string
+	for _, name := range strings.Split(models, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 && cfg != nil {
+		names = cfg.CompareModels
+	}
+	if len(names) < 2 {
+		return Response{
+			Content: "Specify at least two models with /compare --models a,b <prompt> or RIGEL_COMPARE_MODELS.",
+		}
+	}
+
+	targets, err := resolveCompareTargets(llmState, cfg, names)
+	if err != nil {
+		return Failure{Err: err}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	progress := make(chan string, len(targets))
+
+	return Async{
+		Progress: progress,
+		Cancel:   cancel,
+		Fn: func() Result {
+			defer cancel()
+			defer close(progress)
+
+			responses := make([]ComparisonResponse, len(targets))
+			var mu sync.Mutex
+			done := 0
+
+			var wg sync.WaitGroup
+			for i, target := range targets {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					start := time.Now()
+					content, err := target.provider.GenerateWithOptions(ctx, prompt, llm.GenerateOptions{Model: target.model})
+					responses[i] = ComparisonResponse{
+						Provider: target.provider.GetName(),
+						Model:    target.model,
+						Content:  strings.TrimSpace(content),
+						Error:    err,
+						Latency:  time.Since(start),
+						Tokens:   len(content) / 4,
+					}
+
+					mu.Lock()
+					done++
+					progress <- fmt.Sprintf("received %d/%d responses", done, len(targets))
+					mu.Unlock()
+				}()
+			}
+			wg.Wait()
+
+			if errors.Is(ctx.Err(), context.Canceled) {
+				return Response{Content: "Comparison cancelled."}
+			}
+			return ShowComparison{
+				Responses: responses,
+			}
+		},
+	}
+}
@@ -0,0 +1,50 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mizzy/rigel/internal/llm"
+	"github.com/mizzy/rigel/internal/state"
+)
+
+// echoProvider answers with the requested model name
+type echoProvider struct {
+	llm.Provider
+}
+
+func (p *echoProvider) GetName() string            { return "ollama" }
+func (p *echoProvider) GetCurrentModel() llm.Model { return llm.Model{Name: "llama3.2"} }
+
+func (p *echoProvider) GenerateWithOptions(ctx context.Context, prompt string, opts llm.GenerateOptions) (string, error) {
+	if opts.Model == "broken" {
+		return "", errors.New("model not found")
+	}
+	return opts.Model + ": " + prompt, nil
+}
+
+func TestCompareModels(t *testing.T) {
+	llmState := state.NewLLMState()
+	llmState.SetCurrentProvider(&echoProvider{})
+
+	async := as[Async](t, HandleCommand(`/compare --models llama3.2,ollama/qwen,broken "why is the sky blue"`, llmState, state.NewChatState(), nil, nil, nil))
+	responses := as[ShowComparison](t, async.Fn()).Responses
+	require.Len(t, responses, 3)
+
+	assert.Equal(t, "ollama/llama3.2", responses[0].Label())
+	assert.Equal(t, "llama3.2: why is the sky blue", responses[0].Content)
+	assert.Equal(t, "qwen: why is the sky blue", responses[1].Content)
+	assert.EqualError(t, responses[2].Error, "model not found")
+}
+
+func TestCompareModels_NeedsTwoModels(t *testing.T) {
+	llmState := state.NewLLMState()
+	llmState.SetCurrentProvider(&echoProvider{})
+
+	result := as[Response](t, HandleCommand("/compare --models llama3.2 hello there", llmState, state.NewChatState(), nil, nil, nil))
+	assert.Contains(t, result.Content, "Specify at least two models")
+}
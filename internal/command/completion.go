@@ -3,13 +3,21 @@ package command
 import "strings"
 
 // CompletionHandler handles command completion functionality
-type CompletionHandler struct{}
+type CompletionHandler struct {
+	mode string
+}
 
-// NewCompletionHandler creates a new completion handler
+// NewCompletionHandler creates a new completion handler for all commands
 func NewCompletionHandler() *CompletionHandler {
 	return &CompletionHandler{}
 }
 
+// NewCompletionHandlerForMode creates a completion handler limited to
+// commands available in the given UI mode
+func NewCompletionHandlerForMode(mode string) *CompletionHandler {
+	return &CompletionHandler{mode: mode}
+}
+
 // UpdateCompletions updates the command completions based on user input
 func (h *CompletionHandler) UpdateCompletions(inputValue string) ([]string, bool) {
 	completions := []string{}
@@ -18,7 +26,7 @@ func (h *CompletionHandler) UpdateCompletions(inputValue string) ([]string, bool
 	// Check if the input starts with / (without leading spaces)
 	if strings.HasPrefix(inputValue, "/") {
 		prefix := strings.ToLower(inputValue)
-		for _, cmd := range AvailableCommands {
+		for _, cmd := range DefaultRegistry.Commands(h.mode) {
 			if strings.HasPrefix(strings.ToLower(cmd.Command), prefix) {
 				completions = append(completions, cmd.Command)
 			}
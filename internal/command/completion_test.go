@@ -39,7 +39,7 @@ func TestCompletionHandler_UpdateCompletions_SpaceHandling(t *testing.T) {
 			name:        "just slash",
 			input:       "/",
 			expectShow:  true,
-			expectCount: len(AvailableCommands), // All commands should match
+			expectCount: len(AvailableCommands()), // All commands should match
 		},
 		{
 			name:        "space then slash",
@@ -0,0 +1,103 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mizzy/rigel/internal/agent"
+	"github.com/mizzy/rigel/internal/config"
+	"github.com/mizzy/rigel/internal/llm"
+	"github.com/mizzy/rigel/internal/notes"
+)
+
+// showContext lists the AGENTS.md files and memory notes included in the
+// system prompt for the conversation so far, broadest scope first, and the
+// files the agent read or wrote, marking those changed on disk since
+func showContext(cfg *config.Config, ag *agent.Agent) Result {
+	var sb strings.Builder
+	if cfg != nil && !slices.Contains(cfg.Middleware, config.MiddlewareAgentsMD) {
+		sb.WriteString(fmt.Sprintf("No AGENTS.md is loaded: RIGEL_MIDDLEWARE doesn't include %s.", config.MiddlewareAgentsMD))
+	} else if err := writePromptContext(&sb, ag, cfg != nil && cfg.ReadOnly); err != nil {
+		return Failure{Err: err}
+	}
+
+	if ag == nil {
+		return Response{Content: sb.String()}
+	}
+	if files := ag.ContextFiles(); len(files) > 0 {
+		sb.WriteString("\n\nFiles in this conversation:\n")
+		for _, file := range files {
+			sb.WriteString("  " + file.Path)
+			if file.Changed {
+				sb.WriteString(" (changed on disk; refreshed with the next prompt)")
+			}
+			sb.WriteString("\n")
+		}
+	}
+	return Response{Content: strings.TrimSuffix(sb.String(), "\n")}
+}
+
+// addFiles adds the content of files to the conversation with ag
+func addFiles(ag *agent.Agent, paths []string) Result {
+	if ag == nil {
+		return Failure{Err: errors.New("no conversation to add the files to")}
+	}
+	added, err := ag.AddFiles(paths...)
+	if len(added) == 0 {
+		return Failure{Err: err}
+	}
+	content := fmt.Sprintf("Added %s to the conversation.", strings.Join(added, ", "))
+	if err != nil {
+		content += fmt.Sprintf("\nNot added: %v", err)
+	}
+	return Response{Content: content}
+}
+
+// writePromptContext writes the AGENTS.md files and memory notes included
+// in the system prompt for the conversation with ag; in a read-only
+// directory, that is only the global AGENTS.md
+func writePromptContext(sb *strings.Builder, ag *agent.Agent, readOnly bool) error {
+	var texts []string
+	if ag != nil {
+		for _, message := range ag.History() {
+			texts = append(texts, message.Content)
+		}
+	}
+	files, err := llm.LoadAgentsFiles(texts...)
+	if err != nil {
+		return err
+	}
+	if readOnly {
+		files = slices.DeleteFunc(files, func(file llm.AgentsFile) bool { return file.Scope != llm.AgentsScopeGlobal })
+		if len(files) == 0 {
+			sb.WriteString("No AGENTS.md is loaded: this directory isn't trusted, so only ~/.rigel/AGENTS.md would be. Run 'rigel trust' to trust it.")
+			return nil
+		}
+		sb.WriteString("Loaded into the system prompt:\n")
+		for _, file := range files {
+			sb.WriteString(fmt.Sprintf("  %-10s %s\n", file.Scope, file.Path))
+		}
+		sb.WriteString("\nThis directory isn't trusted, so its AGENTS.md files and memory notes aren't loaded. Run 'rigel trust' to trust it.")
+		return nil
+	}
+	memories, err := notes.Load()
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 && len(memories) == 0 {
+		sb.WriteString("No AGENTS.md is loaded. Run /init to generate one for this repository, or write ~/.rigel/AGENTS.md for guidance in every project.")
+		return nil
+	}
+
+	sb.WriteString("Loaded into the system prompt, closest last and taking precedence:\n")
+	for _, file := range files {
+		sb.WriteString(fmt.Sprintf("  %-10s %s\n", file.Scope, file.Path))
+	}
+	if len(memories) > 0 {
+		sb.WriteString(fmt.Sprintf("  %-10s %s\n", "memory", notes.Path))
+	}
+	sb.WriteString("\nAGENTS.md files in subdirectories are loaded once the conversation mentions files under them.")
+	return nil
+}
@@ -0,0 +1,84 @@
+package command
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mizzy/rigel/internal/agent"
+	"github.com/mizzy/rigel/internal/config"
+	"github.com/mizzy/rigel/internal/notes"
+	"github.com/mizzy/rigel/internal/tools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShowContext(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Chdir(t.TempDir())
+	cfg := &config.Config{Middleware: []string{config.MiddlewareAgentsMD}}
+	ag := agent.New(nil)
+
+	assert.Contains(t, as[Response](t, showContext(cfg, ag)).Content, "No AGENTS.md is loaded")
+
+	require.NoError(t, os.WriteFile("AGENTS.md", []byte("# Project\n\nA Go CLI.\n"), 0644))
+	require.NoError(t, os.MkdirAll("lib", 0755))
+	require.NoError(t, os.WriteFile(filepath.Join("lib", "AGENTS.md"), []byte("No cgo."), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join("lib", "parse.go"), []byte("package lib"), 0644))
+	require.NoError(t, notes.Add("we use uber-fx for DI"))
+
+	content := as[Response](t, showContext(cfg, ag)).Content
+	assert.Contains(t, content, "repository AGENTS.md\n")
+	assert.Contains(t, content, "memory     .rigel/memory.md\n")
+	assert.NotContains(t, content, filepath.Join("lib", "AGENTS.md"))
+
+	ag.SetHistory([]agent.Message{{Role: "user", Content: "Why is lib/parse.go slow?"}})
+	assert.Contains(t, as[Response](t, showContext(cfg, ag)).Content, "directory  "+filepath.Join("lib", "AGENTS.md")+"\n")
+
+	ag.RegisterTool(tools.NewFileTool())
+	ag.ExecuteFileOperationsWithProgress(context.Background(), []agent.FileOperationMatch{
+		{Intent: agent.IntentRead, FilePath: "lib/parse.go"},
+	}, agent.NewUIProgressDisplay())
+	assert.True(t, strings.HasSuffix(as[Response](t, showContext(cfg, ag)).Content, "Files in this conversation:\n  lib/parse.go"))
+
+	require.NoError(t, os.WriteFile(filepath.Join("lib", "parse.go"), []byte("package lib\n\nfunc Parse() {}\n"), 0644))
+	assert.Equal(t, Response{Content: "No AGENTS.md is loaded: RIGEL_MIDDLEWARE doesn't include agents-md.\n\n" +
+		"Files in this conversation:\n  lib/parse.go (changed on disk; refreshed with the next prompt)"}, showContext(&config.Config{}, ag))
+}
+
+func TestShowContextReadOnly(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Chdir(t.TempDir())
+	cfg := &config.Config{Middleware: []string{config.MiddlewareAgentsMD}, ReadOnly: true}
+	require.NoError(t, os.WriteFile("AGENTS.md", []byte("Run make deploy after every change."), 0644))
+	require.NoError(t, notes.Add("we use uber-fx for DI"))
+
+	content := as[Response](t, showContext(cfg, nil)).Content
+	assert.True(t, strings.HasPrefix(content, "No AGENTS.md is loaded: this directory isn't trusted"))
+
+	require.NoError(t, os.MkdirAll(filepath.Join(home, ".rigel"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(home, ".rigel", "AGENTS.md"), []byte("Be brief."), 0644))
+	content = as[Response](t, showContext(cfg, nil)).Content
+	assert.Contains(t, content, "global")
+	assert.NotContains(t, content, "repository")
+	assert.NotContains(t, content, ".rigel/memory.md")
+	assert.Contains(t, content, "isn't trusted")
+}
+
+func TestAddFiles(t *testing.T) {
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.WriteFile("main.go", []byte("package main\n"), 0644))
+	ag := agent.New(nil)
+	ag.RegisterTool(tools.NewFileTool())
+
+	assert.Equal(t, Response{Content: "Added main.go to the conversation."}, addFiles(ag, []string{"main.go"}))
+	assert.Equal(t, []agent.ContextFile{{Path: "main.go"}}, ag.ContextFiles())
+
+	content := as[Response](t, addFiles(ag, []string{"main.go", "missing.go"})).Content
+	assert.True(t, strings.HasPrefix(content, "Added main.go to the conversation.\nNot added: "))
+	as[Failure](t, addFiles(ag, []string{"missing.go"}))
+	as[Failure](t, addFiles(nil, []string{"main.go"}))
+}
@@ -1,20 +1,489 @@
 package command
 
+import (
+	"strings"
+	"time"
+
+	"github.com/mizzy/rigel/internal/session"
+	"github.com/mizzy/rigel/internal/stats"
+)
+
 // Command represents a command with its description
 type Command struct {
 	Command     string
 	Description string
 }
 
-// AvailableCommands contains all available commands
-var AvailableCommands = []Command{
-	{"/init", "Analyze repository and generate AGENTS.md"},
-	{"/model", "Show current model and select from available models"},
-	{"/provider", "Switch between LLM providers (Anthropic, Ollama, etc.)"},
-	{"/status", "Show current session status and configuration"},
-	{"/help", "Show available commands"},
-	{"/clear", "Clear chat history"},
-	{"/clearhistory", "Clear command history"},
-	{"/exit", "Exit the application"},
-	{"/quit", "Exit the application"},
+// DefaultRegistry holds the built-in commands and any registered at runtime
+var DefaultRegistry = newDefaultRegistry()
+
+// Register adds a command to the default registry
+func Register(spec Spec) error {
+	return DefaultRegistry.Register(spec)
+}
+
+// AvailableCommands returns all commands in the default registry
+func AvailableCommands() []Command {
+	return DefaultRegistry.Commands("")
+}
+
+func newDefaultRegistry() *Registry {
+	r := NewRegistry()
+
+	r.MustRegister(Spec{
+		Name:        "/init",
+		Description: "Analyze repository and generate or update AGENTS.md",
+		Hint:        "Try /init to analyze this repo",
+		Flags: []Flag{
+			{Name: "force", Description: "Regenerate AGENTS.md from scratch instead of updating it"},
+		},
+		Handler: func(ctx *Context) Result {
+			return analyzeRepository(ctx.LLMState, ctx.Workspace, ctx.Bool("force"))
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/model",
+		Description: "Show current model and select from available models",
+		Hint:        "Try /model to switch models",
+		Args:        []Arg{{Name: "name"}},
+		Handler: func(ctx *Context) Result {
+			if len(ctx.Args) == 1 {
+				return switchModel(ctx.LLMState, ctx.Config, ctx.Args[0])
+			}
+			return showModelSelector(ctx.LLMState, ctx.Config)
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/pull",
+		Description: "Download a model with Ollama",
+		Args:        []Arg{{Name: "model", Required: true}},
+		Handler: func(ctx *Context) Result {
+			return pullModel(ctx.LLMState, ctx.Args[0])
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/show",
+		Description: "Show Ollama model details (defaults to the current model)",
+		Args:        []Arg{{Name: "model"}},
+		Handler: func(ctx *Context) Result {
+			name := ""
+			if len(ctx.Args) == 1 {
+				name = ctx.Args[0]
+			}
+			return showModelInfo(ctx.LLMState, ctx.Config, name)
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/ps",
+		Description: "List models Ollama has loaded in memory",
+		Handler: func(ctx *Context) Result {
+			return listRunningModels(ctx.LLMState, ctx.Config)
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/set",
+		Description: "Show or set options (editing-mode, status-bar, hints, and Ollama's num_ctx, top_p, top_k, seed, keep_alive)",
+		Hint:        "Try /set to see the options you can change",
+		Args:        []Arg{{Name: "option"}, {Name: "value"}},
+		Handler: func(ctx *Context) Result {
+			return setOption(ctx.LLMState, ctx.Config, ctx.Args)
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/provider",
+		Description: "Switch between LLM providers (Anthropic, Ollama, etc.)",
+		Handler: func(ctx *Context) Result {
+			return showProviderSelector(ctx.LLMState)
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/status",
+		Description: "Show current session status and configuration",
+		Handler: func(ctx *Context) Result {
+			return showStatus(ctx.LLMState, ctx.ChatState, ctx.Config, ctx.History, ctx.InputHistory, ctx.Workspace, ctx.Agent)
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/workspace",
+		Description: "List workspace roots, or add or remove one",
+		Args:        []Arg{{Name: "add|remove"}, {Name: "path"}},
+		Flags: []Flag{
+			{Name: "read-only", Description: "Don't allow the agent to write to the added root"},
+		},
+		Handler: func(ctx *Context) Result {
+			return manageWorkspace(ctx.Workspace, ctx.Args, ctx.Bool("read-only"))
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/doctor",
+		Description: "Check configuration, API keys, provider connectivity, sandbox and history",
+		Hint:        "Something wrong? /doctor checks your setup",
+		Handler: func(ctx *Context) Result {
+			return runDoctor(ctx.LLMState, ctx.Config)
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/theme",
+		Description: "Show or switch the color theme (dark, light or a custom theme)",
+		Args:        []Arg{{Name: "name"}},
+		Modes:       []string{ModeBubbletea},
+		Handler: func(ctx *Context) Result {
+			if len(ctx.Args) == 1 {
+				return switchTheme(ctx.Args[0])
+			}
+			return listThemes()
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/debug",
+		Description: "Toggle verbose debug logging to ~/.rigel/logs/rigel.log",
+		Args:        []Arg{{Name: "on|off"}},
+		Handler: func(ctx *Context) Result {
+			arg := ""
+			if len(ctx.Args) == 1 {
+				arg = ctx.Args[0]
+			}
+			return toggleDebug(arg)
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/dryrun",
+		Description: "Toggle dry-run mode, in which the agent describes file writes and commands instead of running them",
+		Hint:        "Try /dryrun to preview what a risky prompt would change",
+		Args:        []Arg{{Name: "on|off"}},
+		Handler: func(ctx *Context) Result {
+			arg := ""
+			if len(ctx.Args) == 1 {
+				arg = ctx.Args[0]
+			}
+			return toggleDryRun(ctx.Agent, arg)
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/cache",
+		Description: "Show response cache statistics, or clear the cache with /cache clear",
+		Args:        []Arg{{Name: "clear"}},
+		Handler: func(ctx *Context) Result {
+			action := ""
+			if len(ctx.Args) == 1 {
+				action = ctx.Args[0]
+			}
+			return manageCache(ctx.LLMState, action)
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/restore",
+		Description: "Restore the conversation from a session that crashed",
+		Handler: func(ctx *Context) Result {
+			return restoreCrashedSession()
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/draft",
+		Description: "Restore the prompt an earlier session exited before sending",
+		Handler: func(ctx *Context) Result {
+			return restoreDraft()
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/retry",
+		Description: "Re-send the last prompt, optionally with another model",
+		Hint:        "Try /retry --model <name> to ask another model",
+		Flags: []Flag{
+			{Name: "model", Description: "Switch to this model before retrying", HasValue: true},
+		},
+		Handler: func(ctx *Context) Result {
+			model, _ := ctx.Flag("model")
+			return retryLastPrompt(ctx.LLMState, ctx.ChatState, ctx.Config, model)
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/continue",
+		Description: "Have the model finish a response that was interrupted",
+		Handler: func(ctx *Context) Result {
+			return continueResponse(ctx.Agent)
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/edit-last",
+		Description: "Edit the last prompt and drop its response",
+		Handler: func(ctx *Context) Result {
+			return editLastPrompt(ctx.ChatState)
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/compare",
+		Description: "Send a prompt to several models and compare the responses",
+		Hint:        "Try /compare <prompt> to ask several models at once",
+		Args:        []Arg{{Name: "prompt", Required: true, Variadic: true}},
+		Flags: []Flag{
+			{Name: "models", Description: "Comma-separated models, as model or provider/model", HasValue: true},
+		},
+		Handler: func(ctx *Context) Result {
+			models, _ := ctx.Flag("models")
+			return compareModels(ctx.LLMState, ctx.Config, strings.Join(ctx.Args, " "), models)
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/compact",
+		Description: "Summarize the conversation to free context, keeping the last n exchanges (default 2)",
+		Hint:        "Long conversation? /compact frees context",
+		Args:        []Arg{{Name: "n"}},
+		Handler: func(ctx *Context) Result {
+			keep := ""
+			if len(ctx.Args) == 1 {
+				keep = ctx.Args[0]
+			}
+			return compactConversation(ctx.LLMState, ctx.ChatState, keep)
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/fork",
+		Description: "Fork the conversation into a new branch",
+		Hint:        "Try /fork to explore another direction",
+		Args:        []Arg{{Name: "name"}},
+		Handler: func(ctx *Context) Result {
+			name := ""
+			if len(ctx.Args) == 1 {
+				name = ctx.Args[0]
+			}
+			return forkConversation(ctx.Branches, ctx.ChatState, ctx.LLMState, ctx.Agent, name)
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/branches",
+		Description: "List the branches of the conversation",
+		Handler: func(ctx *Context) Result {
+			return listBranches(ctx.Branches)
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/switch",
+		Description: "Switch to another conversation branch",
+		Args:        []Arg{{Name: "n", Required: true}},
+		Handler: func(ctx *Context) Result {
+			return switchBranch(ctx.Branches, ctx.ChatState, ctx.LLMState, ctx.Agent, ctx.Args[0])
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/resume",
+		Description: "Continue a saved conversation, or list them",
+		Hint:        "Try /resume to pick up an earlier conversation",
+		Args:        []Arg{{Name: "n"}},
+		Handler: func(ctx *Context) Result {
+			store, err := session.NewStore()
+			if err != nil {
+				return Failure{Err: err}
+			}
+			target := ""
+			if len(ctx.Args) == 1 {
+				target = ctx.Args[0]
+			}
+			return resumeSession(store, ctx.Branches, ctx.ChatState, ctx.LLMState, ctx.Agent, target)
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/search",
+		Description: "Search this conversation and the saved ones, and jump to a matching exchange",
+		Hint:        "Lost an answer? /search finds it in this or past conversations",
+		Args:        []Arg{{Name: "words", Required: true, Variadic: true}},
+		Handler: func(ctx *Context) Result {
+			store, err := session.NewStore()
+			if err != nil {
+				return Failure{Err: err}
+			}
+			return searchConversations(store, ctx.Branches, ctx.ChatState, strings.Join(ctx.Args, " "))
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/session",
+		Description: "List the saved conversations, or rename this one",
+		Args:        []Arg{{Name: "list|rename"}, {Name: "title", Variadic: true}},
+		Handler: func(ctx *Context) Result {
+			store, err := session.NewStore()
+			if err != nil {
+				return Failure{Err: err}
+			}
+			return manageSessions(store, ctx.Branches, ctx.ChatState, ctx.LLMState, ctx.Agent, ctx.Args)
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/jobs",
+		Description: "List the background jobs, follow one's output, or cancel one; end a prompt with & to start one",
+		Hint:        "End a prompt with & to run it in the background",
+		Args:        []Arg{{Name: "list|attach|cancel"}, {Name: "n"}},
+		Handler: func(ctx *Context) Result {
+			return manageJobs(ctx.Agent, ctx.Args)
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/fix",
+		Description: "Paste compiler or test output to have the agent propose a fix as a diff",
+		Hint:        "Build failing? Paste its output after /fix",
+		Args:        []Arg{{Name: "output", Required: true}},
+		Raw:         true,
+		Handler: func(ctx *Context) Result {
+			return fixOutput(ctx.Args[0])
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/save-code",
+		Description: "Save the files the last response gives in code blocks named for them, after reviewing the writes",
+		Hint:        "Asked for a Dockerfile? /save-code writes it to disk",
+		Args:        []Arg{{Name: "path", Variadic: true}},
+		Handler: func(ctx *Context) Result {
+			return saveCode(ctx.Agent, ctx.ChatState, ctx.Args)
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/paste",
+		Description: "Attach the image in the clipboard, or an image file, to the next prompt for vision models",
+		Hint:        "Took a screenshot? /paste attaches it to your next prompt",
+		Args:        []Arg{{Name: "path"}},
+		Raw:         true,
+		Handler: func(ctx *Context) Result {
+			var path string
+			if len(ctx.Args) > 0 {
+				path = ctx.Args[0]
+			}
+			return pasteImage(ctx.LLMState, ctx.Agent, path)
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/review",
+		Description: "Review the uncommitted changes, or those staged or on the current branch, and list the findings by severity",
+		Flags: []Flag{
+			{Name: "staged", Description: "Review only the staged changes"},
+			{Name: "branch", Description: "Review the changes of the current branch since it diverged from this one", HasValue: true},
+		},
+		Handler: func(ctx *Context) Result {
+			base, _ := ctx.Flag("branch")
+			return reviewChanges(ctx.LLMState, ctx.Agent, ctx.Bool("staged"), base)
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/pr",
+		Description: "Push the current branch and open a GitHub pull request, titled and described from its commits",
+		Flags: []Flag{
+			{Name: "base", Description: "Branch to merge into; the repository's default branch if not given", HasValue: true},
+			{Name: "draft", Description: "Open the pull request as a draft"},
+		},
+		Handler: func(ctx *Context) Result {
+			base, _ := ctx.Flag("base")
+			return createPullRequest(ctx.LLMState, ctx.Config, base, ctx.Bool("draft"))
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/issue",
+		Description: "Pull a GitHub issue and its comments into the conversation",
+		Args:        []Arg{{Name: "n", Required: true}},
+		Handler: func(ctx *Context) Result {
+			return showIssue(ctx.Config, ctx.Agent, ctx.Args[0])
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/persona",
+		Description: "Show the personas, or switch the agent to one for this project",
+		Hint:        "Try /persona strict-reviewer for a critical code review",
+		Args:        []Arg{{Name: "name"}},
+		Handler: func(ctx *Context) Result {
+			return managePersona(ctx.Agent, ctx.Args)
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/remember",
+		Description: "Remember a fact about the project in every future session",
+		Hint:        "Try /remember to note a fact for future sessions",
+		Args:        []Arg{{Name: "fact", Required: true, Variadic: true}},
+		Handler: func(ctx *Context) Result {
+			return rememberNote(strings.Join(ctx.Args, " "))
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/memories",
+		Description: "List the remembered facts, or delete one with /memories delete <n>",
+		Args:        []Arg{{Name: "delete"}, {Name: "n"}},
+		Handler: func(ctx *Context) Result {
+			return manageMemories(ctx.Args)
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/add",
+		Description: "Add files to the conversation, so that the next prompts can refer to them",
+		Hint:        "Ctrl+E browses the files to add to the conversation",
+		Args:        []Arg{{Name: "path", Required: true, Variadic: true}},
+		Handler: func(ctx *Context) Result {
+			return addFiles(ctx.Agent, ctx.Args)
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/context",
+		Description: "List the AGENTS.md files and notes in the system prompt, and the files in the conversation",
+		Handler: func(ctx *Context) Result {
+			return showContext(ctx.Config, ctx.Agent)
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/transcript",
+		Description: "List the tools the agent ran in this conversation, or show one call in full",
+		Args:        []Arg{{Name: "n"}},
+		Handler: func(ctx *Context) Result {
+			store, err := session.NewStore()
+			if err != nil {
+				return Failure{Err: err}
+			}
+			return showTranscript(store, ctx.Branches, ctx.Args)
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/stats",
+		Description: "Show usage statistics of the last 7 days, or the last 30 with /stats 30",
+		Args:        []Arg{{Name: "7|30"}},
+		Handler: func(ctx *Context) Result {
+			store, err := stats.NewStore()
+			if err != nil {
+				return Failure{Err: err}
+			}
+			return showStats(store, ctx.Args, time.Now())
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/help",
+		Description: "Show available commands",
+		Handler: func(ctx *Context) Result {
+			return showHelp(ctx.Registry, ctx.Mode)
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/clear",
+		Description: "Clear chat history",
+		Handler: func(ctx *Context) Result {
+			return clearChatHistory(ctx.ChatState)
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/history",
+		Description: "Browse past prompts to re-run, edit or delete them",
+		Hint:        "Try /history to re-run an earlier prompt",
+		Handler: func(ctx *Context) Result {
+			return showHistory(ctx.History)
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/clearhistory",
+		Description: "Clear command history",
+		Handler: func(ctx *Context) Result {
+			return clearCommandHistory(ctx.History)
+		},
+	})
+	r.MustRegister(Spec{
+		Name:        "/exit",
+		Aliases:     []string{"/quit"},
+		Description: "Exit the application",
+		Handler: func(ctx *Context) Result {
+			return Quit{}
+		},
+	})
+
+	return r
 }
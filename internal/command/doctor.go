@@ -0,0 +1,20 @@
+package command
+
+import (
+	"context"
+
+	"github.com/mizzy/rigel/internal/config"
+	"github.com/mizzy/rigel/internal/doctor"
+	"github.com/mizzy/rigel/internal/state"
+)
+
+// runDoctor checks the configuration, the current provider and the local
+// environment in the background
+func runDoctor(llmState *state.LLMState, cfg *config.Config) Result {
+	provider := llmState.GetCurrentProvider()
+	return Async{
+		Fn: func() Result {
+			return Response{Content: doctor.Run(context.Background(), cfg, provider).String()}
+		},
+	}
+}
@@ -0,0 +1,217 @@
+package command
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// fixContextLines is how many lines around each referenced line are
+	// given to the model
+	fixContextLines = 8
+
+	// maxFixReferences limits how many file:line references of the pasted
+	// output are pulled into the prompt
+	maxFixReferences = 10
+)
+
+// fixReferencePatterns match the file and line references of compiler,
+// linter and test output: file:line[:column] as reported by Go, gcc,
+// rustc, tsc --pretty and most linters, file(line,column) as reported by
+// tsc and MSBuild, and Python's File "file", line n
+var fixReferencePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`([\w./\\~-]*\w\.\w+):(\d+)(?::\d+)?`),
+	regexp.MustCompile(`([\w./\\~-]*\w\.\w+)\((\d+)(?:,\d+)?\)`),
+	regexp.MustCompile(`File "([^"]+)", line (\d+)`),
+}
+
+// fixReference is a line of a file that pasted output refers to
+type fixReference struct {
+	path string // Relative to the working directory
+	line int
+}
+
+// fixRegion is a range of lines of a file, counting from 1
+type fixRegion struct {
+	path       string
+	start, end int
+}
+
+// fixOutput asks the agent to fix the problems in pasted compiler or test
+// output, giving it the code around the lines the output refers to
+func fixOutput(output string) Result {
+	if strings.TrimSpace(output) == "" {
+		return Failure{Err: fmt.Errorf("usage: /fix <output>: paste the compiler or test output to fix")}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Fix the problem reported in the output below. Propose the fix as a unified diff with paths relative to the repository root, ")
+	sb.WriteString("which applies with `git apply`, then briefly explain it.\n\n")
+	sb.WriteString("Output:\n```\n" + strings.TrimRight(output, "\n") + "\n```\n")
+
+	regions := fixRegions(parseFixReferences(output, &fileFinder{}))
+	if len(regions) > 0 {
+		sb.WriteString("\nCode the output refers to, with line numbers:\n")
+	}
+	for _, region := range regions {
+		code, err := readLines(&region)
+		if err != nil || code == "" {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("\n%s (lines %d-%d):\n```\n%s```\n", filepath.ToSlash(region.path), region.start, region.end, code))
+	}
+	return Request{Prompt: sb.String()}
+}
+
+// parseFixReferences returns the references of output to lines of files
+// that exist, in order and without repetitions, at most maxFixReferences
+func parseFixReferences(output string, finder *fileFinder) []fixReference {
+	var refs []fixReference
+	seen := map[fixReference]bool{}
+	for _, line := range strings.Split(output, "\n") {
+		for _, pattern := range fixReferencePatterns {
+			for _, m := range pattern.FindAllStringSubmatch(line, -1) {
+				n, err := strconv.Atoi(m[2])
+				if err != nil || n < 1 {
+					continue
+				}
+				path, ok := finder.find(m[1])
+				if !ok {
+					continue
+				}
+				ref := fixReference{path: path, line: n}
+				if seen[ref] {
+					continue
+				}
+				seen[ref] = true
+				if refs = append(refs, ref); len(refs) == maxFixReferences {
+					return refs
+				}
+			}
+		}
+	}
+	return refs
+}
+
+// fixRegions returns the lines around the references, merging those that
+// overlap or touch: by file in the order the files were first referred to,
+// then by line
+func fixRegions(refs []fixReference) []fixRegion {
+	var files []string
+	byFile := map[string][]fixRegion{}
+	for _, ref := range refs {
+		if _, ok := byFile[ref.path]; !ok {
+			files = append(files, ref.path)
+		}
+		byFile[ref.path] = append(byFile[ref.path], fixRegion{path: ref.path, start: max(1, ref.line-fixContextLines), end: ref.line + fixContextLines})
+	}
+
+	var regions []fixRegion
+	for _, file := range files {
+		fileRegions := byFile[file]
+		slices.SortFunc(fileRegions, func(a, b fixRegion) int { return a.start - b.start })
+		for _, region := range fileRegions {
+			if last := len(regions) - 1; last >= 0 && regions[last].path == file && region.start <= regions[last].end+1 {
+				regions[last].end = max(regions[last].end, region.end)
+				continue
+			}
+			regions = append(regions, region)
+		}
+	}
+	return regions
+}
+
+// readLines returns the lines of a region, numbered, clipping the region
+// to the end of the file
+func readLines(region *fixRegion) (string, error) {
+	content, err := os.ReadFile(region.path)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	region.end = min(region.end, len(lines))
+
+	var sb strings.Builder
+	for n := region.start; n <= region.end; n++ {
+		fmt.Fprintf(&sb, "%6d %s\n", n, lines[n-1])
+	}
+	return sb.String(), nil
+}
+
+// fileFinder finds the files pasted output refers to, which tools report
+// relative to different directories: go test, for one, relative to the
+// package
+type fileFinder struct {
+	files []string // Below the working directory, listed once needed
+}
+
+// find returns the path relative to the working directory of a file output
+// refers to: the file itself if it exists, or else the only file below the
+// working directory whose path ends with it
+func (f *fileFinder) find(path string) (string, bool) {
+	path = filepath.Clean(filepath.FromSlash(path))
+	if filepath.IsAbs(path) {
+		// Compare real paths, e.g. where the temporary directory is a symlink
+		cwd, err := os.Getwd()
+		if err != nil {
+			return "", false
+		}
+		if real, err := filepath.EvalSymlinks(cwd); err == nil {
+			cwd = real
+		}
+		if real, err := filepath.EvalSymlinks(path); err == nil {
+			path = real
+		}
+		rel, err := filepath.Rel(cwd, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || !isFile(path) {
+			return "", false
+		}
+		return rel, true
+	}
+	if path == ".." || strings.HasPrefix(path, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	if isFile(path) {
+		return path, true
+	}
+
+	if f.files == nil {
+		f.files = []string{}
+		_ = filepath.WalkDir(".", func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				if name := d.Name(); p != "." && (strings.HasPrefix(name, ".") || name == "node_modules" || name == "vendor") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			f.files = append(f.files, p)
+			return nil
+		})
+	}
+	var match string
+	for _, file := range f.files {
+		if strings.HasSuffix(file, string(filepath.Separator)+path) {
+			if match != "" {
+				// Ambiguous
+				return "", false
+			}
+			match = file
+		}
+	}
+	return match, match != ""
+}
+
+// isFile reports whether path is a file, not a directory
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
@@ -0,0 +1,64 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFixOutput(t *testing.T) {
+	t.Chdir(t.TempDir())
+	var lines []string
+	for i := 1; i <= 40; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	code := strings.Join(lines, "\n") + "\n"
+	require.NoError(t, os.MkdirAll(filepath.Join("internal", "parse"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join("internal", "parse", "parse_test.go"), []byte(code), 0644))
+	require.NoError(t, os.WriteFile("main.go", []byte(code), 0644))
+
+	// go test reports paths relative to the package
+	output := `--- FAIL: TestParse (0.00s)
+    parse_test.go:12: expected "a", got "b"
+    parse_test.go:20: expected "c", got "d"
+FAIL
+./main.go:39:2: undefined: run
+see https://example.com:443 and missing.go:3`
+
+	prompt := as[Request](t, DefaultRegistry.Dispatch("/fix "+output, &Context{})).Prompt
+	assert.Contains(t, prompt, "Propose the fix as a unified diff")
+	assert.Contains(t, prompt, "Output:\n```\n"+output+"\n```\n")
+	assert.Contains(t, prompt, filepath.ToSlash(filepath.Join("internal", "parse", "parse_test.go"))+" (lines 4-28):\n```\n     4 line 4\n")
+	assert.Contains(t, prompt, "    28 line 28\n```\n")
+	assert.Contains(t, prompt, "main.go (lines 31-40):\n```\n    31 line 31\n")
+	assert.True(t, strings.HasSuffix(prompt, "    40 line 40\n```\n"))
+	assert.NotContains(t, prompt, "missing.go (")
+
+	as[Failure](t, DefaultRegistry.Dispatch("/fix", &Context{}))
+}
+
+func TestParseFixReferences(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	for _, file := range []string{"app.py", "src/view.ts", "a/util.go", "b/util.go"} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(file), 0755))
+		require.NoError(t, os.WriteFile(file, []byte("x\n"), 0644))
+	}
+
+	output := `Traceback (most recent call last):
+  File "app.py", line 7, in <module>
+src/view.ts(12,5): error TS2304: Cannot find name 'x'.
+util.go:3: ambiguous
+` + filepath.Join(dir, "app.py") + `:9 +0x1d
+app.py:7`
+	assert.Equal(t, []fixReference{
+		{path: "app.py", line: 7},
+		{path: filepath.Join("src", "view.ts"), line: 12},
+		{path: "app.py", line: 9},
+	}, parseFixReferences(output, &fileFinder{}))
+}
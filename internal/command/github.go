@@ -0,0 +1,230 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/mizzy/rigel/internal/agent"
+	"github.com/mizzy/rigel/internal/config"
+	"github.com/mizzy/rigel/internal/git"
+	"github.com/mizzy/rigel/internal/github"
+	"github.com/mizzy/rigel/internal/llm"
+	"github.com/mizzy/rigel/internal/quarantine"
+	"github.com/mizzy/rigel/internal/state"
+)
+
+const (
+	// githubRemote is the remote pull requests are opened from and issues
+	// looked up in
+	githubRemote = "origin"
+
+	// maxPullRequestDiffLength is how much of a branch's diff the model is
+	// given to describe a pull request
+	maxPullRequestDiffLength = 20000
+
+	// maxIssueCommentLength cuts off long comments pulled in with /issue
+	maxIssueCommentLength = 2000
+)
+
+// githubTokenMissing explains how to give rigel a GitHub token
+const githubTokenMissing = "No GitHub token configured. Set GITHUB_TOKEN or run `rigel auth login github`."
+
+// githubRepository returns a client for the GitHub API and the owner and
+// name of the repository of the origin remote
+func githubRepository(cfg *config.Config) (*github.Client, string, string, error) {
+	if cfg == nil || cfg.GitHubToken == "" {
+		return nil, "", "", errors.New(githubTokenMissing)
+	}
+	remote, err := git.RemoteURL(githubRemote)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to find the GitHub repository: %w", err)
+	}
+	owner, repo, err := github.ParseRemote(remote)
+	if err != nil {
+		return nil, "", "", err
+	}
+	return github.NewClient(cfg.GitHubAPIURL, cfg.GitHubToken), owner, repo, nil
+}
+
+// createPullRequest pushes the current branch and opens a pull request for
+// it, titled and described by the model from the branch's commits and diff
+func createPullRequest(llmState *state.LLMState, cfg *config.Config, base string, draft bool) Result {
+	client, owner, repo, err := githubRepository(cfg)
+	if err != nil {
+		return Response{Content: err.Error()}
+	}
+	branch := git.CurrentBranch()
+	if branch == "" {
+		return Response{Content: "Check out the branch to open a pull request for first."}
+	}
+	provider := llmState.GetCurrentProvider()
+	if provider == nil {
+		return Failure{Err: fmt.Errorf("no provider available")}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	progress := make(chan string, 16)
+	return Async{
+		Progress: progress,
+		Cancel:   cancel,
+		Fn: func() Result {
+			defer cancel()
+			defer close(progress)
+
+			if base == "" {
+				progress <- "Looking up the default branch..."
+				repository, err := client.Repository(ctx, owner, repo)
+				if err != nil {
+					return Failure{Err: err}
+				}
+				base = repository.DefaultBranch
+			}
+			if branch == base {
+				return Response{Content: fmt.Sprintf("You're on %s, the base branch. Create a branch for the pull request first.", base)}
+			}
+
+			// Compare with the remote's base branch, which the pull request
+			// will be merged into, if it has been fetched
+			baseRef := githubRemote + "/" + base
+			if !git.RefExists(baseRef) {
+				baseRef = base
+			}
+			commits, err := git.Log(baseRef)
+			if err != nil {
+				return Failure{Err: err}
+			}
+			if strings.TrimSpace(commits) == "" {
+				return Response{Content: fmt.Sprintf("%s has no commits that aren't on %s.", branch, base)}
+			}
+			diff, err := git.Diff(baseRef)
+			if err != nil {
+				return Failure{Err: err}
+			}
+
+			progress <- "Writing the title and description..."
+			title, body, err := describePullRequest(ctx, provider, commits, diff)
+			if errors.Is(err, context.Canceled) {
+				return Response{Content: "Pull request cancelled."}
+			}
+			if err != nil {
+				return Failure{Err: fmt.Errorf("failed to describe the pull request: %w", err)}
+			}
+
+			progress <- fmt.Sprintf("Pushing %s to %s...", branch, githubRemote)
+			if err := git.Push(ctx, githubRemote, branch); err != nil {
+				return Failure{Err: err}
+			}
+
+			progress <- "Opening the pull request..."
+			pr, err := client.CreatePullRequest(ctx, owner, repo, github.NewPullRequest{Title: title, Body: body, Head: branch, Base: base, Draft: draft})
+			if err != nil {
+				return Failure{Err: err}
+			}
+			return Response{Content: fmt.Sprintf("Opened pull request #%d: %s\n%s", pr.Number, pr.Title, pr.HTMLURL)}
+		},
+	}
+}
+
+// describePullRequest asks the model for the title and body of a pull
+// request from the messages of its commits and its diff
+func describePullRequest(ctx context.Context, provider llm.Provider, commits, diff string) (string, string, error) {
+	if len(diff) > maxPullRequestDiffLength {
+		diff = diff[:maxPullRequestDiffLength] + "\n... (diff truncated)"
+	}
+	prompt := fmt.Sprintf(`Write the title and description of a GitHub pull request with the commits and changes below.
+Reply with the title on the first line, at most 72 characters, then a blank line, then the description in Markdown: what the change does and why, and how it was tested if the commits say so.
+Don't wrap the reply in a code block.
+
+Commits:
+%s
+
+Diff:
+%s`, commits, diff)
+
+	reply, err := provider.Generate(ctx, prompt)
+	if err != nil {
+		return "", "", err
+	}
+	title, body, _ := strings.Cut(strings.TrimSpace(reply), "\n")
+	title = strings.Trim(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(title), "#")), "\"`*")
+	if title == "" {
+		return "", "", errors.New("the model didn't write a title")
+	}
+	return title, strings.TrimSpace(body), nil
+}
+
+// showIssue pulls a GitHub issue and its comments into the conversation, so
+// the next prompts can refer to it
+func showIssue(cfg *config.Config, ag *agent.Agent, number string) Result {
+	n, err := strconv.Atoi(strings.TrimPrefix(number, "#"))
+	if err != nil || n < 1 {
+		return Response{Content: fmt.Sprintf("Invalid issue number: %s", number)}
+	}
+	client, owner, repo, err := githubRepository(cfg)
+	if err != nil {
+		return Response{Content: err.Error()}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return Async{
+		Cancel: cancel,
+		Fn: func() Result {
+			defer cancel()
+
+			issue, err := client.Issue(ctx, owner, repo, n)
+			var apiErr *github.Error
+			if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+				return Response{Content: fmt.Sprintf("%s/%s has no issue #%d.", owner, repo, n)}
+			}
+			if err != nil {
+				return Failure{Err: err}
+			}
+			comments, err := client.IssueComments(ctx, owner, repo, n)
+			if err != nil {
+				return Failure{Err: err}
+			}
+
+			content := formatIssue(issue, comments)
+			// Command output isn't otherwise part of what the model
+			// remembers; anyone can write issues, so it is kept as data
+			if ag != nil {
+				ag.SetHistory(append(ag.History(),
+					agent.Message{Role: "user", Content: fmt.Sprintf("/issue %d", n)},
+					agent.Message{Role: "assistant", Content: quarantine.WrapFetched(fmt.Sprintf("issue #%d", n), content)},
+				))
+			}
+			if warning := quarantine.Warning(fmt.Sprintf("issue #%d", n), content); warning != "" {
+				content += "\n\n" + warning
+			}
+			return Response{Content: content}
+		},
+	}
+}
+
+// formatIssue renders an issue and its comments
+func formatIssue(issue *github.Issue, comments []github.Comment) string {
+	var sb strings.Builder
+	kind := "Issue"
+	if issue.PullRequest != nil {
+		kind = "Pull request"
+	}
+	fmt.Fprintf(&sb, "%s #%d: %s (%s, opened by %s)\n%s\n", kind, issue.Number, issue.Title, issue.State, issue.User.Login, issue.HTMLURL)
+	if len(issue.Labels) > 0 {
+		labels := make([]string, len(issue.Labels))
+		for i, label := range issue.Labels {
+			labels[i] = label.Name
+		}
+		fmt.Fprintf(&sb, "Labels: %s\n", strings.Join(labels, ", "))
+	}
+	if body := strings.TrimSpace(issue.Body); body != "" {
+		fmt.Fprintf(&sb, "\n%s\n", body)
+	}
+	for _, comment := range comments {
+		fmt.Fprintf(&sb, "\n%s commented:\n%s\n", comment.User.Login, truncate(strings.TrimSpace(comment.Body), maxIssueCommentLength))
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
@@ -1,9 +1,12 @@
 package command
 
 import (
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/mizzy/rigel/internal/config"
+	"github.com/mizzy/rigel/internal/history"
 	"github.com/mizzy/rigel/internal/state"
 )
 
@@ -15,46 +18,77 @@ func TestHandleCommand_SpaceHandling(t *testing.T) {
 	tests := []struct {
 		name     string
 		input    string
-		expected string // "request" for regular prompt, "response" for command
+		expected Result // Request for regular prompt, Response or Failure for command
 	}{
 		{
 			name:     "command without space",
 			input:    "/help",
-			expected: "response",
+			expected: Response{},
 		},
 		{
 			name:     "command with leading space",
 			input:    " /help",
-			expected: "request", // Should be treated as regular prompt
+			expected: Request{}, // Should be treated as regular prompt
 		},
 		{
 			name:     "command with multiple leading spaces",
 			input:    "  /help",
-			expected: "request", // Should be treated as regular prompt
+			expected: Request{}, // Should be treated as regular prompt
 		},
 		{
 			name:     "text containing slash",
 			input:    "hello/world",
-			expected: "request",
+			expected: Request{},
 		},
 		{
 			name:     "text with space before slash",
 			input:    "hello /world",
-			expected: "request",
+			expected: Request{},
 		},
 		{
 			name:     "valid command with trailing content",
 			input:    "/help me",
-			expected: "response", // Unknown command, but still command
+			expected: Failure{}, // Unknown command, but still command
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			result := HandleCommand(tt.input, llmState, chatState, cfg, nil, []string{})
-			if result.Type != tt.expected {
-				t.Errorf("HandleCommand(%q) = %q, want %q", tt.input, result.Type, tt.expected)
+			if reflect.TypeOf(result) != reflect.TypeOf(tt.expected) {
+				t.Errorf("HandleCommand(%q) = %T, want %T", tt.input, result, tt.expected)
 			}
 		})
 	}
 }
+
+func TestHandleCommand_History(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	manager, err := history.NewManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, input := range []string{"fix the tests", "/history", "/model llama3", "/history"} {
+		if err := manager.Add(input); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result := HandleCommand("/history", state.NewLLMState(), state.NewChatState(), &config.Config{}, manager, nil)
+	picker, ok := result.(ShowHistory)
+	if !ok {
+		t.Fatalf("HandleCommand(/history) = %+v, want the history picker", result)
+	}
+	var commands []string
+	for _, entry := range picker.Picker.Entries {
+		commands = append(commands, entry.Command)
+	}
+	if strings.Join(commands, ",") != "/model llama3,fix the tests" {
+		t.Errorf("entries = %v, want the prompts newest first without /history", commands)
+	}
+
+	result = HandleCommand("/history", state.NewLLMState(), state.NewChatState(), &config.Config{}, nil, nil)
+	if _, ok := result.(Failure); !ok {
+		t.Error("HandleCommand(/history) without a history = no error, want one")
+	}
+}
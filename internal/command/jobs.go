@@ -0,0 +1,146 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mizzy/rigel/internal/agent"
+	"github.com/mizzy/rigel/internal/config"
+)
+
+// backgroundSuffix ends a prompt to run in the background, as in a shell
+const backgroundSuffix = " &"
+
+// backgroundTask returns the task of a prompt ending in &
+func backgroundTask(input string) (string, bool) {
+	task, ok := strings.CutSuffix(strings.TrimSpace(input), backgroundSuffix)
+	task = strings.TrimSpace(task)
+	return task, ok && task != ""
+}
+
+// startJob runs a task in the background; while plans are reviewed, it
+// only reads
+func startJob(ag *agent.Agent, cfg *config.Config, task string) Result {
+	job := ag.StartJob(task, cfg == nil || cfg.ReviewPlans)
+	return Response{Content: fmt.Sprintf("Started job %d in the background. /jobs lists the jobs, /jobs attach %d follows this one.", job.ID, job.ID)}
+}
+
+// manageJobs lists the background jobs, follows the output of one, or
+// cancels one
+func manageJobs(ag *agent.Agent, args []string) Result {
+	if len(args) == 0 || args[0] == "list" {
+		return listJobs(ag)
+	}
+	action := args[0]
+	if action != "attach" && action != "cancel" {
+		return Response{Content: fmt.Sprintf("Unknown /jobs action %q. Use list, attach or cancel.", action)}
+	}
+	if len(args) < 2 {
+		return Response{Content: fmt.Sprintf("Usage: /jobs %s <n>", action)}
+	}
+	id, err := strconv.Atoi(args[1])
+	job, ok := ag.FindJob(id)
+	if err != nil || !ok {
+		return Response{Content: fmt.Sprintf("No job %s. /jobs lists the jobs.", args[1])}
+	}
+
+	if action == "cancel" {
+		if status, _ := job.Status(); status != agent.JobRunning {
+			return Response{Content: fmt.Sprintf("Job %d already %s.", job.ID, status)}
+		}
+		job.Cancel()
+		return Response{Content: fmt.Sprintf("Cancelling job %d.", job.ID)}
+	}
+	return attachJob(job)
+}
+
+// listJobs lists the background jobs with their status and progress
+func listJobs(ag *agent.Agent) Result {
+	jobs := ag.Jobs()
+	if len(jobs) == 0 {
+		return Response{Content: "No background jobs. End a prompt with & to run it in the background."}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Jobs:\n")
+	for _, job := range jobs {
+		status, activity := job.Status()
+		sb.WriteString(fmt.Sprintf("  %2d. %-9s %6s  %s\n", job.ID, status, job.Elapsed().Round(time.Second), job.Task))
+		if activity != "" {
+			sb.WriteString(fmt.Sprintf("      %s\n", activity))
+		}
+	}
+	sb.WriteString("\nUse /jobs attach <n> to follow a job's output, or /jobs cancel <n> to stop it.")
+	return Response{Content: sb.String()}
+}
+
+// attachJob follows a job's output as it streams until the job ends, or
+// shows its output if it ended already. Cancelling detaches from the job,
+// which keeps running.
+func attachJob(job *agent.Job) Result {
+	select {
+	case <-job.Done():
+		return Response{Content: jobResult(job)}
+	default:
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	progress := make(chan string, 16)
+	return Async{
+		Progress: progress,
+		Cancel:   cancel,
+		Fn: func() Result {
+			defer cancel()
+			defer close(progress)
+
+			for {
+				updated := job.Updated()
+				// Drop updates the UI hasn't caught up with rather than fall behind
+				select {
+				case progress <- jobProgress(job):
+				default:
+				}
+				select {
+				case <-job.Done():
+					return Response{Content: jobResult(job)}
+				case <-ctx.Done():
+					return Response{Content: fmt.Sprintf("Detached from job %d, which keeps running.", job.ID)}
+				case <-updated:
+				}
+			}
+		},
+	}
+}
+
+// jobProgress describes what a running job does: the tool it last used, or
+// the last line of its response so far
+func jobProgress(job *agent.Job) string {
+	_, activity := job.Status()
+	output := strings.TrimSpace(job.Output())
+	if i := strings.LastIndexByte(output, '\n'); i >= 0 {
+		output = output[i+1:]
+	}
+	switch {
+	case output != "":
+		return fmt.Sprintf("job %d: %s", job.ID, output)
+	case activity != "":
+		return fmt.Sprintf("job %d: %s", job.ID, activity)
+	}
+	return fmt.Sprintf("job %d: working on %s", job.ID, job.Task)
+}
+
+// jobResult describes how a job ended, with its response
+func jobResult(job *agent.Job) string {
+	status, _ := job.Status()
+	header := fmt.Sprintf("Job %d %s after %v: %s", job.ID, status, job.Elapsed().Round(time.Second), job.Task)
+	switch status {
+	case agent.JobFailed:
+		return fmt.Sprintf("%s\n\n%v", header, job.Err())
+	case agent.JobDone:
+		return header + "\n\n" + job.Output()
+	}
+	return header
+}
@@ -0,0 +1,95 @@
+package command
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mizzy/rigel/internal/agent"
+	"github.com/mizzy/rigel/internal/llm"
+	"github.com/mizzy/rigel/internal/state"
+)
+
+// jobProvider streams a fixed answer, or nothing until cancelled if block
+// is set
+type jobProvider struct {
+	llm.Provider
+	block bool
+}
+
+func (p *jobProvider) StreamWithHistory(ctx context.Context, messages []llm.Message, opts llm.GenerateOptions) (<-chan llm.StreamResponse, error) {
+	stream := make(chan llm.StreamResponse, 2)
+	if !p.block {
+		stream <- llm.StreamResponse{Content: "Renamed the package."}
+		stream <- llm.StreamResponse{Done: true}
+		close(stream)
+	}
+	return stream, nil
+}
+
+func TestBackgroundTask(t *testing.T) {
+	task, ok := backgroundTask("rename package X &")
+	assert.True(t, ok)
+	assert.Equal(t, "rename package X", task)
+
+	_, ok = backgroundTask("rename package X")
+	assert.False(t, ok)
+	_, ok = backgroundTask(" &")
+	assert.False(t, ok)
+	_, ok = backgroundTask("a&b")
+	assert.False(t, ok)
+}
+
+func TestManageJobs(t *testing.T) {
+	ag := agent.New(&jobProvider{})
+	ag.SetAutoToolEnabled(false)
+
+	assert.Equal(t, Response{Content: "No background jobs. End a prompt with & to run it in the background."}, manageJobs(ag, nil))
+
+	r := NewRegistry()
+	started := as[Response](t, r.Dispatch("rename package X &", &Context{Agent: ag, ChatState: state.NewChatState()}))
+	assert.Equal(t, "Started job 1 in the background. /jobs lists the jobs, /jobs attach 1 follows this one.", started.Content)
+	job, ok := ag.FindJob(1)
+	require.True(t, ok)
+	assert.Equal(t, "rename package X", job.Task)
+	<-job.Done()
+
+	list := as[Response](t, manageJobs(ag, []string{"list"}))
+	assert.Contains(t, list.Content, "   1. done")
+	assert.Contains(t, list.Content, "rename package X\n")
+
+	attached := as[Response](t, manageJobs(ag, []string{"attach", "1"}))
+	assert.Contains(t, attached.Content, "Job 1 done after ")
+	assert.Contains(t, attached.Content, "\n\nRenamed the package.")
+
+	assert.Equal(t, Response{Content: "Job 1 already done."}, manageJobs(ag, []string{"cancel", "1"}))
+	assert.Equal(t, Response{Content: "No job 2. /jobs lists the jobs."}, manageJobs(ag, []string{"attach", "2"}))
+	assert.Equal(t, Response{Content: "Usage: /jobs cancel <n>"}, manageJobs(ag, []string{"cancel"}))
+	assert.Equal(t, Response{Content: `Unknown /jobs action "stop". Use list, attach or cancel.`}, manageJobs(ag, []string{"stop", "1"}))
+}
+
+func TestAttachAndCancelJob(t *testing.T) {
+	ag := agent.New(&jobProvider{block: true})
+	ag.SetAutoToolEnabled(false)
+	job := ag.StartJob("watch the logs", false)
+
+	// Detaching leaves the job running
+	async := as[Async](t, manageJobs(ag, []string{"attach", "1"}))
+	async.Cancel()
+	assert.Equal(t, Response{Content: "Detached from job 1, which keeps running."}, async.Fn())
+	status, _ := job.Status()
+	assert.Equal(t, agent.JobRunning, status)
+
+	async = as[Async](t, manageJobs(ag, []string{"attach", "1"}))
+	results := make(chan Result)
+	go func() { results <- async.Fn() }()
+	assert.Equal(t, "job 1: working on watch the logs", <-async.Progress)
+
+	assert.Equal(t, Response{Content: "Cancelling job 1."}, manageJobs(ag, []string{"cancel", "1"}))
+	result := as[Response](t, <-results)
+	assert.Contains(t, result.Content, "Job 1 cancelled after ")
+	status, _ = job.Status()
+	assert.Equal(t, agent.JobCancelled, status)
+}
@@ -0,0 +1,59 @@
+package command
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mizzy/rigel/internal/notes"
+)
+
+// rememberNote adds a fact about the project to the notes included in the
+// system prompt of every session
+func rememberNote(note string) Result {
+	if err := notes.Add(note); err != nil {
+		return Failure{Err: fmt.Errorf("failed to remember: %w", err)}
+	}
+	return Response{
+		Content: fmt.Sprintf("Remembered in %s. Use /memories to list or delete notes.", notes.Path),
+	}
+}
+
+// manageMemories lists the project's notes, or deletes one with
+// /memories delete <n>
+func manageMemories(args []string) Result {
+	switch {
+	case len(args) == 0:
+		return listMemories()
+	case len(args) == 2 && (args[0] == "delete" || args[0] == "rm"):
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			return Failure{Err: fmt.Errorf("usage: /memories delete <n>")}
+		}
+		removed, err := notes.Remove(n)
+		if err != nil {
+			return Failure{Err: fmt.Errorf("failed to delete note: %w", err)}
+		}
+		return Response{Content: fmt.Sprintf("Forgot: %s", removed)}
+	default:
+		return Failure{Err: fmt.Errorf("usage: /memories [delete <n>]")}
+	}
+}
+
+func listMemories() Result {
+	loaded, err := notes.Load()
+	if err != nil {
+		return Failure{Err: err}
+	}
+	if len(loaded) == 0 {
+		return Response{Content: "No notes yet. Use /remember <fact> to add one."}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Notes in %s, included in every session:\n", notes.Path))
+	for i, note := range loaded {
+		sb.WriteString(fmt.Sprintf("  %d. %s\n", i+1, note))
+	}
+	sb.WriteString("\nUse /memories delete <n> to forget one.")
+	return Response{Content: sb.String()}
+}
@@ -0,0 +1,27 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRememberAndMemories(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	assert.Equal(t, Response{Content: "No notes yet. Use /remember <fact> to add one."}, manageMemories(nil))
+
+	as[Response](t, DefaultRegistry.Dispatch("/remember we use uber-fx for DI", &Context{}))
+	rememberNote("tests use testify")
+
+	list := as[Response](t, manageMemories(nil))
+	assert.Contains(t, list.Content, "1. we use uber-fx for DI")
+	assert.Contains(t, list.Content, "2. tests use testify")
+
+	assert.Equal(t, Response{Content: "Forgot: we use uber-fx for DI"}, manageMemories([]string{"delete", "1"}))
+	assert.NotContains(t, as[Response](t, manageMemories(nil)).Content, "uber-fx")
+
+	as[Failure](t, manageMemories([]string{"delete", "one"}))
+	as[Failure](t, manageMemories([]string{"delete", "5"}))
+	as[Failure](t, manageMemories([]string{"list"}))
+}
@@ -0,0 +1,166 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mizzy/rigel/internal/config"
+	"github.com/mizzy/rigel/internal/llm"
+	"github.com/mizzy/rigel/internal/state"
+)
+
+const ollamaRequired = "Model management requires the Ollama provider. Switch with /provider."
+
+// currentOllama returns the Ollama provider behind the current provider, if any
+func currentOllama(llmState *state.LLMState) (*llm.OllamaProvider, bool) {
+	return llm.As[*llm.OllamaProvider](llmState.GetCurrentProvider())
+}
+
+// pullModel downloads a model with Ollama, streaming a progress bar
+func pullModel(llmState *state.LLMState, name string) Result {
+	ollama, ok := currentOllama(llmState)
+	if !ok {
+		return Response{Content: ollamaRequired}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	progress := make(chan string, 16)
+
+	return Async{
+		Progress: progress,
+		Cancel:   cancel,
+		Fn: func() Result {
+			defer cancel()
+			defer close(progress)
+
+			start := time.Now()
+			err := ollama.Pull(ctx, name, func(p llm.PullProgress) {
+				// Drop updates the UI hasn't caught up with rather than stall the download
+				select {
+				case progress <- formatPullProgress(name, p):
+				default:
+				}
+			})
+			if errors.Is(err, context.Canceled) {
+				return Response{Content: fmt.Sprintf("Pull of %s cancelled.", name)}
+			}
+			if err != nil {
+				return Failure{Err: err}
+			}
+			return Response{
+				Content: fmt.Sprintf("Pulled %s in %v. Switch to it with /model %s", name, time.Since(start).Round(time.Second), name),
+			}
+		},
+	}
+}
+
+// formatPullProgress renders a pull status, with a progress bar while a
+// layer is downloading
+func formatPullProgress(name string, p llm.PullProgress) string {
+	percent := p.Percent()
+	if percent < 0 {
+		return fmt.Sprintf("pulling %s: %s", name, p.Status)
+	}
+
+	const width = 20
+	filled := percent * width / 100
+	return fmt.Sprintf("pulling %s: [%s%s] %3d%% (%s/%s)", name,
+		strings.Repeat("█", filled), strings.Repeat("░", width-filled), percent,
+		formatBytes(p.Completed), formatBytes(p.Total))
+}
+
+// showModelInfo shows Ollama's details for a model, defaulting to the current one
+func showModelInfo(llmState *state.LLMState, cfg *config.Config, name string) Result {
+	ollama, ok := currentOllama(llmState)
+	if !ok {
+		return Response{Content: ollamaRequired}
+	}
+	if name == "" {
+		name = llmState.GetCurrentModel().Name
+	}
+
+	ctx, cancel := llm.WithTimeout(context.Background(), connectTimeout(cfg))
+	defer cancel()
+
+	info, err := ollama.Show(ctx, name)
+	if err != nil {
+		return Failure{Err: fmt.Errorf("failed to show model %s: %w", name, err)}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Model: %s\n", info.Name))
+	writeField := func(label, value string) {
+		if value != "" {
+			sb.WriteString(fmt.Sprintf("  %s: %s\n", label, value))
+		}
+	}
+	writeField("Family", info.Details.Family)
+	writeField("Parameters", info.Details.ParameterSize)
+	writeField("Quantization", info.Details.QuantizationLevel)
+	writeField("Format", info.Details.Format)
+	if n := info.ContextLength(); n > 0 {
+		writeField("Context length", fmt.Sprintf("%d", n))
+	}
+	writeField("Capabilities", strings.Join(info.Capabilities, ", "))
+	writeField("Modified", info.ModifiedAt)
+	if params := strings.TrimSpace(info.Parameters); params != "" {
+		sb.WriteString("  Default parameters:\n")
+		for _, line := range strings.Split(params, "\n") {
+			sb.WriteString("    " + strings.Join(strings.Fields(line), " ") + "\n")
+		}
+	}
+
+	return Response{Content: strings.TrimRight(sb.String(), "\n")}
+}
+
+// listRunningModels shows the models Ollama has loaded in memory
+func listRunningModels(llmState *state.LLMState, cfg *config.Config) Result {
+	ollama, ok := currentOllama(llmState)
+	if !ok {
+		return Response{Content: ollamaRequired}
+	}
+
+	ctx, cancel := llm.WithTimeout(context.Background(), connectTimeout(cfg))
+	defer cancel()
+
+	models, err := ollama.Running(ctx)
+	if err != nil {
+		return Failure{Err: fmt.Errorf("failed to list running models: %w", err)}
+	}
+	if len(models) == 0 {
+		return Response{Content: "No models are loaded."}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Running models:\n")
+	for _, m := range models {
+		sb.WriteString(fmt.Sprintf("  %s  %s", m.Name, formatBytes(m.Size)))
+		if m.Size > 0 {
+			sb.WriteString(fmt.Sprintf(" (%d%% GPU)", m.SizeVRAM*100/m.Size))
+		}
+		if expires, err := time.Parse(time.RFC3339Nano, m.ExpiresAt); err == nil {
+			sb.WriteString(fmt.Sprintf(", unloads in %v", time.Until(expires).Round(time.Second)))
+		}
+		sb.WriteString("\n")
+	}
+	return Response{Content: strings.TrimRight(sb.String(), "\n")}
+}
+
+// formatBytes renders a size in B, KB, MB or GB
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, suffix := float64(n)/unit, "KB"
+	for _, s := range []string{"MB", "GB", "TB"} {
+		if value < unit {
+			break
+		}
+		value, suffix = value/unit, s
+	}
+	return fmt.Sprintf("%.1f %s", value, suffix)
+}
@@ -0,0 +1,137 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mizzy/rigel/internal/config"
+	"github.com/mizzy/rigel/internal/llm"
+	"github.com/mizzy/rigel/internal/state"
+)
+
+func TestFormatPullProgress(t *testing.T) {
+	assert.Equal(t, "pulling llama3.2: pulling manifest",
+		formatPullProgress("llama3.2", llm.PullProgress{Status: "pulling manifest"}))
+	assert.Equal(t, "pulling llama3.2: [█████░░░░░░░░░░░░░░░]  25% (512.0 MB/2.0 GB)",
+		formatPullProgress("llama3.2", llm.PullProgress{Total: 2 << 30, Completed: 512 << 20}))
+}
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "512 B", formatBytes(512))
+	assert.Equal(t, "1.5 KB", formatBytes(1536))
+	assert.Equal(t, "2.0 GB", formatBytes(2<<30))
+}
+
+func TestOllamaCommandsRequireOllama(t *testing.T) {
+	llmState := state.NewLLMState()
+	llmState.SetCurrentProvider(&echoProvider{})
+
+	for _, cmd := range []string{"/pull llama3.2", "/show", "/ps"} {
+		result := HandleCommand(cmd, llmState, state.NewChatState(), nil, nil, nil)
+		assert.Equal(t, Response{Content: ollamaRequired}, result, cmd)
+	}
+}
+
+func TestSetOption(t *testing.T) {
+	provider, err := llm.NewOllamaProvider("", "llama3.2")
+	require.NoError(t, err)
+	llmState := state.NewLLMState()
+	llmState.SetCurrentProvider(provider)
+	cfg := &config.Config{}
+
+	result := as[Response](t, setOption(llmState, cfg, []string{"num_ctx", "8192"}))
+	assert.Equal(t, "Set num_ctx to 8192.", result.Content)
+	result = as[Response](t, setOption(llmState, cfg, []string{"keep_alive", "-1"}))
+	assert.Equal(t, "Set keep_alive to -1.", result.Content)
+	assert.Equal(t, 8192, provider.Defaults().NumCtx)
+	assert.Equal(t, "-1", provider.Defaults().KeepAlive)
+	assert.Equal(t, 8192, cfg.OllamaNumCtx)
+
+	result = as[Response](t, setOption(llmState, cfg, []string{"top_p", "2"}))
+	assert.Contains(t, result.Content, "Invalid value for top_p")
+	assert.Zero(t, provider.Defaults().TopP)
+
+	result = as[Response](t, setOption(llmState, cfg, []string{"num_ctx", "default"}))
+	assert.Equal(t, "Set num_ctx to default.", result.Content)
+	assert.Zero(t, cfg.OllamaNumCtx)
+
+	result = as[Response](t, setOption(llmState, cfg, nil))
+	assert.Contains(t, result.Content, "keep_alive  -1")
+
+	result = as[Response](t, setOption(llmState, cfg, []string{"temperature", "1"}))
+	assert.Contains(t, result.Content, "Unknown option")
+
+	llmState.SetCurrentProvider(&echoProvider{})
+	result = as[Response](t, setOption(llmState, cfg, nil))
+	assert.Contains(t, result.Content, "Ollama provider")
+}
+
+func TestSetEditingMode(t *testing.T) {
+	llmState := state.NewLLMState()
+	llmState.SetCurrentProvider(&echoProvider{})
+	cfg := &config.Config{}
+
+	result := as[Response](t, setOption(llmState, cfg, nil))
+	assert.Contains(t, result.Content, "editing-mode   emacs")
+
+	result = as[Response](t, setOption(llmState, cfg, []string{"editing-mode", "vi"}))
+	assert.Equal(t, "Set editing-mode to vi.", result.Content)
+	assert.Equal(t, config.EditingModeVi, cfg.EditingMode)
+
+	result = as[Response](t, setOption(llmState, cfg, []string{"editing-mode", "nano"}))
+	assert.Contains(t, result.Content, "Invalid value for editing-mode")
+	assert.Equal(t, config.EditingModeVi, cfg.EditingMode)
+
+	result = as[Response](t, setOption(llmState, cfg, []string{"editing-mode"}))
+	assert.Equal(t, "editing-mode = vi", result.Content)
+}
+
+func TestSetStatusBar(t *testing.T) {
+	llmState := state.NewLLMState()
+	llmState.SetCurrentProvider(&echoProvider{})
+	cfg := &config.Config{}
+
+	result := as[Response](t, setOption(llmState, cfg, []string{"status-bar", "on"}))
+	assert.Equal(t, "Set status-bar to on.", result.Content)
+	assert.True(t, cfg.StatusBar)
+
+	result = as[Response](t, setOption(llmState, cfg, []string{"status-bar", "maybe"}))
+	assert.Contains(t, result.Content, "Invalid value for status-bar")
+	assert.True(t, cfg.StatusBar)
+}
+
+func TestSetShowMeta(t *testing.T) {
+	llmState := state.NewLLMState()
+	llmState.SetCurrentProvider(&echoProvider{})
+	cfg := &config.Config{}
+
+	result := as[Response](t, setOption(llmState, cfg, []string{"show-meta", "on"}))
+	assert.Equal(t, "Set show-meta to on.", result.Content)
+	assert.True(t, cfg.ShowMeta)
+
+	result = as[Response](t, setOption(llmState, cfg, []string{"show-meta", "yes"}))
+	assert.Contains(t, result.Content, "Invalid value for show-meta")
+	assert.True(t, cfg.ShowMeta)
+}
+
+func TestSetDeterministic(t *testing.T) {
+	provider := llm.NewDeterministicProvider(&echoProvider{}, false, 0)
+	llmState := state.NewLLMState()
+	llmState.SetCurrentProvider(provider)
+	cfg := &config.Config{}
+
+	result := as[Response](t, setOption(llmState, cfg, []string{"deterministic", "on"}))
+	assert.Equal(t, "Set deterministic to on.", result.Content)
+	assert.True(t, cfg.Deterministic, "providers created later are deterministic too")
+	assert.True(t, provider.IsDeterministic())
+
+	result = as[Response](t, setOption(llmState, cfg, []string{"deterministic", "always"}))
+	assert.Contains(t, result.Content, "Invalid value for deterministic")
+	assert.True(t, provider.IsDeterministic())
+
+	result = as[Response](t, setOption(llmState, cfg, []string{"deterministic", "off"}))
+	assert.Equal(t, "Set deterministic to off.", result.Content)
+	assert.False(t, provider.IsDeterministic())
+}
@@ -0,0 +1,56 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mizzy/rigel/internal/agent"
+	"github.com/mizzy/rigel/internal/paste"
+	"github.com/mizzy/rigel/internal/state"
+)
+
+// clipboardTimeout limits how long reading an image from the clipboard may
+// take, which over SSH goes through the terminal
+const clipboardTimeout = 5 * time.Second
+
+// pasteImage attaches the image in the clipboard, or the image file at
+// path, to the next prompt, downscaled to fit the limits of vision models
+func pasteImage(llmState *state.LLMState, ag *agent.Agent, path string) Result {
+	if ag == nil {
+		return Failure{Err: errors.New("no agent to attach the image to")}
+	}
+
+	var data []byte
+	var err error
+	if path != "" {
+		data, err = paste.FromFile(path)
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), clipboardTimeout)
+		defer cancel()
+		data, err = paste.FromClipboard(ctx)
+	}
+	if err != nil {
+		return Failure{Err: err}
+	}
+	attachment, err := paste.Fit(data)
+	if err != nil {
+		return Failure{Err: err}
+	}
+	ag.AttachImages(attachment.Image)
+
+	content := fmt.Sprintf("Attached an image (%s) to your next prompt.", attachment)
+	if n := ag.AttachedImages(); n > 1 {
+		content = fmt.Sprintf("Attached an image (%s) to your next prompt, which now has %d.", attachment, n)
+	}
+	if llmState != nil {
+		// Models without a known context window weren't described, so
+		// whether they support vision is unknown
+		model := llmState.GetCurrentModel()
+		if d := model.Details; d.ContextWindow > 0 && !d.SupportsVision {
+			content += fmt.Sprintf("\n%s doesn't support images; switch to a vision model with /model before sending the prompt.", model.Name)
+		}
+	}
+	return Response{Content: content}
+}
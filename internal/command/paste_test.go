@@ -0,0 +1,40 @@
+package command
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mizzy/rigel/internal/agent"
+	"github.com/mizzy/rigel/internal/llm"
+	"github.com/mizzy/rigel/internal/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasteImage(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 640, 480))))
+	path := filepath.Join(t.TempDir(), "screenshot.png")
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+
+	assert.EqualError(t, as[Failure](t, pasteImage(nil, nil, path)).Err, "no agent to attach the image to")
+
+	ag := agent.New(nil)
+	llmState := state.NewLLMState()
+	llmState.SetCurrentModel(llm.Model{Name: "llava", Details: llm.ModelDetails{ContextWindow: 4096, SupportsVision: true}})
+	assert.Regexp(t, `^Attached an image \(640×480 PNG, \d+ KB\) to your next prompt\.$`, as[Response](t, pasteImage(llmState, ag, path)).Content)
+	assert.Equal(t, 1, ag.AttachedImages())
+
+	llmState.SetCurrentModel(llm.Model{Name: "llama3.2", Details: llm.ModelDetails{ContextWindow: 4096}})
+	content := as[Response](t, pasteImage(llmState, ag, path)).Content
+	assert.Contains(t, content, "to your next prompt, which now has 2.")
+	assert.Contains(t, content, "\nllama3.2 doesn't support images")
+	assert.Equal(t, 2, ag.AttachedImages())
+
+	as[Failure](t, pasteImage(llmState, ag, filepath.Join(t.TempDir(), "missing.png")))
+	assert.Equal(t, 2, ag.AttachedImages())
+}
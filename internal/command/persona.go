@@ -0,0 +1,69 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mizzy/rigel/internal/agent"
+	"github.com/mizzy/rigel/internal/persona"
+)
+
+// managePersona lists the personas, or switches the agent to one and
+// remembers it for the project
+func managePersona(ag *agent.Agent, args []string) Result {
+	if ag == nil {
+		return Response{Content: "Personas are not available in this session."}
+	}
+	switch len(args) {
+	case 0:
+		return listPersonas(ag)
+	case 1:
+		return switchPersona(ag, args[0])
+	default:
+		return Failure{Err: fmt.Errorf("usage: /persona [name]")}
+	}
+}
+
+func listPersonas(ag *agent.Agent) Result {
+	personas, err := persona.Load()
+	if err != nil {
+		return Failure{Err: err}
+	}
+	current := personaName(ag)
+
+	var sb strings.Builder
+	sb.WriteString("Available personas:\n\n")
+	for _, name := range persona.Names(personas) {
+		marker := "  "
+		if name == current {
+			marker = "* "
+		}
+		sb.WriteString(fmt.Sprintf("  %s%s", marker, name))
+		if description := personas[name].Description; description != "" {
+			sb.WriteString(" - " + description)
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString(fmt.Sprintf("\nPersonas are defined in ~/.rigel/personas.yaml and %s", persona.ProjectFile))
+	return Response{Content: sb.String()}
+}
+
+func switchPersona(ag *agent.Agent, name string) Result {
+	p, err := persona.Find(name)
+	if err != nil {
+		return Failure{Err: err}
+	}
+	ag.SetPersona(p)
+	if err := persona.Save(name); err != nil {
+		return Failure{Err: fmt.Errorf("switched to persona %s, but couldn't remember it: %w", name, err)}
+	}
+	return Response{Content: fmt.Sprintf("Switched to persona: %s", name)}
+}
+
+// personaName returns the name of the agent's persona
+func personaName(ag *agent.Agent) string {
+	if ag == nil || ag.Persona() == nil {
+		return persona.Default
+	}
+	return ag.Persona().Name
+}
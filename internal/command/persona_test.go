@@ -0,0 +1,34 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mizzy/rigel/internal/agent"
+	"github.com/mizzy/rigel/internal/persona"
+)
+
+func TestManagePersona(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Chdir(t.TempDir())
+	ag := agent.New(nil)
+
+	list := as[Response](t, managePersona(ag, nil))
+	assert.Contains(t, list.Content, "  * default - rigel's usual behavior\n")
+	assert.Contains(t, list.Content, "    strict-reviewer - Direct, critical code review\n")
+
+	assert.Equal(t, Response{Content: "Switched to persona: strict-reviewer"}, managePersona(ag, []string{"strict-reviewer"}))
+	assert.Equal(t, "strict-reviewer", ag.Persona().Name)
+	saved, err := persona.Saved()
+	require.NoError(t, err)
+	assert.Equal(t, "strict-reviewer", saved)
+	assert.Contains(t, as[Response](t, managePersona(ag, nil)).Content, "  * strict-reviewer")
+
+	failure := as[Failure](t, managePersona(ag, []string{"pirate"}))
+	assert.ErrorContains(t, failure.Err, "unknown persona: pirate")
+	assert.Equal(t, "strict-reviewer", ag.Persona().Name)
+
+	as[Failure](t, managePersona(ag, []string{"a", "b"}))
+}
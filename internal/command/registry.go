@@ -0,0 +1,312 @@
+package command
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/mizzy/rigel/internal/agent"
+	"github.com/mizzy/rigel/internal/config"
+	"github.com/mizzy/rigel/internal/history"
+	"github.com/mizzy/rigel/internal/state"
+	"github.com/mizzy/rigel/internal/workspace"
+)
+
+// UI modes a command can be restricted to
+const (
+	ModeBubbletea = "bubbletea"
+	ModeTermflow  = "termflow"
+	ModePlain     = "plain"
+)
+
+// Arg describes a positional argument accepted by a command
+type Arg struct {
+	Name     string
+	Required bool
+	Variadic bool // Last argument only: accepts all remaining words
+}
+
+// Context carries everything a command handler may need
+type Context struct {
+	Args         []string
+	Flags        map[string]string
+	LLMState     *state.LLMState
+	ChatState    *state.ChatState
+	Branches     *state.BranchState
+	Config       *config.Config
+	History      *history.Manager
+	InputHistory []string
+	Mode         string
+	Registry     *Registry
+	Workspace    *workspace.Workspace
+	Agent        *agent.Agent // Whose memory is saved with branches
+}
+
+// HandlerFunc executes a command
+type HandlerFunc func(ctx *Context) Result
+
+// Spec describes a slash command
+type Spec struct {
+	Name        string   // Command name including the leading slash
+	Aliases     []string // Alternative names, also with leading slash
+	Description string
+	Args        []Arg
+	Flags       []Flag
+	Modes       []string // UI modes the command is available in; empty means all
+	Hint        string   // Tip shown in the empty input, e.g. "Try /init to analyze this repo"
+	// Raw passes the text after the name to the handler as it was typed, as
+	// a single argument without flags, e.g. pasted output whose quotes
+	// don't group words
+	Raw     bool
+	Handler HandlerFunc
+}
+
+// Usage returns the command name followed by its argument placeholders
+func (s *Spec) Usage() string {
+	usage := s.Name
+	for _, arg := range s.Args {
+		name := arg.Name
+		if arg.Variadic {
+			name += "..."
+		}
+		if arg.Required {
+			usage += " <" + name + ">"
+		} else {
+			usage += " [" + name + "]"
+		}
+	}
+	for _, flag := range s.Flags {
+		if flag.HasValue {
+			usage += " [--" + flag.Name + " <value>]"
+		} else {
+			usage += " [--" + flag.Name + "]"
+		}
+	}
+	return usage
+}
+
+// AvailableIn reports whether the command can be used in the given UI mode.
+// An empty mode matches every command.
+func (s *Spec) AvailableIn(mode string) bool {
+	if mode == "" || len(s.Modes) == 0 {
+		return true
+	}
+	for _, m := range s.Modes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// Registry holds slash command definitions
+type Registry struct {
+	mu    sync.RWMutex
+	specs []*Spec
+	index map[string]*Spec
+}
+
+// NewRegistry creates an empty command registry
+func NewRegistry() *Registry {
+	return &Registry{
+		index: make(map[string]*Spec),
+	}
+}
+
+// Register adds a command to the registry
+func (r *Registry) Register(spec Spec) error {
+	if !strings.HasPrefix(spec.Name, "/") {
+		return fmt.Errorf("command name must start with /: %q", spec.Name)
+	}
+	if spec.Handler == nil {
+		return fmt.Errorf("command %s has no handler", spec.Name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := append([]string{spec.Name}, spec.Aliases...)
+	for _, name := range names {
+		if _, exists := r.index[name]; exists {
+			return fmt.Errorf("command already registered: %s", name)
+		}
+	}
+
+	s := spec
+	r.specs = append(r.specs, &s)
+	for _, name := range names {
+		r.index[name] = &s
+	}
+	return nil
+}
+
+// MustRegister is like Register but panics on error
+func (r *Registry) MustRegister(spec Spec) {
+	if err := r.Register(spec); err != nil {
+		panic(err)
+	}
+}
+
+// Unregister removes a command and its aliases
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	spec, ok := r.index[name]
+	if !ok {
+		return
+	}
+	delete(r.index, spec.Name)
+	for _, alias := range spec.Aliases {
+		delete(r.index, alias)
+	}
+	for i, s := range r.specs {
+		if s == spec {
+			r.specs = append(r.specs[:i], r.specs[i+1:]...)
+			break
+		}
+	}
+}
+
+// Lookup finds a command by name or alias
+func (r *Registry) Lookup(name string) (*Spec, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	spec, ok := r.index[name]
+	return spec, ok
+}
+
+// Specs returns the commands available in the given UI mode, in registration order
+func (r *Registry) Specs(mode string) []*Spec {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	specs := make([]*Spec, 0, len(r.specs))
+	for _, s := range r.specs {
+		if s.AvailableIn(mode) {
+			specs = append(specs, s)
+		}
+	}
+	return specs
+}
+
+// Commands returns completion entries for the given UI mode. Aliases are
+// listed as separate entries so they can be completed too.
+func (r *Registry) Commands(mode string) []Command {
+	var commands []Command
+	for _, s := range r.Specs(mode) {
+		commands = append(commands, Command{s.Name, s.Description})
+		for _, alias := range s.Aliases {
+			commands = append(commands, Command{alias, s.Description})
+		}
+	}
+	return commands
+}
+
+// Hints returns the tips of the commands available in the given UI mode,
+// in registration order
+func (r *Registry) Hints(mode string) []string {
+	var hints []string
+	for _, s := range r.Specs(mode) {
+		if s.Hint != "" {
+			hints = append(hints, s.Hint)
+		}
+	}
+	return hints
+}
+
+// Names returns all registered names and aliases, sorted
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.index))
+	for name := range r.index {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Dispatch parses a slash command line and runs the matching handler.
+// Input that doesn't start with / is returned as a Request for the LLM.
+func (r *Registry) Dispatch(input string, ctx *Context) Result {
+	// Only treat as command if it starts with / without any leading whitespace
+	if !strings.HasPrefix(input, "/") {
+		// A prompt ending in & runs in the background
+		if task, ok := backgroundTask(input); ok && ctx.Agent != nil {
+			return startJob(ctx.Agent, ctx.Config, task)
+		}
+		return Request{
+			Prompt: input,
+		}
+	}
+
+	name, rest := input, ""
+	if i := strings.IndexFunc(input, unicode.IsSpace); i >= 0 {
+		name, rest = input[:i], strings.TrimSpace(input[i:])
+	}
+	if spec, ok := r.Lookup(name); ok && spec.Raw && spec.AvailableIn(ctx.Mode) {
+		args := []string{}
+		if rest != "" {
+			args = append(args, rest)
+		}
+		return r.run(spec, args, map[string]string{}, ctx)
+	}
+
+	words, err := Tokenize(input)
+	if err != nil {
+		return Failure{
+			Err: err,
+		}
+	}
+	spec, ok := r.Lookup(words[0])
+	if !ok || !spec.AvailableIn(ctx.Mode) {
+		return Failure{
+			Err: fmt.Errorf("unknown command: %s, type /help for available commands", strings.TrimSpace(input)),
+		}
+	}
+
+	args, flags, err := parseArgs(spec, words[1:])
+	if err != nil {
+		return Failure{
+			Err: fmt.Errorf("%w\nusage: %s", err, spec.Usage()),
+		}
+	}
+	return r.run(spec, args, flags, ctx)
+}
+
+// run checks the arguments of a command and runs its handler
+func (r *Registry) run(spec *Spec, args []string, flags map[string]string, ctx *Context) Result {
+	if err := checkArgs(spec, args); err != nil {
+		return Failure{
+			Err: err,
+		}
+	}
+
+	ctx.Args = args
+	ctx.Flags = flags
+	if ctx.Registry == nil {
+		ctx.Registry = r
+	}
+	return spec.Handler(ctx)
+}
+
+// checkArgs validates the argument count against the command's spec
+func checkArgs(spec *Spec, args []string) error {
+	required := 0
+	for _, arg := range spec.Args {
+		if arg.Required {
+			required++
+		}
+	}
+
+	variadic := len(spec.Args) > 0 && spec.Args[len(spec.Args)-1].Variadic
+	if len(args) < required || (len(args) > len(spec.Args) && !variadic) {
+		return fmt.Errorf("usage: %s", spec.Usage())
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// as requires a command result to be of type T and returns it
+func as[T Result](t *testing.T, result Result) T {
+	t.Helper()
+	typed, ok := result.(T)
+	require.Truef(t, ok, "got %T: %+v", result, result)
+	return typed
+}
+
+func TestRegistry_RegisterAndDispatch(t *testing.T) {
+	r := NewRegistry()
+	require.NoError(t, r.Register(Spec{
+		Name:        "/greet",
+		Aliases:     []string{"/hi"},
+		Description: "Say hello",
+		Args:        []Arg{{Name: "name", Required: true}},
+		Handler: func(ctx *Context) Result {
+			return Response{Content: "hello " + ctx.Args[0]}
+		},
+	}))
+
+	tests := []struct {
+		name      string
+		input     string
+		want      Result
+		wantError bool
+	}{
+		{name: "by name", input: "/greet bob", want: Response{Content: "hello bob"}},
+		{name: "by alias", input: "/hi alice", want: Response{Content: "hello alice"}},
+		{name: "missing arg", input: "/greet", wantError: true},
+		{name: "too many args", input: "/greet a b", wantError: true},
+		{name: "unknown", input: "/nope", wantError: true},
+		{name: "prompt", input: "hello", want: Request{Prompt: "hello"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := r.Dispatch(tt.input, &Context{})
+			if tt.wantError {
+				as[Failure](t, result)
+				return
+			}
+			assert.Equal(t, tt.want, result)
+		})
+	}
+}
+
+func TestRegistry_RawArgs(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister(Spec{
+		Name:    "/echo",
+		Args:    []Arg{{Name: "text", Required: true}},
+		Raw:     true,
+		Handler: func(ctx *Context) Result { return Response{Content: ctx.Args[0]} },
+	})
+
+	assert.Equal(t, Response{Content: "main.go:3: can't use \"x\" --as a value\n\tmore"},
+		r.Dispatch("/echo main.go:3: can't use \"x\" --as a value\n\tmore\n", &Context{}))
+	assert.Equal(t, Response{Content: "first line"}, r.Dispatch("/echo\nfirst line", &Context{}))
+	as[Failure](t, r.Dispatch("/echo  ", &Context{}))
+}
+
+func TestRegistry_DuplicateAndUnregister(t *testing.T) {
+	r := NewRegistry()
+	noop := func(ctx *Context) Result { return Response{} }
+
+	require.NoError(t, r.Register(Spec{Name: "/a", Aliases: []string{"/b"}, Handler: noop}))
+	assert.Error(t, r.Register(Spec{Name: "/b", Handler: noop}))
+	assert.Error(t, r.Register(Spec{Name: "c", Handler: noop}))
+	assert.Error(t, r.Register(Spec{Name: "/c"}))
+
+	r.Unregister("/b")
+	_, ok := r.Lookup("/a")
+	assert.False(t, ok)
+	assert.Empty(t, r.Names())
+}
+
+func TestRegistry_Modes(t *testing.T) {
+	r := NewRegistry()
+	noop := func(ctx *Context) Result { return Response{} }
+	r.MustRegister(Spec{Name: "/everywhere", Handler: noop})
+	r.MustRegister(Spec{Name: "/tf", Modes: []string{ModeTermflow}, Handler: noop})
+
+	assert.Len(t, r.Commands(ModeBubbletea), 1)
+	assert.Len(t, r.Commands(ModeTermflow), 2)
+	assert.Len(t, r.Commands(""), 2)
+
+	as[Failure](t, r.Dispatch("/tf", &Context{Mode: ModeBubbletea}))
+	as[Response](t, r.Dispatch("/tf", &Context{Mode: ModeTermflow}))
+}
+
+func TestRegistry_Hints(t *testing.T) {
+	r := NewRegistry()
+	noop := func(ctx *Context) Result { return Response{} }
+	r.MustRegister(Spec{Name: "/a", Hint: "Try /a", Handler: noop})
+	r.MustRegister(Spec{Name: "/b", Handler: noop})
+	r.MustRegister(Spec{Name: "/tf", Hint: "Try /tf", Modes: []string{ModeTermflow}, Handler: noop})
+
+	assert.Equal(t, []string{"Try /a"}, r.Hints(ModeBubbletea))
+	assert.Equal(t, []string{"Try /a", "Try /tf"}, r.Hints(ModeTermflow))
+}
+
+func TestDefaultRegistry_Help(t *testing.T) {
+	help := as[Response](t, HandleCommand("/help", nil, nil, nil, nil, nil)).Content
+	assert.Contains(t, help, "/exit, /quit - Exit the application")
+	assert.Contains(t, help, "/init [--force] - Analyze repository")
+}
+
+func TestDefaultRegistry_HelpPerInterface(t *testing.T) {
+	help := as[Response](t, DefaultRegistry.Dispatch("/help", &Context{Mode: ModeTermflow})).Content
+	assert.Contains(t, help, "  Ctrl+J        - New line\n")
+	assert.NotContains(t, help, "Alt+Enter")
+	assert.NotContains(t, help, "/theme")
+	assert.Contains(t, help, "  termflow - Inline chat that keeps the terminal's scrollback, with a status bar (current)\n")
+	assert.Contains(t, help, "  tui      - Sidebar")
+
+	help = as[Response](t, DefaultRegistry.Dispatch("/help", &Context{Mode: ModeBubbletea})).Content
+	assert.Contains(t, help, "  Alt+Enter     - New line\n")
+	assert.Contains(t, help, "/theme")
+	assert.Contains(t, help, "themes and mouse support (current)\n")
+}
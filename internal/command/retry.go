@@ -0,0 +1,68 @@
+package command
+
+import (
+	"strings"
+
+	"github.com/mizzy/rigel/internal/agent"
+	"github.com/mizzy/rigel/internal/config"
+	"github.com/mizzy/rigel/internal/state"
+)
+
+// lastPrompt returns the most recent prompt sent to the LLM, skipping
+// slash commands
+func lastPrompt(chatState *state.ChatState) (string, bool) {
+	history := chatState.GetHistory()
+	for i := len(history) - 1; i >= 0; i-- {
+		if !strings.HasPrefix(history[i].Prompt, "/") {
+			return history[i].Prompt, true
+		}
+	}
+	return "", false
+}
+
+// retryLastPrompt re-sends the last prompt, optionally after switching to
+// another model. The frontend replaces the superseded exchange.
+func retryLastPrompt(llmState *state.LLMState, chatState *state.ChatState, cfg *config.Config, model string) Result {
+	prompt, ok := lastPrompt(chatState)
+	if !ok {
+		return Response{Content: "No previous prompt to retry."}
+	}
+
+	notice := ""
+	if model != "" {
+		switched := switchModel(llmState, cfg, model)
+		response, ok := switched.(Response)
+		if !ok {
+			return switched
+		}
+		notice = response.Content
+	}
+
+	return Retry{
+		Prompt: prompt,
+		Notice: notice,
+	}
+}
+
+// editLastPrompt returns the last prompt so the frontend can put it back in
+// the input box and drop the superseded exchange
+func editLastPrompt(chatState *state.ChatState) Result {
+	prompt, ok := lastPrompt(chatState)
+	if !ok {
+		return Response{Content: "No previous prompt to edit."}
+	}
+	return EditLast{Prompt: prompt}
+}
+
+// continueResponse asks the model to finish the last response if it was
+// interrupted
+func continueResponse(ag *agent.Agent) Result {
+	if ag == nil {
+		return Response{Content: "Nothing to continue."}
+	}
+	history := ag.History()
+	if len(history) == 0 || history[len(history)-1].Role != "assistant" || !agent.IsInterrupted(history[len(history)-1].Content) {
+		return Response{Content: "Nothing to continue: the last response wasn't interrupted."}
+	}
+	return Request{Prompt: agent.ContinuePrompt}
+}
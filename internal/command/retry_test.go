@@ -0,0 +1,58 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mizzy/rigel/internal/agent"
+	"github.com/mizzy/rigel/internal/draft"
+	"github.com/mizzy/rigel/internal/state"
+)
+
+func TestRetryAndEditLast(t *testing.T) {
+	chatState := state.NewChatState()
+
+	assert.Equal(t, Response{Content: "No previous prompt to retry."}, retryLastPrompt(state.NewLLMState(), chatState, nil, ""))
+	assert.Equal(t, Response{Content: "No previous prompt to edit."}, editLastPrompt(chatState))
+
+	chatState.AddExchange("explain channels", "Channels are...")
+	chatState.AddExchange("/status", "Provider: anthropic")
+
+	assert.Equal(t, Retry{Prompt: "explain channels"}, retryLastPrompt(state.NewLLMState(), chatState, nil, ""))
+	assert.Equal(t, EditLast{Prompt: "explain channels"}, editLastPrompt(chatState))
+}
+
+func TestRestoreDraft(t *testing.T) {
+	t.Chdir(t.TempDir())
+	assert.Equal(t, Response{Content: "No draft to restore."}, restoreDraft())
+
+	require.NoError(t, draft.Save("refactor the parser"))
+	assert.Equal(t, RestoreDraft{Draft: "refactor the parser"}, restoreDraft())
+}
+
+func TestRetry_UnknownModel(t *testing.T) {
+	chatState := state.NewChatState()
+	chatState.AddExchange("hello", "hi")
+
+	failure := as[Failure](t, retryLastPrompt(state.NewLLMState(), chatState, nil, "missing"))
+	assert.EqualError(t, failure.Err, "no provider available")
+}
+
+func TestContinue(t *testing.T) {
+	ag := agent.New(nil)
+	assert.Equal(t, Response{Content: "Nothing to continue: the last response wasn't interrupted."}, continueResponse(ag))
+
+	ag.SetHistory([]agent.Message{
+		{Role: "user", Content: "explain channels"},
+		{Role: "assistant", Content: "Channels are"},
+	})
+	assert.Equal(t, Response{Content: "Nothing to continue: the last response wasn't interrupted."}, continueResponse(ag))
+
+	ag.SetHistory([]agent.Message{
+		{Role: "user", Content: "explain channels"},
+		{Role: "assistant", Content: "Channels are\n\n" + agent.InterruptedMarker},
+	})
+	assert.Equal(t, Request{Prompt: agent.ContinuePrompt}, continueResponse(ag))
+}
@@ -0,0 +1,300 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mizzy/rigel/internal/agent"
+	"github.com/mizzy/rigel/internal/git"
+	"github.com/mizzy/rigel/internal/llm"
+	"github.com/mizzy/rigel/internal/quarantine"
+	"github.com/mizzy/rigel/internal/state"
+)
+
+// maxReviewDiffLength is how much of a file's diff the model is given to
+// review
+const maxReviewDiffLength = 12000
+
+// Severities of review findings, most severe first
+const (
+	SeverityCritical   = "critical"
+	SeverityWarning    = "warning"
+	SeveritySuggestion = "suggestion"
+)
+
+// Severities lists the severities of review findings, most severe first
+var Severities = []string{SeverityCritical, SeverityWarning, SeveritySuggestion}
+
+// ReviewFinding is a problem the model found in a change
+type ReviewFinding struct {
+	File     string // Relative to the repository root
+	Line     int    // Line in the new version of the file, 0 if not known
+	Severity string
+	Message  string
+}
+
+// Location returns where the finding is, as file:line
+func (f ReviewFinding) Location() string {
+	if f.Line == 0 {
+		return f.File
+	}
+	return fmt.Sprintf("%s:%d", f.File, f.Line)
+}
+
+// Review is the outcome of /review
+type Review struct {
+	Scope    string // What was reviewed, e.g. "staged changes"
+	Root     string // Repository root the files are relative to
+	Files    []string
+	Findings []ReviewFinding
+	Failed   map[string]error // Files the model couldn't review
+}
+
+// fileDiff is the part of a diff that changes one file
+type fileDiff struct {
+	path string
+	diff string
+}
+
+// reviewChanges reviews the uncommitted changes, the staged ones, or those
+// of the current branch since it diverged from base, one file at a time
+func reviewChanges(llmState *state.LLMState, ag *agent.Agent, staged bool, base string) Result {
+	if staged && base != "" {
+		return Response{Content: "Use either --staged or --branch, not both."}
+	}
+	if !git.IsGitRepo() {
+		return Response{Content: "/review needs a git repository."}
+	}
+	provider := llmState.GetCurrentProvider()
+	if provider == nil {
+		return Failure{Err: fmt.Errorf("no provider available")}
+	}
+
+	var diff, scope string
+	var err error
+	switch {
+	case staged:
+		scope = "staged changes"
+		diff, err = git.StagedDiff()
+	case base != "":
+		if !git.RefExists(base) {
+			return Response{Content: fmt.Sprintf("Unknown branch: %s", base)}
+		}
+		scope = "changes since " + base
+		diff, err = git.Diff(base)
+	default:
+		scope = "uncommitted changes"
+		diff, err = git.WorkingDiff()
+	}
+	if err != nil {
+		return Failure{Err: err}
+	}
+	files := splitDiff(diff)
+	if len(files) == 0 {
+		return Response{Content: fmt.Sprintf("No %s to review.", scope)}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	progress := make(chan string, len(files))
+	return Async{
+		Progress: progress,
+		Cancel:   cancel,
+		Fn: func() Result {
+			defer cancel()
+			defer close(progress)
+
+			review := &Review{Scope: scope, Root: git.TopLevel()}
+			for i, file := range files {
+				progress <- fmt.Sprintf("reviewing %s (%d/%d)", file.path, i+1, len(files))
+				findings, err := reviewFile(ctx, provider, file)
+				if errors.Is(ctx.Err(), context.Canceled) {
+					return Response{Content: "Review cancelled."}
+				}
+				review.Files = append(review.Files, file.path)
+				if err != nil {
+					if review.Failed == nil {
+						review.Failed = make(map[string]error)
+					}
+					review.Failed[file.path] = err
+					continue
+				}
+				review.Findings = append(review.Findings, findings...)
+			}
+
+			// Command output isn't otherwise part of what the model
+			// remembers, and the next prompt will likely ask to fix the
+			// findings
+			if ag != nil {
+				ag.SetHistory(append(ag.History(),
+					agent.Message{Role: "user", Content: "/review " + scope},
+					agent.Message{Role: "assistant", Content: FormatReview(review, nil)},
+				))
+			}
+			return ShowReview{Review: review}
+		},
+	}
+}
+
+// splitDiff splits a diff into the changes of each file, leaving out deleted
+// and binary files, which have no lines to review
+func splitDiff(diff string) []fileDiff {
+	var files []fileDiff
+	var current *fileDiff
+	var lines []string
+	flush := func() {
+		if current != nil && current.path != "" && len(lines) > 0 {
+			current.diff = strings.Join(lines, "\n")
+			files = append(files, *current)
+		}
+		current, lines = nil, nil
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(diff, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			flush()
+			current = &fileDiff{}
+		case current == nil:
+			continue
+		case strings.HasPrefix(line, "@@"), len(lines) > 0:
+			lines = append(lines, line)
+		case strings.HasPrefix(line, "+++ "):
+			if path := strings.TrimPrefix(line, "+++ "); path != "/dev/null" {
+				current.path = strings.TrimPrefix(path, "b/")
+			}
+		}
+	}
+	flush()
+	return files
+}
+
+// numberDiff prefixes the lines of a file's diff that are in its new version
+// with their line numbers, so the model can refer to them
+func numberDiff(diff string) string {
+	var sb strings.Builder
+	line := 0
+	for _, l := range strings.Split(diff, "\n") {
+		if strings.HasPrefix(l, "@@") {
+			// @@ -a,b +c,d @@
+			if _, after, ok := strings.Cut(l, " +"); ok {
+				start, _, _ := strings.Cut(after, " ")
+				start, _, _ = strings.Cut(start, ",")
+				line, _ = strconv.Atoi(start)
+			}
+			sb.WriteString(l + "\n")
+			continue
+		}
+		if strings.HasPrefix(l, "-") || strings.HasPrefix(l, `\`) {
+			fmt.Fprintf(&sb, "%6s %s\n", "", l)
+			continue
+		}
+		fmt.Fprintf(&sb, "%6d %s\n", line, l)
+		line++
+	}
+	return sb.String()
+}
+
+// reviewFile asks the model to review the changes of one file
+func reviewFile(ctx context.Context, provider llm.Provider, file fileDiff) ([]ReviewFinding, error) {
+	diff := numberDiff(file.diff)
+	if len(diff) > maxReviewDiffLength {
+		diff = diff[:maxReviewDiffLength] + "\n... (diff truncated)"
+	}
+	prompt := fmt.Sprintf(`Review the changes to %s below as a careful senior engineer. Look for bugs, security problems, race conditions, missing error handling and unclear code in the added and changed lines.
+Each line of the new version of the file is prefixed with its line number.
+
+Reply with one finding per line, in the form:
+severity | line | finding
+where severity is critical (bugs, security problems, data loss), warning (likely problems, missing error handling) or suggestion (readability, naming, simplifications), and line is the line number the finding is about.
+Reply with only NONE if you find nothing worth mentioning. Don't add anything else.
+%s
+
+%s`, file.path, quarantine.Instruction, quarantine.Wrap(file.path, diff))
+
+	reply, err := provider.Generate(ctx, prompt)
+	if err != nil {
+		return nil, err
+	}
+	return parseFindings(file.path, reply), nil
+}
+
+// parseFindings reads the findings in the model's reply, skipping lines not
+// in the requested form
+func parseFindings(path, reply string) []ReviewFinding {
+	var findings []ReviewFinding
+	for _, line := range strings.Split(reply, "\n") {
+		line = strings.TrimLeft(strings.TrimSpace(line), "-*• ")
+		parts := strings.SplitN(line, "|", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		severity := normalizeSeverity(parts[0])
+		message := strings.TrimSpace(parts[2])
+		if severity == "" || message == "" {
+			continue
+		}
+		n, _ := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(parts[1]), "L"))
+		findings = append(findings, ReviewFinding{File: path, Line: max(n, 0), Severity: severity, Message: message})
+	}
+	return findings
+}
+
+// normalizeSeverity maps the severity the model gave to one of Severities
+func normalizeSeverity(severity string) string {
+	switch strings.ToLower(strings.Trim(strings.TrimSpace(severity), "*[]")) {
+	case SeverityCritical, "error", "high", "bug", "security":
+		return SeverityCritical
+	case SeverityWarning, "medium", "warn":
+		return SeverityWarning
+	case SeveritySuggestion, "low", "info", "nit", "style":
+		return SeveritySuggestion
+	}
+	return ""
+}
+
+// FormatReview renders the findings of a review grouped by severity. link,
+// if not nil, renders each file:line reference, e.g. as a hyperlink.
+func FormatReview(review *Review, link func(root string, finding ReviewFinding) string) string {
+	if link == nil {
+		link = func(_ string, finding ReviewFinding) string { return finding.Location() }
+	}
+
+	var sb strings.Builder
+	files := "files"
+	if len(review.Files) == 1 {
+		files = "file"
+	}
+	fmt.Fprintf(&sb, "Reviewed %s in %d %s.", review.Scope, len(review.Files), files)
+	if len(review.Findings) == 0 && len(review.Failed) == 0 {
+		sb.WriteString(" No findings.")
+	}
+
+	for _, severity := range Severities {
+		var group []ReviewFinding
+		for _, finding := range review.Findings {
+			if finding.Severity == severity {
+				group = append(group, finding)
+			}
+		}
+		if len(group) == 0 {
+			continue
+		}
+		fmt.Fprintf(&sb, "\n\n%s (%d)", strings.ToUpper(severity[:1])+severity[1:], len(group))
+		for _, finding := range group {
+			fmt.Fprintf(&sb, "\n- %s: %s", link(review.Root, finding), finding.Message)
+		}
+	}
+
+	if len(review.Failed) > 0 {
+		sb.WriteString("\n\nNot reviewed")
+		for _, file := range review.Files {
+			if err, ok := review.Failed[file]; ok {
+				fmt.Fprintf(&sb, "\n- %s: %v", file, err)
+			}
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,140 @@
+package command
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mizzy/rigel/internal/agent"
+	"github.com/mizzy/rigel/internal/state"
+)
+
+const sampleDiff = `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -10,3 +10,4 @@ func main() {
+ 	a := 1
+-	b := 2
++	b := 3
++	c := a / 0
+ 	fmt.Println(a, b)
+diff --git a/logo.png b/logo.png
+index 3333333..4444444 100644
+Binary files a/logo.png and b/logo.png differ
+diff --git a/old.go b/old.go
+deleted file mode 100644
+index 5555555..0000000
+--- a/old.go
++++ /dev/null
+@@ -1 +0,0 @@
+-package old
+`
+
+func TestSplitDiff(t *testing.T) {
+	files := splitDiff(sampleDiff)
+	require.Len(t, files, 1, "binary and deleted files are left out")
+	assert.Equal(t, "main.go", files[0].path)
+	assert.True(t, strings.HasPrefix(files[0].diff, "@@ -10,3 +10,4 @@"))
+	assert.True(t, strings.HasSuffix(files[0].diff, "fmt.Println(a, b)"))
+
+	assert.Equal(t, `@@ -10,3 +10,4 @@ func main() {
+    10  	a := 1
+       -	b := 2
+    11 +	b := 3
+    12 +	c := a / 0
+    13  	fmt.Println(a, b)
+`, numberDiff(files[0].diff))
+}
+
+func TestParseFindings(t *testing.T) {
+	findings := parseFindings("main.go", `Here are my findings:
+critical | 12 | Division by zero
+- **Warning** | L11 | b changed without a test
+nit | - | Name c better
+unknown | 3 | Ignored
+NONE`)
+	assert.Equal(t, []ReviewFinding{
+		{File: "main.go", Line: 12, Severity: SeverityCritical, Message: "Division by zero"},
+		{File: "main.go", Line: 11, Severity: SeverityWarning, Message: "b changed without a test"},
+		{File: "main.go", Line: 0, Severity: SeveritySuggestion, Message: "Name c better"},
+	}, findings)
+
+	assert.Empty(t, parseFindings("main.go", "NONE"))
+}
+
+func TestFormatReview(t *testing.T) {
+	review := &Review{
+		Scope: "staged changes",
+		Files: []string{"a.go", "b.go"},
+		Findings: []ReviewFinding{
+			{File: "a.go", Line: 3, Severity: SeveritySuggestion, Message: "Rename x"},
+			{File: "b.go", Line: 7, Severity: SeverityCritical, Message: "Nil dereference"},
+			{File: "a.go", Line: 9, Severity: SeverityCritical, Message: "Unchecked error"},
+		},
+	}
+	assert.Equal(t, `Reviewed staged changes in 2 files.
+
+Critical (2)
+- b.go:7: Nil dereference
+- a.go:9: Unchecked error
+
+Suggestion (1)
+- a.go:3: Rename x`, FormatReview(review, nil))
+
+	link := func(root string, f ReviewFinding) string { return "<" + f.Location() + ">" }
+	assert.Contains(t, FormatReview(review, link), "- <b.go:7>: Nil dereference")
+
+	assert.Equal(t, "Reviewed uncommitted changes in 1 file. No findings.", FormatReview(&Review{Scope: "uncommitted changes", Files: []string{"a.go"}}, nil))
+}
+
+// reviewProvider finds a problem in every file
+type reviewProvider struct {
+	echoProvider
+	prompts []string
+}
+
+func (p *reviewProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	p.prompts = append(p.prompts, prompt)
+	return "warning | 1 | Missing package comment", nil
+}
+
+func TestReviewChanges(t *testing.T) {
+	gitRepo(t)
+	require.NoError(t, os.WriteFile("a.go", []byte("package a\n"), 0o644))
+	require.NoError(t, os.WriteFile("b.go", []byte("package b\n"), 0o644))
+	require.NoError(t, exec.Command("git", "add", "a.go").Run())
+
+	provider := &reviewProvider{}
+	llmState := state.NewLLMState()
+	llmState.SetCurrentProvider(provider)
+	ag := agent.New(nil)
+	run := func(input string) Result {
+		return DefaultRegistry.Dispatch(input, &Context{LLMState: llmState, Agent: ag})
+	}
+
+	result := as[ShowReview](t, awaitResult(t, run("/review --staged")))
+	assert.Equal(t, "staged changes", result.Review.Scope)
+	assert.Equal(t, []string{"a.go"}, result.Review.Files)
+	assert.Equal(t, []ReviewFinding{{File: "a.go", Line: 1, Severity: SeverityWarning, Message: "Missing package comment"}}, result.Review.Findings)
+	require.Len(t, provider.prompts, 1)
+	assert.Contains(t, provider.prompts[0], "     1 +package a")
+
+	history := ag.History()
+	require.Len(t, history, 2)
+	assert.Contains(t, history[1].Content, "- a.go:1: Missing package comment")
+
+	// The branch's committed changes
+	require.NoError(t, exec.Command("git", "commit", "--quiet", "-m", "Add a").Run())
+	result = as[ShowReview](t, awaitResult(t, run("/review --branch main")))
+	assert.Equal(t, []string{"a.go"}, result.Review.Files)
+
+	assert.Equal(t, Response{Content: "No staged changes to review."}, run("/review --staged"))
+	assert.Equal(t, Response{Content: "Unknown branch: nope"}, run("/review --branch nope"))
+	assert.Equal(t, Response{Content: "Use either --staged or --branch, not both."}, run("/review --staged --branch main"))
+}
@@ -0,0 +1,71 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mizzy/rigel/internal/agent"
+	"github.com/mizzy/rigel/internal/state"
+)
+
+// lastResponse returns the response to the most recent prompt sent to the
+// LLM, skipping slash commands
+func lastResponse(chatState *state.ChatState) (string, bool) {
+	history := chatState.GetHistory()
+	for i := len(history) - 1; i >= 0; i-- {
+		if !strings.HasPrefix(history[i].Prompt, "/") {
+			return history[i].Response, true
+		}
+	}
+	return "", false
+}
+
+// saveCode writes the files the last response gives in code blocks named
+// for them, or only those of paths, as a plan of the agent, which the user
+// reviews first if plans are reviewed
+func saveCode(ag *agent.Agent, chatState *state.ChatState, paths []string) Result {
+	if ag == nil {
+		return Response{Content: "No agent to save the code with."}
+	}
+	response, ok := lastResponse(chatState)
+	if !ok {
+		return Response{Content: "No previous response to save code from."}
+	}
+	artifacts := agent.ExtractArtifacts(response)
+	if len(artifacts) == 0 {
+		return Response{Content: "The last response has no code blocks named for a file, as in ```go:main.go."}
+	}
+
+	if len(paths) > 0 {
+		var names []string
+		for _, artifact := range artifacts {
+			names = append(names, artifact.Path)
+		}
+		for _, path := range paths {
+			if !slices.Contains(names, path) {
+				return Response{Content: fmt.Sprintf("The last response has no code block for %s; it has %s.", path, strings.Join(names, ", "))}
+			}
+		}
+		artifacts = slices.DeleteFunc(artifacts, func(artifact agent.CodeArtifact) bool {
+			return !slices.Contains(paths, artifact.Path)
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return Async{
+		Cancel: cancel,
+		Fn: func() Result {
+			defer cancel()
+			saved, err := ag.SaveArtifacts(ctx, artifacts)
+			if err != nil {
+				if ctx.Err() != nil {
+					return Response{Content: "Not saved: cancelled."}
+				}
+				return Failure{Err: err}
+			}
+			return Response{Content: saved}
+		},
+	}
+}
@@ -0,0 +1,43 @@
+package command
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mizzy/rigel/internal/agent"
+	"github.com/mizzy/rigel/internal/state"
+	"github.com/mizzy/rigel/internal/tools"
+)
+
+func TestSaveCode(t *testing.T) {
+	t.Chdir(t.TempDir())
+	ag := agent.New(nil)
+	ag.SetProgressDisplay(agent.NewUIProgressDisplay())
+	ag.RegisterTool(tools.NewFileTool())
+	chatState := state.NewChatState()
+
+	assert.Equal(t, Response{Content: "No previous response to save code from."}, saveCode(ag, chatState, nil))
+
+	chatState.AddExchange("write a hello world", "```go\nfmt.Println(\"hi\")\n```")
+	assert.Equal(t, Response{Content: "The last response has no code blocks named for a file, as in ```go:main.go."}, saveCode(ag, chatState, nil))
+
+	chatState.AddExchange("generate a Dockerfile and a Makefile", "```Dockerfile\nFROM alpine\n```\n\n**Makefile**\n```make\nall:\n\tdocker build .\n```")
+	chatState.AddExchange("/status", "ok")
+	assert.Equal(t, Response{Content: "The last response has no code block for go.mod; it has Dockerfile, Makefile."}, saveCode(ag, chatState, []string{"go.mod"}))
+
+	async := as[Async](t, saveCode(ag, chatState, []string{"Makefile"}))
+	saved := as[Response](t, async.Fn())
+	assert.Contains(t, saved.Content, "[x] 1. Write content to 'Makefile'\n")
+	assert.NoFileExists(t, "Dockerfile")
+	content, err := os.ReadFile("Makefile")
+	require.NoError(t, err)
+	assert.Equal(t, "all:\n\tdocker build .\n", string(content))
+
+	async = as[Async](t, saveCode(ag, chatState, nil))
+	saved = as[Response](t, async.Fn())
+	assert.Contains(t, saved.Content, "[x] 1. Write content to 'Dockerfile'\n[x] 2. Write content to 'Makefile'\n")
+	assert.FileExists(t, "Dockerfile")
+}
@@ -0,0 +1,52 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mizzy/rigel/internal/session"
+	"github.com/mizzy/rigel/internal/state"
+)
+
+// maxSearchHits is how many of the best matching exchanges /search lists
+const maxSearchHits = 50
+
+// searchConversations finds the exchanges of the current conversation and
+// the saved sessions that have every word of the query
+func searchConversations(store *session.Store, branches *state.BranchState, chatState *state.ChatState, query string) Result {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return Response{Content: "Usage: /search <words>"}
+	}
+	sessions, err := store.List()
+	if err != nil {
+		return Failure{Err: err}
+	}
+
+	// The current conversation is searched as it is now, rather than as it
+	// was last saved
+	current := &session.Session{Title: "This conversation"}
+	if branches != nil {
+		current.ID = branches.GetCurrent().ID
+	}
+	for _, ex := range chatState.GetHistory() {
+		current.Exchanges = append(current.Exchanges, session.Exchange{Prompt: ex.Prompt, Response: ex.Response, Meta: ex.Meta})
+	}
+
+	idx := session.NewIndex()
+	idx.Add(current)
+	for _, sess := range sessions {
+		if sess.ID != current.ID {
+			idx.Add(sess)
+		}
+	}
+
+	hits := idx.Search(query)
+	if len(hits) == 0 {
+		return Response{Content: fmt.Sprintf("No exchanges match %q.", query)}
+	}
+	if len(hits) > maxSearchHits {
+		hits = hits[:maxSearchHits]
+	}
+	return ShowSearch{Results: &SearchResultsMsg{Query: query, Hits: hits, Current: current.ID}}
+}
@@ -0,0 +1,51 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mizzy/rigel/internal/session"
+	"github.com/mizzy/rigel/internal/state"
+)
+
+func TestSearchConversations(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	store, err := session.NewStore()
+	require.NoError(t, err)
+
+	past := &session.Session{ID: "past", Title: "Flaky cache test", Exchanges: []session.Exchange{
+		{Prompt: "why does TestCache fail?", Response: "It depends on the clock."},
+	}}
+	require.NoError(t, store.Save(past))
+	// The saved copy of the current conversation is outdated
+	require.NoError(t, store.Save(&session.Session{ID: "current", Exchanges: []session.Exchange{
+		{Prompt: "mock the clock", Response: "old answer"},
+	}}))
+
+	chatState := state.NewChatState()
+	chatState.AddExchange("mock the clock", "Inject a clock interface.")
+	branches := state.NewBranchState("current")
+	run := func(input string) Result {
+		return DefaultRegistry.Dispatch(input, &Context{ChatState: chatState, Branches: branches})
+	}
+
+	results := as[ShowSearch](t, run("/search clock")).Results
+	assert.Equal(t, "clock", results.Query)
+	assert.Equal(t, "current", results.Current)
+	require.Len(t, results.Hits, 2)
+	ids := []string{results.Hits[0].Session.ID, results.Hits[1].Session.ID}
+	assert.ElementsMatch(t, []string{"current", "past"}, ids)
+	for _, hit := range results.Hits {
+		if hit.Session.ID == "current" {
+			assert.Equal(t, "Inject a clock interface.", hit.Session.Exchanges[hit.Exchange].Response)
+		}
+	}
+
+	results = as[ShowSearch](t, run("/search clock depends")).Results
+	require.Len(t, results.Hits, 1)
+	assert.Equal(t, "Flaky cache test", results.Hits[0].Session.DisplayTitle())
+
+	assert.Equal(t, Response{Content: "No exchanges match \"goroutines\"."}, run("/search goroutines"))
+}
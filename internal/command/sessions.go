@@ -0,0 +1,187 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mizzy/rigel/internal/agent"
+	"github.com/mizzy/rigel/internal/llm"
+	"github.com/mizzy/rigel/internal/session"
+	"github.com/mizzy/rigel/internal/state"
+)
+
+const (
+	// maxListedSessions is how many of the most recent sessions are listed
+	maxListedSessions = 20
+
+	// titleTimeout bounds how long saving a session waits for its title
+	titleTimeout = 15 * time.Second
+
+	// maxTitleLength cuts off titles the model made too long
+	maxTitleLength = 60
+
+	// maxTitleExchangeLength is how much of the first prompt and response
+	// the model is given to title a conversation
+	maxTitleExchangeLength = 2000
+)
+
+// generateTitle asks the model for a short title for a conversation from
+// its first exchange. It returns an empty string if there is no exchange
+// other than commands yet or the model fails, to try again on the next save.
+func generateTitle(provider llm.Provider, exchanges []session.Exchange) string {
+	if provider == nil {
+		return ""
+	}
+	var first *session.Exchange
+	for i, ex := range exchanges {
+		if !strings.HasPrefix(ex.Prompt, "/") {
+			first = &exchanges[i]
+			break
+		}
+	}
+	if first == nil {
+		return ""
+	}
+
+	prompt := fmt.Sprintf(`Write a title of at most six words for a conversation between a user and a coding assistant that starts with the exchange below.
+Reply with the title only, without quotes or a final period.
+
+User: %s
+
+Assistant: %s`, truncate(first.Prompt, maxTitleExchangeLength), truncate(first.Response, maxTitleExchangeLength))
+
+	ctx, cancel := context.WithTimeout(context.Background(), titleTimeout)
+	defer cancel()
+	title, err := provider.Generate(ctx, prompt)
+	if err != nil {
+		return ""
+	}
+	return cleanTitle(title)
+}
+
+// cleanTitle keeps the first line of a title the model wrote, without
+// quotes or a final period, cut to maxTitleLength
+func cleanTitle(title string) string {
+	title, _, _ = strings.Cut(strings.TrimSpace(title), "\n")
+	title = strings.TrimPrefix(title, "Title:")
+	title = strings.Trim(strings.TrimSpace(title), "\"'`*")
+	title = strings.TrimSuffix(title, ".")
+	return truncate(strings.TrimSpace(title), maxTitleLength)
+}
+
+// truncate cuts s to at most n runes, marking the cut with an ellipsis
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+// listSessions shows the saved conversations, most recent first, marking
+// the current one
+func listSessions(store *session.Store, branches *state.BranchState) Result {
+	sessions, err := store.List()
+	if err != nil {
+		return Failure{Err: err}
+	}
+	if len(sessions) == 0 {
+		return Response{Content: "No saved sessions yet. Conversations are saved when rigel exits."}
+	}
+
+	current := ""
+	if branches != nil {
+		current = branches.GetCurrent().ID
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Sessions:\n")
+	for i, sess := range sessions {
+		if i == maxListedSessions {
+			sb.WriteString(fmt.Sprintf("  ... and %d older sessions\n", len(sessions)-i))
+			break
+		}
+		marker := " "
+		if sess.ID == current {
+			marker = "*"
+		}
+		sb.WriteString(fmt.Sprintf("%s %2d. %s  %s  (%d messages, %s)\n", marker, i+1,
+			sess.UpdatedAt.Local().Format("2006-01-02 15:04"), sess.DisplayTitle(), len(sess.Exchanges), sess.ID))
+	}
+	sb.WriteString("\nUse /resume <n> to continue a session, or /session rename <title> to rename this one.")
+	return Response{Content: sb.String()}
+}
+
+// resumeSession saves the current conversation and loads a saved one, given
+// by its number in the list or its ID. Without a target it lists the
+// sessions.
+func resumeSession(store *session.Store, branches *state.BranchState, chatState *state.ChatState, llmState *state.LLMState, ag *agent.Agent, target string) Result {
+	if target == "" {
+		return listSessions(store, branches)
+	}
+	if branches == nil {
+		return Response{Content: "Sessions are not available in this session."}
+	}
+
+	// Find it before saving, which reorders the list
+	sess, err := store.Find(target)
+	if errors.Is(err, session.ErrNotFound) {
+		return Response{Content: fmt.Sprintf("Unknown session %q. Use /session list to list sessions.", target)}
+	}
+	if err != nil {
+		return Failure{Err: err}
+	}
+	if sess.ID == branches.GetCurrent().ID {
+		return Response{Content: "That's the current session."}
+	}
+
+	// Saving may wait for the model to title the current conversation
+	return Async{
+		Fn: func() Result {
+			if chatState.GetMessageCount() > 0 {
+				if _, err := saveBranch(store, branches.GetCurrent(), chatState, llmState, ag, ""); err != nil {
+					return Failure{Err: fmt.Errorf("failed to save the current session: %w", err)}
+				}
+			}
+
+			name := sess.Name
+			if name == "" {
+				name = "main"
+			}
+			branches.Reset(state.Branch{ID: sess.ID, Name: name})
+			return Restore{
+				Notice:  fmt.Sprintf("Resumed %q with %d messages.", sess.DisplayTitle(), len(sess.Exchanges)),
+				Session: sess,
+			}
+		},
+	}
+}
+
+// renameSession saves the current conversation under a new title
+func renameSession(store *session.Store, branches *state.BranchState, chatState *state.ChatState, llmState *state.LLMState, ag *agent.Agent, title string) Result {
+	if branches == nil {
+		return Response{Content: "Sessions are not available in this session."}
+	}
+	title = truncate(strings.TrimSpace(title), maxTitleLength)
+	if title == "" {
+		return Response{Content: "Usage: /session rename <title>"}
+	}
+	if _, err := saveBranch(store, branches.GetCurrent(), chatState, llmState, ag, title); err != nil {
+		return Failure{Err: fmt.Errorf("failed to save the session: %w", err)}
+	}
+	return Response{Content: fmt.Sprintf("Renamed this session to %q.", title)}
+}
+
+// manageSessions runs /session list and /session rename
+func manageSessions(store *session.Store, branches *state.BranchState, chatState *state.ChatState, llmState *state.LLMState, ag *agent.Agent, args []string) Result {
+	if len(args) == 0 || args[0] == "list" {
+		return listSessions(store, branches)
+	}
+	if args[0] == "rename" {
+		return renameSession(store, branches, chatState, llmState, ag, strings.Join(args[1:], " "))
+	}
+	return Response{Content: fmt.Sprintf("Unknown /session action %q. Use list or rename.", args[0])}
+}
@@ -0,0 +1,96 @@
+package command
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mizzy/rigel/internal/session"
+	"github.com/mizzy/rigel/internal/state"
+)
+
+// titleProvider titles every conversation the same way
+type titleProvider struct {
+	echoProvider
+	prompts []string
+}
+
+func (p *titleProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	p.prompts = append(p.prompts, prompt)
+	return "Title: \"Fixing the flaky cache test.\"\nIt's about a test.", nil
+}
+
+func TestGenerateTitle(t *testing.T) {
+	provider := &titleProvider{}
+
+	assert.Empty(t, generateTitle(provider, []session.Exchange{{Prompt: "/status", Response: "ok"}}))
+	assert.Empty(t, provider.prompts, "commands aren't titled")
+
+	title := generateTitle(provider, []session.Exchange{
+		{Prompt: "/status", Response: "ok"},
+		{Prompt: "why does TestCache fail?", Response: "It depends on the clock."},
+	})
+	assert.Equal(t, "Fixing the flaky cache test", title)
+	require.Len(t, provider.prompts, 1)
+	assert.Contains(t, provider.prompts[0], "User: why does TestCache fail?")
+	assert.Contains(t, provider.prompts[0], "Assistant: It depends on the clock.")
+
+	assert.Empty(t, generateTitle(nil, []session.Exchange{{Prompt: "hi", Response: "hello"}}))
+}
+
+func TestSessionsTitleListRenameResume(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	store, err := session.NewStore()
+	require.NoError(t, err)
+
+	provider := &titleProvider{}
+	llmState := state.NewLLMState()
+	llmState.SetCurrentProvider(provider)
+	chatState := state.NewChatState()
+	branches := state.NewBranchState("first-id")
+	chatState.AddExchange("why does TestCache fail?", "It depends on the clock.")
+	run := func(input string) Result {
+		return DefaultRegistry.Dispatch(input, &Context{LLMState: llmState, ChatState: chatState, Branches: branches})
+	}
+
+	// Saving titles the conversation once
+	_, err = SaveCurrentBranch(branches, chatState, llmState, nil)
+	require.NoError(t, err)
+	_, err = SaveCurrentBranch(branches, chatState, llmState, nil)
+	require.NoError(t, err)
+	assert.Len(t, provider.prompts, 1)
+
+	time.Sleep(10 * time.Millisecond)
+	other := &session.Session{ID: "other-id", Title: "Other work", Exchanges: []session.Exchange{{Prompt: "hi", Response: "hello"}}}
+	require.NoError(t, store.Save(other))
+
+	list := as[Response](t, run("/session list"))
+	assert.Contains(t, list.Content, "   1. ")
+	assert.Contains(t, list.Content, "Other work  (1 messages, other-id)")
+	assert.Contains(t, list.Content, "*  2. ")
+	assert.Contains(t, list.Content, "Fixing the flaky cache test  (1 messages, first-id)")
+
+	assert.Equal(t, Response{Content: `Renamed this session to "Cache clock bug".`}, run("/session rename Cache clock bug"))
+	sess, err := store.Load("first-id")
+	require.NoError(t, err)
+	assert.Equal(t, "Cache clock bug", sess.Title)
+
+	// Resuming saves the current conversation first
+	chatState.AddExchange("and now?", "It passes.")
+	restore := as[Restore](t, as[Async](t, run("/resume other")).Fn())
+	assert.Equal(t, `Resumed "Other work" with 1 messages.`, restore.Notice)
+	assert.Equal(t, "other-id", restore.Session.ID)
+	assert.Equal(t, []state.Branch{{ID: "other-id", Name: "main"}}, branches.GetBranches())
+
+	sess, err = store.Load("first-id")
+	require.NoError(t, err)
+	assert.Len(t, sess.Exchanges, 2)
+	assert.Equal(t, "Cache clock bug", sess.Title, "a title given isn't regenerated")
+
+	assert.Equal(t, Response{Content: "That's the current session."}, run("/resume other-id"))
+	assert.Equal(t, Response{Content: `Unknown session "9". Use /session list to list sessions.`}, run("/resume 9"))
+	assert.Equal(t, Response{Content: "Usage: /session rename <title>"}, run("/session rename"))
+}
@@ -0,0 +1,324 @@
+package command
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mizzy/rigel/internal/config"
+	"github.com/mizzy/rigel/internal/llm"
+	"github.com/mizzy/rigel/internal/state"
+)
+
+// setting is a UI setting adjustable with /set whatever the provider
+type setting struct {
+	name        string
+	description string
+	get         func(*config.Config) string
+	set         func(*config.Config, string) error
+
+	// Applies the setting to the current provider; nil for settings of
+	// the UI alone
+	apply func(llm.Provider, *config.Config)
+}
+
+var settings = []setting{
+	{
+		name:        "editing-mode",
+		description: "input editing keys, emacs or vi",
+		get: func(cfg *config.Config) string {
+			if cfg.EditingMode == "" {
+				return config.EditingModeEmacs
+			}
+			return cfg.EditingMode
+		},
+		set: func(cfg *config.Config, value string) error {
+			if value != config.EditingModeEmacs && value != config.EditingModeVi {
+				return fmt.Errorf("editing-mode must be emacs or vi")
+			}
+			cfg.EditingMode = value
+			return nil
+		},
+	},
+	{
+		name:        "status-bar",
+		description: "status line above the termflow prompt, on or off",
+		get: func(cfg *config.Config) string {
+			if cfg.StatusBar {
+				return "on"
+			}
+			return "off"
+		},
+		set: func(cfg *config.Config, value string) error {
+			switch value {
+			case "on":
+				cfg.StatusBar = true
+			case "off":
+				cfg.StatusBar = false
+			default:
+				return fmt.Errorf("status-bar must be on or off")
+			}
+			return nil
+		},
+	},
+	{
+		name:        "hints",
+		description: "tips and recent commands shown in the empty input, on or off",
+		get: func(cfg *config.Config) string {
+			if cfg.Hints {
+				return "on"
+			}
+			return "off"
+		},
+		set: func(cfg *config.Config, value string) error {
+			switch value {
+			case "on":
+				cfg.Hints = true
+			case "off":
+				cfg.Hints = false
+			default:
+				return fmt.Errorf("hints must be on or off")
+			}
+			return nil
+		},
+	},
+	{
+		name:        "show-meta",
+		description: "model, latency and tokens after each response, on or off",
+		get: func(cfg *config.Config) string {
+			if cfg.ShowMeta {
+				return "on"
+			}
+			return "off"
+		},
+		set: func(cfg *config.Config, value string) error {
+			switch value {
+			case "on":
+				cfg.ShowMeta = true
+			case "off":
+				cfg.ShowMeta = false
+			default:
+				return fmt.Errorf("show-meta must be on or off")
+			}
+			return nil
+		},
+	},
+	{
+		name:        "deterministic",
+		description: "temperature 0, a fixed seed and cached responses, on or off",
+		get: func(cfg *config.Config) string {
+			if cfg.Deterministic {
+				return "on"
+			}
+			return "off"
+		},
+		set: func(cfg *config.Config, value string) error {
+			switch value {
+			case "on":
+				cfg.Deterministic = true
+			case "off":
+				cfg.Deterministic = false
+			default:
+				return fmt.Errorf("deterministic must be on or off")
+			}
+			return nil
+		},
+		apply: func(p llm.Provider, cfg *config.Config) {
+			llm.SetDeterministic(p, cfg.Deterministic)
+		},
+	},
+}
+
+// ollamaOption is a request option adjustable with /set
+type ollamaOption struct {
+	name        string
+	description string
+	get         func(llm.GenerateOptions) string
+	set         func(*llm.GenerateOptions, *config.Config, string) error
+}
+
+var ollamaOptions = []ollamaOption{
+	{
+		name:        "num_ctx",
+		description: "context window size in tokens",
+		get:         func(o llm.GenerateOptions) string { return formatOptionInt(o.NumCtx) },
+		set: func(o *llm.GenerateOptions, cfg *config.Config, value string) error {
+			n, err := parseOptionInt(value)
+			o.NumCtx, cfg.OllamaNumCtx = n, n
+			return err
+		},
+	},
+	{
+		name:        "top_p",
+		description: "nucleus sampling probability",
+		get: func(o llm.GenerateOptions) string {
+			if o.TopP == 0 {
+				return "default"
+			}
+			return strconv.FormatFloat(float64(o.TopP), 'g', -1, 32)
+		},
+		set: func(o *llm.GenerateOptions, cfg *config.Config, value string) error {
+			f := 0.0
+			if value != "default" {
+				var err error
+				if f, err = strconv.ParseFloat(value, 32); err != nil || f < 0 || f > 1 {
+					return fmt.Errorf("top_p must be between 0 and 1")
+				}
+			}
+			o.TopP, cfg.OllamaTopP = float32(f), f
+			return nil
+		},
+	},
+	{
+		name:        "top_k",
+		description: "number of candidate tokens to sample from",
+		get:         func(o llm.GenerateOptions) string { return formatOptionInt(o.TopK) },
+		set: func(o *llm.GenerateOptions, cfg *config.Config, value string) error {
+			n, err := parseOptionInt(value)
+			o.TopK, cfg.OllamaTopK = n, n
+			return err
+		},
+	},
+	{
+		name:        "seed",
+		description: "random seed for reproducible output",
+		get:         func(o llm.GenerateOptions) string { return formatOptionInt(o.Seed) },
+		set: func(o *llm.GenerateOptions, cfg *config.Config, value string) error {
+			n := 0
+			if value != "default" {
+				var err error
+				if n, err = strconv.Atoi(value); err != nil {
+					return fmt.Errorf("seed must be an integer")
+				}
+			}
+			o.Seed, cfg.OllamaSeed = n, n
+			return nil
+		},
+	},
+	{
+		name:        "keep_alive",
+		description: "how long the model stays loaded, e.g. 10m or -1 for forever",
+		get: func(o llm.GenerateOptions) string {
+			if o.KeepAlive == "" {
+				return "default"
+			}
+			return o.KeepAlive
+		},
+		set: func(o *llm.GenerateOptions, cfg *config.Config, value string) error {
+			if value == "default" {
+				value = ""
+			}
+			o.KeepAlive, cfg.OllamaKeepAlive = value, value
+			return nil
+		},
+	},
+}
+
+func formatOptionInt(n int) string {
+	if n == 0 {
+		return "default"
+	}
+	return strconv.Itoa(n)
+}
+
+func parseOptionInt(value string) (int, error) {
+	if value == "default" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("value must be a positive integer")
+	}
+	return n, nil
+}
+
+// setOption shows or changes the UI settings and the Ollama request options
+// for this session
+func setOption(llmState *state.LLMState, cfg *config.Config, args []string) Result {
+	if cfg == nil {
+		cfg = &config.Config{}
+	}
+	if len(args) > 0 {
+		for _, s := range settings {
+			if s.name == args[0] {
+				return applySetting(s, llmState, cfg, args[1:])
+			}
+		}
+	}
+
+	var sb strings.Builder
+	if len(args) == 0 {
+		sb.WriteString("Settings:\n")
+		for _, s := range settings {
+			sb.WriteString(fmt.Sprintf("  %-13s  %-8s  %s\n", s.name, s.get(cfg), s.description))
+		}
+		sb.WriteString("\n")
+	}
+
+	ollama, ok := currentOllama(llmState)
+	if !ok {
+		sb.WriteString("Ollama options can only be set for the Ollama provider. Switch with /provider.")
+		return Response{Content: sb.String()}
+	}
+	opts := ollama.Defaults()
+
+	if len(args) == 0 {
+		sb.WriteString("Ollama options:\n")
+		for _, option := range ollamaOptions {
+			sb.WriteString(fmt.Sprintf("  %-10s  %-8s  %s\n", option.name, option.get(opts), option.description))
+		}
+		sb.WriteString("\nUse /set <option> <value> to change one, or /set <option> default to reset it.")
+		return Response{Content: sb.String()}
+	}
+
+	for _, option := range ollamaOptions {
+		if option.name != args[0] {
+			continue
+		}
+		if len(args) == 1 {
+			return Response{Content: fmt.Sprintf("%s = %s", option.name, option.get(opts))}
+		}
+
+		// Keep the config in step so a provider created later with /provider
+		// picks up the same options
+		updated := config.Config{}
+		if cfg != nil {
+			updated = *cfg
+		}
+		if err := option.set(&opts, &updated, args[1]); err != nil {
+			return Response{Content: fmt.Sprintf("Invalid value for %s: %v", option.name, err)}
+		}
+		if cfg != nil {
+			*cfg = updated
+		}
+		ollama.SetDefaults(opts)
+		return Response{Content: fmt.Sprintf("Set %s to %s.", option.name, option.get(opts))}
+	}
+
+	var names []string
+	for _, s := range settings {
+		names = append(names, s.name)
+	}
+	for _, option := range ollamaOptions {
+		names = append(names, option.name)
+	}
+	return Response{
+		Content: fmt.Sprintf("Unknown option %q. Available options: %s", args[0], strings.Join(names, ", ")),
+	}
+}
+
+// applySetting shows or changes a setting
+func applySetting(s setting, llmState *state.LLMState, cfg *config.Config, args []string) Result {
+	if len(args) == 0 {
+		return Response{Content: fmt.Sprintf("%s = %s", s.name, s.get(cfg))}
+	}
+	if err := s.set(cfg, args[0]); err != nil {
+		return Response{Content: fmt.Sprintf("Invalid value for %s: %v", s.name, err)}
+	}
+	if s.apply != nil && llmState != nil {
+		if provider := llmState.GetCurrentProvider(); provider != nil {
+			s.apply(provider, cfg)
+		}
+	}
+	return Response{Content: fmt.Sprintf("Set %s to %s.", s.name, s.get(cfg))}
+}
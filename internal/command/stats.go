@@ -0,0 +1,126 @@
+package command
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mizzy/rigel/internal/stats"
+)
+
+// statsBarWidth is the length of the longest bar in the /stats charts
+const statsBarWidth = 30
+
+// statsPeriods are the numbers of days /stats can show
+var statsPeriods = map[string]int{"7": 7, "30": 30}
+
+// showStats shows the usage recorded over the last 7 days, or the last 30
+// with /stats 30, as bar charts
+func showStats(store *stats.Store, args []string, now time.Time) Result {
+	n := 7
+	if len(args) == 1 {
+		var ok bool
+		if n, ok = statsPeriods[args[0]]; !ok {
+			return Failure{Err: fmt.Errorf("invalid period %q: use /stats 7 or /stats 30", args[0])}
+		}
+	}
+	days, err := store.Days(n, now)
+	if err != nil {
+		return Failure{Err: err}
+	}
+
+	var total stats.Day
+	tools, models := map[string]int{}, map[string]int{}
+	for _, day := range days {
+		total.Prompts += day.Prompts
+		total.InputTokens += day.InputTokens
+		total.OutputTokens += day.OutputTokens
+		for tool, runs := range day.Tools {
+			tools[tool] += runs
+		}
+		for model, prompts := range day.Models {
+			models[model] += prompts
+		}
+	}
+	if total.Prompts == 0 && total.Tokens() == 0 && len(tools) == 0 {
+		return Response{Content: fmt.Sprintf("No usage recorded in the last %d days.", n)}
+	}
+
+	runs := 0
+	for _, count := range tools {
+		runs += count
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Usage over the last %d days, kept locally in %s\n", n, store.Path()))
+	sb.WriteString(fmt.Sprintf("  %d prompts · %s tokens (%s in, %s out) · %d tool runs\n", total.Prompts,
+		shortCount(total.Tokens()), shortCount(total.InputTokens), shortCount(total.OutputTokens), runs))
+
+	prompts := make([]statsBar, len(days))
+	tokens := make([]statsBar, len(days))
+	for i, day := range days {
+		date, _ := time.ParseInLocation("2006-01-02", day.Date, time.Local)
+		label := date.Format("Mon Jan 02")
+		prompts[i] = statsBar{label, day.Prompts}
+		tokens[i] = statsBar{label, day.Tokens()}
+	}
+	writeChart(&sb, "Prompts per day", prompts)
+	writeChart(&sb, "Tokens per day", tokens)
+	writeChart(&sb, "Tool runs", rankedBars(tools))
+	writeChart(&sb, "Prompts by model", rankedBars(models))
+	return Response{Content: strings.TrimSuffix(sb.String(), "\n")}
+}
+
+// statsBar is a labelled value of a /stats chart
+type statsBar struct {
+	label string
+	value int
+}
+
+// rankedBars returns bars for counts, largest first
+func rankedBars(counts map[string]int) []statsBar {
+	bars := make([]statsBar, 0, len(counts))
+	for _, label := range slices.Sorted(maps.Keys(counts)) {
+		bars = append(bars, statsBar{label, counts[label]})
+	}
+	slices.SortStableFunc(bars, func(a, b statsBar) int { return cmp.Compare(b.value, a.value) })
+	return bars
+}
+
+// writeChart writes a horizontal bar chart, scaled so the largest value
+// fills statsBarWidth. Charts without bars are left out.
+func writeChart(sb *strings.Builder, title string, bars []statsBar) {
+	if len(bars) == 0 {
+		return
+	}
+	labelWidth, largest := 0, 0
+	for _, bar := range bars {
+		labelWidth = max(labelWidth, len(bar.label))
+		largest = max(largest, bar.value)
+	}
+
+	sb.WriteString("\n" + title + ":\n")
+	for _, bar := range bars {
+		length := 0
+		if bar.value > 0 {
+			length = max(1, bar.value*statsBarWidth/largest)
+		}
+		sb.WriteString(fmt.Sprintf("  %-*s %s%s %s\n", labelWidth, bar.label,
+			strings.Repeat("█", length), strings.Repeat(" ", statsBarWidth-length), shortCount(bar.value)))
+	}
+}
+
+// shortCount shortens a count, e.g. 12345 to 12.3k and 4560000 to 4.6M
+func shortCount(n int) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1_000_000)
+	case n >= 1000:
+		return fmt.Sprintf("%.1fk", float64(n)/1000)
+	default:
+		return fmt.Sprintf("%d", n)
+	}
+}
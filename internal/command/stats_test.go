@@ -0,0 +1,52 @@
+package command
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mizzy/rigel/internal/stats"
+)
+
+func TestShowStats(t *testing.T) {
+	store := stats.NewStoreAt(filepath.Join(t.TempDir(), "stats.json"))
+	now := time.Date(2025, 3, 10, 15, 0, 0, 0, time.Local)
+
+	assert.Equal(t, Response{Content: "No usage recorded in the last 7 days."}, showStats(store, nil, now))
+
+	require.NoError(t, store.Add(usageAt(now, 4, 12000, "ollama/llama3.2", "read", "read", "test")))
+	require.NoError(t, store.Add(usageAt(now.AddDate(0, 0, -2), 2, 3000, "anthropic/claude-sonnet-4")))
+	require.NoError(t, store.Add(usageAt(now.AddDate(0, 0, -20), 1, 500, "ollama/llama3.2")))
+
+	week := as[Response](t, showStats(store, nil, now)).Content
+	assert.Contains(t, week, "Usage over the last 7 days")
+	assert.Contains(t, week, "6 prompts · 15.0k tokens (15.0k in, 0 out) · 3 tool runs")
+	assert.Contains(t, week, "Mon Mar 10 "+bar(30)+" 4\n")
+	assert.Contains(t, week, "Sat Mar 08 "+bar(15)+" 2\n")
+	assert.Contains(t, week, "Sun Mar 09 "+bar(0)+" 0\n")
+	assert.Contains(t, week, "read "+bar(30)+" 2\n  test "+bar(15)+" 1")
+	assert.Contains(t, week, "ollama/llama3.2           "+bar(30)+" 4\n")
+	assert.NotContains(t, week, "Feb 18")
+
+	month := as[Response](t, showStats(store, []string{"30"}, now)).Content
+	assert.Contains(t, month, "7 prompts")
+	assert.Contains(t, month, "Tue Feb 18 ")
+
+	_, ok := showStats(store, []string{"14"}, now).(Failure)
+	assert.True(t, ok)
+}
+
+// usageAt returns prompts answered by model at t, with their tokens and the
+// tools they ran
+func usageAt(t time.Time, prompts, tokens int, model string, tools ...string) stats.Usage {
+	return stats.Usage{Time: t, Prompts: prompts, InputTokens: tokens, Model: model, Tools: tools}
+}
+
+// bar returns a /stats bar of the given length, padded to the chart width
+func bar(length int) string {
+	return strings.Repeat("█", length) + strings.Repeat(" ", statsBarWidth-length)
+}
@@ -0,0 +1,80 @@
+package command
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mizzy/rigel/internal/session"
+	"github.com/mizzy/rigel/internal/state"
+)
+
+// maxTranscriptSummary limits how much of a tool's input is shown per line
+// of the transcript list
+const maxTranscriptSummary = 60
+
+// showTranscript lists the tools the agent ran in the current branch of the
+// conversation, or shows the full input and output of the nth call
+func showTranscript(store *session.Store, branches *state.BranchState, args []string) Result {
+	if branches == nil {
+		return Response{Content: "No transcript is kept in this session."}
+	}
+	calls, err := store.Transcript(branches.GetCurrent().ID)
+	if err != nil {
+		return Failure{Err: err}
+	}
+	if len(calls) == 0 {
+		return Response{Content: "The agent hasn't run any tools in this conversation."}
+	}
+
+	if len(args) == 1 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 || n > len(calls) {
+			return Failure{Err: fmt.Errorf("no tool call %q: there are %d", args[0], len(calls))}
+		}
+		return Response{Content: formatToolCall(n, calls[n-1])}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Tools run in this conversation:\n")
+	for i, call := range calls {
+		status := "ok"
+		if call.Error != "" {
+			status = "failed"
+		}
+		sb.WriteString(fmt.Sprintf("  %d. %s %-6s %s (%s, %s, %s)\n", i+1, call.Time.Format("15:04:05"), call.Tool,
+			summarize(call.Input), call.Duration.Round(time.Millisecond), call.Approval, status))
+	}
+	sb.WriteString("\nUse /transcript <n> to see a call's full input and output.")
+	return Response{Content: sb.String()}
+}
+
+// formatToolCall shows everything recorded about a tool call
+func formatToolCall(n int, call session.ToolCall) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Tool call %d: %s\n", n, call.Tool))
+	sb.WriteString(fmt.Sprintf("  Time: %s\n", call.Time.Format("2006-01-02 15:04:05")))
+	sb.WriteString(fmt.Sprintf("  Duration: %s\n", call.Duration.Round(time.Millisecond)))
+	sb.WriteString(fmt.Sprintf("  Approval: %s\n", call.Approval))
+	sb.WriteString(fmt.Sprintf("\nInput:\n%s\n", call.Input))
+	if call.Error != "" {
+		sb.WriteString(fmt.Sprintf("\nError:\n%s\n", call.Error))
+	}
+	if call.Output != "" {
+		sb.WriteString(fmt.Sprintf("\nOutput:\n%s\n", call.Output))
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// summarize returns the first line of s, cut off to fit a list line
+func summarize(s string) string {
+	line, _, more := strings.Cut(s, "\n")
+	if runes := []rune(line); len(runes) > maxTranscriptSummary {
+		return string(runes[:maxTranscriptSummary-1]) + "…"
+	}
+	if more {
+		return line + " …"
+	}
+	return line
+}
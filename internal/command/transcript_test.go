@@ -0,0 +1,43 @@
+package command
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mizzy/rigel/internal/session"
+	"github.com/mizzy/rigel/internal/state"
+)
+
+func TestShowTranscript(t *testing.T) {
+	store, err := session.NewStoreAt(t.TempDir())
+	require.NoError(t, err)
+	branches := state.NewBranchState("abc123")
+
+	assert.Equal(t, Response{Content: "The agent hasn't run any tools in this conversation."}, showTranscript(store, branches, nil))
+
+	at := time.Date(2025, 1, 2, 14, 3, 4, 0, time.Local)
+	require.NoError(t, store.AppendToolCall("abc123", session.ToolCall{
+		Time: at, Tool: "write", Input: "write main.go package main\n\nfunc main() {}", Output: "Wrote 30 bytes",
+		Duration: 12 * time.Millisecond, Approval: session.ApprovalAuto,
+	}))
+	require.NoError(t, store.AppendToolCall("abc123", session.ToolCall{
+		Time: at, Tool: "check", Input: "check", Error: errors.New("go vet failed").Error(),
+		Duration: 2100 * time.Millisecond, Approval: session.ApprovalAuto,
+	}))
+
+	list := as[Response](t, showTranscript(store, branches, nil))
+	assert.Contains(t, list.Content, "1. 14:03:04 write  write main.go package main … (12ms, auto, ok)")
+	assert.Contains(t, list.Content, "2. 14:03:04 check  check (2.1s, auto, failed)")
+
+	call := as[Response](t, showTranscript(store, branches, []string{"1"}))
+	assert.Contains(t, call.Content, "Tool call 1: write")
+	assert.Contains(t, call.Content, "Input:\nwrite main.go package main\n\nfunc main() {}")
+	assert.Contains(t, call.Content, "Output:\nWrote 30 bytes")
+
+	assert.Contains(t, as[Response](t, showTranscript(store, branches, []string{"2"})).Content, "Error:\ngo vet failed")
+	as[Failure](t, showTranscript(store, branches, []string{"3"}))
+}
@@ -1,23 +1,141 @@
 package command
 
 import (
+	"context"
+
+	"github.com/mizzy/rigel/internal/analyzer"
+	"github.com/mizzy/rigel/internal/history"
 	"github.com/mizzy/rigel/internal/llm"
+	"github.com/mizzy/rigel/internal/session"
+	"github.com/mizzy/rigel/internal/workspace"
 )
 
-// Result represents the result of command execution
-type Result struct {
-	Type    string // "response", "model_selector", "provider_selector", "status", "quit", "clear", "request", "async"
-	Error   error
-	Content string        // Text response content
-	Prompt  string        // For "request" type - the prompt to send to LLM
-	AsyncFn func() Result // For "async" type - function to execute asynchronously
+// Result is the outcome of a command: one of the types below. Frontends
+// handle results with a type switch listing every type; the unexported method
+// keeps the set closed, so go-check-sumtype reports switches that miss one.
+//
+//sumtype:decl
+type Result interface {
+	isResult()
+}
+
+// Response is text to show and record as the command's response
+type Response struct {
+	Content string
+}
+
+// Failure reports that the command failed
+type Failure struct {
+	Err error
+}
+
+// Async runs Fn in the background, then handles the result it returns
+type Async struct {
+	Fn func() Result
+
+	// Optional: progress messages, closed when Fn returns, and a function
+	// that cancels Fn
+	Progress <-chan string
+	Cancel   context.CancelFunc
+}
+
+// Request sends a prompt to the agent
+type Request struct {
+	Prompt string
+}
+
+// Retry sends a prompt again, replacing its last exchange
+type Retry struct {
+	Prompt string
+	Notice string // Shown before the new response
+}
+
+// EditLast removes the last exchange and puts its prompt back in the input
+type EditLast struct {
+	Prompt string
+}
+
+// RestoreDraft puts a prompt an earlier session left unsent back in the
+// input
+type RestoreDraft struct {
+	Draft string
+}
 
-	// Type-specific data (only one should be set based on Type)
-	ModelSelector    *ModelSelectorMsg
-	ProviderSelector *ProviderSelectorMsg
-	StatusInfo       *StatusInfo
+// Restore replaces the conversation with a session
+type Restore struct {
+	Session *session.Session
+	Notice  string // Shown once the conversation is replaced
 }
 
+// ShowModelSelector opens the model selector
+type ShowModelSelector struct {
+	Selector *ModelSelectorMsg
+}
+
+// ShowProviderSelector opens the provider selector
+type ShowProviderSelector struct {
+	Selector *ProviderSelectorMsg
+}
+
+// ShowHistory opens the list of past prompts
+type ShowHistory struct {
+	Picker *HistoryPickerMsg
+}
+
+// ShowSearch opens the results of /search
+type ShowSearch struct {
+	Results *SearchResultsMsg
+}
+
+// ShowStatus shows the session status
+type ShowStatus struct {
+	Info *StatusInfo
+}
+
+// ShowComparison shows the responses of /compare side by side
+type ShowComparison struct {
+	Responses []ComparisonResponse // One per model
+}
+
+// ShowReview shows the findings of /review
+type ShowReview struct {
+	Review *Review
+}
+
+// ThemeChanged reports a theme switch, for frontends to restyle
+type ThemeChanged struct {
+	Content string
+}
+
+// ClearChat reports that the chat history was cleared
+type ClearChat struct{}
+
+// ClearInputHistory clears the frontend's command input history
+type ClearInputHistory struct{}
+
+// Quit ends the session
+type Quit struct{}
+
+func (Response) isResult()             {}
+func (Failure) isResult()              {}
+func (Async) isResult()                {}
+func (Request) isResult()              {}
+func (Retry) isResult()                {}
+func (EditLast) isResult()             {}
+func (RestoreDraft) isResult()         {}
+func (Restore) isResult()              {}
+func (ShowModelSelector) isResult()    {}
+func (ShowProviderSelector) isResult() {}
+func (ShowHistory) isResult()          {}
+func (ShowSearch) isResult()           {}
+func (ShowStatus) isResult()           {}
+func (ShowComparison) isResult()       {}
+func (ShowReview) isResult()           {}
+func (ThemeChanged) isResult()         {}
+func (ClearChat) isResult()            {}
+func (ClearInputHistory) isResult()    {}
+func (Quit) isResult()                 {}
+
 // ModelSelectorMsg represents a model selection request
 type ModelSelectorMsg struct {
 	CurrentModel string
@@ -25,6 +143,18 @@ type ModelSelectorMsg struct {
 	Error        error
 }
 
+// HistoryPickerMsg opens the list of past prompts
+type HistoryPickerMsg struct {
+	Entries []history.Entry // Newest first
+}
+
+// SearchResultsMsg opens the list of exchanges /search found
+type SearchResultsMsg struct {
+	Query   string
+	Hits    []session.Hit // Best match first
+	Current string        // ID of the current conversation's session
+}
+
 // ProviderSelectorMsg represents a provider selection request
 type ProviderSelectorMsg struct {
 	CurrentProvider llm.Provider
@@ -35,6 +165,7 @@ type ProviderSelectorMsg struct {
 type StatusInfo struct {
 	Provider              string
 	Model                 string
+	Persona               string
 	MessageCount          int
 	UserTokens            int
 	AssistantTokens       int
@@ -43,4 +174,14 @@ type StatusInfo struct {
 	PersistenceEnabled    bool
 	LogLevel              string
 	RepositoryInitialized bool
+	Workspace             []workspace.Root // Primary root first
+
+	// How AGENTS.md was generated, nil if it has no metadata, and why it
+	// should be refreshed, if it should
+	AgentsMetadata *analyzer.Metadata
+	AgentsWarning  string
+
+	// Anthropic prompt cache usage; nil for other providers or before the
+	// first request
+	PromptCache *llm.PromptCacheStats
 }
@@ -0,0 +1,64 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mizzy/rigel/internal/sandbox"
+	"github.com/mizzy/rigel/internal/workspace"
+)
+
+const workspaceUsage = "Usage: /workspace [add <path> [--read-only] | remove <label>]"
+
+// manageWorkspace lists the workspace roots, or adds or removes one
+func manageWorkspace(ws *workspace.Workspace, args []string, readOnly bool) Result {
+	if ws == nil {
+		return Failure{Err: fmt.Errorf("no workspace available")}
+	}
+	if len(args) == 0 {
+		return Response{Content: formatWorkspace(ws.Roots())}
+	}
+
+	if len(args) != 2 {
+		return Response{Content: workspaceUsage}
+	}
+	switch args[0] {
+	case "add":
+		// The sandbox profile is fixed when rigel starts, so a root added
+		// later can't be written to anyway
+		sandboxed := sandbox.IsSandboxed() && !readOnly
+		root, err := ws.Add(args[1], readOnly || sandboxed)
+		if err != nil {
+			return Failure{Err: err}
+		}
+		content := fmt.Sprintf("Added %s as %s. Address its files as %s:<path>.", root.Path, root.Label, root.Label)
+		if sandboxed {
+			content += fmt.Sprintf("\nThe sandbox only allows writes to roots given at startup, so it is read-only; restart with --workspace %s to make it writable.", args[1])
+		}
+		return Response{Content: content}
+	case "remove":
+		if err := ws.Remove(args[1]); err != nil {
+			return Failure{Err: err}
+		}
+		return Response{Content: fmt.Sprintf("Removed %s from the workspace.", args[1])}
+	default:
+		return Response{Content: workspaceUsage}
+	}
+}
+
+// formatWorkspace lists roots with their labels and permissions
+func formatWorkspace(roots []workspace.Root) string {
+	var sb strings.Builder
+	sb.WriteString("Workspace roots:\n")
+	for i, root := range roots {
+		sb.WriteString(fmt.Sprintf("  %s: %s", root.Label, root.Path))
+		if i == 0 {
+			sb.WriteString(" (primary)")
+		}
+		if root.ReadOnly {
+			sb.WriteString(" (read-only)")
+		}
+		sb.WriteString("\n")
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
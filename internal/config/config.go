@@ -2,13 +2,88 @@ package config
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/mizzy/rigel/internal/credentials"
 	"github.com/spf13/viper"
 )
 
+// Editing modes of the input area
+const (
+	EditingModeEmacs = "emacs"
+	EditingModeVi    = "vi"
+)
+
+// Interfaces the interactive chat runs in
+const (
+	UITUI      = "tui"      // The Bubbletea TUI
+	UITermflow = "termflow" // Inline, keeping the terminal's scrollback
+	UIPlain    = "plain"    // Plain lines, as when stdout isn't a terminal
+)
+
+// ValidUI reports whether ui names one of the interfaces
+func ValidUI(ui string) bool {
+	return ui == UITUI || ui == UITermflow || ui == UIPlain
+}
+
+// Which prompts the input history holds
+const (
+	HistoryScopeProject = "project" // Prompts entered in the current git repository
+	HistoryScopeGlobal  = "global"  // Prompts from every project
+)
+
+// Backends the agent may search the web with
+const (
+	WebSearchSearxNG    = "searxng"    // A SearxNG instance, at WebSearchURL
+	WebSearchBrave      = "brave"      // The Brave Search API, with BraveAPIKey
+	WebSearchDuckDuckGo = "duckduckgo" // DuckDuckGo's HTML results
+)
+
+// How streamed responses are revealed in the terminal
+const (
+	StreamInstant        = "instant"         // Each piece as soon as it arrives
+	StreamSmooth         = "smooth"          // At a steady pace that catches up with the model
+	StreamCharacterDelay = "character-delay" // One character every StreamCharDelay
+)
+
+// When the agent asks the model which tools a prompt needs
+const (
+	IntentAnalysisAuto   = "auto"   // Unless the prompt plainly needs none, in the request answering it when the model can use tools
+	IntentAnalysisAlways = "always" // In a request of its own for every prompt
+	IntentAnalysisOff    = "off"    // Never; the agent only answers
+)
+
+// Built-in middleware the requests to the provider and its responses pass
+// through
+const (
+	MiddlewareAgentsMD      = "agents-md"      // AGENTS.md and the memory notes before the system prompt
+	MiddlewareStripThinking = "strip-thinking" // Removes the <think> sections of reasoning models from responses
+	MiddlewareLog           = "log"            // Logs requests and responses at info level
+)
+
+// MiddlewareExecPrefix starts middleware that runs a command, e.g.
+// "exec:~/bin/rigel-hook", as a hook
+const MiddlewareExecPrefix = "exec:"
+
+// Ways of alerting the user when a long response is ready
+const (
+	NotifyBell   = "bell"   // Terminal bell
+	NotifyOSC9   = "osc9"   // Desktop notification via OSC 9 (iTerm2, Windows Terminal)
+	NotifyOSC777 = "osc777" // Desktop notification via OSC 777 (VTE, kitty, WezTerm)
+)
+
+// Default timeouts, used where no configuration is at hand
+const (
+	DefaultConnectTimeout    = 10 * time.Second
+	DefaultGenerationTimeout = 15 * time.Minute
+	DefaultToolTimeout       = 10 * time.Minute
+)
+
 type Config struct {
 	Provider        string
 	AnthropicAPIKey string
@@ -16,8 +91,146 @@ type Config struct {
 	GoogleAPIKey    string
 	AzureAPIKey     string
 	OllamaBaseURL   string
-	Model           string
-	LogLevel        string
+
+	// Server speaking OpenAI's chat completions API, e.g. LM Studio, vLLM or
+	// llama.cpp; the API key is optional
+	OpenAICompatibleBaseURL string
+	OpenAICompatibleAPIKey  string
+
+	Model string
+
+	// Default models by provider, from e.g. OLLAMA_MODEL, and the models last
+	// chosen with /model; see ModelFor
+	ProviderModels map[string]string
+	LastModels     map[string]string
+
+	// Requests and tokens per minute providers accept, from e.g.
+	// ANTHROPIC_RPM and ANTHROPIC_TPM; requests beyond them wait
+	RateLimits map[string]RateLimit
+
+	LogLevel      string
+	Theme         string
+	UI            string // UITUI, UITermflow or UIPlain
+	EditingMode   string // EditingModeEmacs or EditingModeVi
+	Mouse         bool   // Capture the mouse in the TUI
+	AltScreen     bool   // Run the TUI full screen, printing the chat on exit
+	TranscriptLog string // File the TUI appends the conversation to on exit
+	StatusBar     bool   // Show a status line above the termflow prompt
+	Hints         bool   // Show rotating tips in the empty input
+	ShowMeta      bool   // Show the model, latency and tokens after each response
+	HistoryScope  string // HistoryScopeProject or HistoryScopeGlobal
+	Redact        bool   // Mask API keys and tokens in prompts and saved files
+	Encrypt       bool   // Encrypt the history and sessions with a keychain key
+	CacheEnabled  bool
+	CacheTTL      time.Duration
+	CompareModels []string // Default models for /compare
+
+	// Providers to fall back to, in order, when the primary provider is
+	// unavailable, as "provider" or "provider/model"
+	FallbackProviders []string
+
+	// Command the run_tests tool runs; detected from the project if empty
+	TestCommand string
+
+	// Build and lint commands the check_code tool runs; detected from the
+	// project if empty
+	CheckCommands []string
+
+	// How many times the agent may try to fix build and lint problems in
+	// files it wrote; 0 disables the fix loop
+	MaxFixIterations int
+
+	// How many times a response cut off at the maximum output length is
+	// continued; 0 leaves it cut off
+	MaxContinuations int
+
+	// How many sub-agents may work on independent subtasks at once
+	MaxSubagents int
+
+	// Largest file the agent may read and largest content it may write, in
+	// bytes; 0 removes the limit
+	MaxReadSize  int
+	MaxWriteSize int
+
+	// Language server used to look up the symbols a prompt mentions; "off"
+	// disables it
+	LSPCommand string
+
+	// How the user is alerted when a response that took at least
+	// NotifyAfter is ready while the terminal isn't focused; empty disables
+	Notify      []string
+	NotifyAfter time.Duration
+
+	// How streamed responses are revealed: StreamInstant, StreamSmooth or
+	// StreamCharacterDelay, which waits StreamCharDelay between characters
+	StreamRender    string
+	StreamCharDelay time.Duration
+
+	// How long reaching a provider may take, including quick requests such
+	// as listing its models; how long answering a prompt may take, tools
+	// included; and how long each tool may run. 0 removes the limit.
+	ConnectTimeout    time.Duration
+	GenerationTimeout time.Duration
+	ToolTimeout       time.Duration
+
+	// Persona the agent answers with unless another was chosen in the
+	// project with /persona; empty for the default
+	Persona string
+
+	// Extra workspace roots given with --workspace, as path or path:ro
+	Workspaces []string
+
+	// The working directory isn't trusted: it is read-only to the agent,
+	// which runs no commands in it, and its AGENTS.md and memory notes
+	// aren't loaded
+	ReadOnly bool
+
+	// Describe the tool operations the agent would perform instead of
+	// running them; set with --dry-run
+	DryRun bool
+
+	// Sample at temperature 0 with a fixed seed and cache the responses, for
+	// reproducible runs; set with --deterministic or /set deterministic
+	Deterministic bool
+
+	// Token for the GitHub API used by /pr and /issue, and the API's URL
+	// for GitHub Enterprise Server; empty for github.com
+	GitHubToken  string
+	GitHubAPIURL string
+
+	// Tell at startup when a newer release of rigel is available
+	UpdateCheck bool
+
+	// Keep local usage statistics for /stats in ~/.rigel/stats.json
+	Stats bool
+
+	// Backend of the web_search tool; empty disables web search
+	WebSearch    string
+	WebSearchURL string // URL of the SearxNG instance
+	BraveAPIKey  string
+
+	// Show the agent's plan before it runs, so the user can approve, reorder
+	// or remove steps
+	ReviewPlans bool
+
+	// After each response, offer to save the files it gives in code blocks
+	// named for them, as /save-code does
+	SaveCode bool
+
+	// When the agent works out which tools a prompt needs: IntentAnalysisAuto,
+	// IntentAnalysisAlways or IntentAnalysisOff
+	IntentAnalysis string
+
+	// Middleware the requests to the provider pass through, in order, and
+	// the responses in reverse order: built-in names or exec: hooks
+	Middleware []string
+
+	// Ollama request options; zero values use the model's defaults
+	OllamaNumCtx    int
+	OllamaTopP      float64
+	OllamaTopK      int
+	OllamaSeed      int
+	OllamaKeepAlive string
 }
 
 func Load(configFile string) (*Config, error) {
@@ -30,28 +243,247 @@ func Load(configFile string) (*Config, error) {
 			return nil, fmt.Errorf("error loading .env file: %w", err)
 		}
 	}
+	return LoadEnv()
+}
 
+// LoadEnv reads the configuration from the environment alone, without a
+// .env file, as in directories that aren't trusted
+func LoadEnv() (*Config, error) {
 	viper.SetEnvPrefix("RIGEL")
 	viper.AutomaticEnv()
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 
 	cfg := &Config{
-		Provider:        getEnv("PROVIDER", "ollama"),
-		AnthropicAPIKey: os.Getenv("ANTHROPIC_API_KEY"),
-		OpenAIAPIKey:    os.Getenv("OPENAI_API_KEY"),
-		GoogleAPIKey:    os.Getenv("GOOGLE_API_KEY"),
-		AzureAPIKey:     os.Getenv("AZURE_OPENAI_API_KEY"),
-		OllamaBaseURL:   getEnv("OLLAMA_BASE_URL", "http://localhost:11434"),
-		Model:           getEnv("MODEL", ""),
-		LogLevel:        getEnv("RIGEL_LOG_LEVEL", "info"),
+		Provider:                getEnv("PROVIDER", "ollama"),
+		AnthropicAPIKey:         os.Getenv("ANTHROPIC_API_KEY"),
+		OpenAIAPIKey:            os.Getenv("OPENAI_API_KEY"),
+		GoogleAPIKey:            os.Getenv("GOOGLE_API_KEY"),
+		AzureAPIKey:             os.Getenv("AZURE_OPENAI_API_KEY"),
+		OllamaBaseURL:           getEnv("OLLAMA_BASE_URL", "http://localhost:11434"),
+		OpenAICompatibleBaseURL: getEnv("OPENAI_COMPATIBLE_BASE_URL", "http://localhost:1234/v1"),
+		OpenAICompatibleAPIKey:  os.Getenv("OPENAI_COMPATIBLE_API_KEY"),
+		Model:                   getEnv("MODEL", ""),
+		LogLevel:                getEnv("RIGEL_LOG_LEVEL", "info"),
+		Theme:                   getEnv("RIGEL_THEME", "dark"),
+		UI:                      getEnv("RIGEL_UI", UITUI),
+		EditingMode:             getEnv("RIGEL_EDITING_MODE", EditingModeEmacs),
+		Mouse:                   getEnvBool("RIGEL_MOUSE", false),
+		AltScreen:               getEnvBool("RIGEL_ALT_SCREEN", false),
+		TranscriptLog:           os.Getenv("RIGEL_TRANSCRIPT_LOG"),
+		StatusBar:               getEnvBool("RIGEL_STATUS_BAR", false),
+		Hints:                   getEnvBool("RIGEL_HINTS", true),
+		ShowMeta:                getEnvBool("RIGEL_SHOW_META", false),
+		HistoryScope:            getEnv("RIGEL_HISTORY", HistoryScopeProject),
+		StreamRender:            getEnv("RIGEL_STREAM_RENDER", StreamInstant),
+		StreamCharDelay:         10 * time.Millisecond,
+		Persona:                 os.Getenv("RIGEL_PERSONA"),
+		Redact:                  getEnvBool("RIGEL_REDACT", true),
+		Encrypt:                 getEnvBool("RIGEL_ENCRYPT", false),
+		CacheEnabled:            getEnvBool("RIGEL_CACHE", false),
+		CompareModels:           getEnvList("RIGEL_COMPARE_MODELS"),
+		FallbackProviders:       getEnvList("RIGEL_FALLBACK_PROVIDERS"),
+		TestCommand:             os.Getenv("RIGEL_TEST_COMMAND"),
+		CheckCommands:           getEnvList("RIGEL_CHECK_COMMANDS"),
+		MaxFixIterations:        3,
+		MaxContinuations:        3,
+		MaxSubagents:            3,
+		MaxReadSize:             256 * 1024,
+		MaxWriteSize:            1024 * 1024,
+		ReviewPlans:             getEnvBool("RIGEL_REVIEW_PLANS", true),
+		SaveCode:                getEnvBool("RIGEL_SAVE_CODE", false),
+		IntentAnalysis:          getEnv("RIGEL_INTENT_ANALYSIS", IntentAnalysisAuto),
+		GitHubToken:             os.Getenv("GITHUB_TOKEN"),
+		GitHubAPIURL:            os.Getenv("RIGEL_GITHUB_API_URL"),
+		UpdateCheck:             getEnvBool("RIGEL_UPDATE_CHECK", true),
+		Stats:                   getEnvBool("RIGEL_STATS", true),
+		WebSearch:               os.Getenv("RIGEL_WEB_SEARCH"),
+		WebSearchURL:            os.Getenv("RIGEL_WEB_SEARCH_URL"),
+		BraveAPIKey:             os.Getenv("BRAVE_API_KEY"),
+		LSPCommand:              getEnv("RIGEL_LSP", "gopls"),
+		OllamaKeepAlive:         os.Getenv("OLLAMA_KEEP_ALIVE"),
+		Middleware:              []string{MiddlewareAgentsMD},
+		Notify:                  []string{NotifyBell, NotifyOSC777},
+		NotifyAfter:             10 * time.Second,
+		ConnectTimeout:          DefaultConnectTimeout,
+		GenerationTimeout:       DefaultGenerationTimeout,
+		ToolTimeout:             DefaultToolTimeout,
+	}
+
+	// Keys stored with `rigel auth login` take precedence over the environment
+	keychain := credentials.Keychain()
+	for name, target := range map[string]*string{
+		"anthropic": &cfg.AnthropicAPIKey,
+		"openai":    &cfg.OpenAIAPIKey,
+		"google":    &cfg.GoogleAPIKey,
+		"azure":     &cfg.AzureAPIKey,
+
+		"openai-compatible": &cfg.OpenAICompatibleAPIKey,
+		"github":            &cfg.GitHubToken,
+	} {
+		provider, _ := credentials.Find(name)
+		if key, source, _ := credentials.Resolve(keychain, provider); source == credentials.SourceKeychain {
+			*target = key
+		}
+	}
+
+	for key, target := range map[string]*int{
+		"OLLAMA_NUM_CTX": &cfg.OllamaNumCtx,
+		"OLLAMA_TOP_K":   &cfg.OllamaTopK,
+		"OLLAMA_SEED":    &cfg.OllamaSeed,
+
+		"RIGEL_MAX_FIX_ITERATIONS": &cfg.MaxFixIterations,
+		"RIGEL_MAX_CONTINUATIONS":  &cfg.MaxContinuations,
+		"RIGEL_MAX_SUBAGENTS":      &cfg.MaxSubagents,
+		"RIGEL_MAX_READ_SIZE":      &cfg.MaxReadSize,
+		"RIGEL_MAX_WRITE_SIZE":     &cfg.MaxWriteSize,
+	} {
+		if value := os.Getenv(key); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q: %w", key, value, err)
+			}
+			*target = n
+		}
+	}
+	if value := os.Getenv("OLLAMA_TOP_P"); value != "" {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OLLAMA_TOP_P %q: %w", value, err)
+		}
+		cfg.OllamaTopP = f
+	}
+
+	if cfg.MaxContinuations < 0 {
+		return nil, fmt.Errorf("invalid RIGEL_MAX_CONTINUATIONS %d: must not be negative", cfg.MaxContinuations)
+	}
+	if cfg.MaxSubagents < 1 {
+		return nil, fmt.Errorf("invalid RIGEL_MAX_SUBAGENTS %d: must be at least 1", cfg.MaxSubagents)
+	}
+
+	if !ValidUI(cfg.UI) {
+		return nil, fmt.Errorf("invalid RIGEL_UI %q: must be tui, termflow or plain", cfg.UI)
+	}
+	if cfg.EditingMode != EditingModeEmacs && cfg.EditingMode != EditingModeVi {
+		return nil, fmt.Errorf("invalid RIGEL_EDITING_MODE %q: must be emacs or vi", cfg.EditingMode)
+	}
+
+	switch cfg.WebSearch {
+	case "", WebSearchDuckDuckGo:
+	case WebSearchSearxNG:
+		if cfg.WebSearchURL == "" {
+			return nil, fmt.Errorf("RIGEL_WEB_SEARCH=searxng needs the instance's URL in RIGEL_WEB_SEARCH_URL")
+		}
+	case WebSearchBrave:
+		if cfg.BraveAPIKey == "" {
+			return nil, fmt.Errorf("RIGEL_WEB_SEARCH=brave needs an API key in BRAVE_API_KEY")
+		}
+	default:
+		return nil, fmt.Errorf("invalid RIGEL_WEB_SEARCH %q: must be searxng, brave or duckduckgo", cfg.WebSearch)
+	}
+
+	if cfg.HistoryScope != HistoryScopeProject && cfg.HistoryScope != HistoryScopeGlobal {
+		return nil, fmt.Errorf("invalid RIGEL_HISTORY %q: must be project or global", cfg.HistoryScope)
+	}
+
+	switch cfg.IntentAnalysis {
+	case IntentAnalysisAuto, IntentAnalysisAlways, IntentAnalysisOff:
+	default:
+		return nil, fmt.Errorf("invalid RIGEL_INTENT_ANALYSIS %q: must be auto, always or off", cfg.IntentAnalysis)
 	}
 
-	if cfg.Provider == "anthropic" && cfg.Model == "" {
-		cfg.Model = "claude-sonnet-4-20250514"
-	} else if cfg.Provider == "openai" && cfg.Model == "" {
-		cfg.Model = "gpt-4-turbo-preview"
-	} else if cfg.Provider == "ollama" && cfg.Model == "" {
-		cfg.Model = "gpt-oss:20b"
+	switch cfg.StreamRender {
+	case StreamInstant, StreamSmooth, StreamCharacterDelay:
+	default:
+		return nil, fmt.Errorf("invalid RIGEL_STREAM_RENDER %q: must be instant, smooth or character-delay", cfg.StreamRender)
+	}
+	if delay := os.Getenv("RIGEL_STREAM_CHAR_DELAY"); delay != "" {
+		d, err := time.ParseDuration(delay)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RIGEL_STREAM_CHAR_DELAY %q: %w", delay, err)
+		}
+		cfg.StreamCharDelay = d
+	}
+
+	if value := os.Getenv("RIGEL_MIDDLEWARE"); value != "" {
+		cfg.Middleware = nil
+		if value != "off" {
+			for _, name := range getEnvList("RIGEL_MIDDLEWARE") {
+				switch {
+				case name == MiddlewareAgentsMD, name == MiddlewareStripThinking, name == MiddlewareLog:
+				case strings.HasPrefix(name, MiddlewareExecPrefix) && strings.TrimPrefix(name, MiddlewareExecPrefix) != "":
+				default:
+					return nil, fmt.Errorf("invalid RIGEL_MIDDLEWARE %q: must be off or a list of agents-md, strip-thinking, log and exec:command", value)
+				}
+				cfg.Middleware = append(cfg.Middleware, name)
+			}
+		}
+	}
+
+	if value := os.Getenv("RIGEL_NOTIFY"); value != "" {
+		cfg.Notify = nil
+		if value != "off" {
+			for _, method := range getEnvList("RIGEL_NOTIFY") {
+				if method != NotifyBell && method != NotifyOSC9 && method != NotifyOSC777 {
+					return nil, fmt.Errorf("invalid RIGEL_NOTIFY %q: must be off or a list of bell, osc9 and osc777", value)
+				}
+				cfg.Notify = append(cfg.Notify, method)
+			}
+		}
+	}
+	if after := os.Getenv("RIGEL_NOTIFY_AFTER"); after != "" {
+		d, err := time.ParseDuration(after)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RIGEL_NOTIFY_AFTER %q: %w", after, err)
+		}
+		cfg.NotifyAfter = d
+	}
+
+	for key, target := range map[string]*time.Duration{
+		"RIGEL_CONNECT_TIMEOUT":    &cfg.ConnectTimeout,
+		"RIGEL_GENERATION_TIMEOUT": &cfg.GenerationTimeout,
+		"RIGEL_TOOL_TIMEOUT":       &cfg.ToolTimeout,
+	} {
+		if value := os.Getenv(key); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q: %w", key, value, err)
+			}
+			if d < 0 {
+				return nil, fmt.Errorf("invalid %s %q: must not be negative", key, value)
+			}
+			*target = d
+		}
+	}
+
+	if ttl := os.Getenv("RIGEL_CACHE_TTL"); ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RIGEL_CACHE_TTL %q: %w", ttl, err)
+		}
+		cfg.CacheTTL = d
+	}
+
+	cfg.ProviderModels = map[string]string{}
+	for _, provider := range providers {
+		if model := os.Getenv(providerModelEnv(provider)); model != "" {
+			cfg.ProviderModels[provider] = model
+		}
+	}
+	rateLimits, err := loadRateLimits()
+	if err != nil {
+		return nil, err
+	}
+	cfg.RateLimits = rateLimits
+	// A damaged file only loses the remembered models
+	lastModels, err := loadLastModels()
+	if err != nil {
+		slog.Warn("ignoring remembered models", "error", err)
+		lastModels = map[string]string{}
+	}
+	cfg.LastModels = lastModels
+
+	if cfg.Model == "" {
+		cfg.Model = cfg.ModelFor(cfg.Provider)
 	}
 
 	return cfg, nil
@@ -64,6 +496,28 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvBool(key string, defaultValue bool) bool {
+	switch strings.ToLower(os.Getenv(key)) {
+	case "1", "true", "yes", "on":
+		return true
+	case "0", "false", "no", "off":
+		return false
+	default:
+		return defaultValue
+	}
+}
+
+// getEnvList splits a comma-separated environment variable, ignoring empty entries
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 func (c *Config) Validate() error {
 	switch c.Provider {
 	case "anthropic":
@@ -84,6 +538,8 @@ func (c *Config) Validate() error {
 		}
 	case "ollama":
 		// Ollama doesn't require API key, just base URL which has a default
+	case "openai-compatible":
+		// The API key is optional and the base URL has a default
 	default:
 		return fmt.Errorf("unsupported provider: %s", c.Provider)
 	}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// defaultModels are the models providers use unless configured otherwise
+var defaultModels = map[string]string{
+	"anthropic": "claude-sonnet-4-20250514",
+	"openai":    "gpt-4-turbo-preview",
+	"ollama":    "gpt-oss:20b",
+}
+
+// providers are the providers that may be configured by name, e.g. with
+// OLLAMA_MODEL
+var providers = []string{"anthropic", "openai", "ollama", "openai-compatible"}
+
+// providerModelEnv returns the variable configuring a provider's default
+// model, e.g. OLLAMA_MODEL or OPENAI_COMPATIBLE_MODEL
+func providerModelEnv(provider string) string {
+	return providerEnv(provider, "MODEL")
+}
+
+// ModelsFilePath returns the file remembering the model last chosen for each
+// provider
+func ModelsFilePath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".rigel", "models.json"), nil
+}
+
+// loadLastModels reads the remembered models; a missing file remembers none
+func loadLastModels() (map[string]string, error) {
+	path, err := ModelsFilePath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	models := map[string]string{}
+	if err := json.Unmarshal(data, &models); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return models, nil
+}
+
+// ModelFor returns the model to use with a provider: the one last chosen
+// with /model, else the provider's configured default (such as
+// OLLAMA_MODEL), else the built-in default. It is empty for providers
+// without a default, which pick a model themselves.
+func (c *Config) ModelFor(provider string) string {
+	if model := c.LastModels[provider]; model != "" {
+		return model
+	}
+	if model := c.ProviderModels[provider]; model != "" {
+		return model
+	}
+	return defaultModels[provider]
+}
+
+// RememberModel records the model chosen for a provider, so that it is used
+// again when switching back to the provider or starting rigel
+func (c *Config) RememberModel(provider, model string) error {
+	if provider == "" || model == "" || c.LastModels[provider] == model {
+		return nil
+	}
+	if c.LastModels == nil {
+		c.LastModels = map[string]string{}
+	}
+	c.LastModels[provider] = model
+
+	// Re-read the file to keep what other sessions remembered meanwhile
+	models, err := loadLastModels()
+	if err != nil {
+		models = map[string]string{}
+	}
+	models[provider] = model
+
+	path, err := ModelsFilePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	data, err := json.MarshalIndent(models, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal models: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
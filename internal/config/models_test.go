@@ -0,0 +1,87 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModelFor(t *testing.T) {
+	cfg := &Config{
+		ProviderModels: map[string]string{"ollama": "llama3.2", "anthropic": "claude-3-5-haiku-20241022"},
+		LastModels:     map[string]string{"anthropic": "claude-opus-4-20250514"},
+	}
+
+	tests := []struct {
+		provider string
+		want     string
+	}{
+		{provider: "anthropic", want: "claude-opus-4-20250514"},
+		{provider: "ollama", want: "llama3.2"},
+		{provider: "openai", want: "gpt-4-turbo-preview"},
+		{provider: "openai-compatible", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			assert.Equal(t, tt.want, cfg.ModelFor(tt.provider))
+		})
+	}
+}
+
+func TestRememberModel(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	cfg := &Config{}
+	require.NoError(t, cfg.RememberModel("ollama", "gpt-oss:20b"))
+
+	// Another session remembers a model meanwhile
+	other := &Config{}
+	require.NoError(t, other.RememberModel("anthropic", "claude-3-5-haiku-20241022"))
+
+	require.NoError(t, cfg.RememberModel("ollama", "qwen2.5-coder"))
+	assert.Equal(t, "qwen2.5-coder", cfg.ModelFor("ollama"))
+
+	models, err := loadLastModels()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"ollama":    "qwen2.5-coder",
+		"anthropic": "claude-3-5-haiku-20241022",
+	}, models)
+}
+
+func TestLoadProviderModels(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("PROVIDER", "ollama")
+	t.Setenv("MODEL", "")
+	t.Setenv("OLLAMA_MODEL", "llama3.2")
+	t.Setenv("ANTHROPIC_MODEL", "claude-3-5-haiku-20241022")
+
+	cfg, err := Load("/nonexistent/.env")
+	require.NoError(t, err)
+	assert.Equal(t, "llama3.2", cfg.Model)
+	assert.Equal(t, "claude-3-5-haiku-20241022", cfg.ModelFor("anthropic"))
+
+	// The model chosen last time wins over the configured default
+	require.NoError(t, cfg.RememberModel("ollama", "gpt-oss:20b"))
+	cfg, err = Load("/nonexistent/.env")
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-oss:20b", cfg.Model)
+
+	// MODEL still overrides both
+	t.Setenv("MODEL", "qwen2.5-coder")
+	cfg, err = Load("/nonexistent/.env")
+	require.NoError(t, err)
+	assert.Equal(t, "qwen2.5-coder", cfg.Model)
+
+	// A damaged file is ignored
+	path, err := ModelsFilePath()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0644))
+	t.Setenv("MODEL", "")
+	cfg, err = Load(filepath.Join(t.TempDir(), ".env"))
+	require.NoError(t, err)
+	assert.Equal(t, "llama3.2", cfg.Model)
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// RateLimit bounds the requests sent to a provider each minute; zero values
+// leave a bound off
+type RateLimit struct {
+	RequestsPerMinute int
+	TokensPerMinute   int
+}
+
+// providerEnv returns a variable configuring a provider, e.g. OLLAMA_MODEL
+// or OPENAI_COMPATIBLE_RPM for the suffix MODEL or RPM
+func providerEnv(provider, suffix string) string {
+	return strings.ToUpper(strings.ReplaceAll(provider, "-", "_")) + "_" + suffix
+}
+
+// loadRateLimits reads the rate limits of the providers that have them,
+// e.g. ANTHROPIC_RPM=50 and ANTHROPIC_TPM=40000
+func loadRateLimits() (map[string]RateLimit, error) {
+	limits := map[string]RateLimit{}
+	for _, provider := range providers {
+		var limit RateLimit
+		for suffix, target := range map[string]*int{
+			"RPM": &limit.RequestsPerMinute,
+			"TPM": &limit.TokensPerMinute,
+		} {
+			key := providerEnv(provider, suffix)
+			value := os.Getenv(key)
+			if value == "" {
+				continue
+			}
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q: %w", key, value, err)
+			}
+			if n < 0 {
+				return nil, fmt.Errorf("invalid %s %q: must not be negative", key, value)
+			}
+			*target = n
+		}
+		if limit != (RateLimit{}) {
+			limits[provider] = limit
+		}
+	}
+	return limits, nil
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Setting is a variable rigel is configured with, in .env or the environment
+type Setting struct {
+	Name        string
+	Description string
+	Secret      bool // API keys and tokens, which shouldn't be shown in full
+	value       func(c *Config) string
+}
+
+// Value returns the setting's effective value in c
+func (s Setting) Value(c *Config) string {
+	return s.value(c)
+}
+
+// Settings lists the variables Load reads, other than the <PROVIDER>_MODEL
+// defaults and the <PROVIDER>_RPM and <PROVIDER>_TPM rate limits
+var Settings = []Setting{
+	{"PROVIDER", "LLM provider: anthropic, ollama or openai-compatible", false, func(c *Config) string { return c.Provider }},
+	{"MODEL", "Model of the provider", false, func(c *Config) string { return c.Model }},
+	{"ANTHROPIC_API_KEY", "Anthropic API key", true, func(c *Config) string { return c.AnthropicAPIKey }},
+	{"OPENAI_API_KEY", "OpenAI API key", true, func(c *Config) string { return c.OpenAIAPIKey }},
+	{"GOOGLE_API_KEY", "Google API key", true, func(c *Config) string { return c.GoogleAPIKey }},
+	{"AZURE_OPENAI_API_KEY", "Azure OpenAI API key", true, func(c *Config) string { return c.AzureAPIKey }},
+	{"OLLAMA_BASE_URL", "URL of the Ollama server", false, func(c *Config) string { return c.OllamaBaseURL }},
+	{"OLLAMA_NUM_CTX", "Context window of Ollama models", false, func(c *Config) string { return intValue(c.OllamaNumCtx) }},
+	{"OLLAMA_TOP_P", "Top-p sampling of Ollama models", false, func(c *Config) string { return floatValue(c.OllamaTopP) }},
+	{"OLLAMA_TOP_K", "Top-k sampling of Ollama models", false, func(c *Config) string { return intValue(c.OllamaTopK) }},
+	{"OLLAMA_SEED", "Random seed of Ollama models", false, func(c *Config) string { return intValue(c.OllamaSeed) }},
+	{"OLLAMA_KEEP_ALIVE", "How long Ollama keeps the model loaded", false, func(c *Config) string { return c.OllamaKeepAlive }},
+	{"OPENAI_COMPATIBLE_BASE_URL", "URL of an OpenAI-compatible server", false, func(c *Config) string { return c.OpenAICompatibleBaseURL }},
+	{"OPENAI_COMPATIBLE_API_KEY", "API key of the OpenAI-compatible server", true, func(c *Config) string { return c.OpenAICompatibleAPIKey }},
+	{"RIGEL_FALLBACK_PROVIDERS", "Providers to fall back to, as provider or provider/model", false, func(c *Config) string { return strings.Join(c.FallbackProviders, ",") }},
+	{"RIGEL_COMPARE_MODELS", "Default models for /compare", false, func(c *Config) string { return strings.Join(c.CompareModels, ",") }},
+	{"RIGEL_CACHE", "Cache responses on disk", false, func(c *Config) string { return strconv.FormatBool(c.CacheEnabled) }},
+	{"RIGEL_CACHE_TTL", "How long cached responses are kept", false, func(c *Config) string { return durationValue(c.CacheTTL) }},
+	{"RIGEL_LOG_LEVEL", "Log level: debug, info, warn or error", false, func(c *Config) string { return c.LogLevel }},
+	{"RIGEL_THEME", "Color theme", false, func(c *Config) string { return c.Theme }},
+	{"RIGEL_UI", "Chat interface: tui, termflow or plain", false, func(c *Config) string { return c.UI }},
+	{"RIGEL_EDITING_MODE", "Input editing keys: emacs or vi", false, func(c *Config) string { return c.EditingMode }},
+	{"RIGEL_MOUSE", "Capture the mouse in the TUI", false, func(c *Config) string { return strconv.FormatBool(c.Mouse) }},
+	{"RIGEL_ALT_SCREEN", "Run the TUI full screen, printing the chat on exit", false, func(c *Config) string { return strconv.FormatBool(c.AltScreen) }},
+	{"RIGEL_TRANSCRIPT_LOG", "File the TUI appends the conversation to on exit", false, func(c *Config) string { return c.TranscriptLog }},
+	{"RIGEL_STATUS_BAR", "Show a status line above the termflow prompt", false, func(c *Config) string { return strconv.FormatBool(c.StatusBar) }},
+	{"RIGEL_HINTS", "Show tips in the empty input", false, func(c *Config) string { return strconv.FormatBool(c.Hints) }},
+	{"RIGEL_SHOW_META", "Show the model, latency and tokens after each response", false, func(c *Config) string { return strconv.FormatBool(c.ShowMeta) }},
+	{"RIGEL_STREAM_RENDER", "How responses appear: instant, smooth or character-delay", false, func(c *Config) string { return c.StreamRender }},
+	{"RIGEL_STREAM_CHAR_DELAY", "Delay between characters with character-delay", false, func(c *Config) string { return durationValue(c.StreamCharDelay) }},
+	{"RIGEL_PERSONA", "Persona the agent answers with, unless set with /persona", false, func(c *Config) string { return c.Persona }},
+	{"RIGEL_HISTORY", "Prompts the history holds: project or global", false, func(c *Config) string { return c.HistoryScope }},
+	{"RIGEL_REDACT", "Mask API keys and tokens in prompts and saved files", false, func(c *Config) string { return strconv.FormatBool(c.Redact) }},
+	{"RIGEL_ENCRYPT", "Encrypt the history and sessions", false, func(c *Config) string { return strconv.FormatBool(c.Encrypt) }},
+	{"RIGEL_NOTIFY", "How to alert when a long response is ready", false, func(c *Config) string { return strings.Join(c.Notify, ",") }},
+	{"RIGEL_NOTIFY_AFTER", "How long a response must take to alert", false, func(c *Config) string { return durationValue(c.NotifyAfter) }},
+	{"RIGEL_CONNECT_TIMEOUT", "How long reaching the provider may take; 0 for no limit", false, func(c *Config) string { return durationValue(c.ConnectTimeout) }},
+	{"RIGEL_GENERATION_TIMEOUT", "How long answering a prompt may take; 0 for no limit", false, func(c *Config) string { return durationValue(c.GenerationTimeout) }},
+	{"RIGEL_TOOL_TIMEOUT", "How long each tool may run; 0 for no limit", false, func(c *Config) string { return durationValue(c.ToolTimeout) }},
+	{"RIGEL_REVIEW_PLANS", "Show the agent's plan before it runs", false, func(c *Config) string { return strconv.FormatBool(c.ReviewPlans) }},
+	{"RIGEL_SAVE_CODE", "Offer to save the files of code blocks after each response", false, func(c *Config) string { return strconv.FormatBool(c.SaveCode) }},
+	{"RIGEL_MIDDLEWARE", "Middleware requests and responses pass through, in order", false, func(c *Config) string { return strings.Join(c.Middleware, ",") }},
+	{"RIGEL_INTENT_ANALYSIS", "When to ask the model which tools a prompt needs: auto, always or off", false, func(c *Config) string { return c.IntentAnalysis }},
+	{"RIGEL_TEST_COMMAND", "Command the run_tests tool runs", false, func(c *Config) string { return c.TestCommand }},
+	{"RIGEL_CHECK_COMMANDS", "Build and lint commands the check_code tool runs", false, func(c *Config) string { return strings.Join(c.CheckCommands, ",") }},
+	{"RIGEL_MAX_FIX_ITERATIONS", "Attempts to fix build and lint problems", false, func(c *Config) string { return strconv.Itoa(c.MaxFixIterations) }},
+	{"RIGEL_MAX_CONTINUATIONS", "Times a response cut off at the maximum output length is continued", false, func(c *Config) string { return strconv.Itoa(c.MaxContinuations) }},
+	{"RIGEL_MAX_SUBAGENTS", "Sub-agents working at once", false, func(c *Config) string { return strconv.Itoa(c.MaxSubagents) }},
+	{"RIGEL_MAX_READ_SIZE", "Largest file the agent may read, in bytes", false, func(c *Config) string { return strconv.Itoa(c.MaxReadSize) }},
+	{"RIGEL_MAX_WRITE_SIZE", "Largest content the agent may write, in bytes", false, func(c *Config) string { return strconv.Itoa(c.MaxWriteSize) }},
+	{"RIGEL_LSP", "Language server for symbol lookup", false, func(c *Config) string { return c.LSPCommand }},
+	{"RIGEL_WEB_SEARCH", "Web search backend: searxng, brave or duckduckgo", false, func(c *Config) string { return c.WebSearch }},
+	{"RIGEL_WEB_SEARCH_URL", "URL of the SearxNG instance", false, func(c *Config) string { return c.WebSearchURL }},
+	{"BRAVE_API_KEY", "Brave Search API key", true, func(c *Config) string { return c.BraveAPIKey }},
+	{"GITHUB_TOKEN", "GitHub token for /pr and /issue", true, func(c *Config) string { return c.GitHubToken }},
+	{"RIGEL_GITHUB_API_URL", "API of a GitHub Enterprise Server", false, func(c *Config) string { return c.GitHubAPIURL }},
+	{"RIGEL_UPDATE_CHECK", "Tell at startup when a new release is available", false, func(c *Config) string { return strconv.FormatBool(c.UpdateCheck) }},
+	{"RIGEL_STATS", "Keep local usage statistics for /stats", false, func(c *Config) string { return strconv.FormatBool(c.Stats) }},
+}
+
+// FindSetting looks up a setting by name
+func FindSetting(name string) (Setting, bool) {
+	for _, s := range Settings {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return Setting{}, false
+}
+
+// intValue formats a number that is unset when zero
+func intValue(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+func floatValue(f float64) string {
+	if f == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func durationValue(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return d.String()
+}
@@ -0,0 +1,151 @@
+// Package credentials stores provider API keys in the OS keychain: the
+// macOS Keychain, the Secret Service on Linux or the Windows Credential
+// Manager. Keys not found there are taken from environment variables.
+package credentials
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/zalando/go-keyring"
+)
+
+// Service is the name keys are stored under in the keychain
+const Service = "rigel"
+
+// keychainTimeout limits how long a keychain operation may take, since the
+// Secret Service can wait indefinitely for the keyring to be unlocked
+const keychainTimeout = 5 * time.Second
+
+// ErrNotFound is returned when no key is stored for a provider
+var ErrNotFound = errors.New("no key stored")
+
+// Provider is a provider that authenticates with an API key
+type Provider struct {
+	Name   string
+	EnvVar string // Environment variable that can hold the key instead
+}
+
+// Providers lists the providers that authenticate with an API key
+var Providers = []Provider{
+	{Name: "anthropic", EnvVar: "ANTHROPIC_API_KEY"},
+	{Name: "openai", EnvVar: "OPENAI_API_KEY"},
+	{Name: "google", EnvVar: "GOOGLE_API_KEY"},
+	{Name: "azure", EnvVar: "AZURE_OPENAI_API_KEY"},
+	{Name: "openai-compatible", EnvVar: "OPENAI_COMPATIBLE_API_KEY"},
+	{Name: "github", EnvVar: "GITHUB_TOKEN"}, // For /pr and /issue
+}
+
+// Find returns the provider with the given name
+func Find(name string) (Provider, bool) {
+	for _, p := range Providers {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Provider{}, false
+}
+
+// Store keeps API keys by provider name
+type Store interface {
+	Get(provider string) (string, error)
+	Set(provider, key string) error
+	Delete(provider string) error
+}
+
+// Keychain returns the store backed by the OS keychain, or a store that is
+// always unavailable if RIGEL_KEYCHAIN is off
+func Keychain() Store {
+	switch os.Getenv("RIGEL_KEYCHAIN") {
+	case "0", "false", "no", "off":
+		return disabled{}
+	}
+	return keychain{}
+}
+
+// keychain stores keys in the OS keychain
+type keychain struct{}
+
+func (keychain) Get(provider string) (string, error) {
+	var key string
+	err := withTimeout(func() error {
+		var err error
+		key, err = keyring.Get(Service, provider)
+		return err
+	})
+	return key, err
+}
+
+func (keychain) Set(provider, key string) error {
+	return withTimeout(func() error { return keyring.Set(Service, provider, key) })
+}
+
+func (keychain) Delete(provider string) error {
+	return withTimeout(func() error { return keyring.Delete(Service, provider) })
+}
+
+// withTimeout runs a keychain operation, giving up after keychainTimeout
+func withTimeout(op func() error) error {
+	done := make(chan error, 1)
+	go func() { done <- op() }()
+
+	select {
+	case err := <-done:
+		if errors.Is(err, keyring.ErrNotFound) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("keychain unavailable: %w", err)
+		}
+		return nil
+	case <-time.After(keychainTimeout):
+		return errors.New("keychain unavailable: timed out")
+	}
+}
+
+// disabled is the store used when the keychain is turned off
+type disabled struct{}
+
+var errDisabled = errors.New("keychain disabled by RIGEL_KEYCHAIN")
+
+func (disabled) Get(string) (string, error) { return "", errDisabled }
+func (disabled) Set(string, string) error   { return errDisabled }
+func (disabled) Delete(string) error        { return errDisabled }
+
+// Source tells where a key was found
+type Source string
+
+const (
+	SourceNone        Source = ""
+	SourceKeychain    Source = "keychain"
+	SourceEnvironment Source = "environment"
+)
+
+// Resolve returns the key of a provider from the store, falling back to its
+// environment variable if the store has none or is unavailable. The error
+// reports why the store couldn't be used, if it wasn't for lack of a key.
+func Resolve(store Store, provider Provider) (string, Source, error) {
+	key, err := store.Get(provider.Name)
+	if err == nil && key != "" {
+		return key, SourceKeychain, nil
+	}
+	if errors.Is(err, ErrNotFound) {
+		err = nil
+	}
+
+	if key := os.Getenv(provider.EnvVar); key != "" {
+		return key, SourceEnvironment, err
+	}
+	return "", SourceNone, err
+}
+
+// Mask shows only the start and the last four characters of a key
+func Mask(key string) string {
+	if len(key) <= 12 {
+		return strings.Repeat("*", len(key))
+	}
+	return key[:7] + "..." + key[len(key)-4:]
+}
@@ -0,0 +1,63 @@
+package credentials
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zalando/go-keyring"
+)
+
+func TestKeychain(t *testing.T) {
+	keyring.MockInit()
+	store := Keychain()
+
+	_, err := store.Get("anthropic")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, store.Set("anthropic", "sk-ant-stored"))
+	key, err := store.Get("anthropic")
+	require.NoError(t, err)
+	assert.Equal(t, "sk-ant-stored", key)
+
+	require.NoError(t, store.Delete("anthropic"))
+	assert.ErrorIs(t, store.Delete("anthropic"), ErrNotFound)
+}
+
+func TestKeychainDisabled(t *testing.T) {
+	t.Setenv("RIGEL_KEYCHAIN", "off")
+	_, err := Keychain().Get("anthropic")
+	assert.ErrorIs(t, err, errDisabled)
+}
+
+func TestResolve(t *testing.T) {
+	keyring.MockInit()
+	store := Keychain()
+	anthropic, ok := Find("anthropic")
+	require.True(t, ok)
+	t.Setenv("ANTHROPIC_API_KEY", "")
+
+	key, source, err := Resolve(store, anthropic)
+	assert.NoError(t, err)
+	assert.Equal(t, SourceNone, source)
+	assert.Empty(t, key)
+
+	t.Setenv("ANTHROPIC_API_KEY", "sk-ant-env")
+	key, source, err = Resolve(store, anthropic)
+	assert.NoError(t, err)
+	assert.Equal(t, SourceEnvironment, source)
+	assert.Equal(t, "sk-ant-env", key)
+
+	require.NoError(t, store.Set("anthropic", "sk-ant-stored"))
+	key, source, err = Resolve(store, anthropic)
+	assert.NoError(t, err)
+	assert.Equal(t, SourceKeychain, source)
+	assert.Equal(t, "sk-ant-stored", key)
+
+	keyring.MockInitWithError(errors.New("no secret service"))
+	key, source, err = Resolve(Keychain(), anthropic)
+	assert.ErrorContains(t, err, "keychain unavailable")
+	assert.Equal(t, SourceEnvironment, source)
+	assert.Equal(t, "sk-ant-env", key)
+}
@@ -0,0 +1,249 @@
+// Package doctor checks rigel's configuration and environment and reports
+// problems with suggestions for fixing them.
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/mizzy/rigel/internal/config"
+	"github.com/mizzy/rigel/internal/credentials"
+	"github.com/mizzy/rigel/internal/history"
+	"github.com/mizzy/rigel/internal/llm"
+	"github.com/mizzy/rigel/internal/sandbox"
+)
+
+// Status is the outcome of a check
+type Status int
+
+const (
+	StatusOK Status = iota
+	StatusWarn
+	StatusFail
+)
+
+// Symbol returns the marker shown before a check in the report
+func (s Status) Symbol() string {
+	switch s {
+	case StatusWarn:
+		return "!"
+	case StatusFail:
+		return "✗"
+	default:
+		return "✓"
+	}
+}
+
+// Check is the result of one diagnostic
+type Check struct {
+	Name   string
+	Status Status
+	Detail string
+}
+
+// Report is the result of all diagnostics, in the order they ran
+type Report struct {
+	Checks []Check
+}
+
+func (r *Report) add(name string, status Status, format string, args ...interface{}) {
+	r.Checks = append(r.Checks, Check{Name: name, Status: status, Detail: fmt.Sprintf(format, args...)})
+}
+
+// Failed reports whether any check failed
+func (r *Report) Failed() bool {
+	for _, check := range r.Checks {
+		if check.Status == StatusFail {
+			return true
+		}
+	}
+	return false
+}
+
+// String renders the report, one check per line, followed by a summary
+func (r *Report) String() string {
+	var sb strings.Builder
+	failures, warnings := 0, 0
+	for _, check := range r.Checks {
+		sb.WriteString(fmt.Sprintf("%s %s: %s\n", check.Status.Symbol(), check.Name, check.Detail))
+		switch check.Status {
+		case StatusFail:
+			failures++
+		case StatusWarn:
+			warnings++
+		}
+	}
+
+	sb.WriteString("\n")
+	switch {
+	case failures > 0:
+		sb.WriteString(fmt.Sprintf("%d problem(s) and %d warning(s) found.", failures, warnings))
+	case warnings > 0:
+		sb.WriteString(fmt.Sprintf("No problems found, %d warning(s).", warnings))
+	default:
+		sb.WriteString("All checks passed.")
+	}
+	return sb.String()
+}
+
+// Run checks the configuration, API keys, provider connectivity, the
+// sandbox and the history file. If provider is nil, one is created from cfg.
+func Run(ctx context.Context, cfg *config.Config, provider llm.Provider) *Report {
+	report := &Report{}
+	if cfg == nil {
+		report.add("Configuration", StatusFail, "no configuration loaded; check your .env file")
+		return report
+	}
+
+	if err := cfg.Validate(); err != nil {
+		report.add("Configuration", StatusFail, "%v", err)
+	} else {
+		report.add("Configuration", StatusOK, "provider %s, model %s", cfg.Provider, cfg.Model)
+	}
+
+	checkAPIKeys(report, cfg)
+
+	if provider == nil {
+		var err error
+		if provider, err = llm.NewProvider(cfg); err != nil {
+			report.add("Provider", StatusFail, "failed to initialize: %v", err)
+		}
+	}
+	if provider != nil {
+		providers := []llm.Provider{provider}
+		if failover, ok := llm.As[*llm.FailoverProvider](provider); ok {
+			providers = failover.Providers()
+		}
+		for _, p := range providers {
+			checkProvider(ctx, report, p, cfg.ConnectTimeout)
+		}
+	}
+
+	checkSandbox(report)
+	checkHistory(report)
+	return report
+}
+
+// usesProvider reports whether name is the configured or a fallback provider
+func usesProvider(cfg *config.Config, name string) bool {
+	if cfg.Provider == name {
+		return true
+	}
+	for _, fallback := range cfg.FallbackProviders {
+		if provider, _, _ := strings.Cut(fallback, "/"); provider == name {
+			return true
+		}
+	}
+	return false
+}
+
+func checkAPIKeys(report *Report, cfg *config.Config) {
+	key := cfg.AnthropicAPIKey
+	switch {
+	case key == "" && usesProvider(cfg, "anthropic"):
+		report.add("ANTHROPIC_API_KEY", StatusFail, "not set; get a key at https://console.anthropic.com and store it with `rigel auth login anthropic`")
+	case key == "":
+		// Not needed
+	case !strings.HasPrefix(key, "sk-ant-"):
+		report.add("ANTHROPIC_API_KEY", StatusWarn, "set, but doesn't look like an Anthropic key (expected sk-ant-...)")
+	case !usesProvider(cfg, "anthropic"):
+		report.add("ANTHROPIC_API_KEY", StatusOK, "set (%s), not used by the current provider", credentials.Mask(key))
+	default:
+		report.add("ANTHROPIC_API_KEY", StatusOK, "set (%s)", credentials.Mask(key))
+	}
+}
+
+// checkProvider checks that provider can be reached within timeout
+func checkProvider(ctx context.Context, report *Report, provider llm.Provider, timeout time.Duration) {
+	name := fmt.Sprintf("Provider %s/%s", provider.GetName(), provider.GetCurrentModel().Name)
+
+	pinger, ok := llm.As[llm.Pinger](provider)
+	if !ok {
+		report.add(name, StatusWarn, "connectivity can't be checked for this provider")
+		return
+	}
+
+	ctx, cancel := llm.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	if err := pinger.Ping(ctx); err != nil {
+		detail := fmt.Sprintf("unreachable: %v", err)
+		if provider.GetName() == "ollama" {
+			detail += "; is `ollama serve` running and OLLAMA_BASE_URL correct?"
+		} else if provider.GetName() == "openai-compatible" {
+			detail += "; is the server running and OPENAI_COMPATIBLE_BASE_URL correct?"
+		}
+		report.add(name, StatusFail, "%s", detail)
+		return
+	}
+	report.add(name, StatusOK, "reachable (%v)", time.Since(start).Round(time.Millisecond))
+
+	if ollama, ok := llm.As[*llm.OllamaProvider](provider); ok {
+		checkOllamaModel(ctx, report, ollama)
+	}
+}
+
+func checkOllamaModel(ctx context.Context, report *Report, ollama *llm.OllamaProvider) {
+	model := ollama.GetCurrentModel().Name
+	models, err := ollama.ListModels(ctx)
+	if err != nil {
+		report.add("Ollama model", StatusFail, "failed to list models: %v", err)
+		return
+	}
+	for _, m := range models {
+		if m.Name == model || m.Name == model+":latest" {
+			report.add("Ollama model", StatusOK, "%s is installed", model)
+			return
+		}
+	}
+	report.add("Ollama model", StatusFail, "%s is not installed; download it with /pull %s", model, model)
+}
+
+func checkSandbox(report *Report) {
+	switch {
+	case sandbox.IsSandboxed():
+		report.add("Sandbox", StatusOK, "enabled, file writes are restricted to the workspace")
+	case runtime.GOOS == "darwin":
+		report.add("Sandbox", StatusWarn, "disabled; file operations are unrestricted (run without --no-sandbox to enable)")
+	default:
+		report.add("Sandbox", StatusWarn, "not supported on %s; file operations are unrestricted", runtime.GOOS)
+	}
+}
+
+func checkHistory(report *Report) {
+	path, err := history.FilePath()
+	if err != nil {
+		report.add("History", StatusFail, "%v", err)
+		return
+	}
+
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		report.add("History", StatusOK, "%s will be created on first use", path)
+		return
+	}
+	if err != nil {
+		report.add("History", StatusFail, "%v", err)
+		return
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND, 0)
+	if err != nil {
+		report.add("History", StatusFail, "%s is not writable: %v", path, err)
+		return
+	}
+	file.Close()
+
+	if perm := info.Mode().Perm(); perm&0o077 != 0 {
+		report.add("History", StatusWarn, "%s is readable by other users (%v); run chmod 600 %s", path, perm, path)
+		return
+	}
+	report.add("History", StatusOK, "%s is writable", path)
+}
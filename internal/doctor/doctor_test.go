@@ -0,0 +1,111 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mizzy/rigel/internal/config"
+)
+
+func findCheck(t *testing.T, report *Report, name string) Check {
+	t.Helper()
+	for _, check := range report.Checks {
+		if check.Name == name {
+			return check
+		}
+	}
+	t.Fatalf("no %q check in report:\n%s", name, report)
+	return Check{}
+}
+
+func TestRunWithOllama(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"models":[{"name":"llama3.2:latest","model":"llama3.2:latest"}]}`)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{Provider: "ollama", Model: "llama3.2", OllamaBaseURL: server.URL}
+	report := Run(context.Background(), cfg, nil)
+
+	assert.Equal(t, StatusOK, findCheck(t, report, "Configuration").Status)
+	assert.Equal(t, StatusOK, findCheck(t, report, "Provider ollama/llama3.2").Status)
+	assert.Equal(t, StatusOK, findCheck(t, report, "Ollama model").Status)
+	assert.Equal(t, StatusOK, findCheck(t, report, "History").Status)
+	assert.False(t, report.Failed())
+
+	cfg.Model = "qwen2.5-coder"
+	report = Run(context.Background(), cfg, nil)
+	check := findCheck(t, report, "Ollama model")
+	assert.Equal(t, StatusFail, check.Status)
+	assert.Contains(t, check.Detail, "/pull qwen2.5-coder")
+	assert.True(t, report.Failed())
+	assert.Contains(t, report.String(), "✗ Ollama model")
+}
+
+func TestRunWithUnreachableOllama(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	report := Run(context.Background(), &config.Config{Provider: "ollama", Model: "llama3.2", OllamaBaseURL: server.URL}, nil)
+
+	check := findCheck(t, report, "Provider ollama/llama3.2")
+	assert.Equal(t, StatusFail, check.Status)
+	assert.Contains(t, check.Detail, "ollama serve")
+}
+
+func TestCheckAPIKeys(t *testing.T) {
+	report := &Report{}
+	checkAPIKeys(report, &config.Config{Provider: "anthropic"})
+	assert.Equal(t, StatusFail, findCheck(t, report, "ANTHROPIC_API_KEY").Status)
+
+	report = &Report{}
+	checkAPIKeys(report, &config.Config{Provider: "ollama", FallbackProviders: []string{"anthropic/claude-sonnet-4-20250514"}, AnthropicAPIKey: "not-a-key"})
+	assert.Equal(t, StatusWarn, findCheck(t, report, "ANTHROPIC_API_KEY").Status)
+
+	report = &Report{}
+	checkAPIKeys(report, &config.Config{Provider: "anthropic", AnthropicAPIKey: "sk-ant-REDACTED"})
+	check := findCheck(t, report, "ANTHROPIC_API_KEY")
+	assert.Equal(t, StatusOK, check.Status)
+	assert.Equal(t, "set (sk-ant-...mnop)", check.Detail)
+
+	report = &Report{}
+	checkAPIKeys(report, &config.Config{Provider: "ollama"})
+	assert.Empty(t, report.Checks)
+}
+
+func TestCheckHistoryPermissions(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	path := filepath.Join(home, ".rigel", "history")
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, nil, 0644))
+
+	report := &Report{}
+	checkHistory(report)
+	assert.Equal(t, StatusWarn, findCheck(t, report, "History").Status)
+
+	require.NoError(t, os.Chmod(path, 0600))
+	report = &Report{}
+	checkHistory(report)
+	assert.Equal(t, StatusOK, findCheck(t, report, "History").Status)
+}
+
+func TestReportString(t *testing.T) {
+	report := &Report{}
+	report.add("Sandbox", StatusWarn, "disabled")
+	assert.Equal(t, "! Sandbox: disabled\n\nNo problems found, 1 warning(s).", report.String())
+
+	report = &Report{}
+	report.add("Configuration", StatusOK, "provider ollama")
+	assert.Equal(t, "✓ Configuration: provider ollama\n\nAll checks passed.", report.String())
+}
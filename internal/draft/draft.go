@@ -0,0 +1,130 @@
+// Package draft keeps the input being composed in .rigel/draft, saved every
+// few seconds while it changes, so that a long prompt survives rigel exiting
+// before it is sent. The next session offers to restore it with /draft.
+package draft
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mizzy/rigel/internal/privacy"
+)
+
+// Path is where the draft is kept, relative to the project root
+var Path = filepath.Join(".rigel", "draft")
+
+// Interval is how often the input is saved while it changes
+const Interval = 3 * time.Second
+
+// Load returns the draft, or an empty string if there is none
+func Load() (string, error) {
+	data, err := os.ReadFile(Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", Path, err)
+	}
+	if data, err = privacy.Open(data); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", Path, err)
+	}
+	return string(data), nil
+}
+
+// Save writes the draft, or removes it if text is blank. Only the user can
+// read it, and it is redacted and encrypted as configured, since a prompt
+// may quote secrets.
+func Save(text string) error {
+	if strings.TrimSpace(text) == "" {
+		if err := os.Remove(Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove %s: %w", Path, err)
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(Path), 0755); err != nil {
+		return fmt.Errorf("failed to create .rigel directory: %w", err)
+	}
+	data, err := privacy.Seal([]byte(privacy.Redact(text)))
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", Path, err)
+	}
+	if err := os.WriteFile(Path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", Path, err)
+	}
+	return nil
+}
+
+// Autosaver saves the input given to Update when it has changed, every
+// interval and once more when closed. Until the input changes, the draft
+// left by an earlier session stays.
+type Autosaver struct {
+	mu    sync.Mutex
+	text  string // The latest input
+	saved string // The input last saved
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// StartAutosave starts saving the input every interval
+func StartAutosave(interval time.Duration) *Autosaver {
+	saved, err := Load()
+	if err != nil {
+		slog.Warn("failed to read the draft", "error", err)
+	}
+	a := &Autosaver{text: saved, saved: saved, stop: make(chan struct{}), done: make(chan struct{})}
+	go a.run(interval)
+	return a
+}
+
+func (a *Autosaver) run(interval time.Duration) {
+	defer close(a.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			a.flush()
+		case <-a.stop:
+			return
+		}
+	}
+}
+
+// Update notes the input as it is now; an empty input removes the draft
+func (a *Autosaver) Update(text string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.text = text
+}
+
+// Close stops saving the input after saving it a last time. Closing it
+// again does nothing.
+func (a *Autosaver) Close() {
+	a.closeOnce.Do(func() {
+		close(a.stop)
+		<-a.done
+		a.flush()
+	})
+}
+
+// flush saves the input if it changed since it was last saved. A failure is
+// logged rather than retried every interval.
+func (a *Autosaver) flush() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.text == a.saved {
+		return
+	}
+	if err := Save(a.text); err != nil {
+		slog.Warn("failed to save the draft", "error", err)
+	}
+	a.saved = a.text
+}
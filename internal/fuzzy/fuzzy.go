@@ -0,0 +1,128 @@
+// Package fuzzy scores how well a typed pattern matches candidate strings,
+// in the manner of fzf: the pattern's characters must appear in order, and
+// matches at word starts and in unbroken runs score higher.
+package fuzzy
+
+import (
+	"sort"
+	"unicode"
+)
+
+// Scoring of a match; the pattern's characters can be spread out, but each
+// skipped character costs a little
+const (
+	scoreMatch       = 16
+	bonusBoundary    = 10 // Character starts a word, e.g. after '-' or at a digit
+	bonusConsecutive = 8  // Character directly follows the previous match
+	penaltyGap       = 1  // Per candidate character skipped between matches
+)
+
+// Match is a candidate that matches a pattern
+type Match struct {
+	Index     int   // Index of the candidate in the list filtered
+	Score     int   // Higher is better
+	Positions []int // Indexes of the matched runes in the candidate
+}
+
+// Score matches pattern against candidate, ignoring case. ok is false if
+// the candidate doesn't contain the pattern's characters in order. An empty
+// pattern matches everything with a score of 0.
+func Score(pattern, candidate string) (score int, positions []int, ok bool) {
+	p := []rune(pattern)
+	if len(p) == 0 {
+		return 0, nil, true
+	}
+	t := []rune(candidate)
+	if len(p) > len(t) {
+		return 0, nil, false
+	}
+	for i := range p {
+		p[i] = unicode.ToLower(p[i])
+	}
+
+	// best[i][j] is the best score with p[i] matched at t[j], and from[i][j]
+	// where p[i-1] was matched for it; -1 marks impossible matches
+	best := make([][]int, len(p))
+	from := make([][]int, len(p))
+	for i := range p {
+		best[i] = make([]int, len(t))
+		from[i] = make([]int, len(t))
+		for j := range t {
+			best[i][j], from[i][j] = -1, -1
+			if unicode.ToLower(t[j]) != p[i] {
+				continue
+			}
+			bonus := scoreMatch + boundaryBonus(t, j)
+			if i == 0 {
+				best[i][j] = bonus
+				continue
+			}
+			for k := i - 1; k < j; k++ {
+				if best[i-1][k] < 0 {
+					continue
+				}
+				s := best[i-1][k] + bonus
+				if k == j-1 {
+					s += bonusConsecutive
+				} else {
+					s -= penaltyGap * (j - k - 1)
+				}
+				if s > best[i][j] {
+					best[i][j], from[i][j] = s, k
+				}
+			}
+		}
+	}
+
+	last := len(p) - 1
+	end := -1
+	for j := range t {
+		if best[last][j] >= 0 && (end < 0 || best[last][j] > best[last][end]) {
+			end = j
+		}
+	}
+	if end < 0 {
+		return 0, nil, false
+	}
+
+	positions = make([]int, len(p))
+	for i, j := last, end; i >= 0; i-- {
+		positions[i] = j
+		j = from[i][j]
+	}
+	return best[last][end], positions, true
+}
+
+// boundaryBonus rewards matching the first character of a word: the start
+// of the candidate, after a separator, at a transition to a digit or to an
+// upper case letter
+func boundaryBonus(t []rune, j int) int {
+	if j == 0 {
+		return bonusBoundary
+	}
+	prev, cur := t[j-1], t[j]
+	switch {
+	case !unicode.IsLetter(prev) && !unicode.IsDigit(prev):
+		return bonusBoundary
+	case unicode.IsDigit(cur) && !unicode.IsDigit(prev):
+		return bonusBoundary
+	case unicode.IsUpper(cur) && unicode.IsLower(prev):
+		return bonusBoundary
+	}
+	return 0
+}
+
+// Filter returns the candidates that match pattern, best first; candidates
+// with equal scores keep their order
+func Filter(pattern string, candidates []string) []Match {
+	var matches []Match
+	for i, candidate := range candidates {
+		if score, positions, ok := Score(pattern, candidate); ok {
+			matches = append(matches, Match{Index: i, Score: score, Positions: positions})
+		}
+	}
+	sort.SliceStable(matches, func(a, b int) bool {
+		return matches[a].Score > matches[b].Score
+	})
+	return matches
+}
@@ -0,0 +1,61 @@
+package fuzzy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		name      string
+		pattern   string
+		candidate string
+		ok        bool
+		positions []int
+	}{
+		{name: "empty pattern", pattern: "", candidate: "llama3.2", ok: true},
+		{name: "word starts", pattern: "35h", candidate: "claude-3-5-haiku-20241022", ok: true, positions: []int{7, 9, 11}},
+		{name: "ignores case", pattern: "QWEN", candidate: "qwen2.5-coder", ok: true, positions: []int{0, 1, 2, 3}},
+		{name: "prefers runs", pattern: "coder", candidate: "codegemma-coder", ok: true, positions: []int{10, 11, 12, 13, 14}},
+		{name: "out of order", pattern: "h53", candidate: "claude-3-5-haiku", ok: false},
+		{name: "longer than candidate", pattern: "llama3.2:latest", candidate: "llama3", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, positions, ok := Score(tt.pattern, tt.candidate)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.positions, positions)
+		})
+	}
+}
+
+func TestFilter(t *testing.T) {
+	candidates := []string{
+		"claude-3-haiku-20240307",
+		"claude-3-5-sonnet-20241022",
+		"claude-3-5-haiku-20241022",
+		"codellama:7b",
+		"llama3.2",
+	}
+
+	matches := Filter("35h", candidates)
+	if assert.Len(t, matches, 1) {
+		assert.Equal(t, 2, matches[0].Index)
+	}
+
+	matches = Filter("llama", candidates)
+	if assert.Len(t, matches, 2) {
+		assert.Equal(t, 4, matches[0].Index, "word starts beat matches inside words")
+		assert.Equal(t, 3, matches[1].Index)
+	}
+
+	matches = Filter("", candidates)
+	assert.Len(t, matches, 5)
+	for i, m := range matches {
+		assert.Equal(t, i, m.Index, "an empty pattern keeps the order")
+	}
+
+	assert.Empty(t, Filter("xyz", candidates))
+}
@@ -1,6 +1,9 @@
 package git
 
 import (
+	"bytes"
+	"context"
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -99,8 +102,79 @@ func getCurrentBranch() string {
 	return branch
 }
 
+// TopLevel returns the root directory of the current git repository, or an
+// empty string outside one
+func TopLevel() string {
+	output, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
 // IsGitRepo checks if the current directory is a git repository
 func IsGitRepo() bool {
 	cmd := exec.Command("git", "rev-parse", "--git-dir")
 	return cmd.Run() == nil
 }
+
+// CurrentBranch returns the branch checked out, or an empty string on a
+// detached HEAD or outside a repository
+func CurrentBranch() string {
+	return getCurrentBranch()
+}
+
+// RemoteURL returns the URL of a remote, such as origin
+func RemoteURL(remote string) (string, error) {
+	output, err := run(context.Background(), "remote", "get-url", remote)
+	return strings.TrimSpace(output), err
+}
+
+// RefExists reports whether a branch, tag or commit exists
+func RefExists(ref string) bool {
+	return exec.Command("git", "rev-parse", "--verify", "--quiet", ref).Run() == nil
+}
+
+// Push pushes a branch to a remote and sets it as the branch's upstream
+func Push(ctx context.Context, remote, branch string) error {
+	_, err := run(ctx, "push", "--set-upstream", remote, branch)
+	return err
+}
+
+// Log returns the messages of the commits on HEAD that aren't on base,
+// oldest first
+func Log(base string) (string, error) {
+	return run(context.Background(), "log", "--reverse", "--format=%s%n%n%b", base+"..HEAD")
+}
+
+// Diff returns the changes on HEAD since it diverged from base
+func Diff(base string) (string, error) {
+	return run(context.Background(), "diff", base+"...HEAD")
+}
+
+// StagedDiff returns the changes staged for the next commit
+func StagedDiff() (string, error) {
+	return run(context.Background(), "diff", "--cached")
+}
+
+// WorkingDiff returns the changes in the working tree and the index since
+// the last commit
+func WorkingDiff() (string, error) {
+	return run(context.Background(), "diff", "HEAD")
+}
+
+// run runs a git command, returning its output, or its error output in the
+// error if it fails
+func run(ctx context.Context, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return stdout.String(), nil
+}
@@ -1,6 +1,8 @@
 package git
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -34,6 +36,19 @@ func TestIsGitRepo(t *testing.T) {
 	}
 }
 
+func TestTopLevel(t *testing.T) {
+	if !IsGitRepo() {
+		t.Skip("Not in a git repository")
+	}
+	root := TopLevel()
+	if root == "" {
+		t.Fatal("Expected the repository root inside a git repository")
+	}
+	if _, err := os.Stat(filepath.Join(root, ".git")); err != nil {
+		t.Errorf("Expected %s to contain .git: %v", root, err)
+	}
+}
+
 func TestGetCurrentBranch(t *testing.T) {
 	branch := getCurrentBranch()
 	// Branch can be empty if in detached HEAD state
@@ -0,0 +1,231 @@
+// Package github is a small client for the GitHub REST API: enough to open
+// pull requests and read issues for the repository rigel runs in.
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultAPIURL is the API of github.com
+const DefaultAPIURL = "https://api.github.com"
+
+// requestTimeout bounds how long an API request may take
+const requestTimeout = 30 * time.Second
+
+// Client calls the GitHub API with a personal access token
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// NewClient creates a client for the API at baseURL, DefaultAPIURL for
+// github.com or https://HOST/api/v3 for GitHub Enterprise Server
+func NewClient(baseURL, token string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultAPIURL
+	}
+	return &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		http:    &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// Repository is a GitHub repository
+type Repository struct {
+	FullName      string `json:"full_name"`
+	DefaultBranch string `json:"default_branch"`
+}
+
+// NewPullRequest describes a pull request to open
+type NewPullRequest struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	Head  string `json:"head"` // Branch with the changes
+	Base  string `json:"base"` // Branch to merge them into
+	Draft bool   `json:"draft,omitempty"`
+}
+
+// PullRequest is an opened pull request
+type PullRequest struct {
+	Number  int    `json:"number"`
+	Title   string `json:"title"`
+	HTMLURL string `json:"html_url"`
+}
+
+// Issue is a GitHub issue
+type Issue struct {
+	Number  int    `json:"number"`
+	Title   string `json:"title"`
+	Body    string `json:"body"`
+	State   string `json:"state"`
+	HTMLURL string `json:"html_url"`
+	User    struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	Labels []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+	PullRequest *struct{} `json:"pull_request"` // Set when the issue is a pull request
+}
+
+// Comment is a comment on an issue
+type Comment struct {
+	Body string `json:"body"`
+	User struct {
+		Login string `json:"login"`
+	} `json:"user"`
+}
+
+// Release is a published release of a repository
+type Release struct {
+	TagName string  `json:"tag_name"`
+	Name    string  `json:"name"`
+	HTMLURL string  `json:"html_url"`
+	Assets  []Asset `json:"assets"`
+}
+
+// Asset is a file attached to a release
+type Asset struct {
+	Name               string `json:"name"`
+	Size               int64  `json:"size"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+}
+
+// Error is an error response of the API
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("GitHub API error (%d): %s", e.StatusCode, e.Message)
+}
+
+// Repository returns the repository owner/repo
+func (c *Client) Repository(ctx context.Context, owner, repo string) (*Repository, error) {
+	var r Repository
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/%s", owner, repo), nil, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// LatestRelease returns the latest release of owner/repo, leaving out
+// drafts and prereleases
+func (c *Client) LatestRelease(ctx context.Context, owner, repo string) (*Release, error) {
+	var release Release
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/%s/releases/latest", owner, repo), nil, &release); err != nil {
+		return nil, err
+	}
+	return &release, nil
+}
+
+// CreatePullRequest opens a pull request in owner/repo
+func (c *Client) CreatePullRequest(ctx context.Context, owner, repo string, pr NewPullRequest) (*PullRequest, error) {
+	var created PullRequest
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/%s/pulls", owner, repo), pr, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// Issue returns issue number n of owner/repo
+func (c *Client) Issue(ctx context.Context, owner, repo string, n int) (*Issue, error) {
+	var issue Issue
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/%s/issues/%d", owner, repo, n), nil, &issue); err != nil {
+		return nil, err
+	}
+	return &issue, nil
+}
+
+// IssueComments returns the comments on issue number n of owner/repo, oldest
+// first, up to the first page of 100
+func (c *Client) IssueComments(ctx context.Context, owner, repo string, n int) ([]Comment, error) {
+	var comments []Comment
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/%s/issues/%d/comments?per_page=100", owner, repo, n), nil, &comments); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+// do sends a request with an optional JSON body and decodes the JSON
+// response into out
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("GitHub API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Message string `json:"message"`
+			Errors  []struct {
+				Message string `json:"message"`
+			} `json:"errors"`
+		}
+		message := resp.Status
+		if json.NewDecoder(resp.Body).Decode(&apiErr) == nil && apiErr.Message != "" {
+			message = apiErr.Message
+			for _, e := range apiErr.Errors {
+				if e.Message != "" {
+					message += ": " + e.Message
+				}
+			}
+		}
+		return &Error{StatusCode: resp.StatusCode, Message: message}
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// ParseRemote returns the owner and name of a GitHub repository from the URL
+// of a git remote, as in git@github.com:owner/repo.git,
+// ssh://git@github.com/owner/repo or https://github.com/owner/repo.git
+func ParseRemote(remote string) (owner, repo string, err error) {
+	remote = strings.TrimSpace(remote)
+	path := ""
+	if u, parseErr := url.Parse(remote); parseErr == nil && u.Scheme != "" && u.Host != "" {
+		path = u.Path
+	} else if _, rest, found := strings.Cut(remote, ":"); found && strings.Contains(remote, "@") {
+		// scp-like syntax: user@host:owner/repo
+		path = rest
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimSuffix(path, ".git"), "/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("%q is not the URL of a GitHub repository", remote)
+	}
+	return parts[0], parts[1], nil
+}
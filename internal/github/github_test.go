@@ -0,0 +1,117 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRemote(t *testing.T) {
+	tests := []struct {
+		remote string
+		owner  string
+		repo   string
+	}{
+		{"git@github.com:mizzy/rigel.git", "mizzy", "rigel"},
+		{"git@github.com:mizzy/rigel", "mizzy", "rigel"},
+		{"https://github.com/mizzy/rigel.git", "mizzy", "rigel"},
+		{"https://github.com/mizzy/rigel/", "mizzy", "rigel"},
+		{"ssh://git@github.com/mizzy/rigel.git", "mizzy", "rigel"},
+		{"https://token@github.example.com/team/tool.git\n", "team", "tool"},
+	}
+	for _, tt := range tests {
+		owner, repo, err := ParseRemote(tt.remote)
+		require.NoError(t, err, tt.remote)
+		assert.Equal(t, tt.owner, owner, tt.remote)
+		assert.Equal(t, tt.repo, repo, tt.remote)
+	}
+
+	for _, remote := range []string{"", "/srv/git/rigel.git", "https://github.com/mizzy", "https://github.com/a/b/c"} {
+		_, _, err := ParseRemote(remote)
+		assert.Error(t, err, remote)
+	}
+}
+
+func TestClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"message":"Bad credentials"}`))
+			return
+		}
+		switch r.Method + " " + r.URL.Path {
+		case "GET /repos/mizzy/rigel":
+			w.Write([]byte(`{"full_name":"mizzy/rigel","default_branch":"main"}`))
+		case "POST /repos/mizzy/rigel/pulls":
+			var pr NewPullRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&pr))
+			if pr.Head == "main" {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				w.Write([]byte(`{"message":"Validation Failed","errors":[{"message":"No commits between main and main"}]}`))
+				return
+			}
+			assert.Equal(t, NewPullRequest{Title: "Add /pr", Body: "Opens PRs.", Head: "pr", Base: "main", Draft: true}, pr)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"number":7,"title":"Add /pr","html_url":"https://github.com/mizzy/rigel/pull/7"}`))
+		case "GET /repos/mizzy/rigel/releases/latest":
+			w.Write([]byte(`{"tag_name":"v0.2.0","html_url":"https://github.com/mizzy/rigel/releases/v0.2.0","assets":[{"name":"checksums.txt","size":120,"browser_download_url":"https://github.com/mizzy/rigel/releases/download/v0.2.0/checksums.txt"}]}`))
+		case "GET /repos/mizzy/rigel/issues/3":
+			w.Write([]byte(`{"number":3,"title":"Crash","body":"It crashes.","state":"open","user":{"login":"alice"},"labels":[{"name":"bug"}]}`))
+		case "GET /repos/mizzy/rigel/issues/3/comments":
+			assert.Equal(t, "100", r.URL.Query().Get("per_page"))
+			w.Write([]byte(`[{"body":"Me too.","user":{"login":"bob"}}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"Not Found"}`))
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	client := NewClient(server.URL+"/", "secret")
+
+	repo, err := client.Repository(ctx, "mizzy", "rigel")
+	require.NoError(t, err)
+	assert.Equal(t, "main", repo.DefaultBranch)
+
+	release, err := client.LatestRelease(ctx, "mizzy", "rigel")
+	require.NoError(t, err)
+	assert.Equal(t, "v0.2.0", release.TagName)
+	require.Len(t, release.Assets, 1)
+	assert.Equal(t, "checksums.txt", release.Assets[0].Name)
+	assert.Equal(t, int64(120), release.Assets[0].Size)
+
+	pr, err := client.CreatePullRequest(ctx, "mizzy", "rigel", NewPullRequest{Title: "Add /pr", Body: "Opens PRs.", Head: "pr", Base: "main", Draft: true})
+	require.NoError(t, err)
+	assert.Equal(t, 7, pr.Number)
+	assert.Equal(t, "https://github.com/mizzy/rigel/pull/7", pr.HTMLURL)
+
+	_, err = client.CreatePullRequest(ctx, "mizzy", "rigel", NewPullRequest{Head: "main", Base: "main"})
+	assert.EqualError(t, err, "GitHub API error (422): Validation Failed: No commits between main and main")
+
+	issue, err := client.Issue(ctx, "mizzy", "rigel", 3)
+	require.NoError(t, err)
+	assert.Equal(t, "Crash", issue.Title)
+	assert.Equal(t, "alice", issue.User.Login)
+	require.Len(t, issue.Labels, 1)
+	assert.Equal(t, "bug", issue.Labels[0].Name)
+	assert.Nil(t, issue.PullRequest)
+
+	comments, err := client.IssueComments(ctx, "mizzy", "rigel", 3)
+	require.NoError(t, err)
+	require.Len(t, comments, 1)
+	assert.Equal(t, "bob", comments[0].User.Login)
+
+	_, err = client.Issue(ctx, "mizzy", "rigel", 4)
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+
+	_, err = NewClient(server.URL, "wrong").Repository(ctx, "mizzy", "rigel")
+	assert.EqualError(t, err, "GitHub API error (401): Bad credentials")
+}
@@ -2,23 +2,30 @@ package history
 
 import (
 	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"time"
+
+	"github.com/mizzy/rigel/internal/privacy"
 )
 
 const (
-	maxHistorySize = 10000 // Maximum number of entries to keep
-	rigelDir       = ".rigel"
-	historyFile    = "history"
+	maxHistorySize    = 10000 // Maximum number of entries to keep
+	rigelDir          = ".rigel"
+	historyFile       = "history"
+	projectHistoryDir = "history.d" // Per-project history files, by hash of the project root
 )
 
 // Entry represents a single history entry
 type Entry struct {
 	Command   string    `json:"command"`
 	Timestamp time.Time `json:"timestamp"`
+	Project   string    `json:"project,omitempty"` // Root of the project it was entered in
 }
 
 // Manager handles command history persistence
@@ -26,6 +33,11 @@ type Manager struct {
 	filePath string
 	entries  []Entry
 	maxSize  int
+
+	// For a project's history: the project root, and the global history
+	// every entry is also appended to
+	project    string
+	globalPath string
 }
 
 // GetRigelDir returns the path to the Rigel configuration directory
@@ -37,6 +49,44 @@ func GetRigelDir() (string, error) {
 	return filepath.Join(homeDir, rigelDir), nil
 }
 
+// FilePath returns the path to the history file
+func FilePath() (string, error) {
+	rigelPath, err := GetRigelDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(rigelPath, historyFile), nil
+}
+
+// ProjectFilePath returns the path to the history file of the project rooted
+// at root
+func ProjectFilePath(root string) (string, error) {
+	rigelPath, err := GetRigelDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(root))
+	return filepath.Join(rigelPath, projectHistoryDir, hex.EncodeToString(sum[:8])), nil
+}
+
+// NewProjectManager creates a history manager for the project rooted at
+// root, such as a git repository's top level, so that prompts from other
+// projects don't show up. Entries are also added to the global history.
+func NewProjectManager(root string) (*Manager, error) {
+	manager, err := NewManager()
+	if err != nil {
+		return nil, err
+	}
+	path, err := ProjectFilePath(root)
+	if err != nil {
+		return nil, err
+	}
+	manager.globalPath = manager.filePath
+	manager.filePath = path
+	manager.project = root
+	return manager, nil
+}
+
 // NewManager creates a new history manager
 func NewManager() (*Manager, error) {
 	rigelPath, err := GetRigelDir()
@@ -58,7 +108,7 @@ func NewManager() (*Manager, error) {
 	// Migrate old history file if it exists
 	if err := manager.migrateOldHistory(); err != nil {
 		// Log the error but don't fail
-		fmt.Fprintf(os.Stderr, "Warning: Failed to migrate old history: %v\n", err)
+		slog.Warn("failed to migrate old history", "error", err)
 	}
 
 	return manager, nil
@@ -132,11 +182,20 @@ func (m *Manager) Load() error {
 	m.entries = []Entry{}
 
 	for scanner.Scan() {
+		line, err := privacy.Open(scanner.Bytes())
+		if err != nil {
+			// Skip entries encrypted with a key that isn't available
+			continue
+		}
 		var entry Entry
-		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+		if err := json.Unmarshal(line, &entry); err != nil {
 			// Skip invalid entries
 			continue
 		}
+		// Skip consecutive duplicates, e.g. from sessions run side by side
+		if n := len(m.entries); n > 0 && m.entries[n-1].Command == entry.Command {
+			continue
+		}
 		m.entries = append(m.entries, entry)
 	}
 
@@ -170,7 +229,7 @@ func (m *Manager) Save() error {
 	defer writer.Flush()
 
 	for _, entry := range m.entries {
-		data, err := json.Marshal(entry)
+		data, err := marshalEntry(entry)
 		if err != nil {
 			continue // Skip entries that can't be marshaled
 		}
@@ -191,6 +250,7 @@ func (m *Manager) Add(command string) error {
 	if command == "" {
 		return nil
 	}
+	command = privacy.Redact(command)
 
 	// Don't add duplicate consecutive commands
 	if len(m.entries) > 0 && m.entries[len(m.entries)-1].Command == command {
@@ -200,6 +260,7 @@ func (m *Manager) Add(command string) error {
 	entry := Entry{
 		Command:   command,
 		Timestamp: time.Now(),
+		Project:   m.project,
 	}
 
 	m.entries = append(m.entries, entry)
@@ -210,7 +271,40 @@ func (m *Manager) Add(command string) error {
 	}
 
 	// Save immediately for persistence
-	return m.Save()
+	if err := m.Save(); err != nil {
+		return err
+	}
+	if m.globalPath != "" {
+		return appendEntry(m.globalPath, entry)
+	}
+	return nil
+}
+
+// appendEntry adds an entry to the end of a history file
+func appendEntry(path string, entry Entry) error {
+	data, err := marshalEntry(entry)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write entry: %w", err)
+	}
+	return nil
+}
+
+// marshalEntry encodes an entry as a line of a history file, encrypted if
+// encryption is configured
+func marshalEntry(entry Entry) ([]byte, error) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal entry: %w", err)
+	}
+	return privacy.Seal(data)
 }
 
 // GetCommands returns all commands as a string slice
@@ -236,6 +330,23 @@ func (m *Manager) GetRecentCommands(n int) []string {
 	return commands
 }
 
+// Entries returns a copy of the history, oldest first
+func (m *Manager) Entries() []Entry {
+	return append([]Entry(nil), m.entries...)
+}
+
+// Delete removes an entry, matched by its command and timestamp, and saves
+// the history. Deleting an entry that isn't there does nothing.
+func (m *Manager) Delete(entry Entry) error {
+	for i, e := range m.entries {
+		if e.Command == entry.Command && e.Timestamp.Equal(entry.Timestamp) {
+			m.entries = append(m.entries[:i], m.entries[i+1:]...)
+			return m.Save()
+		}
+	}
+	return nil
+}
+
 // Clear removes all history
 func (m *Manager) Clear() error {
 	m.entries = []Entry{}
@@ -5,6 +5,9 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/mizzy/rigel/internal/privacy"
+	"github.com/zalando/go-keyring"
 )
 
 func TestHistoryManager(t *testing.T) {
@@ -112,6 +115,36 @@ func TestHistoryManager(t *testing.T) {
 		}
 	})
 
+	t.Run("Delete Entry", func(t *testing.T) {
+		entries := manager.Entries()
+		if err := manager.Delete(entries[1]); err != nil {
+			t.Errorf("Failed to delete entry: %v", err)
+		}
+
+		commands := manager.GetCommands()
+		if strings.Join(commands, ",") != "echo hello,cd /tmp" {
+			t.Errorf("Expected 'ls -la' to be deleted, got %v", commands)
+		}
+
+		// The deletion is saved
+		manager2 := &Manager{
+			filePath: filepath.Join(rigelDir, "history"),
+			entries:  []Entry{},
+			maxSize:  100,
+		}
+		if err := manager2.Load(); err != nil {
+			t.Errorf("Failed to load history: %v", err)
+		}
+		if manager2.Size() != 2 {
+			t.Errorf("Expected 2 commands after load, got %d", manager2.Size())
+		}
+
+		// Deleting it again does nothing
+		if err := manager.Delete(entries[1]); err != nil || manager.Size() != 2 {
+			t.Errorf("Expected deleting a missing entry to do nothing, got %v with %d entries", err, manager.Size())
+		}
+	})
+
 	t.Run("Clear History", func(t *testing.T) {
 		err := manager.Clear()
 		if err != nil {
@@ -202,3 +235,96 @@ func TestGetRigelDir(t *testing.T) {
 		t.Errorf("Expected dir to start with home directory %s, got %s", homeDir, dir)
 	}
 }
+
+func TestProjectManager(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	alpha, err := NewProjectManager("/src/alpha")
+	if err != nil {
+		t.Fatalf("Failed to create project manager: %v", err)
+	}
+	beta, err := NewProjectManager("/src/beta")
+	if err != nil {
+		t.Fatalf("Failed to create project manager: %v", err)
+	}
+	for _, add := range []struct {
+		manager *Manager
+		command string
+	}{{alpha, "fix alpha"}, {beta, "fix beta"}, {alpha, "test alpha"}} {
+		if err := add.manager.Add(add.command); err != nil {
+			t.Fatalf("Failed to add command: %v", err)
+		}
+	}
+
+	// Each project only sees its own prompts
+	reloaded, _ := NewProjectManager("/src/alpha")
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Failed to load history: %v", err)
+	}
+	if got := strings.Join(reloaded.GetCommands(), ","); got != "fix alpha,test alpha" {
+		t.Errorf("Expected alpha's prompts, got %s", got)
+	}
+	if project := reloaded.Entries()[0].Project; project != "/src/alpha" {
+		t.Errorf("Expected entries to record their project, got %q", project)
+	}
+
+	// The global history has them all
+	global, _ := NewManager()
+	if err := global.Load(); err != nil {
+		t.Fatalf("Failed to load history: %v", err)
+	}
+	if got := strings.Join(global.GetCommands(), ","); got != "fix alpha,fix beta,test alpha" {
+		t.Errorf("Expected every project's prompts, got %s", got)
+	}
+}
+
+func TestLoadSkipsConsecutiveDuplicates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	lines := `{"command":"ls"}
+{"command":"ls"}
+{"command":"pwd"}
+{"command":"ls"}
+`
+	if err := os.WriteFile(path, []byte(lines), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	manager := &Manager{filePath: path, maxSize: 100}
+	if err := manager.Load(); err != nil {
+		t.Fatalf("Failed to load history: %v", err)
+	}
+	if got := strings.Join(manager.GetCommands(), ","); got != "ls,pwd,ls" {
+		t.Errorf("Expected consecutive duplicates to be skipped, got %s", got)
+	}
+}
+
+func TestEncryptedRedactedHistory(t *testing.T) {
+	keyring.MockInit()
+	t.Setenv("RIGEL_KEYCHAIN", "")
+	if err := privacy.Configure(true, true); err != nil {
+		t.Fatalf("Failed to configure encryption: %v", err)
+	}
+	t.Cleanup(func() { _ = privacy.Configure(false, false) })
+
+	path := filepath.Join(t.TempDir(), "history")
+	manager := &Manager{filePath: path, maxSize: 100}
+	if err := manager.Add("call the API with sk-ant-api03-abcdefghijkl"); err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "call the API") {
+		t.Errorf("Expected the history file to be encrypted, got %s", data)
+	}
+
+	reloaded := &Manager{filePath: path, maxSize: 100}
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Failed to load history: %v", err)
+	}
+	if got := strings.Join(reloaded.GetCommands(), ","); got != "call the API with [REDACTED]" {
+		t.Errorf("Expected the redacted command, got %s", got)
+	}
+}
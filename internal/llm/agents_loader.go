@@ -1,11 +1,49 @@
 package llm
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/mizzy/rigel/internal/notes"
+)
+
+// AgentsScope is where the guidance of an AGENTS.md file applies
+type AgentsScope string
+
+const (
+	AgentsScopeGlobal     AgentsScope = "global"     // ~/.rigel/AGENTS.md, in every project
+	AgentsScopeRepository AgentsScope = "repository" // AGENTS.md in the working directory
+	AgentsScopeDirectory  AgentsScope = "directory"  // AGENTS.md in a subdirectory, for the files below it
 )
 
+// AgentsFile is an AGENTS.md file included in the system prompt
+type AgentsFile struct {
+	Path    string // ~/.rigel/AGENTS.md, or relative to the working directory
+	Scope   AgentsScope
+	Content string
+}
+
+// Dir returns the directory whose files a subdirectory's AGENTS.md applies
+// to, with a trailing slash
+func (f AgentsFile) Dir() string {
+	return filepath.ToSlash(filepath.Dir(f.Path)) + "/"
+}
+
+// GlobalAgentsPath returns the path of the AGENTS.md included in every
+// project, ~/.rigel/AGENTS.md
+func GlobalAgentsPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".rigel", "AGENTS.md"), nil
+}
+
 // LoadAgentsMD loads the AGENTS.md file content from the current working directory
 func LoadAgentsMD() (string, error) {
 	// Get current working directory
@@ -32,30 +70,161 @@ func LoadAgentsMD() (string, error) {
 	return string(content), nil
 }
 
-// PrependAgentsContext prepends AGENTS.md content to the system prompt if available
-func PrependAgentsContext(systemPrompt string) string {
-	agentsContent, err := LoadAgentsMD()
+// LoadAgentsFiles returns the AGENTS.md files that apply to a conversation
+// made of texts, from the broadest scope to the closest: the global one,
+// the repository's, then those of the subdirectories holding the files the
+// texts mention, parents before children. Missing and empty files are left
+// out; files that can't be read are left out and reported in the error.
+func LoadAgentsFiles(texts ...string) ([]AgentsFile, error) {
+	var files []AgentsFile
+	var errs []error
+	load := func(path, shown string, scope AgentsScope) {
+		content, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to read %s: %w", shown, err))
+			return
+		}
+		if strings.TrimSpace(string(content)) != "" {
+			files = append(files, AgentsFile{Path: shown, Scope: scope, Content: string(content)})
+		}
+	}
+
+	if global, err := GlobalAgentsPath(); err == nil {
+		load(global, "~/.rigel/AGENTS.md", AgentsScopeGlobal)
+	}
+	cwd, err := os.Getwd()
 	if err != nil {
-		// Log error but continue without AGENTS.md content
-		// We don't want to fail the entire request
-		return systemPrompt
+		return files, fmt.Errorf("failed to get current directory: %w", err)
+	}
+	load(filepath.Join(cwd, "AGENTS.md"), "AGENTS.md", AgentsScopeRepository)
+	for _, dir := range mentionedDirs(cwd, texts) {
+		load(filepath.Join(cwd, dir, "AGENTS.md"), filepath.Join(dir, "AGENTS.md"), AgentsScopeDirectory)
 	}
+	return files, errors.Join(errs...)
+}
 
-	if agentsContent == "" {
-		// No AGENTS.md file found
-		return systemPrompt
+// mentionedDirs returns the subdirectories of cwd, relative to it, that hold
+// the files and directories the texts mention, along with their parents,
+// sorted so that parents come first
+func mentionedDirs(cwd string, texts []string) []string {
+	seen := map[string]bool{}
+	dirs := map[string]bool{}
+	for _, text := range texts {
+		for _, word := range strings.FieldsFunc(text, isPathDelimiter) {
+			// Only words that look like paths are looked up, e.g.
+			// internal/llm or main.go, not every word of the conversation
+			word = strings.TrimRight(word, ".")
+			if !strings.ContainsAny(word, "/.") || seen[word] {
+				continue
+			}
+			seen[word] = true
+
+			path := word
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(cwd, path)
+			}
+			rel, err := filepath.Rel(cwd, path)
+			if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				continue
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			if !info.IsDir() {
+				rel = filepath.Dir(rel)
+			}
+			for ; rel != "."; rel = filepath.Dir(rel) {
+				dirs[rel] = true
+			}
+		}
 	}
 
-	// Prepend AGENTS.md content with a separator
-	contextPrompt := fmt.Sprintf(`# Repository Context from AGENTS.md
+	sorted := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		sorted = append(sorted, dir)
+	}
+	slices.Sort(sorted)
+	return sorted
+}
+
+// isPathDelimiter reports whether r ends a path mentioned in text, such as
+// the quotes and backticks around it or the colon before a line number
+func isPathDelimiter(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune("\"'`()[]{}<>,:;=|*", r)
+}
 
-%s
+// agentsContext returns the AGENTS.md files that apply to a conversation
+// made of texts and the project's memory notes, as sections of the system
+// prompt
+func agentsContext(texts ...string) string {
+	// Unreadable AGENTS.md files are skipped: we don't want to fail the
+	// entire request
+	files, _ := LoadAgentsFiles(texts...)
+
+	sections := agentsSections(files)
+	if memory := notes.Section(); memory != "" {
+		sections = append(sections, memory)
+	}
+	return strings.Join(sections, "\n\n")
+}
+
+// globalAgentsContext returns ~/.rigel/AGENTS.md alone as a section of the
+// system prompt
+func globalAgentsContext() string {
+	files, _ := LoadAgentsFiles()
+	files = slices.DeleteFunc(files, func(file AgentsFile) bool { return file.Scope != AgentsScopeGlobal })
+	return strings.Join(agentsSections(files), "\n\n")
+}
+
+// agentsSections returns AGENTS.md files as sections of the system prompt
+func agentsSections(files []AgentsFile) []string {
+	var sections []string
+	for _, file := range files {
+		switch file.Scope {
+		case AgentsScopeGlobal:
+			sections = append(sections, "# Global Context from "+file.Path+"\n\n"+file.Content)
+		case AgentsScopeRepository:
+			sections = append(sections, "# Repository Context from AGENTS.md\n\n"+file.Content)
+		case AgentsScopeDirectory:
+			sections = append(sections, fmt.Sprintf("# Context for %s from %s\n\nApplies to the files under %[1]s and takes precedence over the context above.\n\n%[3]s",
+				file.Dir(), filepath.ToSlash(file.Path), file.Content))
+		}
+	}
+	return sections
+}
+
+// PrependAgentsContext prepends the AGENTS.md files that apply to the
+// conversation in messages and the project's memory notes to the system
+// prompt if available
+func PrependAgentsContext(systemPrompt string, messages ...Message) string {
+	texts := make([]string, len(messages))
+	for i, message := range messages {
+		texts[i] = message.Content
+	}
+	return prependContext(agentsContext(texts...), systemPrompt)
+}
+
+// PrependGlobalAgentsContext prepends ~/.rigel/AGENTS.md alone to the
+// system prompt if available, leaving out the files and notes of a
+// directory that isn't trusted
+func PrependGlobalAgentsContext(systemPrompt string) string {
+	return prependContext(globalAgentsContext(), systemPrompt)
+}
+
+// prependContext puts context before the system prompt, with a separator
+func prependContext(context, systemPrompt string) string {
+	if context == "" {
+		return systemPrompt
+	}
+	return fmt.Sprintf(`%s
 
 ---
 
 # System Instructions
 
-%s`, agentsContent, systemPrompt)
-
-	return contextPrompt
+%s`, context, systemPrompt)
 }
@@ -2,9 +2,11 @@ package llm
 
 import (
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/mizzy/rigel/internal/notes"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -55,6 +57,8 @@ func TestLoadAgentsMD(t *testing.T) {
 }
 
 func TestPrependAgentsContext(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
 	t.Run("prepends AGENTS.md content when available", func(t *testing.T) {
 		// Create a temporary directory
 		tmpDir, err := os.MkdirTemp("", "test-prepend")
@@ -130,4 +134,77 @@ func TestPrependAgentsContext(t *testing.T) {
 		// The system instructions section should be empty
 		assert.True(t, strings.HasSuffix(strings.TrimSpace(result), "# System Instructions"))
 	})
+
+	t.Run("includes the project's memory notes", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+		require.NoError(t, notes.Add("we use uber-fx for DI"))
+
+		result := PrependAgentsContext("You are a helpful assistant.")
+
+		assert.NotContains(t, result, "AGENTS.md")
+		assert.Contains(t, result, "# Project Memory")
+		assert.Contains(t, result, "- we use uber-fx for DI")
+		assert.True(t, strings.HasSuffix(result, "# System Instructions\n\nYou are a helpful assistant."))
+	})
+}
+
+func TestLoadAgentsFiles(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Chdir(t.TempDir())
+
+	files, err := LoadAgentsFiles("Fix internal/llm/cache.go")
+	require.NoError(t, err)
+	assert.Empty(t, files)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(home, ".rigel"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(home, ".rigel", "AGENTS.md"), []byte("Answer tersely."), 0644))
+	require.NoError(t, os.WriteFile("AGENTS.md", []byte("A Go CLI."), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join("internal", "llm"), 0755))
+	require.NoError(t, os.MkdirAll("docs", 0755))
+	require.NoError(t, os.WriteFile(filepath.Join("internal", "AGENTS.md"), []byte("Packages are internal."), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join("internal", "llm", "AGENTS.md"), []byte("Wrap providers."), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join("internal", "llm", "cache.go"), []byte("package llm"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join("docs", "AGENTS.md"), []byte("Write plainly."), 0644))
+
+	t.Run("global and repository files apply everywhere", func(t *testing.T) {
+		files, err := LoadAgentsFiles("What does this project do?")
+		require.NoError(t, err)
+		assert.Equal(t, []AgentsFile{
+			{Path: "~/.rigel/AGENTS.md", Scope: AgentsScopeGlobal, Content: "Answer tersely."},
+			{Path: "AGENTS.md", Scope: AgentsScopeRepository, Content: "A Go CLI."},
+		}, files)
+	})
+
+	t.Run("subdirectory files apply to the files mentioned below them", func(t *testing.T) {
+		files, err := LoadAgentsFiles("Why does `internal/llm/cache.go:42` hash the key?", "See ./missing/file.go.")
+		require.NoError(t, err)
+		require.Len(t, files, 4)
+		assert.Equal(t, AgentsFile{Path: filepath.Join("internal", "AGENTS.md"), Scope: AgentsScopeDirectory, Content: "Packages are internal."}, files[2])
+		assert.Equal(t, AgentsFile{Path: filepath.Join("internal", "llm", "AGENTS.md"), Scope: AgentsScopeDirectory, Content: "Wrap providers."}, files[3])
+		assert.Equal(t, "internal/llm/", files[3].Dir())
+	})
+
+	t.Run("mentioned directories and absolute paths count", func(t *testing.T) {
+		cwd, err := os.Getwd()
+		require.NoError(t, err)
+		files, err := LoadAgentsFiles("Update docs/ and " + filepath.Join(cwd, "internal") + ", not " + filepath.Dir(cwd))
+		require.NoError(t, err)
+		var paths []string
+		for _, file := range files {
+			paths = append(paths, file.Path)
+		}
+		assert.Equal(t, []string{"~/.rigel/AGENTS.md", "AGENTS.md", filepath.Join("docs", "AGENTS.md"), filepath.Join("internal", "AGENTS.md")}, paths)
+	})
+
+	t.Run("closer files come last in the system prompt", func(t *testing.T) {
+		result := PrependAgentsContext("You are a helpful assistant.", Message{Role: "user", Content: "Look at internal/llm/cache.go"})
+
+		global := strings.Index(result, "# Global Context from ~/.rigel/AGENTS.md")
+		repository := strings.Index(result, "# Repository Context from AGENTS.md")
+		directory := strings.Index(result, "# Context for internal/llm/ from internal/llm/AGENTS.md")
+		assert.True(t, global >= 0 && global < repository && repository < directory, result)
+		assert.Contains(t, result, "Applies to the files under internal/llm/ and takes precedence over the context above.\n\nWrap providers.")
+		assert.NotContains(t, result, "Write plainly.")
+	})
 }
@@ -10,12 +10,15 @@ import (
 
 	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/mizzy/rigel/internal/config"
 )
 
 type AnthropicProvider struct {
-	client *anthropic.Client
-	model  Model
-	apiKey string
+	client     *anthropic.Client
+	httpClient *http.Client
+	model      Model
+	apiKey     string
+	cacheStats promptCacheStats
 }
 
 func NewAnthropicProvider(apiKey string, model string) (*AnthropicProvider, error) {
@@ -23,23 +26,33 @@ func NewAnthropicProvider(apiKey string, model string) (*AnthropicProvider, erro
 		return nil, fmt.Errorf("anthropic API key is required")
 	}
 
+	httpClient := newHTTPClient(config.DefaultConnectTimeout)
 	client := anthropic.NewClient(
 		option.WithAPIKey(apiKey),
+		option.WithHTTPClient(httpClient),
 	)
 
 	if model == "" {
 		model = "claude-sonnet-4-20250514"
 	}
 
-	modelStruct := Model{Name: model}
+	modelStruct := WithCapabilities("anthropic", Model{Name: model})
 
 	return &AnthropicProvider{
-		client: client,
-		model:  modelStruct,
-		apiKey: apiKey,
+		client:     client,
+		httpClient: httpClient,
+		model:      modelStruct,
+		apiKey:     apiKey,
 	}, nil
 }
 
+// SetConnectTimeout sets how long connecting to the API may take; 0
+// removes the limit
+func (p *AnthropicProvider) SetConnectTimeout(timeout time.Duration) {
+	p.httpClient = newHTTPClient(timeout)
+	p.client = anthropic.NewClient(option.WithAPIKey(p.apiKey), option.WithHTTPClient(p.httpClient))
+}
+
 func (p *AnthropicProvider) Generate(ctx context.Context, prompt string) (string, error) {
 	return p.GenerateWithOptions(ctx, prompt, GenerateOptions{})
 }
@@ -53,6 +66,36 @@ func (p *AnthropicProvider) GenerateWithOptions(ctx context.Context, prompt stri
 }
 
 func (p *AnthropicProvider) GenerateWithHistory(ctx context.Context, messages []Message, opts GenerateOptions) (string, error) {
+	params := p.messageParams(messages, opts)
+	message, err := p.client.Messages.New(ctx, params)
+	if err != nil {
+		return "", classifyError("anthropic", fmt.Errorf("failed to generate response: %w", err))
+	}
+	p.cacheStats.record(params.Model.Value, message.Usage)
+
+	if len(message.Content) == 0 {
+		return "", fmt.Errorf("no content in response")
+	}
+
+	// A structured response is the input of the tool the model was made
+	// to call
+	err = nil
+	if message.StopReason == anthropic.MessageStopReasonMaxTokens {
+		err = ErrMaxTokens
+	}
+	for _, block := range message.Content {
+		if block.Type == anthropic.ContentBlockTypeToolUse {
+			return string(block.Input), err
+		}
+	}
+	return message.Content[0].Text, err
+}
+
+// messageParams builds a Messages API request with the system prompt and
+// the conversation. The system prompt and the conversation up to the latest
+// message are marked for prompt caching, so the next request only pays full
+// price for what was added since.
+func (p *AnthropicProvider) messageParams(messages []Message, opts GenerateOptions) anthropic.MessageNewParams {
 	model := p.model.Name
 	if opts.Model != "" {
 		model = opts.Model
@@ -65,11 +108,20 @@ func (p *AnthropicProvider) GenerateWithHistory(ctx context.Context, messages []
 
 	// Convert our Message format to Anthropic's MessageParam format
 	anthropicMessages := make([]anthropic.MessageParam, 0, len(messages))
-	for _, msg := range messages {
+	for i, msg := range messages {
+		block := anthropic.NewTextBlock(msg.Content)
+		if i == len(messages)-1 {
+			block.CacheControl = ephemeralCache
+		}
 		if msg.Role == "user" {
-			anthropicMessages = append(anthropicMessages, anthropic.NewUserMessage(anthropic.NewTextBlock(msg.Content)))
+			// Images go before the text asking about them
+			blocks := make([]anthropic.ContentBlockParamUnion, 0, len(msg.Images)+1)
+			for _, image := range msg.Images {
+				blocks = append(blocks, anthropic.NewImageBlockBase64(image.MediaType, image.Base64()))
+			}
+			anthropicMessages = append(anthropicMessages, anthropic.NewUserMessage(append(blocks, block)...))
 		} else if msg.Role == "assistant" {
-			anthropicMessages = append(anthropicMessages, anthropic.NewAssistantMessage(anthropic.NewTextBlock(msg.Content)))
+			anthropicMessages = append(anthropicMessages, anthropic.NewAssistantMessage(block))
 		}
 	}
 
@@ -79,64 +131,77 @@ func (p *AnthropicProvider) GenerateWithHistory(ctx context.Context, messages []
 		MaxTokens: anthropic.F(int64(maxTokens)),
 	}
 
-	// Include AGENTS.md content in the system prompt
-	systemPrompt := opts.SystemPrompt
-	if systemPrompt == "" {
-		// Load AGENTS.md even if no system prompt is provided
-		systemPrompt = PrependAgentsContext("")
-	} else {
-		// Prepend AGENTS.md to existing system prompt
-		systemPrompt = PrependAgentsContext(systemPrompt)
-	}
-
-	if systemPrompt != "" {
-		params.System = anthropic.F([]anthropic.TextBlockParam{
-			anthropic.NewTextBlock(systemPrompt),
-		})
+	if opts.SystemPrompt != "" {
+		system := anthropic.NewTextBlock(opts.SystemPrompt)
+		system.CacheControl = ephemeralCache
+		params.System = anthropic.F([]anthropic.TextBlockParam{system})
 	}
 
-	if opts.Temperature > 0 {
+	if opts.Deterministic {
+		params.Temperature = anthropic.F(0.0)
+	} else if opts.Temperature > 0 {
 		params.Temperature = anthropic.F(float64(opts.Temperature))
 	}
 
-	message, err := p.client.Messages.New(ctx, params)
-	if err != nil {
-		return "", fmt.Errorf("failed to generate response: %w", err)
-	}
-
-	if len(message.Content) == 0 {
-		return "", fmt.Errorf("no content in response")
+	// The API has no JSON mode, but makes a model that must call a tool
+	// give input following the tool's schema
+	if format := opts.ResponseFormat; format != nil {
+		params.Tools = anthropic.F([]anthropic.ToolParam{{
+			Name:        anthropic.F(format.name()),
+			Description: anthropic.F("Give the response as this tool's input."),
+			InputSchema: anthropic.F[interface{}](format.schema()),
+		}})
+		params.ToolChoice = anthropic.F[anthropic.ToolChoiceUnionParam](anthropic.ToolChoiceToolParam{
+			Type: anthropic.F(anthropic.ToolChoiceToolTypeTool),
+			Name: anthropic.F(format.name()),
+		})
 	}
 
-	return message.Content[0].Text, nil
+	return params
 }
 
 func (p *AnthropicProvider) Stream(ctx context.Context, prompt string) (<-chan StreamResponse, error) {
+	return p.StreamWithHistory(ctx, []Message{{Role: "user", Content: prompt}}, GenerateOptions{})
+}
+
+func (p *AnthropicProvider) StreamWithHistory(ctx context.Context, messages []Message, opts GenerateOptions) (<-chan StreamResponse, error) {
+	params := p.messageParams(messages, opts)
 	ch := make(chan StreamResponse)
 
 	go func() {
 		defer close(ch)
 
-		stream := p.client.Messages.NewStreaming(ctx, anthropic.MessageNewParams{
-			Model: anthropic.F(anthropic.Model(p.model.Name)),
-			Messages: anthropic.F([]anthropic.MessageParam{
-				anthropic.NewUserMessage(anthropic.NewTextBlock(prompt)),
-			}),
-			MaxTokens: anthropic.F(int64(4096)),
-		})
+		stream := p.client.Messages.NewStreaming(ctx, params)
 
+		// Input token counts come with the start of the message, the output
+		// token count with its end
+		var usage anthropic.Usage
+		var truncated bool
 		for stream.Next() {
 			event := stream.Current()
 
 			switch event.Type {
+			case anthropic.MessageStreamEventTypeMessageStart:
+				usage = event.Message.Usage
+			case anthropic.MessageStreamEventTypeMessageDelta:
+				usage.OutputTokens = event.Usage.OutputTokens
+				if delta, ok := event.Delta.(anthropic.MessageDeltaEventDelta); ok {
+					truncated = delta.StopReason == anthropic.MessageDeltaEventDeltaStopReasonMaxTokens
+				}
 			case anthropic.MessageStreamEventTypeContentBlockDelta:
-				if delta, ok := event.Delta.(anthropic.ContentBlockDeltaEventDelta); ok && delta.Text != "" {
+				// Text, or the input of the tool giving a structured response
+				if delta, ok := event.Delta.(anthropic.ContentBlockDeltaEventDelta); ok && delta.Text+delta.PartialJSON != "" {
 					ch <- StreamResponse{
-						Content: delta.Text,
+						Content: delta.Text + delta.PartialJSON,
 						Done:    false,
 					}
 				}
 			case anthropic.MessageStreamEventTypeMessageStop:
+				p.cacheStats.record(params.Model.Value, usage)
+				if truncated {
+					ch <- StreamResponse{Error: ErrMaxTokens, Done: true}
+					continue
+				}
 				ch <- StreamResponse{
 					Done: true,
 				}
@@ -145,7 +210,7 @@ func (p *AnthropicProvider) Stream(ctx context.Context, prompt string) (<-chan S
 
 		if err := stream.Err(); err != nil {
 			ch <- StreamResponse{
-				Error: err,
+				Error: classifyError("anthropic", err),
 				Done:  true,
 			}
 		}
@@ -159,7 +224,7 @@ func (p *AnthropicProvider) ListModels(ctx context.Context) ([]Model, error) {
 	models, err := p.fetchModelsFromAPI(ctx)
 	if err != nil {
 		// Fall back to hardcoded list if API call fails
-		return []Model{
+		models = []Model{
 			{Name: "claude-sonnet-4-20250514", Details: ModelDetails{Family: "claude-4"}},
 			{Name: "claude-opus-4-20250131", Details: ModelDetails{Family: "claude-4"}},
 			{Name: "claude-3-5-sonnet-20241022", Details: ModelDetails{Family: "claude-3-5"}},
@@ -167,7 +232,10 @@ func (p *AnthropicProvider) ListModels(ctx context.Context) ([]Model, error) {
 			{Name: "claude-3-opus-20240229", Details: ModelDetails{Family: "claude-3"}},
 			{Name: "claude-3-sonnet-20240229", Details: ModelDetails{Family: "claude-3"}},
 			{Name: "claude-3-haiku-20240307", Details: ModelDetails{Family: "claude-3"}},
-		}, nil
+		}
+	}
+	for i, m := range models {
+		models[i] = WithCapabilities("anthropic", m)
 	}
 	return models, nil
 }
@@ -181,7 +249,18 @@ func (p *AnthropicProvider) GetName() string {
 }
 
 func (p *AnthropicProvider) SetModel(model Model) {
-	p.model = model
+	p.model = WithCapabilities("anthropic", model)
+}
+
+// PromptCacheStats returns how much of the input the prompt cache served
+func (p *AnthropicProvider) PromptCacheStats() PromptCacheStats {
+	return p.cacheStats.get()
+}
+
+// Ping checks that the API is reachable and accepts the API key
+func (p *AnthropicProvider) Ping(ctx context.Context) error {
+	_, err := p.fetchModelsFromAPI(ctx)
+	return err
 }
 
 // anthropicModelResponse represents the API response structure
@@ -207,11 +286,7 @@ func (p *AnthropicProvider) fetchModelsFromAPI(ctx context.Context) ([]Model, er
 	req.Header.Set("anthropic-version", "2023-06-01")
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-
-	resp, err := client.Do(req)
+	resp, err := p.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch models: %w", err)
 	}
@@ -2,8 +2,14 @@ package llm
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -17,17 +23,21 @@ func TestNewAnthropicProvider(t *testing.T) {
 		expectedError bool
 	}{
 		{
-			name:          "valid with custom model",
-			apiKey:        "test-api-key",
-			model:         "claude-3-opus-20240229",
-			expectedModel: Model{Name: "claude-3-opus-20240229"},
+			name:   "valid with custom model",
+			apiKey: "test-api-key",
+			model:  "claude-3-opus-20240229",
+			expectedModel: Model{Name: "claude-3-opus-20240229", Details: ModelDetails{
+				ContextWindow: 200000, SupportsTools: true, SupportsVision: true, InputCost: 15, OutputCost: 75,
+			}},
 			expectedError: false,
 		},
 		{
-			name:          "valid with default model",
-			apiKey:        "test-api-key",
-			model:         "",
-			expectedModel: Model{Name: "claude-sonnet-4-20250514"},
+			name:   "valid with default model",
+			apiKey: "test-api-key",
+			model:  "",
+			expectedModel: Model{Name: "claude-sonnet-4-20250514", Details: ModelDetails{
+				ContextWindow: 200000, SupportsTools: true, SupportsVision: true, InputCost: 3, OutputCost: 15,
+			}},
 			expectedError: false,
 		},
 		{
@@ -122,3 +132,75 @@ func TestAnthropicProvider_GenerateWithOptions(t *testing.T) {
 func TestAnthropicProvider_Stream(t *testing.T) {
 	t.Skip("Skipping integration test that requires real API key")
 }
+
+func TestAnthropicProvider_ResponseFormat(t *testing.T) {
+	var request map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-20250514",`+
+			`"content":[{"type":"tool_use","id":"toolu_1","name":"answer","input":{"answer":42}}],`+
+			`"stop_reason":"tool_use","usage":{"input_tokens":10,"output_tokens":5}}`)
+	}))
+	t.Cleanup(server.Close)
+
+	provider, err := NewAnthropicProvider("test-api-key", "")
+	require.NoError(t, err)
+	provider.client = anthropic.NewClient(option.WithAPIKey("test-api-key"), option.WithBaseURL(server.URL))
+
+	schema := map[string]any{"type": "object", "properties": map[string]any{"answer": map[string]any{"type": "integer"}}}
+	resp, err := provider.GenerateWithOptions(context.Background(), "What is 6 times 7?",
+		GenerateOptions{ResponseFormat: JSONSchema("answer", schema)})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"answer":42}`, resp)
+
+	assert.Equal(t, map[string]any{"type": "tool", "name": "answer"}, request["tool_choice"])
+	tools := request["tools"].([]any)
+	require.Len(t, tools, 1)
+	assert.Equal(t, "answer", tools[0].(map[string]any)["name"])
+	assert.Equal(t, schema["properties"], tools[0].(map[string]any)["input_schema"].(map[string]any)["properties"])
+}
+
+func TestAnthropicProvider_Images(t *testing.T) {
+	var request map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-20250514",`+
+			`"content":[{"type":"text","text":"A cat."}],"stop_reason":"end_turn","usage":{"input_tokens":10,"output_tokens":5}}`)
+	}))
+	t.Cleanup(server.Close)
+
+	provider, err := NewAnthropicProvider("test-api-key", "")
+	require.NoError(t, err)
+	provider.client = anthropic.NewClient(option.WithAPIKey("test-api-key"), option.WithBaseURL(server.URL))
+
+	resp, err := provider.GenerateWithHistory(context.Background(),
+		[]Message{{Role: "user", Content: "What is this?", Images: []Image{{MediaType: "image/png", Data: []byte("png")}}}}, GenerateOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "A cat.", resp)
+
+	messages := request["messages"].([]any)
+	require.Len(t, messages, 1)
+	content := messages[0].(map[string]any)["content"].([]any)
+	require.Len(t, content, 2)
+	assert.Equal(t, map[string]any{"type": "image", "source": map[string]any{"type": "base64", "media_type": "image/png", "data": "cG5n"}}, content[0])
+	assert.Equal(t, "What is this?", content[1].(map[string]any)["text"])
+}
+
+func TestAnthropicProvider_MaxTokens(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-20250514",`+
+			`"content":[{"type":"text","text":"func main() {"}],"stop_reason":"max_tokens","usage":{"input_tokens":10,"output_tokens":4096}}`)
+	}))
+	t.Cleanup(server.Close)
+
+	provider, err := NewAnthropicProvider("test-api-key", "")
+	require.NoError(t, err)
+	provider.client = anthropic.NewClient(option.WithAPIKey("test-api-key"), option.WithBaseURL(server.URL))
+
+	resp, err := provider.Generate(context.Background(), "Write a server")
+	assert.ErrorIs(t, err, ErrMaxTokens)
+	assert.Equal(t, "func main() {", resp)
+}
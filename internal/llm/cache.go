@@ -0,0 +1,297 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mizzy/rigel/internal/history"
+)
+
+// DefaultCacheTTL is how long cached responses stay valid unless configured
+const DefaultCacheTTL = 24 * time.Hour
+
+// ResponseCache stores LLM responses on disk, content-addressed by a hash of
+// the request
+type ResponseCache struct {
+	dir string
+	ttl time.Duration
+
+	mu     sync.Mutex
+	hits   int
+	misses int
+}
+
+// CacheStats describes the cache contents and hit rate for this process
+type CacheStats struct {
+	Dir     string
+	Entries int
+	Bytes   int64
+	Hits    int
+	Misses  int
+}
+
+type cacheEntry struct {
+	CreatedAt time.Time `json:"created_at"`
+	Response  string    `json:"response"`
+}
+
+// DefaultCacheDir returns ~/.rigel/cache/llm
+func DefaultCacheDir() (string, error) {
+	rigelPath, err := history.GetRigelDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(rigelPath, "cache", "llm"), nil
+}
+
+// NewResponseCache creates a cache in dir. A ttl of zero uses DefaultCacheTTL.
+func NewResponseCache(dir string, ttl time.Duration) (*ResponseCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	return &ResponseCache{dir: dir, ttl: ttl}, nil
+}
+
+// CacheKey hashes the request parts into a cache key
+func CacheKey(parts ...interface{}) string {
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+	for _, part := range parts {
+		_ = enc.Encode(part)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *ResponseCache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key+".json")
+}
+
+// Get returns a cached response that hasn't expired
+func (c *ResponseCache) Get(key string) (string, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		c.record(false)
+		return "", false
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || time.Since(entry.CreatedAt) > c.ttl {
+		_ = os.Remove(c.path(key))
+		c.record(false)
+		return "", false
+	}
+
+	c.record(true)
+	return entry.Response, true
+}
+
+// Put stores a response
+func (c *ResponseCache) Put(key, response string) error {
+	data, err := json.Marshal(cacheEntry{CreatedAt: time.Now(), Response: response})
+	if err != nil {
+		return err
+	}
+
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// Clear removes all cached responses and returns how many were removed
+func (c *ResponseCache) Clear() (int, error) {
+	removed := 0
+	err := c.walk(func(path string, info os.FileInfo) error {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	return removed, err
+}
+
+// Stats returns the number and size of cached entries and this process's hit rate
+func (c *ResponseCache) Stats() CacheStats {
+	stats := CacheStats{Dir: c.dir}
+	_ = c.walk(func(path string, info os.FileInfo) error {
+		stats.Entries++
+		stats.Bytes += info.Size()
+		return nil
+	})
+
+	c.mu.Lock()
+	stats.Hits, stats.Misses = c.hits, c.misses
+	c.mu.Unlock()
+	return stats
+}
+
+func (c *ResponseCache) walk(fn func(path string, info os.FileInfo) error) error {
+	return filepath.Walk(c.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, ".json") {
+			return nil
+		}
+		return fn(path, info)
+	})
+}
+
+func (c *ResponseCache) record(hit bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if hit {
+		c.hits++
+	} else {
+		c.misses++
+	}
+}
+
+// CachingProvider answers repeated requests from a ResponseCache
+type CachingProvider struct {
+	Provider
+	cache *ResponseCache
+}
+
+// NewCachingProvider wraps a provider with a response cache
+func NewCachingProvider(p Provider, cache *ResponseCache) *CachingProvider {
+	return &CachingProvider{Provider: p, cache: cache}
+}
+
+// Unwrap returns the underlying provider
+func (c *CachingProvider) Unwrap() Provider {
+	return c.Provider
+}
+
+// Cache returns the response cache
+func (c *CachingProvider) Cache() *ResponseCache {
+	return c.cache
+}
+
+// key builds a cache key from the request. The AGENTS.md files that apply
+// to the request and the memory notes are part of the key because providers
+// prepend them to the system prompt.
+func (c *CachingProvider) key(method string, model string, request ...interface{}) string {
+	if model == "" {
+		model = c.GetCurrentModel().Name
+	}
+	var texts []string
+	for _, part := range request {
+		switch part := part.(type) {
+		case string:
+			texts = append(texts, part)
+		case []Message:
+			for _, message := range part {
+				texts = append(texts, message.Content)
+			}
+		}
+	}
+	parts := append([]interface{}{c.GetName(), model, method, agentsContext(texts...)}, request...)
+	return CacheKey(parts...)
+}
+
+func (c *CachingProvider) cached(key string, generate func() (string, error)) (string, error) {
+	if resp, ok := c.cache.Get(key); ok {
+		return resp, nil
+	}
+
+	resp, err := generate()
+	if err == nil {
+		_ = c.cache.Put(key, resp)
+	}
+	return resp, err
+}
+
+func (c *CachingProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	return c.cached(c.key("generate", "", prompt), func() (string, error) {
+		return c.Provider.Generate(ctx, prompt)
+	})
+}
+
+func (c *CachingProvider) GenerateWithOptions(ctx context.Context, prompt string, opts GenerateOptions) (string, error) {
+	return c.cached(c.key("generate", opts.Model, prompt, opts), func() (string, error) {
+		return c.Provider.GenerateWithOptions(ctx, prompt, opts)
+	})
+}
+
+func (c *CachingProvider) GenerateWithHistory(ctx context.Context, messages []Message, opts GenerateOptions) (string, error) {
+	return c.cached(c.key("history", opts.Model, messages, opts), func() (string, error) {
+		return c.Provider.GenerateWithHistory(ctx, messages, opts)
+	})
+}
+
+func (c *CachingProvider) Stream(ctx context.Context, prompt string) (<-chan StreamResponse, error) {
+	return c.cachedStream(ctx, c.key("generate", "", prompt), func() (<-chan StreamResponse, error) {
+		return c.Provider.Stream(ctx, prompt)
+	})
+}
+
+func (c *CachingProvider) StreamWithHistory(ctx context.Context, messages []Message, opts GenerateOptions) (<-chan StreamResponse, error) {
+	return c.cachedStream(ctx, c.key("history", opts.Model, messages, opts), func() (<-chan StreamResponse, error) {
+		return c.Provider.StreamWithHistory(ctx, messages, opts)
+	})
+}
+
+// cachedStream replays a cached response as a stream, or passes the stream
+// through and stores the full response once it completes
+func (c *CachingProvider) cachedStream(ctx context.Context, key string, stream func() (<-chan StreamResponse, error)) (<-chan StreamResponse, error) {
+	if resp, ok := c.cache.Get(key); ok {
+		ch := make(chan StreamResponse, 2)
+		ch <- StreamResponse{Content: resp}
+		ch <- StreamResponse{Done: true}
+		close(ch)
+		return ch, nil
+	}
+
+	in, err := stream()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan StreamResponse)
+	go func() {
+		defer close(out)
+		var content strings.Builder
+		failed := false
+		for resp := range in {
+			content.WriteString(resp.Content)
+			if resp.Error != nil {
+				failed = true
+			}
+			if resp.Done && !failed {
+				_ = c.cache.Put(key, content.String())
+			}
+			select {
+			case out <- resp:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// FindCache returns the response cache in a provider chain, if any
+func FindCache(p Provider) *ResponseCache {
+	if c, ok := As[*CachingProvider](p); ok {
+		return c.cache
+	}
+	return nil
+}
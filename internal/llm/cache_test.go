@@ -0,0 +1,119 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestCache(t *testing.T, ttl time.Duration) *ResponseCache {
+	t.Helper()
+	cache, err := NewResponseCache(t.TempDir(), ttl)
+	require.NoError(t, err)
+	return cache
+}
+
+func TestResponseCache(t *testing.T) {
+	cache := newTestCache(t, time.Hour)
+	key := CacheKey("model", "prompt")
+
+	_, ok := cache.Get(key)
+	assert.False(t, ok)
+
+	require.NoError(t, cache.Put(key, "answer"))
+	resp, ok := cache.Get(key)
+	assert.True(t, ok)
+	assert.Equal(t, "answer", resp)
+
+	stats := cache.Stats()
+	assert.Equal(t, 1, stats.Entries)
+	assert.Equal(t, 1, stats.Hits)
+	assert.Equal(t, 1, stats.Misses)
+
+	removed, err := cache.Clear()
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	_, ok = cache.Get(key)
+	assert.False(t, ok)
+}
+
+func TestResponseCacheTTL(t *testing.T) {
+	cache := newTestCache(t, time.Nanosecond)
+	key := CacheKey("prompt")
+	require.NoError(t, cache.Put(key, "stale"))
+
+	time.Sleep(time.Millisecond)
+	_, ok := cache.Get(key)
+	assert.False(t, ok)
+}
+
+func TestCacheKeyDistinguishesParts(t *testing.T) {
+	assert.Equal(t, CacheKey("a", GenerateOptions{Temperature: 0.5}), CacheKey("a", GenerateOptions{Temperature: 0.5}))
+	assert.NotEqual(t, CacheKey("a", GenerateOptions{Temperature: 0.5}), CacheKey("a", GenerateOptions{Temperature: 0.7}))
+	assert.NotEqual(t, CacheKey("ab", "c"), CacheKey("a", "bc"))
+}
+
+func TestCachingProvider(t *testing.T) {
+	t.Chdir(t.TempDir()) // No AGENTS.md
+
+	mockProvider := new(MockProvider)
+	mockProvider.On("GetName").Return("mock")
+	mockProvider.On("GetCurrentModel").Return(Model{Name: "m1"})
+	mockProvider.On("Generate", mock.Anything, "hello").Return("hi", nil).Once()
+	mockProvider.On("Generate", mock.Anything, "fail").Return("", errors.New("boom")).Twice()
+
+	provider := NewCachingProvider(mockProvider, newTestCache(t, time.Hour))
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		resp, err := provider.Generate(ctx, "hello")
+		require.NoError(t, err)
+		assert.Equal(t, "hi", resp)
+	}
+
+	// Errors are never cached
+	for i := 0; i < 2; i++ {
+		_, err := provider.Generate(ctx, "fail")
+		assert.Error(t, err)
+	}
+
+	mockProvider.AssertExpectations(t)
+	assert.Same(t, provider.Cache(), FindCache(NewTracingProvider(provider)))
+	assert.Nil(t, FindCache(mockProvider))
+}
+
+func TestCachingProviderStream(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	ch := make(chan StreamResponse, 3)
+	ch <- StreamResponse{Content: "Hel"}
+	ch <- StreamResponse{Content: "lo"}
+	ch <- StreamResponse{Done: true}
+	close(ch)
+
+	mockProvider := new(MockProvider)
+	mockProvider.On("GetName").Return("mock")
+	mockProvider.On("GetCurrentModel").Return(Model{Name: "m1"})
+	mockProvider.On("Stream", mock.Anything, "hi").Return((<-chan StreamResponse)(ch), nil).Once()
+
+	provider := NewCachingProvider(mockProvider, newTestCache(t, time.Hour))
+
+	collect := func() string {
+		out, err := provider.Stream(context.Background(), "hi")
+		require.NoError(t, err)
+		var content string
+		for resp := range out {
+			content += resp.Content
+		}
+		return content
+	}
+
+	assert.Equal(t, "Hello", collect())
+	assert.Equal(t, "Hello", collect(), "second stream is served from the cache")
+	mockProvider.AssertExpectations(t)
+}
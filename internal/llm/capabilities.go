@@ -0,0 +1,49 @@
+package llm
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// claudeContextWindow is the context window of every current Claude model
+const claudeContextWindow = 200_000
+
+// WithCapabilities fills in the capabilities and price known for a
+// provider's model without asking the provider, keeping details already set
+func WithCapabilities(provider string, m Model) Model {
+	d := &m.Details
+	if price, ok := ModelPrice(provider, m.Name); ok && d.InputCost == 0 && d.OutputCost == 0 {
+		d.InputCost, d.OutputCost = price.Input, price.Output
+	}
+	if provider == "anthropic" && strings.HasPrefix(m.Name, "claude-") {
+		if d.ContextWindow == 0 {
+			d.ContextWindow = claudeContextWindow
+		}
+		d.SupportsTools = true
+		d.SupportsVision = true
+	}
+	return m
+}
+
+// describeOllamaModels fills in the context window and capabilities Ollama
+// reports for each model, leaving models it can't describe unchanged
+func (p *OllamaProvider) describeOllamaModels(ctx context.Context, models []Model) {
+	var wg sync.WaitGroup
+	for i := range models {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			info, err := p.Show(ctx, models[i].Name)
+			if err != nil {
+				return
+			}
+			d := &models[i].Details
+			d.ContextWindow = info.ContextLength()
+			d.SupportsTools = slices.Contains(info.Capabilities, "tools")
+			d.SupportsVision = slices.Contains(info.Capabilities, "vision")
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,65 @@
+package llm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithCapabilities(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		model    Model
+		want     ModelDetails
+	}{
+		{
+			name:     "claude model",
+			provider: "anthropic",
+			model:    Model{Name: "claude-3-5-haiku-20241022", Details: ModelDetails{Family: "claude-3-5"}},
+			want: ModelDetails{
+				Family: "claude-3-5", ContextWindow: 200000, SupportsTools: true, SupportsVision: true, InputCost: 0.8, OutputCost: 4,
+			},
+		},
+		{
+			name:     "known details are kept",
+			provider: "anthropic",
+			model:    Model{Name: "claude-sonnet-4-20250514", Details: ModelDetails{ContextWindow: 1000000, InputCost: 6, OutputCost: 22.5}},
+			want: ModelDetails{
+				ContextWindow: 1000000, SupportsTools: true, SupportsVision: true, InputCost: 6, OutputCost: 22.5,
+			},
+		},
+		{
+			name:     "ollama model",
+			provider: "ollama",
+			model:    Model{Name: "llama3.2"},
+			want:     ModelDetails{},
+		},
+		{
+			name:     "unknown provider",
+			provider: "openai-compatible",
+			model:    Model{Name: "qwen2.5-coder-7b"},
+			want:     ModelDetails{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, WithCapabilities(tt.provider, tt.model).Details)
+		})
+	}
+}
+
+func TestOllamaListModelsCapabilities(t *testing.T) {
+	provider := newOllamaTestServer(t)
+
+	models, err := provider.ListModels(context.Background())
+	require.NoError(t, err)
+	require.Len(t, models, 1)
+	assert.Equal(t, "llama", models[0].Details.Family)
+	assert.Equal(t, 131072, models[0].Details.ContextWindow)
+	assert.True(t, models[0].Details.SupportsTools)
+	assert.False(t, models[0].Details.SupportsVision)
+}
@@ -0,0 +1,201 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+const (
+	// continueInstruction asks for the rest of a response that was cut off
+	continueInstruction = "Your response was cut off at the maximum output length. Continue it exactly where it stopped, mid-word or mid-line if need be, without repeating anything, reopening a code block or adding remarks."
+
+	// minOverlap and maxOverlap bound how much of the end of a response a
+	// continuation may repeat for the repetition to be dropped: shorter
+	// repetitions may well be meant
+	minOverlap = 16
+	maxOverlap = 256
+)
+
+// ContinuingProvider asks for the rest of responses cut off at the maximum
+// output length, up to a number of times, and stitches the parts together.
+// A response still cut off after that is returned as it is.
+type ContinuingProvider struct {
+	Provider
+	maxRounds int
+}
+
+// NewContinuingProvider wraps a provider so that responses cut off at the
+// maximum output length are continued up to maxRounds times
+func NewContinuingProvider(p Provider, maxRounds int) *ContinuingProvider {
+	return &ContinuingProvider{Provider: p, maxRounds: maxRounds}
+}
+
+// Unwrap returns the underlying provider
+func (c *ContinuingProvider) Unwrap() Provider {
+	return c.Provider
+}
+
+func (c *ContinuingProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	resp, err := c.Provider.Generate(ctx, prompt)
+	return c.continueResponse(ctx, []Message{{Role: "user", Content: prompt}}, GenerateOptions{}, resp, err)
+}
+
+func (c *ContinuingProvider) GenerateWithOptions(ctx context.Context, prompt string, opts GenerateOptions) (string, error) {
+	resp, err := c.Provider.GenerateWithOptions(ctx, prompt, opts)
+	return c.continueResponse(ctx, []Message{{Role: "user", Content: prompt}}, opts, resp, err)
+}
+
+func (c *ContinuingProvider) GenerateWithHistory(ctx context.Context, messages []Message, opts GenerateOptions) (string, error) {
+	resp, err := c.Provider.GenerateWithHistory(ctx, messages, opts)
+	return c.continueResponse(ctx, messages, opts, resp, err)
+}
+
+func (c *ContinuingProvider) Stream(ctx context.Context, prompt string) (<-chan StreamResponse, error) {
+	in, err := c.Provider.Stream(ctx, prompt)
+	return c.continueStream(ctx, []Message{{Role: "user", Content: prompt}}, GenerateOptions{}, in, err)
+}
+
+func (c *ContinuingProvider) StreamWithHistory(ctx context.Context, messages []Message, opts GenerateOptions) (<-chan StreamResponse, error) {
+	in, err := c.Provider.StreamWithHistory(ctx, messages, opts)
+	return c.continueStream(ctx, messages, opts, in, err)
+}
+
+// continueResponse asks for the rest of response while it is cut off. If a
+// continuation fails, the response so far is returned with the error.
+func (c *ContinuingProvider) continueResponse(ctx context.Context, messages []Message, opts GenerateOptions, response string, err error) (string, error) {
+	if !errors.Is(err, ErrMaxTokens) || !continuable(opts) {
+		return response, err
+	}
+	for round := 0; errors.Is(err, ErrMaxTokens) && round < c.maxRounds; round++ {
+		var part string
+		part, err = c.Provider.GenerateWithHistory(ctx, continuation(messages, response), opts)
+		response += trimOverlap(response, part)
+	}
+	if errors.Is(err, ErrMaxTokens) {
+		slog.Warn("response cut off at the maximum output length", "continuations", c.maxRounds)
+		err = nil
+	}
+	return response, err
+}
+
+// continueStream passes a stream through, streaming the rest of the
+// response after it while it is cut off. The start of each continuation is
+// held back until it is clear how much of it repeats the response.
+func (c *ContinuingProvider) continueStream(ctx context.Context, messages []Message, opts GenerateOptions, in <-chan StreamResponse, err error) (<-chan StreamResponse, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan StreamResponse)
+	go func() {
+		defer close(out)
+		send := func(resp StreamResponse) bool {
+			select {
+			case out <- resp:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		var response strings.Builder
+		for round := 0; ; round++ {
+			truncated := false
+			holding := round > 0
+			var held strings.Builder
+			for resp := range in {
+				if errors.Is(resp.Error, ErrMaxTokens) && continuable(opts) {
+					truncated = true
+					continue
+				}
+				if holding {
+					held.WriteString(resp.Content)
+					if held.Len() < maxOverlap && !resp.Done && resp.Error == nil {
+						continue
+					}
+					holding = false
+					resp.Content = trimOverlap(response.String(), held.String())
+				}
+				response.WriteString(resp.Content)
+				if !send(resp) {
+					return
+				}
+			}
+			if holding && held.Len() > 0 {
+				content := trimOverlap(response.String(), held.String())
+				response.WriteString(content)
+				if !send(StreamResponse{Content: content}) {
+					return
+				}
+			}
+
+			if !truncated {
+				return
+			}
+			if round == c.maxRounds {
+				slog.Warn("response cut off at the maximum output length", "continuations", c.maxRounds)
+				send(StreamResponse{Done: true})
+				return
+			}
+			var err error
+			in, err = c.Provider.StreamWithHistory(ctx, continuation(messages, response.String()), opts)
+			if err != nil {
+				send(StreamResponse{Error: err, Done: true})
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// continuable reports whether a response can be continued: the tool input
+// or JSON of a structured response is given anew rather than continued, so
+// a cut off one is an error
+func continuable(opts GenerateOptions) bool {
+	return opts.ResponseFormat == nil
+}
+
+// continuation returns the conversation asking for the rest of response
+func continuation(messages []Message, response string) []Message {
+	return append(slices.Clip(messages),
+		Message{Role: "assistant", Content: response},
+		Message{Role: "user", Content: continueInstruction},
+	)
+}
+
+// trimOverlap returns what a continuation adds to response: without a code
+// fence reopening the block response was cut off in, nor what it repeats
+// of the end of response, such as the line that was cut off
+func trimOverlap(response, continuation string) string {
+	if inCodeBlock(response) {
+		fence, rest, ok := strings.Cut(strings.TrimLeft(continuation, "\n"), "\n")
+		// A bare fence may close the block instead
+		if ok && strings.HasPrefix(fence, "```") && strings.TrimSpace(fence) != "```" {
+			continuation = rest
+		}
+	}
+
+	for n := min(len(response), len(continuation), maxOverlap); n >= minOverlap; n-- {
+		if strings.HasSuffix(response, continuation[:n]) {
+			return continuation[n:]
+		}
+	}
+	if line := response[strings.LastIndex(response, "\n")+1:]; len(strings.TrimSpace(line)) >= 3 && strings.HasPrefix(continuation, line) {
+		return continuation[len(line):]
+	}
+	return continuation
+}
+
+// inCodeBlock reports whether text ends inside a fenced code block
+func inCodeBlock(text string) bool {
+	open := false
+	for line := range strings.Lines(text) {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			open = !open
+		}
+	}
+	return open
+}
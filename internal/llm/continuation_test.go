@@ -0,0 +1,178 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// partsProvider answers with one part after another, every part but the
+// last cut off at the maximum output length
+type partsProvider struct {
+	Provider
+	parts    []string
+	requests [][]Message
+}
+
+func (p *partsProvider) next(messages []Message) (string, error) {
+	p.requests = append(p.requests, messages)
+	part := p.parts[0]
+	if len(p.parts) == 1 {
+		return part, nil
+	}
+	p.parts = p.parts[1:]
+	return part, ErrMaxTokens
+}
+
+func (p *partsProvider) GenerateWithOptions(ctx context.Context, prompt string, opts GenerateOptions) (string, error) {
+	return p.next([]Message{{Role: "user", Content: prompt}})
+}
+
+func (p *partsProvider) GenerateWithHistory(ctx context.Context, messages []Message, opts GenerateOptions) (string, error) {
+	return p.next(messages)
+}
+
+func (p *partsProvider) StreamWithHistory(ctx context.Context, messages []Message, opts GenerateOptions) (<-chan StreamResponse, error) {
+	part, err := p.next(messages)
+	ch := make(chan StreamResponse, len(part)+1)
+	// One chunk a few bytes long after another
+	for chunk := range slicesOf(part, 5) {
+		ch <- StreamResponse{Content: chunk}
+	}
+	if err != nil {
+		ch <- StreamResponse{Error: err, Done: true}
+	} else {
+		ch <- StreamResponse{Done: true}
+	}
+	close(ch)
+	return ch, nil
+}
+
+func slicesOf(s string, n int) func(yield func(string) bool) {
+	return func(yield func(string) bool) {
+		for len(s) > 0 {
+			k := min(n, len(s))
+			if !yield(s[:k]) {
+				return
+			}
+			s = s[k:]
+		}
+	}
+}
+
+const (
+	firstPart  = "Here is the server:\n\n```go\npackage main\n\nfunc main() {\n\thttp.ListenAndServe(\":8080\", ni"
+	secondPart = "```go\nl)\n}\n```\n\nRun it with go run."
+	answer     = "Here is the server:\n\n```go\npackage main\n\nfunc main() {\n\thttp.ListenAndServe(\":8080\", nil)\n}\n```\n\nRun it with go run."
+)
+
+func TestContinuingProviderGenerate(t *testing.T) {
+	base := &partsProvider{parts: []string{firstPart, secondPart}}
+	p := NewContinuingProvider(base, 3)
+
+	resp, err := p.GenerateWithOptions(context.Background(), "write a server", GenerateOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, answer, resp)
+
+	require.Len(t, base.requests, 2)
+	assert.Equal(t, []Message{
+		{Role: "user", Content: "write a server"},
+		{Role: "assistant", Content: firstPart},
+		{Role: "user", Content: continueInstruction},
+	}, base.requests[1])
+}
+
+func TestContinuingProviderStopsAfterMaxRounds(t *testing.T) {
+	base := &partsProvider{parts: []string{"one ", "two ", "three ", "four"}}
+	resp, err := NewContinuingProvider(base, 2).GenerateWithHistory(context.Background(), []Message{{Role: "user", Content: "count"}}, GenerateOptions{})
+	require.NoError(t, err, "a response still cut off is returned as it is")
+	assert.Equal(t, "one two three ", resp)
+	assert.Len(t, base.requests, 3)
+
+	base = &partsProvider{parts: []string{"one ", "two"}}
+	resp, err = NewContinuingProvider(base, 0).GenerateWithHistory(context.Background(), []Message{{Role: "user", Content: "count"}}, GenerateOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "one ", resp)
+}
+
+func TestContinuingProviderLeavesStructuredResponses(t *testing.T) {
+	base := &partsProvider{parts: []string{`{"intent":`, `"read"}`}}
+	resp, err := NewContinuingProvider(base, 3).GenerateWithHistory(context.Background(), []Message{{Role: "user", Content: "plan"}},
+		GenerateOptions{ResponseFormat: &ResponseFormat{Type: FormatJSONObject}})
+	assert.ErrorIs(t, err, ErrMaxTokens)
+	assert.Equal(t, `{"intent":`, resp)
+	assert.Len(t, base.requests, 1)
+}
+
+func TestContinuingProviderStream(t *testing.T) {
+	base := &partsProvider{parts: []string{firstPart, secondPart}}
+	ch, err := NewContinuingProvider(base, 3).StreamWithHistory(context.Background(), []Message{{Role: "user", Content: "write a server"}}, GenerateOptions{})
+	require.NoError(t, err)
+
+	var sb strings.Builder
+	dones := 0
+	for resp := range ch {
+		require.NoError(t, resp.Error)
+		sb.WriteString(resp.Content)
+		if resp.Done {
+			dones++
+		}
+	}
+	assert.Equal(t, answer, sb.String())
+	assert.Equal(t, 1, dones)
+	assert.Len(t, base.requests, 2)
+}
+
+func TestContinuingProviderStreamFailure(t *testing.T) {
+	failure := errors.New("connection reset")
+	base := &failingContinuation{partsProvider: partsProvider{parts: []string{"Part one, ", "never sent"}}, err: failure}
+	ch, err := NewContinuingProvider(base, 3).StreamWithHistory(context.Background(), []Message{{Role: "user", Content: "go"}}, GenerateOptions{})
+	require.NoError(t, err)
+
+	var sb strings.Builder
+	var last StreamResponse
+	for resp := range ch {
+		sb.WriteString(resp.Content)
+		last = resp
+	}
+	assert.Equal(t, "Part one, ", sb.String())
+	assert.ErrorIs(t, last.Error, failure)
+}
+
+// failingContinuation fails the requests continuing a response
+type failingContinuation struct {
+	partsProvider
+	err error
+}
+
+func (f *failingContinuation) StreamWithHistory(ctx context.Context, messages []Message, opts GenerateOptions) (<-chan StreamResponse, error) {
+	if len(f.requests) > 0 {
+		return nil, f.err
+	}
+	return f.partsProvider.StreamWithHistory(ctx, messages, opts)
+}
+
+func TestTrimOverlap(t *testing.T) {
+	tests := []struct {
+		name         string
+		response     string
+		continuation string
+		want         string
+	}{
+		{"plain", "The quick brown", " fox jumps.", " fox jumps."},
+		{"repeated end", "The quick brown fox jumps over", "brown fox jumps over the lazy dog.", " the lazy dog."},
+		{"restarted line", "Intro.\n\tfmt.Printl", "\tfmt.Println(x)\n", "n(x)\n"},
+		{"reopened code block", "```go\nfunc main() {\n", "```go\n\tprintln()\n}\n```", "\tprintln()\n}\n```"},
+		{"closing fence", "```go\nfunc main() {}\n", "```\n\nDone.", "```\n\nDone."},
+		{"short repetition", "\t}\n", "\t}\n}\n", "\t}\n}\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, trimOverlap(tt.response, tt.continuation))
+		})
+	}
+}
@@ -34,6 +34,11 @@ func (m *TestConversationProvider) Stream(ctx context.Context, prompt string) (<
 	return ch, nil
 }
 
+func (m *TestConversationProvider) StreamWithHistory(ctx context.Context, messages []Message, opts GenerateOptions) (<-chan StreamResponse, error) {
+	m.messagesReceived = messages
+	return m.Stream(ctx, "")
+}
+
 func (m *TestConversationProvider) ListModels(ctx context.Context) ([]Model, error) {
 	return []Model{{Name: "test-model"}}, nil
 }
@@ -0,0 +1,151 @@
+package llm
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// DeterministicSeed is the seed requests are sent with in deterministic mode
+// unless they set one
+const DeterministicSeed = 42
+
+// DeterministicProvider makes responses reproducible while deterministic
+// mode is on: requests are sampled at temperature 0 with a fixed seed, for
+// the providers that take one, and answered from the response cache, so a
+// repeated prompt gets the same answer even where the model itself varies.
+// Without a cache in the chain below it, it keeps one of its own.
+type DeterministicProvider struct {
+	Provider
+	cacheTTL time.Duration
+
+	mu      sync.Mutex
+	enabled bool
+	cached  Provider // The chain below answering from a cache; nil until needed
+}
+
+// NewDeterministicProvider wraps a provider so that deterministic mode can
+// be turned on and off, on to begin with if enabled. Its own cache, if it
+// needs one, keeps responses for cacheTTL.
+func NewDeterministicProvider(p Provider, enabled bool, cacheTTL time.Duration) *DeterministicProvider {
+	d := &DeterministicProvider{Provider: p, cacheTTL: cacheTTL, enabled: enabled}
+	if FindCache(p) != nil {
+		d.cached = p
+	}
+	return d
+}
+
+// Unwrap returns the underlying provider
+func (d *DeterministicProvider) Unwrap() Provider {
+	return d.Provider
+}
+
+// SetDeterministic turns deterministic mode on or off
+func (d *DeterministicProvider) SetDeterministic(on bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.enabled = on
+}
+
+// IsDeterministic reports whether deterministic mode is on
+func (d *DeterministicProvider) IsDeterministic() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.enabled
+}
+
+// target returns the provider requests go to and whether they are made
+// deterministic. If the cache can't be created, responses aren't cached.
+func (d *DeterministicProvider) target() (Provider, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.enabled {
+		return d.Provider, false
+	}
+	if d.cached == nil {
+		dir, err := DefaultCacheDir()
+		var cache *ResponseCache
+		if err == nil {
+			cache, err = NewResponseCache(dir, d.cacheTTL)
+		}
+		if err != nil {
+			slog.Warn("deterministic responses won't be cached", "error", err)
+			return d.Provider, true
+		}
+		d.cached = NewCachingProvider(d.Provider, cache)
+	}
+	return d.cached, true
+}
+
+// deterministic returns opts for a deterministic request
+func deterministic(opts GenerateOptions) GenerateOptions {
+	opts.Deterministic = true
+	if opts.Seed == 0 {
+		opts.Seed = DeterministicSeed
+	}
+	return opts
+}
+
+func (d *DeterministicProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	p, on := d.target()
+	if !on {
+		return p.Generate(ctx, prompt)
+	}
+	return p.GenerateWithOptions(ctx, prompt, deterministic(GenerateOptions{}))
+}
+
+func (d *DeterministicProvider) GenerateWithOptions(ctx context.Context, prompt string, opts GenerateOptions) (string, error) {
+	p, on := d.target()
+	if on {
+		opts = deterministic(opts)
+	}
+	return p.GenerateWithOptions(ctx, prompt, opts)
+}
+
+func (d *DeterministicProvider) GenerateWithHistory(ctx context.Context, messages []Message, opts GenerateOptions) (string, error) {
+	p, on := d.target()
+	if on {
+		opts = deterministic(opts)
+	}
+	return p.GenerateWithHistory(ctx, messages, opts)
+}
+
+func (d *DeterministicProvider) Stream(ctx context.Context, prompt string) (<-chan StreamResponse, error) {
+	p, on := d.target()
+	if !on {
+		return p.Stream(ctx, prompt)
+	}
+	return p.StreamWithHistory(ctx, []Message{{Role: "user", Content: prompt}}, deterministic(GenerateOptions{}))
+}
+
+func (d *DeterministicProvider) StreamWithHistory(ctx context.Context, messages []Message, opts GenerateOptions) (<-chan StreamResponse, error) {
+	p, on := d.target()
+	if on {
+		opts = deterministic(opts)
+	}
+	return p.StreamWithHistory(ctx, messages, opts)
+}
+
+// SetDeterministic turns deterministic mode on or off for a provider chain,
+// reporting whether the chain has a DeterministicProvider to do it
+func SetDeterministic(p Provider, on bool) bool {
+	d, ok := As[*DeterministicProvider](p)
+	if ok {
+		d.SetDeterministic(on)
+	}
+	return ok
+}
+
+// temperature returns the temperature to send with a request, or nil for
+// the default: 0 is a temperature only in deterministic mode
+func temperature(opts GenerateOptions) *float32 {
+	if opts.Deterministic {
+		zero := float32(0)
+		return &zero
+	}
+	if opts.Temperature > 0 {
+		return &opts.Temperature
+	}
+	return nil
+}
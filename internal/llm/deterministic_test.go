@@ -0,0 +1,84 @@
+package llm
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// optionsProvider answers every request the same, recording its options
+type optionsProvider struct {
+	Provider
+	opts []GenerateOptions
+}
+
+func (p *optionsProvider) GetName() string        { return "mock" }
+func (p *optionsProvider) GetCurrentModel() Model { return Model{Name: "m1"} }
+
+func (p *optionsProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	return p.GenerateWithOptions(ctx, prompt, GenerateOptions{})
+}
+
+func (p *optionsProvider) GenerateWithOptions(ctx context.Context, prompt string, opts GenerateOptions) (string, error) {
+	p.opts = append(p.opts, opts)
+	return "answer", nil
+}
+
+func (p *optionsProvider) GenerateWithHistory(ctx context.Context, messages []Message, opts GenerateOptions) (string, error) {
+	return p.GenerateWithOptions(ctx, "", opts)
+}
+
+func TestDeterministicProvider(t *testing.T) {
+	t.Chdir(t.TempDir()) // No AGENTS.md
+	base := &optionsProvider{}
+	p := NewDeterministicProvider(NewCachingProvider(base, newTestCache(t, time.Hour)), false, 0)
+	ctx := context.Background()
+
+	_, err := p.GenerateWithOptions(ctx, "hello", GenerateOptions{Temperature: 0.7})
+	require.NoError(t, err)
+	assert.Equal(t, GenerateOptions{Temperature: 0.7}, base.opts[0], "requests are left alone while off")
+
+	assert.True(t, SetDeterministic(NewTracingProvider(p), true))
+	assert.True(t, p.IsDeterministic())
+	for range 2 {
+		resp, err := p.GenerateWithOptions(ctx, "hello", GenerateOptions{Temperature: 0.7})
+		require.NoError(t, err)
+		assert.Equal(t, "answer", resp)
+	}
+	require.Len(t, base.opts, 2, "the repeated request is answered from the cache")
+	assert.Equal(t, GenerateOptions{Temperature: 0.7, Seed: DeterministicSeed, Deterministic: true}, base.opts[1])
+
+	_, err = p.GenerateWithHistory(ctx, []Message{{Role: "user", Content: "hi"}}, GenerateOptions{Seed: 7})
+	require.NoError(t, err)
+	assert.Equal(t, 7, base.opts[2].Seed, "a seed of the request's own is kept")
+
+	assert.False(t, SetDeterministic(base, true))
+}
+
+func TestDeterministicProviderKeepsItsOwnCache(t *testing.T) {
+	t.Chdir(t.TempDir())
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	base := &optionsProvider{}
+	p := NewDeterministicProvider(base, true, 0)
+
+	for range 2 {
+		resp, err := p.Generate(context.Background(), "hello")
+		require.NoError(t, err)
+		assert.Equal(t, "answer", resp)
+	}
+	assert.Len(t, base.opts, 1)
+	assert.True(t, base.opts[0].Deterministic)
+	assert.DirExists(t, filepath.Join(home, ".rigel", "cache", "llm"))
+}
+
+func TestTemperature(t *testing.T) {
+	assert.Nil(t, temperature(GenerateOptions{}), "the provider's default")
+	assert.Equal(t, float32(0.7), *temperature(GenerateOptions{Temperature: 0.7}))
+	assert.Equal(t, float32(0), *temperature(GenerateOptions{Temperature: 0.7, Deterministic: true}))
+}
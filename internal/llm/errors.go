@@ -0,0 +1,147 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// ErrMaxTokens is returned, with the response so far, when a response is
+// cut off at the maximum output length; streams end with it instead of a
+// plain Done. A ContinuingProvider asks for the rest of such responses.
+var ErrMaxTokens = errors.New("the response reached the maximum output length")
+
+// ErrorKind classifies why a provider request failed
+type ErrorKind int
+
+const (
+	ErrorUnknown       ErrorKind = iota
+	ErrorAuth                    // The API key is missing, invalid or lacks permission
+	ErrorRateLimit               // Too many requests or tokens; retry later
+	ErrorContextLength           // The conversation doesn't fit in the model's context window
+	ErrorNetwork                 // The service couldn't be reached
+	ErrorServer                  // The service failed or is overloaded
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrorAuth:
+		return "auth"
+	case ErrorRateLimit:
+		return "rate_limit"
+	case ErrorContextLength:
+		return "context_length"
+	case ErrorNetwork:
+		return "network"
+	case ErrorServer:
+		return "server"
+	default:
+		return "unknown"
+	}
+}
+
+// Error is a classified provider error
+type Error struct {
+	Kind       ErrorKind
+	Provider   string
+	StatusCode int // HTTP status, if the service responded
+	Err        error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// contextLengthMessages are fragments of the errors services return when a
+// request exceeds the context window
+var contextLengthMessages = []string{
+	"prompt is too long",
+	"context length",
+	"context window",
+	"maximum context",
+	"too many tokens",
+}
+
+// KindOf returns the kind of a provider error. Errors that weren't
+// classified by a provider are classified from their type and message.
+func KindOf(err error) ErrorKind {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return ErrorUnknown
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+
+	var apiErr *anthropic.Error
+	if errors.As(err, &apiErr) {
+		return statusKind(apiErr.StatusCode, apiErr.JSON.RawJSON())
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ErrorNetwork
+	}
+
+	if isContextLengthMessage(err.Error()) {
+		return ErrorContextLength
+	}
+	return ErrorUnknown
+}
+
+// statusKind classifies an HTTP error response from its status and body
+func statusKind(status int, body string) ErrorKind {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrorAuth
+	case status == http.StatusTooManyRequests:
+		return ErrorRateLimit
+	case status == http.StatusRequestEntityTooLarge || isContextLengthMessage(body):
+		return ErrorContextLength
+	case status >= 500:
+		return ErrorServer
+	default:
+		return ErrorUnknown
+	}
+}
+
+func isContextLengthMessage(message string) bool {
+	message = strings.ToLower(message)
+	for _, fragment := range contextLengthMessages {
+		if strings.Contains(message, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// classifyError wraps err in an *Error for provider if its kind is known
+func classifyError(provider string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return err
+	}
+	kind := KindOf(err)
+	if kind == ErrorUnknown {
+		return err
+	}
+
+	classified := &Error{Kind: kind, Provider: provider, Err: err}
+	var apiErr *anthropic.Error
+	if errors.As(err, &apiErr) {
+		classified.StatusCode = apiErr.StatusCode
+	}
+	return classified
+}
@@ -0,0 +1,67 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKindOf(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorKind
+	}{
+		{"nil", nil, ErrorUnknown},
+		{"classified", fmt.Errorf("agent: %w", &Error{Kind: ErrorRateLimit, Err: errors.New("slow down")}), ErrorRateLimit},
+		{"anthropic unauthorized", &anthropic.Error{StatusCode: 401}, ErrorAuth},
+		{"anthropic rate limit", &anthropic.Error{StatusCode: 429}, ErrorRateLimit},
+		{"anthropic overloaded", &anthropic.Error{StatusCode: 529}, ErrorServer},
+		{"anthropic bad request", &anthropic.Error{StatusCode: 400}, ErrorUnknown},
+		{"connection refused", errRefused, ErrorNetwork},
+		{"context length message", errors.New("prompt is too long: 210000 tokens > 200000 maximum"), ErrorContextLength},
+		{"cancelled", fmt.Errorf("failed to send request: %w", context.Canceled), ErrorUnknown},
+		{"other", errors.New("no content in response"), ErrorUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, KindOf(tt.err))
+		})
+	}
+}
+
+func TestStatusKind(t *testing.T) {
+	assert.Equal(t, ErrorAuth, statusKind(403, ""))
+	assert.Equal(t, ErrorContextLength, statusKind(413, ""))
+	assert.Equal(t, ErrorContextLength, statusKind(400, `{"error":{"message":"input exceeds the context window"}}`))
+	assert.Equal(t, ErrorServer, statusKind(503, ""))
+	assert.Equal(t, ErrorUnknown, statusKind(404, `{"error":"model not found"}`))
+}
+
+func TestOllamaErrorsAreClassified(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"server busy"}`, http.StatusServiceUnavailable)
+	}))
+
+	provider, err := NewOllamaProvider(server.URL, "llama3.2")
+	require.NoError(t, err)
+
+	_, err = provider.Generate(context.Background(), "hi")
+	var e *Error
+	require.ErrorAs(t, err, &e)
+	assert.Equal(t, ErrorServer, e.Kind)
+	assert.Equal(t, "ollama", e.Provider)
+	assert.Equal(t, http.StatusServiceUnavailable, e.StatusCode)
+	assert.Contains(t, err.Error(), "ollama API error (status 503)")
+
+	server.Close()
+	_, err = provider.Generate(context.Background(), "hi")
+	assert.Equal(t, ErrorNetwork, KindOf(err))
+}
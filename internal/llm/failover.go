@@ -0,0 +1,210 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// FailoverProvider sends requests to the first of an ordered list of
+// providers and retries on the next one when a provider can't be reached,
+// rejects the credentials or is rate limited
+type FailoverProvider struct {
+	providers []Provider
+
+	mu      sync.Mutex
+	notices []string
+}
+
+// NewFailoverProvider creates a provider that falls back from primary to
+// each of fallbacks in order
+func NewFailoverProvider(primary Provider, fallbacks ...Provider) *FailoverProvider {
+	return &FailoverProvider{providers: append([]Provider{primary}, fallbacks...)}
+}
+
+// Unwrap returns the primary provider
+func (f *FailoverProvider) Unwrap() Provider {
+	return f.providers[0]
+}
+
+// Providers returns the providers in the order they are tried
+func (f *FailoverProvider) Providers() []Provider {
+	return f.providers
+}
+
+// TakeNotice returns a description of the failovers since the last call, or
+// an empty string if every request was served by the primary provider
+func (f *FailoverProvider) TakeNotice() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	notice := strings.Join(f.notices, "\n")
+	f.notices = nil
+	return notice
+}
+
+func (f *FailoverProvider) notify(failures []string, served Provider) {
+	notice := fmt.Sprintf("%s; answered by %s/%s", strings.Join(failures, ", "), served.GetName(), served.GetCurrentModel().Name)
+	slog.Warn("llm failover", "failures", failures, "provider", served.GetName())
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.notices = append(f.notices, notice)
+}
+
+// IsFailoverError reports whether err means the provider is unavailable, so
+// the request may succeed on another provider: connection failures,
+// authentication errors, rate limits and server errors. Cancellation and
+// errors caused by the request itself are not.
+func IsFailoverError(err error) bool {
+	switch KindOf(err) {
+	case ErrorAuth, ErrorRateLimit, ErrorNetwork, ErrorServer:
+		return true
+	default:
+		return false
+	}
+}
+
+// failover calls fn with each provider in turn until one succeeds or fails
+// with an error that another provider wouldn't fix. Fallbacks for a
+// different service use their own model rather than opts.Model.
+func (f *FailoverProvider) failover(ctx context.Context, opts GenerateOptions, fn func(Provider, GenerateOptions) error) error {
+	var failures []string
+	var errs []error
+	for i, p := range f.providers {
+		o := opts
+		if i > 0 && p.GetName() != f.providers[0].GetName() {
+			o.Model = ""
+		}
+
+		err := fn(p, o)
+		if err == nil {
+			if len(failures) > 0 {
+				f.notify(failures, p)
+			}
+			return nil
+		}
+		if !IsFailoverError(err) || ctx.Err() != nil {
+			return err
+		}
+		failures = append(failures, fmt.Sprintf("%s failed (%v)", p.GetName(), err))
+		errs = append(errs, fmt.Errorf("%s: %w", p.GetName(), err))
+	}
+	return fmt.Errorf("all providers failed: %w", errors.Join(errs...))
+}
+
+func (f *FailoverProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	var resp string
+	err := f.failover(ctx, GenerateOptions{}, func(p Provider, _ GenerateOptions) (err error) {
+		resp, err = p.Generate(ctx, prompt)
+		return err
+	})
+	return resp, err
+}
+
+func (f *FailoverProvider) GenerateWithOptions(ctx context.Context, prompt string, opts GenerateOptions) (string, error) {
+	var resp string
+	err := f.failover(ctx, opts, func(p Provider, opts GenerateOptions) (err error) {
+		resp, err = p.GenerateWithOptions(ctx, prompt, opts)
+		return err
+	})
+	return resp, err
+}
+
+func (f *FailoverProvider) GenerateWithHistory(ctx context.Context, messages []Message, opts GenerateOptions) (string, error) {
+	var resp string
+	err := f.failover(ctx, opts, func(p Provider, opts GenerateOptions) (err error) {
+		resp, err = p.GenerateWithHistory(ctx, messages, opts)
+		return err
+	})
+	return resp, err
+}
+
+func (f *FailoverProvider) Stream(ctx context.Context, prompt string) (<-chan StreamResponse, error) {
+	var out <-chan StreamResponse
+	err := f.failover(ctx, GenerateOptions{}, func(p Provider, _ GenerateOptions) error {
+		in, err := p.Stream(ctx, prompt)
+		if err != nil {
+			return err
+		}
+		out, err = peekStream(ctx, in)
+		return err
+	})
+	return out, err
+}
+
+func (f *FailoverProvider) StreamWithHistory(ctx context.Context, messages []Message, opts GenerateOptions) (<-chan StreamResponse, error) {
+	var out <-chan StreamResponse
+	err := f.failover(ctx, opts, func(p Provider, opts GenerateOptions) error {
+		in, err := p.StreamWithHistory(ctx, messages, opts)
+		if err != nil {
+			return err
+		}
+		out, err = peekStream(ctx, in)
+		return err
+	})
+	return out, err
+}
+
+// peekStream waits for the first response of a stream so that a provider
+// failing before it produces any output can be failed over. The returned
+// stream replays the first response.
+func peekStream(ctx context.Context, in <-chan StreamResponse) (<-chan StreamResponse, error) {
+	var first StreamResponse
+	var ok bool
+	select {
+	case first, ok = <-in:
+	case <-ctx.Done():
+		Drain(in)
+		return nil, ctx.Err()
+	}
+	if !ok {
+		return in, nil
+	}
+	if first.Error != nil && first.Content == "" && IsFailoverError(first.Error) {
+		Drain(in)
+		return nil, first.Error
+	}
+
+	out := make(chan StreamResponse)
+	go func() {
+		defer close(out)
+		for resp := first; ok; resp, ok = <-in {
+			select {
+			case out <- resp:
+			case <-ctx.Done():
+				Drain(in)
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// Drain receives what is left of a stream nobody reads any more, so that
+// the provider's goroutine sending it can finish
+func Drain(in <-chan StreamResponse) {
+	go func() {
+		for range in {
+		}
+	}()
+}
+
+// ListModels lists the primary provider's models
+func (f *FailoverProvider) ListModels(ctx context.Context) ([]Model, error) {
+	return f.providers[0].ListModels(ctx)
+}
+
+func (f *FailoverProvider) GetCurrentModel() Model {
+	return f.providers[0].GetCurrentModel()
+}
+
+func (f *FailoverProvider) SetModel(model Model) {
+	f.providers[0].SetModel(model)
+}
+
+func (f *FailoverProvider) GetName() string {
+	return f.providers[0].GetName()
+}
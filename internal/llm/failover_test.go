@@ -0,0 +1,165 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mizzy/rigel/internal/config"
+)
+
+type stubProvider struct {
+	Provider
+	name   string
+	model  string
+	resp   string
+	err    error
+	models []string  // opts.Model of each request
+	last   []Message // Messages of the last request
+}
+
+func (s *stubProvider) GenerateWithHistory(ctx context.Context, messages []Message, opts GenerateOptions) (string, error) {
+	s.models = append(s.models, opts.Model)
+	s.last = messages
+	return s.resp, s.err
+}
+
+func (s *stubProvider) StreamWithHistory(ctx context.Context, messages []Message, opts GenerateOptions) (<-chan StreamResponse, error) {
+	s.last = messages
+	ch := make(chan StreamResponse, 2)
+	if s.err != nil {
+		ch <- StreamResponse{Error: s.err, Done: true}
+	} else {
+		ch <- StreamResponse{Content: s.resp}
+		ch <- StreamResponse{Done: true}
+	}
+	close(ch)
+	return ch, nil
+}
+
+func (s *stubProvider) GetName() string        { return s.name }
+func (s *stubProvider) GetCurrentModel() Model { return Model{Name: s.model} }
+
+var errRefused = fmt.Errorf("failed to send request: %w", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")})
+
+func TestIsFailoverError(t *testing.T) {
+	assert.True(t, IsFailoverError(errRefused))
+	assert.True(t, IsFailoverError(fmt.Errorf("failed: %w", &anthropic.Error{StatusCode: 429})))
+	assert.True(t, IsFailoverError(&anthropic.Error{StatusCode: 401}))
+	assert.True(t, IsFailoverError(&anthropic.Error{StatusCode: 529}))
+	assert.False(t, IsFailoverError(&anthropic.Error{StatusCode: 400}))
+	assert.False(t, IsFailoverError(errors.New("no content in response")))
+	assert.False(t, IsFailoverError(fmt.Errorf("failed to send request: %w", context.Canceled)))
+	assert.False(t, IsFailoverError(nil))
+}
+
+func TestFailoverProvider(t *testing.T) {
+	messages := []Message{{Role: "user", Content: "hi"}}
+
+	t.Run("primary answers", func(t *testing.T) {
+		primary := &stubProvider{name: "anthropic", resp: "from anthropic"}
+		failover := NewFailoverProvider(primary, &stubProvider{name: "ollama", resp: "from ollama"})
+
+		resp, err := failover.GenerateWithHistory(context.Background(), messages, GenerateOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "from anthropic", resp)
+		assert.Empty(t, failover.TakeNotice())
+	})
+
+	t.Run("falls back on connection errors", func(t *testing.T) {
+		primary := &stubProvider{name: "anthropic", err: errRefused}
+		fallback := &stubProvider{name: "ollama", model: "llama3.2", resp: "from ollama"}
+		failover := NewFailoverProvider(primary, fallback)
+
+		resp, err := failover.GenerateWithHistory(context.Background(), messages, GenerateOptions{Model: "claude-sonnet-4-20250514"})
+		require.NoError(t, err)
+		assert.Equal(t, "from ollama", resp)
+		assert.Equal(t, []string{""}, fallback.models, "fallback uses its own model")
+
+		notice := failover.TakeNotice()
+		assert.Contains(t, notice, "anthropic failed")
+		assert.Contains(t, notice, "answered by ollama/llama3.2")
+		assert.Empty(t, failover.TakeNotice())
+	})
+
+	t.Run("returns other errors", func(t *testing.T) {
+		primary := &stubProvider{name: "anthropic", err: errors.New("no content in response")}
+		fallback := &stubProvider{name: "ollama", resp: "from ollama"}
+
+		_, err := NewFailoverProvider(primary, fallback).GenerateWithHistory(context.Background(), messages, GenerateOptions{})
+		assert.EqualError(t, err, "no content in response")
+		assert.Empty(t, fallback.models)
+	})
+
+	t.Run("all providers fail", func(t *testing.T) {
+		failover := NewFailoverProvider(&stubProvider{name: "anthropic", err: errRefused}, &stubProvider{name: "ollama", err: errRefused})
+
+		_, err := failover.GenerateWithHistory(context.Background(), messages, GenerateOptions{})
+		assert.ErrorContains(t, err, "all providers failed")
+		assert.ErrorIs(t, err, errRefused)
+	})
+
+	t.Run("streams fall back before any output", func(t *testing.T) {
+		failover := NewFailoverProvider(&stubProvider{name: "anthropic", err: errRefused}, &stubProvider{name: "ollama", resp: "streamed"})
+
+		ch, err := failover.StreamWithHistory(context.Background(), messages, GenerateOptions{})
+		require.NoError(t, err)
+		var content string
+		for resp := range ch {
+			require.NoError(t, resp.Error)
+			content += resp.Content
+		}
+		assert.Equal(t, "streamed", content)
+		assert.Contains(t, failover.TakeNotice(), "answered by ollama")
+	})
+}
+
+func TestNewProviderWithFallbacks(t *testing.T) {
+	provider, err := NewProvider(&config.Config{
+		Provider:          "ollama",
+		Model:             "llama3.2",
+		FallbackProviders: []string{"anthropic", "ollama/qwen2.5-coder"},
+	})
+	require.NoError(t, err)
+
+	failover, ok := As[*FailoverProvider](provider)
+	require.True(t, ok)
+	// anthropic is skipped without an API key
+	require.Len(t, failover.Providers(), 2)
+	assert.Equal(t, "qwen2.5-coder", failover.Providers()[1].GetCurrentModel().Name)
+
+	ollama, ok := As[*OllamaProvider](provider)
+	require.True(t, ok)
+	assert.Equal(t, "llama3.2", ollama.GetCurrentModel().Name)
+}
+
+func TestPeekStreamCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// The provider only answers after the caller gave up
+	in := make(chan StreamResponse)
+	peeked, sent := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(sent)
+		defer close(in)
+		<-peeked
+		in <- StreamResponse{Content: "late"}
+	}()
+
+	_, err := peekStream(ctx, in)
+	close(peeked)
+	assert.ErrorIs(t, err, context.Canceled)
+	select {
+	case <-sent:
+	case <-time.After(time.Second):
+		t.Fatal("the provider was left blocked sending")
+	}
+}
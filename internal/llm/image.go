@@ -0,0 +1,20 @@
+package llm
+
+import "encoding/base64"
+
+// Image is an image attached to a user message, such as a screenshot, for
+// models that support vision
+type Image struct {
+	MediaType string `json:"media_type"` // image/png, image/jpeg, image/gif or image/webp
+	Data      []byte `json:"data"`
+}
+
+// Base64 returns the image data encoded as the APIs take it
+func (i Image) Base64() string {
+	return base64.StdEncoding.EncodeToString(i.Data)
+}
+
+// DataURL returns the image as a data: URL
+func (i Image) DataURL() string {
+	return "data:" + i.MediaType + ";base64," + i.Base64()
+}
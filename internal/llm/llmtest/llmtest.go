@@ -0,0 +1,133 @@
+// Package llmtest provides a fake llm.Provider for the tests of packages
+// that talk to a model.
+package llmtest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/mizzy/rigel/internal/llm"
+)
+
+// Provider is a fake llm.Provider named "fake" with a single model,
+// "fake-model". It answers every request with Response, streams Chunks and
+// remembers the last request it was sent and how many it was.
+type Provider struct {
+	Response string   // Answer to the Generate methods
+	Chunks   []string // Content streamed by the Stream methods
+	Block    bool     // Streams send nothing until the request is cancelled
+
+	mu       sync.Mutex
+	prompt   string
+	messages []llm.Message
+	opts     llm.GenerateOptions
+	requests int
+}
+
+// Prompt returns the last prompt sent, the content of the last message for
+// the WithHistory methods
+func (p *Provider) Prompt() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.prompt
+}
+
+// Messages returns the messages of the last request sent with its history
+func (p *Provider) Messages() []llm.Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.messages
+}
+
+// Options returns the options of the last request sent with any
+func (p *Provider) Options() llm.GenerateOptions {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.opts
+}
+
+// Requests returns how many requests were sent
+func (p *Provider) Requests() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.requests
+}
+
+func (p *Provider) Generate(ctx context.Context, prompt string) (string, error) {
+	return p.GenerateWithOptions(ctx, prompt, llm.GenerateOptions{})
+}
+
+func (p *Provider) GenerateWithOptions(ctx context.Context, prompt string, opts llm.GenerateOptions) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	p.record(prompt, nil, opts)
+	return p.Response, nil
+}
+
+func (p *Provider) GenerateWithHistory(ctx context.Context, messages []llm.Message, opts llm.GenerateOptions) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	p.record(lastContent(messages), messages, opts)
+	return p.Response, nil
+}
+
+func (p *Provider) Stream(ctx context.Context, prompt string) (<-chan llm.StreamResponse, error) {
+	p.record(prompt, nil, llm.GenerateOptions{})
+	return p.stream(ctx), nil
+}
+
+func (p *Provider) StreamWithHistory(ctx context.Context, messages []llm.Message, opts llm.GenerateOptions) (<-chan llm.StreamResponse, error) {
+	p.record(lastContent(messages), messages, opts)
+	return p.stream(ctx), nil
+}
+
+func (p *Provider) ListModels(ctx context.Context) ([]llm.Model, error) {
+	return []llm.Model{p.GetCurrentModel()}, nil
+}
+
+func (p *Provider) GetCurrentModel() llm.Model { return llm.Model{Name: "fake-model"} }
+func (p *Provider) SetModel(model llm.Model)   {}
+func (p *Provider) GetName() string            { return "fake" }
+
+// record remembers a request
+func (p *Provider) record(prompt string, messages []llm.Message, opts llm.GenerateOptions) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.prompt, p.messages, p.opts = prompt, messages, opts
+	p.requests++
+}
+
+// stream sends the chunks, then a done response, unless it blocks
+func (p *Provider) stream(ctx context.Context) <-chan llm.StreamResponse {
+	ch := make(chan llm.StreamResponse)
+	go func() {
+		defer close(ch)
+		if p.Block {
+			<-ctx.Done()
+			return
+		}
+		for _, chunk := range p.Chunks {
+			select {
+			case ch <- llm.StreamResponse{Content: chunk}:
+			case <-ctx.Done():
+				return
+			}
+		}
+		select {
+		case ch <- llm.StreamResponse{Done: true}:
+		case <-ctx.Done():
+		}
+	}()
+	return ch
+}
+
+// lastContent returns the content of the last message, or "" if there is
+// none
+func lastContent(messages []llm.Message) string {
+	if len(messages) == 0 {
+		return ""
+	}
+	return messages[len(messages)-1].Content
+}
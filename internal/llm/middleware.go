@@ -0,0 +1,403 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/mizzy/rigel/internal/config"
+	"github.com/mizzy/rigel/internal/logging"
+)
+
+// Request is a request to a provider as middleware sees it
+type Request struct {
+	Messages []Message
+	Options  GenerateOptions
+}
+
+// Middleware transforms the requests to a provider and its responses, e.g.
+// adding context to prompts or removing parts of responses. Each step is
+// optional.
+type Middleware struct {
+	Name string
+
+	// Request changes a request before it is sent
+	Request func(ctx context.Context, req *Request) error
+	// Response returns what to give instead of a complete response
+	Response func(ctx context.Context, req Request, response string) (string, error)
+	// Stream transforms a streamed response as it arrives. Without it,
+	// middleware with a Response step gets a streamed response once it is
+	// complete, which is then passed on at once.
+	Stream func(ctx context.Context, req Request, in <-chan StreamResponse) <-chan StreamResponse
+}
+
+// NewMiddleware returns the middleware of a RIGEL_MIDDLEWARE entry
+func NewMiddleware(name string) (Middleware, error) {
+	switch {
+	case name == config.MiddlewareAgentsMD:
+		return AgentsMDMiddleware(), nil
+	case name == config.MiddlewareStripThinking:
+		return StripThinkingMiddleware(), nil
+	case name == config.MiddlewareLog:
+		return LogMiddleware(), nil
+	case strings.HasPrefix(name, config.MiddlewareExecPrefix):
+		return ExecMiddleware(strings.TrimPrefix(name, config.MiddlewareExecPrefix)), nil
+	default:
+		return Middleware{}, fmt.Errorf("unknown middleware %q", name)
+	}
+}
+
+// MiddlewareProvider passes the requests to a provider and its responses
+// through middleware: requests in order, responses in reverse order
+type MiddlewareProvider struct {
+	Provider
+	middleware []Middleware
+}
+
+// NewMiddlewareProvider wraps a provider with middleware
+func NewMiddlewareProvider(p Provider, middleware ...Middleware) *MiddlewareProvider {
+	return &MiddlewareProvider{Provider: p, middleware: middleware}
+}
+
+// Unwrap returns the underlying provider
+func (m *MiddlewareProvider) Unwrap() Provider {
+	return m.Provider
+}
+
+// request passes a request through the middleware
+func (m *MiddlewareProvider) request(ctx context.Context, messages []Message, opts GenerateOptions) (Request, error) {
+	// Middleware may change the messages without changing the caller's
+	req := Request{Messages: slices.Clone(messages), Options: opts}
+	for _, mw := range m.middleware {
+		if mw.Request == nil {
+			continue
+		}
+		if err := mw.Request(ctx, &req); err != nil {
+			return req, fmt.Errorf("%s middleware: %w", mw.Name, err)
+		}
+	}
+	return req, nil
+}
+
+func (m *MiddlewareProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	return m.GenerateWithOptions(ctx, prompt, GenerateOptions{})
+}
+
+func (m *MiddlewareProvider) GenerateWithOptions(ctx context.Context, prompt string, opts GenerateOptions) (string, error) {
+	return m.GenerateWithHistory(ctx, []Message{{Role: "user", Content: prompt}}, opts)
+}
+
+func (m *MiddlewareProvider) GenerateWithHistory(ctx context.Context, messages []Message, opts GenerateOptions) (string, error) {
+	req, err := m.request(ctx, messages, opts)
+	if err != nil {
+		return "", err
+	}
+	response, err := m.Provider.GenerateWithHistory(ctx, req.Messages, req.Options)
+	if err != nil {
+		return response, err
+	}
+	for _, mw := range slices.Backward(m.middleware) {
+		if mw.Response == nil {
+			continue
+		}
+		if response, err = mw.Response(ctx, req, response); err != nil {
+			return "", fmt.Errorf("%s middleware: %w", mw.Name, err)
+		}
+	}
+	return response, nil
+}
+
+func (m *MiddlewareProvider) Stream(ctx context.Context, prompt string) (<-chan StreamResponse, error) {
+	return m.StreamWithHistory(ctx, []Message{{Role: "user", Content: prompt}}, GenerateOptions{})
+}
+
+func (m *MiddlewareProvider) StreamWithHistory(ctx context.Context, messages []Message, opts GenerateOptions) (<-chan StreamResponse, error) {
+	req, err := m.request(ctx, messages, opts)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := m.Provider.StreamWithHistory(ctx, req.Messages, req.Options)
+	if err != nil {
+		return nil, err
+	}
+	for _, mw := range slices.Backward(m.middleware) {
+		switch {
+		case mw.Stream != nil:
+			stream = mw.Stream(ctx, req, stream)
+		case mw.Response != nil:
+			stream = completeStream(ctx, req, mw, stream)
+		}
+	}
+	return stream, nil
+}
+
+// send passes a piece of a streamed response on unless the request was
+// cancelled, reporting whether it did
+func send(ctx context.Context, out chan<- StreamResponse, chunk StreamResponse) bool {
+	select {
+	case out <- chunk:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// completeStream passes a streamed response through middleware without a
+// Stream step once the response is complete. A response cut short by an
+// error is passed on as it is.
+func completeStream(ctx context.Context, req Request, mw Middleware, in <-chan StreamResponse) <-chan StreamResponse {
+	out := make(chan StreamResponse)
+	go func() {
+		defer close(out)
+		var response strings.Builder
+		for chunk := range in {
+			response.WriteString(chunk.Content)
+			if chunk.Error != nil {
+				send(ctx, out, StreamResponse{Content: response.String(), Error: chunk.Error, Done: true})
+				return
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		content, err := mw.Response(ctx, req, response.String())
+		if err != nil {
+			send(ctx, out, StreamResponse{Error: fmt.Errorf("%s middleware: %w", mw.Name, err), Done: true})
+			return
+		}
+		if send(ctx, out, StreamResponse{Content: content}) {
+			send(ctx, out, StreamResponse{Done: true})
+		}
+	}()
+	return out
+}
+
+// AgentsMDMiddleware puts the AGENTS.md files that apply to the request and
+// the project's memory notes before the system prompt
+func AgentsMDMiddleware() Middleware {
+	return Middleware{
+		Name: config.MiddlewareAgentsMD,
+		Request: func(ctx context.Context, req *Request) error {
+			req.Options.SystemPrompt = PrependAgentsContext(req.Options.SystemPrompt, req.Messages...)
+			return nil
+		},
+	}
+}
+
+// GlobalAgentsMDMiddleware puts ~/.rigel/AGENTS.md alone before the system
+// prompt, in place of AgentsMDMiddleware in directories that aren't trusted
+func GlobalAgentsMDMiddleware() Middleware {
+	return Middleware{
+		Name: config.MiddlewareAgentsMD,
+		Request: func(ctx context.Context, req *Request) error {
+			req.Options.SystemPrompt = PrependGlobalAgentsContext(req.Options.SystemPrompt)
+			return nil
+		},
+	}
+}
+
+// StripThinkingMiddleware removes the <think> sections that reasoning
+// models such as DeepSeek-R1 and Qwen3 start their responses with
+func StripThinkingMiddleware() Middleware {
+	return Middleware{
+		Name: config.MiddlewareStripThinking,
+		Response: func(ctx context.Context, req Request, response string) (string, error) {
+			var f thinkingFilter
+			return f.write(response) + f.flush(), nil
+		},
+		Stream: func(ctx context.Context, req Request, in <-chan StreamResponse) <-chan StreamResponse {
+			out := make(chan StreamResponse)
+			go func() {
+				defer close(out)
+				var f thinkingFilter
+				for chunk := range in {
+					chunk.Content = f.write(chunk.Content)
+					if chunk.Done || chunk.Error != nil {
+						chunk.Content += f.flush()
+					}
+					if chunk.Content == "" && !chunk.Done && chunk.Error == nil {
+						continue
+					}
+					if !send(ctx, out, chunk) {
+						return
+					}
+				}
+				if rest := f.flush(); rest != "" {
+					send(ctx, out, StreamResponse{Content: rest})
+				}
+			}()
+			return out
+		},
+	}
+}
+
+// thinkingFilter removes <think> sections from a response piece by piece,
+// holding back what may be the start of a tag
+type thinkingFilter struct {
+	pending  string
+	thinking bool
+	trim     bool // Drop the whitespace after a section
+}
+
+func (f *thinkingFilter) write(s string) string {
+	f.pending += s
+	var out strings.Builder
+	for {
+		tag := "<think>"
+		if f.thinking {
+			tag = "</think>"
+		}
+		if i := strings.Index(f.pending, tag); i >= 0 {
+			if !f.thinking {
+				f.emit(&out, f.pending[:i])
+			}
+			f.pending = f.pending[i+len(tag):]
+			f.thinking = !f.thinking
+			f.trim = !f.thinking
+			continue
+		}
+
+		keep := 0
+		for n := min(len(tag)-1, len(f.pending)); n > 0; n-- {
+			if strings.HasSuffix(f.pending, tag[:n]) {
+				keep = n
+				break
+			}
+		}
+		if !f.thinking {
+			f.emit(&out, f.pending[:len(f.pending)-keep])
+		}
+		f.pending = f.pending[len(f.pending)-keep:]
+		return out.String()
+	}
+}
+
+func (f *thinkingFilter) emit(out *strings.Builder, s string) {
+	if f.trim {
+		s = strings.TrimLeft(s, " \t\r\n")
+		f.trim = s == ""
+	}
+	out.WriteString(s)
+}
+
+// flush returns what was held back once the response is complete
+func (f *thinkingFilter) flush() string {
+	var out strings.Builder
+	if !f.thinking {
+		f.emit(&out, f.pending)
+	}
+	f.pending = ""
+	return out.String()
+}
+
+// maxLogBytes limits how much of a prompt or response LogMiddleware logs
+const maxLogBytes = 2000
+
+// LogMiddleware logs the requests to the provider and its responses at
+// info level
+func LogMiddleware() Middleware {
+	logResponse := func(ctx context.Context, response string) {
+		slog.InfoContext(ctx, "llm response", "response", logging.Truncate(response, maxLogBytes))
+	}
+	return Middleware{
+		Name: config.MiddlewareLog,
+		Request: func(ctx context.Context, req *Request) error {
+			prompt := ""
+			if len(req.Messages) > 0 {
+				prompt = req.Messages[len(req.Messages)-1].Content
+			}
+			slog.InfoContext(ctx, "llm request", "messages", len(req.Messages), "prompt", logging.Truncate(prompt, maxLogBytes))
+			return nil
+		},
+		Response: func(ctx context.Context, req Request, response string) (string, error) {
+			logResponse(ctx, response)
+			return response, nil
+		},
+		Stream: func(ctx context.Context, req Request, in <-chan StreamResponse) <-chan StreamResponse {
+			out := make(chan StreamResponse)
+			go func() {
+				defer close(out)
+				var response strings.Builder
+				for chunk := range in {
+					response.WriteString(chunk.Content)
+					if !send(ctx, out, chunk) {
+						return
+					}
+				}
+				logResponse(ctx, response.String())
+			}()
+			return out
+		},
+	}
+}
+
+// hookMessage is what an exec hook reads on its standard input and writes
+// on its standard output
+type hookMessage struct {
+	Stage    string    `json:"stage"` // "request" or "response"
+	System   string    `json:"system,omitempty"`
+	Messages []Message `json:"messages,omitempty"`
+	Response string    `json:"response,omitempty"`
+}
+
+// ExecMiddleware runs a shell command as a hook for every request and
+// response. The command reads a JSON object on its standard input: for
+// requests, {"stage":"request","system":...,"messages":[...]}, and for
+// responses, {"stage":"response","response":...}. It writes the object with
+// its changes to its standard output, or nothing to leave it as it is. A
+// command that fails fails the request.
+func ExecMiddleware(command string) Middleware {
+	return Middleware{
+		Name: config.MiddlewareExecPrefix + command,
+		Request: func(ctx context.Context, req *Request) error {
+			changed, err := runHook(ctx, command, hookMessage{Stage: "request", System: req.Options.SystemPrompt, Messages: req.Messages})
+			if err != nil || changed == nil {
+				return err
+			}
+			req.Options.SystemPrompt, req.Messages = changed.System, changed.Messages
+			return nil
+		},
+		Response: func(ctx context.Context, req Request, response string) (string, error) {
+			changed, err := runHook(ctx, command, hookMessage{Stage: "response", Response: response})
+			if err != nil || changed == nil {
+				return response, err
+			}
+			return changed.Response, nil
+		},
+	}
+}
+
+// runHook runs a hook command with a message, returning the message the
+// command wrote, or nil if it wrote nothing
+func runHook(ctx context.Context, command string, message hookMessage) (*hookMessage, error) {
+	input, err := json.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = bytes.NewReader(input)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	if len(bytes.TrimSpace(output)) == 0 {
+		return nil, nil
+	}
+
+	var changed hookMessage
+	if err := json.Unmarshal(output, &changed); err != nil {
+		return nil, fmt.Errorf("invalid output: %w", err)
+	}
+	return &changed, nil
+}
@@ -1,19 +1,22 @@
 package llm
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"time"
+
+	"github.com/mizzy/rigel/internal/config"
 )
 
 type OllamaProvider struct {
-	baseURL string
-	model   Model
-	client  *http.Client
+	baseURL  string
+	model    Model
+	client   *http.Client
+	defaults GenerateOptions
 }
 
 func NewOllamaProvider(baseURL, model string) (*OllamaProvider, error) {
@@ -30,53 +33,98 @@ func NewOllamaProvider(baseURL, model string) (*OllamaProvider, error) {
 	return &OllamaProvider{
 		baseURL: strings.TrimSuffix(baseURL, "/"),
 		model:   modelStruct,
-		client:  &http.Client{},
+		client:  newHTTPClient(config.DefaultConnectTimeout),
 	}, nil
 }
 
-type ollamaGenerateRequest struct {
-	Model   string        `json:"model"`
-	Prompt  string        `json:"prompt"`
-	System  string        `json:"system,omitempty"`
-	Stream  bool          `json:"stream"`
-	Options ollamaOptions `json:"options,omitempty"`
+// SetConnectTimeout sets how long connecting to the server may take; 0
+// removes the limit
+func (p *OllamaProvider) SetConnectTimeout(timeout time.Duration) {
+	p.client = newHTTPClient(timeout)
 }
 
 type ollamaChatRequest struct {
-	Model    string          `json:"model"`
-	Messages []ollamaMessage `json:"messages"`
-	Stream   bool            `json:"stream"`
-	Options  ollamaOptions   `json:"options,omitempty"`
+	Model     string          `json:"model"`
+	Messages  []ollamaMessage `json:"messages"`
+	Stream    bool            `json:"stream"`
+	Options   ollamaOptions   `json:"options,omitempty"`
+	KeepAlive string          `json:"keep_alive,omitempty"`
+	Format    any             `json:"format,omitempty"` // "json" or a JSON schema
 }
 
 type ollamaMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role    string   `json:"role"`
+	Content string   `json:"content"`
+	Images  [][]byte `json:"images,omitempty"` // Encoded in base64 by encoding/json
 }
 
+// ollamaDoneLength is the done_reason of a response cut off at its length
+// limit
+const ollamaDoneLength = "length"
+
 type ollamaChatResponse struct {
-	Model     string        `json:"model"`
-	CreatedAt string        `json:"created_at"`
-	Message   ollamaMessage `json:"message"`
-	Done      bool          `json:"done"`
+	Model      string        `json:"model"`
+	CreatedAt  string        `json:"created_at"`
+	Message    ollamaMessage `json:"message"`
+	Done       bool          `json:"done"`
+	DoneReason string        `json:"done_reason,omitempty"` // "length" when cut off at num_predict or the context size
 }
 
 type ollamaOptions struct {
-	Temperature float32 `json:"temperature,omitempty"`
-	NumPredict  int     `json:"num_predict,omitempty"`
+	Temperature *float32 `json:"temperature,omitempty"` // Nil for the model's default
+	NumPredict  int      `json:"num_predict,omitempty"`
+	NumCtx      int      `json:"num_ctx,omitempty"`
+	TopP        float32  `json:"top_p,omitempty"`
+	TopK        int      `json:"top_k,omitempty"`
+	Seed        int      `json:"seed,omitempty"`
+}
+
+// SetDefaults sets options applied to every request unless the request
+// sets them itself
+func (p *OllamaProvider) SetDefaults(opts GenerateOptions) {
+	p.defaults = opts
+}
+
+// Defaults returns the options applied to every request
+func (p *OllamaProvider) Defaults() GenerateOptions {
+	return p.defaults
 }
 
-type ollamaGenerateResponse struct {
-	Model              string `json:"model"`
-	Response           string `json:"response"`
-	Done               bool   `json:"done"`
-	Context            []int  `json:"context,omitempty"`
-	TotalDuration      int64  `json:"total_duration,omitempty"`
-	LoadDuration       int64  `json:"load_duration,omitempty"`
-	PromptEvalCount    int    `json:"prompt_eval_count,omitempty"`
-	PromptEvalDuration int64  `json:"prompt_eval_duration,omitempty"`
-	EvalCount          int    `json:"eval_count,omitempty"`
-	EvalDuration       int64  `json:"eval_duration,omitempty"`
+// requestOptions merges per-request options over the provider defaults and
+// returns them with the keep_alive duration
+func (p *OllamaProvider) requestOptions(opts GenerateOptions) (ollamaOptions, string) {
+	o := ollamaOptions{
+		Temperature: temperature(p.defaults),
+		NumPredict:  p.defaults.MaxTokens,
+		NumCtx:      p.defaults.NumCtx,
+		TopP:        p.defaults.TopP,
+		TopK:        p.defaults.TopK,
+		Seed:        p.defaults.Seed,
+	}
+	keepAlive := p.defaults.KeepAlive
+
+	if t := temperature(opts); t != nil {
+		o.Temperature = t
+	}
+	if opts.MaxTokens > 0 {
+		o.NumPredict = opts.MaxTokens
+	}
+	if opts.NumCtx > 0 {
+		o.NumCtx = opts.NumCtx
+	}
+	if opts.TopP > 0 {
+		o.TopP = opts.TopP
+	}
+	if opts.TopK > 0 {
+		o.TopK = opts.TopK
+	}
+	if opts.Seed != 0 {
+		o.Seed = opts.Seed
+	}
+	if opts.KeepAlive != "" {
+		keepAlive = opts.KeepAlive
+	}
+	return o, keepAlive
 }
 
 func (p *OllamaProvider) Generate(ctx context.Context, prompt string) (string, error) {
@@ -92,6 +140,31 @@ func (p *OllamaProvider) GenerateWithOptions(ctx context.Context, prompt string,
 }
 
 func (p *OllamaProvider) GenerateWithHistory(ctx context.Context, messages []Message, opts GenerateOptions) (string, error) {
+	resp, err := p.post(ctx, "/api/chat", p.chatRequest(messages, opts, false))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var ollamaResp ollamaChatResponse
+	if err := json.Unmarshal(body, &ollamaResp); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	if ollamaResp.DoneReason == ollamaDoneLength {
+		return ollamaResp.Message.Content, ErrMaxTokens
+	}
+	return ollamaResp.Message.Content, nil
+}
+
+// chatRequest builds an /api/chat request with the system prompt followed
+// by the conversation
+func (p *OllamaProvider) chatRequest(messages []Message, opts GenerateOptions, stream bool) ollamaChatRequest {
 	model := p.model.Name
 	if opts.Model != "" {
 		model = opts.Model
@@ -101,128 +174,58 @@ func (p *OllamaProvider) GenerateWithHistory(ctx context.Context, messages []Mes
 	ollamaMessages := make([]ollamaMessage, 0, len(messages)+1)
 
 	// Add system message if we have system prompt
-	systemPrompt := opts.SystemPrompt
-	if systemPrompt == "" {
-		systemPrompt = PrependAgentsContext("")
-	} else {
-		systemPrompt = PrependAgentsContext(systemPrompt)
-	}
-
-	if systemPrompt != "" {
+	if opts.SystemPrompt != "" {
 		ollamaMessages = append(ollamaMessages, ollamaMessage{
 			Role:    "system",
-			Content: systemPrompt,
+			Content: opts.SystemPrompt,
 		})
 	}
 
 	// Add conversation messages
 	for _, msg := range messages {
-		ollamaMessages = append(ollamaMessages, ollamaMessage(msg))
+		message := ollamaMessage{Role: msg.Role, Content: msg.Content}
+		for _, image := range msg.Images {
+			message.Images = append(message.Images, image.Data)
+		}
+		ollamaMessages = append(ollamaMessages, message)
 	}
 
 	reqBody := ollamaChatRequest{
 		Model:    model,
 		Messages: ollamaMessages,
-		Stream:   false,
-	}
-
-	if opts.Temperature > 0 {
-		reqBody.Options.Temperature = opts.Temperature
-	}
-
-	if opts.MaxTokens > 0 {
-		reqBody.Options.NumPredict = opts.MaxTokens
-	}
-
-	jsonBody, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	// Use /api/chat endpoint for conversation history
-	req, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/api/chat", bytes.NewBuffer(jsonBody))
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		Stream:   stream,
 	}
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := p.client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("ollama API error (status %d): %s", resp.StatusCode, string(body))
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
-	}
-
-	var ollamaResp ollamaChatResponse
-	if err := json.Unmarshal(body, &ollamaResp); err != nil {
-		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+	reqBody.Options, reqBody.KeepAlive = p.requestOptions(opts)
+	if format := opts.ResponseFormat; format != nil {
+		reqBody.Format = "json"
+		if format.Type == FormatJSONSchema {
+			reqBody.Format = format.schema()
+		}
 	}
-
-	return ollamaResp.Message.Content, nil
+	return reqBody
 }
 
 func (p *OllamaProvider) Stream(ctx context.Context, prompt string) (<-chan StreamResponse, error) {
+	return p.StreamWithHistory(ctx, []Message{{Role: "user", Content: prompt}}, GenerateOptions{})
+}
+
+func (p *OllamaProvider) StreamWithHistory(ctx context.Context, messages []Message, opts GenerateOptions) (<-chan StreamResponse, error) {
+	reqBody := p.chatRequest(messages, opts, true)
 	ch := make(chan StreamResponse)
 
 	go func() {
 		defer close(ch)
 
-		reqBody := ollamaGenerateRequest{
-			Model:  p.model.Name,
-			Prompt: prompt,
-			Stream: true,
-		}
-
-		jsonBody, err := json.Marshal(reqBody)
-		if err != nil {
-			ch <- StreamResponse{
-				Error: fmt.Errorf("failed to marshal request: %w", err),
-				Done:  true,
-			}
-			return
-		}
-
-		req, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/api/generate", bytes.NewBuffer(jsonBody))
-		if err != nil {
-			ch <- StreamResponse{
-				Error: fmt.Errorf("failed to create request: %w", err),
-				Done:  true,
-			}
-			return
-		}
-		req.Header.Set("Content-Type", "application/json")
-
-		resp, err := p.client.Do(req)
+		resp, err := p.post(ctx, "/api/chat", reqBody)
 		if err != nil {
-			ch <- StreamResponse{
-				Error: fmt.Errorf("failed to send request: %w", err),
-				Done:  true,
-			}
+			ch <- StreamResponse{Error: err, Done: true}
 			return
 		}
 		defer resp.Body.Close()
 
-		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
-			ch <- StreamResponse{
-				Error: fmt.Errorf("ollama API error (status %d): %s", resp.StatusCode, string(body)),
-				Done:  true,
-			}
-			return
-		}
-
 		decoder := json.NewDecoder(resp.Body)
 		for {
-			var streamResp ollamaGenerateResponse
+			var streamResp ollamaChatResponse
 			if err := decoder.Decode(&streamResp); err != nil {
 				if err == io.EOF {
 					break
@@ -234,14 +237,18 @@ func (p *OllamaProvider) Stream(ctx context.Context, prompt string) (<-chan Stre
 				return
 			}
 
-			if streamResp.Response != "" {
+			if streamResp.Message.Content != "" {
 				ch <- StreamResponse{
-					Content: streamResp.Response,
+					Content: streamResp.Message.Content,
 					Done:    false,
 				}
 			}
 
 			if streamResp.Done {
+				if streamResp.DoneReason == ollamaDoneLength {
+					ch <- StreamResponse{Error: ErrMaxTokens, Done: true}
+					break
+				}
 				ch <- StreamResponse{
 					Done: true,
 				}
@@ -266,23 +273,30 @@ type ollamaListResponse struct {
 	Models []ollamaModel `json:"models"`
 }
 
+// ListModels returns the installed models with the context window and
+// capabilities of each
 func (p *OllamaProvider) ListModels(ctx context.Context) ([]Model, error) {
+	models, err := p.tags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.describeOllamaModels(ctx, models)
+	return models, nil
+}
+
+// tags returns the installed models as listed by /api/tags
+func (p *OllamaProvider) tags(ctx context.Context) ([]Model, error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", p.baseURL+"/api/tags", nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	resp, err := p.client.Do(req)
+	resp, err := p.do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("ollama API error (status %d): %s", resp.StatusCode, string(body))
-	}
-
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response: %w", err)
@@ -308,6 +322,12 @@ func (p *OllamaProvider) ListModels(ctx context.Context) ([]Model, error) {
 	return models, nil
 }
 
+// Ping checks that the Ollama server is reachable
+func (p *OllamaProvider) Ping(ctx context.Context) error {
+	_, err := p.tags(ctx)
+	return err
+}
+
 func (p *OllamaProvider) GetCurrentModel() Model {
 	return p.model
 }
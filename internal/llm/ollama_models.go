@@ -0,0 +1,162 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// PullProgress is a status update while Ollama downloads a model
+type PullProgress struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Percent returns the download progress of the current layer, or -1 if the
+// status has no size information
+func (p PullProgress) Percent() int {
+	if p.Total <= 0 {
+		return -1
+	}
+	return int(p.Completed * 100 / p.Total)
+}
+
+// ModelInfo describes a model as reported by Ollama's /api/show
+type ModelInfo struct {
+	Name         string                 `json:"-"`
+	License      string                 `json:"license"`
+	Parameters   string                 `json:"parameters"`
+	Template     string                 `json:"template"`
+	Details      ModelDetails           `json:"details"`
+	ModelInfo    map[string]interface{} `json:"model_info"`
+	Capabilities []string               `json:"capabilities"`
+	ModifiedAt   string                 `json:"modified_at"`
+}
+
+// ContextLength returns the model's context window from its metadata, or 0
+func (m *ModelInfo) ContextLength() int {
+	arch, _ := m.ModelInfo["general.architecture"].(string)
+	if n, ok := m.ModelInfo[arch+".context_length"].(float64); ok {
+		return int(n)
+	}
+	return 0
+}
+
+// RunningModel is a model currently loaded in memory, from /api/ps
+type RunningModel struct {
+	Name      string       `json:"name"`
+	Model     string       `json:"model"`
+	Size      int64        `json:"size"`
+	SizeVRAM  int64        `json:"size_vram"`
+	ExpiresAt string       `json:"expires_at"`
+	Details   ModelDetails `json:"details"`
+}
+
+// Pull downloads a model, reporting each status update to progress
+func (p *OllamaProvider) Pull(ctx context.Context, name string, progress func(PullProgress)) error {
+	resp, err := p.post(ctx, "/api/pull", map[string]interface{}{"model": name, "stream": true})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var update PullProgress
+		if err := decoder.Decode(&update); err != nil {
+			if errors.Is(err, io.EOF) {
+				return fmt.Errorf("pull of %s ended unexpectedly", name)
+			}
+			return fmt.Errorf("failed to decode pull progress: %w", err)
+		}
+		if update.Error != "" {
+			return fmt.Errorf("failed to pull %s: %s", name, update.Error)
+		}
+		if progress != nil {
+			progress(update)
+		}
+		if update.Status == "success" {
+			return nil
+		}
+	}
+}
+
+// Show returns details about an installed model
+func (p *OllamaProvider) Show(ctx context.Context, name string) (*ModelInfo, error) {
+	resp, err := p.post(ctx, "/api/show", map[string]string{"model": name})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var info ModelInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	info.Name = name
+	return &info, nil
+}
+
+// Running returns the models currently loaded in memory
+func (p *OllamaProvider) Running(ctx context.Context) ([]RunningModel, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", p.baseURL+"/api/ps", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := p.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var psResp struct {
+		Models []RunningModel `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&psResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return psResp.Models, nil
+}
+
+// post sends a JSON request to the Ollama API
+func (p *OllamaProvider) post(ctx context.Context, path string, body interface{}) (*http.Response, error) {
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+path, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return p.do(req)
+}
+
+// do sends a request and turns failures and non-200 responses into
+// classified errors
+func (p *OllamaProvider) do(req *http.Request) (*http.Response, error) {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, classifyError("ollama", fmt.Errorf("failed to send request: %w", err))
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &Error{
+			Kind:       statusKind(resp.StatusCode, string(body)),
+			Provider:   "ollama",
+			StatusCode: resp.StatusCode,
+			Err:        fmt.Errorf("ollama API error (status %d): %s", resp.StatusCode, string(body)),
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,149 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newOllamaTestServer(t *testing.T) *OllamaProvider {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/pull", func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		if req["model"] == "missing" {
+			fmt.Fprintln(w, `{"error":"pull model manifest: file does not exist"}`)
+			return
+		}
+		fmt.Fprintln(w, `{"status":"pulling manifest"}`)
+		fmt.Fprintln(w, `{"status":"pulling abc","digest":"sha256:abc","total":200,"completed":50}`)
+		fmt.Fprintln(w, `{"status":"success"}`)
+	})
+	mux.HandleFunc("POST /api/show", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"parameters":"stop \"<|eot|>\"","details":{"family":"llama","parameter_size":"3.2B","quantization_level":"Q4_K_M"},
+			"model_info":{"general.architecture":"llama","llama.context_length":131072},"capabilities":["completion","tools"]}`)
+	})
+	mux.HandleFunc("GET /api/tags", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"models":[{"name":"llama3.2:latest","model":"llama3.2:latest","size":3000,"details":{"family":"llama"}}]}`)
+	})
+	mux.HandleFunc("GET /api/ps", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"models":[{"name":"llama3.2:latest","model":"llama3.2:latest","size":3000,"size_vram":1500}]}`)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	provider, err := NewOllamaProvider(server.URL, "llama3.2")
+	require.NoError(t, err)
+	return provider
+}
+
+func TestOllamaPull(t *testing.T) {
+	provider := newOllamaTestServer(t)
+
+	var updates []PullProgress
+	err := provider.Pull(context.Background(), "llama3.2", func(p PullProgress) {
+		updates = append(updates, p)
+	})
+	require.NoError(t, err)
+	require.Len(t, updates, 3)
+	assert.Equal(t, -1, updates[0].Percent())
+	assert.Equal(t, 25, updates[1].Percent())
+	assert.Equal(t, "success", updates[2].Status)
+
+	err = provider.Pull(context.Background(), "missing", nil)
+	assert.EqualError(t, err, "failed to pull missing: pull model manifest: file does not exist")
+}
+
+func TestOllamaShow(t *testing.T) {
+	provider := newOllamaTestServer(t)
+
+	info, err := provider.Show(context.Background(), "llama3.2")
+	require.NoError(t, err)
+	assert.Equal(t, "llama3.2", info.Name)
+	assert.Equal(t, "3.2B", info.Details.ParameterSize)
+	assert.Equal(t, 131072, info.ContextLength())
+	assert.Equal(t, []string{"completion", "tools"}, info.Capabilities)
+}
+
+func TestOllamaRunning(t *testing.T) {
+	provider := newOllamaTestServer(t)
+
+	models, err := provider.Running(context.Background())
+	require.NoError(t, err)
+	require.Len(t, models, 1)
+	assert.Equal(t, "llama3.2:latest", models[0].Name)
+	assert.Equal(t, int64(1500), models[0].SizeVRAM)
+}
+
+func TestAs(t *testing.T) {
+	ollama, err := NewOllamaProvider("", "llama3.2")
+	require.NoError(t, err)
+
+	found, ok := As[*OllamaProvider](NewTracingProvider(ollama))
+	assert.True(t, ok)
+	assert.Same(t, ollama, found)
+
+	_, ok = As[*CachingProvider](NewTracingProvider(ollama))
+	assert.False(t, ok)
+}
+
+func TestOllamaRequestOptions(t *testing.T) {
+	var got ollamaChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		fmt.Fprint(w, `{"message":{"role":"assistant","content":"ok"},"done":true}`)
+	}))
+	t.Cleanup(server.Close)
+
+	provider, err := NewOllamaProvider(server.URL, "llama3.2")
+	require.NoError(t, err)
+	provider.SetDefaults(GenerateOptions{NumCtx: 8192, TopP: 0.9, Seed: 42, KeepAlive: "30m"})
+
+	_, err = provider.GenerateWithOptions(context.Background(), "hi", GenerateOptions{Seed: 7, TopK: 20})
+	require.NoError(t, err)
+	assert.Equal(t, ollamaOptions{NumCtx: 8192, TopP: 0.9, TopK: 20, Seed: 7}, got.Options)
+	assert.Equal(t, "30m", got.KeepAlive)
+}
+
+func TestOllamaStreamWithHistory(t *testing.T) {
+	var got ollamaChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/chat", r.URL.Path)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"Hel"},"done":false}`)
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"lo"},"done":false}`)
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":""},"done":true}`)
+	}))
+	t.Cleanup(server.Close)
+
+	provider, err := NewOllamaProvider(server.URL, "llama3.2")
+	require.NoError(t, err)
+
+	messages := []Message{{Role: "user", Content: "hi"}, {Role: "assistant", Content: "hey"}, {Role: "user", Content: "again"}}
+	ch, err := provider.StreamWithHistory(context.Background(), messages, GenerateOptions{SystemPrompt: "be brief"})
+	require.NoError(t, err)
+
+	var content string
+	done := false
+	for resp := range ch {
+		require.NoError(t, resp.Error)
+		content += resp.Content
+		done = done || resp.Done
+	}
+	assert.Equal(t, "Hello", content)
+	assert.True(t, done)
+
+	assert.True(t, got.Stream)
+	require.Len(t, got.Messages, 4)
+	assert.Equal(t, "system", got.Messages[0].Role)
+	assert.Contains(t, got.Messages[0].Content, "be brief")
+	assert.Equal(t, ollamaMessage{Role: "user", Content: "again"}, got.Messages[3])
+}
@@ -0,0 +1,66 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOllamaChatRequestFormat(t *testing.T) {
+	provider, err := NewOllamaProvider("", "")
+	assert.NoError(t, err)
+	messages := []Message{{Role: "user", Content: "Hi"}}
+
+	assert.Nil(t, provider.chatRequest(messages, GenerateOptions{}, false).Format)
+
+	req := provider.chatRequest(messages, GenerateOptions{ResponseFormat: &ResponseFormat{Type: FormatJSONObject}}, false)
+	assert.Equal(t, "json", req.Format)
+
+	schema := map[string]any{"type": "object", "required": []string{"answer"}}
+	req = provider.chatRequest(messages, GenerateOptions{ResponseFormat: JSONSchema("answer", schema)}, false)
+	assert.Equal(t, schema, req.Format)
+}
+
+func TestOllamaChatRequestImages(t *testing.T) {
+	provider, err := NewOllamaProvider("", "")
+	assert.NoError(t, err)
+	messages := []Message{{Role: "user", Content: "What is this?", Images: []Image{{MediaType: "image/png", Data: []byte("png")}}}}
+
+	req := provider.chatRequest(messages, GenerateOptions{}, false)
+	assert.Equal(t, ollamaMessage{Role: "user", Content: "What is this?", Images: [][]byte{[]byte("png")}}, req.Messages[len(req.Messages)-1])
+}
+
+func TestOllamaMaxTokens(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ollamaChatRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		if !req.Stream {
+			fmt.Fprint(w, `{"message":{"role":"assistant","content":"func main() {"},"done":true,"done_reason":"length"}`)
+			return
+		}
+		fmt.Fprint(w, `{"message":{"role":"assistant","content":"func main() {"},"done":false}`+"\n")
+		fmt.Fprint(w, `{"message":{"role":"assistant","content":""},"done":true,"done_reason":"length"}`+"\n")
+	}))
+	t.Cleanup(server.Close)
+	provider, err := NewOllamaProvider(server.URL, "llama3.2")
+	require.NoError(t, err)
+
+	resp, err := provider.Generate(context.Background(), "Write a server")
+	assert.ErrorIs(t, err, ErrMaxTokens)
+	assert.Equal(t, "func main() {", resp)
+
+	ch, err := provider.Stream(context.Background(), "Write a server")
+	require.NoError(t, err)
+	var last StreamResponse
+	for resp := range ch {
+		last = resp
+	}
+	assert.True(t, last.Done)
+	assert.ErrorIs(t, last.Error, ErrMaxTokens)
+}
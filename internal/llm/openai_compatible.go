@@ -0,0 +1,362 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mizzy/rigel/internal/config"
+)
+
+// OpenAICompatibleProvider talks to servers that implement OpenAI's chat
+// completions API, such as LM Studio, vLLM and llama.cpp's server
+type OpenAICompatibleProvider struct {
+	baseURL string
+	apiKey  string
+	model   Model
+	client  *http.Client
+}
+
+// NewOpenAICompatibleProvider creates a provider for the server at baseURL,
+// which includes the API version, e.g. http://localhost:1234/v1. The API key
+// is optional. If model is empty, the first model the server lists is used.
+func NewOpenAICompatibleProvider(baseURL, apiKey, model string) (*OpenAICompatibleProvider, error) {
+	if baseURL == "" {
+		return nil, errors.New("a base URL is required for an OpenAI-compatible provider")
+	}
+
+	return &OpenAICompatibleProvider{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   Model{Name: model},
+		client:  newHTTPClient(config.DefaultConnectTimeout),
+	}, nil
+}
+
+// SetConnectTimeout sets how long connecting to the server may take; 0
+// removes the limit
+func (p *OpenAICompatibleProvider) SetConnectTimeout(timeout time.Duration) {
+	p.client = newHTTPClient(timeout)
+}
+
+type openAIChatRequest struct {
+	Model       string          `json:"model"`
+	Messages    []openAIMessage `json:"messages"`
+	Stream      bool            `json:"stream"`
+	Temperature *float32        `json:"temperature,omitempty"` // Nil for the server's default
+	MaxTokens   int             `json:"max_tokens,omitempty"`
+	TopP        float32         `json:"top_p,omitempty"`
+	Seed        int             `json:"seed,omitempty"`
+
+	ResponseFormat *openAIResponseFormat `json:"response_format,omitempty"`
+}
+
+type openAIResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *openAIJSONSchema `json:"json_schema,omitempty"`
+}
+
+type openAIJSONSchema struct {
+	Name   string         `json:"name"`
+	Schema map[string]any `json:"schema"`
+}
+
+type openAIMessage struct {
+	Role    string  `json:"role"`
+	Content string  `json:"content"`
+	Images  []Image `json:"-"` // Sent as content parts after the text
+}
+
+type openAIContentPart struct {
+	Type     string          `json:"type"` // "text" or "image_url"
+	Text     string          `json:"text,omitempty"`
+	ImageURL *openAIImageURL `json:"image_url,omitempty"`
+}
+
+type openAIImageURL struct {
+	URL string `json:"url"`
+}
+
+// MarshalJSON gives the content of a message with images as parts, the
+// text followed by the images as data: URLs
+func (m openAIMessage) MarshalJSON() ([]byte, error) {
+	type message openAIMessage
+	if len(m.Images) == 0 {
+		return json.Marshal(message(m))
+	}
+	parts := []openAIContentPart{{Type: "text", Text: m.Content}}
+	for _, image := range m.Images {
+		parts = append(parts, openAIContentPart{Type: "image_url", ImageURL: &openAIImageURL{URL: image.DataURL()}})
+	}
+	return json.Marshal(struct {
+		Role    string              `json:"role"`
+		Content []openAIContentPart `json:"content"`
+	}{m.Role, parts})
+}
+
+type openAIChatResponse struct {
+	Choices []openAIChoice `json:"choices"`
+}
+
+type openAIChoice struct {
+	Message      openAIMessage `json:"message"`
+	Delta        openAIMessage `json:"delta"`
+	FinishReason *string       `json:"finish_reason"`
+}
+
+// truncated reports whether the choice was cut off at max_tokens or the
+// context size
+func (c openAIChoice) truncated() bool {
+	return c.FinishReason != nil && *c.FinishReason == "length"
+}
+
+type openAIModelsResponse struct {
+	Data []struct {
+		ID      string `json:"id"`
+		OwnedBy string `json:"owned_by"`
+	} `json:"data"`
+}
+
+func (p *OpenAICompatibleProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	return p.GenerateWithOptions(ctx, prompt, GenerateOptions{})
+}
+
+func (p *OpenAICompatibleProvider) GenerateWithOptions(ctx context.Context, prompt string, opts GenerateOptions) (string, error) {
+	return p.GenerateWithHistory(ctx, []Message{{Role: "user", Content: prompt}}, opts)
+}
+
+func (p *OpenAICompatibleProvider) GenerateWithHistory(ctx context.Context, messages []Message, opts GenerateOptions) (string, error) {
+	reqBody, err := p.chatRequest(ctx, messages, opts, false)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := p.post(ctx, "/chat/completions", reqBody)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var chatResp openAIChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	if len(chatResp.Choices) == 0 {
+		return "", errors.New("response contained no choices")
+	}
+	if choice := chatResp.Choices[0]; choice.truncated() {
+		return choice.Message.Content, ErrMaxTokens
+	}
+	return chatResp.Choices[0].Message.Content, nil
+}
+
+// chatRequest builds a /chat/completions request with the system prompt
+// followed by the conversation
+func (p *OpenAICompatibleProvider) chatRequest(ctx context.Context, messages []Message, opts GenerateOptions, stream bool) (openAIChatRequest, error) {
+	model := opts.Model
+	if model == "" {
+		if err := p.resolveModel(ctx); err != nil {
+			return openAIChatRequest{}, err
+		}
+		model = p.model.Name
+	}
+
+	chatMessages := make([]openAIMessage, 0, len(messages)+1)
+	if opts.SystemPrompt != "" {
+		chatMessages = append(chatMessages, openAIMessage{Role: "system", Content: opts.SystemPrompt})
+	}
+	for _, msg := range messages {
+		chatMessages = append(chatMessages, openAIMessage(msg))
+	}
+
+	req := openAIChatRequest{
+		Model:       model,
+		Messages:    chatMessages,
+		Stream:      stream,
+		Temperature: temperature(opts),
+		MaxTokens:   opts.MaxTokens,
+		TopP:        opts.TopP,
+		Seed:        opts.Seed,
+	}
+	if format := opts.ResponseFormat; format != nil {
+		req.ResponseFormat = &openAIResponseFormat{Type: FormatJSONObject}
+		if format.Type == FormatJSONSchema {
+			req.ResponseFormat = &openAIResponseFormat{
+				Type:       FormatJSONSchema,
+				JSONSchema: &openAIJSONSchema{Name: format.name(), Schema: format.schema()},
+			}
+		}
+	}
+	return req, nil
+}
+
+// resolveModel picks the first model the server lists when none was
+// configured; llama.cpp's server, for one, only ever serves a single model
+func (p *OpenAICompatibleProvider) resolveModel(ctx context.Context) error {
+	if p.model.Name != "" {
+		return nil
+	}
+	models, err := p.ListModels(ctx)
+	if err != nil {
+		return err
+	}
+	if len(models) == 0 {
+		return errors.New("the server lists no models; set MODEL")
+	}
+	p.model = models[0]
+	return nil
+}
+
+func (p *OpenAICompatibleProvider) Stream(ctx context.Context, prompt string) (<-chan StreamResponse, error) {
+	return p.StreamWithHistory(ctx, []Message{{Role: "user", Content: prompt}}, GenerateOptions{})
+}
+
+func (p *OpenAICompatibleProvider) StreamWithHistory(ctx context.Context, messages []Message, opts GenerateOptions) (<-chan StreamResponse, error) {
+	reqBody, err := p.chatRequest(ctx, messages, opts, true)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan StreamResponse)
+
+	go func() {
+		defer close(ch)
+
+		resp, err := p.post(ctx, "/chat/completions", reqBody)
+		if err != nil {
+			ch <- StreamResponse{Error: err, Done: true}
+			return
+		}
+		defer resp.Body.Close()
+
+		// Server-sent events: "data: {...}" lines ending with "data: [DONE]"
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		truncated := false
+		for scanner.Scan() {
+			data, ok := strings.CutPrefix(scanner.Text(), "data:")
+			if !ok {
+				continue
+			}
+			data = strings.TrimSpace(data)
+			if data == "[DONE]" {
+				break
+			}
+
+			var chunk openAIChatResponse
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				ch <- StreamResponse{
+					Error: fmt.Errorf("failed to decode stream response: %w", err),
+					Done:  true,
+				}
+				return
+			}
+			for _, choice := range chunk.Choices {
+				if choice.Delta.Content != "" {
+					ch <- StreamResponse{Content: choice.Delta.Content}
+				}
+				truncated = truncated || choice.truncated()
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			ch <- StreamResponse{
+				Error: classifyError(p.GetName(), fmt.Errorf("failed to read stream: %w", err)),
+				Done:  true,
+			}
+			return
+		}
+		if truncated {
+			ch <- StreamResponse{Error: ErrMaxTokens, Done: true}
+			return
+		}
+		ch <- StreamResponse{Done: true}
+	}()
+
+	return ch, nil
+}
+
+func (p *OpenAICompatibleProvider) ListModels(ctx context.Context) ([]Model, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", p.baseURL+"/models", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := p.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var listResp openAIModelsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&listResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	models := make([]Model, len(listResp.Data))
+	for i, m := range listResp.Data {
+		models[i] = Model{Name: m.ID}
+	}
+	return models, nil
+}
+
+// Ping checks that the server is reachable and accepts the API key
+func (p *OpenAICompatibleProvider) Ping(ctx context.Context) error {
+	_, err := p.ListModels(ctx)
+	return err
+}
+
+func (p *OpenAICompatibleProvider) GetCurrentModel() Model {
+	return p.model
+}
+
+func (p *OpenAICompatibleProvider) GetName() string {
+	return "openai-compatible"
+}
+
+func (p *OpenAICompatibleProvider) SetModel(model Model) {
+	p.model = model
+}
+
+func (p *OpenAICompatibleProvider) post(ctx context.Context, path string, body interface{}) (*http.Response, error) {
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+path, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return p.do(req)
+}
+
+// do sends a request with the API key, if any, and turns failures and
+// non-200 responses into classified errors
+func (p *OpenAICompatibleProvider) do(req *http.Request) (*http.Response, error) {
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, classifyError(p.GetName(), fmt.Errorf("failed to send request: %w", err))
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &Error{
+			Kind:       statusKind(resp.StatusCode, string(body)),
+			Provider:   p.GetName(),
+			StatusCode: resp.StatusCode,
+			Err:        fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body)),
+		}
+	}
+	return resp, nil
+}
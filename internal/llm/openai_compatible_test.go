@@ -0,0 +1,189 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newOpenAICompatibleTestServer(t *testing.T, apiKey, model string) (*OpenAICompatibleProvider, *openAIChatRequest) {
+	t.Helper()
+	var last openAIChatRequest
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/models", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"object":"list","data":[{"id":"qwen2.5-coder-7b","owned_by":"lmstudio"},{"id":"llama-3.2-3b","owned_by":"lmstudio"}]}`)
+	})
+	mux.HandleFunc("POST /v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		if apiKey != "" && r.Header.Get("Authorization") != "Bearer "+apiKey {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":{"message":"invalid api key"}}`)
+			return
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&last))
+		if !last.Stream {
+			fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":"Hello from %s"},"finish_reason":"stop"}]}`, last.Model)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"role\":\"assistant\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Hel\"}}]}\n\n")
+		fmt.Fprint(w, ": keep-alive\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"lo\"},\"finish_reason\":\"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	provider, err := NewOpenAICompatibleProvider(server.URL+"/v1/", apiKey, model)
+	require.NoError(t, err)
+	return provider, &last
+}
+
+func TestOpenAICompatibleGenerate(t *testing.T) {
+	provider, last := newOpenAICompatibleTestServer(t, "secret", "llama-3.2-3b")
+
+	resp, err := provider.GenerateWithHistory(context.Background(),
+		[]Message{{Role: "user", Content: "Hi"}},
+		GenerateOptions{SystemPrompt: "Be brief.", Temperature: 0.2, MaxTokens: 100})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello from llama-3.2-3b", resp)
+
+	assert.Equal(t, "llama-3.2-3b", last.Model)
+	require.NotNil(t, last.Temperature)
+	assert.Equal(t, float32(0.2), *last.Temperature)
+	assert.Equal(t, 100, last.MaxTokens)
+	require.Len(t, last.Messages, 2)
+	assert.Equal(t, "system", last.Messages[0].Role)
+	assert.Contains(t, last.Messages[0].Content, "Be brief.")
+	assert.Equal(t, openAIMessage{Role: "user", Content: "Hi"}, last.Messages[1])
+}
+
+func TestOpenAICompatibleDeterministic(t *testing.T) {
+	provider, last := newOpenAICompatibleTestServer(t, "", "llama-3.2-3b")
+
+	_, err := provider.GenerateWithOptions(context.Background(), "Hi",
+		GenerateOptions{Temperature: 0.7, Seed: DeterministicSeed, Deterministic: true})
+	require.NoError(t, err)
+	require.NotNil(t, last.Temperature, "a temperature of 0 is sent")
+	assert.Zero(t, *last.Temperature)
+	assert.Equal(t, DeterministicSeed, last.Seed)
+}
+
+func TestOpenAICompatibleResponseFormat(t *testing.T) {
+	provider, last := newOpenAICompatibleTestServer(t, "", "llama-3.2-3b")
+
+	_, err := provider.GenerateWithOptions(context.Background(), "Hi",
+		GenerateOptions{ResponseFormat: &ResponseFormat{Type: FormatJSONObject}})
+	require.NoError(t, err)
+	assert.Equal(t, &openAIResponseFormat{Type: "json_object"}, last.ResponseFormat)
+
+	schema := map[string]any{"type": "object"}
+	_, err = provider.GenerateWithOptions(context.Background(), "Hi",
+		GenerateOptions{ResponseFormat: JSONSchema("greeting", schema)})
+	require.NoError(t, err)
+	assert.Equal(t, &openAIResponseFormat{
+		Type:       "json_schema",
+		JSONSchema: &openAIJSONSchema{Name: "greeting", Schema: schema},
+	}, last.ResponseFormat)
+
+	*last = openAIChatRequest{}
+	_, err = provider.Generate(context.Background(), "Hi")
+	require.NoError(t, err)
+	assert.Nil(t, last.ResponseFormat)
+}
+
+func TestOpenAICompatibleDefaultModel(t *testing.T) {
+	provider, _ := newOpenAICompatibleTestServer(t, "", "")
+
+	resp, err := provider.Generate(context.Background(), "Hi")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello from qwen2.5-coder-7b", resp)
+	assert.Equal(t, "qwen2.5-coder-7b", provider.GetCurrentModel().Name)
+}
+
+func TestOpenAICompatibleStream(t *testing.T) {
+	provider, last := newOpenAICompatibleTestServer(t, "", "llama-3.2-3b")
+
+	ch, err := provider.Stream(context.Background(), "Hi")
+	require.NoError(t, err)
+
+	var content string
+	var done bool
+	for resp := range ch {
+		require.NoError(t, resp.Error)
+		content += resp.Content
+		done = resp.Done
+	}
+	assert.Equal(t, "Hello", content)
+	assert.True(t, done)
+	assert.True(t, last.Stream)
+}
+
+func TestOpenAICompatibleErrors(t *testing.T) {
+	provider, _ := newOpenAICompatibleTestServer(t, "secret", "llama-3.2-3b")
+	provider.apiKey = "wrong"
+
+	_, err := provider.Generate(context.Background(), "Hi")
+	var llmErr *Error
+	require.ErrorAs(t, err, &llmErr)
+	assert.Equal(t, "openai-compatible", llmErr.Provider)
+	assert.Equal(t, http.StatusUnauthorized, llmErr.StatusCode)
+
+	_, err = NewOpenAICompatibleProvider("", "", "")
+	assert.Error(t, err)
+}
+
+func TestOpenAICompatibleListModels(t *testing.T) {
+	provider, _ := newOpenAICompatibleTestServer(t, "", "")
+
+	models, err := provider.ListModels(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []Model{{Name: "qwen2.5-coder-7b"}, {Name: "llama-3.2-3b"}}, models)
+	assert.NoError(t, provider.Ping(context.Background()))
+}
+
+func TestOpenAICompatibleImages(t *testing.T) {
+	data, err := json.Marshal(openAIMessage{Role: "user", Content: "What is this?", Images: []Image{{MediaType: "image/png", Data: []byte("png")}}})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"role":"user","content":[{"type":"text","text":"What is this?"},{"type":"image_url","image_url":{"url":"data:image/png;base64,cG5n"}}]}`, string(data))
+
+	data, err = json.Marshal(openAIMessage{Role: "user", Content: "Hi"})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"role":"user","content":"Hi"}`, string(data))
+}
+
+func TestOpenAICompatibleMaxTokens(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openAIChatRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		if !req.Stream {
+			fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"func main() {"},"finish_reason":"length"}]}`)
+			return
+		}
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"func main() {\"},\"finish_reason\":\"length\"}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(server.Close)
+	provider, err := NewOpenAICompatibleProvider(server.URL, "", "qwen2.5-coder-7b")
+	require.NoError(t, err)
+
+	resp, err := provider.Generate(context.Background(), "Write a server")
+	assert.ErrorIs(t, err, ErrMaxTokens)
+	assert.Equal(t, "func main() {", resp)
+
+	ch, err := provider.Stream(context.Background(), "Write a server")
+	require.NoError(t, err)
+	var last StreamResponse
+	for resp := range ch {
+		last = resp
+	}
+	assert.True(t, last.Done)
+	assert.ErrorIs(t, last.Error, ErrMaxTokens)
+}
@@ -0,0 +1,79 @@
+package llm
+
+import (
+	"sync"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// Anthropic bills input tokens read from the prompt cache at a tenth of the
+// normal price and tokens written to it at a quarter more
+const (
+	cacheReadPriceFactor  = 0.1
+	cacheWritePriceFactor = 1.25
+)
+
+// ephemeralCache marks the end of a prompt prefix Anthropic should cache
+var ephemeralCache = anthropic.F(anthropic.CacheControlEphemeralParam{
+	Type: anthropic.F(anthropic.CacheControlEphemeralTypeEphemeral),
+})
+
+// PromptCacheStats counts the input tokens of Anthropic requests by whether
+// they were read from the prompt cache, written to it or neither
+type PromptCacheStats struct {
+	Requests     int
+	InputTokens  int64 // Neither read from nor written to the cache
+	ReadTokens   int64
+	WriteTokens  int64
+	OutputTokens int64
+
+	// Estimated US dollars saved compared to sending every request uncached;
+	// negative while cache writes cost more than reads have saved
+	Savings float64
+}
+
+// HitRate returns the share of input tokens read from the cache
+func (s PromptCacheStats) HitRate() float64 {
+	total := s.InputTokens + s.ReadTokens + s.WriteTokens
+	if total == 0 {
+		return 0
+	}
+	return float64(s.ReadTokens) / float64(total)
+}
+
+// promptCacheStats accumulates PromptCacheStats across requests
+type promptCacheStats struct {
+	mu    sync.Mutex
+	stats PromptCacheStats
+}
+
+// record adds the token usage a request reported
+func (c *promptCacheStats) record(model string, usage anthropic.Usage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.Requests++
+	c.stats.InputTokens += usage.InputTokens
+	c.stats.ReadTokens += usage.CacheReadInputTokens
+	c.stats.WriteTokens += usage.CacheCreationInputTokens
+	c.stats.OutputTokens += usage.OutputTokens
+	if price, ok := ModelPrice("anthropic", model); ok {
+		saved := float64(usage.CacheReadInputTokens)*(1-cacheReadPriceFactor) -
+			float64(usage.CacheCreationInputTokens)*(cacheWritePriceFactor-1)
+		c.stats.Savings += saved * price.Input / 1e6
+	}
+}
+
+func (c *promptCacheStats) get() PromptCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// FindPromptCacheStats returns the prompt cache statistics of the Anthropic
+// provider in a provider chain, if any
+func FindPromptCacheStats(p Provider) (PromptCacheStats, bool) {
+	if a, ok := As[*AnthropicProvider](p); ok {
+		return a.PromptCacheStats(), true
+	}
+	return PromptCacheStats{}, false
+}
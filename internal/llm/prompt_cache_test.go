@@ -0,0 +1,48 @@
+package llm
+
+import (
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageParamsMarksPromptCache(t *testing.T) {
+	provider, err := NewAnthropicProvider("test-api-key", "claude-sonnet-4-20250514")
+	require.NoError(t, err)
+
+	params := provider.messageParams([]Message{
+		{Role: "user", Content: "first"},
+		{Role: "assistant", Content: "answer"},
+		{Role: "user", Content: "second"},
+	}, GenerateOptions{SystemPrompt: "You are a coding agent."})
+
+	system := params.System.Value
+	require.Len(t, system, 1)
+	assert.True(t, system[0].CacheControl.Present, "system prompt is cached")
+
+	messages := params.Messages.Value
+	require.Len(t, messages, 3)
+	for i, msg := range messages {
+		block, ok := msg.Content.Value[0].(anthropic.TextBlockParam)
+		require.True(t, ok)
+		assert.Equal(t, i == 2, block.CacheControl.Present, "only the latest message ends the cached prefix")
+	}
+}
+
+func TestPromptCacheStats(t *testing.T) {
+	var stats promptCacheStats
+	stats.record("claude-sonnet-4-20250514", anthropic.Usage{InputTokens: 50, CacheCreationInputTokens: 10000, OutputTokens: 200})
+	stats.record("claude-sonnet-4-20250514", anthropic.Usage{InputTokens: 50, CacheReadInputTokens: 10000, OutputTokens: 100})
+
+	got := stats.get()
+	assert.Equal(t, 2, got.Requests)
+	assert.Equal(t, int64(100), got.InputTokens)
+	assert.Equal(t, int64(10000), got.ReadTokens)
+	assert.Equal(t, int64(10000), got.WriteTokens)
+	assert.Equal(t, int64(300), got.OutputTokens)
+	assert.InDelta(t, 10000.0/20100, got.HitRate(), 1e-9)
+	// 10k tokens read at $3/M save $0.027; writing them cost $0.0075 extra
+	assert.InDelta(t, 0.0195, got.Savings, 1e-9)
+}
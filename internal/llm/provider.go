@@ -3,6 +3,8 @@ package llm
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"strings"
 
 	"github.com/mizzy/rigel/internal/config"
 )
@@ -12,6 +14,7 @@ type Provider interface {
 	GenerateWithOptions(ctx context.Context, prompt string, opts GenerateOptions) (string, error)
 	GenerateWithHistory(ctx context.Context, messages []Message, opts GenerateOptions) (string, error)
 	Stream(ctx context.Context, prompt string) (<-chan StreamResponse, error)
+	StreamWithHistory(ctx context.Context, messages []Message, opts GenerateOptions) (<-chan StreamResponse, error)
 	ListModels(ctx context.Context) ([]Model, error)
 	GetCurrentModel() Model
 	SetModel(model Model)
@@ -19,8 +22,9 @@ type Provider interface {
 }
 
 type Message struct {
-	Role    string `json:"role"` // "user" or "assistant"
-	Content string `json:"content"`
+	Role    string  `json:"role"` // "user" or "assistant"
+	Content string  `json:"content"`
+	Images  []Image `json:"images,omitempty"` // Attached to a user message
 }
 
 type GenerateOptions struct {
@@ -28,6 +32,21 @@ type GenerateOptions struct {
 	MaxTokens    int
 	SystemPrompt string
 	Model        string
+
+	// Sampling and runtime options; zero values use the provider's defaults.
+	// Currently honored by Ollama.
+	NumCtx    int     // Context window size in tokens
+	TopP      float32 // Nucleus sampling
+	TopK      int     // Top-k sampling
+	Seed      int     // Fixed seed for reproducible output
+	KeepAlive string  // How long the model stays loaded, e.g. "10m" or "-1"
+
+	// JSON the response must be; nil for free text
+	ResponseFormat *ResponseFormat
+
+	// Sample greedily, at temperature 0 whatever Temperature says, for
+	// responses as reproducible as the model allows
+	Deterministic bool
 }
 
 type StreamResponse struct {
@@ -50,6 +69,38 @@ type ModelDetails struct {
 	Families          []string `json:"families"`
 	ParameterSize     string   `json:"parameter_size"`
 	QuantizationLevel string   `json:"quantization_level"`
+
+	// Capabilities filled in by providers where known; zero values mean
+	// unknown rather than unsupported
+	ContextWindow  int     `json:"context_window,omitempty"` // Tokens
+	SupportsTools  bool    `json:"supports_tools,omitempty"`
+	SupportsVision bool    `json:"supports_vision,omitempty"`
+	InputCost      float64 `json:"input_cost,omitempty"`  // US dollars per million tokens
+	OutputCost     float64 `json:"output_cost,omitempty"` // US dollars per million tokens
+}
+
+// Pinger is a provider that can check that its service is reachable and
+// accepts the configured credentials
+type Pinger interface {
+	Provider
+	Ping(ctx context.Context) error
+}
+
+// As returns the first provider of type T in a chain of wrapping providers
+// (such as TracingProvider and CachingProvider)
+func As[T Provider](p Provider) (T, bool) {
+	for p != nil {
+		if t, ok := p.(T); ok {
+			return t, true
+		}
+		u, ok := p.(interface{ Unwrap() Provider })
+		if !ok {
+			break
+		}
+		p = u.Unwrap()
+	}
+	var zero T
+	return zero, false
 }
 
 func NewProvider(cfg *config.Config) (Provider, error) {
@@ -57,17 +108,127 @@ func NewProvider(cfg *config.Config) (Provider, error) {
 		return nil, fmt.Errorf("config is nil")
 	}
 
+	provider, err := newProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+	provider = limitRate(provider, cfg)
+
+	if len(cfg.FallbackProviders) > 0 {
+		provider = newFailoverProvider(provider, cfg)
+	}
+
+	// Continuations are requests of their own, limited and failed over
+	provider = NewContinuingProvider(provider, cfg.MaxContinuations)
+
+	// Middleware goes below the cache, which keys responses by AGENTS.md
+	// and the notes rather than by the prompts middleware changes
+	var middleware []Middleware
+	for _, name := range cfg.Middleware {
+		mw, err := NewMiddleware(name)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.ReadOnly && name == config.MiddlewareAgentsMD {
+			mw = GlobalAgentsMDMiddleware()
+		}
+		middleware = append(middleware, mw)
+	}
+	if len(middleware) > 0 {
+		provider = NewMiddlewareProvider(provider, middleware...)
+	}
+
+	// Cached responses cost nothing, so metering goes below the cache
+	provider = NewMeteredProvider(provider)
+
+	if cfg.CacheEnabled {
+		dir, err := DefaultCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		cache, err := NewResponseCache(dir, cfg.CacheTTL)
+		if err != nil {
+			return nil, err
+		}
+		provider = NewCachingProvider(provider, cache)
+	}
+
+	// Above the cache so that deterministic requests are keyed as such
+	provider = NewDeterministicProvider(provider, cfg.Deterministic, cfg.CacheTTL)
+
+	// Redacting last keeps secrets out of the trace log and the cache too
+	provider = NewTracingProvider(provider)
+	if cfg.Redact {
+		provider = NewRedactingProvider(provider)
+	}
+	return provider, nil
+}
+
+// newFailoverProvider adds the configured fallback providers, given as
+// "provider" or "provider/model", behind primary. Fallbacks that can't be
+// created, e.g. for lack of an API key, are skipped.
+func newFailoverProvider(primary Provider, cfg *config.Config) Provider {
+	var fallbacks []Provider
+	for _, name := range cfg.FallbackProviders {
+		fallbackCfg := *cfg
+		fallbackCfg.Provider, fallbackCfg.Model, _ = strings.Cut(name, "/")
+		if fallbackCfg.Model == "" {
+			fallbackCfg.Model = cfg.ModelFor(fallbackCfg.Provider)
+		}
+		fallback, err := newProvider(&fallbackCfg)
+		if err != nil {
+			slog.Warn("skipping fallback provider", "provider", name, "error", err)
+			continue
+		}
+		fallbacks = append(fallbacks, limitRate(fallback, cfg))
+	}
+	if len(fallbacks) == 0 {
+		return primary
+	}
+	return NewFailoverProvider(primary, fallbacks...)
+}
+
+func newProvider(cfg *config.Config) (Provider, error) {
 	switch cfg.Provider {
 	case "anthropic":
-		return NewAnthropicProvider(cfg.AnthropicAPIKey, cfg.Model)
+		return newAnthropicProvider(cfg)
 	case "openai":
 		return nil, fmt.Errorf("OpenAI provider not yet implemented")
 	case "ollama":
-		return NewOllamaProvider(cfg.OllamaBaseURL, cfg.Model)
+		provider, err := NewOllamaProvider(cfg.OllamaBaseURL, cfg.Model)
+		if err != nil {
+			return nil, err
+		}
+		provider.SetConnectTimeout(cfg.ConnectTimeout)
+		provider.SetDefaults(GenerateOptions{
+			NumCtx:    cfg.OllamaNumCtx,
+			TopP:      float32(cfg.OllamaTopP),
+			TopK:      cfg.OllamaTopK,
+			Seed:      cfg.OllamaSeed,
+			KeepAlive: cfg.OllamaKeepAlive,
+		})
+		return provider, nil
+	case "openai-compatible":
+		provider, err := NewOpenAICompatibleProvider(cfg.OpenAICompatibleBaseURL, cfg.OpenAICompatibleAPIKey, cfg.Model)
+		if err != nil {
+			return nil, err
+		}
+		provider.SetConnectTimeout(cfg.ConnectTimeout)
+		return provider, nil
 	default:
 		if cfg.AnthropicAPIKey != "" {
-			return NewAnthropicProvider(cfg.AnthropicAPIKey, cfg.Model)
+			return newAnthropicProvider(cfg)
 		}
 		return nil, fmt.Errorf("no valid LLM provider configured")
 	}
 }
+
+// newAnthropicProvider creates the Anthropic provider cfg configures
+func newAnthropicProvider(cfg *config.Config) (Provider, error) {
+	provider, err := NewAnthropicProvider(cfg.AnthropicAPIKey, cfg.Model)
+	if err != nil {
+		return nil, err
+	}
+	provider.SetConnectTimeout(cfg.ConnectTimeout)
+	return provider, nil
+}
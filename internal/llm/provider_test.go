@@ -35,6 +35,14 @@ func (m *MockProvider) Stream(ctx context.Context, prompt string) (<-chan Stream
 	return nil, args.Error(1)
 }
 
+func (m *MockProvider) StreamWithHistory(ctx context.Context, messages []Message, opts GenerateOptions) (<-chan StreamResponse, error) {
+	args := m.Called(ctx, messages, opts)
+	if ch, ok := args.Get(0).(<-chan StreamResponse); ok {
+		return ch, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
 func (m *MockProvider) ListModels(ctx context.Context) ([]Model, error) {
 	args := m.Called(ctx)
 	return args.Get(0).([]Model), args.Error(1)
@@ -0,0 +1,212 @@
+package llm
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mizzy/rigel/internal/config"
+)
+
+// rateWindow is the period rate limits apply to
+const rateWindow = time.Minute
+
+// RateLimitedProvider keeps the requests to a provider within its rate
+// limits: a request that would exceed them waits, in turn with the others
+// waiting, until enough earlier requests are over a minute old. Tokens are
+// estimated like MeteredProvider's, the response's counted once received.
+type RateLimitedProvider struct {
+	Provider
+	limit  config.RateLimit
+	window time.Duration
+
+	turn chan struct{} // Held by the request first in line
+
+	mu    sync.Mutex
+	sent  []*sentRequest // Within the window, the oldest first
+	until time.Time      // When the request first in line may be sent
+}
+
+// sentRequest is a request counted against the rate limits
+type sentRequest struct {
+	at     time.Time
+	tokens int
+}
+
+// NewRateLimitedProvider wraps a provider with rate limits
+func NewRateLimitedProvider(p Provider, limit config.RateLimit) *RateLimitedProvider {
+	return &RateLimitedProvider{
+		Provider: p,
+		limit:    limit,
+		window:   rateWindow,
+		turn:     make(chan struct{}, 1),
+	}
+}
+
+// limitRate adds the rate limits configured for a provider, if any
+func limitRate(p Provider, cfg *config.Config) Provider {
+	limit, ok := cfg.RateLimits[p.GetName()]
+	if !ok {
+		return p
+	}
+	return NewRateLimitedProvider(p, limit)
+}
+
+// Unwrap returns the underlying provider
+func (r *RateLimitedProvider) Unwrap() Provider {
+	return r.Provider
+}
+
+// Waiting returns how long the request first in line still waits for the
+// rate limits, or 0 if none waits
+func (r *RateLimitedProvider) Waiting() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return max(time.Until(r.until), 0)
+}
+
+// wait blocks until a request of the given tokens fits in the rate limits
+// and counts it against them
+func (r *RateLimitedProvider) wait(ctx context.Context, tokens int) (*sentRequest, error) {
+	select {
+	case r.turn <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-r.turn }()
+
+	for {
+		r.mu.Lock()
+		now := time.Now()
+		delay := r.delay(now, tokens)
+		if delay <= 0 {
+			req := &sentRequest{at: now, tokens: tokens}
+			r.sent = append(r.sent, req)
+			r.until = time.Time{}
+			r.mu.Unlock()
+			return req, nil
+		}
+		r.until = now.Add(delay)
+		r.mu.Unlock()
+
+		slog.InfoContext(ctx, "waiting for rate limit", "provider", r.GetName(), "delay", delay.Round(time.Second))
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			r.mu.Lock()
+			r.until = time.Time{}
+			r.mu.Unlock()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// delay returns how long a request of the given tokens must wait at now,
+// dropping the requests that left the window. A request over the tokens
+// per minute by itself is sent once the window is empty.
+func (r *RateLimitedProvider) delay(now time.Time, tokens int) time.Duration {
+	for len(r.sent) > 0 && now.Sub(r.sent[0].at) >= r.window {
+		r.sent = r.sent[1:]
+	}
+	// leaves returns when the i-th request leaves the window
+	leaves := func(i int) time.Duration {
+		return r.sent[i].at.Add(r.window).Sub(now)
+	}
+
+	var delay time.Duration
+	if rpm := r.limit.RequestsPerMinute; rpm > 0 && len(r.sent) >= rpm {
+		delay = leaves(len(r.sent) - rpm)
+	}
+	if tpm := r.limit.TokensPerMinute; tpm > 0 {
+		used := 0
+		for _, req := range r.sent {
+			used += req.tokens
+		}
+		for i := 0; i < len(r.sent) && used+tokens > tpm; i++ {
+			used -= r.sent[i].tokens
+			delay = max(delay, leaves(i))
+		}
+	}
+	return delay
+}
+
+// received counts the tokens of a response against the rate limits
+func (r *RateLimitedProvider) received(req *sentRequest, response string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	req.tokens += len(response) / charsPerToken
+}
+
+func (r *RateLimitedProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	req, err := r.wait(ctx, len(prompt)/charsPerToken)
+	if err != nil {
+		return "", err
+	}
+	resp, err := r.Provider.Generate(ctx, prompt)
+	r.received(req, resp)
+	return resp, err
+}
+
+func (r *RateLimitedProvider) GenerateWithOptions(ctx context.Context, prompt string, opts GenerateOptions) (string, error) {
+	req, err := r.wait(ctx, len(opts.SystemPrompt+prompt)/charsPerToken)
+	if err != nil {
+		return "", err
+	}
+	resp, err := r.Provider.GenerateWithOptions(ctx, prompt, opts)
+	r.received(req, resp)
+	return resp, err
+}
+
+func (r *RateLimitedProvider) GenerateWithHistory(ctx context.Context, messages []Message, opts GenerateOptions) (string, error) {
+	req, err := r.wait(ctx, len(historyText(messages, opts))/charsPerToken)
+	if err != nil {
+		return "", err
+	}
+	resp, err := r.Provider.GenerateWithHistory(ctx, messages, opts)
+	r.received(req, resp)
+	return resp, err
+}
+
+func (r *RateLimitedProvider) Stream(ctx context.Context, prompt string) (<-chan StreamResponse, error) {
+	req, err := r.wait(ctx, len(prompt)/charsPerToken)
+	if err != nil {
+		return nil, err
+	}
+	in, err := r.Provider.Stream(ctx, prompt)
+	return r.countStream(ctx, req, in, err)
+}
+
+func (r *RateLimitedProvider) StreamWithHistory(ctx context.Context, messages []Message, opts GenerateOptions) (<-chan StreamResponse, error) {
+	req, err := r.wait(ctx, len(historyText(messages, opts))/charsPerToken)
+	if err != nil {
+		return nil, err
+	}
+	in, err := r.Provider.StreamWithHistory(ctx, messages, opts)
+	return r.countStream(ctx, req, in, err)
+}
+
+// countStream passes a stream through, counting the response's tokens once
+// it has been received in full or in part
+func (r *RateLimitedProvider) countStream(ctx context.Context, req *sentRequest, in <-chan StreamResponse, err error) (<-chan StreamResponse, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan StreamResponse)
+	go func() {
+		defer close(out)
+		var content strings.Builder
+		defer func() { r.received(req, content.String()) }()
+		for resp := range in {
+			content.WriteString(resp.Content)
+			if !send(ctx, out, resp) {
+				return
+			}
+		}
+	}()
+	return out, nil
+}
@@ -0,0 +1,94 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mizzy/rigel/internal/config"
+)
+
+// newTestRateLimiter limits a stub provider over a short window
+func newTestRateLimiter(limit config.RateLimit) *RateLimitedProvider {
+	r := NewRateLimitedProvider(&stubProvider{name: "anthropic", resp: "ok"}, limit)
+	r.window = 200 * time.Millisecond
+	return r
+}
+
+func TestRateLimitedProvider_Requests(t *testing.T) {
+	r := newTestRateLimiter(config.RateLimit{RequestsPerMinute: 2})
+	messages := []Message{{Role: "user", Content: "hi"}}
+	ctx := context.Background()
+
+	start := time.Now()
+	for range 2 {
+		_, err := r.GenerateWithHistory(ctx, messages, GenerateOptions{})
+		require.NoError(t, err)
+	}
+	assert.Zero(t, r.Waiting())
+
+	// The third request waits for the first to leave the window
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		resp, err := r.GenerateWithHistory(ctx, messages, GenerateOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, "ok", resp)
+	}()
+	assert.Eventually(t, func() bool { return r.Waiting() > 0 }, time.Second, 5*time.Millisecond)
+	<-done
+	assert.GreaterOrEqual(t, time.Since(start), r.window)
+	assert.Zero(t, r.Waiting())
+}
+
+func TestRateLimitedProvider_Tokens(t *testing.T) {
+	r := newTestRateLimiter(config.RateLimit{TokensPerMinute: 10})
+	prompt := strings.Repeat("word", 10) // 10 tokens
+	ctx := context.Background()
+
+	// A request over the limit by itself is sent while nothing else is
+	start := time.Now()
+	_, err := r.GenerateWithHistory(ctx, []Message{{Role: "user", Content: prompt + prompt}}, GenerateOptions{})
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), r.window)
+
+	stream, err := r.StreamWithHistory(ctx, []Message{{Role: "user", Content: prompt}}, GenerateOptions{})
+	require.NoError(t, err)
+	for range stream {
+	}
+	assert.GreaterOrEqual(t, time.Since(start), r.window)
+}
+
+func TestRateLimitedProvider_Cancel(t *testing.T) {
+	r := newTestRateLimiter(config.RateLimit{RequestsPerMinute: 1})
+	r.window = time.Minute
+	messages := []Message{{Role: "user", Content: "hi"}}
+	_, err := r.GenerateWithHistory(context.Background(), messages, GenerateOptions{})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = r.GenerateWithHistory(ctx, messages, GenerateOptions{})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Zero(t, r.Waiting())
+}
+
+func TestNewProviderWithRateLimits(t *testing.T) {
+	provider, err := NewProvider(&config.Config{
+		Provider:   "ollama",
+		Model:      "llama3.2",
+		RateLimits: map[string]config.RateLimit{"ollama": {RequestsPerMinute: 30}},
+	})
+	require.NoError(t, err)
+	_, ok := As[*RateLimitedProvider](provider)
+	assert.True(t, ok)
+
+	provider, err = NewProvider(&config.Config{Provider: "ollama", Model: "llama3.2"})
+	require.NoError(t, err)
+	_, ok = As[*RateLimitedProvider](provider)
+	assert.False(t, ok)
+}
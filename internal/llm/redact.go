@@ -0,0 +1,56 @@
+package llm
+
+import (
+	"context"
+
+	"github.com/mizzy/rigel/internal/logging"
+)
+
+// RedactingProvider masks strings that look like API keys or tokens in
+// prompts before they are sent, so that secrets pasted into a conversation
+// or read from files don't reach the provider
+type RedactingProvider struct {
+	Provider
+}
+
+// NewRedactingProvider wraps a provider with redaction of requests
+func NewRedactingProvider(p Provider) *RedactingProvider {
+	return &RedactingProvider{Provider: p}
+}
+
+// Unwrap returns the underlying provider
+func (r *RedactingProvider) Unwrap() Provider {
+	return r.Provider
+}
+
+// redactMessages returns a copy of messages with secrets masked
+func redactMessages(messages []Message) []Message {
+	out := make([]Message, len(messages))
+	for i, msg := range messages {
+		out[i] = Message{Role: msg.Role, Content: logging.Redact(msg.Content), Images: msg.Images}
+	}
+	return out
+}
+
+func (r *RedactingProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	return r.Provider.Generate(ctx, logging.Redact(prompt))
+}
+
+func (r *RedactingProvider) GenerateWithOptions(ctx context.Context, prompt string, opts GenerateOptions) (string, error) {
+	opts.SystemPrompt = logging.Redact(opts.SystemPrompt)
+	return r.Provider.GenerateWithOptions(ctx, logging.Redact(prompt), opts)
+}
+
+func (r *RedactingProvider) GenerateWithHistory(ctx context.Context, messages []Message, opts GenerateOptions) (string, error) {
+	opts.SystemPrompt = logging.Redact(opts.SystemPrompt)
+	return r.Provider.GenerateWithHistory(ctx, redactMessages(messages), opts)
+}
+
+func (r *RedactingProvider) Stream(ctx context.Context, prompt string) (<-chan StreamResponse, error) {
+	return r.Provider.Stream(ctx, logging.Redact(prompt))
+}
+
+func (r *RedactingProvider) StreamWithHistory(ctx context.Context, messages []Message, opts GenerateOptions) (<-chan StreamResponse, error) {
+	opts.SystemPrompt = logging.Redact(opts.SystemPrompt)
+	return r.Provider.StreamWithHistory(ctx, redactMessages(messages), opts)
+}
@@ -0,0 +1,35 @@
+package llm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactingProvider(t *testing.T) {
+	stub := &stubProvider{name: "anthropic", resp: "ok"}
+	redacting := NewRedactingProvider(stub)
+	messages := []Message{
+		{Role: "user", Content: "my key is sk-ant-api03-abcdefghijkl"},
+		{Role: "assistant", Content: "noted"},
+	}
+
+	_, err := redacting.GenerateWithHistory(context.Background(), messages, GenerateOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []Message{
+		{Role: "user", Content: "my key is [REDACTED]"},
+		{Role: "assistant", Content: "noted"},
+	}, stub.last)
+	assert.Equal(t, "my key is sk-ant-api03-abcdefghijkl", messages[0].Content, "the caller's messages are left alone")
+
+	ch, err := redacting.StreamWithHistory(context.Background(), messages, GenerateOptions{})
+	require.NoError(t, err)
+	for range ch {
+	}
+	assert.Equal(t, "my key is [REDACTED]", stub.last[0].Content)
+
+	_, ok := As[*stubProvider](NewTracingProvider(redacting))
+	assert.True(t, ok)
+}
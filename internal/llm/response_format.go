@@ -0,0 +1,38 @@
+package llm
+
+// Kinds of structured response
+const (
+	FormatJSONObject = "json_object" // Any JSON object
+	FormatJSONSchema = "json_schema" // A JSON object following the format's schema
+)
+
+// ResponseFormat asks for a response that is JSON rather than free text,
+// which the providers enforce where their API can: Anthropic by making the
+// model call a tool taking the response as its input, OpenAI-compatible
+// servers with response_format and Ollama with format
+type ResponseFormat struct {
+	Type   string         // FormatJSONObject or FormatJSONSchema
+	Name   string         // What the response is, e.g. "operations"; "response" when empty
+	Schema map[string]any // JSON schema of the response, an object, with FormatJSONSchema
+}
+
+// JSONSchema returns a format for responses following schema
+func JSONSchema(name string, schema map[string]any) *ResponseFormat {
+	return &ResponseFormat{Type: FormatJSONSchema, Name: name, Schema: schema}
+}
+
+// name returns what the response is, for APIs that name schemas
+func (f *ResponseFormat) name() string {
+	if f.Name == "" {
+		return "response"
+	}
+	return f.Name
+}
+
+// schema returns the JSON schema of the response, any object without one
+func (f *ResponseFormat) schema() map[string]any {
+	if f.Type == FormatJSONSchema && f.Schema != nil {
+		return f.Schema
+	}
+	return map[string]any{"type": "object"}
+}
@@ -0,0 +1,29 @@
+package llm
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// WithTimeout is context.WithTimeout for configured timeouts, where 0 means
+// no limit
+func WithTimeout(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, timeout)
+}
+
+// newHTTPClient returns a client that gives up connecting to a server,
+// TLS handshake included, after connectTimeout, while responses may take
+// as long as the request's context allows. 0 leaves connecting unbounded.
+func newHTTPClient(connectTimeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if connectTimeout > 0 {
+		transport.DialContext = (&net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}).DialContext
+		transport.TLSHandshakeTimeout = connectTimeout
+	}
+	return &http.Client{Transport: transport}
+}
@@ -0,0 +1,30 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithTimeout(t *testing.T) {
+	ctx, cancel := WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+
+	ctx, cancel = WithTimeout(context.Background(), 0)
+	_, ok = ctx.Deadline()
+	assert.False(t, ok, "0 means no limit")
+	cancel()
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+}
+
+func TestNewHTTPClient(t *testing.T) {
+	client := newHTTPClient(3 * time.Second)
+	assert.Zero(t, client.Timeout, "responses may take as long as the context allows")
+	assert.Equal(t, 3*time.Second, client.Transport.(*http.Transport).TLSHandshakeTimeout)
+}
@@ -0,0 +1,130 @@
+package llm
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/mizzy/rigel/internal/logging"
+)
+
+// maxTraceBytes limits how much of a prompt or response is written to the log
+const maxTraceBytes = 2000
+
+// TracingProvider logs every request and response at debug level
+type TracingProvider struct {
+	Provider
+}
+
+// NewTracingProvider wraps a provider with request/response tracing
+func NewTracingProvider(p Provider) *TracingProvider {
+	return &TracingProvider{Provider: p}
+}
+
+// Unwrap returns the underlying provider
+func (t *TracingProvider) Unwrap() Provider {
+	return t.Provider
+}
+
+func (t *TracingProvider) enabled(ctx context.Context) bool {
+	return slog.Default().Enabled(ctx, slog.LevelDebug)
+}
+
+func (t *TracingProvider) trace(ctx context.Context, method string, start time.Time, request, response string, err error) {
+	attrs := []any{
+		"provider", t.GetName(),
+		"model", t.GetCurrentModel().Name,
+		"duration", time.Since(start),
+		"request", logging.Truncate(request, maxTraceBytes),
+	}
+	if err != nil {
+		slog.DebugContext(ctx, "llm "+method+" failed", append(attrs, "error", err)...)
+		return
+	}
+	slog.DebugContext(ctx, "llm "+method, append(attrs, "response", logging.Truncate(response, maxTraceBytes))...)
+}
+
+func (t *TracingProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	if !t.enabled(ctx) {
+		return t.Provider.Generate(ctx, prompt)
+	}
+	start := time.Now()
+	resp, err := t.Provider.Generate(ctx, prompt)
+	t.trace(ctx, "generate", start, prompt, resp, err)
+	return resp, err
+}
+
+func (t *TracingProvider) GenerateWithOptions(ctx context.Context, prompt string, opts GenerateOptions) (string, error) {
+	if !t.enabled(ctx) {
+		return t.Provider.GenerateWithOptions(ctx, prompt, opts)
+	}
+	start := time.Now()
+	resp, err := t.Provider.GenerateWithOptions(ctx, prompt, opts)
+	t.trace(ctx, "generate", start, opts.SystemPrompt+"\n"+prompt, resp, err)
+	return resp, err
+}
+
+func (t *TracingProvider) GenerateWithHistory(ctx context.Context, messages []Message, opts GenerateOptions) (string, error) {
+	if !t.enabled(ctx) {
+		return t.Provider.GenerateWithHistory(ctx, messages, opts)
+	}
+	start := time.Now()
+	resp, err := t.Provider.GenerateWithHistory(ctx, messages, opts)
+	request := ""
+	if len(messages) > 0 {
+		request = messages[len(messages)-1].Content
+	}
+	t.trace(ctx, "generate_with_history", start, request, resp, err)
+	return resp, err
+}
+
+func (t *TracingProvider) Stream(ctx context.Context, prompt string) (<-chan StreamResponse, error) {
+	if !t.enabled(ctx) {
+		return t.Provider.Stream(ctx, prompt)
+	}
+	start := time.Now()
+	in, err := t.Provider.Stream(ctx, prompt)
+	return t.traceStream(ctx, "stream", start, prompt, in, err)
+}
+
+func (t *TracingProvider) StreamWithHistory(ctx context.Context, messages []Message, opts GenerateOptions) (<-chan StreamResponse, error) {
+	if !t.enabled(ctx) {
+		return t.Provider.StreamWithHistory(ctx, messages, opts)
+	}
+	start := time.Now()
+	in, err := t.Provider.StreamWithHistory(ctx, messages, opts)
+	request := ""
+	if len(messages) > 0 {
+		request = messages[len(messages)-1].Content
+	}
+	return t.traceStream(ctx, "stream_with_history", start, request, in, err)
+}
+
+// traceStream passes a stream through, logging the full response once it ends
+func (t *TracingProvider) traceStream(ctx context.Context, method string, start time.Time, request string, in <-chan StreamResponse, err error) (<-chan StreamResponse, error) {
+	if err != nil {
+		t.trace(ctx, method, start, request, "", err)
+		return nil, err
+	}
+
+	out := make(chan StreamResponse)
+	go func() {
+		defer close(out)
+		var content []byte
+		var streamErr error
+		for resp := range in {
+			content = append(content, resp.Content...)
+			if resp.Error != nil {
+				streamErr = resp.Error
+			}
+			select {
+			case out <- resp:
+			case <-ctx.Done():
+				t.trace(ctx, method, start, request, string(content), ctx.Err())
+				return
+			}
+		}
+		t.trace(ctx, method, start, request, string(content), streamErr)
+	}()
+	return out, nil
+}
@@ -0,0 +1,188 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// charsPerToken approximates how many characters make up a token, since
+// providers don't report token counts through the Provider interface
+const charsPerToken = 4
+
+// Price is what a model charges in US dollars per million tokens
+type Price struct {
+	Input  float64
+	Output float64
+}
+
+// anthropicPrices are the list prices of Anthropic models by name prefix;
+// longer prefixes must come first
+var anthropicPrices = []struct {
+	prefix string
+	price  Price
+}{
+	{"claude-opus-4", Price{Input: 15, Output: 75}},
+	{"claude-sonnet-4", Price{Input: 3, Output: 15}},
+	{"claude-3-7-sonnet", Price{Input: 3, Output: 15}},
+	{"claude-3-5-sonnet", Price{Input: 3, Output: 15}},
+	{"claude-3-5-haiku", Price{Input: 0.8, Output: 4}},
+	{"claude-3-opus", Price{Input: 15, Output: 75}},
+	{"claude-3-sonnet", Price{Input: 3, Output: 15}},
+	{"claude-3-haiku", Price{Input: 0.25, Output: 1.25}},
+}
+
+// ModelPrice returns the price of a provider's model. Local Ollama models
+// are free; ok is false for models with an unknown price.
+func ModelPrice(provider, model string) (Price, bool) {
+	switch provider {
+	case "ollama":
+		return Price{}, true
+	case "anthropic":
+		for _, p := range anthropicPrices {
+			if strings.HasPrefix(model, p.prefix) {
+				return p.price, true
+			}
+		}
+	}
+	return Price{}, false
+}
+
+// Usage is the approximate token usage and cost of the requests made
+// through a MeteredProvider
+type Usage struct {
+	Requests     int
+	InputTokens  int
+	OutputTokens int
+
+	// Tokens of the latest request and its response, i.e. how much of the
+	// context window the conversation takes
+	ContextTokens int
+
+	// Cost in US dollars; only includes models with a known price, which
+	// CostKnown reports for all requests
+	Cost      float64
+	CostKnown bool
+}
+
+// MeteredProvider estimates the tokens and cost of every request
+type MeteredProvider struct {
+	Provider
+
+	mu    sync.Mutex
+	usage Usage
+}
+
+// NewMeteredProvider wraps a provider with usage metering
+func NewMeteredProvider(p Provider) *MeteredProvider {
+	return &MeteredProvider{Provider: p, usage: Usage{CostKnown: true}}
+}
+
+// Unwrap returns the underlying provider
+func (m *MeteredProvider) Unwrap() Provider {
+	return m.Provider
+}
+
+// Usage returns the usage so far
+func (m *MeteredProvider) Usage() Usage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.usage
+}
+
+// record adds a request and its response to the usage
+func (m *MeteredProvider) record(model, request, response string) {
+	if model == "" {
+		model = m.GetCurrentModel().Name
+	}
+	input := len(request) / charsPerToken
+	output := len(response) / charsPerToken
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.usage.Requests++
+	m.usage.InputTokens += input
+	m.usage.OutputTokens += output
+	m.usage.ContextTokens = input + output
+	if price, ok := ModelPrice(m.GetName(), model); ok {
+		m.usage.Cost += (float64(input)*price.Input + float64(output)*price.Output) / 1e6
+	} else {
+		m.usage.CostKnown = false
+	}
+}
+
+// historyText joins the system prompt and messages of a request
+func historyText(messages []Message, opts GenerateOptions) string {
+	var sb strings.Builder
+	sb.WriteString(opts.SystemPrompt)
+	for _, msg := range messages {
+		sb.WriteString(msg.Content)
+	}
+	return sb.String()
+}
+
+func (m *MeteredProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	resp, err := m.Provider.Generate(ctx, prompt)
+	if err == nil {
+		m.record("", prompt, resp)
+	}
+	return resp, err
+}
+
+func (m *MeteredProvider) GenerateWithOptions(ctx context.Context, prompt string, opts GenerateOptions) (string, error) {
+	resp, err := m.Provider.GenerateWithOptions(ctx, prompt, opts)
+	if err == nil {
+		m.record(opts.Model, opts.SystemPrompt+prompt, resp)
+	}
+	return resp, err
+}
+
+func (m *MeteredProvider) GenerateWithHistory(ctx context.Context, messages []Message, opts GenerateOptions) (string, error) {
+	resp, err := m.Provider.GenerateWithHistory(ctx, messages, opts)
+	if err == nil {
+		m.record(opts.Model, historyText(messages, opts), resp)
+	}
+	return resp, err
+}
+
+func (m *MeteredProvider) Stream(ctx context.Context, prompt string) (<-chan StreamResponse, error) {
+	in, err := m.Provider.Stream(ctx, prompt)
+	return m.meterStream(ctx, "", prompt, in, err)
+}
+
+func (m *MeteredProvider) StreamWithHistory(ctx context.Context, messages []Message, opts GenerateOptions) (<-chan StreamResponse, error) {
+	in, err := m.Provider.StreamWithHistory(ctx, messages, opts)
+	return m.meterStream(ctx, opts.Model, historyText(messages, opts), in, err)
+}
+
+// meterStream passes a stream through, recording the request once the
+// response has been received in full or in part
+func (m *MeteredProvider) meterStream(ctx context.Context, model, request string, in <-chan StreamResponse, err error) (<-chan StreamResponse, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan StreamResponse)
+	go func() {
+		defer close(out)
+		var content strings.Builder
+		defer func() { m.record(model, request, content.String()) }()
+		for resp := range in {
+			content.WriteString(resp.Content)
+			select {
+			case out <- resp:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// FindUsage returns the usage metered in a provider chain, if any
+func FindUsage(p Provider) (Usage, bool) {
+	if m, ok := As[*MeteredProvider](p); ok {
+		return m.Usage(), true
+	}
+	return Usage{}, false
+}
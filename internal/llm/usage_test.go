@@ -0,0 +1,55 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModelPrice(t *testing.T) {
+	price, ok := ModelPrice("anthropic", "claude-sonnet-4-20250514")
+	assert.True(t, ok)
+	assert.Equal(t, Price{Input: 3, Output: 15}, price)
+
+	price, ok = ModelPrice("anthropic", "claude-3-5-haiku-20241022")
+	assert.True(t, ok)
+	assert.Equal(t, Price{Input: 0.8, Output: 4}, price)
+
+	price, ok = ModelPrice("ollama", "llama3.2")
+	assert.True(t, ok)
+	assert.Zero(t, price)
+
+	_, ok = ModelPrice("anthropic", "claude-next")
+	assert.False(t, ok)
+}
+
+func TestMeteredProvider(t *testing.T) {
+	stub := &stubProvider{name: "anthropic", model: "claude-sonnet-4-20250514", resp: strings.Repeat("a", 400)}
+	metered := NewMeteredProvider(stub)
+	messages := []Message{{Role: "user", Content: strings.Repeat("q", 4000)}}
+
+	_, err := metered.GenerateWithHistory(context.Background(), messages, GenerateOptions{})
+	require.NoError(t, err)
+
+	ch, err := metered.StreamWithHistory(context.Background(), messages, GenerateOptions{})
+	require.NoError(t, err)
+	for range ch {
+	}
+
+	usage, ok := FindUsage(NewTracingProvider(metered))
+	require.True(t, ok)
+	assert.Equal(t, 2, usage.Requests)
+	assert.Equal(t, 2000, usage.InputTokens)
+	assert.Equal(t, 200, usage.OutputTokens)
+	assert.Equal(t, 1100, usage.ContextTokens)
+	assert.True(t, usage.CostKnown)
+	assert.InDelta(t, (2000*3+200*15)/1e6, usage.Cost, 1e-9)
+
+	stub.model = "claude-next"
+	_, err = metered.GenerateWithHistory(context.Background(), messages, GenerateOptions{})
+	require.NoError(t, err)
+	assert.False(t, metered.Usage().CostKnown)
+}
@@ -0,0 +1,153 @@
+// Package logging configures structured logging to ~/.rigel/logs/rigel.log.
+// Logs never go to the terminal so they cannot corrupt the interactive UI.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	logsDir = "logs"
+	logFile = "rigel.log"
+)
+
+var (
+	level = new(slog.LevelVar)
+
+	mu         sync.RWMutex
+	baseLevel  = slog.LevelInfo
+	secrets    []string
+	apiKeyExpr = regexp.MustCompile(`(sk-ant-[A-Za-z0-9_\-]{8,}|sk-[A-Za-z0-9_\-]{20,}|AIza[0-9A-Za-z_\-]{30,}|(?i:bearer)\s+[A-Za-z0-9._\-]{16,}|` +
+		`gh[pousr]_[A-Za-z0-9]{30,}|github_pat_[A-Za-z0-9_]{30,}|xox[abprs]-[A-Za-z0-9\-]{10,}|(?:AKIA|ASIA)[0-9A-Z]{16})`)
+)
+
+// ParseLevel converts a RIGEL_LOG_LEVEL value into a slog level. Unknown
+// values fall back to info.
+func ParseLevel(name string) slog.Level {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug", "trace":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// Path returns the log file location
+func Path() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".rigel", logsDir, logFile), nil
+}
+
+// Init opens the log file and installs a redacting slog logger as the
+// default. The given secrets (such as API keys) are masked in every log line.
+// The standard log package keeps writing to stderr; it is only used for
+// fatal startup errors the user has to see.
+func Init(levelName string, secretValues ...string) (io.Closer, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	AddSecrets(secretValues...)
+	SetBaseLevel(ParseLevel(levelName))
+
+	handler := slog.NewTextHandler(&redactWriter{w: file}, &slog.HandlerOptions{Level: level})
+	slog.SetDefault(slog.New(handler))
+
+	// slog.SetDefault redirects the standard logger into the handler; undo that
+	log.SetOutput(os.Stderr)
+	log.SetFlags(log.LstdFlags)
+
+	return file, nil
+}
+
+// AddSecrets registers values that must never appear in logs
+func AddSecrets(values ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, v := range values {
+		if len(v) >= 8 {
+			secrets = append(secrets, v)
+		}
+	}
+}
+
+// Redact masks registered secrets and anything that looks like an API key
+func Redact(s string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, "[REDACTED]")
+	}
+	return apiKeyExpr.ReplaceAllString(s, "[REDACTED]")
+}
+
+// SetBaseLevel sets the configured level that debug mode returns to
+func SetBaseLevel(l slog.Level) {
+	mu.Lock()
+	baseLevel = l
+	mu.Unlock()
+	level.Set(l)
+}
+
+// SetDebug switches verbose logging on or off at runtime
+func SetDebug(enabled bool) {
+	if enabled {
+		level.Set(slog.LevelDebug)
+		return
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+	level.Set(baseLevel)
+}
+
+// DebugEnabled reports whether debug logging is active
+func DebugEnabled() bool {
+	return level.Level() <= slog.LevelDebug
+}
+
+// Truncate shortens long payloads for trace logging
+func Truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return fmt.Sprintf("%s... (%d bytes)", s[:max], len(s))
+}
+
+// redactWriter masks secrets before writing log lines
+type redactWriter struct {
+	w io.Writer
+}
+
+func (r *redactWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(r.w, Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
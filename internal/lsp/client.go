@@ -0,0 +1,538 @@
+// Package lsp is a minimal Language Server Protocol client used to look up
+// definitions, references and diagnostics for symbols, starting with gopls.
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Position is a zero-based line and character offset in a document
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// Range is a span in a document
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Location is a range in a file
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+// Path returns the file path of the location
+func (l Location) Path() string {
+	return URIToPath(l.URI)
+}
+
+// Diagnostic is a problem the server found in a document
+type Diagnostic struct {
+	Range    Range  `json:"range"`
+	Severity int    `json:"severity"` // 1 error, 2 warning, 3 information, 4 hint
+	Source   string `json:"source"`
+	Message  string `json:"message"`
+}
+
+// Symbol is a symbol found by a workspace symbol search
+type Symbol struct {
+	Name          string   `json:"name"`
+	Kind          int      `json:"kind"`
+	Location      Location `json:"location"`
+	ContainerName string   `json:"containerName"`
+}
+
+// symbolKinds names the SymbolKind values worth showing
+var symbolKinds = map[int]string{
+	5: "class", 6: "method", 8: "field", 10: "enum", 11: "interface",
+	12: "function", 13: "variable", 14: "constant", 23: "struct", 26: "type parameter",
+}
+
+// KindName returns a readable name for the symbol's kind
+func (s Symbol) KindName() string {
+	if name, ok := symbolKinds[s.Kind]; ok {
+		return name
+	}
+	return "symbol"
+}
+
+// PathToURI converts a file path to a file:// URI
+func PathToURI(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String()
+}
+
+// URIToPath converts a file:// URI to a file path
+func URIToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+// message is a JSON-RPC request, response or notification
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *int            `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *ResponseError  `json:"error,omitempty"`
+}
+
+// ResponseError is an error returned by the server
+type ResponseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("lsp error %d: %s", e.Code, e.Message)
+}
+
+// ErrClosed is returned for requests made after the connection closed
+var ErrClosed = errors.New("lsp connection closed")
+
+// Client is a connection to a language server
+type Client struct {
+	writer io.WriteCloser
+	cmd    *exec.Cmd // Server process, if started by Start
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	nextID  int
+	pending map[int]chan *message
+	opened  map[string]bool
+	closed  bool
+
+	diagMu      sync.Mutex
+	diagnostics map[string][]Diagnostic // By URI
+	diagUpdated chan struct{}           // Closed and replaced when diagnostics arrive
+
+	done chan struct{}
+}
+
+// NewClient creates a client speaking to a server over r and w and starts
+// reading its messages
+func NewClient(r io.Reader, w io.WriteCloser) *Client {
+	c := &Client{
+		writer:      w,
+		pending:     make(map[int]chan *message),
+		opened:      make(map[string]bool),
+		diagnostics: make(map[string][]Diagnostic),
+		diagUpdated: make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go c.readLoop(bufio.NewReader(r))
+	return c
+}
+
+// Start runs a language server command in rootDir and initializes it
+func Start(ctx context.Context, rootDir string, command ...string) (*Client, error) {
+	if len(command) == 0 {
+		return nil, fmt.Errorf("no language server command")
+	}
+
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Dir = rootDir
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", command[0], err)
+	}
+
+	c := NewClient(stdout, stdin)
+	c.cmd = cmd
+	if err := c.Initialize(ctx, rootDir); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("failed to initialize %s: %w", command[0], err)
+	}
+	return c, nil
+}
+
+// Initialize performs the initialize handshake for the workspace at rootDir
+func (c *Client) Initialize(ctx context.Context, rootDir string) error {
+	params := map[string]interface{}{
+		"processId": os.Getpid(),
+		"rootUri":   PathToURI(rootDir),
+		"capabilities": map[string]interface{}{
+			"textDocument": map[string]interface{}{
+				"definition":         map[string]interface{}{},
+				"references":         map[string]interface{}{},
+				"publishDiagnostics": map[string]interface{}{},
+			},
+			"workspace": map[string]interface{}{
+				"symbol":        map[string]interface{}{},
+				"configuration": true,
+			},
+		},
+	}
+	if err := c.Call(ctx, "initialize", params, nil); err != nil {
+		return err
+	}
+	return c.Notify("initialized", map[string]interface{}{})
+}
+
+// Call sends a request and decodes its result into result, which may be nil
+func (c *Client) Call(ctx context.Context, method string, params, result interface{}) error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return ErrClosed
+	}
+	c.nextID++
+	id := c.nextID
+	ch := make(chan *message, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	if err := c.send(&message{ID: &id, Method: method}, params); err != nil {
+		return err
+	}
+
+	select {
+	case resp := <-ch:
+		if resp == nil {
+			return ErrClosed
+		}
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if result == nil || len(resp.Result) == 0 {
+			return nil
+		}
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			return fmt.Errorf("failed to decode %s result: %w", method, err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Notify sends a notification
+func (c *Client) Notify(method string, params interface{}) error {
+	return c.send(&message{Method: method}, params)
+}
+
+func (c *Client) send(msg *message, params interface{}) error {
+	msg.JSONRPC = "2.0"
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return fmt.Errorf("failed to marshal params: %w", err)
+		}
+		msg.Params = data
+	}
+	return c.write(msg)
+}
+
+func (c *Client) write(msg *message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := fmt.Fprintf(c.writer, "Content-Length: %d\r\n\r\n%s", len(data), data); err != nil {
+		return fmt.Errorf("failed to write to language server: %w", err)
+	}
+	return nil
+}
+
+// readLoop dispatches the server's messages until the connection closes
+func (c *Client) readLoop(r *bufio.Reader) {
+	defer func() {
+		c.mu.Lock()
+		c.closed = true
+		for id, ch := range c.pending {
+			close(ch)
+			delete(c.pending, id)
+		}
+		c.mu.Unlock()
+		close(c.done)
+	}()
+
+	for {
+		msg, err := readMessage(r)
+		if err != nil {
+			return
+		}
+
+		switch {
+		case msg.ID != nil && msg.Method != "":
+			c.handleRequest(msg)
+		case msg.ID != nil:
+			c.mu.Lock()
+			ch := c.pending[*msg.ID]
+			c.mu.Unlock()
+			if ch != nil {
+				ch <- msg
+			}
+		case msg.Method == "textDocument/publishDiagnostics":
+			c.handleDiagnostics(msg.Params)
+		}
+	}
+}
+
+// readMessage reads one Content-Length framed message
+func readMessage(r *bufio.Reader) (*message, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(name, "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("invalid Content-Length %q", value)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("missing Content-Length header")
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	var msg message
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, fmt.Errorf("failed to decode message: %w", err)
+	}
+	return &msg, nil
+}
+
+// handleRequest answers requests from the server. Nothing is configured,
+// so configuration requests get an empty value per item and the others a
+// null result.
+func (c *Client) handleRequest(msg *message) {
+	result := json.RawMessage("null")
+	if msg.Method == "workspace/configuration" {
+		var params struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		_ = json.Unmarshal(msg.Params, &params)
+		values := make([]interface{}, len(params.Items))
+		result, _ = json.Marshal(values)
+	}
+	_ = c.write(&message{JSONRPC: "2.0", ID: msg.ID, Result: result})
+}
+
+func (c *Client) handleDiagnostics(params json.RawMessage) {
+	var p struct {
+		URI         string       `json:"uri"`
+		Diagnostics []Diagnostic `json:"diagnostics"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return
+	}
+
+	c.diagMu.Lock()
+	defer c.diagMu.Unlock()
+	c.diagnostics[p.URI] = p.Diagnostics
+	close(c.diagUpdated)
+	c.diagUpdated = make(chan struct{})
+}
+
+// Open tells the server about a file so it is analyzed. Files are only
+// opened once.
+func (c *Client) Open(path string) error {
+	uri := PathToURI(path)
+	c.mu.Lock()
+	if c.opened[uri] {
+		c.mu.Unlock()
+		return nil
+	}
+	c.opened[uri] = true
+	c.mu.Unlock()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return c.Notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{
+			"uri":        uri,
+			"languageId": languageID(path),
+			"version":    1,
+			"text":       string(content),
+		},
+	})
+}
+
+// languageID returns the LSP language identifier for a file
+func languageID(path string) string {
+	switch filepath.Ext(path) {
+	case ".go":
+		return "go"
+	case ".ts", ".tsx":
+		return "typescript"
+	case ".js", ".jsx":
+		return "javascript"
+	case ".py":
+		return "python"
+	case ".rs":
+		return "rust"
+	default:
+		return strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+}
+
+func positionParams(path string, pos Position) map[string]interface{} {
+	return map[string]interface{}{
+		"textDocument": map[string]string{"uri": PathToURI(path)},
+		"position":     pos,
+	}
+}
+
+// Definition returns where the symbol at pos in path is defined
+func (c *Client) Definition(ctx context.Context, path string, pos Position) ([]Location, error) {
+	if err := c.Open(path); err != nil {
+		return nil, err
+	}
+	var raw json.RawMessage
+	if err := c.Call(ctx, "textDocument/definition", positionParams(path, pos), &raw); err != nil {
+		return nil, err
+	}
+	return decodeLocations(raw)
+}
+
+// References returns the locations referring to the symbol at pos in path,
+// excluding its declaration
+func (c *Client) References(ctx context.Context, path string, pos Position) ([]Location, error) {
+	if err := c.Open(path); err != nil {
+		return nil, err
+	}
+	params := positionParams(path, pos)
+	params["context"] = map[string]bool{"includeDeclaration": false}
+	var locations []Location
+	if err := c.Call(ctx, "textDocument/references", params, &locations); err != nil {
+		return nil, err
+	}
+	return locations, nil
+}
+
+// Symbols searches the workspace for symbols matching query
+func (c *Client) Symbols(ctx context.Context, query string) ([]Symbol, error) {
+	var symbols []Symbol
+	if err := c.Call(ctx, "workspace/symbol", map[string]string{"query": query}, &symbols); err != nil {
+		return nil, err
+	}
+	return symbols, nil
+}
+
+// Diagnostics opens path and waits until the server publishes its
+// diagnostics or ctx is done
+func (c *Client) Diagnostics(ctx context.Context, path string) ([]Diagnostic, error) {
+	if err := c.Open(path); err != nil {
+		return nil, err
+	}
+	uri := PathToURI(path)
+	for {
+		c.diagMu.Lock()
+		diagnostics, ok := c.diagnostics[uri]
+		updated := c.diagUpdated
+		c.diagMu.Unlock()
+		if ok {
+			return diagnostics, nil
+		}
+
+		select {
+		case <-updated:
+		case <-c.done:
+			return nil, ErrClosed
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// decodeLocations decodes a definition result, which may be a single
+// location, a list of locations or a list of location links
+func decodeLocations(raw json.RawMessage) ([]Location, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	var single Location
+	if err := json.Unmarshal(raw, &single); err == nil && single.URI != "" {
+		return []Location{single}, nil
+	}
+
+	var items []struct {
+		Location
+		TargetURI   string `json:"targetUri"`
+		TargetRange Range  `json:"targetSelectionRange"`
+	}
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, fmt.Errorf("failed to decode locations: %w", err)
+	}
+	locations := make([]Location, 0, len(items))
+	for _, item := range items {
+		if item.TargetURI != "" {
+			locations = append(locations, Location{URI: item.TargetURI, Range: item.TargetRange})
+		} else {
+			locations = append(locations, item.Location)
+		}
+	}
+	return locations, nil
+}
+
+// Close shuts the server down and waits for it to exit
+func (c *Client) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := c.Call(ctx, "shutdown", nil, nil); err == nil {
+		_ = c.Notify("exit", nil)
+	}
+	c.writer.Close()
+
+	if c.cmd != nil {
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+			_ = c.cmd.Process.Kill()
+		}
+		return c.cmd.Wait()
+	}
+	return nil
+}
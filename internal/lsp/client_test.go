@@ -0,0 +1,183 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeServer is an in-process language server answering requests with
+// handlers by method
+type fakeServer struct {
+	t        *testing.T
+	in       *bufio.Reader
+	out      io.WriteCloser
+	handlers map[string]func(params json.RawMessage) interface{}
+
+	// Called for notifications; may send messages with notify
+	onNotify func(method string, params json.RawMessage)
+
+	// Responses to requests the server sent to the client
+	clientResponses chan *message
+}
+
+// newFakeServer starts a fake server and returns a client connected to it
+func newFakeServer(t *testing.T, handlers map[string]func(json.RawMessage) interface{}) (*fakeServer, *Client) {
+	t.Helper()
+	clientReader, serverWriter := io.Pipe()
+	serverReader, clientWriter := io.Pipe()
+
+	s := &fakeServer{
+		t:               t,
+		in:              bufio.NewReader(serverReader),
+		out:             serverWriter,
+		handlers:        handlers,
+		clientResponses: make(chan *message, 4),
+	}
+	go s.serve()
+
+	client := NewClient(clientReader, clientWriter)
+	t.Cleanup(func() { client.Close() })
+	return s, client
+}
+
+func (s *fakeServer) serve() {
+	defer s.out.Close()
+	for {
+		msg, err := readMessage(s.in)
+		if err != nil {
+			return
+		}
+		switch {
+		case msg.ID != nil && msg.Method == "":
+			s.clientResponses <- msg
+		case msg.ID != nil:
+			var result interface{}
+			if handler, ok := s.handlers[msg.Method]; ok {
+				result = handler(msg.Params)
+			}
+			data, _ := json.Marshal(result)
+			s.send(&message{JSONRPC: "2.0", ID: msg.ID, Result: data})
+		case msg.Method == "exit":
+			return
+		case s.onNotify != nil:
+			s.onNotify(msg.Method, msg.Params)
+		}
+	}
+}
+
+func (s *fakeServer) send(msg *message) {
+	data, _ := json.Marshal(msg)
+	_, _ = io.WriteString(s.out, "Content-Length: "+strconv.Itoa(len(data))+"\r\n\r\n"+string(data))
+}
+
+func (s *fakeServer) notify(method string, params interface{}) {
+	data, _ := json.Marshal(params)
+	s.send(&message{JSONRPC: "2.0", Method: method, Params: data})
+}
+
+func TestClientRequests(t *testing.T) {
+	uri := PathToURI("/repo/main.go")
+	_, client := newFakeServer(t, map[string]func(json.RawMessage) interface{}{
+		"initialize": func(json.RawMessage) interface{} {
+			return map[string]interface{}{"capabilities": map[string]interface{}{}}
+		},
+		"workspace/symbol": func(params json.RawMessage) interface{} {
+			var p struct{ Query string }
+			_ = json.Unmarshal(params, &p)
+			return []Symbol{{Name: p.Query, Kind: 12, Location: Location{URI: uri, Range: Range{Start: Position{Line: 4, Character: 5}}}}}
+		},
+		"textDocument/definition": func(json.RawMessage) interface{} {
+			return []map[string]interface{}{{
+				"targetUri":            uri,
+				"targetSelectionRange": Range{Start: Position{Line: 9}},
+			}}
+		},
+		"textDocument/references": func(params json.RawMessage) interface{} {
+			var p struct {
+				Context struct{ IncludeDeclaration bool }
+			}
+			_ = json.Unmarshal(params, &p)
+			assert.False(t, p.Context.IncludeDeclaration)
+			return []Location{{URI: uri, Range: Range{Start: Position{Line: 20}}}}
+		},
+	})
+	ctx := context.Background()
+
+	require.NoError(t, client.Initialize(ctx, "/repo"))
+
+	symbols, err := client.Symbols(ctx, "NewServer")
+	require.NoError(t, err)
+	require.Len(t, symbols, 1)
+	assert.Equal(t, "NewServer", symbols[0].Name)
+	assert.Equal(t, "function", symbols[0].KindName())
+	assert.Equal(t, "/repo/main.go", symbols[0].Location.Path())
+
+	client.opened[uri] = true // Don't read the file
+	definitions, err := client.Definition(ctx, "/repo/main.go", Position{Line: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []Location{{URI: uri, Range: Range{Start: Position{Line: 9}}}}, definitions)
+
+	refs, err := client.References(ctx, "/repo/main.go", Position{Line: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 20, refs[0].Range.Start.Line)
+}
+
+func TestClientAnswersServerRequests(t *testing.T) {
+	server, _ := newFakeServer(t, nil)
+
+	id := 7
+	server.send(&message{JSONRPC: "2.0", ID: &id, Method: "workspace/configuration", Params: json.RawMessage(`{"items":[{"section":"gopls"},{}]}`)})
+
+	select {
+	case resp := <-server.clientResponses:
+		assert.Equal(t, 7, *resp.ID)
+		assert.JSONEq(t, `[null,null]`, string(resp.Result))
+	case <-time.After(time.Second):
+		t.Fatal("no response to workspace/configuration")
+	}
+}
+
+func TestClientDiagnostics(t *testing.T) {
+	path := t.TempDir() + "/main.go"
+	require.NoError(t, writeFile(path, "package main\n"))
+
+	server, client := newFakeServer(t, nil)
+	server.onNotify = func(method string, params json.RawMessage) {
+		if method != "textDocument/didOpen" {
+			return
+		}
+		var p struct {
+			TextDocument struct{ URI, LanguageID, Text string }
+		}
+		_ = json.Unmarshal(params, &p)
+		assert.Equal(t, "package main\n", p.TextDocument.Text)
+		server.notify("textDocument/publishDiagnostics", map[string]interface{}{
+			"uri":         p.TextDocument.URI,
+			"diagnostics": []Diagnostic{{Severity: 1, Message: "undefined: x"}},
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	diagnostics, err := client.Diagnostics(ctx, path)
+	require.NoError(t, err)
+	require.Len(t, diagnostics, 1)
+	assert.Equal(t, "undefined: x", diagnostics[0].Message)
+}
+
+func TestClientClosed(t *testing.T) {
+	server, client := newFakeServer(t, nil)
+	server.out.Close()
+	<-client.done
+
+	err := client.Call(context.Background(), "workspace/symbol", nil, nil)
+	assert.ErrorIs(t, err, ErrClosed)
+}
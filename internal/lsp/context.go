@@ -0,0 +1,293 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// startTimeout limits how long a language server may take to start,
+	// lookupTimeout how long looking up the symbols of a prompt may take
+	// once it has, and shutdownTimeout how long it may take to exit
+	startTimeout    = 30 * time.Second
+	lookupTimeout   = 10 * time.Second
+	shutdownTimeout = 5 * time.Second
+
+	// diagnosticsTimeout limits how long to wait for a file's diagnostics
+	diagnosticsTimeout = 2 * time.Second
+
+	maxSymbols       = 3  // Symbols looked up per prompt
+	maxSnippetLines  = 40 // Lines of each definition shown
+	maxReferences    = 10 // References listed per symbol
+	maxFileProblems  = 5  // Diagnostics listed per definition's file
+	snippetDocLines  = 10 // Doc comment lines included above a definition
+	minSymbolNameLen = 3
+)
+
+// identifierPattern matches words that look like code identifiers: a
+// backquoted word, a qualified name such as pkg.Name, or a name with an
+// inner capital letter or underscore such as NewProvider or max_tokens
+var identifierPattern = regexp.MustCompile("`([A-Za-z_][\\w.]*)`|\\b([A-Za-z_]\\w*\\.[A-Za-z_]\\w*|[A-Za-z]\\w*[a-z0-9][A-Z_]\\w*)\\b")
+
+// Identifiers returns the distinct identifiers mentioned in a prompt, in
+// order of appearance
+func Identifiers(prompt string) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, m := range identifierPattern.FindAllStringSubmatch(prompt, -1) {
+		name := m[1]
+		if name == "" {
+			name = m[2]
+		}
+		name = strings.Trim(name, ".")
+		if len(name) < minSymbolNameLen || seen[name] || isFileName(name) {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// isFileName reports whether a qualified-looking name is actually a file
+// name such as main.go
+func isFileName(name string) bool {
+	switch filepath.Ext(name) {
+	case ".go", ".md", ".json", ".yaml", ".yml", ".txt", ".mod", ".sum", ".js", ".ts", ".py", ".rs", ".toml":
+		return true
+	}
+	return false
+}
+
+// SymbolContext looks up the symbols a prompt mentions with a language
+// server and describes their definitions, references and diagnostics. The
+// server is started on first use.
+type SymbolContext struct {
+	rootDir string
+	command []string
+
+	mu       sync.Mutex
+	client   *Client
+	startErr error
+}
+
+// NewSymbolContext creates a symbol context for the workspace at rootDir
+// using the language server command
+func NewSymbolContext(rootDir string, command ...string) *SymbolContext {
+	return &SymbolContext{rootDir: rootDir, command: command}
+}
+
+// newSymbolContextWithClient creates a symbol context using an already
+// initialized client
+func newSymbolContextWithClient(rootDir string, client *Client) *SymbolContext {
+	return &SymbolContext{rootDir: rootDir, client: client}
+}
+
+// connect returns the client, starting the server the first time. A server
+// that failed to start isn't retried.
+func (s *SymbolContext) connect(ctx context.Context) (*Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client != nil || s.startErr != nil {
+		return s.client, s.startErr
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, startTimeout)
+	defer cancel()
+	s.client, s.startErr = Start(ctx, s.rootDir, s.command...)
+	return s.client, s.startErr
+}
+
+// ProvideContext describes the symbols mentioned in prompt, or returns an
+// empty string if it mentions none the server knows
+func (s *SymbolContext) ProvideContext(ctx context.Context, prompt string) (string, error) {
+	names := Identifiers(prompt)
+	if len(names) == 0 {
+		return "", nil
+	}
+
+	client, err := s.connect(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, lookupTimeout)
+	defer cancel()
+
+	var sections []string
+	for _, name := range names {
+		if len(sections) == maxSymbols {
+			break
+		}
+		symbol, ok, err := s.find(ctx, client, name)
+		if err != nil {
+			return strings.Join(sections, "\n"), err
+		}
+		if ok {
+			sections = append(sections, s.describe(ctx, client, symbol))
+		}
+	}
+	return strings.Join(sections, "\n"), nil
+}
+
+// find returns the workspace symbol named name. A qualified name such as
+// pkg.Name matches a symbol Name in a container ending with pkg.
+func (s *SymbolContext) find(ctx context.Context, client *Client, name string) (Symbol, bool, error) {
+	qualifier, base := "", name
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		qualifier, base = name[:i], name[i+1:]
+	}
+
+	symbols, err := client.Symbols(ctx, base)
+	if err != nil {
+		return Symbol{}, false, err
+	}
+	for _, symbol := range symbols {
+		symbolName := symbol.Name
+		// gopls qualifies methods and fields with their type, as Type.Name
+		if i := strings.LastIndex(symbolName, "."); i >= 0 && qualifier == "" {
+			symbolName = symbolName[i+1:]
+		}
+		if symbolName != base && symbol.Name != name {
+			continue
+		}
+		if qualifier != "" && symbol.Name != name && !strings.HasSuffix(symbol.ContainerName, qualifier) {
+			continue
+		}
+		if !s.inWorkspace(symbol.Location.Path()) {
+			continue
+		}
+		return symbol, true, nil
+	}
+	return Symbol{}, false, nil
+}
+
+func (s *SymbolContext) inWorkspace(path string) bool {
+	root, err := filepath.Abs(s.rootDir)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(root, path)
+	return err == nil && !strings.HasPrefix(rel, "..")
+}
+
+// relative shows path relative to the workspace root
+func (s *SymbolContext) relative(path string) string {
+	root, err := filepath.Abs(s.rootDir)
+	if err != nil {
+		return path
+	}
+	if rel, err := filepath.Rel(root, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+// describe renders a symbol's definition, references and the problems in
+// its file
+func (s *SymbolContext) describe(ctx context.Context, client *Client, symbol Symbol) string {
+	path := symbol.Location.Path()
+	start := symbol.Location.Range.Start
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s (%s) defined at %s:%d:\n", symbol.Name, symbol.KindName(), s.relative(path), start.Line+1))
+	if snippet, err := readSnippet(path, start.Line); err == nil {
+		sb.WriteString("```\n" + snippet + "\n```\n")
+	}
+
+	if refs, err := client.References(ctx, path, start); err == nil && len(refs) > 0 {
+		var places []string
+		for i, ref := range refs {
+			if i == maxReferences {
+				places = append(places, fmt.Sprintf("and %d more", len(refs)-maxReferences))
+				break
+			}
+			places = append(places, fmt.Sprintf("%s:%d", s.relative(ref.Path()), ref.Range.Start.Line+1))
+		}
+		sb.WriteString("Referenced at: " + strings.Join(places, ", ") + "\n")
+	}
+
+	diagCtx, cancel := context.WithTimeout(ctx, diagnosticsTimeout)
+	defer cancel()
+	if diagnostics, err := client.Diagnostics(diagCtx, path); err == nil && len(diagnostics) > 0 {
+		sb.WriteString(fmt.Sprintf("Problems in %s:\n", s.relative(path)))
+		for i, d := range diagnostics {
+			if i == maxFileProblems {
+				sb.WriteString(fmt.Sprintf("  ... and %d more\n", len(diagnostics)-maxFileProblems))
+				break
+			}
+			sb.WriteString(fmt.Sprintf("  %d:%d: %s\n", d.Range.Start.Line+1, d.Range.Start.Character+1, d.Message))
+		}
+	}
+	return sb.String()
+}
+
+// readSnippet returns the declaration starting at line (zero-based) with
+// its doc comment: the lines up to where the braces opened on it close, or
+// the first blank line, at most maxSnippetLines
+func readSnippet(path string, line int) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	if line < 0 || line >= len(lines) {
+		return "", fmt.Errorf("line %d is outside %s", line+1, path)
+	}
+
+	start := line
+	for start > 0 && line-start < snippetDocLines && strings.HasPrefix(strings.TrimSpace(lines[start-1]), "//") {
+		start--
+	}
+
+	depth, opened := 0, false
+	end := line
+	for ; end < len(lines) && end-start < maxSnippetLines; end++ {
+		text := lines[end]
+		if end > line && !opened && strings.TrimSpace(text) == "" {
+			break
+		}
+		depth += strings.Count(text, "{") + strings.Count(text, "(") - strings.Count(text, "}") - strings.Count(text, ")")
+		if strings.ContainsAny(text, "{(") {
+			opened = true
+		}
+		if opened && depth <= 0 {
+			end++
+			break
+		}
+		if !opened && end == line {
+			// A one-line declaration such as a constant or field
+			end++
+			break
+		}
+	}
+	return strings.Join(lines[start:end], "\n"), nil
+}
+
+// Close stops the language server if it was started
+func (s *SymbolContext) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client == nil {
+		return nil
+	}
+	err := s.client.Close()
+	s.client = nil
+	return err
+}
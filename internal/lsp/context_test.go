@@ -0,0 +1,141 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(path, content string) error {
+	return os.WriteFile(path, []byte(content), 0o644)
+}
+
+func TestIdentifiers(t *testing.T) {
+	tests := []struct {
+		prompt string
+		want   []string
+	}{
+		{"Why does NewProvider fail?", []string{"NewProvider"}},
+		{"Explain llm.NewProvider and `run`", []string{"llm.NewProvider", "run"}},
+		{"What does max_tokens do in parseConfig?", []string{"max_tokens", "parseConfig"}},
+		{"Fix the bug in main.go", nil},
+		{"How are you today?", nil},
+		{"Compare NewProvider with NewProvider", []string{"NewProvider"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.prompt, func(t *testing.T) {
+			assert.Equal(t, tt.want, Identifiers(tt.prompt))
+		})
+	}
+}
+
+const serverSource = `package server
+
+// Server handles requests
+type Server struct {
+	addr string
+}
+
+// NewServer creates a server
+// listening on addr
+func NewServer(addr string) *Server {
+	if addr == "" {
+		addr = ":8080"
+	}
+	return &Server{addr: addr}
+}
+
+const defaultPort = 8080
+
+func other() {}
+`
+
+func TestReadSnippet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.go")
+	require.NoError(t, writeFile(path, serverSource))
+
+	tests := []struct {
+		name string
+		line int
+		want string
+	}{
+		{"function with doc comment", 9, "// NewServer creates a server\n// listening on addr\nfunc NewServer(addr string) *Server {\n\tif addr == \"\" {\n\t\taddr = \":8080\"\n\t}\n\treturn &Server{addr: addr}\n}"},
+		{"struct", 3, "// Server handles requests\ntype Server struct {\n\taddr string\n}"},
+		{"constant", 16, "const defaultPort = 8080"},
+		{"one-line function", 18, "func other() {}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snippet, err := readSnippet(path, tt.line)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, snippet)
+		})
+	}
+
+	_, err := readSnippet(path, 100)
+	assert.Error(t, err)
+}
+
+func TestSymbolContext(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "server", "server.go")
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, writeFile(path, serverSource))
+	uri := PathToURI(path)
+
+	server, client := newFakeServer(t, map[string]func(json.RawMessage) interface{}{
+		"workspace/symbol": func(params json.RawMessage) interface{} {
+			var p struct{ Query string }
+			_ = json.Unmarshal(params, &p)
+			if p.Query != "NewServer" {
+				return []Symbol{}
+			}
+			return []Symbol{
+				{Name: "NewServer", Kind: 12, ContainerName: "example.com/other", Location: Location{URI: PathToURI("/elsewhere/server.go")}},
+				{Name: "NewServer", Kind: 12, ContainerName: "example.com/demo/server", Location: Location{URI: uri, Range: Range{Start: Position{Line: 9, Character: 5}}}},
+			}
+		},
+		"textDocument/references": func(json.RawMessage) interface{} {
+			return []Location{{URI: PathToURI(filepath.Join(root, "main.go")), Range: Range{Start: Position{Line: 11}}}}
+		},
+	})
+	server.onNotify = func(method string, params json.RawMessage) {
+		if method == "textDocument/didOpen" {
+			server.notify("textDocument/publishDiagnostics", map[string]interface{}{
+				"uri":         uri,
+				"diagnostics": []Diagnostic{{Range: Range{Start: Position{Line: 16, Character: 6}}, Message: "defaultPort is unused"}},
+			})
+		}
+	}
+
+	symbols := newSymbolContextWithClient(root, client)
+	result, err := symbols.ProvideContext(context.Background(), "Why does server.NewServer ignore UnknownThing?")
+	require.NoError(t, err)
+
+	assert.Contains(t, result, "NewServer (function) defined at server/server.go:10:")
+	assert.Contains(t, result, "func NewServer(addr string) *Server {")
+	assert.Contains(t, result, "Referenced at: main.go:12")
+	assert.Contains(t, result, "Problems in server/server.go:\n  17:7: defaultPort is unused")
+	assert.NotContains(t, result, "UnknownThing")
+
+	result, err = symbols.ProvideContext(context.Background(), "how are you?")
+	require.NoError(t, err)
+	assert.Empty(t, result)
+}
+
+func TestSymbolContextStartFailure(t *testing.T) {
+	symbols := NewSymbolContext(t.TempDir(), "rigel-no-such-language-server")
+	_, err := symbols.ProvideContext(context.Background(), "Explain NewServer")
+	assert.Error(t, err)
+
+	// The failure is remembered rather than retried
+	_, err2 := symbols.ProvideContext(context.Background(), "Explain NewServer")
+	assert.Equal(t, err, err2)
+}
@@ -0,0 +1,110 @@
+// Package notes keeps durable facts about a project, such as "we use
+// uber-fx for DI", in .rigel/memory.md. They are added with /remember or by
+// the agent and included in the system prompt of every session.
+package notes
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mizzy/rigel/internal/privacy"
+)
+
+// Path is where the notes are kept, relative to the project root
+var Path = filepath.Join(".rigel", "memory.md")
+
+// header starts the notes file, which stays readable and editable by hand
+const header = `# Project memory
+
+Facts to remember in every session, added with /remember. One per line.
+
+`
+
+// Load returns the notes, oldest first. A missing file has no notes.
+func Load() ([]string, error) {
+	data, err := os.ReadFile(Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", Path, err)
+	}
+
+	var notes []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if note, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "- "); ok {
+			if note = strings.TrimSpace(note); note != "" {
+				notes = append(notes, note)
+			}
+		}
+	}
+	return notes, scanner.Err()
+}
+
+// Add appends a note, with secrets masked. Notes already there aren't added
+// again.
+func Add(note string) error {
+	note = privacy.Redact(strings.Join(strings.Fields(note), " "))
+	if note == "" {
+		return fmt.Errorf("nothing to remember")
+	}
+	notes, err := Load()
+	if err != nil {
+		return err
+	}
+	for _, existing := range notes {
+		if existing == note {
+			return nil
+		}
+	}
+	return save(append(notes, note))
+}
+
+// Remove deletes the nth note, counting from 1, and returns it
+func Remove(n int) (string, error) {
+	notes, err := Load()
+	if err != nil {
+		return "", err
+	}
+	if n < 1 || n > len(notes) {
+		return "", fmt.Errorf("no note %d: there are %d", n, len(notes))
+	}
+	removed := notes[n-1]
+	return removed, save(append(notes[:n-1], notes[n:]...))
+}
+
+// Section returns the notes as a section of the system prompt, or "" if
+// there are none
+func Section() string {
+	notes, err := Load()
+	if err != nil || len(notes) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("# Project Memory\n\nFacts the user asked you to remember about this project:\n\n")
+	for _, note := range notes {
+		sb.WriteString("- " + note + "\n")
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// save rewrites the notes file
+func save(notes []string) error {
+	if err := os.MkdirAll(filepath.Dir(Path), 0755); err != nil {
+		return fmt.Errorf("failed to create .rigel directory: %w", err)
+	}
+	var sb strings.Builder
+	sb.WriteString(header)
+	for _, note := range notes {
+		sb.WriteString("- " + note + "\n")
+	}
+	if err := os.WriteFile(Path, []byte(sb.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", Path, err)
+	}
+	return nil
+}
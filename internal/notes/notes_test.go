@@ -0,0 +1,53 @@
+package notes
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotes(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	loaded, err := Load()
+	require.NoError(t, err)
+	assert.Empty(t, loaded)
+	assert.Empty(t, Section())
+
+	require.NoError(t, Add("we use uber-fx for DI"))
+	require.NoError(t, Add("  tests   use testify "))
+	require.NoError(t, Add("we use uber-fx for DI"))
+	assert.Error(t, Add("   "))
+
+	loaded, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"we use uber-fx for DI", "tests use testify"}, loaded)
+	assert.Equal(t, `# Project Memory
+
+Facts the user asked you to remember about this project:
+
+- we use uber-fx for DI
+- tests use testify`, Section())
+
+	removed, err := Remove(1)
+	require.NoError(t, err)
+	assert.Equal(t, "we use uber-fx for DI", removed)
+	_, err = Remove(2)
+	assert.Error(t, err)
+
+	loaded, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tests use testify"}, loaded)
+}
+
+func TestLoadHandEditedFile(t *testing.T) {
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.Mkdir(".rigel", 0755))
+	require.NoError(t, os.WriteFile(Path, []byte("# Notes\n\nSome prose.\n\n- first\n-\n  - second\n"), 0644))
+
+	loaded, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first", "second"}, loaded)
+}
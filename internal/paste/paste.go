@@ -0,0 +1,277 @@
+// Package paste reads images to attach to a prompt, such as screenshots,
+// from the clipboard or from files, and fits them to the size limits of
+// vision models
+package paste
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // Registers the decoder
+	"image/jpeg"
+	"image/png"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/mizzy/rigel/internal/llm"
+)
+
+const (
+	// MaxDimension is the longest side images are downscaled to: the
+	// providers downscale larger images anyway, charging for the upload
+	MaxDimension = 1568
+
+	// MaxBytes is the largest an attached image may be, which keeps it
+	// under the 5 MB limit of the APIs once base64 encoded
+	MaxBytes = 3_750_000
+
+	// jpegQuality is used when an image is too large as PNG
+	jpegQuality = 85
+)
+
+// ErrNoImage is returned when the clipboard holds no image
+var ErrNoImage = errors.New("the clipboard holds no image")
+
+// clipboardCommand reads a PNG image from the clipboard
+type clipboardCommand struct {
+	args []string
+	// available reports whether the command applies to this terminal
+	available func() bool
+	// decode extracts the image from the output, which is the image itself
+	// if nil
+	decode func([]byte) []byte
+}
+
+// clipboardCommands are tried in order until one returns an image. kitty's
+// clipboard kitten reads through the terminal, so it works over SSH too.
+var clipboardCommands = []clipboardCommand{
+	{
+		args:      []string{"kitten", "clipboard", "--get-clipboard", "--mime", "image/png", "/dev/stdout"},
+		available: func() bool { return os.Getenv("KITTY_WINDOW_ID") != "" },
+	},
+	{
+		args:      []string{"pngpaste", "-"},
+		available: func() bool { return runtime.GOOS == "darwin" },
+	},
+	{
+		args:      []string{"osascript", "-e", "the clipboard as «class PNGf»"},
+		available: func() bool { return runtime.GOOS == "darwin" },
+		decode:    decodeAppleScriptData,
+	},
+	{
+		args:      []string{"wl-paste", "--no-newline", "--type", "image/png"},
+		available: func() bool { return os.Getenv("WAYLAND_DISPLAY") != "" },
+	},
+	{
+		args:      []string{"xclip", "-selection", "clipboard", "-target", "image/png", "-out"},
+		available: func() bool { return os.Getenv("DISPLAY") != "" },
+	},
+}
+
+// Attachment is an image fitted to the limits, ready to attach to a prompt
+type Attachment struct {
+	Image         llm.Image
+	Width, Height int // Zero for WebP images, which aren't decoded
+	// Original is the size of the image before it was downscaled, or zero
+	Original image.Point
+}
+
+// String describes the attachment, e.g. "1568×980 PNG, 412 KB"
+func (a Attachment) String() string {
+	format := strings.ToUpper(strings.TrimPrefix(a.Image.MediaType, "image/"))
+	s := fmt.Sprintf("%s, %s", format, formatSize(len(a.Image.Data)))
+	if a.Width > 0 {
+		s = fmt.Sprintf("%d×%d %s", a.Width, a.Height, s)
+	}
+	if a.Original != (image.Point{}) {
+		s += fmt.Sprintf(", downscaled from %d×%d", a.Original.X, a.Original.Y)
+	}
+	return s
+}
+
+// FromClipboard reads the image in the clipboard with the first of the
+// clipboard tools installed that has one
+func FromClipboard(ctx context.Context) ([]byte, error) {
+	found := false
+	for _, cmd := range clipboardCommands {
+		if !cmd.available() {
+			continue
+		}
+		if _, err := exec.LookPath(cmd.args[0]); err != nil {
+			continue
+		}
+		found = true
+
+		out, err := exec.CommandContext(ctx, cmd.args[0], cmd.args[1:]...).Output()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue
+		}
+		if cmd.decode != nil {
+			out = cmd.decode(out)
+		}
+		if len(out) > 0 {
+			return out, nil
+		}
+	}
+	if !found {
+		return nil, errors.New("no clipboard tool for images found: install pngpaste on macOS, wl-clipboard on Wayland or xclip on X11, or give the path of the image")
+	}
+	return nil, ErrNoImage
+}
+
+// decodeAppleScriptData extracts the image from AppleScript's «data PNGf…»
+// notation of the clipboard, hex encoded
+func decodeAppleScriptData(out []byte) []byte {
+	s := strings.TrimSpace(string(out))
+	s, ok := strings.CutPrefix(s, "«data PNGf")
+	if !ok {
+		return nil
+	}
+	data, err := hex.DecodeString(strings.TrimSuffix(s, "»"))
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// FromFile reads an image file, e.g. the path a terminal pastes when a
+// screenshot is dropped into it
+func FromFile(path string) ([]byte, error) {
+	path = strings.Trim(strings.TrimSpace(path), `"'`)
+	// Terminals escape the spaces of dropped paths
+	path = strings.ReplaceAll(path, `\ `, " ")
+	if home, err := os.UserHomeDir(); err == nil && strings.HasPrefix(path, "~/") {
+		path = home + path[1:]
+	}
+	return os.ReadFile(path)
+}
+
+// Fit returns the image fitted to the limits: downscaled to MaxDimension
+// and re-encoded as PNG, or as JPEG if it is still larger than MaxBytes
+func Fit(data []byte) (Attachment, error) {
+	if isWebP(data) {
+		// Not decodable by the standard library, so passed as is
+		if len(data) > MaxBytes {
+			return Attachment{}, fmt.Errorf("the WebP image is %s, larger than the %s limit", formatSize(len(data)), formatSize(MaxBytes))
+		}
+		return Attachment{Image: llm.Image{MediaType: "image/webp", Data: data}}, nil
+	}
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Attachment{}, fmt.Errorf("not a PNG, JPEG, GIF or WebP image: %w", err)
+	}
+	if config.Width <= MaxDimension && config.Height <= MaxDimension && len(data) <= MaxBytes {
+		return Attachment{Image: llm.Image{MediaType: "image/" + format, Data: data}, Width: config.Width, Height: config.Height}, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Attachment{}, err
+	}
+	original := img.Bounds().Size()
+	width, height := fitDimensions(original.X, original.Y, MaxDimension)
+	for {
+		scaled := downscale(img, width, height)
+		encoded, mediaType, err := encode(scaled)
+		if err != nil {
+			return Attachment{}, err
+		}
+		if len(encoded) <= MaxBytes || width <= 1 || height <= 1 {
+			attachment := Attachment{Image: llm.Image{MediaType: mediaType, Data: encoded}, Width: width, Height: height}
+			if width != original.X || height != original.Y {
+				attachment.Original = original
+			}
+			return attachment, nil
+		}
+		width, height = max(1, width*3/4), max(1, height*3/4)
+	}
+}
+
+// fitDimensions returns the size of an image scaled down to fit limit on
+// its longest side, keeping the aspect ratio
+func fitDimensions(width, height, limit int) (int, int) {
+	if width <= limit && height <= limit {
+		return width, height
+	}
+	if width >= height {
+		return limit, max(1, height*limit/width)
+	}
+	return max(1, width*limit/height), limit
+}
+
+// encode encodes an image as PNG, which suits screenshots, or as JPEG if
+// the PNG is too large
+func encode(img image.Image) ([]byte, string, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, "", err
+	}
+	if buf.Len() <= MaxBytes {
+		return buf.Bytes(), "image/png", nil
+	}
+	buf.Reset()
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "image/jpeg", nil
+}
+
+// downscale scales an image down to width×height, averaging the source
+// pixels each destination pixel covers, which keeps text in screenshots
+// legible
+func downscale(src image.Image, width, height int) *image.RGBA {
+	bounds := src.Bounds()
+	rgba, ok := src.(*image.RGBA)
+	if !ok {
+		rgba = image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+	}
+	srcW, srcH := rgba.Bounds().Dx(), rgba.Bounds().Dy()
+	origin := rgba.Bounds().Min
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		y0, y1 := y*srcH/height, max((y+1)*srcH/height, y*srcH/height+1)
+		for x := range width {
+			x0, x1 := x*srcW/width, max((x+1)*srcW/width, x*srcW/width+1)
+			var r, g, b, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := rgba.RGBAAt(origin.X+sx, origin.Y+sy)
+					r, g, b, a = r+uint32(c.R), g+uint32(c.G), b+uint32(c.B), a+uint32(c.A)
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: uint8(a / n)})
+		}
+	}
+	return dst
+}
+
+// isWebP reports whether data is a WebP image
+func isWebP(data []byte) bool {
+	return len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP"
+}
+
+// formatSize formats a size in bytes for display
+func formatSize(n int) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1f MB", float64(n)/1_000_000)
+	case n >= 1_000:
+		return fmt.Sprintf("%d KB", n/1_000)
+	default:
+		return fmt.Sprintf("%d bytes", n)
+	}
+}
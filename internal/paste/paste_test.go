@@ -0,0 +1,113 @@
+package paste
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodePNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.SetRGBA(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestFit(t *testing.T) {
+	small := encodePNG(t, 800, 600)
+	attachment, err := Fit(small)
+	require.NoError(t, err)
+	assert.Equal(t, small, attachment.Image.Data, "images within the limits are kept as they are")
+	assert.Equal(t, "image/png", attachment.Image.MediaType)
+	assert.Equal(t, "800×600 PNG, "+formatSize(len(small)), attachment.String())
+
+	attachment, err = Fit(encodePNG(t, 3136, 1000))
+	require.NoError(t, err)
+	assert.Equal(t, 1568, attachment.Width)
+	assert.Equal(t, 500, attachment.Height)
+	assert.Equal(t, image.Point{X: 3136, Y: 1000}, attachment.Original)
+	assert.Contains(t, attachment.String(), ", downscaled from 3136×1000")
+	config, format, err := image.DecodeConfig(bytes.NewReader(attachment.Image.Data))
+	require.NoError(t, err)
+	assert.Equal(t, "image/"+format, attachment.Image.MediaType)
+	assert.Equal(t, 1568, config.Width)
+	assert.LessOrEqual(t, len(attachment.Image.Data), MaxBytes)
+
+	webp := append([]byte("RIFF\x00\x00\x00\x00WEBPVP8 "), make([]byte, 16)...)
+	attachment, err = Fit(webp)
+	require.NoError(t, err)
+	assert.Equal(t, "image/webp", attachment.Image.MediaType)
+	assert.Equal(t, "WEBP, 32 bytes", attachment.String())
+
+	_, err = Fit([]byte("not an image"))
+	assert.ErrorContains(t, err, "not a PNG, JPEG, GIF or WebP image")
+}
+
+func TestFitDimensions(t *testing.T) {
+	tests := []struct {
+		width, height, wantWidth, wantHeight int
+	}{
+		{1000, 800, 1000, 800},
+		{3000, 1500, 1568, 784},
+		{1000, 4000, 392, 1568},
+		{10000, 1, 1568, 1},
+	}
+	for _, tt := range tests {
+		width, height := fitDimensions(tt.width, tt.height, MaxDimension)
+		assert.Equal(t, tt.wantWidth, width)
+		assert.Equal(t, tt.wantHeight, height)
+	}
+}
+
+func TestDownscale(t *testing.T) {
+	// Black and white columns average to grey
+	src := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for y := range 2 {
+		for x := range 4 {
+			if x%2 == 0 {
+				src.SetRGBA(x, y, color.RGBA{A: 255})
+			} else {
+				src.SetRGBA(x, y, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+			}
+		}
+	}
+	dst := downscale(src, 2, 1)
+	assert.Equal(t, image.Rect(0, 0, 2, 1), dst.Bounds())
+	assert.Equal(t, color.RGBA{R: 127, G: 127, B: 127, A: 255}, dst.RGBAAt(0, 0))
+	assert.Equal(t, color.RGBA{R: 127, G: 127, B: 127, A: 255}, dst.RGBAAt(1, 0))
+}
+
+func TestDecodeAppleScriptData(t *testing.T) {
+	assert.Equal(t, []byte("\x89PNG"), decodeAppleScriptData([]byte("«data PNGf89504E47»\n")))
+	assert.Nil(t, decodeAppleScriptData([]byte("")))
+	assert.Nil(t, decodeAppleScriptData([]byte("«data PNGfXYZ»")))
+}
+
+func TestFromFile(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	path := filepath.Join(dir, "Screen Shot.png")
+	require.NoError(t, os.WriteFile(path, []byte("png"), 0644))
+
+	for _, pasted := range []string{path, "'" + path + "'", filepath.Join(dir, `Screen\ Shot.png`) + " ", "~/Screen Shot.png"} {
+		data, err := FromFile(pasted)
+		require.NoError(t, err, pasted)
+		assert.Equal(t, []byte("png"), data)
+	}
+
+	_, err := FromFile(filepath.Join(dir, "missing.png"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,177 @@
+// Package persona defines named profiles, such as "strict-reviewer", that
+// change how the agent answers: instructions added to its system prompt and
+// generation options. Besides the built-in personas, they are defined in
+// ~/.rigel/personas.yaml and in the project's .rigel/personas.yaml, and the
+// one chosen with /persona is remembered per project in .rigel/persona.
+package persona
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Default is the persona that changes nothing
+const Default = "default"
+
+// ProjectFile defines the project's personas and SavedPath remembers the one
+// chosen, relative to the project root
+var (
+	ProjectFile = filepath.Join(".rigel", "personas.yaml")
+	SavedPath   = filepath.Join(".rigel", "persona")
+)
+
+// Persona is a named profile for the agent. Zero options keep the agent's
+// defaults.
+type Persona struct {
+	Name        string   `yaml:"-"`
+	Description string   `yaml:"description"`
+	Prompt      string   `yaml:"prompt"`      // Added to the system prompt
+	Temperature *float32 `yaml:"temperature"` // Nil keeps the default
+	MaxTokens   int      `yaml:"max_tokens"`
+	TopP        float32  `yaml:"top_p"`
+}
+
+func temperature(t float32) *float32 {
+	return &t
+}
+
+// builtins are the personas every project has; files may redefine them
+func builtins() map[string]*Persona {
+	return map[string]*Persona{
+		Default: {
+			Description: "rigel's usual behavior",
+		},
+		"strict-reviewer": {
+			Description: "Direct, critical code review",
+			Prompt: "Act as a strict code reviewer. Point out bugs, unclear names, missing error handling and missing tests, " +
+				"most serious first, citing the code concerned. Be direct and specific, and don't praise.",
+			Temperature: temperature(0.2),
+		},
+		"explainer": {
+			Description: "Patient explanations for newcomers to the code",
+			Prompt: "Explain for someone new to this codebase. Start with the big picture, define the terms you use, " +
+				"and walk through the code step by step with short examples.",
+			Temperature: temperature(0.5),
+		},
+	}
+}
+
+// UserFile returns the file defining the user's personas
+func UserFile() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".rigel", "personas.yaml"), nil
+}
+
+// Load returns the personas by name: the built-in ones, then those of the
+// user's file and of the project's file, each replacing personas of the same
+// name. Missing files define none.
+func Load() (map[string]*Persona, error) {
+	personas := builtins()
+	files := []string{ProjectFile}
+	if userFile, err := UserFile(); err == nil {
+		files = []string{userFile, ProjectFile}
+	}
+	for _, path := range files {
+		if err := loadFile(path, personas); err != nil {
+			return nil, err
+		}
+	}
+	for name, p := range personas {
+		p.Name = name
+	}
+	return personas, nil
+}
+
+// loadFile adds the personas a YAML file maps by name
+func loadFile(path string, personas map[string]*Persona) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var defined map[string]*Persona
+	if err := yaml.Unmarshal(data, &defined); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for name, p := range defined {
+		if p == nil {
+			p = &Persona{}
+		}
+		personas[name] = p
+	}
+	return nil
+}
+
+// Names returns the names of personas, sorted
+func Names(personas map[string]*Persona) []string {
+	names := make([]string, 0, len(personas))
+	for name := range personas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Find returns the persona with the given name
+func Find(name string) (*Persona, error) {
+	personas, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	p, ok := personas[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown persona: %s (available: %s)", name, strings.Join(Names(personas), ", "))
+	}
+	return p, nil
+}
+
+// Current returns the persona chosen in the project with /persona, else the
+// one named fallback, as set with RIGEL_PERSONA, else the default
+func Current(fallback string) (*Persona, error) {
+	name, err := Saved()
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name = fallback
+	}
+	if name == "" {
+		name = Default
+	}
+	return Find(name)
+}
+
+// Saved returns the persona last chosen in the project, or "" if none was
+func Saved() (string, error) {
+	data, err := os.ReadFile(SavedPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", SavedPath, err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Save remembers the persona chosen in the project, which is used instead
+// of RIGEL_PERSONA in later sessions
+func Save(name string) error {
+	if err := os.MkdirAll(filepath.Dir(SavedPath), 0755); err != nil {
+		return fmt.Errorf("failed to create .rigel directory: %w", err)
+	}
+	if err := os.WriteFile(SavedPath, []byte(name+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", SavedPath, err)
+	}
+	return nil
+}
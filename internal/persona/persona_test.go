@@ -0,0 +1,82 @@
+package persona
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Chdir(t.TempDir())
+
+	personas, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"default", "explainer", "strict-reviewer"}, Names(personas))
+	assert.Empty(t, personas[Default].Prompt)
+	assert.Equal(t, "strict-reviewer", personas["strict-reviewer"].Name)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(home, ".rigel"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(home, ".rigel", "personas.yaml"), []byte(`
+terse:
+  description: Short answers
+  prompt: Answer in at most three sentences.
+  temperature: 0.3
+  max_tokens: 300
+explainer:
+  prompt: Explain like I'm five.
+`), 0644))
+	require.NoError(t, os.MkdirAll(".rigel", 0755))
+	require.NoError(t, os.WriteFile(ProjectFile, []byte(`
+terse:
+  prompt: Answer in one sentence.
+`), 0644))
+
+	personas, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"default", "explainer", "strict-reviewer", "terse"}, Names(personas))
+	assert.Equal(t, "Explain like I'm five.", personas["explainer"].Prompt)
+	assert.Equal(t, "Answer in one sentence.", personas["terse"].Prompt, "the project's personas replace the user's")
+	assert.Nil(t, personas["terse"].Temperature)
+
+	require.NoError(t, os.WriteFile(ProjectFile, []byte("terse: [oops"), 0644))
+	_, err = Load()
+	assert.ErrorContains(t, err, "failed to parse")
+}
+
+func TestCurrent(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Chdir(t.TempDir())
+
+	p, err := Current("")
+	require.NoError(t, err)
+	assert.Equal(t, Default, p.Name)
+
+	p, err = Current("explainer")
+	require.NoError(t, err)
+	assert.Equal(t, "explainer", p.Name)
+	require.NotNil(t, p.Temperature)
+	assert.InDelta(t, 0.5, *p.Temperature, 0.001)
+
+	require.NoError(t, Save("strict-reviewer"))
+	saved, err := Saved()
+	require.NoError(t, err)
+	assert.Equal(t, "strict-reviewer", saved)
+	p, err = Current("explainer")
+	require.NoError(t, err)
+	assert.Equal(t, "strict-reviewer", p.Name, "the persona chosen in the project wins")
+
+	require.NoError(t, Save(Default))
+	p, err = Current("explainer")
+	require.NoError(t, err)
+	assert.Equal(t, Default, p.Name)
+
+	_, err = Current("pirate")
+	require.NoError(t, err, "the saved persona is used")
+	_, err = Find("pirate")
+	assert.ErrorContains(t, err, "unknown persona: pirate (available: default, explainer, strict-reviewer)")
+}
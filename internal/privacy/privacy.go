@@ -0,0 +1,160 @@
+// Package privacy protects what rigel writes to disk: it masks strings that
+// look like API keys or tokens, and encrypts the input history and saved
+// sessions with AES-GCM under a key kept in the OS keychain.
+package privacy
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/mizzy/rigel/internal/credentials"
+	"github.com/mizzy/rigel/internal/logging"
+)
+
+const (
+	keyName = "encryption-key" // Keychain entry holding the key
+	keySize = 32               // AES-256
+
+	// Marks encrypted data, which is base64 so that it fits on one line of
+	// the history file
+	sealedPrefix = "rigel-sealed:v1:"
+)
+
+var (
+	mu      sync.Mutex
+	redact  bool
+	encrypt bool
+	aead    cipher.AEAD // Loaded from the keychain when first needed
+)
+
+// Configure sets whether secrets are masked before data is saved, and
+// whether the history and sessions are encrypted. The encryption key is
+// created in the keychain the first time it is needed.
+func Configure(redactSecrets, encryptFiles bool) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	redact = redactSecrets
+	encrypt = false
+	if !encryptFiles {
+		return nil
+	}
+	if err := loadKey(true); err != nil {
+		return err
+	}
+	encrypt = true
+	return nil
+}
+
+// Redact masks strings that look like API keys or tokens, if redaction is
+// configured
+func Redact(s string) string {
+	mu.Lock()
+	enabled := redact
+	mu.Unlock()
+
+	if !enabled {
+		return s
+	}
+	return logging.Redact(s)
+}
+
+// Seal encrypts data if encryption is configured, and returns it unchanged
+// otherwise
+func Seal(data []byte) ([]byte, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if !encrypt {
+		return data, nil
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to encrypt: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, data, nil)
+
+	out := make([]byte, len(sealedPrefix)+base64.StdEncoding.EncodedLen(len(sealed)))
+	copy(out, sealedPrefix)
+	base64.StdEncoding.Encode(out[len(sealedPrefix):], sealed)
+	return out, nil
+}
+
+// Open decrypts data written by Seal. Data that isn't encrypted is returned
+// unchanged, so files written before encryption was turned on, or after it
+// was turned off, stay readable.
+func Open(data []byte) ([]byte, error) {
+	if !IsSealed(data) {
+		return data, nil
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := loadKey(false); err != nil {
+		return nil, err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(string(data[len(sealedPrefix):]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("failed to decrypt: data too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return plain, nil
+}
+
+// IsSealed reports whether data was encrypted by Seal
+func IsSealed(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(sealedPrefix))
+}
+
+// loadKey reads the key from the keychain, creating one if asked to. The
+// caller holds mu.
+func loadKey(create bool) error {
+	if aead != nil {
+		return nil
+	}
+
+	store := credentials.Keychain()
+	var key []byte
+	encoded, err := store.Get(keyName)
+	switch {
+	case err == nil:
+		key, err = base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != keySize {
+			return errors.New("invalid encryption key in the keychain")
+		}
+	case errors.Is(err, credentials.ErrNotFound) && create:
+		key = make([]byte, keySize)
+		if _, err := rand.Read(key); err != nil {
+			return fmt.Errorf("failed to create encryption key: %w", err)
+		}
+		if err := store.Set(keyName, base64.StdEncoding.EncodeToString(key)); err != nil {
+			return fmt.Errorf("failed to store encryption key: %w", err)
+		}
+	default:
+		return fmt.Errorf("failed to read encryption key: %w", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return fmt.Errorf("invalid encryption key: %w", err)
+	}
+	aead, err = cipher.NewGCM(block)
+	if err != nil {
+		return fmt.Errorf("invalid encryption key: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package privacy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zalando/go-keyring"
+)
+
+// reset forgets the configuration and the loaded key
+func reset(t *testing.T) {
+	t.Cleanup(func() {
+		redact, encrypt, aead = false, false, nil
+	})
+	redact, encrypt, aead = false, false, nil
+}
+
+func TestRedact(t *testing.T) {
+	reset(t)
+	const prompt = "use sk-ant-api03-abcdefghijkl to call the API"
+
+	assert.Equal(t, prompt, Redact(prompt))
+
+	require.NoError(t, Configure(true, false))
+	assert.Equal(t, "use [REDACTED] to call the API", Redact(prompt))
+}
+
+func TestSealAndOpen(t *testing.T) {
+	reset(t)
+	keyring.MockInit()
+	plain := []byte(`{"command":"hello"}`)
+
+	// Without encryption data is written as is
+	data, err := Seal(plain)
+	require.NoError(t, err)
+	assert.Equal(t, plain, data)
+
+	require.NoError(t, Configure(false, true))
+	data, err = Seal(plain)
+	require.NoError(t, err)
+	assert.True(t, IsSealed(data))
+	assert.NotContains(t, string(data), "hello")
+	assert.NotContains(t, string(data), "\n")
+
+	opened, err := Open(data)
+	require.NoError(t, err)
+	assert.Equal(t, plain, opened)
+
+	// Plain data stays readable
+	opened, err = Open(plain)
+	require.NoError(t, err)
+	assert.Equal(t, plain, opened)
+
+	// The key is kept in the keychain, so another run can read the data
+	aead = nil
+	require.NoError(t, Configure(false, false))
+	opened, err = Open(data)
+	require.NoError(t, err)
+	assert.Equal(t, plain, opened)
+
+	// Tampered data is rejected
+	tampered := append([]byte(nil), data...)
+	tampered[len(tampered)-2] ^= 1
+	_, err = Open(tampered)
+	assert.Error(t, err)
+}
+
+func TestOpenWithoutKey(t *testing.T) {
+	reset(t)
+	keyring.MockInit()
+	require.NoError(t, Configure(false, true))
+	data, err := Seal([]byte("secret"))
+	require.NoError(t, err)
+
+	keyring.MockInit()
+	aead = nil
+	_, err = Open(data)
+	assert.ErrorContains(t, err, "encryption key")
+}
@@ -0,0 +1,97 @@
+// Package recovery restores the terminal and records diagnostics when rigel
+// panics, and saves the state when it is terminated by a signal, so neither
+// a crash nor a closed terminal window leaves the user's shell in raw mode.
+package recovery
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime/debug"
+
+	"golang.org/x/term"
+
+	"github.com/mizzy/rigel/internal/logging"
+)
+
+// resetSequence shows the cursor and resets text attributes
+const resetSequence = "\033[?25h\033[0m"
+
+// Terminal holds the terminal state captured before the UI changed it
+type Terminal struct {
+	fd    int
+	state *term.State
+}
+
+// SaveTerminal captures the current state of stdin if it is a terminal
+func SaveTerminal() *Terminal {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return &Terminal{fd: -1}
+	}
+
+	state, err := term.GetState(fd)
+	if err != nil {
+		return &Terminal{fd: -1}
+	}
+	return &Terminal{fd: fd, state: state}
+}
+
+// Restore puts the terminal back into the captured state
+func (t *Terminal) Restore() {
+	if t.state != nil {
+		_ = term.Restore(t.fd, t.state)
+	}
+	fmt.Fprint(os.Stdout, resetSequence)
+}
+
+// LogPanic writes a panic value and the current stack trace to the log
+func LogPanic(r any) {
+	slog.Error("panic", "value", fmt.Sprint(r), "stack", string(debug.Stack()))
+}
+
+// LogAndRepanic logs a panic in progress and re-raises it, for use in code
+// where another layer (such as Bubbletea) restores the terminal.
+// Use as: defer recovery.LogAndRepanic()
+func LogAndRepanic() {
+	if r := recover(); r != nil {
+		LogPanic(r)
+		panic(r)
+	}
+}
+
+// Guard returns a function to defer around the interactive UI. On panic it
+// restores the terminal, logs the stack trace, runs onPanic (e.g. to save the
+// conversation) and exits with a short message instead of a raw stack dump.
+// Use as: defer recovery.Guard(onPanic)()
+func Guard(onPanic func()) func() {
+	terminal := SaveTerminal()
+
+	return func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+
+		terminal.Restore()
+		LogPanic(r)
+		if onPanic != nil {
+			func() {
+				// A failing snapshot must not hide the original crash
+				defer func() { _ = recover() }()
+				onPanic()
+			}()
+		}
+
+		Report(fmt.Sprint(r))
+		os.Exit(2)
+	}
+}
+
+// Report tells the user rigel crashed and where to find the details
+func Report(reason string) {
+	fmt.Fprintf(os.Stderr, "\nrigel crashed: %s\n", reason)
+	if path, err := logging.Path(); err == nil {
+		fmt.Fprintf(os.Stderr, "The stack trace was written to %s\n", path)
+	}
+}
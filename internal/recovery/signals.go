@@ -0,0 +1,79 @@
+package recovery
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// saveTimeout bounds how long saving the state may take on termination
+// before rigel exits anyway
+const saveTimeout = 5 * time.Second
+
+// terminateSignals end rigel: SIGTERM as sent by kill, and SIGHUP as sent
+// when the terminal window is closed
+var terminateSignals = []os.Signal{syscall.SIGTERM, syscall.SIGHUP}
+
+// OnTerminate returns a function to defer around the interactive UI. Until
+// it is called, SIGTERM and SIGHUP end rigel cleanly: stop, if not nil,
+// shuts the UI down, the terminal is restored, save writes the state that
+// would be lost (e.g. the history and the conversation), and rigel exits
+// with the status of a process killed by the signal. A second signal exits
+// at once.
+// Use as: defer recovery.OnTerminate(stop, save)()
+func OnTerminate(stop, save func()) func() {
+	terminal := SaveTerminal()
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, terminateSignals...)
+	done := make(chan struct{})
+
+	go func() {
+		var sig os.Signal
+		select {
+		case sig = <-signals:
+		case <-done:
+			return
+		}
+		slog.Info("terminating", "signal", sig.String())
+
+		saved := make(chan struct{})
+		go func() {
+			defer close(saved)
+			// A failing save must not keep rigel from exiting
+			defer func() {
+				if r := recover(); r != nil {
+					LogPanic(r)
+				}
+			}()
+			if stop != nil {
+				stop()
+			}
+			terminal.Restore()
+			save()
+		}()
+
+		select {
+		case <-saved:
+		case <-signals:
+			terminal.Restore()
+		case <-time.After(saveTimeout):
+			slog.Error("saving the state on termination timed out")
+		}
+		os.Exit(exitStatus(sig))
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// exitStatus returns the status of a process killed by sig
+func exitStatus(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+	return 1
+}
@@ -0,0 +1,391 @@
+// Package rpc implements a headless JSON-RPC 2.0 protocol over stdin/stdout so
+// editor plugins can drive rigel. Messages are newline-delimited JSON objects.
+package rpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mizzy/rigel/internal/agent"
+	"github.com/mizzy/rigel/internal/llm"
+	"github.com/mizzy/rigel/internal/tools"
+	"github.com/mizzy/rigel/internal/version"
+	"github.com/mizzy/rigel/internal/workspace"
+)
+
+// Method names understood by the server
+const (
+	MethodInitialize    = "initialize"
+	MethodPrompt        = "prompt"
+	MethodCancel        = "cancel"
+	MethodContextUpdate = "context/update"
+	MethodShutdown      = "shutdown"
+
+	// NotificationChunk is sent for every streamed piece of a prompt response
+	NotificationChunk = "response/chunk"
+)
+
+// Standard JSON-RPC error codes plus the LSP request-cancelled code
+const (
+	CodeParseError       = -32700
+	CodeInvalidRequest   = -32600
+	CodeMethodNotFound   = -32601
+	CodeInvalidParams    = -32602
+	CodeInternalError    = -32603
+	CodeRequestCancelled = -32800
+)
+
+// Request is an incoming JSON-RPC request or notification
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// Response is an outgoing JSON-RPC response
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Notification is an outgoing JSON-RPC notification
+type Notification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+// Error is a JSON-RPC error object
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// PromptParams are the parameters of the prompt method
+type PromptParams struct {
+	Prompt string `json:"prompt"`
+	// Agent runs the prompt through the agent (with tools) instead of streaming plain chat
+	Agent bool `json:"agent,omitempty"`
+}
+
+// PromptResult is the result of the prompt method
+type PromptResult struct {
+	Content string `json:"content"`
+}
+
+// ChunkParams are the parameters of the response/chunk notification
+type ChunkParams struct {
+	ID      json.RawMessage `json:"id"`
+	Content string          `json:"content"`
+}
+
+// CancelParams are the parameters of the cancel method
+type CancelParams struct {
+	ID json.RawMessage `json:"id"`
+}
+
+// FileContext is a file the editor wants included in prompts
+type FileContext struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+// ContextUpdateParams are the parameters of the context/update method.
+// Files with empty content are removed from the context.
+type ContextUpdateParams struct {
+	Files []FileContext `json:"files"`
+}
+
+// Server serves JSON-RPC requests from a reader and writes replies to a writer
+type Server struct {
+	provider llm.Provider
+	in       io.Reader
+	out      io.Writer
+	outMu    sync.Mutex
+
+	agent    *agent.Agent
+	agentMu  sync.Mutex
+	fileTool *tools.FileTool
+
+	mu       sync.Mutex
+	files    map[string]string
+	inflight map[string]context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// NewServer creates a new stdio JSON-RPC server
+func NewServer(provider llm.Provider, in io.Reader, out io.Writer) *Server {
+	rpcAgent := agent.New(provider)
+	fileTool := tools.NewFileTool()
+	rpcAgent.RegisterTool(fileTool)
+	// Console progress would corrupt the protocol stream on stdout
+	rpcAgent.SetProgressDisplay(agent.NewUIProgressDisplay())
+
+	return &Server{
+		provider: provider,
+		in:       in,
+		out:      out,
+		agent:    rpcAgent,
+		fileTool: fileTool,
+		files:    make(map[string]string),
+		inflight: make(map[string]context.CancelFunc),
+	}
+}
+
+// SetWorkspace confines the agent's file tool to ws, e.g. a read-only one
+// in a directory that isn't trusted
+func (s *Server) SetWorkspace(ws *workspace.Workspace) {
+	s.fileTool.SetWorkspace(ws)
+}
+
+// Serve reads requests until EOF, a shutdown request, or ctx cancellation,
+// then cancels the prompts still running and waits for them to end
+func (s *Server) Serve(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer func() {
+		cancel()
+		s.wg.Wait()
+	}()
+
+	scanner := bufio.NewScanner(s.in)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var req Request
+		if err := json.Unmarshal([]byte(line), &req); err != nil {
+			s.writeError(nil, CodeParseError, fmt.Sprintf("parse error: %v", err))
+			continue
+		}
+
+		if req.Method == MethodShutdown {
+			s.writeResult(req.ID, map[string]bool{"ok": true})
+			return nil
+		}
+
+		s.dispatch(ctx, req)
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+
+	return scanner.Err()
+}
+
+// dispatch routes a request to its handler
+func (s *Server) dispatch(ctx context.Context, req Request) {
+	switch req.Method {
+	case MethodInitialize:
+		s.writeResult(req.ID, map[string]string{
+			"name":     "rigel",
+			"version":  version.Short(),
+			"provider": s.provider.GetName(),
+			"model":    s.provider.GetCurrentModel().Name,
+		})
+
+	case MethodPrompt:
+		var params PromptParams
+		if err := json.Unmarshal(req.Params, &params); err != nil || strings.TrimSpace(params.Prompt) == "" {
+			s.writeError(req.ID, CodeInvalidParams, "prompt is required")
+			return
+		}
+
+		// Cancel requests find prompts by ID, so each needs its own
+		if req.ID == nil {
+			s.writeError(nil, CodeInvalidRequest, "prompt requires an id")
+			return
+		}
+		promptCtx, cancel := context.WithCancel(ctx)
+		key := string(req.ID)
+		s.mu.Lock()
+		_, running := s.inflight[key]
+		if !running {
+			s.inflight[key] = cancel
+		}
+		s.mu.Unlock()
+		if running {
+			cancel()
+			s.writeError(req.ID, CodeInvalidRequest, fmt.Sprintf("prompt %s is already running", key))
+			return
+		}
+
+		// Prompts run concurrently so cancel requests can be processed meanwhile
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer func() {
+				s.mu.Lock()
+				delete(s.inflight, key)
+				s.mu.Unlock()
+				cancel()
+			}()
+			s.handlePrompt(promptCtx, req.ID, params)
+		}()
+
+	case MethodCancel:
+		var params CancelParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			s.writeError(req.ID, CodeInvalidParams, "id is required")
+			return
+		}
+		s.mu.Lock()
+		cancel, ok := s.inflight[string(params.ID)]
+		s.mu.Unlock()
+		if ok {
+			cancel()
+		}
+		if req.ID != nil {
+			s.writeResult(req.ID, map[string]bool{"cancelled": ok})
+		}
+
+	case MethodContextUpdate:
+		var params ContextUpdateParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			s.writeError(req.ID, CodeInvalidParams, "files are required")
+			return
+		}
+		s.mu.Lock()
+		for _, file := range params.Files {
+			if file.Content == "" {
+				delete(s.files, file.Path)
+			} else {
+				s.files[file.Path] = file.Content
+			}
+		}
+		count := len(s.files)
+		s.mu.Unlock()
+		if req.ID != nil {
+			s.writeResult(req.ID, map[string]int{"files": count})
+		}
+
+	default:
+		if req.ID != nil {
+			s.writeError(req.ID, CodeMethodNotFound, fmt.Sprintf("method not found: %s", req.Method))
+		}
+	}
+}
+
+// handlePrompt answers a prompt, streaming chunks as notifications
+func (s *Server) handlePrompt(ctx context.Context, id json.RawMessage, params PromptParams) {
+	prompt := s.buildPrompt(params.Prompt)
+
+	if params.Agent {
+		s.agentMu.Lock()
+		content, err := s.agent.Execute(ctx, prompt)
+		s.agentMu.Unlock()
+		if err != nil {
+			s.writePromptError(ctx, id, err)
+			return
+		}
+		s.writeNotification(NotificationChunk, ChunkParams{ID: id, Content: content})
+		s.writeResult(id, PromptResult{Content: content})
+		return
+	}
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	ch, err := s.provider.Stream(streamCtx, prompt)
+	if err != nil {
+		cancel()
+		s.writePromptError(ctx, id, err)
+		return
+	}
+	// Stop the provider when the answer ends early, and let it finish
+	// sending
+	defer func() {
+		cancel()
+		llm.Drain(ch)
+	}()
+
+	var content strings.Builder
+	for chunk := range ch {
+		if chunk.Error != nil {
+			s.writePromptError(ctx, id, chunk.Error)
+			return
+		}
+		if chunk.Content != "" {
+			content.WriteString(chunk.Content)
+			s.writeNotification(NotificationChunk, ChunkParams{ID: id, Content: chunk.Content})
+		}
+		if chunk.Done {
+			break
+		}
+	}
+
+	if ctx.Err() != nil {
+		s.writeError(id, CodeRequestCancelled, "request cancelled")
+		return
+	}
+	s.writeResult(id, PromptResult{Content: content.String()})
+}
+
+// buildPrompt prepends the editor-provided file context to the prompt
+func (s *Server) buildPrompt(prompt string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.files) == 0 {
+		return prompt
+	}
+
+	paths := make([]string, 0, len(s.files))
+	for path := range s.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var sb strings.Builder
+	sb.WriteString("Files open in the editor:\n\n")
+	for _, path := range paths {
+		sb.WriteString(fmt.Sprintf("--- %s ---\n%s\n\n", path, s.files[path]))
+	}
+	sb.WriteString(prompt)
+	return sb.String()
+}
+
+func (s *Server) writePromptError(ctx context.Context, id json.RawMessage, err error) {
+	if ctx.Err() != nil {
+		s.writeError(id, CodeRequestCancelled, "request cancelled")
+		return
+	}
+	s.writeError(id, CodeInternalError, err.Error())
+}
+
+func (s *Server) writeResult(id json.RawMessage, result interface{}) {
+	s.write(Response{JSONRPC: "2.0", ID: id, Result: result})
+}
+
+func (s *Server) writeError(id json.RawMessage, code int, message string) {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	s.write(Response{JSONRPC: "2.0", ID: id, Error: &Error{Code: code, Message: message}})
+}
+
+func (s *Server) writeNotification(method string, params interface{}) {
+	s.write(Notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func (s *Server) write(v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	s.out.Write(append(data, '\n'))
+}
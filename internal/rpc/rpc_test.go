@@ -0,0 +1,197 @@
+package rpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mizzy/rigel/internal/llm"
+	"github.com/mizzy/rigel/internal/llm/llmtest"
+)
+
+// serveLines sends lines to a server and returns the messages it writes.
+// Its input is closed, which cancels the prompts still running, once n
+// messages were written.
+func serveLines(t *testing.T, provider llm.Provider, n int, lines ...string) []map[string]interface{} {
+	t.Helper()
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- NewServer(provider, inR, outW).Serve(context.Background())
+		outW.Close()
+	}()
+	go io.WriteString(inW, strings.Join(lines, "\n")+"\n")
+
+	var messages []map[string]interface{}
+	scanner := bufio.NewScanner(outR)
+	for scanner.Scan() {
+		var msg map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &msg))
+		messages = append(messages, msg)
+		if len(messages) == n {
+			inW.Close()
+		}
+	}
+	require.NoError(t, <-done)
+	return messages
+}
+
+func TestInitialize(t *testing.T) {
+	messages := serveLines(t, &llmtest.Provider{}, 1, `{"jsonrpc":"2.0","id":1,"method":"initialize"}`)
+
+	require.Len(t, messages, 1)
+	result := messages[0]["result"].(map[string]interface{})
+	assert.Equal(t, "rigel", result["name"])
+	assert.Equal(t, "fake-model", result["model"])
+}
+
+func TestPromptStreamsChunks(t *testing.T) {
+	provider := &llmtest.Provider{Chunks: []string{"Hel", "lo"}}
+	messages := serveLines(t, provider, 3,
+		`{"jsonrpc":"2.0","method":"context/update","params":{"files":[{"path":"main.go","content":"package main"}]}}`,
+		`{"jsonrpc":"2.0","id":"p1","method":"prompt","params":{"prompt":"explain"}}`,
+	)
+
+	require.Len(t, messages, 3)
+	assert.Equal(t, NotificationChunk, messages[0]["method"])
+	assert.Equal(t, NotificationChunk, messages[1]["method"])
+	result := messages[2]["result"].(map[string]interface{})
+	assert.Equal(t, "Hello", result["content"])
+
+	assert.Contains(t, provider.Prompt(), "--- main.go ---")
+	assert.True(t, strings.HasSuffix(provider.Prompt(), "explain"))
+}
+
+func TestAgentPrompt(t *testing.T) {
+	messages := serveLines(t, &llmtest.Provider{Response: "agent answer"}, 2,
+		`{"jsonrpc":"2.0","id":7,"method":"prompt","params":{"prompt":"hello","agent":true}}`,
+	)
+
+	require.Len(t, messages, 2)
+	result := messages[1]["result"].(map[string]interface{})
+	assert.Equal(t, "agent answer", result["content"])
+}
+
+func TestErrors(t *testing.T) {
+	messages := serveLines(t, &llmtest.Provider{}, 3,
+		`not json`,
+		`{"jsonrpc":"2.0","id":2,"method":"unknown"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"prompt","params":{"prompt":""}}`,
+	)
+
+	require.Len(t, messages, 3)
+	codes := []float64{CodeParseError, CodeMethodNotFound, CodeInvalidParams}
+	for i, msg := range messages {
+		rpcErr := msg["error"].(map[string]interface{})
+		assert.Equal(t, codes[i], rpcErr["code"])
+	}
+}
+
+func TestPromptIDs(t *testing.T) {
+	messages := serveLines(t, &llmtest.Provider{Block: true}, 2,
+		`{"jsonrpc":"2.0","method":"prompt","params":{"prompt":"no id"}}`,
+		`{"jsonrpc":"2.0","id":1,"method":"prompt","params":{"prompt":"slow"}}`,
+		`{"jsonrpc":"2.0","id":1,"method":"prompt","params":{"prompt":"same id"}}`,
+	)
+
+	require.Len(t, messages, 3)
+	ids := []interface{}{nil, float64(1), float64(1)}
+	codes := []float64{CodeInvalidRequest, CodeInvalidRequest, CodeRequestCancelled}
+	for i, msg := range messages {
+		assert.Equal(t, ids[i], msg["id"])
+		rpcErr := msg["error"].(map[string]interface{})
+		assert.Equal(t, codes[i], rpcErr["code"])
+	}
+}
+
+// failingProvider streams an error, then more content nobody asked for
+type failingProvider struct {
+	llmtest.Provider
+	sent chan struct{}
+}
+
+func (f *failingProvider) Stream(ctx context.Context, prompt string) (<-chan llm.StreamResponse, error) {
+	ch := make(chan llm.StreamResponse)
+	go func() {
+		defer close(f.sent)
+		defer close(ch)
+		ch <- llm.StreamResponse{Error: errors.New("overloaded")}
+		ch <- llm.StreamResponse{Content: "more"}
+	}()
+	return ch, nil
+}
+
+func TestPromptDrainsFailedStream(t *testing.T) {
+	provider := &failingProvider{sent: make(chan struct{})}
+	messages := serveLines(t, provider, 1, `{"jsonrpc":"2.0","id":1,"method":"prompt","params":{"prompt":"hi"}}`)
+
+	require.Len(t, messages, 1)
+	assert.Contains(t, messages[0]["error"].(map[string]interface{})["message"], "overloaded")
+	select {
+	case <-provider.sent:
+	case <-time.After(time.Second):
+		t.Fatal("the provider was left blocked sending")
+	}
+}
+
+func TestCancel(t *testing.T) {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+
+	server := NewServer(&llmtest.Provider{Block: true}, inR, outW)
+	done := make(chan error, 1)
+	go func() { done <- server.Serve(context.Background()) }()
+
+	reader := bufio.NewReader(outR)
+	io.WriteString(inW, `{"jsonrpc":"2.0","id":1,"method":"prompt","params":{"prompt":"slow"}}`+"\n")
+
+	// Wait until the prompt is registered as in flight before cancelling it
+	require.Eventually(t, func() bool {
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		return len(server.inflight) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	io.WriteString(inW, `{"jsonrpc":"2.0","method":"cancel","params":{"id":1}}`+"\n")
+
+	line, err := reader.ReadBytes('\n')
+	require.NoError(t, err)
+	var resp Response
+	require.NoError(t, json.Unmarshal(line, &resp))
+	require.NotNil(t, resp.Error)
+	assert.Equal(t, CodeRequestCancelled, resp.Error.Code)
+
+	inW.Close()
+	require.NoError(t, <-done)
+}
+
+func TestServeCancelsRunningPromptsOnExit(t *testing.T) {
+	inR, inW := io.Pipe()
+	server := NewServer(&llmtest.Provider{Block: true}, inR, io.Discard)
+	done := make(chan error, 1)
+	go func() { done <- server.Serve(context.Background()) }()
+
+	io.WriteString(inW, `{"jsonrpc":"2.0","id":1,"method":"prompt","params":{"prompt":"slow"}}`+"\n")
+	require.Eventually(t, func() bool {
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		return len(server.inflight) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	inW.Close()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Serve waited for the running prompt instead of cancelling it")
+	}
+}
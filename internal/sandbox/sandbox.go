@@ -6,6 +6,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strings"
 )
 
 const sandboxEnvVar = "RIGEL_SANDBOXED"
@@ -16,7 +17,8 @@ func IsSandboxed() bool {
 }
 
 // EnableSandbox re-executes the current process in a sandboxed environment
-func EnableSandbox(sandboxDir string) error {
+// that allows writes to sandboxDir and any extraDirs
+func EnableSandbox(sandboxDir string, extraDirs ...string) error {
 	// Only support macOS for now
 	if runtime.GOOS != "darwin" {
 		return fmt.Errorf("sandbox mode is currently only supported on macOS")
@@ -27,23 +29,27 @@ func EnableSandbox(sandboxDir string) error {
 		return nil
 	}
 
-	// Get absolute path of sandbox directory
-	absDir, err := filepath.Abs(sandboxDir)
-	if err != nil {
-		return fmt.Errorf("failed to get absolute path: %w", err)
-	}
+	// Get absolute paths of the writable directories
+	var absDirs []string
+	for _, dir := range append([]string{sandboxDir}, extraDirs...) {
+		absDir, err := filepath.Abs(dir)
+		if err != nil {
+			return fmt.Errorf("failed to get absolute path: %w", err)
+		}
 
-	// Verify directory exists
-	info, err := os.Stat(absDir)
-	if err != nil {
-		return fmt.Errorf("failed to stat directory: %w", err)
-	}
-	if !info.IsDir() {
-		return fmt.Errorf("path is not a directory: %s", absDir)
+		// Verify directory exists
+		info, err := os.Stat(absDir)
+		if err != nil {
+			return fmt.Errorf("failed to stat directory: %w", err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("path is not a directory: %s", absDir)
+		}
+		absDirs = append(absDirs, absDir)
 	}
 
 	// Create sandbox profile
-	profile := generateSandboxProfile(absDir)
+	profile := generateSandboxProfile(absDirs...)
 
 	// Get current executable path
 	executable, err := os.Executable()
@@ -100,30 +106,35 @@ func EnableSandbox(sandboxDir string) error {
 	return nil
 }
 
-// generateSandboxProfile creates a sandbox profile string for the given directory
-func generateSandboxProfile(sandboxDir string) string {
+// generateSandboxProfile creates a sandbox profile string allowing writes
+// to the given directories
+func generateSandboxProfile(sandboxDirs ...string) string {
 	// Get home directory for .rigel access
 	homeDir := os.Getenv("HOME")
 	if homeDir == "" {
 		homeDir = "/Users/" + os.Getenv("USER")
 	}
 
+	var subpaths strings.Builder
+	for _, dir := range sandboxDirs {
+		subpaths.WriteString(fmt.Sprintf("    (subpath %q)\n", dir))
+	}
+
 	// Create a simple but effective sandbox profile
-	// Allow reads everywhere but restrict writes to current directory and .rigel only
+	// Allow reads everywhere but restrict writes to the workspace directories and .rigel only
 	profile := fmt.Sprintf(`(version 1)
 (allow default)
 (deny file-write*
     (regex #"^/")
     (subpath "/"))
 (allow file-write*
-    (subpath "%s")
-    (subpath "%s/.rigel")
+%s    (subpath "%s/.rigel")
     (regex #"^/private/var/")
     (regex #"^/var/")
     (regex #"^/private/tmp/")
     (regex #"^/tmp/")
     (regex #"^/dev/"))
-`, sandboxDir, homeDir)
+`, subpaths.String(), homeDir)
 
 	return profile
 }
@@ -131,7 +142,7 @@ func generateSandboxProfile(sandboxDir string) string {
 // GetSandboxInfo returns information about the current sandbox status
 func GetSandboxInfo() string {
 	if IsSandboxed() {
-		return "Sandbox: ENABLED (file writes restricted to current directory)"
+		return "Sandbox: ENABLED (file writes restricted to the workspace)"
 	}
 	return "Sandbox: DISABLED (use --sandbox flag to enable)"
 }
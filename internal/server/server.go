@@ -0,0 +1,457 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mizzy/rigel/internal/agent"
+	"github.com/mizzy/rigel/internal/config"
+	"github.com/mizzy/rigel/internal/llm"
+	"github.com/mizzy/rigel/internal/tools"
+	"github.com/mizzy/rigel/internal/workspace"
+)
+
+// Server exposes the rigel agent core over HTTP
+type Server struct {
+	provider llm.Provider
+	config   *config.Config
+	tools    map[string]tools.Tool
+	token    string // Clients send it as a bearer token
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+// Session is a long-lived conversation backed by its own agent
+type Session struct {
+	SessionInfo // Guarded by the server's mu
+
+	mu    sync.Mutex
+	agent *agent.Agent
+}
+
+// SessionInfo describes a session in the session endpoints' responses
+type SessionInfo struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Turns     int       `json:"turns"`
+}
+
+// GenerateRequest is the body accepted by the generate and stream endpoints
+type GenerateRequest struct {
+	Prompt       string        `json:"prompt"`
+	Messages     []llm.Message `json:"messages,omitempty"`
+	SystemPrompt string        `json:"system_prompt,omitempty"`
+	Model        string        `json:"model,omitempty"`
+	Temperature  float32       `json:"temperature,omitempty"`
+	MaxTokens    int           `json:"max_tokens,omitempty"`
+}
+
+// messages returns the conversation with the prompt, if any, as the last
+// user message
+func (req GenerateRequest) messages() []llm.Message {
+	messages := req.Messages
+	if req.Prompt != "" {
+		messages = append(messages, llm.Message{Role: "user", Content: req.Prompt})
+	}
+	return messages
+}
+
+func (req GenerateRequest) options() llm.GenerateOptions {
+	return llm.GenerateOptions{
+		SystemPrompt: req.SystemPrompt,
+		Model:        req.Model,
+		Temperature:  req.Temperature,
+		MaxTokens:    req.MaxTokens,
+	}
+}
+
+// GenerateResponse is returned by the generate and session message endpoints
+type GenerateResponse struct {
+	Content string `json:"content"`
+	Model   string `json:"model"`
+}
+
+// ToolRequest is the body accepted by the tool execution endpoint
+type ToolRequest struct {
+	Input string `json:"input"`
+}
+
+// ToolResponse is returned by the tool execution endpoint
+type ToolResponse struct {
+	Tool     string `json:"tool"`
+	Output   string `json:"output"`
+	Duration string `json:"duration"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// New creates a new server sharing the given provider
+func New(provider llm.Provider, cfg *config.Config) *Server {
+	s := &Server{
+		provider: provider,
+		config:   cfg,
+		tools:    make(map[string]tools.Tool),
+		token:    rand.Text(),
+		sessions: make(map[string]*Session),
+	}
+
+	fileTool := tools.NewFileTool()
+	if cfg != nil {
+		fileTool.SetSizeLimits(cfg.MaxReadSize, cfg.MaxWriteSize)
+	}
+	newWorkspace := workspace.New
+	if cfg != nil && cfg.ReadOnly {
+		// A directory that isn't trusted only has its files read
+		newWorkspace = workspace.NewReadOnly
+	}
+	if ws, err := newWorkspace("."); err == nil {
+		fileTool.SetWorkspace(ws)
+	}
+	s.tools[fileTool.Name()] = fileTool
+
+	return s
+}
+
+// Token returns the token clients must send in an Authorization: Bearer
+// header, generated when the server was created
+func (s *Server) Token() string {
+	return s.token
+}
+
+// Handler returns the HTTP handler with all routes registered
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/health", s.handleHealth)
+	mux.HandleFunc("POST /v1/generate", s.handleGenerate)
+	mux.HandleFunc("POST /v1/chat/stream", s.handleStream)
+	mux.HandleFunc("GET /v1/sessions", s.handleListSessions)
+	mux.HandleFunc("POST /v1/sessions", s.handleCreateSession)
+	mux.HandleFunc("DELETE /v1/sessions/{id}", s.handleDeleteSession)
+	mux.HandleFunc("POST /v1/sessions/{id}/messages", s.handleSessionMessage)
+	mux.HandleFunc("GET /v1/tools", s.handleListTools)
+	mux.HandleFunc("POST /v1/tools/{name}", s.handleExecuteTool)
+	return s.authorize(mux)
+}
+
+// authorize admits requests bearing the server's token, except for the
+// health check. Since browsers don't send it across origins, a web page the
+// user opens can't reach the API on localhost; requiring JSON bodies also
+// keeps forms from posting to it.
+func (s *Server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && r.URL.Path == "/v1/health" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+s.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("missing or invalid bearer token"))
+			return
+		}
+		if r.Method == http.MethodPost {
+			if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+				writeError(w, http.StatusUnsupportedMediaType, fmt.Errorf("content type must be application/json"))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ListenAndServe starts serving on addr until ctx is cancelled
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return httpServer.Shutdown(shutdownCtx)
+	case err := <-errCh:
+		if err == http.ErrServerClosed {
+			return nil
+		}
+		return err
+	}
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"status":   "ok",
+		"provider": s.provider.GetName(),
+		"model":    s.provider.GetCurrentModel().Name,
+	})
+}
+
+func (s *Server) handleGenerate(w http.ResponseWriter, r *http.Request) {
+	var req GenerateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+
+	messages := req.messages()
+	if len(messages) == 0 {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("prompt or messages is required"))
+		return
+	}
+
+	content, err := s.provider.GenerateWithHistory(r.Context(), messages, req.options())
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+
+	model := req.Model
+	if model == "" {
+		model = s.provider.GetCurrentModel().Name
+	}
+	writeJSON(w, http.StatusOK, GenerateResponse{Content: content, Model: model})
+}
+
+// handleStream streams the response as server-sent events
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	var req GenerateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	messages := req.messages()
+	if len(messages) == 0 || strings.TrimSpace(messages[len(messages)-1].Content) == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("prompt or messages is required"))
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("streaming not supported"))
+		return
+	}
+
+	ch, err := s.provider.StreamWithHistory(r.Context(), messages, req.options())
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	for chunk := range ch {
+		if chunk.Error != nil {
+			writeEvent(w, "error", errorResponse{Error: chunk.Error.Error()})
+			flusher.Flush()
+			return
+		}
+		if chunk.Content != "" {
+			writeEvent(w, "chunk", map[string]string{"content": chunk.Content})
+			flusher.Flush()
+		}
+		if chunk.Done {
+			break
+		}
+	}
+
+	writeEvent(w, "done", map[string]bool{"done": true})
+	flusher.Flush()
+}
+
+func (s *Server) handleListSessions(w http.ResponseWriter, r *http.Request) {
+	// The sessions are copied under the lock that guards their turns
+	s.mu.Lock()
+	sessions := make([]SessionInfo, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessions = append(sessions, session.SessionInfo)
+	}
+	s.mu.Unlock()
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.Before(sessions[j].CreatedAt)
+	})
+
+	writeJSON(w, http.StatusOK, map[string][]SessionInfo{"sessions": sessions})
+}
+
+func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
+	info, err := s.createSession()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, info)
+}
+
+func (s *Server) handleDeleteSession(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	s.mu.Lock()
+	_, ok := s.sessions[id]
+	delete(s.sessions, id)
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("session not found: %s", id))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleSessionMessage(w http.ResponseWriter, r *http.Request) {
+	session, ok := s.getSession(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("session not found: %s", r.PathValue("id")))
+		return
+	}
+
+	var req GenerateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	// The session's agent keeps the conversation, so it takes a prompt alone
+	if strings.TrimSpace(req.Prompt) == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("prompt is required"))
+		return
+	}
+
+	// An agent keeps conversation memory, so turns within a session are serialized
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	content, err := session.agent.Execute(r.Context(), req.Prompt)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	s.mu.Lock()
+	session.Turns++
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, GenerateResponse{
+		Content: content,
+		Model:   s.provider.GetCurrentModel().Name,
+	})
+}
+
+func (s *Server) handleListTools(w http.ResponseWriter, r *http.Request) {
+	type toolInfo struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+
+	list := make([]toolInfo, 0, len(s.tools))
+	for _, tool := range s.tools {
+		list = append(list, toolInfo{Name: tool.Name(), Description: tool.Description()})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+	writeJSON(w, http.StatusOK, map[string][]toolInfo{"tools": list})
+}
+
+func (s *Server) handleExecuteTool(w http.ResponseWriter, r *http.Request) {
+	tool, ok := s.tools[r.PathValue("name")]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("tool not found: %s", r.PathValue("name")))
+		return
+	}
+
+	var req ToolRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+
+	start := time.Now()
+	output, err := tool.Execute(r.Context(), req.Input)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ToolResponse{
+		Tool:     tool.Name(),
+		Output:   output,
+		Duration: time.Since(start).Round(time.Millisecond).String(),
+	})
+}
+
+// createSession creates a new session with its own agent, returning its
+// description
+func (s *Server) createSession() (SessionInfo, error) {
+	id, err := newSessionID()
+	if err != nil {
+		return SessionInfo{}, err
+	}
+
+	sessionAgent := agent.New(s.provider)
+	for _, tool := range s.tools {
+		sessionAgent.RegisterTool(tool)
+	}
+	// Console progress would write to the server's stdout, so collect it instead
+	sessionAgent.SetProgressDisplay(agent.NewUIProgressDisplay())
+
+	info := SessionInfo{ID: id, CreatedAt: time.Now()}
+	session := &Session{SessionInfo: info, agent: sessionAgent}
+
+	s.mu.Lock()
+	s.sessions[id] = session
+	s.mu.Unlock()
+
+	return info, nil
+}
+
+func (s *Server) getSession(id string) (*Session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[id]
+	return session, ok
+}
+
+func newSessionID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate session id: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+func writeEvent(w http.ResponseWriter, event string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mizzy/rigel/internal/llm"
+	"github.com/mizzy/rigel/internal/llm/llmtest"
+)
+
+// testServer serves the API of a server with provider, returning its URL
+// and token
+func testServer(t *testing.T, provider llm.Provider) (string, string) {
+	t.Helper()
+	s := New(provider, nil)
+	srv := httptest.NewServer(s.Handler())
+	t.Cleanup(srv.Close)
+	return srv.URL, s.Token()
+}
+
+// request sends a request with the token, and a JSON body unless body is nil
+func request(t *testing.T, method, url, token string, body interface{}) *http.Response {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		require.NoError(t, err)
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, url, reader)
+	require.NoError(t, err)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return resp
+}
+
+func postJSON(t *testing.T, url, token string, body interface{}) *http.Response {
+	t.Helper()
+	return request(t, http.MethodPost, url, token, body)
+}
+
+func TestAuthorization(t *testing.T) {
+	url, token := testServer(t, &llmtest.Provider{Response: "hello"})
+
+	resp, err := http.Get(url + "/v1/health")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "the health check is open")
+
+	resp, err = http.Get(url + "/v1/tools")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = postJSON(t, url+"/v1/tools/file_operations", "guessed", ToolRequest{Input: "write x.txt hi"})
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// Bodies other than JSON, as web pages can post without a preflight,
+	// are refused
+	req, err := http.NewRequest(http.MethodPost, url+"/v1/generate", strings.NewReader(`{"prompt":"hi"}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+
+	resp = request(t, http.MethodGet, url+"/v1/tools", token, nil)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestGenerateEndpoint(t *testing.T) {
+	url, token := testServer(t, &llmtest.Provider{Response: "hello"})
+
+	t.Run("returns content", func(t *testing.T) {
+		resp := postJSON(t, url+"/v1/generate", token, GenerateRequest{Prompt: "hi"})
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var out GenerateResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+		assert.Equal(t, "hello", out.Content)
+		assert.Equal(t, "fake-model", out.Model)
+	})
+
+	t.Run("rejects empty prompt", func(t *testing.T) {
+		resp := postJSON(t, url+"/v1/generate", token, GenerateRequest{})
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestStreamEndpoint(t *testing.T) {
+	url, token := testServer(t, &llmtest.Provider{Chunks: []string{"Hel", "lo"}})
+
+	resp := postJSON(t, url+"/v1/chat/stream", token, GenerateRequest{Prompt: "hi"})
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(string(body), "event: chunk"))
+	assert.Contains(t, string(body), `"content":"Hel"`)
+	assert.Contains(t, string(body), "event: done")
+}
+
+func TestStreamEndpointWithHistory(t *testing.T) {
+	provider := &llmtest.Provider{Chunks: []string{"4"}}
+	url, token := testServer(t, provider)
+
+	history := []llm.Message{{Role: "user", Content: "2+2?"}, {Role: "assistant", Content: "4"}}
+	resp := postJSON(t, url+"/v1/chat/stream", token, GenerateRequest{Messages: history, Prompt: "and doubled?", SystemPrompt: "Answer with a number."})
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Len(t, provider.Messages(), 3)
+	assert.Equal(t, llm.Message{Role: "user", Content: "and doubled?"}, provider.Messages()[2])
+	assert.Equal(t, "Answer with a number.", provider.Options().SystemPrompt)
+
+	resp = postJSON(t, url+"/v1/chat/stream", token, GenerateRequest{})
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestSessionLifecycle(t *testing.T) {
+	url, token := testServer(t, &llmtest.Provider{Response: "agent reply"})
+
+	resp := postJSON(t, url+"/v1/sessions", token, struct{}{})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var session Session
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&session))
+	resp.Body.Close()
+	require.NotEmpty(t, session.ID)
+
+	resp = postJSON(t, url+"/v1/sessions/"+session.ID+"/messages", token, GenerateRequest{Prompt: "hello"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var out GenerateResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	resp.Body.Close()
+	assert.Equal(t, "agent reply", out.Content)
+
+	resp = request(t, http.MethodGet, url+"/v1/sessions", token, nil)
+	var list map[string][]SessionInfo
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	resp.Body.Close()
+	require.Len(t, list["sessions"], 1)
+	assert.Equal(t, 1, list["sessions"][0].Turns)
+
+	resp = request(t, http.MethodDelete, url+"/v1/sessions/"+session.ID, token, nil)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp = postJSON(t, url+"/v1/sessions/"+session.ID+"/messages", token, GenerateRequest{Prompt: "hello"})
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestToolEndpoint(t *testing.T) {
+	url, token := testServer(t, &llmtest.Provider{})
+
+	resp := postJSON(t, url+"/v1/tools/file_operations", token, ToolRequest{Input: "exists server.go"})
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var out ToolResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	assert.Contains(t, out.Output, "Exists")
+
+	resp2 := postJSON(t, url+"/v1/tools/unknown", token, ToolRequest{Input: "x"})
+	defer resp2.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp2.StatusCode)
+}
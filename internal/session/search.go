@@ -0,0 +1,155 @@
+package session
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxSnippetLength is how much of the line a search term is found on a hit
+// shows
+const maxSnippetLength = 80
+
+// Hit is an exchange of a session that has every word searched for
+type Hit struct {
+	Session  *Session
+	Exchange int    // Index of the exchange in the session
+	Snippet  string // The line the first word searched for is found on
+
+	score int
+}
+
+// posting is an exchange a word is found in, and how many times
+type posting struct {
+	session  int
+	exchange int
+	count    int
+}
+
+// Index is an inverted index of the words of the exchanges of sessions, for
+// full-text search. Exchanges of commands aren't indexed.
+type Index struct {
+	sessions []*Session
+	postings map[string][]posting
+}
+
+// NewIndex creates an empty index
+func NewIndex() *Index {
+	return &Index{postings: map[string][]posting{}}
+}
+
+// Add indexes the exchanges of a session
+func (idx *Index) Add(sess *Session) {
+	n := len(idx.sessions)
+	idx.sessions = append(idx.sessions, sess)
+	for i, ex := range sess.Exchanges {
+		if strings.HasPrefix(ex.Prompt, "/") {
+			continue
+		}
+		counts := map[string]int{}
+		for _, word := range words(ex.Prompt + "\n" + ex.Response) {
+			counts[word]++
+		}
+		for word, count := range counts {
+			idx.postings[word] = append(idx.postings[word], posting{session: n, exchange: i, count: count})
+		}
+	}
+}
+
+// Search returns the exchanges that have every word of the query, or a word
+// starting with it, the most matches first and then the most recent
+func (idx *Index) Search(query string) []Hit {
+	terms := words(query)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	type key struct{ session, exchange int }
+	var scores map[key]int
+	for _, term := range terms {
+		found := map[key]int{}
+		for word, postings := range idx.postings {
+			if !strings.HasPrefix(word, term) {
+				continue
+			}
+			for _, p := range postings {
+				found[key{p.session, p.exchange}] += p.count
+			}
+		}
+		if scores == nil {
+			scores = found
+			continue
+		}
+		// Only the exchanges with every term are kept
+		for k, score := range scores {
+			if count, ok := found[k]; ok {
+				scores[k] = score + count
+			} else {
+				delete(scores, k)
+			}
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for k, score := range scores {
+		sess := idx.sessions[k.session]
+		hits = append(hits, Hit{
+			Session:  sess,
+			Exchange: k.exchange,
+			Snippet:  snippet(sess.Exchanges[k.exchange], terms[0]),
+			score:    score,
+		})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		a, b := hits[i], hits[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if !a.Session.UpdatedAt.Equal(b.Session.UpdatedAt) {
+			return a.Session.UpdatedAt.After(b.Session.UpdatedAt)
+		}
+		return a.Exchange > b.Exchange
+	})
+	return hits
+}
+
+// words returns the lowercased words of text: runs of letters, digits and
+// underscores
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+}
+
+// snippet returns the line of an exchange term is first found on, cut to
+// maxSnippetLength around it
+func snippet(ex Exchange, term string) string {
+	for _, line := range strings.Split(ex.Prompt+"\n"+ex.Response, "\n") {
+		line = strings.TrimSpace(line)
+		lower := strings.ToLower(line)
+		at := strings.Index(lower, term)
+		if at < 0 {
+			continue
+		}
+		runes := []rune(line)
+		if len(runes) <= maxSnippetLength {
+			return line
+		}
+
+		// Start a little before the term, marking the cuts with ellipses
+		pos := utf8.RuneCountInString(lower[:at])
+		start := max(min(pos-maxSnippetLength/4, len(runes)-maxSnippetLength), 0)
+		end := start + maxSnippetLength
+		cut := slices.Clone(runes[start:end])
+		if start > 0 {
+			cut[0] = '…'
+		}
+		if end < len(runes) {
+			cut[len(cut)-1] = '…'
+		}
+		return string(cut)
+	}
+	return strings.TrimSpace(ex.Prompt)
+}
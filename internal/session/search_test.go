@@ -0,0 +1,52 @@
+package session
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexSearch(t *testing.T) {
+	now := time.Now()
+	older := &Session{ID: "older", UpdatedAt: now.Add(-time.Hour), Exchanges: []Exchange{
+		{Prompt: "How do I refactor the parser?", Response: "Split parseExpr into smaller functions."},
+		{Prompt: "/search parser", Response: "parser parser parser"},
+	}}
+	newer := &Session{ID: "newer", UpdatedAt: now, Exchanges: []Exchange{
+		{Prompt: "explain goroutines", Response: "Goroutines are lightweight threads."},
+		{Prompt: "and the parser?", Response: "The Parser reads tokens.\nIt builds the AST."},
+	}}
+	idx := NewIndex()
+	idx.Add(older)
+	idx.Add(newer)
+
+	hits := idx.Search("Parser")
+	require.Len(t, hits, 2, "commands aren't indexed")
+	assert.Equal(t, newer, hits[0].Session, "as many matches; the newer first")
+	assert.Equal(t, 1, hits[0].Exchange)
+	assert.Equal(t, "and the parser?", hits[0].Snippet)
+	assert.Equal(t, older, hits[1].Session)
+
+	hits = idx.Search("parser tokens")
+	require.Len(t, hits, 1, "every word must match")
+	assert.Equal(t, "newer", hits[0].Session.ID)
+
+	hits = idx.Search("goroutine")
+	require.Len(t, hits, 1, "words are matched by prefix")
+	assert.Equal(t, 0, hits[0].Exchange)
+
+	assert.Empty(t, idx.Search("channels"))
+	assert.Empty(t, idx.Search("  ?! "))
+}
+
+func TestSnippet(t *testing.T) {
+	line := strings.Repeat("a ", 50) + "needle" + strings.Repeat(" b", 50)
+	got := snippet(Exchange{Prompt: "find it", Response: "first line\n" + line}, "needle")
+	assert.Len(t, []rune(got), maxSnippetLength)
+	assert.Contains(t, got, "needle")
+	assert.True(t, strings.HasPrefix(got, "…"))
+	assert.True(t, strings.HasSuffix(got, "…"))
+}
@@ -0,0 +1,326 @@
+// Package session persists chat conversations under ~/.rigel/sessions so
+// they can be restored later.
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mizzy/rigel/internal/history"
+	"github.com/mizzy/rigel/internal/privacy"
+)
+
+const (
+	sessionsDir  = "sessions"
+	recoveryFile = "recovery.json"
+
+	// Length a session without a title is shown with, from its first prompt
+	maxUntitledLength = 50
+)
+
+// ErrNotFound is returned when a session does not exist
+var ErrNotFound = errors.New("session not found")
+
+// Exchange is a single prompt and response
+type Exchange struct {
+	Prompt   string `json:"prompt"`
+	Response string `json:"response"`
+	Meta     *Meta  `json:"meta,omitempty"` // Nil for command output
+}
+
+// Meta describes how a response was generated
+type Meta struct {
+	Model        string        `json:"model"`
+	Latency      time.Duration `json:"latency"`
+	InputTokens  int           `json:"input_tokens"` // Estimated, as metered
+	OutputTokens int           `json:"output_tokens"`
+	Cost         float64       `json:"cost,omitempty"` // US dollars; 0 if free or the price is unknown
+}
+
+// String renders the metadata in one line, e.g.
+// "claude-3-5-haiku · 2.3s · 412→618 tokens · $0.0021"
+func (m Meta) String() string {
+	parts := []string{m.Model, fmt.Sprintf("%.1fs", m.Latency.Seconds())}
+	if m.InputTokens > 0 || m.OutputTokens > 0 {
+		parts = append(parts, fmt.Sprintf("%d→%d tokens", m.InputTokens, m.OutputTokens))
+	}
+	if m.Cost > 0 {
+		parts = append(parts, fmt.Sprintf("$%.4f", m.Cost))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// Message is a message the agent remembers
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Memory is what the agent remembers of a conversation, which can differ
+// from the exchanges shown, e.g. when tools ran
+type Memory struct {
+	Messages []Message              `json:"messages"`
+	Context  map[string]interface{} `json:"context,omitempty"`
+}
+
+// Session is a saved conversation
+type Session struct {
+	ID        string     `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Provider  string     `json:"provider,omitempty"`
+	Model     string     `json:"model,omitempty"`
+	Exchanges []Exchange `json:"exchanges"`
+	Pending   string     `json:"pending,omitempty"` // Prompt that was in flight when the session was saved
+	Memory    *Memory    `json:"memory,omitempty"`  // What the agent remembered; rebuilt from Exchanges if missing
+
+	// Generated by the model from the first exchange, or set with /session
+	// rename
+	Title string `json:"title,omitempty"`
+
+	// Set on branches created with /fork
+	Name      string `json:"name,omitempty"`
+	ParentID  string `json:"parent_id,omitempty"`
+	ForkPoint int    `json:"fork_point,omitempty"` // Number of exchanges copied from the parent
+}
+
+// New creates an empty session with a fresh ID
+func New() *Session {
+	now := time.Now()
+	return &Session{
+		ID:        NewID(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Exchanges: []Exchange{},
+	}
+}
+
+// DisplayTitle returns the session's title or, if it has none yet, the start
+// of its first prompt
+func (s *Session) DisplayTitle() string {
+	if s.Title != "" {
+		return s.Title
+	}
+	for _, ex := range s.Exchanges {
+		if strings.HasPrefix(ex.Prompt, "/") {
+			continue
+		}
+		line, _, _ := strings.Cut(strings.TrimSpace(ex.Prompt), "\n")
+		if runes := []rune(line); len(runes) > maxUntitledLength {
+			return string(runes[:maxUntitledLength-1]) + "…"
+		}
+		return line
+	}
+	return "(untitled)"
+}
+
+// NewID returns a short random session ID
+func NewID() string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// Store reads and writes sessions as JSON files in a directory
+type Store struct {
+	dir string
+}
+
+// NewStore creates a store in ~/.rigel/sessions
+func NewStore() (*Store, error) {
+	rigelPath, err := history.GetRigelDir()
+	if err != nil {
+		return nil, err
+	}
+	return NewStoreAt(filepath.Join(rigelPath, sessionsDir))
+}
+
+// NewStoreAt creates a store in the given directory
+func NewStoreAt(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create sessions directory: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Dir returns the directory the store writes to
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Save writes a session, updating its UpdatedAt timestamp
+func (s *Store) Save(sess *Session) error {
+	if sess.ID == "" || strings.ContainsAny(sess.ID, `/\`) {
+		return fmt.Errorf("invalid session ID: %q", sess.ID)
+	}
+	sess.UpdatedAt = time.Now()
+	return writeJSON(filepath.Join(s.dir, sess.ID+".json"), sess)
+}
+
+// Load reads a session by ID
+func (s *Store) Load(id string) (*Session, error) {
+	if strings.ContainsAny(id, `/\`) {
+		return nil, fmt.Errorf("invalid session ID: %q", id)
+	}
+	return readJSON(filepath.Join(s.dir, id+".json"))
+}
+
+// Delete removes a session
+func (s *Store) Delete(id string) error {
+	err := os.Remove(filepath.Join(s.dir, id+".json"))
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// List returns all saved sessions, most recently updated first
+func (s *Store) List() ([]*Session, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sessions directory: %w", err)
+	}
+
+	var sessions []*Session
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".json" || name == recoveryFile {
+			continue
+		}
+		sess, err := readJSON(filepath.Join(s.dir, name))
+		if err != nil {
+			// Skip unreadable session files
+			continue
+		}
+		sessions = append(sessions, sess)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
+	})
+	return sessions, nil
+}
+
+// Find returns a session by its number in the list List returns, starting
+// at 1, by its ID or by a prefix of its ID that only it has
+func (s *Store) Find(ref string) (*Session, error) {
+	if ref == "" {
+		return nil, ErrNotFound
+	}
+	sessions, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	if n, err := strconv.Atoi(ref); err == nil && n >= 1 && n <= len(sessions) {
+		return sessions[n-1], nil
+	}
+
+	var found *Session
+	for _, sess := range sessions {
+		if sess.ID == ref {
+			return sess, nil
+		}
+		if strings.HasPrefix(sess.ID, ref) {
+			if found != nil {
+				return nil, fmt.Errorf("session ID prefix %q is ambiguous", ref)
+			}
+			found = sess
+		}
+	}
+	if found == nil {
+		return nil, ErrNotFound
+	}
+	return found, nil
+}
+
+// SaveRecovery writes a snapshot of a conversation interrupted by a crash
+func (s *Store) SaveRecovery(sess *Session) error {
+	sess.UpdatedAt = time.Now()
+	return writeJSON(filepath.Join(s.dir, recoveryFile), sess)
+}
+
+// LoadRecovery returns the crash snapshot, or ErrNotFound if there is none
+func (s *Store) LoadRecovery() (*Session, error) {
+	return readJSON(filepath.Join(s.dir, recoveryFile))
+}
+
+// ClearRecovery removes the crash snapshot
+func (s *Store) ClearRecovery() error {
+	err := os.Remove(filepath.Join(s.dir, recoveryFile))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// writeJSON writes a session with secrets masked, encrypted if encryption
+// is configured
+func writeJSON(path string, sess *Session) error {
+	data, err := json.MarshalIndent(redacted(sess), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+	if data, err = privacy.Seal(data); err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+
+	// Write to a temp file first so a crash mid-write can't corrupt the session
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+	return nil
+}
+
+func readJSON(path string) (*Session, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session: %w", err)
+	}
+	if data, err = privacy.Open(data); err != nil {
+		return nil, fmt.Errorf("failed to read session %s: %w", filepath.Base(path), err)
+	}
+
+	var sess Session
+	if err := json.Unmarshal(data, &sess); err != nil {
+		return nil, fmt.Errorf("failed to parse session %s: %w", filepath.Base(path), err)
+	}
+	return &sess, nil
+}
+
+// redacted returns a copy of a session with the prompts, responses and
+// remembered messages passed through privacy.Redact
+func redacted(sess *Session) *Session {
+	out := *sess
+	out.Exchanges = make([]Exchange, len(sess.Exchanges))
+	for i, ex := range sess.Exchanges {
+		out.Exchanges[i] = Exchange{Prompt: privacy.Redact(ex.Prompt), Response: privacy.Redact(ex.Response), Meta: ex.Meta}
+	}
+	out.Pending = privacy.Redact(sess.Pending)
+	if sess.Memory != nil {
+		memory := *sess.Memory
+		memory.Messages = make([]Message, len(sess.Memory.Messages))
+		for i, msg := range sess.Memory.Messages {
+			memory.Messages[i] = Message{Role: msg.Role, Content: privacy.Redact(msg.Content)}
+		}
+		out.Memory = &memory
+	}
+	return &out
+}
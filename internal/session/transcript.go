@@ -0,0 +1,116 @@
+package session
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mizzy/rigel/internal/privacy"
+)
+
+const (
+	transcriptsDir = "transcripts"
+
+	// maxTranscriptOutput limits how much of a tool's output is recorded
+	maxTranscriptOutput = 16 * 1024
+)
+
+// How a tool call was approved
+const (
+	ApprovalAuto = "auto" // Run by the agent without asking
+)
+
+// ToolCall is a tool the agent ran, as recorded in a session's transcript
+type ToolCall struct {
+	Time     time.Time     `json:"time"`
+	Tool     string        `json:"tool"`
+	Input    string        `json:"input"`
+	Output   string        `json:"output,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+	Approval string        `json:"approval"`
+}
+
+// transcriptPath returns the transcript file of a session
+func (s *Store) transcriptPath(id string) (string, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) {
+		return "", fmt.Errorf("invalid session ID: %q", id)
+	}
+	return filepath.Join(s.dir, transcriptsDir, id+".jsonl"), nil
+}
+
+// AppendToolCall adds a tool call to the transcript of a session, with
+// secrets masked and long output cut off
+func (s *Store) AppendToolCall(id string, call ToolCall) error {
+	path, err := s.transcriptPath(id)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create transcripts directory: %w", err)
+	}
+
+	call.Input = privacy.Redact(call.Input)
+	call.Output = privacy.Redact(call.Output)
+	call.Error = privacy.Redact(call.Error)
+	if len(call.Output) > maxTranscriptOutput {
+		call.Output = fmt.Sprintf("%s... (%d bytes)", call.Output[:maxTranscriptOutput], len(call.Output))
+	}
+	data, err := json.Marshal(call)
+	if err != nil {
+		return fmt.Errorf("failed to encode tool call: %w", err)
+	}
+	if data, err = privacy.Seal(data); err != nil {
+		return fmt.Errorf("failed to encode tool call: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open transcript: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write transcript: %w", err)
+	}
+	return nil
+}
+
+// Transcript returns the tool calls recorded for a session, oldest first
+func (s *Store) Transcript(id string) ([]ToolCall, error) {
+	path, err := s.transcriptPath(id)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open transcript: %w", err)
+	}
+	defer file.Close()
+
+	var calls []ToolCall
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 4*maxTranscriptOutput)
+	for scanner.Scan() {
+		line, err := privacy.Open(scanner.Bytes())
+		if err != nil {
+			// Skip calls encrypted with a key that isn't available
+			continue
+		}
+		var call ToolCall
+		if err := json.Unmarshal(line, &call); err != nil {
+			continue
+		}
+		calls = append(calls, call)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read transcript: %w", err)
+	}
+	return calls, nil
+}
@@ -0,0 +1,74 @@
+package state
+
+// Branch identifies a conversation branch saved in the session store
+type Branch struct {
+	ID   string
+	Name string
+}
+
+// BranchState tracks the branches forked from the current conversation
+type BranchState struct {
+	branches []Branch
+	current  int
+}
+
+// NewBranchState creates a branch state with a single "main" branch
+func NewBranchState(id string) *BranchState {
+	return &BranchState{
+		branches: []Branch{{ID: id, Name: "main"}},
+	}
+}
+
+// GetBranches returns all branches in creation order
+func (bs *BranchState) GetBranches() []Branch {
+	return bs.branches
+}
+
+// GetCurrent returns the active branch
+func (bs *BranchState) GetCurrent() Branch {
+	return bs.branches[bs.current]
+}
+
+// GetCurrentIndex returns the index of the active branch
+func (bs *BranchState) GetCurrentIndex() int {
+	return bs.current
+}
+
+// Add appends a branch and makes it the active one
+func (bs *BranchState) Add(branch Branch) int {
+	bs.branches = append(bs.branches, branch)
+	bs.current = len(bs.branches) - 1
+	return bs.current
+}
+
+// SetCurrent makes the branch at index active. It reports whether the index
+// was valid.
+func (bs *BranchState) SetCurrent(index int) bool {
+	if index < 0 || index >= len(bs.branches) {
+		return false
+	}
+	bs.current = index
+	return true
+}
+
+// Reset makes branch the only one, e.g. when another conversation is
+// resumed
+func (bs *BranchState) Reset(branch Branch) {
+	bs.branches = []Branch{branch}
+	bs.current = 0
+}
+
+// Find returns the index of the branch with the given name, or -1
+func (bs *BranchState) Find(name string) int {
+	for i, branch := range bs.branches {
+		if branch.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// IsForked reports whether the conversation has more than one branch
+func (bs *BranchState) IsForked() bool {
+	return len(bs.branches) > 1
+}
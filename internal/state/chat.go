@@ -1,13 +1,23 @@
 package state
 
+import (
+	"sync"
+
+	"github.com/mizzy/rigel/internal/session"
+)
+
 // Exchange represents a single chat exchange
 type Exchange struct {
 	Prompt   string
 	Response string
+	Meta     *session.Meta // How the response was generated; nil for command output
 }
 
-// ChatState manages the chat conversation state
+// ChatState manages the chat conversation state. It is safe for concurrent
+// use, since commands and requests running in the background read and change
+// it alongside the UI.
 type ChatState struct {
+	mu            sync.RWMutex
 	history       []Exchange
 	thinking      bool
 	currentPrompt string
@@ -23,63 +33,104 @@ func NewChatState() *ChatState {
 
 // AddExchange adds a new exchange to the history
 func (cs *ChatState) AddExchange(prompt, response string) {
+	cs.AddExchangeWithMeta(prompt, response, nil)
+}
+
+// AddExchangeWithMeta adds a new exchange to the history along with how
+// its response was generated
+func (cs *ChatState) AddExchangeWithMeta(prompt, response string, meta *session.Meta) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
 	cs.history = append(cs.history, Exchange{
 		Prompt:   prompt,
 		Response: response,
+		Meta:     meta,
 	})
 }
 
-// GetHistory returns the chat history
+// GetHistory returns a copy of the chat history
 func (cs *ChatState) GetHistory() []Exchange {
-	return cs.history
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return append([]Exchange{}, cs.history...)
+}
+
+// RemoveExchange removes the exchange at index from the history
+func (cs *ChatState) RemoveExchange(index int) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if index < 0 || index >= len(cs.history) {
+		return
+	}
+	cs.history = append(cs.history[:index], cs.history[index+1:]...)
 }
 
 // ClearHistory clears the chat history
 func (cs *ChatState) ClearHistory() {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
 	cs.history = []Exchange{}
 }
 
 // SetThinking sets the thinking state
 func (cs *ChatState) SetThinking(thinking bool) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
 	cs.thinking = thinking
 }
 
 // IsThinking returns whether the chat is currently thinking
 func (cs *ChatState) IsThinking() bool {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
 	return cs.thinking
 }
 
 // SetCurrentPrompt sets the current prompt being processed
 func (cs *ChatState) SetCurrentPrompt(prompt string) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
 	cs.currentPrompt = prompt
 }
 
 // GetCurrentPrompt returns the current prompt
 func (cs *ChatState) GetCurrentPrompt() string {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
 	return cs.currentPrompt
 }
 
 // ClearCurrentPrompt clears the current prompt
 func (cs *ChatState) ClearCurrentPrompt() {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
 	cs.currentPrompt = ""
 }
 
 // SetError sets the current error
 func (cs *ChatState) SetError(err error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
 	cs.err = err
 }
 
 // GetError returns the current error
 func (cs *ChatState) GetError() error {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
 	return cs.err
 }
 
 // ClearError clears the current error
 func (cs *ChatState) ClearError() {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
 	cs.err = nil
 }
 
 // GetMessageCount returns the number of messages in history
 func (cs *ChatState) GetMessageCount() int {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
 	return len(cs.history)
 }
@@ -0,0 +1,153 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ExplorerEntry is a file or directory listed by the file explorer
+type ExplorerEntry struct {
+	Name string // ".." for the parent directory
+	Dir  bool
+}
+
+// FileExplorer tracks the Ctrl+E file explorer: the directory listed,
+// relative to the root it was opened in, the entry selected and the files
+// marked to add together
+type FileExplorer struct {
+	picker[ExplorerEntry]
+	root   string
+	dir    string          // Relative to root, "." for the root itself
+	marked map[string]bool // Paths relative to root
+}
+
+// NewFileExplorer creates an inactive file explorer
+func NewFileExplorer() *FileExplorer {
+	return &FileExplorer{}
+}
+
+// Activate shows the explorer listing root, with no files marked
+func (fe *FileExplorer) Activate(root string) error {
+	fe.root = root
+	fe.marked = map[string]bool{}
+	if err := fe.list("."); err != nil {
+		return err
+	}
+	fe.active = true
+	return nil
+}
+
+// Deactivate hides the explorer
+func (fe *FileExplorer) Deactivate() {
+	fe.picker.Deactivate()
+	fe.marked = nil
+}
+
+// GetDir returns the directory listed, relative to the root
+func (fe *FileExplorer) GetDir() string {
+	return fe.dir
+}
+
+// GetEntries returns the entries of the directory: its parent unless it is
+// the root, then its directories and files, each by name
+func (fe *FileExplorer) GetEntries() []ExplorerEntry {
+	return fe.filtered
+}
+
+// Open enters the selected directory, or returns the path of the selected
+// file relative to the root
+func (fe *FileExplorer) Open() (string, bool, error) {
+	entry, ok := fe.GetSelected()
+	if !ok {
+		return "", false, nil
+	}
+	if entry.Name == ".." {
+		return "", false, fe.Parent()
+	}
+	path := filepath.Join(fe.dir, entry.Name)
+	if entry.Dir {
+		return "", false, fe.list(path)
+	}
+	return path, true, nil
+}
+
+// Parent lists the parent directory, selecting the directory left, unless
+// the root is listed
+func (fe *FileExplorer) Parent() error {
+	if fe.dir == "." {
+		return nil
+	}
+	left := filepath.Base(fe.dir)
+	if err := fe.list(filepath.Dir(fe.dir)); err != nil {
+		return err
+	}
+	fe.selected = max(slices.Index(fe.filtered, ExplorerEntry{Name: left, Dir: true}), 0)
+	return nil
+}
+
+// ToggleMarked marks the selected file to add, or unmarks it
+func (fe *FileExplorer) ToggleMarked() {
+	entry, ok := fe.GetSelected()
+	if !ok || entry.Dir {
+		return
+	}
+	path := filepath.Join(fe.dir, entry.Name)
+	if fe.marked[path] {
+		delete(fe.marked, path)
+	} else {
+		fe.marked[path] = true
+	}
+}
+
+// IsMarked reports whether the entry of the listed directory named name is
+// marked
+func (fe *FileExplorer) IsMarked(name string) bool {
+	return fe.marked[filepath.Join(fe.dir, name)]
+}
+
+// GetMarked returns the paths of the marked files relative to the root, in
+// order
+func (fe *FileExplorer) GetMarked() []string {
+	var paths []string
+	for path := range fe.marked {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+	return paths
+}
+
+// list lists dir, relative to the root, selecting its first entry. Hidden
+// files and directories are left out.
+func (fe *FileExplorer) list(dir string) error {
+	dirEntries, err := os.ReadDir(filepath.Join(fe.root, dir))
+	if err != nil {
+		return err
+	}
+
+	var dirs, files []ExplorerEntry
+	for _, entry := range dirEntries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		// Follow symlinks to tell directories from files
+		info, err := os.Stat(filepath.Join(fe.root, dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		if info.IsDir() {
+			dirs = append(dirs, ExplorerEntry{Name: entry.Name(), Dir: true})
+		} else {
+			files = append(files, ExplorerEntry{Name: entry.Name()})
+		}
+	}
+
+	fe.dir = filepath.Clean(dir)
+	var entries []ExplorerEntry
+	if fe.dir != "." {
+		entries = append(entries, ExplorerEntry{Name: "..", Dir: true})
+	}
+	fe.setItems(append(append(entries, dirs...), files...))
+	return nil
+}
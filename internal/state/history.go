@@ -0,0 +1,52 @@
+package state
+
+import (
+	"github.com/mizzy/rigel/internal/fuzzy"
+	"github.com/mizzy/rigel/internal/history"
+)
+
+// HistoryPicker tracks the /history list: the past prompts, newest first,
+// narrowed down by a fuzzy filter, and the one selected
+type HistoryPicker struct {
+	picker[history.Entry]
+}
+
+// NewHistoryPicker creates an inactive history picker
+func NewHistoryPicker() *HistoryPicker {
+	return &HistoryPicker{picker[history.Entry]{match: matchHistoryEntries}}
+}
+
+// Activate shows the picker with entries, newest first
+func (hp *HistoryPicker) Activate(entries []history.Entry) {
+	hp.show(entries)
+}
+
+// RemoveSelected drops the selected entry from the list and returns it
+func (hp *HistoryPicker) RemoveSelected() (history.Entry, bool) {
+	entry, ok := hp.GetSelected()
+	if !ok {
+		return entry, false
+	}
+	for i, e := range hp.items {
+		if e.Command == entry.Command && e.Timestamp.Equal(entry.Timestamp) {
+			hp.items = append(hp.items[:i:i], hp.items[i+1:]...)
+			break
+		}
+	}
+	hp.applyFilter()
+	hp.selected = min(hp.selected, max(len(hp.filtered)-1, 0))
+	return entry, true
+}
+
+// matchHistoryEntries returns the entries whose command matches filter
+func matchHistoryEntries(filter string, entries []history.Entry) []history.Entry {
+	commands := make([]string, len(entries))
+	for i, entry := range entries {
+		commands[i] = entry.Command
+	}
+	var filtered []history.Entry
+	for _, match := range fuzzy.Filter(filter, commands) {
+		filtered = append(filtered, entries[match.Index])
+	}
+	return filtered
+}
@@ -1,8 +1,9 @@
 package state
 
 import (
-	"strings"
+	"sort"
 
+	"github.com/mizzy/rigel/internal/fuzzy"
 	"github.com/mizzy/rigel/internal/llm"
 )
 
@@ -18,11 +19,38 @@ type LLMState struct {
 	filteredModels       []llm.Model
 	selectedModelIndex   int
 	modelFilter          string
+	modelSort            ModelSort
 
 	// Provider selection
 	providerSelectionActive bool
 	availableProviders      []llm.Provider
+	filteredProviders       []llm.Provider
 	selectedProviderIndex   int
+	providerFilter          string
+}
+
+// ModelSort is the order of the models in the selector
+type ModelSort int
+
+const (
+	ModelSortDefault ModelSort = iota // As listed, or best match first when filtering
+	ModelSortName
+	ModelSortSize // Largest first
+	ModelSortFamily
+)
+
+// String returns the name of the sort order
+func (s ModelSort) String() string {
+	switch s {
+	case ModelSortName:
+		return "name"
+	case ModelSortSize:
+		return "size"
+	case ModelSortFamily:
+		return "family"
+	default:
+		return "default"
+	}
 }
 
 // NewLLMState creates a new LLM state manager
@@ -68,13 +96,23 @@ func (ls *LLMState) IsModelSelectionActive() bool {
 	return ls.modelSelectionActive
 }
 
-// ActivateModelSelection enters model selection mode
+// ActivateModelSelection enters model selection mode with the current model
+// selected
 func (ls *LLMState) ActivateModelSelection(models []llm.Model) {
 	ls.modelSelectionActive = true
 	ls.availableModels = models
-	ls.filteredModels = models
 	ls.selectedModelIndex = 0
 	ls.modelFilter = ""
+	ls.filterModels()
+
+	// Start at the current model, which may be on a later page
+	current := ls.GetCurrentModel().Name
+	for i, model := range ls.filteredModels {
+		if model.Name == current {
+			ls.selectedModelIndex = i
+			break
+		}
+	}
 }
 
 // DeactivateModelSelection exits model selection mode
@@ -130,25 +168,71 @@ func (ls *LLMState) MoveModelSelectionDown() {
 	}
 }
 
+// MoveModelSelection moves model selection by delta entries, e.g. a page,
+// stopping at the first and last model
+func (ls *LLMState) MoveModelSelection(delta int) {
+	ls.selectedModelIndex = max(0, min(ls.selectedModelIndex+delta, len(ls.filteredModels)-1))
+}
+
+// GetModelSort returns the order of the models
+func (ls *LLMState) GetModelSort() ModelSort {
+	return ls.modelSort
+}
+
+// CycleModelSort switches to the next sort order, keeping the selected
+// model selected
+func (ls *LLMState) CycleModelSort() {
+	selected, ok := ls.GetSelectedModel()
+	ls.modelSort = (ls.modelSort + 1) % (ModelSortFamily + 1)
+	ls.filterModels()
+	if ok {
+		for i, model := range ls.filteredModels {
+			if model.Name == selected.Name {
+				ls.selectedModelIndex = i
+				break
+			}
+		}
+	}
+}
+
+// SelectModel selects the filtered model at index, ignoring indexes out of
+// range
+func (ls *LLMState) SelectModel(index int) {
+	if index >= 0 && index < len(ls.filteredModels) {
+		ls.selectedModelIndex = index
+	}
+}
+
 // HasFilteredModels returns whether there are any filtered models
 func (ls *LLMState) HasFilteredModels() bool {
 	return len(ls.filteredModels) > 0
 }
 
-// filterModels applies the current filter to available models
+// filterModels applies the current filter to available models, best
+// fuzzy matches first, then the sort order
 func (ls *LLMState) filterModels() {
-	if ls.modelFilter == "" {
-		ls.filteredModels = ls.availableModels
-		return
+	names := make([]string, len(ls.availableModels))
+	for i, model := range ls.availableModels {
+		names[i] = model.Name
+	}
+	ls.filteredModels = nil
+	for _, match := range fuzzy.Filter(ls.modelFilter, names) {
+		ls.filteredModels = append(ls.filteredModels, ls.availableModels[match.Index])
 	}
 
-	filter := strings.ToLower(ls.modelFilter)
-	ls.filteredModels = ls.filteredModels[:0] // Clear but keep capacity
-
-	for _, model := range ls.availableModels {
-		if strings.Contains(strings.ToLower(model.Name), filter) {
-			ls.filteredModels = append(ls.filteredModels, model)
-		}
+	switch ls.modelSort {
+	case ModelSortName:
+		sort.SliceStable(ls.filteredModels, func(i, j int) bool {
+			return ls.filteredModels[i].Name < ls.filteredModels[j].Name
+		})
+	case ModelSortSize:
+		sort.SliceStable(ls.filteredModels, func(i, j int) bool {
+			return ls.filteredModels[i].Size > ls.filteredModels[j].Size
+		})
+	case ModelSortFamily:
+		sort.SliceStable(ls.filteredModels, func(i, j int) bool {
+			return ls.filteredModels[i].Details.Family < ls.filteredModels[j].Details.Family
+		})
 	}
 }
 
@@ -163,7 +247,9 @@ func (ls *LLMState) IsProviderSelectionActive() bool {
 func (ls *LLMState) ActivateProviderSelection(providers []llm.Provider, currentProvider llm.Provider) {
 	ls.providerSelectionActive = true
 	ls.availableProviders = providers
+	ls.filteredProviders = providers
 	ls.selectedProviderIndex = 0
+	ls.providerFilter = ""
 
 	// Find current provider index
 	for i, p := range providers {
@@ -178,7 +264,9 @@ func (ls *LLMState) ActivateProviderSelection(providers []llm.Provider, currentP
 func (ls *LLMState) DeactivateProviderSelection() {
 	ls.providerSelectionActive = false
 	ls.availableProviders = nil
+	ls.filteredProviders = nil
 	ls.selectedProviderIndex = 0
+	ls.providerFilter = ""
 }
 
 // GetAvailableProviders returns available providers
@@ -186,6 +274,30 @@ func (ls *LLMState) GetAvailableProviders() []llm.Provider {
 	return ls.availableProviders
 }
 
+// GetFilteredProviders returns currently filtered providers
+func (ls *LLMState) GetFilteredProviders() []llm.Provider {
+	return ls.filteredProviders
+}
+
+// GetProviderFilter returns the current provider filter
+func (ls *LLMState) GetProviderFilter() string {
+	return ls.providerFilter
+}
+
+// SetProviderFilter updates the provider filter and re-filters
+func (ls *LLMState) SetProviderFilter(filter string) {
+	ls.providerFilter = filter
+	ls.filteredProviders = nil
+	names := make([]string, len(ls.availableProviders))
+	for i, provider := range ls.availableProviders {
+		names[i] = provider.GetName()
+	}
+	for _, match := range fuzzy.Filter(filter, names) {
+		ls.filteredProviders = append(ls.filteredProviders, ls.availableProviders[match.Index])
+	}
+	ls.selectedProviderIndex = 0 // Reset selection when filter changes
+}
+
 // GetSelectedProviderIndex returns the currently selected provider index
 func (ls *LLMState) GetSelectedProviderIndex() int {
 	return ls.selectedProviderIndex
@@ -193,10 +305,10 @@ func (ls *LLMState) GetSelectedProviderIndex() int {
 
 // GetSelectedProvider returns the currently selected provider
 func (ls *LLMState) GetSelectedProvider() (llm.Provider, bool) {
-	if !ls.providerSelectionActive || len(ls.availableProviders) == 0 || ls.selectedProviderIndex >= len(ls.availableProviders) {
+	if !ls.providerSelectionActive || len(ls.filteredProviders) == 0 || ls.selectedProviderIndex >= len(ls.filteredProviders) {
 		return nil, false
 	}
-	return ls.availableProviders[ls.selectedProviderIndex], true
+	return ls.filteredProviders[ls.selectedProviderIndex], true
 }
 
 // MoveProviderSelectionUp moves provider selection up
@@ -208,7 +320,15 @@ func (ls *LLMState) MoveProviderSelectionUp() {
 
 // MoveProviderSelectionDown moves provider selection down
 func (ls *LLMState) MoveProviderSelectionDown() {
-	if ls.selectedProviderIndex < len(ls.availableProviders)-1 {
+	if ls.selectedProviderIndex < len(ls.filteredProviders)-1 {
 		ls.selectedProviderIndex++
 	}
 }
+
+// SelectProvider selects the filtered provider at index, ignoring indexes
+// out of range
+func (ls *LLMState) SelectProvider(index int) {
+	if index >= 0 && index < len(ls.filteredProviders) {
+		ls.selectedProviderIndex = index
+	}
+}
@@ -0,0 +1,58 @@
+package state
+
+import (
+	"strings"
+
+	"github.com/mizzy/rigel/internal/fuzzy"
+)
+
+// PaletteEntry is a slash command listed by the command palette
+type PaletteEntry struct {
+	Name        string
+	Aliases     []string
+	Usage       string // The name followed by the arguments, e.g. "/fix <output>"
+	Description string
+	NeedsArgs   bool // Has required arguments, so it is put in the input rather than run
+}
+
+// CommandPalette tracks the Ctrl+P command palette: the slash commands,
+// narrowed down by a fuzzy filter on their names and descriptions, and the
+// one selected
+type CommandPalette struct {
+	picker[PaletteEntry]
+}
+
+// NewCommandPalette creates an inactive command palette
+func NewCommandPalette() *CommandPalette {
+	return &CommandPalette{picker[PaletteEntry]{match: matchPaletteEntries}}
+}
+
+// Activate shows the palette with entries, in the order given
+func (cp *CommandPalette) Activate(entries []PaletteEntry) {
+	cp.show(entries)
+}
+
+// matchPaletteEntries returns the entries matching filter. Names are
+// matched on their own first, so that commands whose name matches come
+// before those whose description does.
+func matchPaletteEntries(filter string, entries []PaletteEntry) []PaletteEntry {
+	names := make([]string, len(entries))
+	texts := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = strings.Join(append([]string{entry.Name}, entry.Aliases...), " ")
+		texts[i] = names[i] + " " + entry.Description
+	}
+
+	var filtered []PaletteEntry
+	matched := make(map[int]bool, len(entries))
+	for _, match := range fuzzy.Filter(filter, names) {
+		matched[match.Index] = true
+		filtered = append(filtered, entries[match.Index])
+	}
+	for _, match := range fuzzy.Filter(filter, texts) {
+		if !matched[match.Index] {
+			filtered = append(filtered, entries[match.Index])
+		}
+	}
+	return filtered
+}
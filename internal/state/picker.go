@@ -0,0 +1,98 @@
+package state
+
+// picker tracks a list the user picks an item from: the items, those
+// matching the filter typed, and the one selected. The lists of the TUI
+// embed it, giving it the way their items are filtered.
+type picker[T any] struct {
+	active   bool
+	items    []T
+	filtered []T
+	filter   string
+	selected int
+
+	// match returns the items matching filter, best match first; when nil,
+	// every item is listed in order
+	match func(filter string, items []T) []T
+}
+
+// IsActive reports whether the list is shown
+func (p *picker[T]) IsActive() bool {
+	return p.active
+}
+
+// Deactivate hides the list
+func (p *picker[T]) Deactivate() {
+	p.active = false
+	p.items = nil
+	p.filtered = nil
+	p.filter = ""
+	p.selected = 0
+}
+
+// GetFiltered returns the items matching the filter, best match first
+func (p *picker[T]) GetFiltered() []T {
+	return p.filtered
+}
+
+// GetSelectedIndex returns the index of the selected filtered item
+func (p *picker[T]) GetSelectedIndex() int {
+	return p.selected
+}
+
+// GetSelected returns the selected item, if any item matches the filter
+func (p *picker[T]) GetSelected() (T, bool) {
+	if p.selected < 0 || p.selected >= len(p.filtered) {
+		var zero T
+		return zero, false
+	}
+	return p.filtered[p.selected], true
+}
+
+// GetFilter returns the filter typed so far
+func (p *picker[T]) GetFilter() string {
+	return p.filter
+}
+
+// SetFilter narrows the items down to those matching filter
+func (p *picker[T]) SetFilter(filter string) {
+	p.filter = filter
+	p.selected = 0
+	p.applyFilter()
+}
+
+// Move moves the selection by delta items, stopping at the first and last
+// item
+func (p *picker[T]) Move(delta int) {
+	p.selected = max(0, min(p.selected+delta, len(p.filtered)-1))
+}
+
+// Select selects the filtered item at index, ignoring indexes out of range
+func (p *picker[T]) Select(index int) {
+	if index >= 0 && index < len(p.filtered) {
+		p.selected = index
+	}
+}
+
+// show shows the list with items and no filter, selecting the first item
+func (p *picker[T]) show(items []T) {
+	p.active = true
+	p.filter = ""
+	p.setItems(items)
+}
+
+// setItems replaces the items, keeping the filter and selecting the first
+// item matching it
+func (p *picker[T]) setItems(items []T) {
+	p.items = items
+	p.selected = 0
+	p.applyFilter()
+}
+
+// applyFilter narrows the items down to those matching the filter
+func (p *picker[T]) applyFilter() {
+	if p.match == nil {
+		p.filtered = p.items
+		return
+	}
+	p.filtered = p.match(p.filter, p.items)
+}
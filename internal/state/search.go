@@ -0,0 +1,101 @@
+package state
+
+import "github.com/mizzy/rigel/internal/session"
+
+// SearchPicker tracks the /search results: the exchanges found, best match
+// first, and the one selected
+type SearchPicker struct {
+	picker[session.Hit]
+	query   string
+	current string
+}
+
+// NewSearchPicker creates an inactive search picker
+func NewSearchPicker() *SearchPicker {
+	return &SearchPicker{}
+}
+
+// Activate shows the picker with the hits of a query; current is the ID of
+// the current conversation's session
+func (sp *SearchPicker) Activate(query string, hits []session.Hit, current string) {
+	sp.query = query
+	sp.current = current
+	sp.show(hits)
+}
+
+// Deactivate hides the picker
+func (sp *SearchPicker) Deactivate() {
+	sp.picker.Deactivate()
+	sp.query = ""
+}
+
+// GetQuery returns the words searched for
+func (sp *SearchPicker) GetQuery() string {
+	return sp.query
+}
+
+// GetHits returns the exchanges found
+func (sp *SearchPicker) GetHits() []session.Hit {
+	return sp.filtered
+}
+
+// GetCurrent returns the ID of the current conversation's session
+func (sp *SearchPicker) GetCurrent() string {
+	return sp.current
+}
+
+// SessionViewer tracks a session shown read-only one exchange at a time,
+// as /search opens a saved conversation
+type SessionViewer struct {
+	session  *session.Session
+	exchange int
+	current  bool
+}
+
+// NewSessionViewer creates a viewer showing nothing
+func NewSessionViewer() *SessionViewer {
+	return &SessionViewer{}
+}
+
+// IsActive reports whether a session is shown
+func (sv *SessionViewer) IsActive() bool {
+	return sv.session != nil
+}
+
+// Open shows a session at one of its exchanges; current tells whether it
+// is the current conversation
+func (sv *SessionViewer) Open(sess *session.Session, exchange int, current bool) {
+	sv.session = sess
+	sv.exchange = exchange
+	sv.current = current
+}
+
+// Close stops showing the session
+func (sv *SessionViewer) Close() {
+	sv.session = nil
+	sv.exchange = 0
+	sv.current = false
+}
+
+// GetSession returns the session shown
+func (sv *SessionViewer) GetSession() *session.Session {
+	return sv.session
+}
+
+// IsCurrent reports whether the session shown is the current conversation
+func (sv *SessionViewer) IsCurrent() bool {
+	return sv.current
+}
+
+// GetExchange returns the index of the exchange shown
+func (sv *SessionViewer) GetExchange() int {
+	return sv.exchange
+}
+
+// Move shows the exchange delta exchanges away, stopping at the first and
+// last
+func (sv *SessionViewer) Move(delta int) {
+	if sv.session != nil {
+		sv.exchange = max(0, min(sv.exchange+delta, len(sv.session.Exchanges)-1))
+	}
+}
@@ -0,0 +1,176 @@
+// Package stats keeps local usage statistics in ~/.rigel/stats.json: for
+// each day, how many prompts were answered, the tokens they took, the tools
+// the agent ran and the models that answered. They are shown by /stats and
+// never leave the machine.
+package stats
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+)
+
+// retention is how many days of statistics are kept
+const retention = 365
+
+// dateFormat is the layout of Day.Date
+const dateFormat = "2006-01-02"
+
+// Day is the usage of one day, in local time
+type Day struct {
+	Date         string         `json:"date"` // YYYY-MM-DD
+	Prompts      int            `json:"prompts"`
+	InputTokens  int            `json:"input_tokens"`
+	OutputTokens int            `json:"output_tokens"`
+	Tools        map[string]int `json:"tools,omitempty"`  // Runs by tool
+	Models       map[string]int `json:"models,omitempty"` // Prompts by provider/model
+}
+
+// Tokens returns the input and output tokens of the day
+func (d Day) Tokens() int {
+	return d.InputTokens + d.OutputTokens
+}
+
+// Usage is what is added to a day's statistics, typically once a prompt was
+// answered
+type Usage struct {
+	Time         time.Time
+	Prompts      int
+	Model        string // provider/model that answered the prompts
+	InputTokens  int
+	OutputTokens int
+	Tools        []string // Tools run, once per run
+}
+
+// empty reports whether the usage adds nothing
+func (u Usage) empty() bool {
+	return u.Prompts == 0 && u.InputTokens == 0 && u.OutputTokens == 0 && len(u.Tools) == 0
+}
+
+// Store reads and writes the statistics file
+type Store struct {
+	path string
+}
+
+// NewStore creates a store for ~/.rigel/stats.json
+func NewStore() (*Store, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return NewStoreAt(filepath.Join(homeDir, ".rigel", "stats.json")), nil
+}
+
+// NewStoreAt creates a store for the given file
+func NewStoreAt(path string) *Store {
+	return &Store{path: path}
+}
+
+// Path returns the file the store writes to
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Add adds usage to the statistics of its day. Days older than a year are
+// dropped.
+func (s *Store) Add(usage Usage) error {
+	if usage.empty() {
+		return nil
+	}
+	if usage.Time.IsZero() {
+		usage.Time = time.Now()
+	}
+
+	// Re-read the file to keep what other sessions added meanwhile
+	days, err := s.load()
+	if err != nil {
+		return err
+	}
+	date := usage.Time.Format(dateFormat)
+	i := sort.Search(len(days), func(i int) bool { return days[i].Date >= date })
+	if i == len(days) || days[i].Date != date {
+		days = slices.Insert(days, i, Day{Date: date})
+	}
+	day := &days[i]
+	day.Prompts += usage.Prompts
+	day.InputTokens += usage.InputTokens
+	day.OutputTokens += usage.OutputTokens
+	for _, tool := range usage.Tools {
+		if day.Tools == nil {
+			day.Tools = map[string]int{}
+		}
+		day.Tools[tool]++
+	}
+	if usage.Model != "" && usage.Prompts > 0 {
+		if day.Models == nil {
+			day.Models = map[string]int{}
+		}
+		day.Models[usage.Model] += usage.Prompts
+	}
+
+	oldest := usage.Time.AddDate(0, 0, -retention).Format(dateFormat)
+	for len(days) > 0 && days[0].Date < oldest {
+		days = days[1:]
+	}
+	return s.save(days)
+}
+
+// Days returns the statistics of the n days up to and including the day of
+// now, oldest first. Days without usage are included, empty.
+func (s *Store) Days(n int, now time.Time) ([]Day, error) {
+	stored, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	byDate := make(map[string]Day, len(stored))
+	for _, day := range stored {
+		byDate[day.Date] = day
+	}
+
+	days := make([]Day, n)
+	for i := range days {
+		date := now.AddDate(0, 0, i-n+1).Format(dateFormat)
+		day, ok := byDate[date]
+		if !ok {
+			day = Day{Date: date}
+		}
+		days[i] = day
+	}
+	return days, nil
+}
+
+// load reads the stored days, oldest first; a missing file has none
+func (s *Store) load() ([]Day, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", s.path, err)
+	}
+	var days []Day
+	if err := json.Unmarshal(data, &days); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", s.path, err)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+	return days, nil
+}
+
+func (s *Store) save(days []Day) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(s.path), err)
+	}
+	data, err := json.MarshalIndent(days, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal statistics: %w", err)
+	}
+	if err := os.WriteFile(s.path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", s.path, err)
+	}
+	return nil
+}
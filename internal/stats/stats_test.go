@@ -0,0 +1,59 @@
+package stats
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreAddAndDays(t *testing.T) {
+	store := NewStoreAt(filepath.Join(t.TempDir(), "stats.json"))
+	today := time.Date(2025, 3, 10, 15, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+
+	require.NoError(t, store.Add(Usage{Time: today, Prompts: 1, Model: "ollama/llama3.2", InputTokens: 100, OutputTokens: 20, Tools: []string{"read", "read"}}))
+	require.NoError(t, store.Add(Usage{Time: today, Prompts: 1, Model: "anthropic/claude", InputTokens: 50, OutputTokens: 10, Tools: []string{"test"}}))
+	require.NoError(t, store.Add(Usage{Time: yesterday, Prompts: 2, Model: "ollama/llama3.2"}))
+	require.NoError(t, store.Add(Usage{Time: today}), "empty usage is ignored")
+
+	days, err := store.Days(3, today)
+	require.NoError(t, err)
+	assert.Equal(t, []Day{
+		{Date: "2025-03-08"},
+		{Date: "2025-03-09", Prompts: 2, Models: map[string]int{"ollama/llama3.2": 2}},
+		{Date: "2025-03-10", Prompts: 2, InputTokens: 150, OutputTokens: 30,
+			Tools:  map[string]int{"read": 2, "test": 1},
+			Models: map[string]int{"ollama/llama3.2": 1, "anthropic/claude": 1}},
+	}, days)
+	assert.Equal(t, 180, days[2].Tokens())
+}
+
+func TestStoreDropsOldDays(t *testing.T) {
+	store := NewStoreAt(filepath.Join(t.TempDir(), "stats.json"))
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.Local)
+
+	require.NoError(t, store.Add(Usage{Time: now.AddDate(-2, 0, 0), Prompts: 1}))
+	require.NoError(t, store.Add(Usage{Time: now, Prompts: 1}))
+
+	days, err := store.load()
+	require.NoError(t, err)
+	require.Len(t, days, 1)
+	assert.Equal(t, "2025-03-10", days[0].Date)
+}
+
+func TestStoreMissingAndDamagedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.json")
+	store := NewStoreAt(path)
+
+	days, err := store.Days(7, time.Now())
+	require.NoError(t, err)
+	assert.Len(t, days, 7)
+
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0644))
+	_, err = store.Days(7, time.Now())
+	assert.ErrorContains(t, err, "failed to parse")
+}
@@ -0,0 +1,254 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultCheckTimeout limits how long each check command may take
+	defaultCheckTimeout = 5 * time.Minute
+
+	// maxDiagnostics limits how many diagnostics are reported to the model
+	maxDiagnostics = 50
+)
+
+// Diagnostic is a problem reported by a build or lint command
+type Diagnostic struct {
+	Path    string // Absolute path of the file
+	File    string // Path as reported by the command
+	Line    int
+	Column  int // 0 if not reported
+	Message string
+	Source  string // Command that reported the problem
+}
+
+func (d Diagnostic) String() string {
+	location := fmt.Sprintf("%s:%d", d.File, d.Line)
+	if d.Column > 0 {
+		location += fmt.Sprintf(":%d", d.Column)
+	}
+	return fmt.Sprintf("%s: %s (%s)", location, d.Message, d.Source)
+}
+
+// CheckResult is the outcome of running the check commands
+type CheckResult struct {
+	Diagnostics []Diagnostic
+	Failed      []string // Commands that failed, with output that had no diagnostics
+}
+
+// OK reports whether every command passed
+func (r *CheckResult) OK() bool {
+	return len(r.Diagnostics) == 0 && len(r.Failed) == 0
+}
+
+// ForFile returns the diagnostics in the file at the absolute path
+func (r *CheckResult) ForFile(path string) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, d := range r.Diagnostics {
+		if d.Path == filepath.Clean(path) {
+			diagnostics = append(diagnostics, d)
+		}
+	}
+	return diagnostics
+}
+
+func (r *CheckResult) String() string {
+	if r.OK() {
+		return "No problems found"
+	}
+
+	var sb strings.Builder
+	if n := len(r.Diagnostics); n > 0 {
+		sb.WriteString(fmt.Sprintf("%d problem(s) found:\n", n))
+		for i, d := range r.Diagnostics {
+			if i == maxDiagnostics {
+				sb.WriteString(fmt.Sprintf("... (%d more)\n", n-maxDiagnostics))
+				break
+			}
+			sb.WriteString(d.String() + "\n")
+		}
+	}
+	for _, failure := range r.Failed {
+		sb.WriteString(failure + "\n")
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// CheckTool builds and lints the project and reports problems by file and
+// line so the agent can fix them
+type CheckTool struct {
+	BaseTool
+	dir      string
+	commands []string // Configured commands; detected from the project if empty
+	timeout  time.Duration
+}
+
+// NewCheckTool creates a tool that runs commands in dir, or commands
+// detected from the project if commands is empty
+func NewCheckTool(dir string, commands []string) *CheckTool {
+	return &CheckTool{
+		BaseTool: BaseTool{
+			name:        "check_code",
+			description: "Build and lint the project and report problems with file and line",
+		},
+		dir:      dir,
+		commands: commands,
+		timeout:  defaultCheckTimeout,
+	}
+}
+
+// SetTimeout sets how long each command may take; 0 removes the limit
+func (c *CheckTool) SetTimeout(timeout time.Duration) {
+	c.timeout = timeout
+}
+
+// DetectCheckCommands returns the build and lint commands for the project
+// in dir. golangci-lint is only included if it is installed.
+func DetectCheckCommands(dir string) ([]string, error) {
+	if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+		commands := []string{"go build ./..."}
+		if _, err := exec.LookPath("golangci-lint"); err == nil {
+			commands = append(commands, "golangci-lint run")
+		}
+		return commands, nil
+	}
+
+	if data, err := os.ReadFile(filepath.Join(dir, "package.json")); err == nil {
+		var pkg struct {
+			Scripts map[string]string `json:"scripts"`
+		}
+		if json.Unmarshal(data, &pkg) == nil {
+			var commands []string
+			for _, script := range []string{"build", "lint"} {
+				if pkg.Scripts[script] != "" {
+					commands = append(commands, "npm run "+script+" --silent")
+				}
+			}
+			if len(commands) > 0 {
+				return commands, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("no build or lint commands found in %s; set RIGEL_CHECK_COMMANDS", dir)
+}
+
+// Execute runs the checks and describes the problems found
+func (c *CheckTool) Execute(ctx context.Context, input string) (string, error) {
+	result, err := c.Run(ctx)
+	if err != nil {
+		return "", err
+	}
+	return result.String(), nil
+}
+
+// Run runs each check command and collects their diagnostics
+func (c *CheckTool) Run(ctx context.Context) (*CheckResult, error) {
+	commands := c.commands
+	if len(commands) == 0 {
+		var err error
+		if commands, err = DetectCheckCommands(c.dir); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &CheckResult{}
+	for _, command := range commands {
+		output, err := c.run(ctx, command)
+		if err != nil {
+			return nil, err
+		}
+		if output == nil {
+			continue
+		}
+
+		diagnostics := parseDiagnostics(c.dir, command, output)
+		if len(diagnostics) == 0 {
+			result.Failed = append(result.Failed, fmt.Sprintf("%s failed:\n%s", command, lastLines(string(output), maxOutputLines)))
+		}
+		result.Diagnostics = append(result.Diagnostics, diagnostics...)
+	}
+	return result, nil
+}
+
+// run runs a command and returns its output if it failed, or nil if it
+// succeeded
+func (c *CheckTool) run(ctx context.Context, command string) ([]byte, error) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, fields[0], fields[1:]...)
+	cmd.Dir = c.dir
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	err := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("%s timed out after %v", command, c.timeout)
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return output.Bytes(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to run %s: %w", command, err)
+	}
+	return nil, nil
+}
+
+// diagnosticLine matches file:line[:column]: message
+var diagnosticLine = regexp.MustCompile(`^(\S+?\.\w+):(\d+)(?::(\d+))?:\s*(.+)$`)
+
+// parseDiagnostics extracts file:line diagnostics from a command's output.
+// Paths are relative to dir unless absolute.
+func parseDiagnostics(dir, command string, output []byte) []Diagnostic {
+	source := strings.Fields(command)[0]
+	var diagnostics []Diagnostic
+	for _, line := range strings.Split(string(output), "\n") {
+		m := diagnosticLine.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		lineNumber, _ := strconv.Atoi(m[2])
+		column, _ := strconv.Atoi(m[3])
+
+		path := m[1]
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			abs = path
+		}
+
+		diagnostics = append(diagnostics, Diagnostic{
+			Path:    abs,
+			File:    filepath.Clean(m[1]),
+			Line:    lineNumber,
+			Column:  column,
+			Message: m[4],
+			Source:  source,
+		})
+	}
+	return diagnostics
+}
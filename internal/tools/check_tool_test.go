@@ -0,0 +1,93 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDiagnostics(t *testing.T) {
+	dir := t.TempDir()
+	output := `# example.com/demo
+./main.go:5:2: undefined: fmt
+internal/util/util.go:12: missing return
+main.go:8:6: func unused is unused (unused)
+Error: build failed
+`
+
+	diagnostics := parseDiagnostics(dir, "golangci-lint run", []byte(output))
+	assert.Equal(t, []Diagnostic{
+		{Path: filepath.Join(dir, "main.go"), File: "main.go", Line: 5, Column: 2, Message: "undefined: fmt", Source: "golangci-lint"},
+		{Path: filepath.Join(dir, "internal/util/util.go"), File: "internal/util/util.go", Line: 12, Message: "missing return", Source: "golangci-lint"},
+		{Path: filepath.Join(dir, "main.go"), File: "main.go", Line: 8, Column: 6, Message: "func unused is unused (unused)", Source: "golangci-lint"},
+	}, diagnostics)
+	assert.Equal(t, "main.go:5:2: undefined: fmt (golangci-lint)", diagnostics[0].String())
+	assert.Equal(t, "internal/util/util.go:12: missing return (golangci-lint)", diagnostics[1].String())
+}
+
+func TestCheckResult(t *testing.T) {
+	result := &CheckResult{Diagnostics: []Diagnostic{
+		{Path: "/repo/main.go", File: "main.go", Line: 5, Message: "undefined: x", Source: "go"},
+		{Path: "/repo/util.go", File: "util.go", Line: 1, Message: "syntax error", Source: "go"},
+	}}
+
+	assert.False(t, result.OK())
+	assert.Len(t, result.ForFile("/repo/main.go"), 1)
+	assert.Empty(t, result.ForFile("/repo/other.go"))
+	assert.Equal(t, "2 problem(s) found:\nmain.go:5: undefined: x (go)\nutil.go:1: syntax error (go)", result.String())
+
+	assert.Equal(t, "No problems found", (&CheckResult{}).String())
+}
+
+func TestDetectCheckCommands(t *testing.T) {
+	t.Run("npm", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "package.json"), []byte(`{"scripts": {"lint": "eslint ."}}`), 0o644))
+
+		commands, err := DetectCheckCommands(dir)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"npm run lint --silent"}, commands)
+	})
+
+	t.Run("go module", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/demo\n"), 0o644))
+
+		commands, err := DetectCheckCommands(dir)
+		require.NoError(t, err)
+		assert.Equal(t, "go build ./...", commands[0])
+	})
+
+	t.Run("nothing", func(t *testing.T) {
+		_, err := DetectCheckCommands(t.TempDir())
+		assert.ErrorContains(t, err, "RIGEL_CHECK_COMMANDS")
+	})
+}
+
+func TestCheckToolRun(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go is not installed")
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/demo\n\ngo 1.21\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {\n\tundefinedFunc()\n}\n"), 0o644))
+
+	tool := NewCheckTool(dir, []string{"go build ./..."})
+	result, err := tool.Run(context.Background())
+	require.NoError(t, err)
+	require.Len(t, result.Diagnostics, 1)
+	assert.Equal(t, filepath.Join(dir, "main.go"), result.Diagnostics[0].Path)
+	assert.Equal(t, 4, result.Diagnostics[0].Line)
+	assert.Contains(t, result.Diagnostics[0].Message, "undefinedFunc")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0o644))
+	output, err := tool.Execute(context.Background(), "")
+	require.NoError(t, err)
+	assert.Equal(t, "No problems found", output)
+}
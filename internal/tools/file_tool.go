@@ -1,23 +1,120 @@
 package tools
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"fmt"
+	"io/fs"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/mizzy/rigel/internal/workspace"
+)
+
+const (
+	// maxSearchMatches limits how many matching lines a search returns
+	maxSearchMatches = 100
+
+	// DefaultMaxReadSize is the largest file read returns, in bytes, so a
+	// single file can't fill the model's context
+	DefaultMaxReadSize = 256 * 1024
+
+	// DefaultMaxWriteSize is the largest content write accepts, in bytes
+	DefaultMaxWriteSize = 1024 * 1024
+
+	// binarySniffLength is how much of a file is checked for binary content
+	binarySniffLength = 8000
 )
 
 type FileTool struct {
 	BaseTool
+	workspace    *workspace.Workspace
+	maxReadSize  int
+	maxWriteSize int
 }
 
 func NewFileTool() *FileTool {
 	return &FileTool{
 		BaseTool: BaseTool{
 			name:        "file_operations",
-			description: "Perform file operations like read, write, and list files",
+			description: "Perform file operations like read, write, list, tree and search files",
 		},
+		maxReadSize:  DefaultMaxReadSize,
+		maxWriteSize: DefaultMaxWriteSize,
+	}
+}
+
+// SetWorkspace makes the tool operate across the workspace's roots: paths
+// may address a root as label:path, search covers every root and writes to
+// read-only roots are refused. Without a workspace, the tool is confined to
+// the current directory.
+func (f *FileTool) SetWorkspace(ws *workspace.Workspace) {
+	f.workspace = ws
+}
+
+// SetSizeLimits sets the largest file read returns and the largest content
+// write accepts, in bytes; 0 or less removes a limit
+func (f *FileTool) SetSizeLimits(maxRead, maxWrite int) {
+	f.maxReadSize = maxRead
+	f.maxWriteSize = maxWrite
+}
+
+// resolve returns the canonical absolute path for path, refusing paths
+// outside the workspace, whether through .., an absolute path or a symlink,
+// and paths in read-only workspace roots when write is set
+func (f *FileTool) resolve(path string, write bool) (string, error) {
+	abs, _, err := f.resolveInRoot(path, write)
+	return abs, err
+}
+
+// resolveInRoot is resolve, also returning the workspace root the path is in
+func (f *FileTool) resolveInRoot(path string, write bool) (string, workspace.Root, error) {
+	ws := f.workspace
+	if ws == nil {
+		var err error
+		if ws, err = workspace.New("."); err != nil {
+			return "", workspace.Root{}, err
+		}
+	}
+
+	abs, root, ok := ws.Resolve(path)
+	if !ok {
+		return "", root, fmt.Errorf("%s is outside the workspace", path)
+	}
+	resolved, err := canonicalPath(abs)
+	if err != nil {
+		return "", root, err
+	}
+	if rootPath, err := canonicalPath(root.Path); err != nil || !workspace.Within(rootPath, resolved) {
+		return "", root, fmt.Errorf("%s resolves to %s, outside the workspace", path, resolved)
+	}
+	if write && root.ReadOnly {
+		return "", root, fmt.Errorf("%s is in read-only workspace root %s", abs, root.Label)
+	}
+	return abs, root, nil
+}
+
+// canonicalPath resolves the symlinks in the part of path that exists, so
+// files that don't exist yet can be checked too
+func canonicalPath(path string) (string, error) {
+	var missing []string
+	for {
+		real, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(append([]string{real}, missing...)...), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", fmt.Errorf("failed to resolve %s: %w", path, err)
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return "", err
+		}
+		missing = append([]string{filepath.Base(path)}, missing...)
+		path = parent
 	}
 }
 
@@ -37,11 +134,11 @@ func (f *FileTool) Execute(ctx context.Context, input string) (string, error) {
 		}
 		return f.readFile(args[0])
 	case "write":
-		if len(args) < 2 {
-			return "", fmt.Errorf("usage: write <path> <content>")
+		path, content, ok := writeInput(input)
+		if !ok {
+			return "", fmt.Errorf("usage: write <path>, then the content on the next lines")
 		}
-		content := strings.Join(args[1:], " ")
-		return f.writeFile(args[0], content)
+		return f.writeFile(path, content)
 	case "list":
 		path := "."
 		if len(args) > 0 {
@@ -58,30 +155,87 @@ func (f *FileTool) Execute(ctx context.Context, input string) (string, error) {
 			return "", fmt.Errorf("no file path specified")
 		}
 		return f.deleteFile(args[0])
+	case "tree":
+		return f.tree(ctx, args)
+	case "search":
+		if len(args) == 0 {
+			return "", fmt.Errorf("no search text specified")
+		}
+		return f.search(ctx, strings.Join(args, " "))
 	default:
 		return "", fmt.Errorf("unknown operation: %s", operation)
 	}
 }
 
+// writeInput splits the input of the write operation into the path, on the
+// first line, and the content, verbatim, on the lines after it. Content
+// without newlines may follow the path on the first line instead.
+func writeInput(input string) (path, content string, ok bool) {
+	rest := strings.TrimPrefix(strings.TrimLeft(input, " \t"), "write")
+	header, content, multiline := strings.Cut(rest, "\n")
+	path = strings.TrimSpace(header)
+	if !multiline {
+		path, content, ok = strings.Cut(path, " ")
+		return path, content, ok && path != ""
+	}
+	return path, content, path != ""
+}
+
+// AbsPath returns the absolute path the tool uses for path
+func (f *FileTool) AbsPath(path string) (string, error) {
+	return f.resolve(path, false)
+}
+
+// Read returns the content of path within the size limit, or a note if it
+// is a binary file
+func (f *FileTool) Read(path string) (string, error) {
+	return f.readFile(path)
+}
+
+// Write writes content to path as is, like the write operation
+func (f *FileTool) Write(path, content string) (string, error) {
+	return f.writeFile(path, content)
+}
+
 func (f *FileTool) readFile(path string) (string, error) {
-	absPath, err := filepath.Abs(path)
+	absPath, err := f.resolve(path, false)
 	if err != nil {
 		return "", err
 	}
 
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	if f.maxReadSize > 0 && info.Size() > int64(f.maxReadSize) {
+		return "", fmt.Errorf("%s is %d bytes, larger than the %d byte read limit", path, info.Size(), f.maxReadSize)
+	}
+
 	content, err := os.ReadFile(absPath)
 	if err != nil {
 		return "", fmt.Errorf("failed to read file: %w", err)
 	}
+	if isBinary(content) {
+		return fmt.Sprintf("Binary file: %s (%d bytes, %s)", absPath, len(content), http.DetectContentType(content)), nil
+	}
 
 	return string(content), nil
 }
 
+// isBinary reports whether content looks like a binary file: like git, it
+// checks the start of the file for NUL bytes
+func isBinary(content []byte) bool {
+	return bytes.IndexByte(content[:min(len(content), binarySniffLength)], 0) >= 0
+}
+
 func (f *FileTool) writeFile(path, content string) (string, error) {
-	absPath, err := filepath.Abs(path)
+	absPath, err := f.resolve(path, true)
 	if err != nil {
 		return "", err
 	}
+	if f.maxWriteSize > 0 && len(content) > f.maxWriteSize {
+		return "", fmt.Errorf("refusing to write %d bytes to %s, more than the %d byte write limit", len(content), path, f.maxWriteSize)
+	}
 
 	dir := filepath.Dir(absPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -96,7 +250,7 @@ func (f *FileTool) writeFile(path, content string) (string, error) {
 }
 
 func (f *FileTool) listFiles(path string) (string, error) {
-	absPath, err := filepath.Abs(path)
+	absPath, err := f.resolve(path, false)
 	if err != nil {
 		return "", err
 	}
@@ -126,7 +280,7 @@ func (f *FileTool) listFiles(path string) (string, error) {
 }
 
 func (f *FileTool) checkExists(path string) (string, error) {
-	absPath, err := filepath.Abs(path)
+	absPath, err := f.resolve(path, false)
 	if err != nil {
 		return "", err
 	}
@@ -149,7 +303,7 @@ func (f *FileTool) checkExists(path string) (string, error) {
 }
 
 func (f *FileTool) deleteFile(path string) (string, error) {
-	absPath, err := filepath.Abs(path)
+	absPath, err := f.resolve(path, true)
 	if err != nil {
 		return "", err
 	}
@@ -160,3 +314,82 @@ func (f *FileTool) deleteFile(path string) (string, error) {
 
 	return fmt.Sprintf("File deleted successfully: %s", absPath), nil
 }
+
+// search finds lines containing text in the files of every workspace root,
+// or of the current directory without a workspace
+func (f *FileTool) search(ctx context.Context, text string) (string, error) {
+	roots := []workspace.Root{{Path: "."}}
+	if f.workspace != nil {
+		roots = f.workspace.Roots()
+	}
+
+	var matches []string
+	for i, root := range roots {
+		prefix := ""
+		if i > 0 {
+			prefix = root.Label + ":"
+		}
+		err := filepath.WalkDir(root.Path, func(path string, d fs.DirEntry, err error) error {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			if len(matches) >= maxSearchMatches {
+				return fs.SkipAll
+			}
+			if err != nil {
+				return nil // Skip files that can't be accessed
+			}
+			name := d.Name()
+			if d.IsDir() {
+				if path != root.Path && (strings.HasPrefix(name, ".") || name == "vendor" || name == "node_modules") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if strings.HasPrefix(name, ".") {
+				return nil
+			}
+
+			rel, _ := filepath.Rel(root.Path, path)
+			for _, m := range searchFile(path, text, maxSearchMatches-len(matches)) {
+				matches = append(matches, prefix+rel+":"+m)
+			}
+			return nil
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to search files: %w", err)
+		}
+	}
+
+	if len(matches) == 0 {
+		return fmt.Sprintf("No matches for %q", text), nil
+	}
+	result := strings.Join(matches, "\n")
+	if len(matches) >= maxSearchMatches {
+		result += fmt.Sprintf("\n(stopped after %d matches)", maxSearchMatches)
+	}
+	return result, nil
+}
+
+// searchFile returns up to limit lines of a text file containing text, as
+// line:content
+func searchFile(path, text string, limit int) []string {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var matches []string
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan() && len(matches) < limit; n++ {
+		line := scanner.Text()
+		if strings.ContainsRune(line, 0) {
+			return nil // Binary file
+		}
+		if strings.Contains(line, text) {
+			matches = append(matches, fmt.Sprintf("%d: %s", n, strings.TrimSpace(line)))
+		}
+	}
+	return matches
+}
@@ -0,0 +1,166 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mizzy/rigel/internal/workspace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileToolWorkspace(t *testing.T) {
+	base := t.TempDir()
+	app := filepath.Join(base, "app")
+	lib := filepath.Join(base, "lib")
+	for _, dir := range []string{app, lib} {
+		require.NoError(t, os.MkdirAll(dir, 0o755))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(app, "main.go"), []byte("package main\n\nfunc main() { Greet() }\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(lib, "greet.go"), []byte("package lib\n\nfunc Greet() {}\n"), 0o644))
+
+	ws, err := workspace.New(app)
+	require.NoError(t, err)
+	_, err = ws.Add(lib, true)
+	require.NoError(t, err)
+
+	tool := NewFileTool()
+	tool.SetWorkspace(ws)
+	ctx := context.Background()
+
+	t.Run("reads a labeled path", func(t *testing.T) {
+		content, err := tool.Execute(ctx, "read lib:greet.go")
+		require.NoError(t, err)
+		assert.Contains(t, content, "func Greet()")
+	})
+
+	t.Run("relative paths resolve against the primary root", func(t *testing.T) {
+		content, err := tool.Execute(ctx, "read main.go")
+		require.NoError(t, err)
+		assert.Contains(t, content, "package main")
+	})
+
+	t.Run("refuses writes to a read-only root", func(t *testing.T) {
+		_, err := tool.Execute(ctx, "write lib:new.go package lib")
+		assert.ErrorContains(t, err, "read-only")
+		assert.NoFileExists(t, filepath.Join(lib, "new.go"))
+
+		_, err = tool.Execute(ctx, "delete lib:greet.go")
+		assert.ErrorContains(t, err, "read-only")
+	})
+
+	t.Run("writes to the primary root", func(t *testing.T) {
+		_, err := tool.Execute(ctx, "write notes.txt hello")
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(app, "notes.txt"))
+	})
+
+	t.Run("searches every root", func(t *testing.T) {
+		result, err := tool.Execute(ctx, "search Greet()")
+		require.NoError(t, err)
+		assert.Contains(t, result, "main.go:3: func main() { Greet() }")
+		assert.Contains(t, result, "lib:greet.go:3: func Greet() {}")
+	})
+
+	t.Run("reports no matches", func(t *testing.T) {
+		result, err := tool.Execute(ctx, "search nothing-matches-this")
+		require.NoError(t, err)
+		assert.Contains(t, result, "No matches")
+	})
+}
+
+func TestFileToolWriteKeepsContent(t *testing.T) {
+	dir := t.TempDir()
+	ws, err := workspace.New(dir)
+	require.NoError(t, err)
+	tool := NewFileTool()
+	tool.SetWorkspace(ws)
+	ctx := context.Background()
+
+	content := "package main\n\nfunc main() {\n\tprintln(\"a  b\")\n}\n"
+	_, err = tool.Execute(ctx, "write main.go\n"+content)
+	require.NoError(t, err)
+	data, err := os.ReadFile(filepath.Join(dir, "main.go"))
+	require.NoError(t, err)
+	assert.Equal(t, content, string(data), "the lines after the path are written verbatim")
+
+	_, err = tool.Execute(ctx, "write notes.txt two  spaces")
+	require.NoError(t, err)
+	data, err = os.ReadFile(filepath.Join(dir, "notes.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "two  spaces", string(data))
+
+	_, err = tool.Execute(ctx, "write empty.txt\n")
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(dir, "empty.txt"))
+
+	_, err = tool.Execute(ctx, "write notes.txt")
+	assert.ErrorContains(t, err, "usage: write")
+}
+
+func TestFileToolConfinesPaths(t *testing.T) {
+	base := t.TempDir()
+	app := filepath.Join(base, "app")
+	require.NoError(t, os.MkdirAll(app, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(base, "secret.txt"), []byte("secret"), 0o644))
+	require.NoError(t, os.Symlink(base, filepath.Join(app, "escape")))
+
+	ws, err := workspace.New(app)
+	require.NoError(t, err)
+	tool := NewFileTool()
+	tool.SetWorkspace(ws)
+	ctx := context.Background()
+
+	for _, input := range []string{
+		"read ../secret.txt",
+		"read " + filepath.Join(base, "secret.txt"),
+		"read escape/secret.txt",
+		"write ../evil.txt x",
+		"write escape/new/evil.txt x",
+		"delete escape/secret.txt",
+	} {
+		t.Run(input, func(t *testing.T) {
+			_, err := tool.Execute(ctx, input)
+			assert.ErrorContains(t, err, "outside the workspace")
+		})
+	}
+	assert.NoFileExists(t, filepath.Join(base, "evil.txt"))
+	assert.NoDirExists(t, filepath.Join(base, "new"))
+	assert.FileExists(t, filepath.Join(base, "secret.txt"))
+
+	_, err = tool.Execute(ctx, "write sub/dir/../ok.txt fine")
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(app, "sub", "ok.txt"))
+}
+
+func TestFileToolLimits(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "big.txt"), []byte(strings.Repeat("x", 100)), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "image.png"), []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), 0o644))
+
+	ws, err := workspace.New(dir)
+	require.NoError(t, err)
+	tool := NewFileTool()
+	tool.SetWorkspace(ws)
+	tool.SetSizeLimits(50, 10)
+	ctx := context.Background()
+
+	_, err = tool.Execute(ctx, "read big.txt")
+	assert.ErrorContains(t, err, "big.txt is 100 bytes, larger than the 50 byte read limit")
+
+	_, err = tool.Execute(ctx, "write small.txt this is too long")
+	assert.ErrorContains(t, err, "more than the 10 byte write limit")
+	assert.NoFileExists(t, filepath.Join(dir, "small.txt"))
+
+	summary, err := tool.Execute(ctx, "read image.png")
+	require.NoError(t, err)
+	assert.Equal(t, "Binary file: "+filepath.Join(dir, "image.png")+" (16 bytes, image/png)", summary)
+
+	tool.SetSizeLimits(0, 0)
+	content, err := tool.Execute(ctx, "read big.txt")
+	require.NoError(t, err)
+	assert.Len(t, content, 100)
+}
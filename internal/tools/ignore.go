@@ -0,0 +1,106 @@
+package tools
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreRule is a pattern of a .gitignore file
+type ignoreRule struct {
+	base     string   // Directory of the .gitignore, relative to the root, "" for the root
+	segments []string // Pattern split at slashes
+	anchored bool     // Matches paths relative to base rather than names
+	negate   bool     // Re-includes what earlier rules ignored
+	dirOnly  bool
+}
+
+// readIgnoreFile returns the rules of the .gitignore in dir, whose path
+// relative to the root is base. A missing file has no rules.
+func readIgnoreFile(dir, base string) []ignoreRule {
+	file, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{base: base}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		// A slash other than a trailing one anchors the pattern
+		rule.anchored = strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+		if line == "" {
+			continue
+		}
+		rule.segments = strings.Split(line, "/")
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// ignored reports whether rules ignore the entry at rel, a slash-separated
+// path relative to the root. Later rules take precedence, as in git.
+func ignored(rules []ignoreRule, rel string, isDir bool) bool {
+	result := false
+	for _, rule := range rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		sub := rel
+		if rule.base != "" {
+			if !strings.HasPrefix(rel, rule.base+"/") {
+				continue
+			}
+			sub = strings.TrimPrefix(rel, rule.base+"/")
+		}
+		var match bool
+		if rule.anchored {
+			match = matchSegments(rule.segments, strings.Split(sub, "/"))
+		} else {
+			match, _ = path.Match(rule.segments[0], path.Base(sub))
+		}
+		if match {
+			result = !rule.negate
+		}
+	}
+	return result
+}
+
+// matchSegments matches a path against a pattern, both split at slashes,
+// where a "**" segment matches any number of segments
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], segments[1:])
+}
@@ -0,0 +1,32 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mizzy/rigel/internal/notes"
+)
+
+// RememberTool stores durable facts about the project, which are included
+// in the system prompt of every future session
+type RememberTool struct {
+	BaseTool
+}
+
+// NewRememberTool creates a tool that adds notes to .rigel/memory.md
+func NewRememberTool() *RememberTool {
+	return &RememberTool{
+		BaseTool: BaseTool{
+			name:        "remember",
+			description: "Remember a fact about the project, such as a convention or decision, in every future session",
+		},
+	}
+}
+
+// Execute adds input as a note
+func (t *RememberTool) Execute(ctx context.Context, input string) (string, error) {
+	if err := notes.Add(input); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Remembered in %s: %s", notes.Path, input), nil
+}
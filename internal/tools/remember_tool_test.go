@@ -0,0 +1,27 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mizzy/rigel/internal/notes"
+)
+
+func TestRememberTool(t *testing.T) {
+	t.Chdir(t.TempDir())
+	tool := NewRememberTool()
+
+	output, err := tool.Execute(context.Background(), "we use uber-fx for DI")
+	require.NoError(t, err)
+	assert.Contains(t, output, "we use uber-fx for DI")
+
+	loaded, err := notes.Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"we use uber-fx for DI"}, loaded)
+
+	_, err = tool.Execute(context.Background(), "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,303 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultTestTimeout limits how long a test run may take
+	defaultTestTimeout = 10 * time.Minute
+
+	// maxFailureLines limits the output kept for each failing test
+	maxFailureLines = 30
+
+	// maxOutputLines limits the output kept from a test command whose
+	// results can't be parsed
+	maxOutputLines = 60
+)
+
+// TestRunnerTool runs the project's tests and summarizes the failures
+type TestRunnerTool struct {
+	BaseTool
+	dir     string
+	command []string // Configured test command; detected from the project if empty
+	timeout time.Duration
+}
+
+// NewTestRunnerTool creates a tool that runs tests in dir with command, or
+// with a command detected from go.mod, package.json or a Makefile if
+// command is empty
+func NewTestRunnerTool(dir, command string) *TestRunnerTool {
+	return &TestRunnerTool{
+		BaseTool: BaseTool{
+			name:        "run_tests",
+			description: "Run the project's tests and report the failing tests with their output",
+		},
+		dir:     dir,
+		command: strings.Fields(command),
+		timeout: defaultTestTimeout,
+	}
+}
+
+// SetTimeout sets how long a test run may take; 0 removes the limit
+func (t *TestRunnerTool) SetTimeout(timeout time.Duration) {
+	t.timeout = timeout
+}
+
+// makeTestTarget matches a test target in a Makefile
+var makeTestTarget = regexp.MustCompile(`(?m)^test\s*:`)
+
+// DetectTestCommand returns the command that runs the tests of the project
+// in dir. Go tests are run with -json so their results can be parsed.
+func DetectTestCommand(dir string) ([]string, error) {
+	if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+		return []string{"go", "test", "-json", "./..."}, nil
+	}
+
+	if data, err := os.ReadFile(filepath.Join(dir, "package.json")); err == nil {
+		var pkg struct {
+			Scripts map[string]string `json:"scripts"`
+		}
+		if json.Unmarshal(data, &pkg) == nil && pkg.Scripts["test"] != "" {
+			return []string{"npm", "test", "--silent"}, nil
+		}
+	}
+
+	if data, err := os.ReadFile(filepath.Join(dir, "Makefile")); err == nil && makeTestTarget.Match(data) {
+		return []string{"make", "test"}, nil
+	}
+
+	return nil, fmt.Errorf("no test command found in %s; set RIGEL_TEST_COMMAND", dir)
+}
+
+// Execute runs the tests. Input, if any, is appended to the command, e.g.
+// a package pattern such as ./internal/llm/... for Go.
+func (t *TestRunnerTool) Execute(ctx context.Context, input string) (string, error) {
+	command := t.command
+	if len(command) == 0 {
+		var err error
+		if command, err = DetectTestCommand(t.dir); err != nil {
+			return "", err
+		}
+	}
+	args := append([]string{}, command[1:]...)
+	if extra := strings.Fields(input); len(extra) > 0 {
+		if isGoTest(command) && args[len(args)-1] == "./..." {
+			args = args[:len(args)-1] // Replace the default package pattern
+		}
+		args = append(args, extra...)
+	}
+
+	if t.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, command[0], args...)
+	cmd.Dir = t.dir
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	start := time.Now()
+	err := cmd.Run()
+	elapsed := time.Since(start).Round(time.Millisecond)
+	commandLine := strings.Join(append([]string{command[0]}, args...), " ")
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return "", fmt.Errorf("%s timed out after %v", commandLine, t.timeout)
+	}
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return "", fmt.Errorf("failed to run %s: %w", commandLine, err)
+	}
+
+	if isGoTest(command) {
+		if summary, ok := ParseGoTestJSON(output.Bytes()); ok {
+			return fmt.Sprintf("$ %s (%v)\n%s", commandLine, elapsed, summary), nil
+		}
+	}
+
+	status := "passed"
+	if err != nil {
+		status = fmt.Sprintf("failed (%v)", err)
+	}
+	return fmt.Sprintf("$ %s (%v)\nTests %s\n%s", commandLine, elapsed, status, lastLines(output.String(), maxOutputLines)), nil
+}
+
+// isGoTest reports whether command runs go test with JSON output
+func isGoTest(command []string) bool {
+	if len(command) < 2 || command[0] != "go" || command[1] != "test" {
+		return false
+	}
+	for _, arg := range command[2:] {
+		if arg == "-json" {
+			return true
+		}
+	}
+	return false
+}
+
+// TestFailure is a failing test or package and its output
+type TestFailure struct {
+	Package string
+	Test    string // Empty if the package itself failed, e.g. to build
+	Output  []string
+}
+
+// TestSummary is the parsed result of a test run
+type TestSummary struct {
+	Passed   int
+	Failed   int
+	Skipped  int
+	Failures []TestFailure
+}
+
+func (s *TestSummary) String() string {
+	var sb strings.Builder
+	if len(s.Failures) == 0 {
+		sb.WriteString(fmt.Sprintf("All tests passed: %d passed, %d skipped", s.Passed, s.Skipped))
+		return sb.String()
+	}
+
+	sb.WriteString(fmt.Sprintf("Tests failed: %d passed, %d failed, %d skipped\n", s.Passed, s.Failed, s.Skipped))
+	for _, failure := range s.Failures {
+		if failure.Test == "" {
+			sb.WriteString(fmt.Sprintf("\nFAIL package %s\n", failure.Package))
+		} else {
+			sb.WriteString(fmt.Sprintf("\nFAIL %s (%s)\n", failure.Test, failure.Package))
+		}
+		for _, line := range failure.Output {
+			sb.WriteString("    " + line + "\n")
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// goTestEvent is a line of go test -json output
+type goTestEvent struct {
+	Action     string `json:"Action"`
+	Package    string `json:"Package"`
+	ImportPath string `json:"ImportPath"` // Set instead of Package on build output
+	Test       string `json:"Test"`
+	Output     string `json:"Output"`
+}
+
+// ParseGoTestJSON summarizes go test -json output. Build errors, which may
+// be printed as plain text, are attributed to the failing package. It
+// returns false if the output contains no test events.
+func ParseGoTestJSON(output []byte) (*TestSummary, bool) {
+	summary := &TestSummary{}
+	outputs := map[string][]string{} // By package and test
+	var plain []string
+	failedTests := map[string]bool{} // Packages with failing tests
+	events := 0
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var event goTestEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil || event.Action == "" {
+			if line := strings.TrimRight(scanner.Text(), " \t"); line != "" {
+				plain = append(plain, line)
+			}
+			continue
+		}
+		events++
+
+		key := event.Package + "\x00" + event.Test
+		switch event.Action {
+		case "output":
+			if line := strings.TrimRight(event.Output, "\n"); !isTestFrameLine(line) {
+				outputs[key] = append(outputs[key], strings.TrimPrefix(line, "    "))
+			}
+		case "build-output":
+			// ImportPath is the package, followed by the test binary in brackets
+			pkg, _, _ := strings.Cut(event.ImportPath, " ")
+			if line := strings.TrimRight(event.Output, "\n"); !strings.HasPrefix(line, "# ") {
+				outputs[pkg+"\x00"] = append(outputs[pkg+"\x00"], line)
+			}
+		case "pass":
+			if event.Test != "" {
+				summary.Passed++
+			}
+		case "skip":
+			if event.Test != "" {
+				summary.Skipped++
+			}
+		case "fail":
+			if event.Test != "" && hasFailedSubtest(summary.Failures, event.Package, event.Test) {
+				break // The failing subtests are already reported
+			}
+			if event.Test != "" {
+				summary.Failed++
+				failedTests[event.Package] = true
+				summary.Failures = append(summary.Failures, TestFailure{
+					Package: event.Package,
+					Test:    event.Test,
+					Output:  truncateLines(outputs[key], maxFailureLines),
+				})
+			} else if !failedTests[event.Package] {
+				// The package failed without a failing test, e.g. it didn't build
+				lines := append(plain, outputs[key]...)
+				summary.Failures = append(summary.Failures, TestFailure{
+					Package: event.Package,
+					Output:  truncateLines(lines, maxFailureLines),
+				})
+				plain = nil
+			}
+		}
+	}
+	return summary, events > 0
+}
+
+// hasFailedSubtest reports whether a subtest of test has failed
+func hasFailedSubtest(failures []TestFailure, pkg, test string) bool {
+	for _, failure := range failures {
+		if failure.Package == pkg && strings.HasPrefix(failure.Test, test+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// isTestFrameLine reports whether a line of test output is one of go test's
+// own status lines rather than output from the test
+func isTestFrameLine(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	for _, prefix := range []string{"=== RUN", "=== PAUSE", "=== CONT", "--- PASS", "--- SKIP", "--- FAIL", "PASS", "FAIL", "ok  "} {
+		if strings.HasPrefix(trimmed, prefix) {
+			return true
+		}
+	}
+	return trimmed == ""
+}
+
+// truncateLines keeps the first n lines
+func truncateLines(lines []string, n int) []string {
+	if len(lines) <= n {
+		return lines
+	}
+	return append(lines[:n:n], fmt.Sprintf("... (%d more lines)", len(lines)-n))
+}
+
+// lastLines keeps the last n lines of s
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) <= n {
+		return strings.Join(lines, "\n")
+	}
+	return fmt.Sprintf("... (%d lines omitted)\n%s", len(lines)-n, strings.Join(lines[len(lines)-n:], "\n"))
+}
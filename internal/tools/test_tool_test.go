@@ -0,0 +1,126 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const goTestOutput = `{"Action":"start","Package":"example.com/demo/a"}
+{"Action":"run","Package":"example.com/demo/a","Test":"TestOK"}
+{"Action":"output","Package":"example.com/demo/a","Test":"TestOK","Output":"=== RUN   TestOK\n"}
+{"Action":"output","Package":"example.com/demo/a","Test":"TestOK","Output":"--- PASS: TestOK (0.00s)\n"}
+{"Action":"pass","Package":"example.com/demo/a","Test":"TestOK"}
+{"Action":"run","Package":"example.com/demo/a","Test":"TestBad"}
+{"Action":"output","Package":"example.com/demo/a","Test":"TestBad","Output":"=== RUN   TestBad\n"}
+{"Action":"output","Package":"example.com/demo/a","Test":"TestBad","Output":"    a_test.go:6: want 1, got 2\n"}
+{"Action":"output","Package":"example.com/demo/a","Test":"TestBad","Output":"--- FAIL: TestBad (0.00s)\n"}
+{"Action":"fail","Package":"example.com/demo/a","Test":"TestBad"}
+{"Action":"run","Package":"example.com/demo/a","Test":"TestSub"}
+{"Action":"run","Package":"example.com/demo/a","Test":"TestSub/x"}
+{"Action":"output","Package":"example.com/demo/a","Test":"TestSub/x","Output":"    a_test.go:8: boom\n"}
+{"Action":"fail","Package":"example.com/demo/a","Test":"TestSub/x"}
+{"Action":"skip","Package":"example.com/demo/a","Test":"TestSub/y"}
+{"Action":"fail","Package":"example.com/demo/a","Test":"TestSub"}
+{"Action":"output","Package":"example.com/demo/a","Output":"FAIL\texample.com/demo/a\t0.003s\n"}
+{"Action":"fail","Package":"example.com/demo/a"}
+{"ImportPath":"example.com/demo/b [example.com/demo/b.test]","Action":"build-output","Output":"# example.com/demo/b [example.com/demo/b.test]\n"}
+{"ImportPath":"example.com/demo/b [example.com/demo/b.test]","Action":"build-output","Output":"b/b.go:3:23: cannot use \"x\" (untyped string constant) as int value in return statement\n"}
+{"ImportPath":"example.com/demo/b [example.com/demo/b.test]","Action":"build-fail"}
+{"Action":"start","Package":"example.com/demo/b"}
+{"Action":"output","Package":"example.com/demo/b","Output":"FAIL\texample.com/demo/b [build failed]\n"}
+{"Action":"fail","Package":"example.com/demo/b"}
+`
+
+func TestParseGoTestJSON(t *testing.T) {
+	summary, ok := ParseGoTestJSON([]byte(goTestOutput))
+	require.True(t, ok)
+
+	assert.Equal(t, 1, summary.Passed)
+	assert.Equal(t, 2, summary.Failed, "a parent of a failing subtest isn't counted again")
+	assert.Equal(t, 1, summary.Skipped)
+	assert.Equal(t, []TestFailure{
+		{Package: "example.com/demo/a", Test: "TestBad", Output: []string{"a_test.go:6: want 1, got 2"}},
+		{Package: "example.com/demo/a", Test: "TestSub/x", Output: []string{"a_test.go:8: boom"}},
+		{Package: "example.com/demo/b", Output: []string{`b/b.go:3:23: cannot use "x" (untyped string constant) as int value in return statement`}},
+	}, summary.Failures)
+
+	assert.Equal(t, `Tests failed: 1 passed, 2 failed, 1 skipped
+
+FAIL TestBad (example.com/demo/a)
+    a_test.go:6: want 1, got 2
+
+FAIL TestSub/x (example.com/demo/a)
+    a_test.go:8: boom
+
+FAIL package example.com/demo/b
+    b/b.go:3:23: cannot use "x" (untyped string constant) as int value in return statement`, summary.String())
+}
+
+func TestParseGoTestJSON_NotJSON(t *testing.T) {
+	_, ok := ParseGoTestJSON([]byte("ok  \texample.com/demo\t0.01s\n"))
+	assert.False(t, ok)
+}
+
+func TestDetectTestCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		command []string
+	}{
+		{"go module", map[string]string{"go.mod": "module example.com/demo\n"}, []string{"go", "test", "-json", "./..."}},
+		{"npm", map[string]string{"package.json": `{"scripts": {"test": "jest"}}`}, []string{"npm", "test", "--silent"}},
+		{"npm without tests", map[string]string{"package.json": `{"scripts": {}}`}, nil},
+		{"make", map[string]string{"Makefile": "build:\n\tgo build\n\ntest: build\n\tgo test\n"}, []string{"make", "test"}},
+		{"nothing", map[string]string{"README.md": "# Demo\n"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+			}
+
+			command, err := DetectTestCommand(dir)
+			if tt.command == nil {
+				assert.ErrorContains(t, err, "RIGEL_TEST_COMMAND")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.command, command)
+		})
+	}
+}
+
+func TestTestRunnerTool(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go is not installed")
+	}
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":       "module example.com/demo\n\ngo 1.21\n",
+		"demo_test.go": "package demo\n\nimport \"testing\"\n\nfunc TestPass(t *testing.T) {}\n\nfunc TestFail(t *testing.T) { t.Error(\"expected failure\") }\n",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+
+	result, err := NewTestRunnerTool(dir, "").Execute(context.Background(), "")
+	require.NoError(t, err, "failing tests are reported, not returned as errors")
+	assert.Contains(t, result, "$ go test -json ./...")
+	assert.Contains(t, result, "Tests failed: 1 passed, 1 failed, 0 skipped")
+	assert.Contains(t, result, "FAIL TestFail (example.com/demo)")
+	assert.Contains(t, result, "demo_test.go:7: expected failure")
+
+	result, err = NewTestRunnerTool(dir, "").Execute(context.Background(), "-run TestPass .")
+	require.NoError(t, err)
+	assert.Contains(t, result, "$ go test -json -run TestPass .")
+	assert.Contains(t, result, "All tests passed: 1 passed, 0 skipped")
+}
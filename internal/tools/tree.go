@@ -0,0 +1,200 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// defaultTreeDepth is how many levels of directories tree shows unless
+	// given --depth
+	defaultTreeDepth = 3
+
+	// maxTreeEntries limits how many files and directories tree shows
+	maxTreeEntries = 300
+)
+
+// treeOptions are the arguments of the tree operation:
+// tree [path] [--depth n] [--glob pattern]
+type treeOptions struct {
+	path  string
+	depth int
+	glob  string
+}
+
+func parseTreeOptions(args []string) (treeOptions, error) {
+	opts := treeOptions{path: ".", depth: defaultTreeDepth}
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--depth", "-depth", "--glob", "-glob":
+			if i+1 == len(args) {
+				return opts, fmt.Errorf("%s needs a value", args[i])
+			}
+			if strings.HasSuffix(args[i], "glob") {
+				opts.glob = args[i+1]
+			} else if depth, err := strconv.Atoi(args[i+1]); err == nil && depth >= 1 {
+				opts.depth = depth
+			} else {
+				return opts, fmt.Errorf("invalid depth %q: must be a positive number", args[i+1])
+			}
+			i++
+		default:
+			opts.path = args[i]
+		}
+	}
+	return opts, nil
+}
+
+// treeWalker renders a directory tree, skipping what the .gitignore files
+// ignore
+type treeWalker struct {
+	ctx       context.Context
+	start     string // Directory the tree starts at
+	opts      treeOptions
+	entries   int
+	truncated bool // Whether entries were left out after maxTreeEntries
+}
+
+// tree renders the directory tree under a path as an indented list, down to
+// a depth and with only the files matching a glob if given, so the agent can
+// see the project layout at once
+func (f *FileTool) tree(ctx context.Context, args []string) (string, error) {
+	opts, err := parseTreeOptions(args)
+	if err != nil {
+		return "", err
+	}
+	start, root, err := f.resolveInRoot(opts.path, false)
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(start); err != nil {
+		return "", fmt.Errorf("failed to read directory: %w", err)
+	} else if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", opts.path)
+	}
+
+	// The .gitignore files from the root down to the start apply too
+	var rules []ignoreRule
+	rel, _ := filepath.Rel(root.Path, start)
+	dir, base := root.Path, ""
+	if rel != "." {
+		for _, name := range strings.Split(rel, string(filepath.Separator)) {
+			rules = append(rules, readIgnoreFile(dir, base)...)
+			dir = filepath.Join(dir, name)
+			base = path.Join(base, name)
+		}
+	}
+
+	w := &treeWalker{ctx: ctx, start: start, opts: opts}
+	lines, err := w.walk(start, base, rules, 0)
+	if err != nil {
+		return "", err
+	}
+	if len(lines) == 0 && opts.glob != "" {
+		return fmt.Sprintf("No files matching %q", opts.glob), nil
+	}
+
+	result := opts.path + "/\n" + strings.Join(lines, "\n")
+	if w.truncated {
+		result += fmt.Sprintf("\n(stopped after %d entries; give a subdirectory, --depth or --glob to see the rest)", maxTreeEntries)
+	}
+	return result, nil
+}
+
+// walk returns the lines of the tree of dir, whose path relative to the
+// root is rel, at the given level
+func (w *treeWalker) walk(dir, rel string, rules []ignoreRule, level int) ([]string, error) {
+	if err := w.ctx.Err(); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if level == 0 {
+			return nil, fmt.Errorf("failed to read directory: %w", err)
+		}
+		return nil, nil // Skip directories that can't be read
+	}
+	rules = append(rules[:len(rules):len(rules)], readIgnoreFile(dir, rel)...)
+
+	indent := strings.Repeat("  ", level+1)
+	var lines []string
+	for _, entry := range entries {
+		if w.entries == maxTreeEntries {
+			w.truncated = true
+			break
+		}
+		name := entry.Name()
+		entryRel := path.Join(rel, name)
+		if name == ".git" || ignored(rules, entryRel, entry.IsDir()) {
+			continue
+		}
+
+		if !entry.IsDir() {
+			if !w.matches(filepath.Join(dir, name)) {
+				continue
+			}
+			w.entries++
+			lines = append(lines, indent+name)
+			continue
+		}
+
+		if level+1 == w.opts.depth {
+			// Directories too deep to expand are listed with how many
+			// entries they have, unless filtering, as their files can't match
+			if w.opts.glob != "" {
+				continue
+			}
+			w.entries++
+			lines = append(lines, indent+name+"/"+countEntries(filepath.Join(dir, name)))
+			continue
+		}
+
+		w.entries++
+		children, err := w.walk(filepath.Join(dir, name), entryRel, rules, level+1)
+		if err != nil {
+			return nil, err
+		}
+		if len(children) == 0 && w.opts.glob != "" {
+			w.entries--
+			continue
+		}
+		lines = append(lines, indent+name+"/")
+		lines = append(lines, children...)
+	}
+	return lines, nil
+}
+
+// matches reports whether a file matches the glob, by name, or by its path
+// from the start of the tree if the glob has a slash
+func (w *treeWalker) matches(file string) bool {
+	if w.opts.glob == "" {
+		return true
+	}
+	if !strings.Contains(w.opts.glob, "/") {
+		ok, _ := filepath.Match(w.opts.glob, filepath.Base(file))
+		return ok
+	}
+	rel, err := filepath.Rel(w.start, file)
+	if err != nil {
+		return false
+	}
+	return matchSegments(strings.Split(w.opts.glob, "/"), strings.Split(filepath.ToSlash(rel), "/"))
+}
+
+// countEntries describes how many entries a directory that isn't expanded
+// has
+func countEntries(dir string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) == 0 {
+		return ""
+	}
+	if len(entries) == 1 {
+		return " (1 entry)"
+	}
+	return fmt.Sprintf(" (%d entries)", len(entries))
+}
@@ -0,0 +1,142 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mizzy/rigel/internal/workspace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTreeTool(t *testing.T, files map[string]string) *FileTool {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	ws, err := workspace.New(dir)
+	require.NoError(t, err)
+	tool := NewFileTool()
+	tool.SetWorkspace(ws)
+	return tool
+}
+
+func TestFileToolTree(t *testing.T) {
+	tool := newTreeTool(t, map[string]string{
+		".gitignore":               "*.log\n/bin/\n",
+		".git/HEAD":                "ref: refs/heads/main\n",
+		"bin/rigel":                "",
+		"go.mod":                   "module example.com/demo\n",
+		"debug.log":                "",
+		"cmd/rigel/main.go":        "package main\n",
+		"internal/app/app.go":      "package app\n",
+		"internal/app/app_test.go": "package app\n",
+		"internal/app/data/x.json": "{}",
+		"internal/.gitignore":      "gen/\n!keep.log\n",
+		"internal/gen/out.go":      "package gen\n",
+		"internal/keep.log":        "",
+	})
+	ctx := context.Background()
+
+	t.Run("honors ignore files and depth", func(t *testing.T) {
+		result, err := tool.Execute(ctx, "tree")
+		require.NoError(t, err)
+		assert.Equal(t, `./
+  .gitignore
+  cmd/
+    rigel/
+      main.go
+  go.mod
+  internal/
+    .gitignore
+    app/
+      app.go
+      app_test.go
+      data/ (1 entry)
+    keep.log`, result)
+	})
+
+	t.Run("limits the depth", func(t *testing.T) {
+		result, err := tool.Execute(ctx, "tree internal --depth 1")
+		require.NoError(t, err)
+		assert.Equal(t, "internal/\n  .gitignore\n  app/ (3 entries)\n  keep.log", result)
+	})
+
+	t.Run("filters files with a glob", func(t *testing.T) {
+		result, err := tool.Execute(ctx, "tree . --glob *_test.go")
+		require.NoError(t, err)
+		assert.Equal(t, "./\n  internal/\n    app/\n      app_test.go", result)
+
+		result, err = tool.Execute(ctx, "tree --glob cmd/**/*.go")
+		require.NoError(t, err)
+		assert.Equal(t, "./\n  cmd/\n    rigel/\n      main.go", result)
+
+		result, err = tool.Execute(ctx, "tree --glob *.rs")
+		require.NoError(t, err)
+		assert.Equal(t, `No files matching "*.rs"`, result)
+	})
+
+	t.Run("root ignore files apply to subdirectories", func(t *testing.T) {
+		result, err := tool.Execute(ctx, "tree internal")
+		require.NoError(t, err)
+		assert.NotContains(t, result, "gen/")
+		assert.Contains(t, result, "keep.log", "negated patterns re-include files")
+	})
+
+	t.Run("rejects bad arguments", func(t *testing.T) {
+		_, err := tool.Execute(ctx, "tree --depth 0")
+		assert.ErrorContains(t, err, "invalid depth")
+		_, err = tool.Execute(ctx, "tree go.mod")
+		assert.ErrorContains(t, err, "not a directory")
+		_, err = tool.Execute(ctx, "tree ..")
+		assert.ErrorContains(t, err, "outside the workspace")
+	})
+}
+
+func TestFileToolTreeStopsAfterMaxEntries(t *testing.T) {
+	files := make(map[string]string)
+	for i := range maxTreeEntries + 10 {
+		files[filepath.Join("many", "file"+string(rune('a'+i%26))+string(rune('a'+i/26)))] = ""
+	}
+	tool := newTreeTool(t, files)
+
+	result, err := tool.Execute(context.Background(), "tree")
+	require.NoError(t, err)
+	assert.Contains(t, result, "(stopped after 300 entries")
+}
+
+func TestIgnored(t *testing.T) {
+	rules := []ignoreRule{
+		{segments: []string{"*.log"}},
+		{segments: []string{"build"}, dirOnly: true},
+		{segments: []string{"docs", "**", "*.tmp"}, anchored: true},
+		{base: "web", segments: []string{"dist"}, anchored: true},
+		{segments: []string{"important.log"}, negate: true},
+	}
+
+	tests := []struct {
+		rel   string
+		isDir bool
+		want  bool
+	}{
+		{"debug.log", false, true},
+		{"a/b/debug.log", false, true},
+		{"a/important.log", false, false},
+		{"build", true, true},
+		{"build", false, false},
+		{"docs/x.tmp", false, true},
+		{"docs/a/b/x.tmp", false, true},
+		{"src/docs/x.tmp", false, false},
+		{"web/dist", true, true},
+		{"dist", true, false},
+		{"web/src/dist", true, false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, ignored(rules, tt.rel, tt.isDir), tt.rel)
+	}
+}
@@ -0,0 +1,257 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxWebResults is how many results a web search returns
+	maxWebResults = 8
+
+	// webSearchTimeout bounds how long a search backend may take
+	webSearchTimeout = 15 * time.Second
+)
+
+// Web search backends
+const (
+	WebSearchSearxNG    = "searxng"
+	WebSearchBrave      = "brave"
+	WebSearchDuckDuckGo = "duckduckgo"
+)
+
+// WebSearchResult is a page a web search found
+type WebSearchResult struct {
+	Title   string
+	URL     string
+	Snippet string
+}
+
+// WebSearchBackend runs web searches
+type WebSearchBackend interface {
+	Search(ctx context.Context, query string, limit int) ([]WebSearchResult, error)
+}
+
+// NewWebSearchBackend creates the backend with the given name. SearxNG needs
+// the URL of an instance and Brave an API key.
+func NewWebSearchBackend(name, searxngURL, braveAPIKey string) (WebSearchBackend, error) {
+	client := &http.Client{Timeout: webSearchTimeout}
+	switch name {
+	case WebSearchSearxNG:
+		if searxngURL == "" {
+			return nil, fmt.Errorf("the searxng web search backend needs the URL of an instance")
+		}
+		return &searxngBackend{client: client, baseURL: strings.TrimSuffix(searxngURL, "/")}, nil
+	case WebSearchBrave:
+		if braveAPIKey == "" {
+			return nil, fmt.Errorf("the brave web search backend needs an API key")
+		}
+		return &braveBackend{client: client, endpoint: "https://api.search.brave.com/res/v1/web/search", apiKey: braveAPIKey}, nil
+	case WebSearchDuckDuckGo:
+		return &duckDuckGoBackend{client: client, endpoint: "https://html.duckduckgo.com/html/"}, nil
+	default:
+		return nil, fmt.Errorf("unknown web search backend %q: must be searxng, brave or duckduckgo", name)
+	}
+}
+
+// WebSearchTool searches the web, returning the title, URL and a snippet of
+// each page found
+type WebSearchTool struct {
+	BaseTool
+	backend WebSearchBackend
+}
+
+func NewWebSearchTool(backend WebSearchBackend) *WebSearchTool {
+	return &WebSearchTool{
+		BaseTool: BaseTool{
+			name:        "web_search",
+			description: "Search the web, returning the title, URL and a snippet of each result",
+		},
+		backend: backend,
+	}
+}
+
+// Execute searches the web for the input
+func (w *WebSearchTool) Execute(ctx context.Context, input string) (string, error) {
+	query := strings.TrimSpace(input)
+	if query == "" {
+		return "", fmt.Errorf("no search query specified")
+	}
+
+	results, err := w.backend.Search(ctx, query, maxWebResults)
+	if err != nil {
+		return "", fmt.Errorf("web search failed: %w", err)
+	}
+	if len(results) == 0 {
+		return fmt.Sprintf("No web results for %q", query), nil
+	}
+
+	var sb strings.Builder
+	for i, result := range results {
+		fmt.Fprintf(&sb, "%d. %s\n   %s\n", i+1, result.Title, result.URL)
+		if result.Snippet != "" {
+			fmt.Fprintf(&sb, "   %s\n", result.Snippet)
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "\n"), nil
+}
+
+// getJSON fetches a URL and decodes its JSON response into v
+func getJSON(ctx context.Context, client *http.Client, req *http.Request, v any) error {
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", req.URL.Host, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// searxngBackend queries a SearxNG instance, which must allow the JSON format
+type searxngBackend struct {
+	client  *http.Client
+	baseURL string
+}
+
+func (s *searxngBackend) Search(ctx context.Context, query string, limit int) ([]WebSearchResult, error) {
+	req, err := http.NewRequest(http.MethodGet, s.baseURL+"/search?"+url.Values{"q": {query}, "format": {"json"}}.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	var body struct {
+		Results []struct {
+			Title   string `json:"title"`
+			URL     string `json:"url"`
+			Content string `json:"content"`
+		} `json:"results"`
+	}
+	if err := getJSON(ctx, s.client, req, &body); err != nil {
+		return nil, err
+	}
+
+	var results []WebSearchResult
+	for _, r := range body.Results[:min(len(body.Results), limit)] {
+		results = append(results, WebSearchResult{Title: r.Title, URL: r.URL, Snippet: strings.TrimSpace(r.Content)})
+	}
+	return results, nil
+}
+
+// braveBackend uses the Brave Search API
+type braveBackend struct {
+	client   *http.Client
+	endpoint string
+	apiKey   string
+}
+
+func (b *braveBackend) Search(ctx context.Context, query string, limit int) ([]WebSearchResult, error) {
+	req, err := http.NewRequest(http.MethodGet, b.endpoint+"?"+url.Values{"q": {query}, "count": {strconv.Itoa(limit)}}.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("X-Subscription-Token", b.apiKey)
+	var body struct {
+		Web struct {
+			Results []struct {
+				Title       string `json:"title"`
+				URL         string `json:"url"`
+				Description string `json:"description"`
+			} `json:"results"`
+		} `json:"web"`
+	}
+	if err := getJSON(ctx, b.client, req, &body); err != nil {
+		return nil, err
+	}
+
+	var results []WebSearchResult
+	for _, r := range body.Web.Results[:min(len(body.Web.Results), limit)] {
+		results = append(results, WebSearchResult{Title: stripTags(r.Title), URL: r.URL, Snippet: stripTags(r.Description)})
+	}
+	return results, nil
+}
+
+// duckDuckGoBackend scrapes DuckDuckGo's HTML results page, which needs no
+// API key
+type duckDuckGoBackend struct {
+	client   *http.Client
+	endpoint string
+}
+
+var (
+	duckDuckGoResult  = regexp.MustCompile(`(?s)<a[^>]*class="result__a"[^>]*href="([^"]*)"[^>]*>(.*?)</a>`)
+	duckDuckGoSnippet = regexp.MustCompile(`(?s)class="result__snippet"[^>]*>(.*?)</a>`)
+	htmlTag           = regexp.MustCompile(`<[^>]*>`)
+)
+
+func (d *duckDuckGoBackend) Search(ctx context.Context, query string, limit int) ([]WebSearchResult, error) {
+	req, err := http.NewRequest(http.MethodGet, d.endpoint+"?"+url.Values{"q": {query}}.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; rigel)")
+	resp, err := d.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", req.URL.Host, resp.Status)
+	}
+	page, err := io.ReadAll(io.LimitReader(resp.Body, 2<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	// Each result's snippet follows its link
+	links := duckDuckGoResult.FindAllStringSubmatchIndex(string(page), -1)
+	var results []WebSearchResult
+	for i, link := range links {
+		if len(results) == limit {
+			break
+		}
+		end := len(page)
+		if i+1 < len(links) {
+			end = links[i+1][0]
+		}
+		result := WebSearchResult{
+			Title: stripTags(string(page[link[4]:link[5]])),
+			URL:   duckDuckGoTarget(html.UnescapeString(string(page[link[2]:link[3]]))),
+		}
+		if snippet := duckDuckGoSnippet.FindSubmatch(page[link[1]:end]); snippet != nil {
+			result.Snippet = stripTags(string(snippet[1]))
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// duckDuckGoTarget returns the page a DuckDuckGo redirect link leads to
+func duckDuckGoTarget(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	if target := u.Query().Get("uddg"); target != "" {
+		return target
+	}
+	if u.Scheme == "" {
+		u.Scheme = "https"
+	}
+	return u.String()
+}
+
+// stripTags turns an HTML fragment into plain text
+func stripTags(fragment string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(htmlTag.ReplaceAllString(fragment, ""))), " ")
+}
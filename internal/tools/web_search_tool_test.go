@@ -0,0 +1,98 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewWebSearchBackend(t *testing.T) {
+	_, err := NewWebSearchBackend(WebSearchSearxNG, "", "")
+	assert.ErrorContains(t, err, "URL")
+	_, err = NewWebSearchBackend(WebSearchBrave, "", "")
+	assert.ErrorContains(t, err, "API key")
+	_, err = NewWebSearchBackend("bing", "", "")
+	assert.ErrorContains(t, err, `unknown web search backend "bing"`)
+
+	backend, err := NewWebSearchBackend(WebSearchDuckDuckGo, "", "")
+	require.NoError(t, err)
+	assert.IsType(t, &duckDuckGoBackend{}, backend)
+}
+
+func TestWebSearchToolSearxNG(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/search", r.URL.Path)
+		assert.Equal(t, "go generics", r.URL.Query().Get("q"))
+		assert.Equal(t, "json", r.URL.Query().Get("format"))
+		w.Write([]byte(`{"results":[
+			{"title":"Tutorial: Getting started with generics","url":"https://go.dev/doc/tutorial/generics","content":" Learn generics. "},
+			{"title":"No snippet","url":"https://example.com"}
+		]}`))
+	}))
+	defer server.Close()
+
+	backend, err := NewWebSearchBackend(WebSearchSearxNG, server.URL+"/", "")
+	require.NoError(t, err)
+	tool := NewWebSearchTool(backend)
+
+	result, err := tool.Execute(context.Background(), "go generics")
+	require.NoError(t, err)
+	assert.Equal(t, "1. Tutorial: Getting started with generics\n   https://go.dev/doc/tutorial/generics\n   Learn generics.\n"+
+		"2. No snippet\n   https://example.com", result)
+
+	_, err = tool.Execute(context.Background(), "  ")
+	assert.ErrorContains(t, err, "no search query")
+}
+
+func TestWebSearchToolBrave(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Subscription-Token") != "key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		assert.Equal(t, "8", r.URL.Query().Get("count"))
+		w.Write([]byte(`{"web":{"results":[{"title":"The <strong>Go</strong> Blog","url":"https://go.dev/blog","description":"News &amp; <strong>articles</strong>"}]}}`))
+	}))
+	defer server.Close()
+
+	tool := NewWebSearchTool(&braveBackend{client: server.Client(), endpoint: server.URL, apiKey: "key"})
+	result, err := tool.Execute(context.Background(), "go blog")
+	require.NoError(t, err)
+	assert.Equal(t, "1. The Go Blog\n   https://go.dev/blog\n   News & articles", result)
+
+	tool = NewWebSearchTool(&braveBackend{client: server.Client(), endpoint: server.URL, apiKey: "wrong"})
+	_, err = tool.Execute(context.Background(), "go blog")
+	assert.ErrorContains(t, err, "401 Unauthorized")
+}
+
+func TestWebSearchToolDuckDuckGo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("q") {
+		case "golang":
+			w.Write([]byte(`<div class="result">
+  <h2><a rel="nofollow" class="result__a" href="//duckduckgo.com/l/?uddg=https%3A%2F%2Fgo.dev%2F&amp;rut=abc">The <b>Go</b> Programming Language</a></h2>
+  <a class="result__snippet" href="//duckduckgo.com/l/?uddg=https%3A%2F%2Fgo.dev%2F">Go is an open source &#x27;language&#x27;.</a>
+</div>
+<div class="result">
+  <h2><a rel="nofollow" class="result__a" href="https://pkg.go.dev/">Go Packages</a></h2>
+</div>`))
+		default:
+			w.Write([]byte(`<div class="no-results">No results.</div>`))
+		}
+	}))
+	defer server.Close()
+
+	tool := NewWebSearchTool(&duckDuckGoBackend{client: server.Client(), endpoint: server.URL})
+	result, err := tool.Execute(context.Background(), "golang")
+	require.NoError(t, err)
+	assert.Equal(t, "1. The Go Programming Language\n   https://go.dev/\n   Go is an open source 'language'.\n"+
+		"2. Go Packages\n   https://pkg.go.dev/", result)
+
+	result, err = tool.Execute(context.Background(), "nothing")
+	require.NoError(t, err)
+	assert.Equal(t, `No web results for "nothing"`, result)
+}
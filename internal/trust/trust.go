@@ -0,0 +1,133 @@
+// Package trust remembers the directories the user trusts rigel to work in:
+// to load their AGENTS.md and .env, and to let the agent write files and run
+// commands there. The decisions are kept in ~/.rigel/trust.json, and one
+// about a directory applies to the directories below it.
+package trust
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/mizzy/rigel/internal/history"
+)
+
+const trustFile = "trust.json"
+
+// Store reads and writes the trust decisions
+type Store struct {
+	path string
+}
+
+// file is what trust.json holds
+type file struct {
+	Directories map[string]bool `json:"directories"` // Absolute path to whether it is trusted
+}
+
+// NewStore creates a store in ~/.rigel/trust.json
+func NewStore() (*Store, error) {
+	rigelPath, err := history.GetRigelDir()
+	if err != nil {
+		return nil, err
+	}
+	return NewStoreAt(filepath.Join(rigelPath, trustFile)), nil
+}
+
+// NewStoreAt creates a store in the given file
+func NewStoreAt(path string) *Store {
+	return &Store{path: path}
+}
+
+// Lookup returns whether dir is trusted, as decided for it or the closest
+// directory above it; known is false if neither was decided on yet
+func (s *Store) Lookup(dir string) (trusted, known bool, err error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return false, false, err
+	}
+	f, err := s.load()
+	if err != nil {
+		return false, false, err
+	}
+	for {
+		if trusted, ok := f.Directories[abs]; ok {
+			return trusted, true, nil
+		}
+		parent := filepath.Dir(abs)
+		if parent == abs {
+			return false, false, nil
+		}
+		abs = parent
+	}
+}
+
+// Set remembers whether dir is trusted
+func (s *Store) Set(dir string, trusted bool) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	f, err := s.load()
+	if err != nil {
+		return err
+	}
+	f.Directories[abs] = trusted
+	return s.save(f)
+}
+
+// Forget drops the decision about dir, so that it is asked about again. It
+// reports whether there was one.
+func (s *Store) Forget(dir string) (bool, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return false, err
+	}
+	f, err := s.load()
+	if err != nil {
+		return false, err
+	}
+	if _, ok := f.Directories[abs]; !ok {
+		return false, nil
+	}
+	delete(f.Directories, abs)
+	return true, s.save(f)
+}
+
+func (s *Store) load() (*file, error) {
+	f := &file{Directories: map[string]bool{}}
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", s.path, err)
+	}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", s.path, err)
+	}
+	if f.Directories == nil {
+		f.Directories = map[string]bool{}
+	}
+	return f, nil
+}
+
+func (s *Store) save(f *file) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode trust decisions: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(s.path), err)
+	}
+
+	// Write to a temp file first so a crash mid-write can't lose the others
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", s.path, err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", s.path, err)
+	}
+	return nil
+}
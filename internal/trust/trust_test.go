@@ -0,0 +1,56 @@
+package trust
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	store := NewStoreAt(filepath.Join(t.TempDir(), "rigel", "trust.json"))
+	projects := t.TempDir()
+	app := filepath.Join(projects, "app")
+	vendored := filepath.Join(app, "vendor", "lib")
+
+	_, known, err := store.Lookup(app)
+	require.NoError(t, err)
+	assert.False(t, known, "nothing decided yet")
+
+	require.NoError(t, store.Set(app, true))
+	trusted, known, err := store.Lookup(vendored)
+	require.NoError(t, err)
+	assert.True(t, known)
+	assert.True(t, trusted, "the decision applies below the directory")
+	_, known, err = store.Lookup(projects)
+	require.NoError(t, err)
+	assert.False(t, known, "but not above it")
+
+	// The closest decision wins
+	require.NoError(t, store.Set(vendored, false))
+	trusted, _, err = store.Lookup(vendored)
+	require.NoError(t, err)
+	assert.False(t, trusted)
+	trusted, _, err = store.Lookup(app)
+	require.NoError(t, err)
+	assert.True(t, trusted)
+
+	forgot, err := store.Forget(vendored)
+	require.NoError(t, err)
+	assert.True(t, forgot)
+	trusted, _, err = store.Lookup(vendored)
+	require.NoError(t, err)
+	assert.True(t, trusted)
+	forgot, err = store.Forget(vendored)
+	require.NoError(t, err)
+	assert.False(t, forgot)
+}
+
+func TestStoreRejectsCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trust.json")
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0600))
+	_, _, err := NewStoreAt(path).Lookup(t.TempDir())
+	assert.Error(t, err)
+}
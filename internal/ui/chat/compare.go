@@ -0,0 +1,30 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mizzy/rigel/internal/command"
+)
+
+// ComparisonBody returns a model's response, or its error
+func ComparisonBody(r command.ComparisonResponse) string {
+	if r.Error != nil {
+		return "Error: " + r.Error.Error()
+	}
+	return r.Content
+}
+
+// FormatComparison renders /compare responses one after another, each
+// labeled with its model and stats
+func FormatComparison(responses []command.ComparisonResponse) string {
+	var sb strings.Builder
+	for i, r := range responses {
+		if i > 0 {
+			sb.WriteString("\n\n")
+		}
+		sb.WriteString(fmt.Sprintf("── %s (%s) ──\n", r.Label(), r.Stats()))
+		sb.WriteString(ComparisonBody(r))
+	}
+	return sb.String()
+}
@@ -0,0 +1,21 @@
+package chat
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mizzy/rigel/internal/command"
+)
+
+func TestFormatComparison(t *testing.T) {
+	out := FormatComparison([]command.ComparisonResponse{
+		{Provider: "ollama", Model: "llama3.2", Content: "Rayleigh scattering.", Latency: 1200 * time.Millisecond, Tokens: 5},
+		{Provider: "ollama", Model: "qwen", Error: errors.New("model not found"), Latency: 100 * time.Millisecond},
+	})
+
+	assert.Equal(t, "── ollama/llama3.2 (1.2s, ~5 tokens) ──\nRayleigh scattering.\n\n"+
+		"── ollama/qwen (failed after 100ms) ──\nError: model not found", out)
+}
@@ -0,0 +1,54 @@
+package chat
+
+import (
+	"fmt"
+
+	"github.com/mizzy/rigel/internal/llm"
+)
+
+// contextWarningShare is the share of the context window a conversation
+// may fill before the user is warned
+const contextWarningShare = 0.8
+
+// Levels of context window use the user has been warned about
+const (
+	contextRoomy = iota
+	contextFilling
+	contextExceeded
+)
+
+// ContextWarning warns when the last request filled most of the current
+// model's context window or more, once per level reached, or returns an
+// empty string. Models with an unknown context window are never warned about.
+func (c *Core) ContextWarning() string {
+	provider := c.LLMState.GetCurrentProvider()
+	if provider == nil {
+		return ""
+	}
+	window := provider.GetCurrentModel().Details.ContextWindow
+	usage, ok := llm.FindUsage(provider)
+	if !ok || window <= 0 {
+		return ""
+	}
+
+	level := contextRoomy
+	switch {
+	case usage.ContextTokens >= window:
+		level = contextExceeded
+	case float64(usage.ContextTokens) >= contextWarningShare*float64(window):
+		level = contextFilling
+	}
+	warned := c.contextLevel
+	c.contextLevel = level
+	if level <= warned {
+		return ""
+	}
+
+	model := provider.GetCurrentModel().Name
+	if level == contextExceeded {
+		return fmt.Sprintf("The conversation (~%s tokens) exceeds the %s-token context window of %s; earlier messages may be dropped. Run /compact or /clear.",
+			formatTokens(usage.ContextTokens), formatTokens(window), model)
+	}
+	return fmt.Sprintf("The conversation (~%s tokens) fills %d%% of the %s-token context window of %s; consider /compact.",
+		formatTokens(usage.ContextTokens), usage.ContextTokens*100/window, formatTokens(window), model)
+}
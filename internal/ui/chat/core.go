@@ -0,0 +1,630 @@
+// Package chat contains the frontend-independent chat engine shared by the
+// Bubbletea and termflow user interfaces.
+package chat
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mizzy/rigel/internal/agent"
+	"github.com/mizzy/rigel/internal/analyzer"
+	"github.com/mizzy/rigel/internal/command"
+	"github.com/mizzy/rigel/internal/config"
+	"github.com/mizzy/rigel/internal/draft"
+	"github.com/mizzy/rigel/internal/git"
+	"github.com/mizzy/rigel/internal/history"
+	"github.com/mizzy/rigel/internal/llm"
+	"github.com/mizzy/rigel/internal/lsp"
+	"github.com/mizzy/rigel/internal/persona"
+	"github.com/mizzy/rigel/internal/session"
+	"github.com/mizzy/rigel/internal/state"
+	"github.com/mizzy/rigel/internal/stats"
+	"github.com/mizzy/rigel/internal/tools"
+	"github.com/mizzy/rigel/internal/workspace"
+)
+
+// toolProgressBuffer is how many tool progress lines may wait for a frontend
+// to show them before more are dropped
+const toolProgressBuffer = 64
+
+// jobStopTimeout is how long closing waits for cancelled background jobs to
+// end
+const jobStopTimeout = 5 * time.Second
+
+// Core holds the state and services every chat frontend needs
+type Core struct {
+	Config        *config.Config
+	ChatState     *state.ChatState
+	LLMState      *state.LLMState
+	Branches      *state.BranchState
+	History       *history.Manager
+	HistoryPicker *state.HistoryPicker  // The /history list
+	SearchPicker  *state.SearchPicker   // The /search results
+	Viewer        *state.SessionViewer  // An exchange /search opened
+	Explorer      *state.FileExplorer   // The Ctrl+E file explorer
+	Palette       *state.CommandPalette // The Ctrl+P command palette
+	Agent         *agent.Agent
+	GitInfo       *git.Info
+	Workspace     *workspace.Workspace
+	Mode          string // UI mode used to filter commands
+
+	// ToolProgress receives a line each time the agent starts or finishes
+	// a tool, so frontends can show progress while a request runs
+	ToolProgress <-chan string
+
+	inputHistory []string
+	queued       []string // Prompts typed while thinking
+	exitGuard    ExitGuard
+
+	// When the last prompt was submitted, to notify the user if it takes long
+	submittedAt     time.Time
+	submittedPrompt string
+
+	// Whether the last prompt went to the model, when, and what had been
+	// metered before it, to describe its response
+	requestMu    sync.Mutex
+	requesting   bool
+	requestStart time.Time
+	requestUsage llm.Usage
+
+	// Context window use the user was last warned about
+	contextLevel int
+
+	// How many hints have been shown; see NextHint
+	hintIndex int
+
+	// Saves the input being composed; started by the first SaveDraft
+	drafts    *draft.Autosaver
+	draftOnce sync.Once
+
+	// Close runs once, as a signal may close the core while the UI shuts
+	// down; later calls wait for it and return its error
+	closeOnce sync.Once
+	closeErr  error
+
+	// Usage statistics, nil if disabled, and what was used since they were
+	// last recorded; the agent counts tools from its own goroutines
+	stats        *stats.Store
+	statsMu      sync.Mutex
+	statsPrompts int
+	statsTools   []string
+	statsUsage   llm.Usage
+}
+
+// NewCore creates a chat core for the given UI mode with persistent history
+// and an agent with file tools
+func NewCore(provider llm.Provider, cfg *config.Config, mode string) *Core {
+	// Initialize history manager
+	histManager, err := newHistoryManager(cfg)
+	if err != nil {
+		// If we can't create history manager, continue without it
+		histManager = nil
+	} else {
+		// Load existing history
+		_ = histManager.Load()
+	}
+
+	llmState := state.NewLLMState()
+	llmState.SetCurrentProvider(provider)
+
+	ws := newWorkspace(cfg)
+
+	// Create intelligent agent with file tools
+	intelligentAgent := agent.New(provider)
+	intelligentAgent.SetStreaming(true) // Keeps partial responses when interrupted
+	fileTool := tools.NewFileTool()
+	if ws != nil {
+		fileTool.SetWorkspace(ws)
+	}
+	if cfg != nil {
+		fileTool.SetSizeLimits(cfg.MaxReadSize, cfg.MaxWriteSize)
+	}
+	intelligentAgent.RegisterTool(fileTool)
+	readOnly := cfg != nil && cfg.ReadOnly
+	if !readOnly {
+		// A directory that isn't trusted only has its files read: its tests
+		// and build aren't run, and no notes are written to it
+		intelligentAgent.RegisterTool(newTestRunnerTool(ws, cfg))
+		intelligentAgent.RegisterTool(newCheckTool(ws, cfg))
+		intelligentAgent.RegisterTool(tools.NewRememberTool())
+	}
+	if webSearch := newWebSearchTool(cfg); webSearch != nil {
+		intelligentAgent.RegisterTool(webSearch)
+	}
+	if cfg != nil {
+		intelligentAgent.SetMaxFixIterations(cfg.MaxFixIterations)
+		intelligentAgent.SetMaxSubagents(cfg.MaxSubagents)
+		intelligentAgent.SetToolTimeout(cfg.ToolTimeout)
+		intelligentAgent.SetDryRun(cfg.DryRun)
+		switch cfg.IntentAnalysis {
+		case config.IntentAnalysisAuto:
+			intelligentAgent.SetFastIntent(true)
+		case config.IntentAnalysisOff:
+			intelligentAgent.SetAutoToolEnabled(false)
+		}
+	}
+	if p, err := persona.Current(configuredPersona(cfg)); err != nil {
+		slog.Warn("failed to load the persona", "error", err)
+	} else {
+		intelligentAgent.SetPersona(p)
+	}
+	if symbols := newSymbolContext(ws, cfg); symbols != nil {
+		intelligentAgent.AddContextProvider(symbols)
+	}
+
+	// Use UIProgressDisplay to avoid interfering with the terminal UI
+	toolProgress := make(chan string, toolProgressBuffer)
+	progressDisplay := agent.NewUIProgressDisplay()
+	progressDisplay.SetUpdates(toolProgress)
+	intelligentAgent.SetProgressDisplay(progressDisplay)
+
+	c := &Core{
+		Config:        cfg,
+		ChatState:     state.NewChatState(),
+		LLMState:      llmState,
+		Branches:      state.NewBranchState(session.NewID()),
+		History:       histManager,
+		HistoryPicker: state.NewHistoryPicker(),
+		SearchPicker:  state.NewSearchPicker(),
+		Viewer:        state.NewSessionViewer(),
+		Explorer:      state.NewFileExplorer(),
+		Palette:       state.NewCommandPalette(),
+		Agent:         intelligentAgent,
+		GitInfo:       git.GetRepoInfo(),
+		Workspace:     ws,
+		Mode:          mode,
+		ToolProgress:  toolProgress,
+		inputHistory:  []string{},
+		stats:         newStatsStore(cfg),
+	}
+
+	intelligentAgent.SetToolRecorder(c)
+
+	// Load input history from manager if available
+	if histManager != nil {
+		c.inputHistory = histManager.GetCommands()
+	}
+
+	return c
+}
+
+// configuredPersona returns the persona set with RIGEL_PERSONA, if any
+func configuredPersona(cfg *config.Config) string {
+	if cfg == nil {
+		return ""
+	}
+	return cfg.Persona
+}
+
+// newHistoryManager creates the manager of the input history: the current
+// git repository's own, unless the global history is configured or rigel
+// runs outside a repository
+func newHistoryManager(cfg *config.Config) (*history.Manager, error) {
+	if cfg != nil && cfg.HistoryScope == config.HistoryScopeGlobal {
+		return history.NewManager()
+	}
+	if root := git.TopLevel(); root != "" {
+		return history.NewProjectManager(root)
+	}
+	return history.NewManager()
+}
+
+// newWorkspace creates a workspace rooted at the current directory, which is
+// read-only unless trusted, with the configured extra roots. Roots that
+// can't be added are logged and skipped.
+func newWorkspace(cfg *config.Config) *workspace.Workspace {
+	newPrimary := workspace.New
+	if cfg != nil && cfg.ReadOnly {
+		newPrimary = workspace.NewReadOnly
+	}
+	ws, err := newPrimary(".")
+	if err != nil {
+		slog.Error("failed to create workspace", "error", err)
+		return nil
+	}
+	if cfg != nil {
+		if err := ws.AddSpecs(cfg.Workspaces); err != nil {
+			slog.Warn("failed to add workspace roots", "error", err)
+		}
+	}
+	return ws
+}
+
+// newTestRunnerTool creates a tool that runs the tests of the primary
+// workspace root with the configured or detected test command
+func newTestRunnerTool(ws *workspace.Workspace, cfg *config.Config) *tools.TestRunnerTool {
+	dir := "."
+	if ws != nil {
+		dir = ws.Primary().Path
+	}
+	if cfg == nil {
+		return tools.NewTestRunnerTool(dir, "")
+	}
+	tool := tools.NewTestRunnerTool(dir, cfg.TestCommand)
+	tool.SetTimeout(cfg.ToolTimeout)
+	return tool
+}
+
+// newCheckTool creates a tool that builds and lints the primary workspace
+// root with the configured or detected commands
+func newCheckTool(ws *workspace.Workspace, cfg *config.Config) *tools.CheckTool {
+	dir := "."
+	if ws != nil {
+		dir = ws.Primary().Path
+	}
+	if cfg == nil {
+		return tools.NewCheckTool(dir, nil)
+	}
+	tool := tools.NewCheckTool(dir, cfg.CheckCommands)
+	tool.SetTimeout(cfg.ToolTimeout)
+	return tool
+}
+
+// newWebSearchTool creates a tool searching the web with the configured
+// backend, or returns nil if web search is disabled
+func newWebSearchTool(cfg *config.Config) *tools.WebSearchTool {
+	if cfg == nil || cfg.WebSearch == "" {
+		return nil
+	}
+	backend, err := tools.NewWebSearchBackend(cfg.WebSearch, cfg.WebSearchURL, cfg.BraveAPIKey)
+	if err != nil {
+		slog.Warn("web search disabled", "error", err)
+		return nil
+	}
+	return tools.NewWebSearchTool(backend)
+}
+
+// newSymbolContext creates a symbol context backed by the configured
+// language server, or returns nil if it is disabled or not installed, or
+// the directory isn't trusted. gopls is only used for Go modules.
+func newSymbolContext(ws *workspace.Workspace, cfg *config.Config) *lsp.SymbolContext {
+	// A language server may run the code of the project it loads
+	if cfg == nil || ws == nil || cfg.ReadOnly {
+		return nil
+	}
+	command := strings.Fields(cfg.LSPCommand)
+	if len(command) == 0 || command[0] == "off" {
+		return nil
+	}
+	if _, err := exec.LookPath(command[0]); err != nil {
+		slog.Debug("language server not found", "command", command[0])
+		return nil
+	}
+
+	root := ws.Primary().Path
+	if command[0] == "gopls" {
+		if _, err := os.Stat(filepath.Join(root, "go.mod")); err != nil {
+			return nil
+		}
+	}
+	return lsp.NewSymbolContext(root, command...)
+}
+
+// Submit records the input in history, marks the chat as thinking and
+// dispatches it to the command handler
+func (c *Core) Submit(input string) command.Result {
+	c.RecordInput(input)
+	c.submittedAt = time.Now()
+	c.submittedPrompt = input
+
+	c.ChatState.SetCurrentPrompt(input)
+	c.ChatState.SetThinking(true)
+	c.ChatState.ClearError()
+	c.exitGuard.Reset()
+
+	result := c.dispatch(input)
+	_, requesting := result.(command.Request)
+	if requesting {
+		c.statsMu.Lock()
+		c.statsPrompts++
+		c.statsMu.Unlock()
+	}
+	c.startRequest(requesting)
+	return result
+}
+
+// startRequest notes whether the last prompt went to the model, for
+// responseMeta to describe its response; false also forgets a request that
+// failed
+func (c *Core) startRequest(requesting bool) {
+	c.requestMu.Lock()
+	defer c.requestMu.Unlock()
+	c.requesting = requesting
+	if requesting {
+		c.requestStart = time.Now()
+		c.requestUsage, _ = llm.FindUsage(c.LLMState.GetCurrentProvider())
+	}
+}
+
+// dispatch runs a command, or turns a prompt into a request
+func (c *Core) dispatch(input string) command.Result {
+	return command.DefaultRegistry.Dispatch(input, &command.Context{
+		LLMState:     c.LLMState,
+		ChatState:    c.ChatState,
+		Branches:     c.Branches,
+		Config:       c.Config,
+		History:      c.History,
+		InputHistory: c.inputHistory,
+		Mode:         c.Mode,
+		Workspace:    c.Workspace,
+		Agent:        c.Agent,
+	})
+}
+
+// RecordInput adds the input to the in-memory and persistent history
+func (c *Core) RecordInput(input string) {
+	if strings.TrimSpace(input) == "" {
+		return
+	}
+
+	// Repeating the previous input doesn't add to the history
+	if n := len(c.inputHistory); n > 0 && c.inputHistory[n-1] == input {
+		return
+	}
+	c.inputHistory = append(c.inputHistory, input)
+
+	if c.History != nil {
+		_ = c.History.Add(input)
+	}
+}
+
+// InputHistory returns the inputs submitted so far, oldest first
+func (c *Core) InputHistory() []string {
+	return c.inputHistory
+}
+
+// ClearInputHistory clears the in-memory input history
+func (c *Core) ClearInputHistory() {
+	c.inputHistory = []string{}
+}
+
+// DeleteHistoryEntry removes a past prompt from the persistent and the
+// in-memory history
+func (c *Core) DeleteHistoryEntry(entry history.Entry) error {
+	if c.History == nil {
+		return nil
+	}
+	if err := c.History.Delete(entry); err != nil {
+		return err
+	}
+	c.inputHistory = c.History.GetCommands()
+	return nil
+}
+
+// CompleteExchange stores a finished exchange for the current prompt
+func (c *Core) CompleteExchange(response string) {
+	c.recordStats()
+	c.ChatState.SetThinking(false)
+	c.ChatState.AddExchangeWithMeta(c.ChatState.GetCurrentPrompt(), response, c.responseMeta())
+	c.ChatState.ClearCurrentPrompt()
+}
+
+// responseMeta describes the response of the model to the last prompt: the
+// model, how long it took and, if the provider is metered, its tokens and
+// cost. It returns nil for command output.
+func (c *Core) responseMeta() *session.Meta {
+	c.requestMu.Lock()
+	defer c.requestMu.Unlock()
+	if !c.requesting {
+		return nil
+	}
+	c.requesting = false
+	provider := c.LLMState.GetCurrentProvider()
+	if provider == nil {
+		return nil
+	}
+
+	model := c.LLMState.GetCurrentModel().Name
+	meta := &session.Meta{Model: model, Latency: time.Since(c.requestStart)}
+	// Switching providers starts metering over
+	if usage, ok := llm.FindUsage(provider); ok && usage.Requests > c.requestUsage.Requests {
+		meta.InputTokens = usage.InputTokens - c.requestUsage.InputTokens
+		meta.OutputTokens = usage.OutputTokens - c.requestUsage.OutputTokens
+		if price, ok := llm.ModelPrice(provider.GetName(), model); ok {
+			meta.Cost = (float64(meta.InputTokens)*price.Input + float64(meta.OutputTokens)*price.Output) / 1e6
+		}
+	}
+	return meta
+}
+
+// MetaFooter returns the line describing the last response, such as
+// "claude-3-5-haiku · 2.3s · 412→618 tokens · $0.0021", if RIGEL_SHOW_META
+// or /set show-meta turned it on, or an empty string
+func (c *Core) MetaFooter() string {
+	if c.Config == nil || !c.Config.ShowMeta {
+		return ""
+	}
+	history := c.ChatState.GetHistory()
+	if len(history) == 0 || history[len(history)-1].Meta == nil {
+		return ""
+	}
+	return history[len(history)-1].Meta.String()
+}
+
+// InterruptedHint offers to finish a response that was interrupted
+const InterruptedHint = "Response interrupted. Type /continue to have the model finish it."
+
+// Cancel ends the current prompt after its request was cancelled. If the
+// model had started answering, the partial response is recorded, marked as
+// interrupted, and returned.
+func (c *Core) Cancel(err error) (string, bool) {
+	c.recordStats()
+	var interrupted *agent.Interrupted
+	if errors.As(err, &interrupted) {
+		c.CompleteExchange(interrupted.Response())
+		return interrupted.Response(), true
+	}
+	c.startRequest(false)
+	c.ChatState.SetThinking(false)
+	c.ChatState.ClearCurrentPrompt()
+	return "", false
+}
+
+// FailoverNotice describes the requests a fallback provider answered since
+// the last call, or returns an empty string
+func (c *Core) FailoverNotice() string {
+	if failover, ok := llm.As[*llm.FailoverProvider](c.LLMState.GetCurrentProvider()); ok {
+		return failover.TakeNotice()
+	}
+	return ""
+}
+
+// Fail records an error for the current prompt
+func (c *Core) Fail(err error) {
+	c.recordStats()
+	c.startRequest(false)
+	c.ChatState.SetThinking(false)
+	c.ChatState.SetError(err)
+}
+
+// Supersede removes the most recent exchange for prompt from the chat
+// history and the agent's memory, before it is retried or edited
+func (c *Core) Supersede(prompt string) {
+	history := c.ChatState.GetHistory()
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Prompt == prompt {
+			c.ChatState.RemoveExchange(i)
+			break
+		}
+	}
+	c.Agent.RemoveExchange(prompt)
+}
+
+// Snapshot captures the conversation, including a prompt still in flight
+func (c *Core) Snapshot() *session.Session {
+	sess := session.New()
+	if provider := c.LLMState.GetCurrentProvider(); provider != nil {
+		sess.Provider = provider.GetName()
+		sess.Model = c.LLMState.GetCurrentModel().Name
+	}
+	for _, ex := range c.ChatState.GetHistory() {
+		sess.Exchanges = append(sess.Exchanges, session.Exchange{Prompt: ex.Prompt, Response: ex.Response, Meta: ex.Meta})
+	}
+	if c.ChatState.IsThinking() {
+		sess.Pending = c.ChatState.GetCurrentPrompt()
+	}
+	sess.Memory = c.Agent.SaveMemory()
+	return sess
+}
+
+// Restore replaces the conversation shown in the UI and remembered by the
+// agent with a saved session. Sessions saved without the agent's memory,
+// such as a compacted conversation, have it rebuilt from their exchanges.
+func (c *Core) Restore(sess *session.Session) {
+	c.ChatState.ClearHistory()
+	var messages []agent.Message
+	for _, ex := range sess.Exchanges {
+		c.ChatState.AddExchangeWithMeta(ex.Prompt, ex.Response, ex.Meta)
+		messages = append(messages,
+			agent.Message{Role: "user", Content: ex.Prompt},
+			agent.Message{Role: "assistant", Content: ex.Response},
+		)
+	}
+	if sess.Memory != nil {
+		c.Agent.RestoreMemory(sess.Memory)
+		return
+	}
+	c.Agent.SetHistory(messages)
+}
+
+// SaveRecovery writes a crash snapshot of the conversation
+func (c *Core) SaveRecovery() error {
+	store, err := session.NewStore()
+	if err != nil {
+		return err
+	}
+	return store.SaveRecovery(c.Snapshot())
+}
+
+// PendingRecovery returns the snapshot left by a previous crash, if any
+func (c *Core) PendingRecovery() *session.Session {
+	store, err := session.NewStore()
+	if err != nil {
+		return nil
+	}
+	sess, err := store.LoadRecovery()
+	if err != nil {
+		return nil
+	}
+	return sess
+}
+
+// AgentsWarning asks to refresh the analysis when AGENTS.md is stale or was
+// edited since rigel generated it
+func (c *Core) AgentsWarning() string {
+	return analyzer.CheckAgentsFile(".").Warning()
+}
+
+// ExitGuard returns the two-press Ctrl+C guard for this session
+func (c *Core) ExitGuard() *ExitGuard {
+	return &c.exitGuard
+}
+
+// Commands returns the slash commands available in this UI mode
+func (c *Core) Commands() []command.Command {
+	return command.DefaultRegistry.Commands(c.Mode)
+}
+
+// Close flushes persistent state, including the active branch of the
+// conversation, so that it can be resumed later. Closing it again does
+// nothing.
+func (c *Core) Close() error {
+	c.closeOnce.Do(func() { c.closeErr = c.close() })
+	return c.closeErr
+}
+
+func (c *Core) close() error {
+	c.closeDrafts()
+	if c.Agent != nil {
+		// Background jobs end with the session rather than outlive it, but
+		// one that won't stop mustn't keep the session from being saved
+		ctx, cancel := context.WithTimeout(context.Background(), jobStopTimeout)
+		if err := c.Agent.CancelJobs(ctx); err != nil {
+			slog.Warn("failed to stop background jobs", "error", err)
+		}
+		cancel()
+	}
+	if c.Branches != nil && (c.Branches.IsForked() || c.ChatState.GetMessageCount() > 0) {
+		if _, err := command.SaveCurrentBranch(c.Branches, c.ChatState, c.LLMState, c.Agent); err != nil {
+			return err
+		}
+	}
+	if c.History != nil {
+		return c.History.Save()
+	}
+	return nil
+}
+
+// ExitGuard implements the two-press Ctrl+C exit pattern
+type ExitGuard struct {
+	pressed bool
+}
+
+// ExitHint is shown after the first Ctrl+C press
+const ExitHint = "Press Ctrl+C again to exit"
+
+// Press registers a Ctrl+C press and reports whether the app should exit
+func (g *ExitGuard) Press() bool {
+	if g.pressed {
+		return true
+	}
+	g.pressed = true
+	return false
+}
+
+// Pressed reports whether Ctrl+C has been pressed once
+func (g *ExitGuard) Pressed() bool {
+	return g.pressed
+}
+
+// Reset clears the pending Ctrl+C press
+func (g *ExitGuard) Reset() {
+	g.pressed = false
+}
@@ -0,0 +1,69 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mizzy/rigel/internal/config"
+	"github.com/mizzy/rigel/internal/llm"
+)
+
+// deadlineWarningShare is the share of the generation timeout left when
+// frontends start counting down to the deadline
+const deadlineWarningShare = 0.2
+
+// RequestContext returns the context a prompt is answered in, which is
+// cancelled once the generation timeout has passed, and the function
+// cancelling it sooner
+func (c *Core) RequestContext() (context.Context, context.CancelFunc) {
+	return llm.WithTimeout(context.Background(), c.generationTimeout())
+}
+
+// generationTimeout returns how long answering a prompt may take; 0 for no
+// limit
+func (c *Core) generationTimeout() time.Duration {
+	if c.Config == nil {
+		return config.DefaultGenerationTimeout
+	}
+	return c.Config.GenerationTimeout
+}
+
+// DeadlineNotice counts down to the deadline of a request's context once
+// less than a fifth of the generation timeout is left, or returns an empty
+// string
+func (c *Core) DeadlineNotice(ctx context.Context) string {
+	timeout := c.generationTimeout()
+	if ctx == nil || timeout <= 0 {
+		return ""
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return ""
+	}
+	left := time.Until(deadline)
+	if left > time.Duration(deadlineWarningShare*float64(timeout)) {
+		return ""
+	}
+	return fmt.Sprintf("Times out in %v", max(left, 0).Round(time.Second))
+}
+
+// RateLimitNotice tells how long a request still waits for the provider's
+// rate limits, or returns an empty string
+func (c *Core) RateLimitNotice() string {
+	limiter, ok := llm.As[*llm.RateLimitedProvider](c.LLMState.GetCurrentProvider())
+	if !ok {
+		return ""
+	}
+	wait := limiter.Waiting()
+	if wait <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("Waiting for rate limit (%v)", wait.Round(time.Second))
+}
+
+// TimeoutNotice tells that a request was cancelled for running past the
+// generation timeout
+func (c *Core) TimeoutNotice() string {
+	return fmt.Sprintf("Request timed out after %v. Set RIGEL_GENERATION_TIMEOUT to allow more time.", c.generationTimeout())
+}
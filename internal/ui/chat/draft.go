@@ -0,0 +1,45 @@
+package chat
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/mizzy/rigel/internal/draft"
+)
+
+// SaveDraft notes the input as it is being composed. It is saved to
+// .rigel/draft every few seconds while it changes, so that it isn't lost if
+// rigel exits before it is sent; an empty input removes the draft. Like
+// notes, drafts aren't written to directories that aren't trusted.
+func (c *Core) SaveDraft(text string) {
+	if c.Config != nil && c.Config.ReadOnly {
+		return
+	}
+	c.draftOnce.Do(func() {
+		c.drafts = draft.StartAutosave(draft.Interval)
+	})
+	c.drafts.Update(text)
+}
+
+// closeDrafts saves the input a last time
+func (c *Core) closeDrafts() {
+	c.draftOnce.Do(func() {}) // No autosaver is started after this
+	if c.drafts != nil {
+		c.drafts.Close()
+	}
+}
+
+// DraftNotice offers to restore the draft an earlier session exited before
+// sending, or returns an empty string if there is none
+func (c *Core) DraftNotice() string {
+	text, err := draft.Load()
+	if err != nil {
+		slog.Warn("failed to read the draft", "error", err)
+		return ""
+	}
+	if strings.TrimSpace(text) == "" {
+		return ""
+	}
+	return fmt.Sprintf("An unsent draft from the last session was saved: %q. Type /draft to restore it.", Preview(text))
+}
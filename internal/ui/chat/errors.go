@@ -0,0 +1,44 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mizzy/rigel/internal/llm"
+)
+
+// ErrorDescription explains a failed request and what to do about it
+type ErrorDescription struct {
+	Title  string // Short summary, e.g. "Rate limited by anthropic"
+	Detail string // The underlying error
+	Hint   string // Suggested action; empty for errors that aren't classified
+}
+
+// DescribeError turns a provider error into an actionable message
+func DescribeError(err error) ErrorDescription {
+	provider := "the provider"
+	var e *llm.Error
+	if errors.As(err, &e) && e.Provider != "" {
+		provider = e.Provider
+	}
+
+	desc := ErrorDescription{Title: "Error", Detail: err.Error()}
+	switch llm.KindOf(err) {
+	case llm.ErrorAuth:
+		desc.Title = fmt.Sprintf("Authentication with %s failed", provider)
+		desc.Hint = "Check your API key, or run /doctor to diagnose the configuration."
+	case llm.ErrorRateLimit:
+		desc.Title = fmt.Sprintf("Rate limited by %s", provider)
+		desc.Hint = "Wait a moment and run /retry, or switch with /provider."
+	case llm.ErrorContextLength:
+		desc.Title = "Context too long"
+		desc.Hint = "Run /compact to summarize the conversation, or /clear to start over."
+	case llm.ErrorNetwork:
+		desc.Title = fmt.Sprintf("Can't reach %s", provider)
+		desc.Hint = "Check your network connection and that the service is running, or run /doctor."
+	case llm.ErrorServer:
+		desc.Title = fmt.Sprintf("%s is unavailable", provider)
+		desc.Hint = "The service failed or is overloaded; try again with /retry."
+	}
+	return desc
+}
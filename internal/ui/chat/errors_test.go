@@ -0,0 +1,27 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mizzy/rigel/internal/llm"
+)
+
+func TestDescribeError(t *testing.T) {
+	err := fmt.Errorf("failed to execute task: %w", &llm.Error{Kind: llm.ErrorRateLimit, Provider: "anthropic", Err: errors.New("429 Too Many Requests")})
+	desc := DescribeError(err)
+	assert.Equal(t, "Rate limited by anthropic", desc.Title)
+	assert.Contains(t, desc.Detail, "429 Too Many Requests")
+	assert.Contains(t, desc.Hint, "/retry")
+
+	desc = DescribeError(errors.New("prompt is too long: 210000 tokens > 200000 maximum"))
+	assert.Equal(t, "Context too long", desc.Title)
+	assert.Contains(t, desc.Hint, "/compact")
+
+	desc = DescribeError(errors.New("no content in response"))
+	assert.Equal(t, "Error", desc.Title)
+	assert.Empty(t, desc.Hint)
+}
@@ -0,0 +1,49 @@
+package chat
+
+import (
+	"strings"
+
+	"github.com/mizzy/rigel/internal/command"
+)
+
+// recentHints is how many recently used commands are suggested again
+const recentHints = 3
+
+// keyHints are tips about each UI's keys
+var keyHints = map[string][]string{
+	command.ModeBubbletea: {"Alt+Enter for a new line", "↑ recalls earlier prompts"},
+	command.ModeTermflow:  {"Ctrl+J for newline", "↑ recalls earlier prompts"},
+}
+
+// Hints returns the tips to show in the empty input: the UI's keys, the
+// commands' tips from the registry and the commands used most recently
+func (c *Core) Hints() []string {
+	hints := append([]string{}, keyHints[c.Mode]...)
+	hints = append(hints, command.DefaultRegistry.Hints(c.Mode)...)
+
+	seen := map[string]bool{}
+	for i := len(c.inputHistory) - 1; i >= 0 && len(seen) < recentHints; i-- {
+		input := strings.TrimSpace(c.inputHistory[i])
+		if !strings.HasPrefix(input, "/") || strings.Contains(input, "\n") || seen[input] {
+			continue
+		}
+		seen[input] = true
+		hints = append(hints, "Recent: "+input)
+	}
+	return hints
+}
+
+// NextHint returns the next tip to show in the empty input, cycling through
+// Hints, or an empty string if hints are turned off
+func (c *Core) NextHint() string {
+	if c.Config != nil && !c.Config.Hints {
+		return ""
+	}
+	hints := c.Hints()
+	if len(hints) == 0 {
+		return ""
+	}
+	hint := hints[c.hintIndex%len(hints)]
+	c.hintIndex++
+	return hint
+}
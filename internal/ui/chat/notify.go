@@ -0,0 +1,66 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mizzy/rigel/internal/config"
+)
+
+// notificationTitle is the title of desktop notifications
+const notificationTitle = "Rigel"
+
+// Notification returns the escape sequences that alert the user that the
+// last submitted prompt has finished, or an empty string if it finished
+// sooner than the configured NotifyAfter or the terminal has focus. It is
+// meant to be called once the chat stops thinking.
+func (c *Core) Notification(focused bool) string {
+	if focused || c.Config == nil || len(c.Config.Notify) == 0 || c.Config.NotifyAfter <= 0 || c.submittedAt.IsZero() {
+		return ""
+	}
+	elapsed := time.Since(c.submittedAt)
+	c.submittedAt = time.Time{}
+	if elapsed < c.Config.NotifyAfter {
+		return ""
+	}
+
+	body := fmt.Sprintf("Finished after %s: %s", elapsed.Round(time.Second), Preview(c.submittedPrompt))
+	if c.ChatState.GetError() != nil {
+		body = fmt.Sprintf("Failed after %s: %s", elapsed.Round(time.Second), Preview(c.submittedPrompt))
+	}
+	return notificationSequence(c.Config.Notify, notificationTitle, body)
+}
+
+// notificationSequence builds the escape sequences of each notification
+// method
+func notificationSequence(methods []string, title, body string) string {
+	title, body = notificationText(title), notificationText(body)
+
+	var sb strings.Builder
+	for _, method := range methods {
+		switch method {
+		case config.NotifyBell:
+			sb.WriteString("\a")
+		case config.NotifyOSC9:
+			sb.WriteString("\x1b]9;" + title + ": " + body + "\a")
+		case config.NotifyOSC777:
+			sb.WriteString("\x1b]777;notify;" + title + ";" + body + "\a")
+		}
+	}
+	return sb.String()
+}
+
+// notificationText removes what would end an OSC sequence or its field
+// early: control characters, and semicolons, which separate OSC 777 fields
+func notificationText(text string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == ';':
+			return ','
+		case r < 0x20 || r == 0x7f:
+			return -1
+		}
+		return r
+	}, text)
+}
@@ -0,0 +1,28 @@
+package chat
+
+import (
+	"slices"
+
+	"github.com/mizzy/rigel/internal/command"
+	"github.com/mizzy/rigel/internal/state"
+)
+
+// PaletteEntries returns the slash commands available in this UI mode for
+// the command palette, in registration order. Commands registered at
+// runtime are listed too.
+func (c *Core) PaletteEntries() []state.PaletteEntry {
+	specs := command.DefaultRegistry.Specs(c.Mode)
+	entries := make([]state.PaletteEntry, 0, len(specs))
+	for _, spec := range specs {
+		entries = append(entries, state.PaletteEntry{
+			Name:        spec.Name,
+			Aliases:     spec.Aliases,
+			Usage:       spec.Usage(),
+			Description: spec.Description,
+			NeedsArgs: slices.ContainsFunc(spec.Args, func(arg command.Arg) bool {
+				return arg.Required
+			}),
+		})
+	}
+	return entries
+}
@@ -0,0 +1,97 @@
+package chat
+
+import (
+	"context"
+
+	"github.com/mizzy/rigel/internal/agent"
+)
+
+// PlanReview is a plan of the agent the user is reviewing, with the step
+// they selected to move or remove
+type PlanReview struct {
+	Plan     *agent.Plan
+	selected int
+	reply    chan bool
+}
+
+// NewPlanReview starts reviewing a plan with its first step selected
+func NewPlanReview(plan *agent.Plan) *PlanReview {
+	return &PlanReview{Plan: plan, reply: make(chan bool, 1)}
+}
+
+// Selected returns the index of the selected step
+func (r *PlanReview) Selected() int {
+	return r.selected
+}
+
+// Select moves the selection by offset steps, stopping at either end
+func (r *PlanReview) Select(offset int) {
+	r.selected = min(max(r.selected+offset, 0), max(r.Plan.Len()-1, 0))
+}
+
+// MoveStep moves the selected step by offset places, keeping it selected
+func (r *PlanReview) MoveStep(offset int) {
+	r.selected = r.Plan.Move(r.selected, offset)
+}
+
+// RemoveStep removes the selected step and selects the one after it
+func (r *PlanReview) RemoveStep() {
+	r.Plan.Remove(r.selected)
+	r.Select(0)
+}
+
+// Approve lets the agent carry out the plan as edited
+func (r *PlanReview) Approve() {
+	r.decide(true)
+}
+
+// Cancel stops the agent from carrying out the plan
+func (r *PlanReview) Cancel() {
+	r.decide(false)
+}
+
+// decide passes the user's decision to the agent waiting for it; only the
+// first counts
+func (r *PlanReview) decide(approved bool) {
+	select {
+	case r.reply <- approved:
+	default:
+	}
+}
+
+// PlanReviews hands the agent's plans to a frontend that runs apart from the
+// agent, like the Bubbletea UI, and waits for the user to decide on them
+type PlanReviews struct {
+	reviews chan *PlanReview
+}
+
+// NewPlanReviews creates a reviewer whose plans are received from Next
+func NewPlanReviews() *PlanReviews {
+	return &PlanReviews{reviews: make(chan *PlanReview)}
+}
+
+// Next delivers the plans waiting to be reviewed
+func (p *PlanReviews) Next() <-chan *PlanReview {
+	return p.reviews
+}
+
+// ReviewPlan implements agent.PlanReviewer, blocking until the frontend
+// approves or cancels the plan
+func (p *PlanReviews) ReviewPlan(ctx context.Context, plan *agent.Plan) bool {
+	review := NewPlanReview(plan)
+	select {
+	case p.reviews <- review:
+	case <-ctx.Done():
+		return false
+	}
+	select {
+	case approved := <-review.reply:
+		return approved
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// PlanUpdated implements agent.PlanReviewer. The frontend redraws the plan
+// it approved as its steps change, so there's nothing to do.
+func (p *PlanReviews) PlanUpdated(plan *agent.Plan) {}
@@ -0,0 +1,70 @@
+package chat
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mizzy/rigel/internal/agent"
+)
+
+func testPlan(paths ...string) *agent.Plan {
+	var tasks []agent.Task
+	for _, path := range paths {
+		tasks = append(tasks, agent.Task{Description: "Read " + path, Match: agent.FileOperationMatch{Intent: agent.IntentRead, FilePath: path}})
+	}
+	return agent.NewPlan(tasks)
+}
+
+func planDescriptions(plan *agent.Plan) []string {
+	var descriptions []string
+	for _, step := range plan.Steps() {
+		descriptions = append(descriptions, step.Task.Description)
+	}
+	return descriptions
+}
+
+func TestPlanReviewEditing(t *testing.T) {
+	review := NewPlanReview(testPlan("a", "b", "c"))
+
+	review.Select(-1)
+	assert.Equal(t, 0, review.Selected())
+	review.MoveStep(1)
+	assert.Equal(t, 1, review.Selected())
+	assert.Equal(t, []string{"Read b", "Read a", "Read c"}, planDescriptions(review.Plan))
+
+	review.Select(5)
+	assert.Equal(t, 2, review.Selected())
+	review.RemoveStep()
+	assert.Equal(t, 1, review.Selected(), "the last step should be selected once the selected one is gone")
+	review.RemoveStep()
+	review.RemoveStep()
+	assert.Equal(t, 0, review.Plan.Len())
+	assert.Equal(t, 0, review.Selected())
+}
+
+func TestPlanReviews(t *testing.T) {
+	reviews := NewPlanReviews()
+
+	go func() {
+		review := <-reviews.Next()
+		review.Plan.Remove(0)
+		review.Approve()
+		review.Cancel() // Ignored once approved
+	}()
+	plan := testPlan("a", "b")
+	assert.True(t, reviews.ReviewPlan(context.Background(), plan))
+	assert.Equal(t, []string{"Read b"}, planDescriptions(plan))
+
+	go func() {
+		(<-reviews.Next()).Cancel()
+	}()
+	assert.False(t, reviews.ReviewPlan(context.Background(), testPlan("a")))
+
+	// Nobody reviews it
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.False(t, reviews.ReviewPlan(ctx, testPlan("a")))
+}
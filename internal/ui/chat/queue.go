@@ -0,0 +1,55 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxQueuePreview limits how much of a queued prompt the indicator shows
+const maxQueuePreview = 40
+
+// Enqueue queues a prompt typed while a response was being generated, to be
+// submitted once it completes
+func (c *Core) Enqueue(prompt string) {
+	if strings.TrimSpace(prompt) == "" {
+		return
+	}
+	c.queued = append(c.queued, prompt)
+}
+
+// Dequeue removes and returns the oldest queued prompt
+func (c *Core) Dequeue() (string, bool) {
+	if len(c.queued) == 0 {
+		return "", false
+	}
+	prompt := c.queued[0]
+	c.queued = c.queued[1:]
+	return prompt, true
+}
+
+// Queued returns the queued prompts, oldest first
+func (c *Core) Queued() []string {
+	return c.queued
+}
+
+// QueueIndicator describes the queued prompts in one line, or returns an
+// empty string if there are none
+func QueueIndicator(queued []string) string {
+	switch len(queued) {
+	case 0:
+		return ""
+	case 1:
+		return "⏎ queued: " + Preview(queued[0])
+	default:
+		return fmt.Sprintf("⏎ %d queued, next: %s", len(queued), Preview(queued[0]))
+	}
+}
+
+// Preview shortens text to one line of at most maxQueuePreview characters
+func Preview(text string) string {
+	line := strings.Join(strings.Fields(text), " ")
+	if runes := []rune(line); len(runes) > maxQueuePreview {
+		return string(runes[:maxQueuePreview-1]) + "…"
+	}
+	return line
+}
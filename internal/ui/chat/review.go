@@ -0,0 +1,55 @@
+package chat
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mizzy/rigel/internal/command"
+)
+
+// FormatReview renders the findings of /review grouped by severity, with
+// their file:line references as hyperlinks in terminals that support them
+func FormatReview(review *command.Review) string {
+	if !SupportsHyperlinks() {
+		return command.FormatReview(review, nil)
+	}
+	return command.FormatReview(review, func(root string, finding command.ReviewFinding) string {
+		return Hyperlink(fileURL(filepath.Join(root, finding.File)), finding.Location())
+	})
+}
+
+// Hyperlink wraps text in an OSC 8 hyperlink to target
+func Hyperlink(target, text string) string {
+	return "\x1b]8;;" + target + "\x1b\\" + text + "\x1b]8;;\x1b\\"
+}
+
+// fileURL returns the file:// URL of an absolute path
+func fileURL(path string) string {
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(path)}
+	if host, err := os.Hostname(); err == nil {
+		u.Host = host
+	}
+	return u.String()
+}
+
+// SupportsHyperlinks reports whether the terminal is known to open OSC 8
+// hyperlinks; others may print the escape sequences instead of hiding them
+func SupportsHyperlinks() bool {
+	if os.Getenv("TMUX") != "" || strings.HasPrefix(os.Getenv("TERM"), "screen") {
+		// tmux and screen drop them unless configured to pass them through
+		return false
+	}
+	switch os.Getenv("TERM_PROGRAM") {
+	case "iTerm.app", "WezTerm", "vscode", "ghostty", "Hyper":
+		return true
+	}
+	if os.Getenv("WT_SESSION") != "" || os.Getenv("KITTY_WINDOW_ID") != "" || os.Getenv("TERM") == "xterm-kitty" {
+		return true
+	}
+	// VTE-based terminals (GNOME Terminal, Tilix) since 0.50
+	version, err := strconv.Atoi(os.Getenv("VTE_VERSION"))
+	return err == nil && version >= 5000
+}
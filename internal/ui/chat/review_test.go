@@ -0,0 +1,37 @@
+package chat
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mizzy/rigel/internal/command"
+)
+
+func TestFormatReviewHyperlinks(t *testing.T) {
+	review := &command.Review{
+		Scope:    "staged changes",
+		Root:     "/src/app",
+		Files:    []string{"main.go"},
+		Findings: []command.ReviewFinding{{File: "main.go", Line: 12, Severity: command.SeverityCritical, Message: "Division by zero"}},
+	}
+	for _, name := range []string{"TMUX", "TERM", "TERM_PROGRAM", "WT_SESSION", "KITTY_WINDOW_ID", "VTE_VERSION"} {
+		t.Setenv(name, "")
+	}
+
+	assert.Contains(t, FormatReview(review), "- main.go:12: Division by zero")
+
+	t.Setenv("TERM_PROGRAM", "WezTerm")
+	host, _ := os.Hostname()
+	assert.Contains(t, FormatReview(review), "- \x1b]8;;file://"+host+"/src/app/main.go\x1b\\main.go:12\x1b]8;;\x1b\\: Division by zero")
+
+	// tmux swallows them
+	t.Setenv("TMUX", "/tmp/tmux-1000/default,1,0")
+	assert.False(t, SupportsHyperlinks())
+
+	t.Setenv("TMUX", "")
+	t.Setenv("TERM_PROGRAM", "")
+	t.Setenv("VTE_VERSION", "7600")
+	assert.True(t, SupportsHyperlinks())
+}
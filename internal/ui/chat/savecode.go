@@ -0,0 +1,22 @@
+package chat
+
+import (
+	"github.com/mizzy/rigel/internal/agent"
+	"github.com/mizzy/rigel/internal/command"
+)
+
+// saveCodeCommand saves the files of the last response's code blocks
+const saveCodeCommand = "/save-code"
+
+// SaveCodeOffer runs /save-code for a response that gives files in code
+// blocks named for them, when RIGEL_SAVE_CODE is set, so that the writes
+// await approval right away. The frontend handles the result as that of a
+// command; it is nil when there is nothing to offer.
+func (c *Core) SaveCodeOffer(response string) command.Result {
+	if c.Config == nil || !c.Config.SaveCode || len(agent.ExtractArtifacts(response)) == 0 {
+		return nil
+	}
+	c.ChatState.SetCurrentPrompt(saveCodeCommand)
+	c.ChatState.SetThinking(true)
+	return c.dispatch(saveCodeCommand)
+}
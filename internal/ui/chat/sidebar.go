@@ -0,0 +1,165 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mizzy/rigel/internal/llm"
+)
+
+// sidebarRecentInputs is how many of the latest inputs the History section
+// lists
+const sidebarRecentInputs = 5
+
+// SidebarSection is a section of the sidebar beside the chat
+type SidebarSection int
+
+const (
+	SectionChat SidebarSection = iota
+	SectionFiles
+	SectionHistory
+	SectionSettings
+)
+
+// SidebarSections lists the sections in the order the sidebar shows them
+var SidebarSections = []SidebarSection{SectionChat, SectionFiles, SectionHistory, SectionSettings}
+
+// String returns the title of the section
+func (s SidebarSection) String() string {
+	switch s {
+	case SectionChat:
+		return "Chat"
+	case SectionFiles:
+		return "Files"
+	case SectionHistory:
+		return "History"
+	case SectionSettings:
+		return "Settings"
+	}
+	return ""
+}
+
+// Command returns the slash command showing the section in full
+func (s SidebarSection) Command() string {
+	switch s {
+	case SectionChat:
+		return "/branches"
+	case SectionFiles:
+		return "/context"
+	case SectionHistory:
+		return "/history"
+	case SectionSettings:
+		return "/status"
+	}
+	return ""
+}
+
+// SidebarEntry is a section of the sidebar as the session fills it in
+type SidebarEntry struct {
+	Section SidebarSection
+	Count   int      // Shown beside the title; -1 for none
+	Details []string // Shown below the title while the section is selected
+}
+
+// Sidebar describes the sections of the sidebar as the session is now
+func (c *Core) Sidebar() []SidebarEntry {
+	entries := make([]SidebarEntry, 0, len(SidebarSections))
+	for _, section := range SidebarSections {
+		entry := SidebarEntry{Section: section, Count: -1}
+		switch section {
+		case SectionChat:
+			entry.Count, entry.Details = c.chatDetails()
+		case SectionFiles:
+			entry.Count, entry.Details = c.filesDetails()
+		case SectionHistory:
+			entry.Count, entry.Details = c.historyDetails()
+		case SectionSettings:
+			entry.Details = c.settingsDetails()
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// chatDetails returns the exchanges of the conversation, its branch, and
+// what waits for the next prompt
+func (c *Core) chatDetails() (int, []string) {
+	exchanges := len(c.ChatState.GetHistory())
+	var details []string
+	if c.Branches != nil {
+		details = append(details, fmt.Sprintf("branch %s (%d in all)", c.Branches.GetCurrent().Name, len(c.Branches.GetBranches())))
+	}
+	if queued := len(c.queued); queued > 0 {
+		details = append(details, fmt.Sprintf("%d queued", queued))
+	}
+	if c.Agent != nil {
+		if images := c.Agent.AttachedImages(); images > 0 {
+			details = append(details, fmt.Sprintf("%d images attached", images))
+		}
+	}
+	if len(details) == 0 {
+		details = append(details, "no exchanges yet")
+	}
+	return exchanges, details
+}
+
+// filesDetails returns the files the agent read or wrote, marking those
+// changed on disk since
+func (c *Core) filesDetails() (int, []string) {
+	if c.Agent == nil {
+		return 0, nil
+	}
+	files := c.Agent.ContextFiles()
+	details := make([]string, 0, len(files))
+	for _, file := range files {
+		if file.Changed {
+			details = append(details, file.Path+" (changed)")
+		} else {
+			details = append(details, file.Path)
+		}
+	}
+	if len(details) == 0 {
+		details = append(details, "none read or written yet")
+	}
+	return len(files), details
+}
+
+// historyDetails returns the inputs submitted so far, the latest first
+func (c *Core) historyDetails() (int, []string) {
+	inputs := c.inputHistory
+	var details []string
+	for i := len(inputs) - 1; i >= 0 && len(details) < sidebarRecentInputs; i-- {
+		// Multi-line inputs show their first line
+		line, _, _ := strings.Cut(inputs[i], "\n")
+		details = append(details, line)
+	}
+	return len(inputs), details
+}
+
+// settingsDetails returns the provider and model, how the agent answers
+// and what the conversation has cost so far
+func (c *Core) settingsDetails() []string {
+	var details []string
+	if provider := c.LLMState.GetCurrentProvider(); provider != nil {
+		details = append(details, "provider "+provider.GetName(), "model "+c.LLMState.GetCurrentModel().Name)
+		if usage, ok := llm.FindUsage(provider); ok && usage.Requests > 0 {
+			cost := ""
+			if usage.CostKnown {
+				cost = " · " + formatCost(usage.Cost)
+			}
+			details = append(details, "~"+formatTokens(usage.ContextTokens)+" tokens"+cost)
+		}
+	}
+	if c.Config != nil && c.Config.Theme != "" {
+		details = append(details, "theme "+c.Config.Theme)
+	}
+	if c.Agent != nil {
+		if p := c.Agent.Persona(); p != nil {
+			details = append(details, "persona "+p.Name)
+		}
+		if c.Agent.IsDryRun() {
+			details = append(details, "dry run")
+		}
+	}
+	return details
+}
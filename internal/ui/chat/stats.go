@@ -0,0 +1,61 @@
+package chat
+
+import (
+	"log/slog"
+
+	"github.com/mizzy/rigel/internal/config"
+	"github.com/mizzy/rigel/internal/llm"
+	"github.com/mizzy/rigel/internal/stats"
+)
+
+// newStatsStore returns the store usage statistics are recorded in, or nil
+// if RIGEL_STATS turned them off
+func newStatsStore(cfg *config.Config) *stats.Store {
+	if cfg == nil || !cfg.Stats {
+		return nil
+	}
+	store, err := stats.NewStore()
+	if err != nil {
+		slog.Warn("usage statistics disabled", "error", err)
+		return nil
+	}
+	return store
+}
+
+// countTool counts a tool the agent ran toward the usage statistics
+func (c *Core) countTool(tool string) {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	c.statsTools = append(c.statsTools, tool)
+}
+
+// recordStats adds what was used since it was last called to the usage
+// statistics: the prompt the agent answered, if any, the tokens the
+// provider metered and the tools the agent ran
+func (c *Core) recordStats() {
+	c.statsMu.Lock()
+	prompts, tools := c.statsPrompts, c.statsTools
+	c.statsPrompts, c.statsTools = 0, nil
+	c.statsMu.Unlock()
+	if c.stats == nil {
+		return
+	}
+
+	usage := stats.Usage{Prompts: prompts, Tools: tools}
+	if provider := c.LLMState.GetCurrentProvider(); provider != nil {
+		usage.Model = provider.GetName() + "/" + provider.GetCurrentModel().Name
+		if metered, ok := llm.FindUsage(provider); ok {
+			// Switching providers starts metering over
+			last := c.statsUsage
+			if metered.Requests < last.Requests {
+				last = llm.Usage{}
+			}
+			usage.InputTokens = metered.InputTokens - last.InputTokens
+			usage.OutputTokens = metered.OutputTokens - last.OutputTokens
+			c.statsUsage = metered
+		}
+	}
+	if err := c.stats.Add(usage); err != nil {
+		slog.Warn("failed to record usage statistics", "error", err)
+	}
+}
@@ -0,0 +1,102 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mizzy/rigel/internal/command"
+)
+
+// FormatStatus renders session status information as plain text
+func FormatStatus(status *command.StatusInfo, uiMode string) string {
+	return formatSummary(status, uiMode) + formatAgents(status) + formatPromptCache(status) + formatWorkspace(status)
+}
+
+func formatSummary(status *command.StatusInfo, uiMode string) string {
+	return fmt.Sprintf("✦ Rigel Session Status\n\n"+
+		"🤖 LLM Configuration\n"+
+		"  Provider: %s\n"+
+		"  Model: %s\n"+
+		"  Persona: %s\n\n"+
+		"💬 Chat History\n"+
+		"  Messages: %d\n"+
+		"  User tokens: ~%d\n"+
+		"  Assistant tokens: ~%d\n"+
+		"  Total tokens: ~%d\n\n"+
+		"📝 Command History\n"+
+		"  Commands saved: %d\n"+
+		"  Persistence: %s\n\n"+
+		"🔧 Environment\n"+
+		"  UI Mode: %s\n"+
+		"  Log level: %s\n"+
+		"  Repository context: %s\n",
+		status.Provider, status.Model, status.Persona,
+		status.MessageCount,
+		status.UserTokens, status.AssistantTokens, status.TotalTokens,
+		status.CommandsCount,
+		checkmark(status.PersistenceEnabled, "Enabled", "Disabled"),
+		uiMode,
+		status.LogLevel,
+		checkmark(status.RepositoryInitialized, "AGENTS.md loaded", "Not initialized (run /init)"))
+}
+
+// formatAgents tells when and by which rigel AGENTS.md was generated, and
+// whether it should be refreshed
+func formatAgents(status *command.StatusInfo) string {
+	var sb strings.Builder
+	if m := status.AgentsMetadata; m != nil {
+		sb.WriteString(fmt.Sprintf("  AGENTS.md generated: %s by rigel %s (format %d)\n",
+			m.GeneratedAt.Local().Format("2006-01-02 15:04"), m.AnalyzerVersion, m.FormatVersion))
+	}
+	if status.AgentsWarning != "" {
+		sb.WriteString("  ⚠ " + status.AgentsWarning + "\n")
+	}
+	return sb.String()
+}
+
+// formatPromptCache reports how much of the input Anthropic's prompt cache
+// served and what that saved
+func formatPromptCache(status *command.StatusInfo) string {
+	stats := status.PromptCache
+	if stats == nil {
+		return ""
+	}
+	return fmt.Sprintf("\n⚡ Prompt Cache\n"+
+		"  Requests: %d\n"+
+		"  Cache reads: %d tokens (%.0f%% of input)\n"+
+		"  Cache writes: %d tokens\n"+
+		"  Estimated savings: $%.4f\n",
+		stats.Requests,
+		stats.ReadTokens, stats.HitRate()*100,
+		stats.WriteTokens,
+		stats.Savings)
+}
+
+// formatWorkspace lists the workspace roots when there is more than the
+// current directory
+func formatWorkspace(status *command.StatusInfo) string {
+	if len(status.Workspace) < 2 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\n📁 Workspace\n")
+	for i, root := range status.Workspace {
+		access := "read-write"
+		if root.ReadOnly {
+			access = "read-only"
+		}
+		if i == 0 {
+			access += ", primary"
+		}
+		sb.WriteString(fmt.Sprintf("  %s: %s (%s)\n", root.Label, root.Path, access))
+	}
+	return sb.String()
+}
+
+func checkmark(ok bool, yes, no string) string {
+	if ok {
+		return "✓ " + yes
+	}
+	return "✗ " + no
+}
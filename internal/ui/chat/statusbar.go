@@ -0,0 +1,81 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mizzy/rigel/internal/git"
+	"github.com/mizzy/rigel/internal/llm"
+)
+
+// StatusBar summarizes the session in one line: the provider and model,
+// the git branch, how many tokens the conversation takes and what it has
+// cost so far, and whether the agent only describes what it would do
+type StatusBar struct {
+	Provider string
+	Model    string
+	Branch   string
+	Usage    *llm.Usage // nil if the provider isn't metered
+	DryRun   bool
+}
+
+// StatusBar describes the session as it is now, looking up the branch
+// again since the agent may have switched it
+func (c *Core) StatusBar() StatusBar {
+	var bar StatusBar
+	if provider := c.LLMState.GetCurrentProvider(); provider != nil {
+		bar.Provider = provider.GetName()
+		bar.Model = c.LLMState.GetCurrentModel().Name
+		if usage, ok := llm.FindUsage(provider); ok {
+			bar.Usage = &usage
+		}
+	}
+	c.GitInfo = git.GetRepoInfo()
+	if c.GitInfo != nil {
+		bar.Branch = c.GitInfo.Branch
+	}
+	bar.DryRun = c.Agent != nil && c.Agent.IsDryRun()
+	return bar
+}
+
+// String renders the status bar, e.g.
+// "anthropic/claude-sonnet-4 · ⎇ main · ~12.3k tokens · $0.04"
+func (b StatusBar) String() string {
+	var parts []string
+	if b.Provider != "" {
+		model := b.Provider
+		if b.Model != "" {
+			model += "/" + b.Model
+		}
+		parts = append(parts, model)
+	}
+	if b.Branch != "" {
+		parts = append(parts, "⎇ "+b.Branch)
+	}
+	if b.Usage != nil && b.Usage.Requests > 0 {
+		parts = append(parts, "~"+formatTokens(b.Usage.ContextTokens)+" tokens")
+		if b.Usage.CostKnown {
+			parts = append(parts, formatCost(b.Usage.Cost))
+		}
+	}
+	if b.DryRun {
+		parts = append(parts, "dry run")
+	}
+	return strings.Join(parts, " · ")
+}
+
+// formatTokens shortens a token count, e.g. 12345 to 12.3k
+func formatTokens(n int) string {
+	if n < 1000 {
+		return fmt.Sprintf("%d", n)
+	}
+	return fmt.Sprintf("%.1fk", float64(n)/1000)
+}
+
+// formatCost renders a cost in US dollars, showing small amounts as <$0.01
+func formatCost(cost float64) string {
+	if cost > 0 && cost < 0.01 {
+		return "<$0.01"
+	}
+	return fmt.Sprintf("$%.2f", cost)
+}
@@ -0,0 +1,75 @@
+package chat
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mizzy/rigel/internal/agent"
+	"github.com/mizzy/rigel/internal/privacy"
+	"github.com/mizzy/rigel/internal/session"
+)
+
+// RecordTool adds a tool the agent ran to the transcript of the current
+// branch of the conversation, shown by /transcript, and counts it toward the
+// usage statistics
+func (c *Core) RecordTool(result agent.ToolExecutionResult) {
+	c.countTool(result.Tool)
+	if c.Branches == nil {
+		return
+	}
+	store, err := session.NewStore()
+	if err != nil {
+		slog.Warn("failed to record tool call", "tool", result.Tool, "error", err)
+		return
+	}
+
+	call := session.ToolCall{
+		Time:     result.StartTime,
+		Tool:     result.Tool,
+		Input:    result.Input,
+		Output:   result.Output,
+		Duration: result.Duration,
+		Approval: session.ApprovalAuto,
+	}
+	if result.Error != nil {
+		call.Error = result.Error.Error()
+	}
+	if err := store.AppendToolCall(c.Branches.GetCurrent().ID, call); err != nil {
+		slog.Warn("failed to record tool call", "tool", result.Tool, "error", err)
+	}
+}
+
+// AppendTranscript appends the conversation as plain text to the file at
+// path, e.g. RIGEL_TRANSCRIPT_LOG, so that it outlives the screen it was
+// shown on. Nothing is written if nothing was said. Since a prompt may quote
+// secrets, only the user can read a new file, and secrets are masked if
+// redaction is configured. The file stays plain text to be read as it is,
+// so it is never encrypted.
+func (c *Core) AppendTranscript(path string) error {
+	history := c.ChatState.GetHistory()
+	if len(history) == 0 {
+		return nil
+	}
+
+	var s strings.Builder
+	fmt.Fprintf(&s, "# %s\n\n", time.Now().Format("2006-01-02 15:04:05"))
+	for _, ex := range history {
+		for line := range strings.SplitSeq(ex.Prompt, "\n") {
+			s.WriteString(strings.TrimRight("> "+line, " ") + "\n")
+		}
+		s.WriteString("\n" + strings.TrimRight(ex.Response, "\n") + "\n\n")
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(privacy.Redact(s.String())); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
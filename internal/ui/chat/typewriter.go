@@ -0,0 +1,132 @@
+package chat
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mizzy/rigel/internal/config"
+)
+
+// TypewriterInterval is how often frontends advance a typewriter
+const TypewriterInterval = 16 * time.Millisecond
+
+// smoothCatchUp is the share of the text waiting to be shown that smooth
+// rendering reveals on each advance: 1/smoothCatchUp of it, at least a
+// character, so that it keeps up with fast models without jumping ahead
+const smoothCatchUp = 8
+
+// Typewriter paces how a streamed response is revealed, as configured with
+// RIGEL_STREAM_RENDER. The agent writes pieces of the response to it as they
+// arrive, and the frontend advances it on a timer to show what is due.
+type Typewriter struct {
+	mu       sync.Mutex
+	mode     string
+	delay    time.Duration // Between characters with config.StreamCharacterDelay
+	received []rune
+	shown    int
+	last     time.Time // When characters were last revealed
+	skipped  bool      // Everything is shown as soon as it arrives
+	done     bool      // The whole response was received
+}
+
+// NewTypewriter creates a typewriter for a rendering mode from the config
+func NewTypewriter(mode string, delay time.Duration) *Typewriter {
+	return &Typewriter{
+		mode:    mode,
+		delay:   delay,
+		skipped: mode == config.StreamInstant || mode == "",
+	}
+}
+
+// NewTypewriter creates a typewriter as configured
+func (c *Core) NewTypewriter() *Typewriter {
+	if c.Config == nil {
+		return NewTypewriter(config.StreamInstant, 0)
+	}
+	return NewTypewriter(c.Config.StreamRender, c.Config.StreamCharDelay)
+}
+
+// Write adds a piece of the response. It may be called from any goroutine.
+func (t *Typewriter) Write(chunk string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.received = append(t.received, []rune(chunk)...)
+	if t.skipped {
+		t.shown = len(t.received)
+	}
+}
+
+// Finish replaces what was received with the complete response, which ends
+// with any text that wasn't streamed
+func (t *Typewriter) Finish(response string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.received = []rune(response)
+	t.shown = min(t.shown, len(t.received))
+	if t.skipped {
+		t.shown = len(t.received)
+	}
+	t.done = true
+}
+
+// Advance reveals what is due at now and returns the newly revealed text
+func (t *Typewriter) Advance(now time.Time) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	backlog := len(t.received) - t.shown
+	if backlog == 0 {
+		// Don't let the time spent waiting for the model burst out later
+		t.last = time.Time{}
+		return ""
+	}
+
+	n := backlog
+	switch {
+	case t.skipped:
+	case t.mode == config.StreamSmooth:
+		n = max(1, backlog/smoothCatchUp)
+	case t.mode == config.StreamCharacterDelay && t.delay > 0:
+		if t.last.IsZero() {
+			t.last = now
+			n = 1
+			break
+		}
+		n = int(now.Sub(t.last) / t.delay)
+		t.last = t.last.Add(time.Duration(n) * t.delay)
+	}
+	n = min(n, backlog)
+
+	revealed := string(t.received[t.shown : t.shown+n])
+	t.shown += n
+	return revealed
+}
+
+// Shown returns the text revealed so far
+func (t *Typewriter) Shown() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(t.received[:t.shown])
+}
+
+// Pending reports whether text received is still waiting to be revealed
+func (t *Typewriter) Pending() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.shown < len(t.received)
+}
+
+// Done reports whether the whole response was received and revealed
+func (t *Typewriter) Done() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.done && t.shown == len(t.received)
+}
+
+// Skip jumps to the complete output: what was received is revealed on the
+// next advance, and the rest as soon as it arrives
+func (t *Typewriter) Skip() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.skipped = true
+}
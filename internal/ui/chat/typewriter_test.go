@@ -0,0 +1,79 @@
+package chat
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mizzy/rigel/internal/config"
+)
+
+func TestTypewriter(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("instant shows each piece as it arrives", func(t *testing.T) {
+		tw := NewTypewriter(config.StreamInstant, 0)
+		tw.Write("Hello, ")
+		assert.Equal(t, "Hello, ", tw.Shown())
+		assert.False(t, tw.Pending())
+
+		tw.Finish("Hello, world")
+		assert.Equal(t, "Hello, world", tw.Shown())
+		assert.True(t, tw.Done())
+	})
+
+	t.Run("smooth catches up gradually", func(t *testing.T) {
+		tw := NewTypewriter(config.StreamSmooth, 0)
+		tw.Write(strings.Repeat("a", 80))
+		assert.Equal(t, "", tw.Shown())
+
+		assert.Len(t, tw.Advance(start), 10)
+		assert.Len(t, tw.Advance(start), 8)
+		for tw.Pending() {
+			assert.NotEmpty(t, tw.Advance(start))
+		}
+		assert.Len(t, tw.Shown(), 80)
+		assert.False(t, tw.Done(), "the response isn't complete yet")
+	})
+
+	t.Run("character delay reveals a character per delay", func(t *testing.T) {
+		tw := NewTypewriter(config.StreamCharacterDelay, 10*time.Millisecond)
+		tw.Write("héllo world")
+
+		assert.Equal(t, "h", tw.Advance(start))
+		assert.Equal(t, "", tw.Advance(start.Add(5*time.Millisecond)))
+		assert.Equal(t, "é", tw.Advance(start.Add(10*time.Millisecond)))
+		assert.Equal(t, "llo", tw.Advance(start.Add(40*time.Millisecond)))
+
+		// Waiting for the model doesn't make the next piece burst out
+		assert.Equal(t, " world", tw.Advance(start.Add(time.Second)))
+		assert.Equal(t, "", tw.Advance(start.Add(2*time.Second)))
+		tw.Write("!")
+		assert.Equal(t, "!", tw.Advance(start.Add(time.Hour)))
+	})
+
+	t.Run("skip jumps to the complete output", func(t *testing.T) {
+		tw := NewTypewriter(config.StreamCharacterDelay, time.Second)
+		tw.Write("The answer")
+		assert.Equal(t, "T", tw.Advance(start))
+
+		tw.Skip()
+		assert.Equal(t, "he answer", tw.Advance(start))
+		tw.Write(" is 42")
+		assert.Equal(t, "The answer is 42", tw.Shown())
+	})
+
+	t.Run("finish adds the text that wasn't streamed", func(t *testing.T) {
+		tw := NewTypewriter(config.StreamSmooth, 0)
+		tw.Write("streamed")
+		tw.Finish("streamed\n\nsummary")
+		assert.True(t, tw.Pending())
+		for tw.Pending() {
+			tw.Advance(start)
+		}
+		assert.Equal(t, "streamed\n\nsummary", tw.Shown())
+		assert.True(t, tw.Done())
+	})
+}
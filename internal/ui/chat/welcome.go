@@ -0,0 +1,46 @@
+package chat
+
+import (
+	"os"
+
+	"github.com/mizzy/rigel/internal/session"
+)
+
+// welcomeSessions is how many of the latest sessions the welcome panel lists
+const welcomeSessions = 5
+
+// Welcome is what the welcome panel shows at startup
+type Welcome struct {
+	Provider string
+	Model    string
+	AgentsMD bool               // Whether the working directory has an AGENTS.md
+	Sessions []*session.Session // The latest saved sessions, most recent first
+}
+
+// Welcome describes the session starting: the provider and model, whether
+// AGENTS.md exists, and the saved sessions that can be resumed
+func (c *Core) Welcome() Welcome {
+	var w Welcome
+	if provider := c.LLMState.GetCurrentProvider(); provider != nil {
+		w.Provider = provider.GetName()
+		w.Model = c.LLMState.GetCurrentModel().Name
+	}
+	if _, err := os.Stat("AGENTS.md"); err == nil {
+		w.AgentsMD = true
+	}
+	if store, err := session.NewStore(); err == nil {
+		if sessions, err := store.List(); err == nil {
+			w.Sessions = sessions[:min(len(sessions), welcomeSessions)]
+		}
+	}
+	return w
+}
+
+// ResumeCommand returns the command resuming the nth session listed, from
+// 1, or "" if there is none
+func (w Welcome) ResumeCommand(n int) string {
+	if n < 1 || n > len(w.Sessions) {
+		return ""
+	}
+	return "/resume " + w.Sessions[n-1].ID
+}
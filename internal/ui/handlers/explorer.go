@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mizzy/rigel/internal/state"
+)
+
+// ExplorerAction is what a key pressed in the file explorer asks for
+type ExplorerAction struct {
+	Close bool     // The explorer was closed
+	Add   []string // Files to add to the conversation
+	Err   error    // A directory couldn't be listed
+}
+
+// HandleFileExplorerKey handles key input in the file explorer, which lists
+// pageSize entries at a time: Enter opens a directory or adds the file, or
+// the files marked with Space
+func HandleFileExplorerKey(msg tea.KeyMsg, explorer *state.FileExplorer, pageSize int) ExplorerAction {
+	switch msg.Type {
+	case tea.KeyEsc, tea.KeyCtrlC, tea.KeyCtrlE:
+		explorer.Deactivate()
+		return ExplorerAction{Close: true}
+
+	case tea.KeyEnter, tea.KeyRight:
+		path, isFile, err := explorer.Open()
+		if err != nil {
+			return ExplorerAction{Err: err}
+		}
+		if !isFile || msg.Type == tea.KeyRight {
+			return ExplorerAction{}
+		}
+		add := explorer.GetMarked()
+		if len(add) == 0 {
+			add = []string{path}
+		}
+		explorer.Deactivate()
+		return ExplorerAction{Close: true, Add: add}
+
+	case tea.KeyLeft, tea.KeyBackspace:
+		if err := explorer.Parent(); err != nil {
+			return ExplorerAction{Err: err}
+		}
+
+	case tea.KeySpace:
+		explorer.ToggleMarked()
+		explorer.Move(1)
+
+	case tea.KeyUp:
+		explorer.Move(-1)
+
+	case tea.KeyDown:
+		explorer.Move(1)
+
+	case tea.KeyPgUp:
+		explorer.Move(-pageSize)
+
+	case tea.KeyPgDown:
+		explorer.Move(pageSize)
+	}
+	return ExplorerAction{}
+}
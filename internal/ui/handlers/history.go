@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mizzy/rigel/internal/history"
+	"github.com/mizzy/rigel/internal/state"
+)
+
+// HistoryAction is what a key pressed in the history picker asks for
+type HistoryAction struct {
+	Close  bool           // The picker was closed
+	Run    string         // Prompt to send again
+	Edit   string         // Prompt to put in the input for editing
+	Delete *history.Entry // Entry to remove from the history
+}
+
+// HandleHistoryPickerKey handles key input in the history picker, which
+// lists pageSize prompts at a time
+func HandleHistoryPickerKey(msg tea.KeyMsg, picker *state.HistoryPicker, chatState *state.ChatState, pageSize int) HistoryAction {
+	switch msg.Type {
+	case tea.KeyEsc, tea.KeyCtrlC:
+		picker.Deactivate()
+		chatState.SetThinking(false)
+		return HistoryAction{Close: true}
+
+	case tea.KeyEnter, tea.KeyTab:
+		entry, ok := picker.GetSelected()
+		if !ok {
+			return HistoryAction{}
+		}
+		picker.Deactivate()
+		chatState.SetThinking(false)
+		if msg.Type == tea.KeyTab {
+			return HistoryAction{Close: true, Edit: entry.Command}
+		}
+		return HistoryAction{Close: true, Run: entry.Command}
+
+	case tea.KeyDelete, tea.KeyCtrlD:
+		if entry, ok := picker.RemoveSelected(); ok {
+			return HistoryAction{Delete: &entry}
+		}
+
+	case tea.KeyUp:
+		picker.Move(-1)
+
+	case tea.KeyDown:
+		picker.Move(1)
+
+	case tea.KeyPgUp:
+		picker.Move(-pageSize)
+
+	case tea.KeyPgDown:
+		picker.Move(pageSize)
+
+	case tea.KeyBackspace, tea.KeyRunes:
+		if filter, ok := editFilter(msg, picker.GetFilter()); ok {
+			picker.SetFilter(filter)
+		}
+	}
+	return HistoryAction{}
+}
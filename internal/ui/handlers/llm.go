@@ -7,6 +7,7 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/mizzy/rigel/internal/agent"
+	"github.com/mizzy/rigel/internal/command"
 	"github.com/mizzy/rigel/internal/config"
 	"github.com/mizzy/rigel/internal/llm"
 	"github.com/mizzy/rigel/internal/state"
@@ -18,11 +19,10 @@ type AIResponse struct {
 	Error   error
 }
 
-// RequestResponseWithAgent sends a request using the intelligent agent
-func RequestResponseWithAgent(prompt string, agentInstance *agent.Agent) tea.Cmd {
+// RequestResponseWithAgent sends a request using the intelligent agent.
+// Cancelling ctx interrupts it.
+func RequestResponseWithAgent(ctx context.Context, prompt string, agentInstance *agent.Agent) tea.Cmd {
 	return func() tea.Msg {
-		ctx := context.Background()
-
 		response, err := agentInstance.Execute(ctx, prompt)
 		if err != nil {
 			return AIResponse{Error: err}
@@ -83,7 +83,6 @@ type SelectionResult struct {
 	ShouldSwitch bool
 	SwitchCmd    tea.Cmd
 	InputValue   string
-	Placeholder  string
 }
 
 // HandleProviderSelectionKey handles key input during provider selection
@@ -111,34 +110,58 @@ func HandleProviderSelectionKey(msg tea.KeyMsg, llmState *state.LLMState, chatSt
 		llmState.MoveProviderSelectionDown()
 		return SelectionResult{}
 
+	case tea.KeyBackspace, tea.KeyRunes:
+		// Handle typing for fuzzy filtering
+		if filter, ok := editFilter(msg, llmState.GetProviderFilter()); ok {
+			llmState.SetProviderFilter(filter)
+		}
+		return SelectionResult{}
+
 	default:
 		return SelectionResult{}
 	}
 }
 
-// HandleModelSelectionKey handles key input during model selection
-func HandleModelSelectionKey(msg tea.KeyMsg, llmState *state.LLMState, chatState *state.ChatState, input InputUpdater) SelectionResult {
+// editFilter applies a backspace or printable characters to a selector
+// filter, reporting whether the filter changed
+func editFilter(msg tea.KeyMsg, filter string) (string, bool) {
+	switch msg.Type {
+	case tea.KeyBackspace:
+		if len(filter) > 0 {
+			return filter[:len(filter)-1], true
+		}
+	case tea.KeyRunes:
+		// Ignore non-printable characters
+		runes := msg.Runes
+		if len(runes) > 0 && runes[0] >= 32 && runes[0] <= 126 {
+			return filter + string(runes), true
+		}
+	}
+	return filter, false
+}
+
+// HandleModelSelectionKey handles key input during model selection, which
+// lists pageSize models at a time
+func HandleModelSelectionKey(msg tea.KeyMsg, llmState *state.LLMState, chatState *state.ChatState, cfg *config.Config, input InputUpdater, pageSize int) SelectionResult {
 	switch msg.Type {
 	case tea.KeyEsc:
 		llmState.DeactivateModelSelection()
 		chatState.SetThinking(false)
 		return SelectionResult{
-			ShouldExit:  true,
-			InputValue:  "",
-			Placeholder: "Type a message or / for commands (Alt+Enter for new line)",
+			ShouldExit: true,
+			InputValue: "",
 		}
 
 	case tea.KeyEnter:
 		if model, ok := llmState.GetSelectedModel(); ok {
 			llmState.DeactivateModelSelection()
 			chatState.SetThinking(false)
-			cmd := CreateModelSwitchCommand(model, llmState)
+			cmd := CreateModelSwitchCommand(model, llmState, cfg)
 			return SelectionResult{
 				ShouldExit:   true,
 				ShouldSwitch: true,
 				SwitchCmd:    cmd,
 				InputValue:   "",
-				Placeholder:  "Type a message or / for commands (Alt+Enter for new line)",
 			}
 		}
 		return SelectionResult{}
@@ -151,30 +174,23 @@ func HandleModelSelectionKey(msg tea.KeyMsg, llmState *state.LLMState, chatState
 		llmState.MoveModelSelectionDown()
 		return SelectionResult{}
 
-	case tea.KeyBackspace:
-		// Handle backspace for filtering
-		currentFilter := llmState.GetModelFilter()
-		if len(currentFilter) > 0 {
-			newFilter := currentFilter[:len(currentFilter)-1]
-			llmState.SetModelFilter(newFilter)
-			return SelectionResult{InputValue: newFilter}
-		}
+	case tea.KeyPgUp:
+		llmState.MoveModelSelection(-pageSize)
 		return SelectionResult{}
 
-	case tea.KeyRunes:
-		// Handle printable characters for filtering
-		runes := msg.Runes
-		if len(runes) > 0 {
-			// Ignore non-printable characters
-			if runes[0] < 32 || runes[0] > 126 {
-				return SelectionResult{}
-			}
+	case tea.KeyPgDown:
+		llmState.MoveModelSelection(pageSize)
+		return SelectionResult{}
+
+	case tea.KeyTab:
+		llmState.CycleModelSort()
+		return SelectionResult{}
 
-			// Add to filter
-			currentFilter := llmState.GetModelFilter()
-			newFilter := currentFilter + string(runes)
-			llmState.SetModelFilter(newFilter)
-			return SelectionResult{InputValue: newFilter}
+	case tea.KeyBackspace, tea.KeyRunes:
+		// Handle typing for fuzzy filtering
+		if filter, ok := editFilter(msg, llmState.GetModelFilter()); ok {
+			llmState.SetModelFilter(filter)
+			return SelectionResult{InputValue: filter}
 		}
 		return SelectionResult{}
 
@@ -186,9 +202,12 @@ func HandleModelSelectionKey(msg tea.KeyMsg, llmState *state.LLMState, chatState
 // CreateProviderSwitchCommand creates a command to switch providers
 func CreateProviderSwitchCommand(provider llm.Provider, cfg *config.Config) tea.Cmd {
 	return func() tea.Msg {
-		// Update config
+		// Update config and restore the model last used with the provider
 		if cfg != nil {
 			cfg.Provider = provider.GetName()
+			if model := cfg.ModelFor(provider.GetName()); model != "" {
+				provider.SetModel(llm.Model{Name: model})
+			}
 		}
 
 		return ProviderSwitchResponse{
@@ -198,14 +217,10 @@ func CreateProviderSwitchCommand(provider llm.Provider, cfg *config.Config) tea.
 	}
 }
 
-// CreateModelSwitchCommand creates a command to switch models
-func CreateModelSwitchCommand(model llm.Model, llmState *state.LLMState) tea.Cmd {
-	// Actually switch the model
-	provider := llmState.GetCurrentProvider()
-	if provider != nil {
-		provider.SetModel(model)
-		llmState.SetCurrentModel(model)
-	}
+// CreateModelSwitchCommand creates a command to switch models, remembering
+// the model for the provider
+func CreateModelSwitchCommand(model llm.Model, llmState *state.LLMState, cfg *config.Config) tea.Cmd {
+	command.SetModel(llmState, cfg, model)
 
 	return func() tea.Msg {
 		return AIResponse{
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mizzy/rigel/internal/state"
+)
+
+// PaletteAction is what a key pressed in the command palette asks for
+type PaletteAction struct {
+	Close bool   // The palette was closed
+	Run   string // Command to run
+	Edit  string // Command to put in the input, to type its arguments
+}
+
+// HandleCommandPaletteKey handles key input in the command palette, which
+// lists pageSize commands at a time. Enter runs the selected command, or
+// puts it in the input if it needs arguments; Tab always puts it there.
+func HandleCommandPaletteKey(msg tea.KeyMsg, palette *state.CommandPalette, pageSize int) PaletteAction {
+	switch msg.Type {
+	case tea.KeyEsc, tea.KeyCtrlC, tea.KeyCtrlP:
+		palette.Deactivate()
+		return PaletteAction{Close: true}
+
+	case tea.KeyEnter, tea.KeyTab:
+		entry, ok := palette.GetSelected()
+		if !ok {
+			return PaletteAction{}
+		}
+		palette.Deactivate()
+		if msg.Type == tea.KeyTab || entry.NeedsArgs {
+			return PaletteAction{Close: true, Edit: entry.Name + " "}
+		}
+		return PaletteAction{Close: true, Run: entry.Name}
+
+	case tea.KeyUp:
+		palette.Move(-1)
+
+	case tea.KeyDown:
+		palette.Move(1)
+
+	case tea.KeyPgUp:
+		palette.Move(-pageSize)
+
+	case tea.KeyPgDown:
+		palette.Move(pageSize)
+
+	case tea.KeyBackspace, tea.KeyRunes, tea.KeySpace:
+		if msg.Type == tea.KeySpace {
+			msg = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{' '}}
+		}
+		if filter, ok := editFilter(msg, palette.GetFilter()); ok {
+			palette.SetFilter(filter)
+		}
+	}
+	return PaletteAction{}
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mizzy/rigel/internal/session"
+	"github.com/mizzy/rigel/internal/state"
+)
+
+// SearchAction is what a key pressed in the /search results asks for
+type SearchAction struct {
+	Close bool         // The results were closed
+	Open  *session.Hit // Exchange to show
+}
+
+// HandleSearchPickerKey handles key input in the /search results, which
+// list pageSize exchanges at a time
+func HandleSearchPickerKey(msg tea.KeyMsg, picker *state.SearchPicker, chatState *state.ChatState, pageSize int) SearchAction {
+	switch msg.Type {
+	case tea.KeyEsc, tea.KeyCtrlC:
+		picker.Deactivate()
+		chatState.SetThinking(false)
+		return SearchAction{Close: true}
+
+	case tea.KeyEnter:
+		hit, ok := picker.GetSelected()
+		if !ok {
+			return SearchAction{}
+		}
+		picker.Deactivate()
+		chatState.SetThinking(false)
+		return SearchAction{Close: true, Open: &hit}
+
+	case tea.KeyUp:
+		picker.Move(-1)
+
+	case tea.KeyDown:
+		picker.Move(1)
+
+	case tea.KeyPgUp:
+		picker.Move(-pageSize)
+
+	case tea.KeyPgDown:
+		picker.Move(pageSize)
+	}
+	return SearchAction{}
+}
+
+// HandleSessionViewerKey handles key input while a session is shown
+// read-only: ←/→ move between its exchanges, Esc closes it
+func HandleSessionViewerKey(msg tea.KeyMsg, viewer *state.SessionViewer) {
+	switch msg.Type {
+	case tea.KeyEsc, tea.KeyCtrlC, tea.KeyEnter:
+		viewer.Close()
+
+	case tea.KeyLeft, tea.KeyUp, tea.KeyPgUp:
+		viewer.Move(-1)
+
+	case tea.KeyRight, tea.KeyDown, tea.KeyPgDown:
+		viewer.Move(1)
+	}
+}
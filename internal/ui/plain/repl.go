@@ -0,0 +1,339 @@
+// Package plain is the line-based chat used with --ui plain and when stdout
+// is not a terminal, as in rigel | tee log: it prints plain lines, without
+// colors or cursor movement, and reads whole lines, keeping the slash
+// commands of the termflow UI.
+package plain
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/mizzy/rigel/internal/command"
+	"github.com/mizzy/rigel/internal/config"
+	"github.com/mizzy/rigel/internal/llm"
+	"github.com/mizzy/rigel/internal/ui/chat"
+)
+
+// REPL reads prompts and commands line by line and prints the responses
+type REPL struct {
+	core *chat.Core
+	in   *bufio.Reader
+	out  io.Writer
+}
+
+// NewREPL creates a line-based chat reading from in and writing to out
+func NewREPL(provider llm.Provider, cfg *config.Config, in io.Reader, out io.Writer) *REPL {
+	return &REPL{
+		core: chat.NewCore(provider, cfg, command.ModePlain),
+		in:   bufio.NewReader(in),
+		out:  out,
+	}
+}
+
+// Core returns the chat engine behind the REPL
+func (r *REPL) Core() *chat.Core {
+	return r.core
+}
+
+// Run reads and answers lines until /quit or the end of the input
+func (r *REPL) Run() error {
+	defer r.core.Close()
+
+	r.showWelcome()
+	for {
+		fmt.Fprint(r.out, "> ")
+		input, err := r.in.ReadString('\n')
+		if err != nil && (!errors.Is(err, io.EOF) || input == "") {
+			fmt.Fprintln(r.out)
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		input = strings.TrimRight(input, "\r\n")
+		if strings.TrimSpace(input) == "" {
+			continue
+		}
+
+		quit, err := r.handleResult(r.core.Submit(input))
+		if err != nil {
+			r.core.Fail(err)
+			r.showError(err)
+		}
+		if quit {
+			return nil
+		}
+	}
+}
+
+// showWelcome prints the repository, the model and how to get help
+func (r *REPL) showWelcome() {
+	fmt.Fprintln(r.out, "Rigel - AI Coding Agent")
+	if gitInfo := r.core.GitInfo; gitInfo != nil {
+		fmt.Fprintf(r.out, "  %s (%s)\n", gitInfo.RepoName, gitInfo.Branch)
+	}
+	welcome := r.core.Welcome()
+	if welcome.Provider != "" {
+		fmt.Fprintf(r.out, "  Model: %s / %s\n", welcome.Provider, welcome.Model)
+	}
+	fmt.Fprintln(r.out, "  Plain lines, without line editing. Type /help for commands, /quit to exit.")
+	fmt.Fprintln(r.out)
+	if warning := r.core.AgentsWarning(); warning != "" {
+		r.print(warning)
+	}
+}
+
+// handleResult prints the result of an input. It reports whether the
+// session should end.
+func (r *REPL) handleResult(result command.Result) (bool, error) {
+	switch result := result.(type) {
+	case command.Failure:
+		return false, result.Err
+
+	case command.Async:
+		return r.handleResult(r.runAsync(result))
+
+	case command.Response:
+		if result.Content != "" {
+			r.respond(result.Content)
+			return false, nil
+		}
+
+	case command.ThemeChanged:
+		r.respond(result.Content)
+		return false, nil
+
+	case command.Quit:
+		return true, nil
+
+	case command.ClearChat:
+		r.print("Chat history cleared")
+
+	case command.ClearInputHistory:
+		r.core.ClearInputHistory()
+		r.print("Command history cleared")
+
+	case command.ShowStatus:
+		r.respond(chat.FormatStatus(result.Info, r.core.Mode))
+		return false, nil
+
+	case command.Restore:
+		r.core.Restore(result.Session)
+		r.print(result.Notice)
+
+	case command.Request:
+		return false, r.handleChatMessage(result.Prompt)
+
+	case command.Retry:
+		r.core.Supersede(result.Prompt)
+		r.core.ChatState.SetCurrentPrompt(result.Prompt)
+		if result.Notice != "" {
+			r.print(result.Notice)
+		}
+		return false, r.handleChatMessage(result.Prompt)
+
+	case command.ShowModelSelector:
+		if result.Selector.Error != nil {
+			return false, result.Selector.Error
+		}
+		r.listModels(result.Selector)
+
+	case command.ShowProviderSelector:
+		r.print("Switching providers needs a terminal; run rigel with --provider instead")
+
+	case command.ShowHistory:
+		r.listHistory(result.Picker)
+
+	case command.ShowSearch:
+		r.listSearch(result.Results)
+
+	case command.ShowComparison:
+		r.respond(chat.FormatComparison(result.Responses))
+		return false, nil
+
+	case command.ShowReview:
+		r.respond(chat.FormatReview(result.Review))
+		return false, nil
+
+	case command.EditLast:
+		// There is no input to edit; the prompt is printed to copy instead
+		r.core.Supersede(result.Prompt)
+		r.print("Removed the last exchange; its prompt was:\n" + result.Prompt)
+
+	case command.RestoreDraft:
+		// There is no input to put it in; the draft is printed to copy instead
+		r.print("The draft was:\n" + result.Draft)
+	}
+
+	r.core.ChatState.SetThinking(false)
+	r.core.ChatState.ClearCurrentPrompt()
+	return false, nil
+}
+
+// runAsync runs an async command, cancelling it on Ctrl+C
+func (r *REPL) runAsync(result command.Async) command.Result {
+	// Progress is dropped, but must be read for the command to go on
+	if result.Progress != nil {
+		go func() {
+			for range result.Progress {
+			}
+		}()
+	}
+	if result.Cancel != nil {
+		stop := onInterrupt(result.Cancel)
+		defer stop()
+	}
+	return result.Fn()
+}
+
+// handleChatMessage sends a prompt to the agent, printing the response as
+// it streams
+func (r *REPL) handleChatMessage(input string) error {
+	ctx, cancel := r.core.RequestContext()
+	defer cancel()
+	stop := onInterrupt(cancel)
+	defer stop()
+
+	var streamed strings.Builder
+	r.core.Agent.SetStreamHandler(func(chunk string) {
+		streamed.WriteString(chunk)
+		fmt.Fprint(r.out, chunk)
+	})
+	defer r.core.Agent.SetStreamHandler(nil)
+
+	response, err := r.core.Agent.Execute(ctx, input)
+	started := streamed.Len() > 0
+	if started {
+		fmt.Fprint(r.out, "\n\n")
+	}
+
+	if notice := r.core.FailoverNotice(); notice != "" {
+		r.print(notice)
+	}
+	if warning := r.core.ContextWarning(); warning != "" {
+		r.print(warning)
+	}
+	if err != nil && ctx.Err() != nil {
+		partial, cancelled := r.core.Cancel(err)
+		switch {
+		case cancelled:
+			if !started {
+				r.print(partial)
+			}
+			r.print(chat.InterruptedHint)
+		case !errors.Is(ctx.Err(), context.DeadlineExceeded):
+			r.print("Request cancelled")
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			r.print(r.core.TimeoutNotice())
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to generate response: %w", err)
+	}
+
+	if !started {
+		r.print(response)
+	}
+	r.core.CompleteExchange(response)
+	if footer := r.core.MetaFooter(); footer != "" {
+		r.print(footer)
+	}
+	if offer := r.core.SaveCodeOffer(response); offer != nil {
+		_, err := r.handleResult(offer)
+		return err
+	}
+	return nil
+}
+
+// listModels prints the models to choose from with /model <name>
+func (r *REPL) listModels(selector *command.ModelSelectorMsg) {
+	var sb strings.Builder
+	sb.WriteString("Models:\n")
+	for _, model := range selector.Models {
+		marker := " "
+		if model.Name == selector.CurrentModel {
+			marker = "*"
+		}
+		sb.WriteString(fmt.Sprintf("%s %s\n", marker, model.Name))
+	}
+	sb.WriteString("\nUse /model <name> to switch.")
+	r.print(sb.String())
+}
+
+// listHistory prints the past prompts, the newest last so that it is
+// nearest the prompt
+func (r *REPL) listHistory(picker *command.HistoryPickerMsg) {
+	if len(picker.Entries) == 0 {
+		r.print("No prompts in the history yet")
+		return
+	}
+	var sb strings.Builder
+	for i := len(picker.Entries) - 1; i >= 0; i-- {
+		sb.WriteString(fmt.Sprintf("%4d  %s\n", i+1, picker.Entries[i].Command))
+	}
+	r.print(strings.TrimSuffix(sb.String(), "\n"))
+}
+
+// listSearch prints the exchanges /search found, the best match first, with
+// the conversation each is in
+func (r *REPL) listSearch(results *command.SearchResultsMsg) {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Exchanges matching %q:\n", results.Query))
+	for i, hit := range results.Hits {
+		where := fmt.Sprintf("%q (%s)", hit.Session.DisplayTitle(), hit.Session.ID)
+		if hit.Session.ID == results.Current {
+			where = "this conversation"
+		}
+		sb.WriteString(fmt.Sprintf("%4d. exchange %d of %s: %s\n", i+1, hit.Exchange+1, where, hit.Snippet))
+	}
+	sb.WriteString("\nUse /resume <id> to continue a saved conversation.")
+	r.print(sb.String())
+}
+
+// showError prints an error, with a suggested action for provider errors
+// that have one
+func (r *REPL) showError(err error) {
+	desc := chat.DescribeError(err)
+	if desc.Hint == "" {
+		fmt.Fprintf(r.out, "Error: %v\n\n", err)
+		return
+	}
+	fmt.Fprintf(r.out, "✗ %s\n  %s\n  → %s\n\n", desc.Title, desc.Detail, desc.Hint)
+}
+
+// respond prints a response and records it as an exchange
+func (r *REPL) respond(content string) {
+	r.print(content)
+	r.core.CompleteExchange(content)
+}
+
+// print prints text followed by a blank line
+func (r *REPL) print(text string) {
+	fmt.Fprintf(r.out, "%s\n\n", strings.TrimRight(text, "\n"))
+}
+
+// onInterrupt calls cancel on Ctrl+C until the returned function is called
+func onInterrupt(cancel func()) func() {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-interrupt:
+			cancel()
+		case <-done:
+		}
+	}()
+	return func() {
+		signal.Stop(interrupt)
+		close(done)
+	}
+}
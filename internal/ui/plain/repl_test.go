@@ -0,0 +1,37 @@
+package plain
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mizzy/rigel/internal/llm/llmtest"
+)
+
+func TestREPL(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Chdir(t.TempDir())
+
+	var out bytes.Buffer
+	repl := NewREPL(&llmtest.Provider{}, nil, strings.NewReader("/help\n\n/quit\n/help\n"), &out)
+	require.NoError(t, repl.Run())
+
+	output := out.String()
+	assert.Contains(t, output, "Model: fake / fake-model")
+	assert.Contains(t, output, "/quit")
+	assert.NotContains(t, output, "\x1b[", "no escape sequences")
+	assert.Equal(t, 1, strings.Count(output, "Available commands"), "lines after /quit are not read")
+}
+
+func TestREPLEndOfInput(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Chdir(t.TempDir())
+
+	var out bytes.Buffer
+	repl := NewREPL(&llmtest.Provider{}, nil, strings.NewReader("/clear"), &out)
+	require.NoError(t, repl.Run())
+	assert.Contains(t, out.String(), "Chat history cleared", "a last line without a newline is read")
+}
@@ -4,7 +4,7 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/charmbracelet/lipgloss"
+	"github.com/mizzy/rigel/internal/ui/styles"
 	"golang.org/x/term"
 )
 
@@ -12,17 +12,13 @@ import (
 type Exchange struct {
 	Prompt   string
 	Response string
+	Footer   string // Dim line below the response, such as the model and latency
 }
 
-var (
-	promptSymbol    = lipgloss.NewStyle().Foreground(lipgloss.Color("87")).Bold(true).Render("✦")
-	promptStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("87")).Bold(true)
-	inputStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("195"))
-	outputStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("252"))
-	thinkingStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("117")).Italic(true)
-	suggestionStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("243"))
-	highlightStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("87")).Bold(true)
-)
+// promptSymbol renders the prompt symbol in the current theme
+func promptSymbol() string {
+	return styles.PromptStyle.Render("✦")
+}
 
 // GetTerminalWidth returns the terminal width or a default value
 func GetTerminalWidth() int {
@@ -51,18 +47,22 @@ func ChatHistory(history []Exchange) string {
 
 	for _, ex := range history {
 		// User prompt with > symbol
-		s.WriteString(promptSymbol)
+		s.WriteString(promptSymbol())
 		s.WriteString(" ")
 
 		// Use lipgloss Width() for proper wrapping
-		promptStyle := inputStyle.Width(promptWidth)
+		promptStyle := styles.InputStyle.Width(promptWidth)
 		s.WriteString(promptStyle.Render(ex.Prompt))
 		s.WriteString("\n\n")
 
 		// Assistant response with wrapping
-		responseStyle := outputStyle.Width(responseWidth)
-		s.WriteString(responseStyle.Render(ex.Response))
+		responseStyle := styles.OutputStyle.Width(responseWidth)
+		s.WriteString(responseStyle.Render(highlightDiffs(ex.Response)))
 		s.WriteString("\n\n")
+		if ex.Footer != "" {
+			s.WriteString(styles.PlaceholderStyle.Render(ex.Footer))
+			s.WriteString("\n\n")
+		}
 	}
 
 	return s.String()
@@ -70,6 +70,12 @@ func ChatHistory(history []Exchange) string {
 
 // ThinkingState renders the thinking indicator
 func ThinkingState(currentPrompt string, spinner string) string {
+	return ThinkingStatus(currentPrompt, spinner, "Thinking...")
+}
+
+// ThinkingStatus renders the thinking indicator with a custom status, such
+// as the progress of a long-running command
+func ThinkingStatus(currentPrompt string, spinner string, status string) string {
 	if currentPrompt == "" {
 		return ""
 	}
@@ -78,25 +84,64 @@ func ThinkingState(currentPrompt string, spinner string) string {
 	termWidth := GetTerminalWidth()
 	promptWidth := termWidth - 3
 
-	s.WriteString(promptSymbol)
+	s.WriteString(promptSymbol())
 	s.WriteString(" ")
 
-	promptLineStyle := inputStyle.Width(promptWidth)
+	promptLineStyle := styles.InputStyle.Width(promptWidth)
 	s.WriteString(promptLineStyle.Render(currentPrompt))
 	s.WriteString("\n\n")
-	s.WriteString(promptStyle.Render(spinner))
-	s.WriteString(thinkingStyle.Render(" Thinking..."))
+	s.WriteString(styles.PromptStyle.Render(spinner))
+	s.WriteString(styles.ThinkingStyle.Render(" " + status))
 	s.WriteString("\n")
 
 	return s.String()
 }
 
+// maxToolProgressLines limits the tool progress lines shown while thinking
+const maxToolProgressLines = 8
+
+// ToolProgress renders the latest tool start and finish lines beneath the
+// thinking indicator
+func ToolProgress(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	if len(lines) > maxToolProgressLines {
+		lines = lines[len(lines)-maxToolProgressLines:]
+	}
+
+	var s strings.Builder
+	for _, line := range lines {
+		s.WriteString("  ")
+		s.WriteString(styles.InfoStyle.Render(line))
+		s.WriteString("\n")
+	}
+	return s.String()
+}
+
+// StreamedResponse renders the part of a response shown while it streams
+func StreamedResponse(response string) string {
+	if response == "" {
+		return ""
+	}
+	responseStyle := styles.OutputStyle.Width(GetTerminalWidth() - 2)
+	return "\n" + responseStyle.Render(highlightDiffs(response)) + "\n"
+}
+
+// QueuedPrompts renders the indicator of prompts queued while thinking
+func QueuedPrompts(indicator string) string {
+	if indicator == "" {
+		return ""
+	}
+	return "  " + styles.InfoStyle.Render(indicator) + "\n"
+}
+
 // ThinkingStateWithInput renders the thinking indicator with preserved input
 func ThinkingStateWithInput(inputView string, spinner string) string {
 	var s strings.Builder
 
 	// Show input with prompt symbol
-	s.WriteString(promptSymbol)
+	s.WriteString(promptSymbol())
 	s.WriteString(" ")
 
 	// Handle multi-line alignment by replacing newlines with proper indentation
@@ -111,10 +156,10 @@ func ThinkingStateWithInput(inputView string, spinner string) string {
 	// Add thinking indicator
 	s.WriteString("\n\n")
 	if spinner != "" {
-		s.WriteString(promptStyle.Render(spinner))
+		s.WriteString(styles.PromptStyle.Render(spinner))
 		s.WriteString(" ")
 	}
-	s.WriteString(thinkingStyle.Render("Thinking..."))
+	s.WriteString(styles.ThinkingStyle.Render("Thinking..."))
 	s.WriteString("\n")
 
 	return s.String()
@@ -134,20 +179,20 @@ func CommandSuggestions(suggestions []string, selectedIndex int, commands []Comm
 
 	var s strings.Builder
 	s.WriteString("\n\n")
-	s.WriteString(suggestionStyle.Render("Commands:"))
+	s.WriteString(styles.SuggestionStyle.Render("Commands:"))
 	s.WriteString("\n")
 
 	for i, suggestion := range suggestions {
 		if i == selectedIndex {
-			s.WriteString(highlightStyle.Render(fmt.Sprintf("  → %s", suggestion)))
+			s.WriteString(styles.HighlightStyle.Render(fmt.Sprintf("  → %s", suggestion)))
 		} else {
-			s.WriteString(suggestionStyle.Render(fmt.Sprintf("    %s", suggestion)))
+			s.WriteString(styles.SuggestionStyle.Render(fmt.Sprintf("    %s", suggestion)))
 		}
 
 		// Add description
 		for _, cmd := range commands {
 			if cmd.Command == suggestion {
-				s.WriteString(suggestionStyle.Render(fmt.Sprintf(" - %s", cmd.Description)))
+				s.WriteString(styles.SuggestionStyle.Render(fmt.Sprintf(" - %s", cmd.Description)))
 				break
 			}
 		}
@@ -155,7 +200,7 @@ func CommandSuggestions(suggestions []string, selectedIndex int, commands []Comm
 	}
 
 	s.WriteString("\n")
-	s.WriteString(suggestionStyle.Render("Press Tab or Enter to complete, ↑/↓ to navigate"))
+	s.WriteString(styles.SuggestionStyle.Render("Press Tab or Enter to complete, ↑/↓ to navigate"))
 
 	return s.String()
 }
@@ -165,12 +210,27 @@ func ErrorMessage(err error) string {
 	if err == nil {
 		return ""
 	}
-	return "\n\n" + lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Render(fmt.Sprintf("Error: %v", err))
+	return "\n\n" + styles.ErrorStyle.Render(fmt.Sprintf("Error: %v", err))
+}
+
+// maxErrorDetail limits how much of an underlying error is shown below an
+// actionable error
+const maxErrorDetail = 300
+
+// ActionableError renders an error summary with the underlying error and a
+// suggested action
+func ActionableError(title, detail, hint string) string {
+	if len(detail) > maxErrorDetail {
+		detail = detail[:maxErrorDetail] + "..."
+	}
+	return "\n\n" + styles.ErrorStyle.Bold(true).Render("✗ "+title) +
+		"\n" + styles.InfoStyle.Render("  "+detail) +
+		"\n" + styles.StatusWarningStyle.Render("  → "+hint)
 }
 
 // ThinkingText renders just the thinking text without prompt
 func ThinkingText() string {
-	return thinkingStyle.Render(" Thinking...")
+	return styles.ThinkingStyle.Render(" Thinking...")
 }
 
 // InfoMessage renders info messages
@@ -178,7 +238,7 @@ func InfoMessage(message string) string {
 	if message == "" {
 		return ""
 	}
-	return "\n\n" + lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Render(message)
+	return "\n\n" + styles.InfoStyle.Render(message)
 }
 
 // RepoInfo renders repository information (repo name and branch)
@@ -189,23 +249,17 @@ func RepoInfo(repoName, branch string) string {
 
 	var result strings.Builder
 
-	// Repository name in bright blue (Rigel theme color)
+	// Repository name in the theme repo color
 	if repoName != "" {
-		repoStyle := lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#5793ff")).
-			Bold(true)
-		result.WriteString(repoStyle.Render(repoName))
+		result.WriteString(styles.RepoStyle.Render(repoName))
 	}
 
-	// Branch name in dim blue with brackets
+	// Branch name in the theme branch color with brackets
 	if branch != "" {
 		if repoName != "" {
 			result.WriteString(" ")
 		}
-		branchStyle := lipgloss.NewStyle().
-			Foreground(lipgloss.Color("117")).
-			Italic(true)
-		result.WriteString(branchStyle.Render(fmt.Sprintf("(%s)", branch)))
+		result.WriteString(styles.BranchStyle.Render(fmt.Sprintf("(%s)", branch)))
 	}
 
 	return result.String() + "\n"
@@ -0,0 +1,55 @@
+package render
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Column is one titled column of side-by-side output
+type Column struct {
+	Title    string
+	Subtitle string
+	Body     string
+}
+
+// Columns renders columns side by side within width. The output is plain
+// text so it can be stored in the chat history.
+func Columns(columns []Column, width int) string {
+	if len(columns) == 0 {
+		return ""
+	}
+
+	const gap = 2
+	columnWidth := (width - gap*(len(columns)-1)) / len(columns)
+	if columnWidth < 20 {
+		// Too narrow to sit side by side; stack the columns instead
+		columnWidth = width
+	}
+
+	style := lipgloss.NewStyle().Width(columnWidth)
+	rendered := make([]string, 0, len(columns)*2)
+	for i, column := range columns {
+		var s strings.Builder
+		s.WriteString(column.Title + "\n")
+		if column.Subtitle != "" {
+			s.WriteString(column.Subtitle + "\n")
+		}
+		s.WriteString(strings.Repeat("─", columnWidth) + "\n")
+		s.WriteString(column.Body)
+
+		if i > 0 {
+			if columnWidth < width {
+				rendered = append(rendered, strings.Repeat(" ", gap))
+			} else {
+				rendered = append(rendered, "")
+			}
+		}
+		rendered = append(rendered, style.Render(s.String()))
+	}
+
+	if columnWidth == width {
+		return lipgloss.JoinVertical(lipgloss.Left, rendered...)
+	}
+	return lipgloss.JoinHorizontal(lipgloss.Top, rendered...)
+}
@@ -0,0 +1,36 @@
+package render
+
+import (
+	"strings"
+
+	"github.com/mizzy/rigel/internal/ui/styles"
+)
+
+// highlightDiffs colors the lines of ```diff code blocks in a response:
+// added lines green, removed lines red, and hunk headers muted
+func highlightDiffs(response string) string {
+	if !strings.Contains(response, "```diff") {
+		return response
+	}
+
+	lines := strings.Split(response, "\n")
+	inDiff, inHeader := false, false
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "```"):
+			inDiff = !inDiff && strings.TrimSpace(strings.TrimPrefix(line, "```")) == "diff"
+			inHeader = inDiff
+		case !inDiff:
+		case strings.HasPrefix(line, "@@"):
+			lines[i] = styles.InfoStyle.Render(line)
+			inHeader = false
+		case inHeader:
+			lines[i] = styles.HighlightStyle.Render(line)
+		case strings.HasPrefix(line, "+"):
+			lines[i] = styles.StatusSuccessStyle.Render(line)
+		case strings.HasPrefix(line, "-"):
+			lines[i] = styles.StatusDangerStyle.Render(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,72 @@
+package render
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mizzy/rigel/internal/state"
+	"github.com/mizzy/rigel/internal/ui/styles"
+)
+
+// FileExplorerListLine is the line of the file explorer the first listed
+// entry is on, below its title and a blank line
+const FileExplorerListLine = 2
+
+// FileExplorer renders the entries of the directory dir, showing the page
+// of pageSize entries (all if pageSize is 0) with the selected one and
+// checking the marked files
+func FileExplorer(dir string, entries []state.ExplorerEntry, selectedIndex int, marked func(name string) bool, markedCount, pageSize int) string {
+	var sb strings.Builder
+	title := "Add files to the conversation"
+	if dir != "." {
+		title += " · " + filepath.ToSlash(dir) + "/"
+	}
+	sb.WriteString(title + ":\n\n")
+	if len(entries) == 0 {
+		sb.WriteString("  No files\n")
+	}
+
+	first, last := 0, len(entries)
+	if pageSize > 0 && len(entries) > pageSize {
+		first = selectedIndex / pageSize * pageSize
+		last = min(first+pageSize, len(entries))
+	}
+	for i := first; i < last; i++ {
+		entry := entries[i]
+		style := lipgloss.NewStyle()
+		prefix := "  "
+		if i == selectedIndex {
+			style = styles.HighlightStyle
+			prefix = "> "
+		}
+		check := "    "
+		if !entry.Dir {
+			check = "[ ] "
+			if marked(entry.Name) {
+				check = "[x] "
+			}
+		}
+		name := entry.Name
+		if entry.Dir {
+			name += "/"
+		}
+		sb.WriteString(style.Render(prefix + check + name))
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString("\n")
+	if first > 0 || last < len(entries) {
+		pages := (len(entries) + pageSize - 1) / pageSize
+		sb.WriteString(fmt.Sprintf("Page %d/%d · %d entries\n", first/pageSize+1, pages, len(entries)))
+	}
+	switch {
+	case markedCount == 1:
+		sb.WriteString("1 file marked\n")
+	case markedCount > 1:
+		sb.WriteString(fmt.Sprintf("%d files marked\n", markedCount))
+	}
+	sb.WriteString("↑/↓: navigate • Enter: open or add • Space: mark • ←: parent • Esc: cancel")
+	return sb.String()
+}
@@ -2,13 +2,25 @@ package render
 
 import (
 	"strings"
+
+	"github.com/mizzy/rigel/internal/ui/styles"
 )
 
 // InputPrompt renders the input prompt with proper alignment
 func InputPrompt(inputView string) string {
+	return inputPrompt(promptSymbol(), inputView)
+}
+
+// NormalModeInputPrompt renders the input prompt in vi normal mode, marked
+// with ❮ instead of ✦
+func NormalModeInputPrompt(inputView string) string {
+	return inputPrompt(styles.PromptStyle.Render("❮"), inputView)
+}
+
+func inputPrompt(symbol, inputView string) string {
 	var s strings.Builder
 
-	s.WriteString(promptSymbol)
+	s.WriteString(symbol)
 	s.WriteString(" ")
 
 	// Handle multi-line alignment by replacing newlines with proper indentation
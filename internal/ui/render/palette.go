@@ -0,0 +1,74 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mizzy/rigel/internal/state"
+	"github.com/mizzy/rigel/internal/ui/styles"
+)
+
+// paletteUsageWidth is the widest the column of commands and their
+// arguments gets
+const paletteUsageWidth = 36
+
+// CommandPaletteListLine returns the line of the command palette the first
+// listed command is on
+func CommandPaletteListLine(filter string) int {
+	// Title, blank line, the filter if any and a blank line
+	if filter != "" {
+		return 4
+	}
+	return 2
+}
+
+// CommandPalette renders the slash commands matching the filter, showing
+// the page of pageSize commands (all if pageSize is 0) with the selected
+// one, each with its arguments and description
+func CommandPalette(entries []state.PaletteEntry, selectedIndex int, filter string, pageSize int) string {
+	var sb strings.Builder
+	sb.WriteString("Commands:\n\n")
+	if filter != "" {
+		sb.WriteString(fmt.Sprintf("Filter: %s\n\n", filter))
+	}
+	if len(entries) == 0 {
+		sb.WriteString("  No matching commands\n")
+	}
+
+	// Descriptions line up after the arguments, but for those of commands
+	// with many flags
+	usageWidth := 0
+	for _, entry := range entries {
+		usageWidth = max(usageWidth, lipgloss.Width(entry.Usage))
+	}
+	usageWidth = min(usageWidth, paletteUsageWidth)
+	first, last := 0, len(entries)
+	if pageSize > 0 && len(entries) > pageSize {
+		first = selectedIndex / pageSize * pageSize
+		last = min(first+pageSize, len(entries))
+	}
+	for i := first; i < last; i++ {
+		entry := entries[i]
+		style := lipgloss.NewStyle()
+		prefix := "  "
+		if i == selectedIndex {
+			style = styles.HighlightStyle
+			prefix = "> "
+		}
+		sb.WriteString(style.Render(prefix))
+		sb.WriteString(highlightMatches(entry.Name, filter, style))
+		sb.WriteString(style.Render(padRight(strings.TrimPrefix(entry.Usage, entry.Name), usageWidth-lipgloss.Width(entry.Name))))
+		sb.WriteString("  ")
+		sb.WriteString(styles.SuggestionStyle.Render(entry.Description))
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString("\n")
+	if first > 0 || last < len(entries) {
+		pages := (len(entries) + pageSize - 1) / pageSize
+		sb.WriteString(fmt.Sprintf("Page %d/%d · %d commands\n", first/pageSize+1, pages, len(entries)))
+	}
+	sb.WriteString("↑/↓: navigate • Enter: run • Tab: edit • type to filter • Esc: cancel")
+	return sb.String()
+}
@@ -0,0 +1,53 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mizzy/rigel/internal/agent"
+	"github.com/mizzy/rigel/internal/ui/styles"
+)
+
+// PlanReview renders a plan of the agent waiting for the user's approval,
+// with the selected step highlighted
+func PlanReview(steps []agent.Step, selectedIndex int) string {
+	var sb strings.Builder
+	sb.WriteString("Here's my plan:\n\n")
+	if len(steps) == 0 {
+		sb.WriteString("  No steps left\n")
+	}
+	for i, step := range steps {
+		style := lipgloss.NewStyle()
+		prefix := "  "
+		if i == selectedIndex {
+			style = styles.HighlightStyle
+			prefix = "> "
+		}
+		sb.WriteString(style.Render(fmt.Sprintf("%s%d. %s", prefix, i+1, step.Task.Description)))
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\n↑/↓: select • K/J: move step up/down • Del: remove step • Enter: run • Esc: cancel")
+	return sb.String()
+}
+
+// PlanChecklist renders the steps of a running plan, ticking off those
+// done
+func PlanChecklist(steps []agent.Step) string {
+	var sb strings.Builder
+	for i, step := range steps {
+		style := styles.PlaceholderStyle
+		switch step.Status {
+		case agent.StepRunning:
+			style = styles.HighlightStyle
+		case agent.StepDone:
+			style = styles.StatusSuccessStyle
+		case agent.StepFailed:
+			style = styles.StatusDangerStyle
+		}
+		sb.WriteString("  ")
+		sb.WriteString(style.Render(fmt.Sprintf("%s %d. %s", step.Status.Mark(), i+1, step.Task.Description)))
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
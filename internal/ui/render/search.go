@@ -0,0 +1,94 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mizzy/rigel/internal/session"
+	"github.com/mizzy/rigel/internal/ui/styles"
+)
+
+// searchTitleWidth is how much of a session's title the search results show
+const searchTitleWidth = 28
+
+// SearchResultsListLine is the line of the search results the first hit is
+// on, below the title and a blank line
+const SearchResultsListLine = 2
+
+// SearchResults renders the exchanges /search found, best match first,
+// showing the page of pageSize hits (all if pageSize is 0) with the selected
+// one. Hits in the session with the ID current are in this conversation.
+func SearchResults(hits []session.Hit, selectedIndex int, query, current string, pageSize int) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Exchanges matching %q:\n\n", query))
+
+	first, last := 0, len(hits)
+	if pageSize > 0 && len(hits) > pageSize {
+		first = selectedIndex / pageSize * pageSize
+		last = min(first+pageSize, len(hits))
+	}
+	for i := first; i < last; i++ {
+		hit := hits[i]
+		style := lipgloss.NewStyle()
+		prefix := "  "
+		if i == selectedIndex {
+			style = styles.HighlightStyle
+			prefix = "> "
+		}
+		title := hit.Session.DisplayTitle()
+		if hit.Session.ID == current {
+			title = "This conversation"
+		}
+		if runes := []rune(title); len(runes) > searchTitleWidth {
+			title = string(runes[:searchTitleWidth-1]) + "…"
+		}
+		sb.WriteString(style.Render(prefix))
+		sb.WriteString(styles.PlaceholderStyle.Render(fmt.Sprintf("%-*s #%-3d", searchTitleWidth, title, hit.Exchange+1)))
+		sb.WriteString(" ")
+		sb.WriteString(style.Render(hit.Snippet))
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString("\n")
+	if first > 0 || last < len(hits) {
+		pages := (len(hits) + pageSize - 1) / pageSize
+		sb.WriteString(fmt.Sprintf("Page %d/%d · %d exchanges\n", first/pageSize+1, pages, len(hits)))
+	}
+	sb.WriteString("↑/↓: navigate • PgUp/PgDn: page • Enter: open • Esc: cancel")
+
+	return sb.String()
+}
+
+// SessionView renders one exchange of a session shown read-only, with the
+// session's title and how to leave it or, unless it is the current
+// conversation, continue it
+func SessionView(sess *session.Session, exchange int, current bool) string {
+	var sb strings.Builder
+	title := sess.DisplayTitle()
+	if current {
+		title = "This conversation"
+	}
+	sb.WriteString(styles.HighlightStyle.Render(title))
+	if !current {
+		sb.WriteString(styles.PlaceholderStyle.Render(" · " + sess.UpdatedAt.Local().Format("2006-01-02 15:04")))
+	}
+	sb.WriteString(styles.PlaceholderStyle.Render(fmt.Sprintf(" · exchange %d of %d (read-only)", exchange+1, len(sess.Exchanges))))
+	sb.WriteString("\n\n")
+
+	if exchange >= 0 && exchange < len(sess.Exchanges) {
+		ex := sess.Exchanges[exchange]
+		view := Exchange{Prompt: ex.Prompt, Response: ex.Response}
+		if ex.Meta != nil {
+			view.Footer = ex.Meta.String()
+		}
+		sb.WriteString(ChatHistory([]Exchange{view}))
+	}
+
+	help := "←/→: previous/next exchange • Esc: close"
+	if !current {
+		help += fmt.Sprintf(" • /resume %s continues it", sess.ID)
+	}
+	sb.WriteString(help)
+	return sb.String()
+}
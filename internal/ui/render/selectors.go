@@ -2,50 +2,196 @@ package render
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/mizzy/rigel/internal/fuzzy"
+	"github.com/mizzy/rigel/internal/history"
 	"github.com/mizzy/rigel/internal/llm"
+	"github.com/mizzy/rigel/internal/ui/styles"
 )
 
-// ModelSelector renders the model selection interface
-func ModelSelector(models []llm.Model, selectedIndex int, filter string) string {
-	if len(models) == 0 {
+// modelColumns are the columns of the model selector after the name, each
+// shown only if some model has a value for it
+var modelColumns = []struct {
+	title string
+	value func(llm.Model) string
+}{
+	{"FAMILY", func(m llm.Model) string { return m.Details.Family }},
+	{"PARAMS", func(m llm.Model) string { return m.Details.ParameterSize }},
+	{"QUANT", func(m llm.Model) string { return m.Details.QuantizationLevel }},
+	{"SIZE", func(m llm.Model) string { return formatSize(m.Size) }},
+}
+
+// ModelSelectorListLine returns the line of the model selector the first
+// listed model is on
+func ModelSelectorListLine(filter string) int {
+	// Title, blank line, the filter if any and a blank line, column titles
+	if filter != "" {
+		return 5
+	}
+	return 3
+}
+
+// ModelSelector renders the model selection interface as a table showing
+// the page of pageSize models (all if pageSize is 0) with the selected one
+func ModelSelector(models []llm.Model, selectedIndex int, filter, sortOrder string, pageSize int) string {
+	if len(models) == 0 && filter == "" {
 		return "No models available"
 	}
 
 	var sb strings.Builder
-	sb.WriteString("Select a model:\n\n")
+	if sortOrder != "" && sortOrder != "default" {
+		sb.WriteString(fmt.Sprintf("Select a model (sorted by %s):\n\n", sortOrder))
+	} else {
+		sb.WriteString("Select a model:\n\n")
+	}
 
 	// Show filter if active
 	if filter != "" {
 		sb.WriteString(fmt.Sprintf("Filter: %s\n\n", filter))
 	}
 
-	// Display models
-	for i, model := range models {
-		displayName := model.Name
-		if model.Details.Family != "" {
-			displayName = fmt.Sprintf("%s (%s)", model.Name, model.Details.Family)
+	// Size the columns to fit every model, so they don't shift between pages
+	nameWidth := len("NAME")
+	for _, model := range models {
+		nameWidth = max(nameWidth, lipgloss.Width(model.Name))
+	}
+	var titles []string
+	var widths []int
+	var columns []int
+	for i, column := range modelColumns {
+		width := 0
+		for _, model := range models {
+			width = max(width, lipgloss.Width(column.value(model)))
+		}
+		if width > 0 {
+			columns = append(columns, i)
+			widths = append(widths, max(width, len(column.title)))
+			titles = append(titles, column.title)
 		}
+	}
+
+	sb.WriteString("  " + padRight("NAME", nameWidth))
+	for i, title := range titles {
+		sb.WriteString("  " + padRight(title, widths[i]))
+	}
+	sb.WriteString("\n")
+
+	if len(models) == 0 {
+		sb.WriteString("  No matching models\n")
+	}
 
+	first, last := 0, len(models)
+	if pageSize > 0 && len(models) > pageSize {
+		first = selectedIndex / pageSize * pageSize
+		last = min(first+pageSize, len(models))
+	}
+	for i := first; i < last; i++ {
+		model := models[i]
+		style := lipgloss.NewStyle()
+		prefix := "  "
 		if i == selectedIndex {
-			style := lipgloss.NewStyle().Foreground(lipgloss.Color("87")).Bold(true)
-			sb.WriteString(style.Render(fmt.Sprintf("> %s", displayName)))
-		} else {
-			sb.WriteString(fmt.Sprintf("  %s", displayName))
+			style = styles.HighlightStyle
+			prefix = "> "
+		}
+
+		sb.WriteString(style.Render(prefix))
+		sb.WriteString(highlightMatches(model.Name, filter, style))
+		row := strings.Repeat(" ", nameWidth-lipgloss.Width(model.Name))
+		for j, column := range columns {
+			row += "  " + padRight(modelColumns[column].value(model), widths[j])
+		}
+		if capabilities := modelCapabilities(model.Details); capabilities != "" {
+			row += "  " + capabilities
 		}
+		sb.WriteString(style.Render(strings.TrimRight(row, " ")))
 		sb.WriteString("\n")
 	}
 
 	sb.WriteString("\n")
-	sb.WriteString("↑/↓: navigate • Enter: select • /: filter • Esc: cancel")
+	if first > 0 || last < len(models) {
+		pages := (len(models) + pageSize - 1) / pageSize
+		sb.WriteString(fmt.Sprintf("Page %d/%d · %d models\n", first/pageSize+1, pages, len(models)))
+	}
+	sb.WriteString("↑/↓: navigate • PgUp/PgDn: page • Tab: sort • Enter: select • type to filter • Esc: cancel")
+
+	return sb.String()
+}
+
+// padRight pads s with spaces to width cells
+func padRight(s string, width int) string {
+	return s + strings.Repeat(" ", max(width-lipgloss.Width(s), 0))
+}
+
+// formatSize renders a size in bytes, e.g. 4.7 GB, or nothing if unknown
+func formatSize(bytes int64) string {
+	switch {
+	case bytes <= 0:
+		return ""
+	case bytes >= 1e9:
+		return fmt.Sprintf("%.1f GB", float64(bytes)/1e9)
+	default:
+		return fmt.Sprintf("%d MB", bytes/1e6)
+	}
+}
+
+// highlightMatches renders name in style with the characters matching the
+// fuzzy filter underlined
+func highlightMatches(name, filter string, style lipgloss.Style) string {
+	_, positions, _ := fuzzy.Score(filter, name)
+	if len(positions) == 0 {
+		return style.Render(name)
+	}
+
+	matched := make(map[int]bool, len(positions))
+	for _, i := range positions {
+		matched[i] = true
+	}
+	matchStyle := style.Bold(true).Underline(true)
 
+	// Render runs of matched and unmatched characters
+	var sb strings.Builder
+	runes := []rune(name)
+	start := 0
+	for i := 1; i <= len(runes); i++ {
+		if i < len(runes) && matched[i] == matched[start] {
+			continue
+		}
+		run := string(runes[start:i])
+		if matched[start] {
+			sb.WriteString(matchStyle.Render(run))
+		} else {
+			sb.WriteString(style.Render(run))
+		}
+		start = i
+	}
 	return sb.String()
 }
 
+// modelCapabilities summarizes the known capabilities and price of a model,
+// e.g. "200k context · tools · vision · $3/$15 per Mtok"
+func modelCapabilities(d llm.ModelDetails) string {
+	var parts []string
+	if d.ContextWindow > 0 {
+		parts = append(parts, fmt.Sprintf("%dk context", d.ContextWindow/1000))
+	}
+	if d.SupportsTools {
+		parts = append(parts, "tools")
+	}
+	if d.SupportsVision {
+		parts = append(parts, "vision")
+	}
+	if d.InputCost > 0 || d.OutputCost > 0 {
+		parts = append(parts, fmt.Sprintf("$%s/$%s per Mtok",
+			strconv.FormatFloat(d.InputCost, 'f', -1, 64), strconv.FormatFloat(d.OutputCost, 'f', -1, 64)))
+	}
+	return strings.Join(parts, " · ")
+}
+
 // ProviderSelector renders the provider selection interface
-func ProviderSelector(providers []llm.Provider, selectedIndex int) string {
+func ProviderSelector(providers []llm.Provider, selectedIndex int, filter string) string {
 	if len(providers) == 0 {
 		return "No providers available"
 	}
@@ -53,20 +199,97 @@ func ProviderSelector(providers []llm.Provider, selectedIndex int) string {
 	var sb strings.Builder
 	sb.WriteString("Select a provider:\n\n")
 
+	// Show filter if active
+	if filter != "" {
+		sb.WriteString(fmt.Sprintf("Filter: %s\n\n", filter))
+	}
+
 	// Display providers
 	for i, provider := range providers {
-		providerName := provider.GetName()
 		if i == selectedIndex {
-			style := lipgloss.NewStyle().Foreground(lipgloss.Color("87")).Bold(true)
-			sb.WriteString(style.Render(fmt.Sprintf("> %s", providerName)))
+			sb.WriteString(styles.HighlightStyle.Render("> "))
+			sb.WriteString(highlightMatches(provider.GetName(), filter, styles.HighlightStyle))
 		} else {
-			sb.WriteString(fmt.Sprintf("  %s", providerName))
+			sb.WriteString("  ")
+			sb.WriteString(highlightMatches(provider.GetName(), filter, lipgloss.NewStyle()))
+		}
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString("\n")
+	sb.WriteString("↑/↓: navigate • Enter: select • type to filter • Esc: cancel")
+
+	return sb.String()
+}
+
+// historyPreviewWidth is how much of a past prompt the history picker shows
+const historyPreviewWidth = 80
+
+// HistoryPickerListLine returns the line of the history picker the first
+// listed prompt is on
+func HistoryPickerListLine(filter string) int {
+	// Title, blank line, the filter if any and a blank line
+	if filter != "" {
+		return 4
+	}
+	return 2
+}
+
+// HistoryPicker renders the list of past prompts, newest first, showing the
+// page of pageSize entries (all if pageSize is 0) with the selected one
+func HistoryPicker(entries []history.Entry, selectedIndex int, filter string, pageSize int) string {
+	if len(entries) == 0 && filter == "" {
+		return "No history yet"
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Past prompts:\n\n")
+	if filter != "" {
+		sb.WriteString(fmt.Sprintf("Filter: %s\n\n", filter))
+	}
+	if len(entries) == 0 {
+		sb.WriteString("  No matching prompts\n")
+	}
+
+	first, last := 0, len(entries)
+	if pageSize > 0 && len(entries) > pageSize {
+		first = selectedIndex / pageSize * pageSize
+		last = min(first+pageSize, len(entries))
+	}
+	for i := first; i < last; i++ {
+		entry := entries[i]
+		style := lipgloss.NewStyle()
+		prefix := "  "
+		if i == selectedIndex {
+			style = styles.HighlightStyle
+			prefix = "> "
 		}
+		sb.WriteString(style.Render(prefix))
+		sb.WriteString(styles.PlaceholderStyle.Render(entry.Timestamp.Local().Format("2006-01-02 15:04")))
+		sb.WriteString("  ")
+		sb.WriteString(highlightMatches(historyPreview(entry.Command), filter, style))
 		sb.WriteString("\n")
 	}
 
 	sb.WriteString("\n")
-	sb.WriteString("↑/↓: navigate • Enter: select • Esc: cancel")
+	if first > 0 || last < len(entries) {
+		pages := (len(entries) + pageSize - 1) / pageSize
+		sb.WriteString(fmt.Sprintf("Page %d/%d · %d prompts\n", first/pageSize+1, pages, len(entries)))
+	}
+	sb.WriteString("↑/↓: navigate • PgUp/PgDn: page • Enter: run • Tab: edit • Del: delete • type to filter • Esc: cancel")
 
 	return sb.String()
 }
+
+// historyPreview shortens a past prompt to its first line, cut to fit
+func historyPreview(command string) string {
+	line, rest, multiline := strings.Cut(command, "\n")
+	runes := []rune(line)
+	if len(runes) > historyPreviewWidth {
+		return string(runes[:historyPreviewWidth-1]) + "…"
+	}
+	if multiline && rest != "" {
+		return line + " …"
+	}
+	return line
+}
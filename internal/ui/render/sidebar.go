@@ -0,0 +1,62 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/mizzy/rigel/internal/ui/styles"
+)
+
+// SidebarSection is a section of the sidebar, with its count if any and
+// the details shown while it is selected
+type SidebarSection struct {
+	Title   string
+	Count   int // -1 for none
+	Details []string
+}
+
+// sidebarBorder separates the sidebar from the chat
+var sidebarBorder = lipgloss.NewStyle().Border(lipgloss.NormalBorder(), false, true, false, false)
+
+// Sidebar renders the sidebar width columns wide and height lines high,
+// border included, expanding the selected section. Its keys are listed at
+// the bottom while it has the focus.
+func Sidebar(sections []SidebarSection, selected int, focused bool, width, height int) string {
+	inner := width - 1
+	var lines []string
+	for i, section := range sections {
+		title := section.Title
+		if section.Count >= 0 {
+			title = fmt.Sprintf("%s (%d)", title, section.Count)
+		}
+		if i != selected {
+			lines = append(lines, "  "+title)
+			continue
+		}
+		if focused {
+			lines = append(lines, styles.HighlightStyle.Render("▸ "+title))
+		} else {
+			lines = append(lines, styles.PromptStyle.Render("▸ ")+title)
+		}
+		for _, detail := range section.Details {
+			lines = append(lines, styles.SuggestionStyle.Render("    "+ansi.Truncate(detail, inner-4, "…")))
+		}
+	}
+
+	help := "Shift+Tab focus · Ctrl+O hide"
+	if focused {
+		help = "↑↓ · Enter open · Esc back"
+	}
+	lines = lines[:min(len(lines), max(height-2, 0))]
+	for len(lines) < height-1 {
+		lines = append(lines, "")
+	}
+	lines = append(lines, styles.PlaceholderStyle.Render(ansi.Truncate(help, inner, "…")))
+
+	for i, line := range lines {
+		lines[i] = ansi.Truncate(line, inner, "")
+	}
+	return sidebarBorder.Width(inner).Height(height).MaxHeight(height).Render(strings.Join(lines, "\n"))
+}
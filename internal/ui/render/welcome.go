@@ -0,0 +1,65 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mizzy/rigel/internal/ui/styles"
+)
+
+// WelcomeSession is a saved session listed by the welcome panel
+type WelcomeSession struct {
+	Title    string
+	Updated  time.Time
+	Messages int
+}
+
+// WelcomePanel is what the welcome panel shows
+type WelcomePanel struct {
+	RepoName string
+	Branch   string
+	Provider string
+	Model    string
+	AgentsMD bool
+	Sessions []WelcomeSession
+}
+
+// Welcome renders the panel shown above the input until the first prompt:
+// the repository, the provider and model, whether AGENTS.md exists, the
+// latest sessions numbered for resuming, and the quick actions
+func Welcome(panel WelcomePanel) string {
+	var sb strings.Builder
+	sb.WriteString(styles.PromptStyle.Render("Rigel") + " - AI Coding Agent\n")
+	if repo := RepoInfo(panel.RepoName, panel.Branch); repo != "" {
+		sb.WriteString("  " + repo)
+	}
+	if panel.Provider != "" {
+		sb.WriteString(fmt.Sprintf("  %s %s\n", styles.StatusLabelStyle.Render("Model:"), styles.StatusValueStyle.Render(panel.Provider+" / "+panel.Model)))
+	}
+	if panel.AgentsMD {
+		sb.WriteString(fmt.Sprintf("  %s %s\n", styles.StatusLabelStyle.Render("AGENTS.md:"), styles.StatusSuccessStyle.Render("found")))
+	} else {
+		sb.WriteString(fmt.Sprintf("  %s %s\n", styles.StatusLabelStyle.Render("AGENTS.md:"), styles.StatusWarningStyle.Render("missing, /init writes one")))
+	}
+
+	if len(panel.Sessions) > 0 {
+		sb.WriteString("\n" + styles.StatusHeaderStyle.Render("Recent sessions") + "\n")
+		for i, sess := range panel.Sessions {
+			sb.WriteString(fmt.Sprintf("  %s %s  %s %s\n", styles.HighlightStyle.Render(fmt.Sprintf("%d", i+1)),
+				styles.SuggestionStyle.Render(sess.Updated.Local().Format("2006-01-02 15:04")), sess.Title,
+				styles.SuggestionStyle.Render(fmt.Sprintf("(%d messages)", sess.Messages))))
+		}
+	}
+
+	actions := "/init analyze the repository · /help commands · Ctrl+P palette"
+	switch len(panel.Sessions) {
+	case 0:
+	case 1:
+		actions = "1 resume · " + actions
+	default:
+		actions = fmt.Sprintf("1-%d resume · ", len(panel.Sessions)) + actions
+	}
+	sb.WriteString("\n" + styles.PlaceholderStyle.Render(actions) + "\n\n")
+	return sb.String()
+}
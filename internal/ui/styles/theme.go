@@ -0,0 +1,229 @@
+package styles
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/lipgloss"
+	"gopkg.in/yaml.v3"
+)
+
+// Theme is a named color palette for the terminal UI. Colors are lipgloss
+// color strings: ANSI 256 codes ("87") or hex values ("#5793ff").
+type Theme struct {
+	Name        string `yaml:"name"`
+	Accent      string `yaml:"accent"`      // Prompt symbol, spinner, selections
+	Input       string `yaml:"input"`       // User input text
+	Output      string `yaml:"output"`      // Assistant responses
+	Thinking    string `yaml:"thinking"`    // Thinking indicator
+	Muted       string `yaml:"muted"`       // Suggestions and hints
+	Info        string `yaml:"info"`        // Info messages
+	Error       string `yaml:"error"`       // Error messages
+	Repo        string `yaml:"repo"`        // Repository name
+	Branch      string `yaml:"branch"`      // Branch name
+	Placeholder string `yaml:"placeholder"` // Input placeholder
+	Label       string `yaml:"label"`       // Status labels
+	Value       string `yaml:"value"`       // Status values
+	Success     string `yaml:"success"`
+	Warning     string `yaml:"warning"`
+	Danger      string `yaml:"danger"`
+	Divider     string `yaml:"divider"`
+}
+
+// NewDarkTheme returns the default palette for dark terminals
+func NewDarkTheme() *Theme {
+	return &Theme{
+		Name:        "dark",
+		Accent:      "87",
+		Input:       "195",
+		Output:      "252",
+		Thinking:    "117",
+		Muted:       "243",
+		Info:        "240",
+		Error:       "196",
+		Repo:        "#5793ff",
+		Branch:      "117",
+		Placeholder: "60",
+		Label:       "250",
+		Value:       "195",
+		Success:     "82",
+		Warning:     "226",
+		Danger:      "203",
+		Divider:     "238",
+	}
+}
+
+// NewLightTheme returns a palette for light terminals
+func NewLightTheme() *Theme {
+	return &Theme{
+		Name:        "light",
+		Accent:      "25",
+		Input:       "18",
+		Output:      "235",
+		Thinking:    "31",
+		Muted:       "245",
+		Info:        "244",
+		Error:       "160",
+		Repo:        "#1f5fd1",
+		Branch:      "31",
+		Placeholder: "103",
+		Label:       "240",
+		Value:       "24",
+		Success:     "28",
+		Warning:     "136",
+		Danger:      "160",
+		Divider:     "250",
+	}
+}
+
+// builtinThemes maps names to constructors for the bundled themes
+var builtinThemes = map[string]func() *Theme{
+	"dark":  NewDarkTheme,
+	"light": NewLightTheme,
+}
+
+// LoadThemeFile reads a YAML palette. Colors missing from the file fall back
+// to the dark theme.
+func LoadThemeFile(path string) (*Theme, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read theme file: %w", err)
+	}
+
+	theme := NewDarkTheme()
+	theme.Name = ""
+	if err := yaml.Unmarshal(data, theme); err != nil {
+		return nil, fmt.Errorf("failed to parse theme file %s: %w", path, err)
+	}
+	if theme.Name == "" {
+		theme.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return theme, nil
+}
+
+// ThemesDir returns the directory holding user-defined themes
+func ThemesDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".rigel", "themes"), nil
+}
+
+// ResolveTheme finds a theme by built-in name, by name in the themes
+// directory, or by path to a YAML file
+func ResolveTheme(name string) (*Theme, error) {
+	if newTheme, ok := builtinThemes[name]; ok {
+		return newTheme(), nil
+	}
+
+	if strings.HasSuffix(name, ".yaml") || strings.HasSuffix(name, ".yml") || strings.ContainsRune(name, os.PathSeparator) {
+		return LoadThemeFile(name)
+	}
+
+	dir, err := ThemesDir()
+	if err != nil {
+		return nil, err
+	}
+	for _, ext := range []string{".yaml", ".yml"} {
+		path := filepath.Join(dir, name+ext)
+		if _, err := os.Stat(path); err == nil {
+			return LoadThemeFile(path)
+		}
+	}
+
+	return nil, fmt.Errorf("unknown theme: %s", name)
+}
+
+// ThemeNames lists built-in themes followed by user themes found in the themes directory
+func ThemeNames() []string {
+	names := []string{"dark", "light"}
+
+	dir, err := ThemesDir()
+	if err != nil {
+		return names
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return names
+	}
+
+	var custom []string
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), ext)
+		if _, builtin := builtinThemes[name]; !builtin {
+			custom = append(custom, name)
+		}
+	}
+	sort.Strings(custom)
+	return append(names, custom...)
+}
+
+// Styles derived from the current theme. They are rebuilt by Apply, so
+// render code should reference them at render time rather than copying them.
+var (
+	PromptStyle      lipgloss.Style
+	InputStyle       lipgloss.Style
+	OutputStyle      lipgloss.Style
+	ThinkingStyle    lipgloss.Style
+	SuggestionStyle  lipgloss.Style
+	HighlightStyle   lipgloss.Style
+	ErrorStyle       lipgloss.Style
+	InfoStyle        lipgloss.Style
+	RepoStyle        lipgloss.Style
+	BranchStyle      lipgloss.Style
+	PlaceholderStyle lipgloss.Style
+)
+
+var (
+	themeMu sync.RWMutex
+	current *Theme
+)
+
+func init() {
+	Apply(NewDarkTheme())
+}
+
+// Current returns the active theme
+func Current() *Theme {
+	themeMu.RLock()
+	defer themeMu.RUnlock()
+	return current
+}
+
+// Apply makes the theme active and rebuilds all styles from it
+func Apply(theme *Theme) {
+	themeMu.Lock()
+	defer themeMu.Unlock()
+
+	current = theme
+	color := func(c string) lipgloss.Color { return lipgloss.Color(c) }
+
+	PromptStyle = lipgloss.NewStyle().Foreground(color(theme.Accent)).Bold(true)
+	InputStyle = lipgloss.NewStyle().Foreground(color(theme.Input))
+	OutputStyle = lipgloss.NewStyle().Foreground(color(theme.Output))
+	ThinkingStyle = lipgloss.NewStyle().Foreground(color(theme.Thinking)).Italic(true)
+	SuggestionStyle = lipgloss.NewStyle().Foreground(color(theme.Muted))
+	HighlightStyle = lipgloss.NewStyle().Foreground(color(theme.Accent)).Bold(true)
+	ErrorStyle = lipgloss.NewStyle().Foreground(color(theme.Error))
+	InfoStyle = lipgloss.NewStyle().Foreground(color(theme.Info))
+	RepoStyle = lipgloss.NewStyle().Foreground(color(theme.Repo)).Bold(true)
+	BranchStyle = lipgloss.NewStyle().Foreground(color(theme.Branch)).Italic(true)
+	PlaceholderStyle = lipgloss.NewStyle().Foreground(color(theme.Placeholder))
+
+	StatusHeaderStyle = lipgloss.NewStyle().Foreground(color(theme.Accent)).Bold(true)
+	StatusLabelStyle = lipgloss.NewStyle().Foreground(color(theme.Label))
+	StatusValueStyle = lipgloss.NewStyle().Foreground(color(theme.Value))
+	StatusSuccessStyle = lipgloss.NewStyle().Foreground(color(theme.Success))
+	StatusWarningStyle = lipgloss.NewStyle().Foreground(color(theme.Warning))
+	StatusDangerStyle = lipgloss.NewStyle().Foreground(color(theme.Danger))
+	StatusDivider = lipgloss.NewStyle().Foreground(color(theme.Divider)).Render("─")
+}
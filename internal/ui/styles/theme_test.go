@@ -0,0 +1,54 @@
+package styles
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadThemeFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ocean.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("accent: \"33\"\nerror: \"#ff0000\"\n"), 0644))
+
+	theme, err := LoadThemeFile(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, "ocean", theme.Name)
+	assert.Equal(t, "33", theme.Accent)
+	assert.Equal(t, "#ff0000", theme.Error)
+	assert.Equal(t, NewDarkTheme().Output, theme.Output, "missing colors fall back to dark theme")
+}
+
+func TestResolveTheme(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	theme, err := ResolveTheme("light")
+	require.NoError(t, err)
+	assert.Equal(t, "light", theme.Name)
+
+	themesDir, err := ThemesDir()
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(themesDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(themesDir, "solar.yml"), []byte("name: Solarized\n"), 0644))
+
+	theme, err = ResolveTheme("solar")
+	require.NoError(t, err)
+	assert.Equal(t, "Solarized", theme.Name)
+	assert.Equal(t, []string{"dark", "light", "solar"}, ThemeNames())
+
+	_, err = ResolveTheme("missing")
+	assert.Error(t, err)
+}
+
+func TestApply(t *testing.T) {
+	defer Apply(NewDarkTheme())
+
+	Apply(NewLightTheme())
+	assert.Equal(t, "light", Current().Name)
+	assert.Equal(t, lipgloss.Color(NewLightTheme().Accent), PromptStyle.GetForeground())
+}
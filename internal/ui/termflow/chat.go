@@ -2,30 +2,36 @@ package termflow
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"time"
 
-	"github.com/mizzy/rigel/internal/agent"
 	"github.com/mizzy/rigel/internal/command"
 	"github.com/mizzy/rigel/internal/config"
-	"github.com/mizzy/rigel/internal/git"
-	"github.com/mizzy/rigel/internal/history"
 	"github.com/mizzy/rigel/internal/llm"
-	"github.com/mizzy/rigel/internal/state"
-	"github.com/mizzy/rigel/internal/tools"
+	"github.com/mizzy/rigel/internal/ui/chat"
 	"github.com/mizzy/rigel/lib/termflow"
 )
 
 // ChatSession represents a termflow-based chat session
 type ChatSession struct {
-	client         *termflow.InteractiveClient
-	historyManager *history.Manager
-	chatState      *state.ChatState
-	llmState       *state.LLMState
-	agent          *agent.Agent
-	config         *config.Config
-	gitInfo        *git.Info
-	ctrlCPressed   bool // Track Ctrl+C presses for 2-press exit
+	client *termflow.InteractiveClient
+	core   *chat.Core
+
+	// The spinner and typeahead of the running request, which reviewing the
+	// agent's plan pauses, and what Ctrl+C does meanwhile
+	spinner   *termflow.ThinkingSpinner
+	typeahead *termflow.Typeahead
+	interrupt func()
+	skip      func()
+
+	// What the welcome panel showed, whose sessions a digit resumes until
+	// the first exchange
+	welcome chat.Welcome
 }
 
 // NewChatSession creates a new termflow chat session
@@ -35,45 +41,26 @@ func NewChatSession(provider llm.Provider, cfg *config.Config) (*ChatSession, er
 		return nil, fmt.Errorf("failed to create termflow client: %w", err)
 	}
 
-	// Initialize history manager
-	histManager, err := history.NewManager()
-	if err != nil {
-		histManager = nil // Continue without history if it fails
-	} else {
-		_ = histManager.Load() // Load existing history
+	// The light theme implies a light terminal background
+	if cfg != nil && cfg.Theme == "light" {
+		client.Colors().SetLightBackground(true)
 	}
 
-	// Initialize LLM state
-	llmState := state.NewLLMState()
-	llmState.SetCurrentProvider(provider)
-
-	// Create intelligent agent with file tools
-	intelligentAgent := agent.New(provider)
-	fileTool := tools.NewFileTool()
-	intelligentAgent.RegisterTool(fileTool)
-
-	// Use UI progress display for termflow mode
-	uiProgress := agent.NewUIProgressDisplay()
-	intelligentAgent.SetProgressDisplay(uiProgress)
-
 	session := &ChatSession{
-		client:         client,
-		historyManager: histManager,
-		chatState:      state.NewChatState(),
-		llmState:       llmState,
-		agent:          intelligentAgent,
-		config:         cfg,
-		gitInfo:        git.GetRepoInfo(),
+		client: client,
+		core:   chat.NewCore(provider, cfg, command.ModeTermflow),
 	}
 
+	session.core.Agent.SetPlanReviewer(session)
+
 	// Set up command completion
 	session.setupCompletion()
 
 	// Load persistent history into the client
-	if histManager != nil {
-		history := histManager.GetCommands()
-		client.SetHistory(history)
-	}
+	client.SetHistory(session.core.InputHistory())
+
+	// Autosave the prompt being typed as a draft
+	client.OnInputChange(session.core.SaveDraft)
 
 	return session, nil
 }
@@ -83,7 +70,7 @@ func (cs *ChatSession) setupCompletion() {
 	provider := termflow.NewCompletionProvider()
 
 	// Add available commands
-	for _, cmd := range command.AvailableCommands {
+	for _, cmd := range cs.core.Commands() {
 		provider.AddCommand(cmd.Command, cmd.Description)
 	}
 
@@ -95,23 +82,20 @@ func (cs *ChatSession) setupCompletion() {
 // Run starts the chat session
 func (cs *ChatSession) Run() error {
 	defer cs.client.Close()
-	defer func() {
-		if cs.historyManager != nil {
-			cs.historyManager.Save()
-		}
-	}()
+	defer cs.core.Close()
 
 	// Show welcome message
 	cs.showWelcome()
 
+	// Focus reports tell whether to notify when a long response is ready
+	if cs.core.Config != nil && len(cs.core.Config.Notify) > 0 {
+		cs.client.ReportFocus(true)
+		defer cs.client.ReportFocus(false)
+	}
+
 	// Main chat loop
 	for {
-		var input string
-		var err error
-
-		// Use ReadLineOrMultiLine to support both single and multi-line input
-		input, err = cs.client.ReadLineOrMultiLine()
-
+		input, err := cs.nextInput()
 		if err != nil {
 			// Handle interruption - line editor handles two-press behavior internally
 			if err.Error() == "interrupted" {
@@ -122,182 +106,462 @@ func (cs *ChatSession) Run() error {
 			break
 		}
 
-		// Reset Ctrl+C flag when user provides input
-		cs.ctrlCPressed = false
-
 		// Handle empty input
 		if strings.TrimSpace(input) == "" {
 			continue
 		}
 
-		// Add to history
-		if cs.historyManager != nil {
-			cs.historyManager.Add(input)
+		// A digit alone resumes a session the welcome panel listed
+		if command := cs.welcomeResume(input); command != "" {
+			input = command
 		}
 
-		// Handle quit commands
-		if input == "/quit" || input == "/exit" {
+		// Process the input
+		quit, err := cs.processInput(input)
+		if err != nil {
+			cs.core.Fail(err)
+			cs.showError(err)
+		}
+		if notification := cs.core.Notification(cs.client.Focused()); notification != "" {
+			cs.client.Print(notification)
+		}
+		if quit {
 			cs.client.ShowInfo("Goodbye!")
 			break
 		}
-
-		// Process the input
-		if err := cs.processInput(input); err != nil {
-			cs.client.ShowError(err)
-		}
 	}
 
 	return nil
 }
 
-// showWelcome displays the welcome message
-func (cs *ChatSession) showWelcome() {
-	cs.client.Printf("\n\033[1;38;5;87m✦\033[0m \033[1mRigel - AI Coding Agent\033[0m\n")
-	if cs.gitInfo != nil {
-		cs.client.Printf("  \033[38;2;87;147;255m%s\033[0m \033[38;5;117m(%s)\033[0m\n", cs.gitInfo.RepoName, cs.gitInfo.Branch)
+// Core returns the chat engine behind the session
+func (cs *ChatSession) Core() *chat.Core {
+	return cs.core
+}
+
+// nextInput returns the next prompt typed ahead while thinking, echoing it
+// as if it had been entered, or reads a line. ReadLineOrMultiLine supports
+// both single and multi-line input.
+func (cs *ChatSession) nextInput() (string, error) {
+	if prompt, ok := cs.core.Dequeue(); ok {
+		cs.client.PrintInput(prompt)
+		return prompt, nil
+	}
+	// The editing mode may have been changed with /set editing-mode
+	cs.client.SetViMode(cs.core.Config != nil && cs.core.Config.EditingMode == config.EditingModeVi)
+	cs.client.SetStatusLine(cs.statusLine())
+	cs.client.SetPlaceholder(cs.core.NextHint())
+	input, err := cs.client.ReadLineOrMultiLine()
+	if err == nil {
+		// The line was entered, so it is no longer a draft
+		cs.core.SaveDraft("")
 	}
-	cs.client.Printf("  Using termflow UI - terminal scrollback is preserved!\n")
-	cs.client.Printf("  \033[90mInput:\033[0m Single line; use Ctrl+J for newline\n")
-	cs.client.Printf("  \033[90mCommands:\033[0m Type / for commands (Ctrl+C to exit)\n\n")
+	return input, err
 }
 
-// processInput processes user input (commands or chat messages)
-func (cs *ChatSession) processInput(input string) error {
-	cs.chatState.SetCurrentPrompt(input)
+// statusLine returns the status bar to show above the prompt, or an empty
+// string unless it is turned on with RIGEL_STATUS_BAR or /set status-bar
+func (cs *ChatSession) statusLine() string {
+	if cs.core.Config == nil || !cs.core.Config.StatusBar {
+		return ""
+	}
+	return cs.core.StatusBar().String()
+}
 
-	// Reset Ctrl+C flag when processing input
-	cs.ctrlCPressed = false
+// startTypeahead queues what the user types while the spinner runs and
+// shows it next to the spinner. Raw mode turns Ctrl+C into a key, so it
+// calls interrupt instead of raising a signal; End and Ctrl+End call skip,
+// which may be nil.
+func (cs *ChatSession) startTypeahead(spinner *termflow.ThinkingSpinner, interrupt, skip func()) *termflow.Typeahead {
+	return cs.client.StartTypeahead(func(pending string, queued []string) {
+		spinner.SetNote(typeaheadNote(pending, queued))
+		cs.core.SaveDraft(pending)
+	}, interrupt, skip)
+}
 
-	if strings.HasPrefix(input, "/") {
-		// Handle commands
-		return cs.handleCommand(input)
-	} else {
-		// Handle chat message
-		return cs.handleChatMessage(input)
+// stopTypeahead queues the prompts typed ahead and keeps an unfinished line
+// for the next prompt
+func (cs *ChatSession) stopTypeahead(typeahead *termflow.Typeahead) {
+	pending, queued := typeahead.Stop()
+	for _, prompt := range queued {
+		cs.core.Enqueue(prompt)
+	}
+	if pending != "" {
+		cs.client.SetInitialInput(pending)
+	}
+}
+
+// typeaheadNote describes the queued prompts and the line being typed
+func typeaheadNote(pending string, queued []string) string {
+	var parts []string
+	if indicator := chat.QueueIndicator(queued); indicator != "" {
+		parts = append(parts, indicator)
 	}
+	if strings.TrimSpace(pending) != "" {
+		parts = append(parts, "› "+chat.Preview(pending))
+	}
+	return strings.Join(parts, "  ")
 }
 
-// handleCommand processes slash commands
-func (cs *ChatSession) handleCommand(input string) error {
-	result := command.HandleCommand(
-		input,
-		cs.llmState,
-		cs.chatState,
-		cs.config,
-		cs.historyManager,
-		cs.getInputHistory(),
-	)
-
-	// Handle different result types
-	switch result.Type {
-	case "response":
-		if result.Error != nil {
-			return result.Error
+// showWelcome displays the welcome panel: the repository, the provider and
+// model, whether AGENTS.md exists, the latest sessions numbered for
+// resuming, and the quick actions
+func (cs *ChatSession) showWelcome() {
+	cs.welcome = cs.core.Welcome()
+	colors := cs.client.Colors()
+	cs.client.Printf("\n%s%s\n", cs.client.Prompt(), colors.Paint(termflow.RoleHeading, "Rigel - AI Coding Agent"))
+	if gitInfo := cs.core.GitInfo; gitInfo != nil {
+		cs.client.Printf("  %s %s\n", colors.Paint(termflow.RoleRepo, gitInfo.RepoName), colors.Sprintf(termflow.RoleBranch, "(%s)", gitInfo.Branch))
+	}
+	if cs.welcome.Provider != "" {
+		cs.client.Printf("  %s %s / %s\n", colors.Paint(termflow.RoleMuted, "Model:"), cs.welcome.Provider, cs.welcome.Model)
+	}
+	agentsMD := "found"
+	if !cs.welcome.AgentsMD {
+		agentsMD = "missing, /init writes one"
+	}
+	cs.client.Printf("  %s %s\n", colors.Paint(termflow.RoleMuted, "AGENTS.md:"), agentsMD)
+
+	if len(cs.welcome.Sessions) > 0 {
+		cs.client.Printf("\n  %s\n", colors.Paint(termflow.RoleHeading, "Recent sessions"))
+		for i, sess := range cs.welcome.Sessions {
+			cs.client.Printf("  %s %s  %s %s\n", colors.Sprintf(termflow.RoleAccent, "%d", i+1),
+				colors.Paint(termflow.RoleMuted, sess.UpdatedAt.Local().Format("2006-01-02 15:04")), sess.DisplayTitle(),
+				colors.Sprintf(termflow.RoleMuted, "(%d messages)", len(sess.Exchanges)))
 		}
-		cs.client.PrintResponse(result.Content)
-		cs.chatState.AddExchange(input, result.Content)
-
-	case "async":
-		if result.AsyncFn != nil {
-			// Show animated processing spinner
-			spinner := cs.client.ShowThinkingWithSpinner("Processing...")
-			asyncResult := result.AsyncFn()
-			spinner.Stop()
-			if asyncResult.Error != nil {
-				return asyncResult.Error
-			}
-			cs.client.PrintResponse(asyncResult.Content)
-			cs.chatState.AddExchange(input, asyncResult.Content)
+	}
+
+	actions := "/init analyze the repository · /help commands · Ctrl+J newline · Ctrl+C exit"
+	switch len(cs.welcome.Sessions) {
+	case 0:
+	case 1:
+		actions = "1 resume · " + actions
+	default:
+		actions = fmt.Sprintf("1-%d resume · ", len(cs.welcome.Sessions)) + actions
+	}
+	cs.client.Printf("\n  %s\n\n", colors.Paint(termflow.RoleMuted, actions))
+	if notice := cs.core.DraftNotice(); notice != "" {
+		cs.client.ShowInfo(notice)
+	}
+	if warning := cs.core.AgentsWarning(); warning != "" {
+		cs.client.ShowInfo(warning)
+	}
+}
+
+// welcomeResume returns the command resuming the session listed under the
+// digit typed alone, before the first exchange, or "" otherwise
+func (cs *ChatSession) welcomeResume(input string) string {
+	if cs.core.ChatState.GetMessageCount() > 0 {
+		return ""
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(input))
+	if err != nil {
+		return ""
+	}
+	return cs.welcome.ResumeCommand(n)
+}
+
+// processInput submits user input to the chat core and renders the result.
+// It reports whether the session should end.
+func (cs *ChatSession) processInput(input string) (bool, error) {
+	return cs.handleResult(cs.core.Submit(input))
+}
+
+// runAsync runs an async command, showing its progress in the spinner and
+// cancelling it on Ctrl+C
+func (cs *ChatSession) runAsync(result command.Async, spinner *termflow.ThinkingSpinner) command.Result {
+	cancel := func() {
+		if result.Cancel != nil {
+			spinner.SetMessage("Cancelling...")
+			result.Cancel()
 		}
+	}
+	// Plans the command makes, like /save-code's, are reviewed over the
+	// spinner as a prompt's are
+	cs.spinner, cs.interrupt = spinner, cancel
+	cs.typeahead = cs.startTypeahead(spinner, cancel, nil)
+	defer func() {
+		cs.stopTypeahead(cs.typeahead)
+		cs.spinner, cs.typeahead, cs.interrupt = nil, nil, nil
+	}()
 
-	case "clear":
-		// Clear chat state
-		cs.chatState.ClearHistory()
-		cs.client.ShowInfo("Chat history cleared")
+	if result.Progress != nil {
+		go func() {
+			for text := range result.Progress {
+				spinner.SetMessage(text)
+			}
+		}()
+	}
 
-	case "clear_input_history":
-		if cs.historyManager != nil {
-			if err := cs.historyManager.Clear(); err != nil {
-				return fmt.Errorf("failed to clear history: %w", err)
+	if result.Cancel != nil {
+		interrupt := make(chan os.Signal, 1)
+		signal.Notify(interrupt, os.Interrupt)
+		defer signal.Stop(interrupt)
+
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			select {
+			case <-interrupt:
+				cancel()
+			case <-done:
 			}
+		}()
+	}
+
+	return result.Fn()
+}
+
+// handleResult renders a command result
+func (cs *ChatSession) handleResult(result command.Result) (bool, error) {
+	switch result := result.(type) {
+	case command.Failure:
+		return false, result.Err
+
+	case command.Async:
+		// Show animated processing spinner
+		spinner := cs.client.ShowThinkingWithSpinner("Processing...")
+		asyncResult := cs.runAsync(result, spinner)
+		spinner.Stop()
+		return cs.handleResult(asyncResult)
+
+	case command.Response:
+		if result.Content != "" {
+			cs.respond(result.Content)
+			return false, nil
 		}
+
+	case command.ThemeChanged:
+		cs.respond(result.Content)
+		return false, nil
+
+	case command.Quit:
+		return true, nil
+
+	case command.ClearChat:
+		cs.core.ChatState.SetThinking(false)
+		cs.client.ShowInfo("Chat history cleared")
+		return false, nil
+
+	case command.ClearInputHistory:
+		cs.core.ClearInputHistory()
+		cs.client.SetHistory(nil)
+		cs.core.ChatState.SetThinking(false)
 		cs.client.ShowInfo("Command history cleared")
+		return false, nil
 
-	case "status":
-		if result.StatusInfo != nil {
-			content := cs.formatStatusInfo(result.StatusInfo)
-			cs.client.PrintResponse(content)
-			cs.chatState.AddExchange(input, content)
-		}
+	case command.ShowStatus:
+		cs.respond(chat.FormatStatus(result.Info, cs.core.Mode))
+		return false, nil
+
+	case command.Restore:
+		cs.core.Restore(result.Session)
+		cs.core.ChatState.SetThinking(false)
+		cs.core.ChatState.ClearCurrentPrompt()
+		cs.client.ShowInfo(result.Notice)
+		return false, nil
 
-	case "request":
+	case command.Request:
 		// Handle normal prompts using intelligent agent
-		return cs.handleChatMessage(result.Prompt)
+		return false, cs.handleChatMessage(result.Prompt)
 
-	default:
-		if result.Content != "" {
-			cs.client.PrintResponse(result.Content)
-			cs.chatState.AddExchange(input, result.Content)
+	case command.Retry:
+		cs.core.Supersede(result.Prompt)
+		cs.core.ChatState.SetCurrentPrompt(result.Prompt)
+		if result.Notice != "" {
+			cs.client.ShowInfo(result.Notice)
+		}
+		return false, cs.handleChatMessage(result.Prompt)
+
+	case command.ShowModelSelector:
+		if result.Selector.Error != nil {
+			return false, result.Selector.Error
 		}
+		cs.selectModel(result.Selector)
+		return false, nil
+
+	case command.ShowProviderSelector:
+		// Termflow has no provider selector
+
+	case command.ShowHistory:
+		return false, cs.selectHistory(result.Picker)
+
+	case command.ShowSearch:
+		return false, cs.selectSearch(result.Results)
+
+	case command.ShowComparison:
+		cs.respond(chat.FormatComparison(result.Responses))
+		return false, nil
+
+	case command.ShowReview:
+		cs.respond(chat.FormatReview(result.Review))
+		return false, nil
+
+	case command.EditLast:
+		cs.core.Supersede(result.Prompt)
+		cs.core.ChatState.SetThinking(false)
+		cs.core.ChatState.ClearCurrentPrompt()
+		cs.client.SetInitialInput(result.Prompt)
+		return false, nil
+
+	case command.RestoreDraft:
+		cs.core.ChatState.SetThinking(false)
+		cs.core.ChatState.ClearCurrentPrompt()
+		cs.client.SetInitialInput(result.Draft)
+		return false, nil
 	}
 
-	cs.chatState.ClearCurrentPrompt()
-	return nil
+	cs.core.ChatState.SetThinking(false)
+	cs.core.ChatState.ClearCurrentPrompt()
+	return false, nil
+}
+
+// showError prints an error, with a suggested action for provider errors
+// that have one
+func (cs *ChatSession) showError(err error) {
+	desc := chat.DescribeError(err)
+	if desc.Hint == "" {
+		cs.client.ShowError(err)
+		return
+	}
+	colors := cs.client.Colors()
+	cs.client.Printf("\n\n%s\n%s\n%s", colors.Paint(termflow.RoleError, "✗ "+desc.Title),
+		colors.Paint(termflow.RoleInfo, "  "+desc.Detail), colors.Paint(termflow.RoleAccent, "  → "+desc.Hint))
+}
+
+// respond prints a response and records it as an exchange
+func (cs *ChatSession) respond(content string) {
+	cs.client.PrintResponse(content)
+	cs.core.CompleteExchange(content)
 }
 
 // handleChatMessage processes regular chat messages
 func (cs *ChatSession) handleChatMessage(input string) error {
 	// Show animated thinking spinner
 	spinner := cs.client.ShowThinkingWithSpinner("Thinking...")
-	defer spinner.Stop()
+
+	// The response replaces the spinner as it streams, at the pace
+	// RIGEL_STREAM_RENDER sets; End or Ctrl+End shows the rest at once
+	tw := cs.core.NewTypewriter()
+	cs.core.Agent.SetStreamHandler(tw.Write)
+	defer cs.core.Agent.SetStreamHandler(nil)
+	stream := cs.printStream(tw, spinner)
+
+	// Input typed meanwhile is queued; Ctrl+C or Esc cancels the request,
+	// as does running past the generation timeout
+	ctx, cancel := cs.core.RequestContext()
+	defer cancel()
+	go cs.countDown(ctx, spinner)
+	cs.spinner = spinner
+	cs.interrupt = func() {
+		spinner.SetMessage("Cancelling...")
+		cancel()
+		tw.Skip()
+	}
+	cs.skip = tw.Skip
+	cs.typeahead = cs.startTypeahead(spinner, cs.interrupt, cs.skip)
 
 	// Use the intelligent agent to generate response
-	response, err := cs.agent.Execute(context.Background(), input)
+	response, err := cs.core.Agent.Execute(ctx, input)
+	if err == nil {
+		tw.Finish(response)
+		stream.Wait()
+	} else {
+		stream.Stop()
+	}
+	cs.stopTypeahead(cs.typeahead)
+	if !stream.Started() {
+		spinner.Stop()
+	}
+	cs.spinner, cs.typeahead, cs.interrupt, cs.skip = nil, nil, nil, nil
+
+	// What wasn't streamed yet is printed with the cancelled response
+	var partial string
+	cancelled := err != nil && ctx.Err() != nil
+	if cancelled {
+		var ok bool
+		if partial, ok = cs.core.Cancel(err); !ok {
+			cancelled = false
+		}
+	}
+	if stream.Started() {
+		if cancelled {
+			cs.client.Printf("%s", cs.client.Colors().Paint(termflow.RoleOutput, unprinted(partial, tw.Shown())))
+		}
+		cs.client.Printf("\n\n")
+	}
+
+	if notice := cs.core.FailoverNotice(); notice != "" {
+		cs.client.ShowInfo(notice)
+	}
+	if warning := cs.core.ContextWarning(); warning != "" {
+		cs.client.ShowInfo(warning)
+	}
+	if err != nil && ctx.Err() != nil {
+		timedOut := errors.Is(ctx.Err(), context.DeadlineExceeded)
+		switch {
+		case cancelled:
+			if !stream.Started() {
+				cs.client.PrintResponse(partial)
+			}
+			cs.client.ShowInfo(chat.InterruptedHint)
+		case !timedOut:
+			cs.client.ShowInfo("Request cancelled")
+		}
+		if timedOut {
+			cs.client.ShowInfo(cs.core.TimeoutNotice())
+		}
+		return nil
+	}
 	if err != nil {
 		return fmt.Errorf("failed to generate response: %w", err)
 	}
 
 	// Display only the AI response (user input is already visible)
-	cs.client.PrintResponse(response)
-
-	// Add to chat state
-	cs.chatState.AddExchange(input, response)
-	cs.chatState.ClearCurrentPrompt()
-
+	if stream.Started() {
+		cs.core.CompleteExchange(response)
+	} else {
+		cs.respond(response)
+	}
+	if footer := cs.core.MetaFooter(); footer != "" {
+		cs.client.Printf("%s\n\n", cs.client.Colors().Paint(termflow.RoleInfo, footer))
+	}
+	if offer := cs.core.SaveCodeOffer(response); offer != nil {
+		_, err := cs.handleResult(offer)
+		return err
+	}
 	return nil
 }
 
-// formatStatusInfo formats status information for display
-func (cs *ChatSession) formatStatusInfo(status *command.StatusInfo) string {
-	return fmt.Sprintf("✦ Rigel Session Status\n\n"+
-		"🤖 LLM Configuration\n"+
-		"  Provider: %s\n"+
-		"  Model: %s\n\n"+
-		"💬 Chat History\n"+
-		"  Messages: %d\n"+
-		"  User tokens: ~%d\n"+
-		"  Assistant tokens: ~%d\n"+
-		"  Total tokens: ~%d\n\n"+
-		"📝 Command History\n"+
-		"  Commands saved: %d\n"+
-		"  Persistence: %s\n\n"+
-		"🔧 Environment\n"+
-		"  UI Mode: termflow\n"+
-		"  Log level: %s\n"+
-		"  Repository context: %s\n",
-		status.Provider, status.Model,
-		status.MessageCount,
-		status.UserTokens, status.AssistantTokens, status.TotalTokens,
-		status.CommandsCount,
-		map[bool]string{true: "✓ Enabled", false: "✗ Disabled"}[status.PersistenceEnabled],
-		status.LogLevel,
-		map[bool]string{true: "✓ AGENTS.md loaded", false: "✗ Not initialized (run /init)"}[status.RepositoryInitialized])
+// countDown shows next to the spinner how long the request waits for the
+// provider's rate limits, and how long it has left once its deadline is
+// near, until the request ends
+func (cs *ChatSession) countDown(ctx context.Context, spinner *termflow.ThinkingSpinner) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	waited := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if notice := cs.core.RateLimitNotice(); notice != "" {
+				spinner.SetMessage(notice)
+				waited = true
+			} else if notice := cs.core.DeadlineNotice(ctx); notice != "" {
+				spinner.SetMessage(notice)
+			} else if waited {
+				spinner.SetMessage("Thinking...")
+				waited = false
+			}
+		}
+	}
 }
 
-// getInputHistory returns the current input history for commands
-func (cs *ChatSession) getInputHistory() []string {
-	if cs.historyManager != nil {
-		return cs.historyManager.GetCommands()
+// unprinted returns the end of response after the part already printed
+func unprinted(response, printed string) string {
+	if rest, ok := strings.CutPrefix(response, strings.TrimRight(printed, " \n")); ok {
+		return rest
 	}
-	return []string{}
+	return "\n\n" + response
 }
@@ -0,0 +1,84 @@
+package termflow
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mizzy/rigel/internal/agent"
+	"github.com/mizzy/rigel/internal/ui/chat"
+	"github.com/mizzy/rigel/internal/ui/render"
+	"github.com/mizzy/rigel/lib/termflow"
+)
+
+// ReviewPlan implements agent.PlanReviewer: it lets the user select, move and
+// remove steps of the agent's plan before running it, pausing the spinner
+// and the typeahead meanwhile. Plans run right away unless they are
+// reviewed first.
+func (cs *ChatSession) ReviewPlan(ctx context.Context, plan *agent.Plan) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if cs.spinner == nil || (cs.core.Config != nil && !cs.core.Config.ReviewPlans) {
+		return true
+	}
+
+	cs.stopTypeahead(cs.typeahead)
+	cs.spinner.Stop()
+	defer func() {
+		cs.spinner.Start()
+		cs.typeahead = cs.startTypeahead(cs.spinner, cs.interrupt, cs.skip)
+	}()
+
+	review := chat.NewPlanReview(plan)
+	region := cs.client.NewLiveRegion()
+	draw := func() {
+		region.Draw(render.PlanReview(plan.Steps(), review.Selected()))
+	}
+	draw()
+
+	approved := false
+	err := cs.client.ReadKeys(func(key termflow.Key) bool {
+		switch key.Type {
+		case termflow.KeyEscape, termflow.KeyCtrlC:
+			return false
+		case termflow.KeyEnter:
+			approved = true
+			return false
+		case termflow.KeyArrowUp:
+			review.Select(-1)
+		case termflow.KeyArrowDown:
+			review.Select(1)
+		case termflow.KeyDelete:
+			review.RemoveStep()
+		case termflow.KeyRune:
+			switch key.Rune {
+			case 'K':
+				review.MoveStep(-1)
+			case 'J':
+				review.MoveStep(1)
+			case 'x':
+				review.RemoveStep()
+			}
+		}
+		draw()
+		return true
+	})
+	region.Clear()
+	return err == nil && approved
+}
+
+// PlanUpdated implements agent.PlanReviewer, showing the step running next
+// to the spinner
+func (cs *ChatSession) PlanUpdated(plan *agent.Plan) {
+	if cs.spinner == nil {
+		return
+	}
+	steps := plan.Steps()
+	for i, step := range steps {
+		if step.Status == agent.StepRunning {
+			cs.spinner.SetMessage(fmt.Sprintf("Step %d/%d: %s", i+1, len(steps), step.Task.Description))
+			return
+		}
+	}
+	cs.spinner.SetMessage("Thinking...")
+}
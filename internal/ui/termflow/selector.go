@@ -0,0 +1,229 @@
+package termflow
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mizzy/rigel/internal/command"
+	"github.com/mizzy/rigel/internal/session"
+	"github.com/mizzy/rigel/internal/ui/render"
+	"github.com/mizzy/rigel/lib/termflow"
+	"golang.org/x/term"
+)
+
+// Lines of the terminal the model selector keeps for its title, headings,
+// page line and help, and the models listed per page when the terminal's
+// height is unknown
+const (
+	selectorChrome          = 9
+	minSelectorPageSize     = 5
+	defaultSelectorPageSize = 20
+)
+
+// selectModel lets the user pick a model in a list redrawn in place, paged
+// to fit the terminal, and switches to it
+func (cs *ChatSession) selectModel(msg *command.ModelSelectorMsg) {
+	llmState := cs.core.LLMState
+	llmState.ActivateModelSelection(msg.Models)
+	defer llmState.DeactivateModelSelection()
+
+	pageSize := selectorPageSize()
+	region := cs.client.NewLiveRegion()
+	draw := func() {
+		region.Draw(render.ModelSelector(llmState.GetFilteredModels(), llmState.GetSelectedModelIndex(),
+			llmState.GetModelFilter(), llmState.GetModelSort().String(), pageSize))
+	}
+	draw()
+
+	var chosen bool
+	err := cs.client.ReadKeys(func(key termflow.Key) bool {
+		switch key.Type {
+		case termflow.KeyEscape, termflow.KeyCtrlC:
+			return false
+		case termflow.KeyEnter:
+			if _, ok := llmState.GetSelectedModel(); ok {
+				chosen = true
+				return false
+			}
+		case termflow.KeyArrowUp:
+			llmState.MoveModelSelectionUp()
+		case termflow.KeyArrowDown:
+			llmState.MoveModelSelectionDown()
+		case termflow.KeyPageUp:
+			llmState.MoveModelSelection(-pageSize)
+		case termflow.KeyPageDown:
+			llmState.MoveModelSelection(pageSize)
+		case termflow.KeyTab:
+			llmState.CycleModelSort()
+		case termflow.KeyBackspace:
+			if filter := []rune(llmState.GetModelFilter()); len(filter) > 0 {
+				llmState.SetModelFilter(string(filter[:len(filter)-1]))
+			}
+		case termflow.KeyRune:
+			llmState.SetModelFilter(llmState.GetModelFilter() + string(key.Rune))
+		}
+		draw()
+		return true
+	})
+	region.Clear()
+
+	if err != nil {
+		cs.client.ShowError(fmt.Errorf("failed to read keys: %w", err))
+	}
+	model, ok := llmState.GetSelectedModel()
+	if err != nil || !chosen || !ok {
+		cs.core.ChatState.SetThinking(false)
+		cs.core.ChatState.ClearCurrentPrompt()
+		return
+	}
+	command.SetModel(llmState, cs.core.Config, model)
+	cs.respond(fmt.Sprintf("Switched to model: %s", model.Name))
+}
+
+// selectHistory lets the user pick a past prompt to run again or edit, or
+// delete prompts from the history
+func (cs *ChatSession) selectHistory(msg *command.HistoryPickerMsg) error {
+	picker := cs.core.HistoryPicker
+	picker.Activate(msg.Entries)
+	defer picker.Deactivate()
+	cs.core.ChatState.SetThinking(false)
+	cs.core.ChatState.ClearCurrentPrompt()
+
+	pageSize := selectorPageSize()
+	region := cs.client.NewLiveRegion()
+	draw := func() {
+		region.Draw(render.HistoryPicker(picker.GetFiltered(), picker.GetSelectedIndex(), picker.GetFilter(), pageSize))
+	}
+	draw()
+
+	var chosen termflow.KeyType
+	var deleteErr error
+	err := cs.client.ReadKeys(func(key termflow.Key) bool {
+		switch key.Type {
+		case termflow.KeyEscape, termflow.KeyCtrlC:
+			return false
+		case termflow.KeyEnter, termflow.KeyTab:
+			if _, ok := picker.GetSelected(); ok {
+				chosen = key.Type
+				return false
+			}
+		case termflow.KeyDelete, termflow.KeyCtrlD:
+			if entry, ok := picker.RemoveSelected(); ok {
+				if err := cs.core.DeleteHistoryEntry(entry); err != nil {
+					deleteErr = err
+				}
+			}
+		case termflow.KeyArrowUp:
+			picker.Move(-1)
+		case termflow.KeyArrowDown:
+			picker.Move(1)
+		case termflow.KeyPageUp:
+			picker.Move(-pageSize)
+		case termflow.KeyPageDown:
+			picker.Move(pageSize)
+		case termflow.KeyBackspace:
+			if filter := []rune(picker.GetFilter()); len(filter) > 0 {
+				picker.SetFilter(string(filter[:len(filter)-1]))
+			}
+		case termflow.KeyRune:
+			picker.SetFilter(picker.GetFilter() + string(key.Rune))
+		}
+		draw()
+		return true
+	})
+	region.Clear()
+
+	// The line editor keeps its own copy of the history
+	cs.client.SetHistory(cs.core.InputHistory())
+	if err != nil {
+		return fmt.Errorf("failed to read keys: %w", err)
+	}
+	if deleteErr != nil {
+		return fmt.Errorf("failed to delete from the history: %w", deleteErr)
+	}
+
+	entry, _ := picker.GetSelected()
+	switch chosen {
+	case termflow.KeyEnter:
+		// Run it next, echoed as if it had been typed
+		cs.core.Enqueue(entry.Command)
+	case termflow.KeyTab:
+		cs.client.SetInitialInput(entry.Command)
+	}
+	return nil
+}
+
+// selectSearch lets the user pick one of the exchanges /search found and
+// prints it read-only
+func (cs *ChatSession) selectSearch(msg *command.SearchResultsMsg) error {
+	picker := cs.core.SearchPicker
+	picker.Activate(msg.Query, msg.Hits, msg.Current)
+	defer picker.Deactivate()
+	cs.core.ChatState.SetThinking(false)
+	cs.core.ChatState.ClearCurrentPrompt()
+
+	pageSize := selectorPageSize()
+	region := cs.client.NewLiveRegion()
+	draw := func() {
+		region.Draw(render.SearchResults(picker.GetHits(), picker.GetSelectedIndex(), picker.GetQuery(), picker.GetCurrent(), pageSize))
+	}
+	draw()
+
+	var chosen bool
+	err := cs.client.ReadKeys(func(key termflow.Key) bool {
+		switch key.Type {
+		case termflow.KeyEscape, termflow.KeyCtrlC:
+			return false
+		case termflow.KeyEnter:
+			if _, ok := picker.GetSelected(); ok {
+				chosen = true
+				return false
+			}
+		case termflow.KeyArrowUp:
+			picker.Move(-1)
+		case termflow.KeyArrowDown:
+			picker.Move(1)
+		case termflow.KeyPageUp:
+			picker.Move(-pageSize)
+		case termflow.KeyPageDown:
+			picker.Move(pageSize)
+		}
+		draw()
+		return true
+	})
+	region.Clear()
+	if err != nil {
+		return fmt.Errorf("failed to read keys: %w", err)
+	}
+	if chosen {
+		hit, _ := picker.GetSelected()
+		cs.showHit(hit, hit.Session.ID == msg.Current)
+	}
+	return nil
+}
+
+// showHit prints the exchange of a /search hit below a line naming its
+// conversation, without adding it to this one. The scrollback can't be
+// scrolled to an exchange, so one of this conversation is printed again.
+func (cs *ChatSession) showHit(hit session.Hit, current bool) {
+	sess := hit.Session
+	header := fmt.Sprintf("Exchange %d of %d of this conversation:", hit.Exchange+1, len(sess.Exchanges))
+	if !current {
+		header = fmt.Sprintf("Exchange %d of %d of %q, %s (read-only; /resume %s continues it):", hit.Exchange+1,
+			len(sess.Exchanges), sess.DisplayTitle(), sess.UpdatedAt.Local().Format("2006-01-02 15:04"), sess.ID)
+	}
+	cs.client.ShowInfo(header)
+	ex := sess.Exchanges[hit.Exchange]
+	cs.client.Print("\n")
+	cs.client.PrintChat(ex.Prompt, ex.Response)
+}
+
+// selectorPageSize returns how many entries the selectors list per page, so
+// that it fits the terminal
+func selectorPageSize() int {
+	_, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || height <= 0 {
+		return defaultSelectorPageSize
+	}
+	return max(height-selectorChrome, minSelectorPageSize)
+}
@@ -0,0 +1,88 @@
+package termflow
+
+import (
+	"strings"
+	"time"
+
+	"github.com/mizzy/rigel/internal/ui/chat"
+	"github.com/mizzy/rigel/lib/termflow"
+)
+
+// streamPrinter prints a response as its typewriter reveals it, in place of
+// the spinner once the first text is due
+type streamPrinter struct {
+	client     *termflow.InteractiveClient
+	typewriter *chat.Typewriter
+	spinner    *termflow.ThinkingSpinner
+
+	stop chan struct{}
+	done chan struct{}
+
+	// Set once the spinner was replaced; read only after done is closed
+	started bool
+}
+
+// printStream starts printing the response the typewriter reveals
+func (cs *ChatSession) printStream(tw *chat.Typewriter, spinner *termflow.ThinkingSpinner) *streamPrinter {
+	p := &streamPrinter{
+		client:     cs.client,
+		typewriter: tw,
+		spinner:    spinner,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+// run advances the typewriter until it is done or stopped
+func (p *streamPrinter) run() {
+	defer close(p.done)
+	ticker := time.NewTicker(chat.TypewriterInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case now := <-ticker.C:
+			p.print(p.typewriter.Advance(now))
+			if p.typewriter.Done() {
+				return
+			}
+		}
+	}
+}
+
+// print writes revealed text. The typeahead keeps the terminal in raw mode,
+// where line feeds don't return the cursor to the start of the line.
+func (p *streamPrinter) print(text string) {
+	if text == "" {
+		return
+	}
+	if !p.started {
+		p.started = true
+		p.spinner.Stop()
+		p.client.Printf("\r\n")
+	}
+	painted := p.client.Colors().Paint(termflow.RoleOutput, text)
+	p.client.Printf("%s", strings.ReplaceAll(painted, "\n", "\r\n"))
+}
+
+// Wait waits until the whole response is printed; the typewriter must have
+// been finished
+func (p *streamPrinter) Wait() {
+	<-p.done
+}
+
+// Stop stops printing, leaving the rest of the response unprinted
+func (p *streamPrinter) Stop() {
+	close(p.stop)
+	<-p.done
+}
+
+// Started reports whether any of the response was printed. It must be
+// called after Wait or Stop.
+func (p *streamPrinter) Started() bool {
+	return p.started
+}
@@ -0,0 +1,52 @@
+package terminal
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mizzy/rigel/internal/ui/handlers"
+)
+
+// explorerKey opens the file explorer, in place of the input's binding to
+// the end of the line, which End keeps
+const explorerKey = "ctrl+e"
+
+// openExplorer shows the file explorer listing the working directory
+func (m *Model) openExplorer() {
+	if m.core.ChatState.IsThinking() {
+		m.infoMessage = "Files can be added once the response is complete"
+		return
+	}
+	root, err := os.Getwd()
+	if err == nil {
+		err = m.core.Explorer.Activate(root)
+	}
+	if err != nil {
+		m.infoMessage = fmt.Sprintf("Couldn't list the files: %v", err)
+	}
+}
+
+// handleExplorerKey applies a key pressed in the file explorer: browsing
+// the directories, marking files and adding them to the conversation
+func (m Model) handleExplorerKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	action := handlers.HandleFileExplorerKey(msg, m.core.Explorer, m.selectorPageSize())
+	if action.Err != nil {
+		m.infoMessage = fmt.Sprintf("Couldn't list the files: %v", action.Err)
+	}
+	if len(action.Add) == 0 {
+		return m, nil
+	}
+
+	added, err := m.core.Agent.AddFiles(action.Add...)
+	var notices []string
+	if len(added) > 0 {
+		notices = append(notices, fmt.Sprintf("Added %s to the conversation", strings.Join(added, ", ")))
+	}
+	if err != nil {
+		notices = append(notices, fmt.Sprintf("Not added: %v", err))
+	}
+	m.infoMessage = strings.Join(notices, "\n")
+	return m, nil
+}
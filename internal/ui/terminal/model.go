@@ -1,6 +1,10 @@
 package terminal
 
 import (
+	"context"
+	"fmt"
+	"strings"
+
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textarea"
 	tea "github.com/charmbracelet/bubbletea"
@@ -8,35 +12,81 @@ import (
 	"github.com/mizzy/rigel/internal/agent"
 	"github.com/mizzy/rigel/internal/command"
 	"github.com/mizzy/rigel/internal/config"
-	"github.com/mizzy/rigel/internal/git"
-	"github.com/mizzy/rigel/internal/history"
 	"github.com/mizzy/rigel/internal/llm"
 	"github.com/mizzy/rigel/internal/state"
-	"github.com/mizzy/rigel/internal/tools"
+	"github.com/mizzy/rigel/internal/ui/chat"
+	"github.com/mizzy/rigel/internal/ui/handlers"
+	"github.com/mizzy/rigel/internal/ui/styles"
+	"github.com/mizzy/rigel/lib/undo"
+	"github.com/mizzy/rigel/lib/vi"
 )
 
+// defaultPlaceholder is shown in the empty input when hints are turned off
+const defaultPlaceholder = "Type a message or / for commands (Alt+Enter for new line)"
+
 // Model represents the main chat interface
 type Model struct {
-	config  *config.Config
+	core    *chat.Core
 	input   textarea.Model
 	spinner spinner.Model
 
-	chatState          *state.ChatState
-	inputHistory       []string
 	historyIndex       int
 	currentInput       string
 	quitting           bool
 	completions        []string
 	selectedCompletion int
 	showCompletions    bool
-	ctrlCPressed       bool
 	infoMessage        string
-	historyManager     *history.Manager // Add history manager
-	llmState           *state.LLMState
-	gitInfo            *git.Info // Git repository information
+	hint               string // Tip shown in the empty input; see hintTick
+
+	// Running async command
+	asyncStatus   string
+	asyncProgress <-chan string
+	cancelAsync   func()
+
+	// Tool start and finish lines of the running agent request
+	toolProgress []string
+
+	// Context of the running agent request, whose deadline is counted down
+	// to once it is near
+	requestCtx context.Context
+
+	// The response of the running agent request as it streams, and the
+	// response once complete if it is still being revealed
+	typewriter *chat.Typewriter
+	streamed   *handlers.AIResponse
+
+	// The agent's plans: the one waiting for the user's approval, if any,
+	// and the one of the running request, ticked off as it runs
+	planReviews *chat.PlanReviews
+	planReview  *chat.PlanReview
+	plan        *agent.Plan
 
-	// Intelligent Agent with file tools
-	agent *agent.Agent
+	// Vi-style editing of the input; nil unless the editing mode is vi
+	vi *vi.Editor
+
+	// Changes to the input that can be undone
+	edits undo.History
+
+	// Whether the UI owns the screen, with RIGEL_ALT_SCREEN or RIGEL_MOUSE,
+	// and shows as much of the chat as fits
+	fullScreen bool
+	width      int
+	height     int
+	scroll     int        // Lines scrolled up from the bottom
+	selection  *selection // Lines being selected by dragging
+
+	// The sidebar beside the chat, whether it has the focus instead of the
+	// input, and its selected section
+	sidebar        bool
+	sidebarFocus   bool
+	sidebarSection int
+
+	// What the welcome panel shows until the first prompt, read at startup
+	welcome chat.Welcome
+
+	// Set while the terminal reports it has lost focus
+	unfocused bool
 
 	// Handlers
 	completionHandler *command.CompletionHandler
@@ -48,7 +98,7 @@ type Exchange = state.Exchange
 // NewModel creates a new chat model instance
 func NewModel(provider llm.Provider, cfg *config.Config) *Model {
 	ta := textarea.New()
-	ta.Placeholder = "Type a message or / for commands (Alt+Enter for new line)"
+	ta.Placeholder = defaultPlaceholder
 	ta.Focus()
 	ta.CharLimit = 5000
 	ta.SetWidth(100)
@@ -67,72 +117,86 @@ func NewModel(provider llm.Provider, cfg *config.Config) *Model {
 
 	ta.FocusedStyle.Base = noBorder
 	ta.FocusedStyle.CursorLine = lipgloss.NewStyle()
-	ta.FocusedStyle.Placeholder = lipgloss.NewStyle().Foreground(lipgloss.Color("60")) // Dim blue
 	ta.FocusedStyle.Prompt = lipgloss.NewStyle()
 	ta.FocusedStyle.Text = lipgloss.NewStyle()
 	ta.FocusedStyle.EndOfBuffer = lipgloss.NewStyle()
 
 	ta.BlurredStyle.Base = noBorder
 	ta.BlurredStyle.CursorLine = lipgloss.NewStyle()
-	ta.BlurredStyle.Placeholder = lipgloss.NewStyle().Foreground(lipgloss.Color("60")) // Dim blue
 	ta.BlurredStyle.Prompt = lipgloss.NewStyle()
 	ta.BlurredStyle.Text = lipgloss.NewStyle()
 	ta.BlurredStyle.EndOfBuffer = lipgloss.NewStyle()
 
 	s := spinner.New()
 	s.Spinner = spinner.Dot
-	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("87")).Bold(true) // Same as prompt symbol
-
-	// Initialize history manager
-	histManager, err := history.NewManager()
-	if err != nil {
-		// If we can't create history manager, continue without it
-		histManager = nil
-	} else {
-		// Load existing history
-		_ = histManager.Load()
-	}
 
-	llmState := state.NewLLMState()
-	if cfg != nil {
-		llmState.SetCurrentProvider(provider)
+	// Apply the configured theme, falling back to the default on errors
+	if cfg != nil && cfg.Theme != "" {
+		if theme, err := styles.ResolveTheme(cfg.Theme); err == nil {
+			styles.Apply(theme)
+		}
 	}
 
-	// Create intelligent agent with file tools
-	intelligentAgent := agent.New(provider)
-	fileTool := tools.NewFileTool()
-	intelligentAgent.RegisterTool(fileTool)
-
-	// Use UIProgressDisplay for interactive mode to avoid interfering with terminal UI
-	uiProgress := agent.NewUIProgressDisplay()
-	intelligentAgent.SetProgressDisplay(uiProgress)
-
 	m := &Model{
-		config:            cfg,
+		core:              chat.NewCore(provider, cfg, command.ModeBubbletea),
 		input:             ta,
 		spinner:           s,
-		chatState:         state.NewChatState(),
-		inputHistory:      []string{},
 		historyIndex:      -1,
-		historyManager:    histManager,
-		llmState:          llmState,
-		gitInfo:           git.GetRepoInfo(),
-		agent:             intelligentAgent,
-		completionHandler: command.NewCompletionHandler(),
+		planReviews:       chat.NewPlanReviews(),
+		fullScreen:        cfg != nil && (cfg.AltScreen || cfg.Mouse),
+		completionHandler: command.NewCompletionHandlerForMode(command.ModeBubbletea),
 	}
-
-	// Load input history from manager if available
-	if histManager != nil {
-		m.inputHistory = histManager.GetCommands()
+	m.core.Agent.SetPlanReviewer(m.planReviews)
+	m.applyTheme()
+	m.welcome = m.core.Welcome()
+	m.hint = m.core.NextHint()
+	m.input.Placeholder = m.placeholder()
+
+	// Offer to bring back a conversation lost in a crash and a prompt left
+	// unsent, and to refresh a stale AGENTS.md
+	var notices []string
+	if sess := m.core.PendingRecovery(); sess != nil {
+		notices = append(notices, fmt.Sprintf("The previous session ended unexpectedly (%d messages). Type /restore to recover it.", len(sess.Exchanges)))
 	}
+	if notice := m.core.DraftNotice(); notice != "" {
+		notices = append(notices, notice)
+	}
+	if warning := m.core.AgentsWarning(); warning != "" {
+		notices = append(notices, warning)
+	}
+	m.infoMessage = strings.Join(notices, "\n")
 
 	return m
 }
 
+// Core returns the chat engine behind the model
+func (m *Model) Core() *chat.Core {
+	return m.core
+}
+
+// placeholder returns the text shown in the empty input: the current hint,
+// or the default when hints are turned off
+func (m Model) placeholder() string {
+	if m.hint == "" {
+		return defaultPlaceholder
+	}
+	return m.hint
+}
+
+// applyTheme updates component styles that are copied from the current theme
+func (m *Model) applyTheme() {
+	m.input.FocusedStyle.Placeholder = styles.PlaceholderStyle
+	m.input.BlurredStyle.Placeholder = styles.PlaceholderStyle
+	m.spinner.Style = styles.PromptStyle // Same as prompt symbol
+}
+
 // Init initializes the chat model
 func (m Model) Init() tea.Cmd {
 	return tea.Batch(
 		textarea.Blink,
 		m.spinner.Tick,
+		waitForToolProgress(m.core.ToolProgress),
+		waitForPlanReview(m.planReviews),
+		hintTick(),
 	)
 }
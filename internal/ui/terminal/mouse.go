@@ -0,0 +1,245 @@
+package terminal
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/atotto/clipboard"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/mizzy/rigel/internal/ui/render"
+)
+
+// wheelScrollLines is how far one wheel step scrolls the chat
+const wheelScrollLines = 3
+
+// selectionStyle highlights lines selected with the mouse
+var selectionStyle = lipgloss.NewStyle().Reverse(true)
+
+// targetKind tells what a click on a line of the interface selects
+type targetKind int
+
+const (
+	targetSuggestion targetKind = iota
+	targetModel
+	targetProvider
+	targetHistory
+	targetSearch
+	targetFile
+	targetCommand
+)
+
+// clickTarget is a line of the interface that responds to clicks: the
+// entry at index of a suggestion, model, provider, history, search result,
+// file or command list
+type clickTarget struct {
+	line  int
+	kind  targetKind
+	index int
+}
+
+// listTargets returns the targets of n entries of a list starting with the
+// entry at index from, shown one per line starting at line first
+func listTargets(kind targetKind, first, from, n int) []clickTarget {
+	targets := make([]clickTarget, max(n, 0))
+	for i := range targets {
+		targets[i] = clickTarget{line: first + i, kind: kind, index: from + i}
+	}
+	return targets
+}
+
+// lineCount returns the number of complete lines in s, which is the index
+// of the line text appended to it starts on
+func lineCount(s string) int {
+	return strings.Count(s, "\n")
+}
+
+// selection is a range of content lines selected by dragging the mouse
+type selection struct {
+	start, end int
+	dragged    bool
+}
+
+// lines returns the first and last selected lines
+func (s selection) lines() (int, int) {
+	if s.start > s.end {
+		return s.end, s.start
+	}
+	return s.start, s.end
+}
+
+// contains reports whether line is selected
+func (s *selection) contains(line int) bool {
+	if s == nil || !s.dragged {
+		return false
+	}
+	first, last := s.lines()
+	return line >= first && line <= last
+}
+
+// top returns the first content line shown, given the number of lines,
+// keeping the view within the content
+func (m Model) top(lines int) int {
+	top := lines - m.height - m.scroll
+	if top < 0 {
+		return 0
+	}
+	return top
+}
+
+// maxScroll returns how far the content can be scrolled up
+func (m Model) maxScroll(lines int) int {
+	if lines <= m.height {
+		return 0
+	}
+	return lines - m.height
+}
+
+// scrollPage scrolls the chat up or down a screen less a line, from
+// scrolled lines up from the bottom
+func (m *Model) scrollPage(scrolled int, up bool) {
+	page := max(m.height-1, 1)
+	if !up {
+		m.scroll = max(scrolled-page, 0)
+		return
+	}
+	content, _ := m.content()
+	m.scroll = min(scrolled+page, m.maxScroll(lineCount(content)+1))
+}
+
+// viewport renders the lines of content that fit the screen, cutting long
+// lines so that each content line takes one screen line
+func (m Model) viewport(content string) string {
+	if m.height <= 0 {
+		return content
+	}
+
+	lines := strings.Split(content, "\n")
+	top := m.top(len(lines))
+	bottom := min(top+m.height, len(lines))
+
+	visible := make([]string, 0, bottom-top)
+	for i := top; i < bottom; i++ {
+		line := lines[i]
+		if width := m.chatWidth(); width > 0 {
+			line = ansi.Truncate(line, width, "")
+		}
+		if m.selection.contains(i) {
+			line = selectionStyle.Render(ansi.Strip(line))
+		}
+		visible = append(visible, line)
+	}
+	return strings.Join(visible, "\n")
+}
+
+// scrollToExchange scrolls the chat so that the exchange at index starts at
+// the top of the screen, as far as the content allows
+func (m *Model) scrollToExchange(index int) {
+	history := m.core.ChatState.GetHistory()
+	before := m.renderHistory(history[:min(index, len(history))])
+	content, _ := m.content()
+	lines := lineCount(content) + 1
+	m.scroll = max(min(lines-m.height-lineCount(render.ChatHistory(before)), m.maxScroll(lines)), 0)
+}
+
+// handleMouse scrolls the chat with the wheel, activates clicked list
+// entries and copies lines selected by dragging to the clipboard
+func (m Model) handleMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
+	content, targets := m.content()
+	lines := strings.Split(content, "\n")
+	line := m.top(len(lines)) + msg.Y
+
+	switch msg.Button {
+	case tea.MouseButtonWheelUp:
+		m.scroll = min(m.scroll+wheelScrollLines, m.maxScroll(len(lines)))
+		return m, nil
+	case tea.MouseButtonWheelDown:
+		m.scroll = max(m.scroll-wheelScrollLines, 0)
+		return m, nil
+	}
+
+	// A click on a section of the sidebar selects it
+	if m.sidebarShown() && msg.X < sidebarWidth {
+		if msg.Action == tea.MouseActionPress && msg.Button == tea.MouseButtonLeft {
+			if section, ok := m.sidebarSectionAt(msg.Y); ok {
+				m.sidebarSection = section
+			}
+		}
+		return m, nil
+	}
+
+	switch msg.Action {
+	case tea.MouseActionPress:
+		if msg.Button != tea.MouseButtonLeft {
+			return m, nil
+		}
+		for _, target := range targets {
+			if target.line == line {
+				m.selection = nil
+				return m.click(target)
+			}
+		}
+		m.selection = &selection{start: line, end: line}
+		return m, nil
+
+	case tea.MouseActionMotion:
+		if m.selection != nil {
+			m.selection.end = min(max(line, 0), len(lines)-1)
+			m.selection.dragged = m.selection.dragged || m.selection.end != m.selection.start
+		}
+		return m, nil
+
+	case tea.MouseActionRelease:
+		if m.selection == nil || !m.selection.dragged {
+			m.selection = nil
+			return m, nil
+		}
+		first, last := m.selection.lines()
+		m.copyLines(lines[first : last+1])
+		return m, nil
+	}
+	return m, nil
+}
+
+// click activates the list entry of a clicked target
+func (m Model) click(target clickTarget) (tea.Model, tea.Cmd) {
+	switch target.kind {
+	case targetSuggestion:
+		m.selectedCompletion = target.index
+		m.complete()
+		return m, nil
+	case targetModel:
+		m.core.LLMState.SelectModel(target.index)
+	case targetProvider:
+		m.core.LLMState.SelectProvider(target.index)
+	case targetHistory:
+		m.core.HistoryPicker.Select(target.index)
+	case targetSearch:
+		m.core.SearchPicker.Select(target.index)
+	case targetFile:
+		m.core.Explorer.Select(target.index)
+	case targetCommand:
+		m.core.Palette.Select(target.index)
+	}
+	// Choose the entry as if Enter had been pressed on it
+	return m.update(tea.KeyMsg{Type: tea.KeyEnter})
+}
+
+// copyLines copies the text of rendered lines to the clipboard, reporting
+// the outcome below the input
+func (m *Model) copyLines(lines []string) {
+	text := make([]string, len(lines))
+	for i, line := range lines {
+		text[i] = strings.TrimRight(ansi.Strip(line), " ")
+	}
+	if err := clipboard.WriteAll(strings.Join(text, "\n")); err != nil {
+		m.infoMessage = fmt.Sprintf("Couldn't copy to the clipboard: %v", err)
+		return
+	}
+	if len(lines) == 1 {
+		m.infoMessage = "Copied 1 line to the clipboard"
+	} else {
+		m.infoMessage = fmt.Sprintf("Copied %d lines to the clipboard", len(lines))
+	}
+}
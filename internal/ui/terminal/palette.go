@@ -0,0 +1,35 @@
+package terminal
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mizzy/rigel/internal/ui/handlers"
+)
+
+// paletteKey opens the command palette, in place of the input's binding
+// to the previous line
+const paletteKey = "ctrl+p"
+
+// openPalette shows the command palette with the commands of the registry
+func (m *Model) openPalette() {
+	m.showCompletions = false
+	m.core.Palette.Activate(m.core.PaletteEntries())
+}
+
+// handlePaletteKey applies a key pressed in the command palette: running
+// the chosen command, or putting it in the input to type its arguments
+func (m Model) handlePaletteKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	action := handlers.HandleCommandPaletteKey(msg, m.core.Palette, m.selectorPageSize())
+	switch {
+	case action.Run != "":
+		// Like a prompt, it waits for the response being received
+		if m.core.ChatState.IsThinking() {
+			m.core.Enqueue(action.Run)
+			return m, nil
+		}
+		return m, m.send(action.Run)
+	case action.Edit != "":
+		m.input.SetValue(action.Edit)
+		m.input.CursorEnd()
+	}
+	return m, nil
+}
@@ -0,0 +1,133 @@
+package terminal
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/mizzy/rigel/internal/ui/chat"
+	"github.com/mizzy/rigel/internal/ui/render"
+)
+
+const (
+	// sidebarWidth is the width of the sidebar, its border included
+	sidebarWidth = 32
+
+	// minChatWidth is the narrowest the chat gets beside the sidebar; the
+	// sidebar is hidden in narrower terminals
+	minChatWidth = 48
+
+	// Keys toggling the sidebar and moving the focus between it and the
+	// input; neither is bound by the input
+	sidebarToggleKey = "ctrl+o"
+	sidebarFocusKey  = "shift+tab"
+)
+
+// sidebarShown reports whether the sidebar is open and the terminal is
+// large enough to show it
+func (m Model) sidebarShown() bool {
+	return m.sidebar && m.width >= sidebarWidth+minChatWidth && m.height > 0
+}
+
+// chatWidth returns the width left for the chat, or 0 if unknown
+func (m Model) chatWidth() int {
+	if m.sidebarShown() {
+		return m.width - sidebarWidth
+	}
+	return m.width
+}
+
+// toggleSidebar opens or closes the sidebar, handing the focus back to the
+// input when it closes
+func (m *Model) toggleSidebar() {
+	m.sidebar = !m.sidebar
+	if !m.sidebar {
+		m.focusInput()
+		return
+	}
+	if !m.sidebarShown() {
+		m.sidebar = false
+		m.infoMessage = "The terminal is too narrow for the sidebar"
+	}
+}
+
+// focusSidebar moves the focus to the sidebar, opening it if needed, or
+// back to the input
+func (m *Model) focusSidebar() {
+	if m.sidebarFocus {
+		m.focusInput()
+		return
+	}
+	if !m.sidebar {
+		if m.toggleSidebar(); !m.sidebar {
+			return
+		}
+	}
+	m.sidebarFocus = true
+	m.input.Blur()
+}
+
+// focusInput gives the focus back to the input
+func (m *Model) focusInput() {
+	m.sidebarFocus = false
+	m.input.Focus()
+}
+
+// handleSidebarKey applies a key pressed while the sidebar has the focus:
+// selecting a section, or opening it in full with its command
+func (m Model) handleSidebarKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "up", "k":
+		m.sidebarSection = max(m.sidebarSection-1, 0)
+	case "down", "j":
+		m.sidebarSection = min(m.sidebarSection+1, len(chat.SidebarSections)-1)
+	case "esc":
+		m.focusInput()
+	case "enter":
+		m.focusInput()
+		command := chat.SidebarSections[m.sidebarSection].Command()
+		// Like a prompt, it waits for the response being received
+		if m.core.ChatState.IsThinking() {
+			m.core.Enqueue(command)
+			return m, nil
+		}
+		return m, m.send(command)
+	}
+	return m, nil
+}
+
+// sidebarSectionAt returns the section on line y of the sidebar, laid out
+// as render.Sidebar lays it out
+func (m Model) sidebarSectionAt(y int) (int, bool) {
+	line := 0
+	for i, entry := range m.core.Sidebar() {
+		if y == line {
+			return i, true
+		}
+		line++
+		if i == m.sidebarSection {
+			line += len(entry.Details)
+		}
+	}
+	return 0, false
+}
+
+// withSidebar renders the sidebar to the left of the lines of the chat that
+// fit the screen
+func (m Model) withSidebar(view string) string {
+	width := m.chatWidth() - 1 // After a space
+	lines := strings.Split(view, "\n")
+	lines = lines[max(len(lines)-m.height, 0):]
+	for i, line := range lines {
+		lines[i] = " " + ansi.Truncate(line, width, "")
+	}
+
+	entries := m.core.Sidebar()
+	sections := make([]render.SidebarSection, len(entries))
+	for i, entry := range entries {
+		sections[i] = render.SidebarSection{Title: entry.Section.String(), Count: entry.Count, Details: entry.Details}
+	}
+	sidebar := render.Sidebar(sections, m.sidebarSection, m.sidebarFocus, sidebarWidth, m.height)
+	return lipgloss.JoinHorizontal(lipgloss.Top, sidebar, strings.Join(lines, "\n"))
+}
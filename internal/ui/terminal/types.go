@@ -1,6 +1,12 @@
 package terminal
 
-import "github.com/mizzy/rigel/internal/llm"
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mizzy/rigel/internal/llm"
+	"github.com/mizzy/rigel/internal/ui/chat"
+)
 
 // modelSelectorMsg is sent when model selection is requested
 type modelSelectorMsg struct {
@@ -14,3 +20,43 @@ type providerSelectorMsg struct {
 	currentProvider llm.Provider
 	err             error
 }
+
+// asyncProgressMsg carries a progress update from a running async command
+type asyncProgressMsg struct {
+	text     string
+	progress <-chan string
+}
+
+// toolProgressMsg carries a line about a tool the agent started or finished
+type toolProgressMsg struct {
+	text string
+}
+
+// planReviewMsg carries a plan of the agent waiting for the user's approval
+type planReviewMsg struct {
+	review *chat.PlanReview
+}
+
+// hintTickMsg is sent when the input's placeholder should show the next hint
+type hintTickMsg struct{}
+
+// hintInterval is how long each hint stays in the empty input
+const hintInterval = 8 * time.Second
+
+// hintTick schedules the next hint
+func hintTick() tea.Cmd {
+	return tea.Tick(hintInterval, func(time.Time) tea.Msg {
+		return hintTickMsg{}
+	})
+}
+
+// typewriterTickMsg is sent when the streamed response should show what is
+// due at its time
+type typewriterTickMsg time.Time
+
+// typewriterTick schedules the next advance of the streamed response
+func typewriterTick() tea.Cmd {
+	return tea.Tick(chat.TypewriterInterval, func(t time.Time) tea.Msg {
+		return typewriterTickMsg(t)
+	})
+}
@@ -0,0 +1,48 @@
+package terminal
+
+import (
+	"github.com/mizzy/rigel/lib/undo"
+	"github.com/mizzy/rigel/lib/vi"
+)
+
+// Keys undoing the last change to the input, readline-style, and redoing
+// the last change undone; neither is bound by the input
+const (
+	undoKey = "ctrl+_"
+	redoKey = "ctrl+z"
+)
+
+// resetEdits forgets the changes made to the input, e.g. after it was sent
+func (m *Model) resetEdits() {
+	m.edits.Reset(m.inputState())
+	m.core.SaveDraft(m.input.Value())
+}
+
+// inputState returns the input and its cursor for the undo history
+func (m *Model) inputState() undo.State {
+	return undo.State{Text: m.input.Value(), Cursor: m.inputCursor()}
+}
+
+// recordEdit notes the state of the input in its undo history and the
+// draft autosaved
+func (m *Model) recordEdit() {
+	m.edits.Record(m.inputState())
+	m.core.SaveDraft(m.input.Value())
+}
+
+// undoEdit undoes the last change to the input, or redoes the last change
+// undone
+func (m *Model) undoEdit(redo bool) {
+	m.recordEdit()
+	step := m.edits.Undo
+	if redo {
+		step = m.edits.Redo
+	}
+	s, ok := step()
+	if !ok {
+		return
+	}
+	m.setInput(vi.Buffer{Text: []rune(s.Text), Cursor: s.Cursor})
+	m.completions, m.showCompletions = m.completionHandler.UpdateCompletions(m.input.Value())
+	m.selectedCompletion = 0
+}
@@ -1,41 +1,124 @@
 package terminal
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/mizzy/rigel/internal/command"
+	"github.com/mizzy/rigel/internal/recovery"
+	"github.com/mizzy/rigel/internal/ui/chat"
 	"github.com/mizzy/rigel/internal/ui/handlers"
+	"github.com/mizzy/rigel/internal/ui/render"
 )
 
 // Update handles incoming messages and returns updated application state
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	// Bubbletea restores the terminal on panic; make sure the stack reaches the log
+	defer recovery.LogAndRepanic()
+
+	wasThinking := m.core.ChatState.IsThinking()
+	model, cmd := m.update(msg)
+	next, ok := model.(Model)
+	if !ok {
+		return model, cmd
+	}
+
+	// Alert the user if a long response finished while they were away
+	if wasThinking && !next.core.ChatState.IsThinking() {
+		if notification := next.core.Notification(!next.unfocused); notification != "" {
+			cmd = tea.Batch(cmd, notify(notification))
+		}
+	}
+
+	// Send the prompts typed while thinking once the response is complete
+	if submit := next.submitQueued(); submit != nil {
+		return next, tea.Batch(cmd, submit)
+	}
+	return next, cmd
+}
+
+// update applies a message to the model
+func (m Model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 	var cmds []tea.Cmd
 
+	chatState := m.core.ChatState
+	llmState := m.core.LLMState
+
 	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+
+	case tea.MouseMsg:
+		return m.handleMouse(msg)
+
+	case tea.FocusMsg:
+		m.unfocused = false
+		return m, nil
+
+	case tea.BlurMsg:
+		m.unfocused = true
+		return m, nil
+
 	case tea.KeyMsg:
+		// Typing brings the input back into view; the page keys scroll from
+		// where the chat was scrolled to
+		scrolled := m.scroll
+		m.scroll = 0
+		m.selection = nil
+
 		// Handle provider selection mode
-		if m.llmState.IsProviderSelectionActive() {
-			result := handlers.HandleProviderSelectionKey(msg, m.llmState, m.chatState, m.config)
+		if llmState.IsProviderSelectionActive() {
+			result := handlers.HandleProviderSelectionKey(msg, llmState, chatState, m.core.Config)
 			if result.ShouldSwitch {
 				return m, result.SwitchCmd
 			}
 			return m, nil
 		}
 
+		// Handle the /history list
+		if m.core.HistoryPicker.IsActive() {
+			return m.handleHistoryKey(msg)
+		}
+
+		// Handle the /search results and the exchange opened from them
+		if m.core.SearchPicker.IsActive() {
+			return m.handleSearchKey(msg)
+		}
+		if m.core.Viewer.IsActive() {
+			handlers.HandleSessionViewerKey(msg, m.core.Viewer)
+			return m, nil
+		}
+
+		// Handle the file explorer
+		if m.core.Explorer.IsActive() {
+			return m.handleExplorerKey(msg)
+		}
+
+		// Handle the command palette
+		if m.core.Palette.IsActive() {
+			return m.handlePaletteKey(msg)
+		}
+
+		// Handle the agent's plan waiting for approval
+		if m.planReview != nil {
+			return m.handlePlanKey(msg)
+		}
+
 		// Handle model selection mode
-		if m.llmState.IsModelSelectionActive() {
-			result := handlers.HandleModelSelectionKey(msg, m.llmState, m.chatState, &m.input)
-			if result.InputValue != "" || result.Placeholder != "" {
-				if result.InputValue != "" {
-					m.input.SetValue(result.InputValue)
-				}
-				if result.Placeholder != "" {
-					m.input.Placeholder = result.Placeholder
-				}
+		if llmState.IsModelSelectionActive() {
+			result := handlers.HandleModelSelectionKey(msg, llmState, chatState, m.core.Config, &m.input, m.selectorPageSize())
+			if result.InputValue != "" {
+				m.input.SetValue(result.InputValue)
+			}
+			if result.ShouldExit {
+				m.input.Placeholder = m.placeholder()
 			}
 			if result.ShouldSwitch {
 				return m, result.SwitchCmd
@@ -44,47 +127,110 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 		// Handle special keys first
+		exitGuard := m.core.ExitGuard()
 		switch msg.Type {
 		case tea.KeyCtrlC:
-			if m.ctrlCPressed {
+			// The first Ctrl+C cancels a running request or async command
+			if m.cancelAsync != nil {
+				m.cancelRunning()
+				return m, nil
+			}
+			if exitGuard.Press() {
 				m.quitting = true
 				return m, tea.Quit
 			}
-			m.ctrlCPressed = true
-			m.infoMessage = "Press Ctrl+C again to exit"
-			// Reset Ctrl+C flag after any other key
+			m.infoMessage = chat.ExitHint
 			return m, nil
 
+		case tea.KeyEsc:
+			exitGuard.Reset()
+			m.infoMessage = ""
+			// Esc cancels a running request or async command too
+			if m.cancelAsync != nil {
+				m.cancelRunning()
+				return m, nil
+			}
+
+		case tea.KeyEnd, tea.KeyCtrlEnd:
+			// Jump to the complete output of the response being revealed;
+			// End still moves the cursor when nothing is waiting
+			if tw := m.typewriter; tw != nil && (msg.Type == tea.KeyCtrlEnd || tw.Pending()) {
+				tw.Skip()
+				return m, nil
+			}
+
+		case tea.KeyPgUp, tea.KeyPgDown:
+			// Scroll the chat on a screen the UI owns
+			if m.fullScreen {
+				m.scrollPage(scrolled, msg.Type == tea.KeyPgUp)
+				return m, nil
+			}
+
 		case tea.KeyCtrlD:
 			// Reset Ctrl+C flag on any other key
-			m.ctrlCPressed = false
+			exitGuard.Reset()
 			m.infoMessage = ""
-			if !m.chatState.IsThinking() && m.input.Value() == "" {
+			if !chatState.IsThinking() && m.input.Value() == "" {
 				m.quitting = true
 				return m, tea.Quit
 			}
 		default:
 			// Reset Ctrl+C flag on any other key
-			m.ctrlCPressed = false
+			exitGuard.Reset()
 			m.infoMessage = ""
 		}
 
-		// Handle Tab key for completion
-		if msg.String() == "tab" && !m.chatState.IsThinking() && m.showCompletions {
-			completionValue := m.completionHandler.GetCompletionValue(m.completions, m.selectedCompletion)
-			if completionValue != "" {
-				m.input.SetValue(completionValue)
-				m.input.CursorEnd()
-				m.showCompletions = false
-				m.completions = []string{}
+		// Toggle the sidebar or move the focus between it and the input, or
+		// open the file explorer or the command palette
+		switch msg.String() {
+		case explorerKey:
+			m.openExplorer()
+			return m, nil
+		case paletteKey:
+			m.openPalette()
+			return m, nil
+		case sidebarToggleKey:
+			m.toggleSidebar()
+			return m, nil
+		case sidebarFocusKey:
+			m.focusSidebar()
+			return m, nil
+		}
+		if m.sidebarFocus {
+			return m.handleSidebarKey(msg)
+		}
+
+		// Digits resume the sessions the welcome panel lists
+		if m.welcomeShown() && m.input.Value() == "" && !m.selectorActive() {
+			if command := m.welcomeResume(msg); command != "" {
+				return m, m.send(command)
 			}
-			m.ctrlCPressed = false // Reset Ctrl+C flag
-			m.infoMessage = ""
+		}
+
+		// In vi mode, let the vi editor handle the key first
+		m.syncEditingMode()
+		if m.vi != nil {
+			var pass bool
+			if msg, pass = m.handleViKey(msg); !pass {
+				m.recordEdit()
+				return m, nil
+			}
+		}
+
+		// Undo or redo changes to the input, also while thinking
+		if key := msg.String(); (key == undoKey || key == redoKey) && !m.selectorActive() {
+			m.undoEdit(key == redoKey)
+			return m, nil
+		}
+
+		// Handle Tab key for completion
+		if msg.String() == "tab" && !chatState.IsThinking() && m.showCompletions {
+			m.complete()
 			return m, nil
 		}
 
 		// Handle arrow keys for suggestion navigation or history navigation
-		if !m.chatState.IsThinking() {
+		if !chatState.IsThinking() {
 			switch msg.String() {
 			case "up":
 				if m.showCompletions {
@@ -92,18 +238,9 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 						m.selectedCompletion--
 					}
 				} else {
-					histState := &handlers.HistoryNavigationState{
-						InputHistory: m.inputHistory,
-						HistoryIndex: m.historyIndex,
-						CurrentInput: m.currentInput,
-					}
-					handlers.NavigateHistory(-1, &m.input, histState)
-					m.inputHistory = histState.InputHistory
-					m.historyIndex = histState.HistoryIndex
-					m.currentInput = histState.CurrentInput
+					m.navigateHistory(-1)
+					m.recordEdit()
 				}
-				m.ctrlCPressed = false // Reset Ctrl+C flag
-				m.infoMessage = ""
 				return m, nil
 			case "down":
 				if m.showCompletions {
@@ -111,26 +248,26 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 						m.selectedCompletion++
 					}
 				} else {
-					histState := &handlers.HistoryNavigationState{
-						InputHistory: m.inputHistory,
-						HistoryIndex: m.historyIndex,
-						CurrentInput: m.currentInput,
-					}
-					handlers.NavigateHistory(1, &m.input, histState)
-					m.inputHistory = histState.InputHistory
-					m.historyIndex = histState.HistoryIndex
-					m.currentInput = histState.CurrentInput
+					m.navigateHistory(1)
+					m.recordEdit()
 				}
-				m.ctrlCPressed = false // Reset Ctrl+C flag
-				m.infoMessage = ""
 				return m, nil
 			}
 		}
 
+		// Enter while thinking queues the prompt to send when the response completes
+		if msg.String() == "enter" && chatState.IsThinking() {
+			if strings.TrimSpace(m.input.Value()) != "" {
+				m.core.Enqueue(m.input.Value())
+				m.input.SetValue("")
+				m.showCompletions = false
+				m.resetEdits()
+			}
+			return m, nil
+		}
+
 		// Check for Enter key specifically (not Alt+Enter)
-		if msg.String() == "enter" && !m.chatState.IsThinking() {
-			m.ctrlCPressed = false // Reset Ctrl+C flag
-			m.infoMessage = ""
+		if msg.String() == "enter" && !chatState.IsThinking() {
 			// If completions are shown and one is selected, complete and execute it
 			if m.showCompletions {
 				completionValue := m.completionHandler.GetCompletionValue(m.completions, m.selectedCompletion)
@@ -140,75 +277,30 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 				m.showCompletions = false
 				m.completions = []string{}
-				// After completing suggestion, check if it's a command and execute it
+				// After completing suggestion, execute it if it's a command
 				if strings.HasPrefix(m.input.Value(), "/") {
-					// Treat it as if user pressed Enter with the command
-					prompt := m.input.Value()
-					m.chatState.SetCurrentPrompt(prompt)
-
-					// Save to input history
-					m.inputHistory = append(m.inputHistory, prompt)
-					m.historyIndex = -1
-					m.currentInput = ""
-
-					// Save to persistent history
-					if m.historyManager != nil {
-						_ = m.historyManager.Add(prompt)
-					}
-
-					m.input.SetValue("")
-					m.chatState.SetThinking(true)
-					m.chatState.ClearError()
-					m.showCompletions = false
-
-					// Handle the command
-					cmdPrompt := m.chatState.GetCurrentPrompt()
-					result := command.HandleCommand(cmdPrompt, m.llmState, m.chatState, m.config, m.historyManager, m.inputHistory)
-					cmd := func() tea.Msg { return result }
-					return m, tea.Batch(cmd, m.spinner.Tick)
+					return m, m.submit()
 				}
 				return m, nil
 			}
 
 			if strings.TrimSpace(m.input.Value()) != "" {
-				prompt := m.input.Value()
-				m.chatState.SetCurrentPrompt(prompt)
-
-				// Save to input history
-				m.inputHistory = append(m.inputHistory, prompt)
-				m.historyIndex = -1
-				m.currentInput = ""
-
-				// Save to persistent history
-				if m.historyManager != nil {
-					_ = m.historyManager.Add(prompt)
-				}
-
-				m.input.SetValue("")
-				m.chatState.SetThinking(true)
-				m.chatState.ClearError()
-				m.showCompletions = false
-
-				// Handle commands
-				cmdPrompt := m.chatState.GetCurrentPrompt()
-				result := command.HandleCommand(cmdPrompt, m.llmState, m.chatState, m.config, m.historyManager, m.inputHistory)
-				cmd := func() tea.Msg { return result }
-				return m, tea.Batch(cmd, m.spinner.Tick)
+				return m, m.submit()
 			}
 			return m, nil
 		}
 
-		// Pass all other keys (including alt+enter and ctrl+j) to textarea
-		if !m.chatState.IsThinking() && !m.llmState.IsModelSelectionActive() && !m.llmState.IsProviderSelectionActive() {
+		// Pass all other keys (including alt+enter and ctrl+j) to textarea,
+		// also while thinking so the next prompt can be typed ahead
+		if !m.selectorActive() {
 			oldValue := m.input.Value()
 			m.input, cmd = m.input.Update(msg)
+			m.recordEdit()
 
 			// Update completions if input changed
 			if oldValue != m.input.Value() {
 				m.completions, m.showCompletions = m.completionHandler.UpdateCompletions(m.input.Value())
 				m.selectedCompletion = 0
-				m.ctrlCPressed = false // Reset Ctrl+C flag when typing
-				m.infoMessage = ""
 
 				// Reset history navigation if user types
 				if m.historyIndex != -1 {
@@ -227,7 +319,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 
-		m.llmState.ActivateProviderSelection(msg.providers, msg.currentProvider)
+		llmState.ActivateProviderSelection(msg.providers, msg.currentProvider)
 		return m, nil
 
 	case modelSelectorMsg:
@@ -237,66 +329,148 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 
-		m.llmState.ActivateModelSelection(msg.models)
+		llmState.ActivateModelSelection(msg.models)
 		m.input.SetValue("")
 		m.input.Placeholder = "Type to filter models, Enter to select, Esc to cancel"
 
 		return m, nil
 
-	case command.Result:
-		if msg.Error != nil {
-			m.chatState.SetThinking(false)
-			m.chatState.SetError(msg.Error)
+	case asyncProgressMsg:
+		// Ignore updates from a command that finished or is being cancelled
+		if msg.progress == m.asyncProgress {
+			m.asyncStatus = msg.text
+		}
+		return m, waitForProgress(msg.progress)
+
+	case hintTickMsg:
+		// The model selector uses the placeholder for its own help
+		m.hint = m.core.NextHint()
+		if !llmState.IsModelSelectionActive() {
+			m.input.Placeholder = m.placeholder()
+		}
+		return m, hintTick()
+
+	case typewriterTickMsg:
+		tw := m.typewriter
+		if tw == nil {
+			return m, nil
+		}
+		tw.Advance(time.Time(msg))
+		if m.streamed != nil && tw.Done() {
+			response := *m.streamed
+			m.streamed = nil
+			return m.update(response)
+		}
+		return m, typewriterTick()
+
+	case toolProgressMsg:
+		// Lines arriving after the request finished are already in its response
+		if chatState.IsThinking() {
+			m.toolProgress = append(m.toolProgress, msg.text)
+		}
+		return m, waitForToolProgress(m.core.ToolProgress)
+
+	case planReviewMsg:
+		// The plan is shown as a checklist while it runs; it runs right away
+		// unless plans are reviewed first
+		m.plan = msg.review.Plan
+		if m.core.Config != nil && !m.core.Config.ReviewPlans {
+			msg.review.Approve()
 		} else {
-			switch msg.Type {
-			case "async":
-				// Keep thinking state ON and execute async function
-				if msg.AsyncFn != nil {
-					asyncCmd := func() tea.Msg {
-						return msg.AsyncFn()
-					}
-					return m, asyncCmd
-				}
-			case "clear_input_history":
-				// Clear input history
-				m.chatState.SetThinking(false)
-				m.inputHistory = []string{}
-				m.historyIndex = -1
-				m.currentInput = ""
-				m.chatState.AddExchange(m.chatState.GetCurrentPrompt(), "Command history cleared successfully.")
-				m.chatState.ClearCurrentPrompt()
-			case "request":
-				// Handle normal prompts (non-commands) using intelligent agent - keep thinking state ON
-				return m, handlers.RequestResponseWithAgent(msg.Prompt, m.agent)
-			case "model_selector":
-				m.chatState.SetThinking(false)
-				if msg.ModelSelector != nil {
-					return m, func() tea.Msg { return *msg.ModelSelector }
-				}
-			case "provider_selector":
-				m.chatState.SetThinking(false)
-				if msg.ProviderSelector != nil {
-					return m, func() tea.Msg { return *msg.ProviderSelector }
-				}
-			case "status":
-				m.chatState.SetThinking(false)
-				if msg.StatusInfo != nil {
-					return m, func() tea.Msg { return *msg.StatusInfo }
-				}
-			case "quit":
-				m.chatState.SetThinking(false)
-				m.quitting = true
-				return m, tea.Quit
-			case "clear":
-				m.chatState.SetThinking(false)
-				return m, nil
-			default:
-				m.chatState.SetThinking(false)
-				if msg.Content != "" {
-					m.chatState.AddExchange(m.chatState.GetCurrentPrompt(), msg.Content)
-					m.chatState.ClearCurrentPrompt()
-				}
+			m.planReview = msg.review
+		}
+		return m, waitForPlanReview(m.planReviews)
+
+	case command.Result:
+		if _, ok := msg.(command.Async); !ok {
+			m.asyncStatus = ""
+			m.asyncProgress = nil
+			m.cancelAsync = nil
+		}
+		switch result := msg.(type) {
+		case command.Failure:
+			m.core.Fail(result.Err)
+		case command.Async:
+			// Keep thinking state ON and execute async function
+			asyncCmd := func() tea.Msg {
+				return result.Fn()
+			}
+			m.cancelAsync = result.Cancel
+			m.asyncProgress = result.Progress
+			if result.Progress != nil {
+				return m, tea.Batch(asyncCmd, waitForProgress(result.Progress))
+			}
+			return m, asyncCmd
+		case command.Response:
+			chatState.SetThinking(false)
+			if result.Content != "" {
+				m.core.CompleteExchange(result.Content)
 			}
+		case command.ClearInputHistory:
+			m.core.ClearInputHistory()
+			m.historyIndex = -1
+			m.currentInput = ""
+			m.core.CompleteExchange("Command history cleared successfully.")
+		case command.Request:
+			// Handle normal prompts (non-commands) using intelligent agent - keep thinking state ON
+			return m, m.request(result.Prompt)
+		case command.Retry:
+			m.core.Supersede(result.Prompt)
+			chatState.SetCurrentPrompt(result.Prompt)
+			m.infoMessage = result.Notice
+			return m, m.request(result.Prompt)
+		case command.ShowComparison:
+			columns := make([]render.Column, len(result.Responses))
+			for i, r := range result.Responses {
+				columns[i] = render.Column{Title: r.Label(), Subtitle: r.Stats(), Body: chat.ComparisonBody(r)}
+			}
+			m.core.CompleteExchange(render.Columns(columns, render.GetTerminalWidth()-2))
+		case command.ShowReview:
+			m.core.CompleteExchange(chat.FormatReview(result.Review))
+		case command.EditLast:
+			m.core.Supersede(result.Prompt)
+			chatState.SetThinking(false)
+			chatState.ClearCurrentPrompt()
+			m.input.SetValue(result.Prompt)
+			m.input.CursorEnd()
+		case command.RestoreDraft:
+			chatState.SetThinking(false)
+			chatState.ClearCurrentPrompt()
+			m.input.SetValue(result.Draft)
+			m.input.CursorEnd()
+			m.recordEdit()
+		case command.ShowModelSelector:
+			chatState.SetThinking(false)
+			return m, func() tea.Msg { return *result.Selector }
+		case command.ShowHistory:
+			chatState.SetThinking(false)
+			chatState.ClearCurrentPrompt()
+			m.core.HistoryPicker.Activate(result.Picker.Entries)
+		case command.ShowSearch:
+			chatState.SetThinking(false)
+			chatState.ClearCurrentPrompt()
+			m.core.SearchPicker.Activate(result.Results.Query, result.Results.Hits, result.Results.Current)
+		case command.ShowProviderSelector:
+			chatState.SetThinking(false)
+			return m, func() tea.Msg { return *result.Selector }
+		case command.ShowStatus:
+			chatState.SetThinking(false)
+			return m, func() tea.Msg { return *result.Info }
+		case command.Quit:
+			chatState.SetThinking(false)
+			m.quitting = true
+			return m, tea.Quit
+		case command.ClearChat:
+			chatState.SetThinking(false)
+			return m, nil
+		case command.ThemeChanged:
+			m.applyTheme()
+			m.core.CompleteExchange(result.Content)
+		case command.Restore:
+			chatState.SetThinking(false)
+			chatState.ClearCurrentPrompt()
+			m.core.Restore(result.Session)
+			m.infoMessage = result.Notice
 		}
 		return m, nil
 
@@ -307,76 +481,289 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 
-		m.llmState.ActivateModelSelection(msg.Models)
+		llmState.ActivateModelSelection(msg.Models)
 		m.input.SetValue("")
 		m.input.Placeholder = "Type to filter models, Enter to select, Esc to cancel"
 
 		return m, nil
 
 	case command.ProviderSelectorMsg:
-		m.llmState.ActivateProviderSelection(msg.Providers, msg.CurrentProvider)
+		llmState.ActivateProviderSelection(msg.Providers, msg.CurrentProvider)
 		return m, nil
 
 	case command.StatusInfo:
-		m.chatState.SetThinking(false)
-		// Convert StatusInfo to formatted string and display it
-		statusContent := fmt.Sprintf("✦ Rigel Session Status\n\n"+
-			"🤖 LLM Configuration\n"+
-			"  Provider: %s\n"+
-			"  Model: %s\n\n"+
-			"💬 Chat History\n"+
-			"  Messages: %d\n"+
-			"  User tokens: ~%d\n"+
-			"  Assistant tokens: ~%d\n"+
-			"  Total tokens: ~%d\n\n"+
-			"📝 Command History\n"+
-			"  Commands saved: %d\n"+
-			"  Persistence: %s\n\n"+
-			"🔧 Environment\n"+
-			"  Log level: %s\n"+
-			"  Repository context: %s\n",
-			msg.Provider, msg.Model,
-			msg.MessageCount,
-			msg.UserTokens, msg.AssistantTokens, msg.TotalTokens,
-			msg.CommandsCount,
-			map[bool]string{true: "✓ Enabled", false: "✗ Disabled"}[msg.PersistenceEnabled],
-			msg.LogLevel,
-			map[bool]string{true: "✓ AGENTS.md loaded", false: "✗ Not initialized (run /init)"}[msg.RepositoryInitialized])
-
-		m.chatState.AddExchange(m.chatState.GetCurrentPrompt(), statusContent)
-		m.chatState.ClearCurrentPrompt()
+		m.core.CompleteExchange(chat.FormatStatus(&msg, m.core.Mode))
 		return m, nil
 
 	case handlers.ProviderSwitchResponse:
-		m.llmState.SetCurrentProvider(msg.Provider)
-		m.chatState.SetThinking(false)
-		response := fmt.Sprintf("Switched to provider: %s\nCurrent model: %s", msg.ProviderName, m.llmState.GetCurrentModel().Name)
-		m.chatState.AddExchange(m.chatState.GetCurrentPrompt(), response)
-		m.chatState.ClearCurrentPrompt()
+		llmState.SetCurrentProvider(msg.Provider)
+		response := fmt.Sprintf("Switched to provider: %s\nCurrent model: %s", msg.ProviderName, llmState.GetCurrentModel().Name)
+		m.core.CompleteExchange(response)
 		return m, nil
 
 	case handlers.AIResponse:
-		m.chatState.SetThinking(false)
-
-		if msg.Error != nil {
-			m.chatState.SetError(msg.Error)
-		} else {
-			m.chatState.AddExchange(m.chatState.GetCurrentPrompt(), msg.Content)
-			m.chatState.ClearCurrentPrompt()
+		// Finish revealing the response before completing the exchange
+		if tw := m.typewriter; tw != nil && msg.Error == nil && !tw.Done() {
+			tw.Finish(msg.Content)
+			if !tw.Done() {
+				m.streamed = &msg
+				return m, nil
+			}
 		}
-		return m, nil
+		m.core.Agent.SetStreamHandler(nil)
+		m.typewriter, m.streamed = nil, nil
+		m.toolProgress = nil
+		timedOut := m.requestCtx != nil && errors.Is(m.requestCtx.Err(), context.DeadlineExceeded)
+		m.requestCtx = nil
+		if m.cancelAsync != nil {
+			m.cancelAsync()
+			m.cancelAsync = nil
+		}
+		m.asyncStatus = ""
+		var notices []string
+		var next tea.Cmd
+		switch {
+		case errors.Is(msg.Error, context.Canceled):
+			if _, ok := m.core.Cancel(msg.Error); ok {
+				notices = append(notices, chat.InterruptedHint)
+			} else {
+				notices = append(notices, "Request cancelled")
+			}
+		case msg.Error != nil && timedOut:
+			if _, ok := m.core.Cancel(msg.Error); ok {
+				notices = append(notices, chat.InterruptedHint)
+			}
+			notices = append(notices, m.core.TimeoutNotice())
+		case msg.Error != nil:
+			m.core.Fail(msg.Error)
+		default:
+			m.core.CompleteExchange(msg.Content)
+			if offer := m.core.SaveCodeOffer(msg.Content); offer != nil {
+				next = func() tea.Msg { return offer }
+			}
+		}
+		for _, notice := range []string{m.core.FailoverNotice(), m.core.ContextWarning()} {
+			if notice != "" {
+				notices = append(notices, notice)
+			}
+		}
+		if len(notices) > 0 {
+			m.infoMessage = strings.Join(notices, "\n")
+		}
+		return m, next
 
 	case spinner.TickMsg:
-		if m.chatState.IsThinking() {
+		if chatState.IsThinking() {
 			m.spinner, cmd = m.spinner.Update(msg)
 			return m, cmd
 		}
 	}
 
-	if !m.chatState.IsThinking() && !m.llmState.IsModelSelectionActive() && !m.llmState.IsProviderSelectionActive() {
+	if !m.selectorActive() {
 		m.input, cmd = m.input.Update(msg)
 		cmds = append(cmds, cmd)
 	}
 
 	return m, tea.Batch(cmds...)
 }
+
+// request sends a prompt to the agent, which Ctrl+C or Esc interrupts, as
+// does running past the generation timeout
+func (m *Model) request(prompt string) tea.Cmd {
+	ctx, cancel := m.core.RequestContext()
+	m.requestCtx = ctx
+	m.cancelAsync = cancel
+	m.typewriter = m.core.NewTypewriter()
+	m.core.Agent.SetStreamHandler(m.typewriter.Write)
+	return tea.Batch(handlers.RequestResponseWithAgent(ctx, prompt, m.core.Agent), typewriterTick())
+}
+
+// cancelRunning cancels the running request or async command
+func (m *Model) cancelRunning() {
+	// The response is complete and only left to reveal; show it at once
+	if m.streamed != nil {
+		m.typewriter.Skip()
+		return
+	}
+	m.cancelAsync()
+	m.cancelAsync = nil
+	m.asyncProgress = nil
+	m.asyncStatus = "Cancelling..."
+}
+
+// selectorActive reports whether a list the keys navigate, such as the
+// model selector, is shown instead of the input
+func (m Model) selectorActive() bool {
+	return m.core.LLMState.IsModelSelectionActive() || m.core.LLMState.IsProviderSelectionActive() ||
+		m.core.HistoryPicker.IsActive() || m.core.SearchPicker.IsActive() || m.core.Viewer.IsActive() ||
+		m.core.Explorer.IsActive() || m.core.Palette.IsActive() || m.planReview != nil
+}
+
+// handleHistoryKey applies a key pressed in the /history list: running or
+// editing the chosen prompt, or deleting it from the history
+func (m Model) handleHistoryKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	action := handlers.HandleHistoryPickerKey(msg, m.core.HistoryPicker, m.core.ChatState, m.selectorPageSize())
+	if action.Delete != nil {
+		if err := m.core.DeleteHistoryEntry(*action.Delete); err != nil {
+			m.infoMessage = fmt.Sprintf("Couldn't delete the prompt: %v", err)
+		}
+	}
+	switch {
+	case action.Run != "":
+		m.input.SetValue(action.Run)
+		return m, m.submit()
+	case action.Edit != "":
+		m.input.SetValue(action.Edit)
+		m.input.CursorEnd()
+	}
+	return m, nil
+}
+
+// handleSearchKey applies a key pressed in the /search results. When the
+// UI owns the screen, an exchange of this conversation is scrolled to; others,
+// and those of saved conversations, are shown read-only.
+func (m Model) handleSearchKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	current := m.core.SearchPicker.GetCurrent()
+	action := handlers.HandleSearchPickerKey(msg, m.core.SearchPicker, m.core.ChatState, m.selectorPageSize())
+	hit := action.Open
+	if hit == nil {
+		return m, nil
+	}
+	if hit.Session.ID == current && m.fullScreen && m.height > 0 {
+		m.scrollToExchange(hit.Exchange)
+		return m, nil
+	}
+	m.core.Viewer.Open(hit.Session, hit.Exchange, hit.Session.ID == current)
+	return m, nil
+}
+
+// handlePlanKey applies a key pressed while the agent's plan waits for
+// approval: selecting, moving or removing steps, running the plan or
+// cancelling it
+func (m Model) handlePlanKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	review := m.planReview
+	switch msg.String() {
+	case "esc", "ctrl+c":
+		review.Cancel()
+		m.planReview = nil
+		m.plan = nil
+	case "enter":
+		review.Approve()
+		m.planReview = nil
+	case "up":
+		review.Select(-1)
+	case "down":
+		review.Select(1)
+	case "K", "shift+up":
+		review.MoveStep(-1)
+	case "J", "shift+down":
+		review.MoveStep(1)
+	case "delete", "x":
+		review.RemoveStep()
+	}
+	return m, nil
+}
+
+// submit sends the current input to the chat core and resets the input box
+func (m *Model) submit() tea.Cmd {
+	prompt := m.input.Value()
+
+	m.historyIndex = -1
+	m.currentInput = ""
+	m.input.SetValue("")
+	m.showCompletions = false
+	m.resetEdits()
+	if m.vi != nil {
+		m.vi.Reset()
+	}
+
+	return m.send(prompt)
+}
+
+// complete replaces the input with the selected suggestion
+func (m *Model) complete() {
+	completionValue := m.completionHandler.GetCompletionValue(m.completions, m.selectedCompletion)
+	if completionValue != "" {
+		m.input.SetValue(completionValue)
+		m.input.CursorEnd()
+		m.showCompletions = false
+		m.completions = []string{}
+	}
+}
+
+// submitQueued sends the oldest prompt typed while thinking, or returns nil
+// if there is none or the model is still busy
+func (m *Model) submitQueued() tea.Cmd {
+	if m.quitting || m.core.ChatState.IsThinking() || m.selectorActive() {
+		return nil
+	}
+	prompt, ok := m.core.Dequeue()
+	if !ok {
+		return nil
+	}
+	return m.send(prompt)
+}
+
+// send submits a prompt to the chat core
+func (m *Model) send(prompt string) tea.Cmd {
+	m.toolProgress = nil
+	m.plan = nil
+
+	result := m.core.Submit(prompt)
+	return tea.Batch(func() tea.Msg { return result }, m.spinner.Tick)
+}
+
+// notify writes a notification's escape sequences to the terminal. They
+// don't move the cursor, so they can go around the renderer.
+func notify(sequence string) tea.Cmd {
+	return func() tea.Msg {
+		fmt.Fprint(os.Stdout, sequence)
+		return nil
+	}
+}
+
+// waitForProgress delivers the next progress update of an async command.
+// It returns nil once the command has finished and closed the channel.
+func waitForProgress(progress <-chan string) tea.Cmd {
+	return func() tea.Msg {
+		text, ok := <-progress
+		if !ok {
+			return nil
+		}
+		return asyncProgressMsg{text: text, progress: progress}
+	}
+}
+
+// waitForToolProgress delivers the next line about the agent's tools. The
+// channel stays open for the life of the chat core.
+func waitForToolProgress(progress <-chan string) tea.Cmd {
+	if progress == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		text, ok := <-progress
+		if !ok {
+			return nil
+		}
+		return toolProgressMsg{text: text}
+	}
+}
+
+// waitForPlanReview delivers the next plan of the agent to be reviewed
+func waitForPlanReview(reviews *chat.PlanReviews) tea.Cmd {
+	return func() tea.Msg {
+		return planReviewMsg{review: <-reviews.Next()}
+	}
+}
+
+// navigateHistory moves through the input history in the given direction
+func (m *Model) navigateHistory(direction int) {
+	histState := &handlers.HistoryNavigationState{
+		InputHistory: m.core.InputHistory(),
+		HistoryIndex: m.historyIndex,
+		CurrentInput: m.currentInput,
+	}
+	handlers.NavigateHistory(direction, &m.input, histState)
+	m.historyIndex = histState.HistoryIndex
+	m.currentInput = histState.CurrentInput
+}
@@ -3,78 +3,261 @@ package terminal
 import (
 	"strings"
 
-	"github.com/mizzy/rigel/internal/command"
+	"github.com/mizzy/rigel/internal/state"
+	"github.com/mizzy/rigel/internal/ui/chat"
 	"github.com/mizzy/rigel/internal/ui/render"
+	"github.com/mizzy/rigel/lib/vi"
 )
 
+// Lines of the model selector besides the models: its headings, page
+// indicator, key help and a message below
+const selectorChrome = 9
+
+// minSelectorPageSize is the fewest models listed per page, however small
+// the terminal
+const minSelectorPageSize = 5
+
+// defaultSelectorPageSize is the models listed per page before the size of
+// the terminal is known
+const defaultSelectorPageSize = 20
+
 // View renders the chat interface
 func (m Model) View() string {
 	if m.quitting {
 		return ""
 	}
 
+	content, _ := m.content()
+	if m.fullScreen {
+		content = m.viewport(content)
+	}
+	if m.sidebarShown() {
+		return m.withSidebar(content)
+	}
+	return content
+}
+
+// content renders the whole chat interface and the lines of it that respond
+// to clicks
+func (m Model) content() (string, []clickTarget) {
 	var s strings.Builder
+	var targets []clickTarget
 
 	// Render chat history using extracted render function
-	history := m.chatState.GetHistory()
-	renderHistory := make([]render.Exchange, len(history))
-	for i, ex := range history {
-		renderHistory[i] = render.Exchange{
-			Prompt:   ex.Prompt,
-			Response: ex.Response,
-		}
-	}
-	s.WriteString(render.ChatHistory(renderHistory))
+	s.WriteString(render.ChatHistory(m.renderHistory(m.core.ChatState.GetHistory())))
 
 	// Display provider selection interface if in provider selection mode
-	if m.llmState.IsProviderSelectionActive() {
-		s.WriteString(render.ProviderSelector(m.llmState.GetAvailableProviders(), m.llmState.GetSelectedProviderIndex()))
+	if m.core.LLMState.IsProviderSelectionActive() {
+		// Providers are listed below a title, the filter if any, and blank
+		// lines
+		providers := m.core.LLMState.GetFilteredProviders()
+		first := lineCount(s.String()) + 2
+		if m.core.LLMState.GetProviderFilter() != "" {
+			first += 2
+		}
+		targets = listTargets(targetProvider, first, 0, len(providers))
+		s.WriteString(render.ProviderSelector(providers, m.core.LLMState.GetSelectedProviderIndex(), m.core.LLMState.GetProviderFilter()))
 		s.WriteString(render.InfoMessage(m.infoMessage))
-		s.WriteString(render.ErrorMessage(m.chatState.GetError()))
-		return s.String()
+		s.WriteString(m.errorView())
+		return s.String(), targets
 	}
 
 	// Display model selection interface if in model selection mode
-	if m.llmState.IsModelSelectionActive() {
-		s.WriteString(render.ModelSelector(m.llmState.GetFilteredModels(), m.llmState.GetSelectedModelIndex(), m.llmState.GetModelFilter()))
+	if m.core.LLMState.IsModelSelectionActive() {
+		// The page of models with the selected one is listed below the
+		// selector's headings
+		llmState := m.core.LLMState
+		models := llmState.GetFilteredModels()
+		pageSize := m.selectorPageSize()
+		page := llmState.GetSelectedModelIndex() / pageSize * pageSize
+		first := lineCount(s.String()) + render.ModelSelectorListLine(llmState.GetModelFilter())
+		targets = listTargets(targetModel, first, page, min(len(models)-page, pageSize))
+		s.WriteString(render.ModelSelector(models, llmState.GetSelectedModelIndex(), llmState.GetModelFilter(), llmState.GetModelSort().String(), pageSize))
+		s.WriteString(render.InfoMessage(m.infoMessage))
+		s.WriteString(m.errorView())
+		return s.String(), targets
+	}
+
+	// Display the /history list
+	if picker := m.core.HistoryPicker; picker.IsActive() {
+		entries := picker.GetFiltered()
+		pageSize := m.selectorPageSize()
+		page := picker.GetSelectedIndex() / pageSize * pageSize
+		first := lineCount(s.String()) + render.HistoryPickerListLine(picker.GetFilter())
+		targets = listTargets(targetHistory, first, page, min(len(entries)-page, pageSize))
+		s.WriteString(render.HistoryPicker(entries, picker.GetSelectedIndex(), picker.GetFilter(), pageSize))
+		s.WriteString(render.InfoMessage(m.infoMessage))
+		s.WriteString(m.errorView())
+		return s.String(), targets
+	}
+
+	// Display the /search results
+	if picker := m.core.SearchPicker; picker.IsActive() {
+		hits := picker.GetHits()
+		pageSize := m.selectorPageSize()
+		page := picker.GetSelectedIndex() / pageSize * pageSize
+		first := lineCount(s.String()) + render.SearchResultsListLine
+		targets = listTargets(targetSearch, first, page, min(len(hits)-page, pageSize))
+		s.WriteString(render.SearchResults(hits, picker.GetSelectedIndex(), picker.GetQuery(), picker.GetCurrent(), pageSize))
+		s.WriteString(render.InfoMessage(m.infoMessage))
+		s.WriteString(m.errorView())
+		return s.String(), targets
+	}
+
+	// Display the exchange opened from the /search results
+	if viewer := m.core.Viewer; viewer.IsActive() {
+		s.WriteString(render.SessionView(viewer.GetSession(), viewer.GetExchange(), viewer.IsCurrent()))
+		s.WriteString(render.InfoMessage(m.infoMessage))
+		s.WriteString(m.errorView())
+		return s.String(), targets
+	}
+
+	// Display the file explorer
+	if explorer := m.core.Explorer; explorer.IsActive() {
+		entries := explorer.GetEntries()
+		pageSize := m.selectorPageSize()
+		page := explorer.GetSelectedIndex() / pageSize * pageSize
+		first := lineCount(s.String()) + render.FileExplorerListLine
+		targets = listTargets(targetFile, first, page, min(len(entries)-page, pageSize))
+		s.WriteString(render.FileExplorer(explorer.GetDir(), entries, explorer.GetSelectedIndex(), explorer.IsMarked, len(explorer.GetMarked()), pageSize))
+		s.WriteString(render.InfoMessage(m.infoMessage))
+		s.WriteString(m.errorView())
+		return s.String(), targets
+	}
+
+	// Display the command palette
+	if palette := m.core.Palette; palette.IsActive() {
+		entries := palette.GetFiltered()
+		pageSize := m.selectorPageSize()
+		page := palette.GetSelectedIndex() / pageSize * pageSize
+		first := lineCount(s.String()) + render.CommandPaletteListLine(palette.GetFilter())
+		targets = listTargets(targetCommand, first, page, min(len(entries)-page, pageSize))
+		s.WriteString(render.CommandPalette(entries, palette.GetSelectedIndex(), palette.GetFilter(), pageSize))
 		s.WriteString(render.InfoMessage(m.infoMessage))
-		s.WriteString(render.ErrorMessage(m.chatState.GetError()))
-		return s.String()
+		s.WriteString(m.errorView())
+		return s.String(), targets
 	}
 
 	// Display thinking state
-	if m.chatState.IsThinking() {
-		s.WriteString(render.ThinkingState(m.chatState.GetCurrentPrompt(), m.spinner.View()))
+	if m.core.ChatState.IsThinking() {
+		status := m.asyncStatus
+		if status == "" {
+			status = m.core.RateLimitNotice()
+		}
+		if status == "" {
+			status = m.core.DeadlineNotice(m.requestCtx)
+		}
+		if status != "" {
+			s.WriteString(render.ThinkingStatus(m.core.ChatState.GetCurrentPrompt(), m.spinner.View(), status))
+		} else {
+			s.WriteString(render.ThinkingState(m.core.ChatState.GetCurrentPrompt(), m.spinner.View()))
+		}
+		if m.planReview != nil {
+			s.WriteString("\n")
+			s.WriteString(render.PlanReview(m.planReview.Plan.Steps(), m.planReview.Selected()))
+			s.WriteString(render.InfoMessage(m.infoMessage))
+			s.WriteString(m.errorView())
+			return s.String(), nil
+		}
+		if m.plan != nil {
+			s.WriteString(render.PlanChecklist(m.plan.Steps()))
+		}
+		s.WriteString(render.ToolProgress(m.toolProgress))
+		if m.typewriter != nil {
+			s.WriteString(render.StreamedResponse(m.typewriter.Shown()))
+		}
+
+		// Keep the input visible so the next prompt can be typed ahead
+		s.WriteString(render.QueuedPrompts(chat.QueueIndicator(m.core.Queued())))
+		s.WriteString("\n")
+		s.WriteString(m.inputPrompt())
 		s.WriteString(render.InfoMessage(m.infoMessage))
-		s.WriteString(render.ErrorMessage(m.chatState.GetError()))
-		return s.String()
+		s.WriteString(m.errorView())
+		return s.String(), nil
 	}
 
 	// Display input prompt and suggestions
-	if !m.chatState.IsThinking() {
-		// Display repository information above input prompt if available
-		if m.gitInfo != nil {
-			s.WriteString(render.RepoInfo(m.gitInfo.RepoName, m.gitInfo.Branch))
+	if !m.core.ChatState.IsThinking() {
+		// Display the welcome panel until the first prompt, then repository
+		// information above input prompt if available
+		if m.welcomeShown() {
+			s.WriteString(m.welcomeView())
+		} else if m.core.GitInfo != nil {
+			s.WriteString(render.RepoInfo(m.core.GitInfo.RepoName, m.core.GitInfo.Branch))
 		}
-		s.WriteString(render.InputPrompt(m.input.View()))
+		s.WriteString(m.inputPrompt())
 
 		// Display command completions using render function
 		if m.showCompletions && len(m.completions) > 0 {
 			// Convert commands to render.Command format
-			renderCommands := make([]render.Command, len(command.AvailableCommands))
-			for i, cmd := range command.AvailableCommands {
+			commands := m.core.Commands()
+			renderCommands := make([]render.Command, len(commands))
+			for i, cmd := range commands {
 				renderCommands[i] = render.Command{
 					Command:     cmd.Command,
 					Description: cmd.Description,
 				}
 			}
+			// Suggestions are listed below a blank line and a title
+			targets = listTargets(targetSuggestion, lineCount(s.String())+3, 0, len(m.completions))
 			s.WriteString(render.CommandSuggestions(m.completions, m.selectedCompletion, renderCommands))
 		}
 	}
 
 	// Display messages using render functions
 	s.WriteString(render.InfoMessage(m.infoMessage))
-	s.WriteString(render.ErrorMessage(m.chatState.GetError()))
+	s.WriteString(m.errorView())
 
-	return s.String()
+	return s.String(), targets
+}
+
+// Transcript renders the conversation as the chat showed it, to be printed
+// on the normal screen once the UI leaves the alternate one
+func (m Model) Transcript() string {
+	return render.ChatHistory(m.renderHistory(m.core.ChatState.GetHistory()))
+}
+
+// renderHistory prepares exchanges for rendering, with the model, latency
+// and tokens of their responses if /set show-meta turned them on
+func (m Model) renderHistory(history []state.Exchange) []render.Exchange {
+	showMeta := m.core.Config != nil && m.core.Config.ShowMeta
+	exchanges := make([]render.Exchange, len(history))
+	for i, ex := range history {
+		exchanges[i] = render.Exchange{Prompt: ex.Prompt, Response: ex.Response}
+		if showMeta && ex.Meta != nil {
+			exchanges[i].Footer = ex.Meta.String()
+		}
+	}
+	return exchanges
+}
+
+// errorView renders the last error, with a suggested action for provider
+// errors that have one
+func (m Model) errorView() string {
+	err := m.core.ChatState.GetError()
+	if err == nil {
+		return ""
+	}
+	desc := chat.DescribeError(err)
+	if desc.Hint == "" {
+		return render.ErrorMessage(err)
+	}
+	return render.ActionableError(desc.Title, desc.Detail, desc.Hint)
+}
+
+// inputPrompt renders the input area, marking vi normal mode
+func (m Model) inputPrompt() string {
+	if m.vi != nil && m.vi.Mode() == vi.Normal {
+		return render.NormalModeInputPrompt(m.input.View())
+	}
+	return render.InputPrompt(m.input.View())
+}
+
+// selectorPageSize returns how many entries the selectors list per page, so
+// that it fits the terminal
+func (m Model) selectorPageSize() int {
+	if m.height <= 0 {
+		return defaultSelectorPageSize
+	}
+	return max(m.height-selectorChrome, minSelectorPageSize)
 }
@@ -0,0 +1,78 @@
+package terminal
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mizzy/rigel/internal/config"
+	"github.com/mizzy/rigel/lib/vi"
+)
+
+// syncEditingMode creates or drops the vi editor when the editing mode is
+// changed, e.g. with /set editing-mode
+func (m *Model) syncEditingMode() {
+	enabled := m.core.Config != nil && m.core.Config.EditingMode == config.EditingModeVi
+	switch {
+	case enabled && m.vi == nil:
+		m.vi = vi.New()
+	case !enabled:
+		m.vi = nil
+	}
+}
+
+// handleViKey lets the vi editor handle a key. It returns the key to handle
+// as usual, which vi may have translated, such as the up arrow for k, or
+// false if vi consumed it. u undoes through the input's undo history.
+func (m *Model) handleViKey(msg tea.KeyMsg) (tea.KeyMsg, bool) {
+	value := m.input.Value()
+	cursor := m.inputCursor()
+	buf := vi.Buffer{Text: []rune(value), Cursor: cursor}
+
+	result := m.vi.HandleKey(msg.String(), &buf)
+	switch {
+	case result.Handled:
+		if string(buf.Text) != value || buf.Cursor != cursor {
+			m.setInput(buf)
+			m.completions, m.showCompletions = m.completionHandler.UpdateCompletions(m.input.Value())
+			m.selectedCompletion = 0
+		}
+		return msg, false
+	case result.Key == "undo":
+		m.undoEdit(false)
+		return msg, false
+	case result.Key == "up":
+		return tea.KeyMsg{Type: tea.KeyUp}, true
+	case result.Key == "down":
+		return tea.KeyMsg{Type: tea.KeyDown}, true
+	}
+	return msg, true
+}
+
+// inputCursor returns the cursor position in the input as a rune offset
+func (m *Model) inputCursor() int {
+	lines := strings.Split(m.input.Value(), "\n")
+	row := min(m.input.Line(), len(lines)-1)
+
+	offset := 0
+	for _, line := range lines[:row] {
+		offset += len([]rune(line)) + 1
+	}
+	info := m.input.LineInfo()
+	return offset + info.StartColumn + info.ColumnOffset
+}
+
+// setInput replaces the input with the buffer's text and moves the cursor
+// to the buffer's cursor
+func (m *Model) setInput(buf vi.Buffer) {
+	before := string(buf.Text[:buf.Cursor])
+	row := strings.Count(before, "\n")
+	col := len([]rune(before[strings.LastIndex(before, "\n")+1:]))
+
+	// SetValue leaves the cursor at the end; move up to the row, one
+	// wrapped line at a time
+	m.input.SetValue(string(buf.Text))
+	for i := 0; m.input.Line() > row && i <= len(buf.Text); i++ {
+		m.input.CursorUp()
+	}
+	m.input.SetCursor(col)
+}
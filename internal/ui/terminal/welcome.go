@@ -0,0 +1,44 @@
+package terminal
+
+import (
+	"strconv"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mizzy/rigel/internal/ui/render"
+)
+
+// welcomeShown reports whether the welcome panel is shown: until the
+// conversation has its first exchange
+func (m Model) welcomeShown() bool {
+	return len(m.core.ChatState.GetHistory()) == 0 && !m.core.ChatState.IsThinking()
+}
+
+// welcomeView renders the welcome panel, with the model switched to since
+// startup if any
+func (m Model) welcomeView() string {
+	panel := render.WelcomePanel{AgentsMD: m.welcome.AgentsMD}
+	if provider := m.core.LLMState.GetCurrentProvider(); provider != nil {
+		panel.Provider, panel.Model = provider.GetName(), m.core.LLMState.GetCurrentModel().Name
+	}
+	if gitInfo := m.core.GitInfo; gitInfo != nil {
+		panel.RepoName, panel.Branch = gitInfo.RepoName, gitInfo.Branch
+	}
+	for _, sess := range m.welcome.Sessions {
+		panel.Sessions = append(panel.Sessions, render.WelcomeSession{
+			Title:    sess.DisplayTitle(),
+			Updated:  sess.UpdatedAt,
+			Messages: len(sess.Exchanges),
+		})
+	}
+	return render.Welcome(panel)
+}
+
+// welcomeResume returns the command resuming the session listed under the
+// digit pressed, or "" if the key is not one
+func (m Model) welcomeResume(msg tea.KeyMsg) string {
+	n, err := strconv.Atoi(msg.String())
+	if err != nil {
+		return ""
+	}
+	return m.welcome.ResumeCommand(n)
+}
@@ -0,0 +1,85 @@
+package update
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mizzy/rigel/internal/github"
+)
+
+// CheckInterval is how often the startup notice asks GitHub for the latest
+// release
+const CheckInterval = 24 * time.Hour
+
+// lastCheck is the result of the last check, remembered between runs so
+// that startup doesn't wait for GitHub
+type lastCheck struct {
+	CheckedAt time.Time `json:"checked_at"`
+	Latest    string    `json:"latest"`
+}
+
+// CheckFilePath returns the file remembering the last check for a release
+func CheckFilePath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".rigel", "update-check.json"), nil
+}
+
+// Notice returns a one-line notice if the last check found a release newer
+// than current, and whether it is time to check again. The notice is empty
+// when there is nothing to announce.
+func Notice(current string) (notice string, due bool) {
+	check := readLastCheck()
+	due = time.Since(check.CheckedAt) >= CheckInterval
+	if check.Latest != "" && Newer(check.Latest, current) {
+		notice = fmt.Sprintf("rigel %s is available (you have %s); run `rigel update` to install it", check.Latest, current)
+	}
+	return notice, due
+}
+
+// Check asks GitHub for the latest release and remembers it for Notice
+func Check(ctx context.Context, client *github.Client) error {
+	latest, err := client.LatestRelease(ctx, Owner, Repo)
+	if err != nil {
+		return err
+	}
+	return writeLastCheck(lastCheck{CheckedAt: time.Now(), Latest: strings.TrimPrefix(latest.TagName, "v")})
+}
+
+// readLastCheck reads the last check; a missing or unreadable file reads
+// as never checked
+func readLastCheck() lastCheck {
+	var check lastCheck
+	path, err := CheckFilePath()
+	if err != nil {
+		return check
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return check
+	}
+	_ = json.Unmarshal(data, &check)
+	return check
+}
+
+func writeLastCheck(check lastCheck) error {
+	path, err := CheckFilePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(check)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
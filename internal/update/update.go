@@ -0,0 +1,305 @@
+// Package update finds newer releases of rigel on GitHub and replaces the
+// running executable with one, after verifying its checksum and, when
+// rigel was built with a release key, the signature of the checksums.
+package update
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mizzy/rigel/internal/github"
+)
+
+const (
+	// Owner and Repo are the GitHub repository rigel is released from
+	Owner = "mizzy"
+	Repo  = "rigel"
+
+	// checksumsAsset lists the SHA-256 of every other asset of a release,
+	// as sha256sum prints them, and signatureAsset is its base64 ed25519
+	// signature
+	checksumsAsset = "checksums.txt"
+	signatureAsset = "checksums.txt.sig"
+
+	// maxBinarySize bounds the size of a downloaded binary or archive
+	maxBinarySize = 200 << 20
+
+	downloadTimeout = 5 * time.Minute
+)
+
+// PublicKey is the base64 ed25519 key release checksums are signed with,
+// set at build time with -ldflags "-X .../update.PublicKey=...". Without it
+// only the checksums are verified.
+var PublicKey = ""
+
+// Release is a published release of rigel
+type Release struct {
+	Version string // Without the leading v
+	URL     string // Release page
+	assets  []github.Asset
+}
+
+// Latest returns the latest release, and whether it is newer than current
+func Latest(ctx context.Context, client *github.Client, current string) (*Release, bool, error) {
+	latest, err := client.LatestRelease(ctx, Owner, Repo)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to check for a new release: %w", err)
+	}
+	release := &Release{Version: strings.TrimPrefix(latest.TagName, "v"), URL: latest.HTMLURL, assets: latest.Assets}
+	return release, Newer(release.Version, current), nil
+}
+
+// Newer reports whether version a is newer than b, comparing the numbers of
+// major.minor.patch. A prerelease, such as 1.2.0-rc.1, is older than its
+// release, and versions that can't be parsed, such as dev builds, are never
+// newer or older.
+func Newer(a, b string) bool {
+	pa, prea, okA := parseVersion(a)
+	pb, preb, okB := parseVersion(b)
+	if !okA || !okB {
+		return false
+	}
+	for i := range pa {
+		if pa[i] != pb[i] {
+			return pa[i] > pb[i]
+		}
+	}
+	return prea == "" && preb != ""
+}
+
+func parseVersion(v string) ([3]int, string, bool) {
+	var parts [3]int
+	v, prerelease, _ := strings.Cut(strings.TrimPrefix(strings.TrimSpace(v), "v"), "-")
+	fields := strings.Split(v, ".")
+	if len(fields) == 0 || len(fields) > 3 {
+		return parts, "", false
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return parts, "", false
+		}
+		parts[i] = n
+	}
+	return parts, prerelease, true
+}
+
+// assetName returns the name of the release asset with the binary for a
+// platform, e.g. rigel_linux_amd64 or rigel_windows_amd64.exe
+func assetName(goos, goarch string) string {
+	name := fmt.Sprintf("rigel_%s_%s", goos, goarch)
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// binaryAsset finds the asset for this platform, as a bare binary or in a
+// .tar.gz archive
+func (r *Release) binaryAsset() (*github.Asset, error) {
+	name := assetName(runtime.GOOS, runtime.GOARCH)
+	for _, candidate := range []string{name, name + ".tar.gz"} {
+		for i := range r.assets {
+			if r.assets[i].Name == candidate {
+				return &r.assets[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("release %s has no binary for %s/%s", r.Version, runtime.GOOS, runtime.GOARCH)
+}
+
+func (r *Release) asset(name string) *github.Asset {
+	for i := range r.assets {
+		if r.assets[i].Name == name {
+			return &r.assets[i]
+		}
+	}
+	return nil
+}
+
+// Apply downloads the release's binary for this platform, verifies it and
+// atomically replaces the executable at exe with it
+func Apply(ctx context.Context, release *Release, exe string) error {
+	ctx, cancel := context.WithTimeout(ctx, downloadTimeout)
+	defer cancel()
+
+	asset, err := release.binaryAsset()
+	if err != nil {
+		return err
+	}
+	checksumsFile := release.asset(checksumsAsset)
+	if checksumsFile == nil {
+		return fmt.Errorf("release %s has no %s to verify the download with", release.Version, checksumsAsset)
+	}
+
+	checksums, err := download(ctx, checksumsFile.BrowserDownloadURL, 1<<20)
+	if err != nil {
+		return err
+	}
+	if err := verifySignature(ctx, release, checksums); err != nil {
+		return err
+	}
+	want, err := checksumFor(checksums, asset.Name)
+	if err != nil {
+		return err
+	}
+
+	data, err := download(ctx, asset.BrowserDownloadURL, maxBinarySize)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", asset.Name, want, got)
+	}
+	if strings.HasSuffix(asset.Name, ".tar.gz") {
+		if data, err = extractBinary(data); err != nil {
+			return fmt.Errorf("failed to extract %s: %w", asset.Name, err)
+		}
+	}
+	return replaceExecutable(exe, data)
+}
+
+// verifySignature checks the signature of the checksums against PublicKey,
+// if rigel was built with one
+func verifySignature(ctx context.Context, release *Release, checksums []byte) error {
+	if PublicKey == "" {
+		return nil
+	}
+	key, err := base64.StdEncoding.DecodeString(PublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid release public key")
+	}
+	signatureFile := release.asset(signatureAsset)
+	if signatureFile == nil {
+		return fmt.Errorf("release %s is not signed", release.Version)
+	}
+	encoded, err := download(ctx, signatureFile.BrowserDownloadURL, 4096)
+	if err != nil {
+		return err
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil || !ed25519.Verify(key, checksums, signature) {
+		return fmt.Errorf("the signature of release %s doesn't match its checksums", release.Version)
+	}
+	return nil
+}
+
+// checksumFor finds the SHA-256 of a file in sha256sum output
+func checksumFor(checksums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("%s has no checksum for %s", checksumsAsset, name)
+}
+
+// download fetches a URL, refusing responses larger than limit
+func download(ctx context.Context, url string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%s is larger than %d bytes", url, limit)
+	}
+	return data, nil
+}
+
+// extractBinary returns the rigel executable in a .tar.gz archive
+func extractBinary(archive []byte) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("no rigel executable in the archive")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if name := filepath.Base(header.Name); header.Typeflag == tar.TypeReg && (name == "rigel" || name == "rigel.exe") {
+			return io.ReadAll(io.LimitReader(tr, maxBinarySize))
+		}
+	}
+}
+
+// replaceExecutable writes the new binary next to exe and renames it over
+// exe, so that exe is never left half-written. Windows can't replace a
+// running executable, so the old one is moved aside first.
+func replaceExecutable(exe string, data []byte) error {
+	info, err := os.Stat(exe)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(exe)
+	tmp, err := os.CreateTemp(dir, ".rigel-update-*")
+	if err != nil {
+		return fmt.Errorf("can't write to %s: %w", dir, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()|0o111); err != nil {
+		return err
+	}
+
+	var old string
+	if runtime.GOOS == "windows" {
+		old = exe + ".old"
+		os.Remove(old)
+		if err := os.Rename(exe, old); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(tmp.Name(), exe); err != nil {
+		if old != "" {
+			// Put the running executable back rather than leave none
+			if rerr := os.Rename(old, exe); rerr != nil {
+				return fmt.Errorf("failed to replace %s: %w (it is left at %s)", exe, err, old)
+			}
+		}
+		return fmt.Errorf("failed to replace %s: %w", exe, err)
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package update
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mizzy/rigel/internal/github"
+)
+
+func TestNewer(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		newer bool
+	}{
+		{"0.2.0", "0.1.0", true},
+		{"v0.1.1", "0.1.0", true},
+		{"1.0", "0.9.9", true},
+		{"0.10.0", "0.9.0", true},
+		{"0.1.0", "0.1.0", false},
+		{"0.1.0", "v0.2.0", false},
+		{"1.2.0", "1.2.0-rc.1", true},
+		{"1.2.0-rc.1", "1.2.0", false},
+		{"0.2.0", "dev", false},
+		{"latest", "0.1.0", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.newer, Newer(tt.a, tt.b), "%s > %s", tt.a, tt.b)
+	}
+}
+
+// releaseServer serves the latest release with the given assets, mapping
+// names to contents, from /releases/<name>
+func releaseServer(t *testing.T, tag string, assets map[string][]byte) *httptest.Server {
+	t.Helper()
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/repos/mizzy/rigel/releases/latest" {
+			var list []string
+			for name := range assets {
+				list = append(list, fmt.Sprintf(`{"name":%q,"browser_download_url":"%s/releases/%s"}`, name, server.URL, name))
+			}
+			fmt.Fprintf(w, `{"tag_name":%q,"html_url":"https://github.com/mizzy/rigel/releases/%s","assets":[%s]}`, tag, tag, strings.Join(list, ","))
+			return
+		}
+		data, ok := assets[filepath.Base(r.URL.Path)]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func checksums(files map[string][]byte) []byte {
+	var b bytes.Buffer
+	for name, data := range files {
+		sum := sha256.Sum256(data)
+		fmt.Fprintf(&b, "%s  %s\n", hex.EncodeToString(sum[:]), name)
+	}
+	return b.Bytes()
+}
+
+// executable creates a fake rigel executable to be replaced
+func executable(t *testing.T) string {
+	t.Helper()
+	exe := filepath.Join(t.TempDir(), "rigel")
+	require.NoError(t, os.WriteFile(exe, []byte("old"), 0o755))
+	return exe
+}
+
+func TestLatest(t *testing.T) {
+	server := releaseServer(t, "v0.2.0", map[string][]byte{})
+	client := github.NewClient(server.URL, "")
+
+	release, newer, err := Latest(context.Background(), client, "0.1.0")
+	require.NoError(t, err)
+	assert.True(t, newer)
+	assert.Equal(t, "0.2.0", release.Version)
+	assert.Equal(t, "https://github.com/mizzy/rigel/releases/v0.2.0", release.URL)
+
+	_, newer, err = Latest(context.Background(), client, "0.2.0")
+	require.NoError(t, err)
+	assert.False(t, newer)
+}
+
+func TestApply(t *testing.T) {
+	name := assetName(runtime.GOOS, runtime.GOARCH)
+	binary := []byte("new rigel")
+	server := releaseServer(t, "v0.2.0", map[string][]byte{
+		name:           binary,
+		checksumsAsset: checksums(map[string][]byte{name: binary}),
+	})
+	release, _, err := Latest(context.Background(), github.NewClient(server.URL, ""), "0.1.0")
+	require.NoError(t, err)
+
+	exe := executable(t)
+	require.NoError(t, Apply(context.Background(), release, exe))
+	data, err := os.ReadFile(exe)
+	require.NoError(t, err)
+	assert.Equal(t, binary, data)
+	info, err := os.Stat(exe)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o755), info.Mode().Perm())
+
+	entries, err := os.ReadDir(filepath.Dir(exe))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "no temporary file is left behind")
+}
+
+func TestApplyArchive(t *testing.T) {
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	for name, data := range map[string]string{"README.md": "readme", "rigel": "new rigel"} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o755, Size: int64(len(data)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(data))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+
+	name := assetName(runtime.GOOS, runtime.GOARCH) + ".tar.gz"
+	server := releaseServer(t, "v0.2.0", map[string][]byte{
+		name:           archive.Bytes(),
+		checksumsAsset: checksums(map[string][]byte{name: archive.Bytes()}),
+	})
+	release, _, err := Latest(context.Background(), github.NewClient(server.URL, ""), "0.1.0")
+	require.NoError(t, err)
+
+	exe := executable(t)
+	require.NoError(t, Apply(context.Background(), release, exe))
+	data, err := os.ReadFile(exe)
+	require.NoError(t, err)
+	assert.Equal(t, "new rigel", string(data))
+}
+
+func TestApplyRejectsBadDownloads(t *testing.T) {
+	name := assetName(runtime.GOOS, runtime.GOARCH)
+	binary := []byte("new rigel")
+	sums := checksums(map[string][]byte{name: binary})
+
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	_, otherKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	sign := func(key ed25519.PrivateKey) []byte {
+		return []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(key, sums)))
+	}
+
+	tests := []struct {
+		name      string
+		publicKey string
+		assets    map[string][]byte
+		err       string
+	}{
+		{"tampered", "", map[string][]byte{name: []byte("evil"), checksumsAsset: sums}, "checksum mismatch for " + name},
+		{"no checksums", "", map[string][]byte{name: binary}, "release 0.2.0 has no checksums.txt"},
+		{"no binary", "", map[string][]byte{checksumsAsset: sums}, "release 0.2.0 has no binary for " + runtime.GOOS + "/" + runtime.GOARCH},
+		{"unsigned", base64.StdEncoding.EncodeToString(publicKey), map[string][]byte{name: binary, checksumsAsset: sums}, "release 0.2.0 is not signed"},
+		{"wrong key", base64.StdEncoding.EncodeToString(publicKey), map[string][]byte{name: binary, checksumsAsset: sums, signatureAsset: sign(otherKey)}, "the signature of release 0.2.0 doesn't match its checksums"},
+		{"signed", base64.StdEncoding.EncodeToString(publicKey), map[string][]byte{name: binary, checksumsAsset: sums, signatureAsset: sign(privateKey)}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			PublicKey = tt.publicKey
+			t.Cleanup(func() { PublicKey = "" })
+
+			server := releaseServer(t, "v0.2.0", tt.assets)
+			release, _, err := Latest(context.Background(), github.NewClient(server.URL, ""), "0.1.0")
+			require.NoError(t, err)
+
+			exe := executable(t)
+			err = Apply(context.Background(), release, exe)
+			data, _ := os.ReadFile(exe)
+			if tt.err == "" {
+				require.NoError(t, err)
+				assert.Equal(t, binary, data)
+				return
+			}
+			assert.ErrorContains(t, err, tt.err)
+			assert.Equal(t, "old", string(data), "the executable is left alone")
+		})
+	}
+}
+
+func TestNotice(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	notice, due := Notice("0.1.0")
+	assert.Empty(t, notice)
+	assert.True(t, due, "never checked")
+
+	server := releaseServer(t, "v0.2.0", map[string][]byte{})
+	require.NoError(t, Check(context.Background(), github.NewClient(server.URL, "")))
+
+	notice, due = Notice("0.1.0")
+	assert.Equal(t, "rigel 0.2.0 is available (you have 0.1.0); run `rigel update` to install it", notice)
+	assert.False(t, due)
+
+	notice, _ = Notice("0.2.0")
+	assert.Empty(t, notice)
+
+	require.NoError(t, writeLastCheck(lastCheck{CheckedAt: time.Now().Add(-CheckInterval), Latest: "v0.2.0"}))
+	_, due = Notice("0.2.0")
+	assert.True(t, due)
+}
@@ -0,0 +1,183 @@
+// Package workspace tracks the root directories the agent works in: the
+// current directory plus any extra roots added with --workspace or
+// /workspace add.
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// readOnlySuffix marks a root spec as read-only, as in ../other-service:ro
+const readOnlySuffix = ":ro"
+
+// Root is a directory the agent may read, and unless ReadOnly, write
+type Root struct {
+	Path     string // Absolute path
+	Label    string // Short name used to address the root, as label:path
+	ReadOnly bool
+}
+
+// Workspace is an ordered set of roots. The first root is the primary one,
+// which relative paths resolve against. It is safe for concurrent use.
+type Workspace struct {
+	mu    sync.RWMutex
+	roots []Root
+}
+
+// New creates a workspace whose primary root is dir
+func New(dir string) (*Workspace, error) {
+	return newWorkspace(dir, false)
+}
+
+// NewReadOnly creates a workspace whose primary root is dir, which the agent
+// may only read, as in a directory that isn't trusted
+func NewReadOnly(dir string) (*Workspace, error) {
+	return newWorkspace(dir, true)
+}
+
+func newWorkspace(dir string, readOnly bool) (*Workspace, error) {
+	root, err := newRoot(dir, readOnly)
+	if err != nil {
+		return nil, err
+	}
+	return &Workspace{roots: []Root{root}}, nil
+}
+
+func newRoot(dir string, readOnly bool) (Root, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return Root{}, err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return Root{}, fmt.Errorf("failed to add workspace root: %w", err)
+	}
+	if !info.IsDir() {
+		return Root{}, fmt.Errorf("workspace root is not a directory: %s", abs)
+	}
+	return Root{Path: filepath.Clean(abs), Label: filepath.Base(abs), ReadOnly: readOnly}, nil
+}
+
+// ParseSpec splits a root spec of the form path or path:ro
+func ParseSpec(spec string) (path string, readOnly bool) {
+	if strings.HasSuffix(spec, readOnlySuffix) {
+		return strings.TrimSuffix(spec, readOnlySuffix), true
+	}
+	return spec, false
+}
+
+// Add adds dir as a root and returns it. Its label is the directory name,
+// with a numeric suffix if another root already uses that name.
+func (w *Workspace) Add(dir string, readOnly bool) (Root, error) {
+	root, err := newRoot(dir, readOnly)
+	if err != nil {
+		return Root{}, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	labels := map[string]bool{}
+	for _, r := range w.roots {
+		if r.Path == root.Path {
+			return Root{}, fmt.Errorf("%s is already in the workspace as %s", root.Path, r.Label)
+		}
+		labels[r.Label] = true
+	}
+	base := root.Label
+	for i := 2; labels[root.Label]; i++ {
+		root.Label = fmt.Sprintf("%s-%d", base, i)
+	}
+
+	w.roots = append(w.roots, root)
+	return root, nil
+}
+
+// AddSpecs adds a root for each spec (path or path:ro). Roots that can't be
+// added are reported in the returned error; the others are still added.
+func (w *Workspace) AddSpecs(specs []string) error {
+	var errs []error
+	for _, spec := range specs {
+		path, readOnly := ParseSpec(spec)
+		if _, err := w.Add(path, readOnly); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Remove removes the root with the given label. The primary root can't be
+// removed.
+func (w *Workspace) Remove(label string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for i, r := range w.roots {
+		if r.Label != label {
+			continue
+		}
+		if i == 0 {
+			return fmt.Errorf("the primary root %s can't be removed", label)
+		}
+		w.roots = append(w.roots[:i], w.roots[i+1:]...)
+		return nil
+	}
+	return fmt.Errorf("no workspace root named %q", label)
+}
+
+// Roots returns the roots, primary first
+func (w *Workspace) Roots() []Root {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return append([]Root(nil), w.roots...)
+}
+
+// Primary returns the primary root
+func (w *Workspace) Primary() Root {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.roots[0]
+}
+
+// Resolve turns a path into an absolute path and returns the root it
+// belongs to. Paths may address a root by label, as label:path; other
+// relative paths resolve against the primary root. Paths outside every root
+// are returned with ok set to false.
+func (w *Workspace) Resolve(path string) (abs string, root Root, ok bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if label, rest, found := strings.Cut(path, ":"); found && !filepath.IsAbs(path) {
+		for _, r := range w.roots {
+			if r.Label == label {
+				abs = filepath.Join(r.Path, rest)
+				return abs, r, Within(r.Path, abs)
+			}
+		}
+	}
+
+	abs = path
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(w.roots[0].Path, abs)
+	}
+	abs = filepath.Clean(abs)
+
+	// Prefer the most specific root when roots are nested
+	for _, r := range w.roots {
+		if Within(r.Path, abs) && (!ok || len(r.Path) > len(root.Path)) {
+			root, ok = r, true
+		}
+	}
+	return abs, root, ok
+}
+
+// Within reports whether path is dir or inside it
+func Within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
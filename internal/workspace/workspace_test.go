@@ -0,0 +1,121 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSpec(t *testing.T) {
+	tests := []struct {
+		spec     string
+		path     string
+		readOnly bool
+	}{
+		{"../other", "../other", false},
+		{"../other:ro", "../other", true},
+		{"/abs/path", "/abs/path", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			path, readOnly := ParseSpec(tt.spec)
+			assert.Equal(t, tt.path, path)
+			assert.Equal(t, tt.readOnly, readOnly)
+		})
+	}
+}
+
+func TestWorkspace(t *testing.T) {
+	base := t.TempDir()
+	primary := filepath.Join(base, "app")
+	other := filepath.Join(base, "service")
+	nested := filepath.Join(base, "nested", "service")
+	for _, dir := range []string{primary, other, nested} {
+		require.NoError(t, os.MkdirAll(dir, 0o755))
+	}
+
+	ws, err := New(primary)
+	require.NoError(t, err)
+	assert.Equal(t, "app", ws.Primary().Label)
+
+	root, err := ws.Add(other, false)
+	require.NoError(t, err)
+	assert.Equal(t, "service", root.Label)
+
+	t.Run("duplicate labels get a suffix", func(t *testing.T) {
+		root, err := ws.Add(nested, true)
+		require.NoError(t, err)
+		assert.Equal(t, "service-2", root.Label)
+		assert.True(t, root.ReadOnly)
+	})
+
+	t.Run("rejects a root added twice", func(t *testing.T) {
+		_, err := ws.Add(other, false)
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects missing directories", func(t *testing.T) {
+		_, err := ws.Add(filepath.Join(base, "missing"), false)
+		assert.Error(t, err)
+	})
+
+	t.Run("resolve", func(t *testing.T) {
+		tests := []struct {
+			path  string
+			abs   string
+			label string
+			ok    bool
+		}{
+			{"main.go", filepath.Join(primary, "main.go"), "app", true},
+			{"service:cmd/main.go", filepath.Join(other, "cmd", "main.go"), "service", true},
+			{"service-2:go.mod", filepath.Join(nested, "go.mod"), "service-2", true},
+			{filepath.Join(other, "go.mod"), filepath.Join(other, "go.mod"), "service", true},
+			{"service:../escape", filepath.Join(base, "escape"), "service", false},
+			{filepath.Join(base, "elsewhere"), filepath.Join(base, "elsewhere"), "", false},
+			{"unknown:file", filepath.Join(primary, "unknown:file"), "app", true},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.path, func(t *testing.T) {
+				abs, root, ok := ws.Resolve(tt.path)
+				assert.Equal(t, tt.abs, abs)
+				assert.Equal(t, tt.ok, ok)
+				if tt.ok {
+					assert.Equal(t, tt.label, root.Label)
+				}
+			})
+		}
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		assert.Error(t, ws.Remove("app"), "primary root can't be removed")
+		assert.Error(t, ws.Remove("missing"))
+		require.NoError(t, ws.Remove("service-2"))
+
+		var labels []string
+		for _, root := range ws.Roots() {
+			labels = append(labels, root.Label)
+		}
+		assert.Equal(t, []string{"app", "service"}, labels)
+	})
+}
+
+func TestAddSpecs(t *testing.T) {
+	base := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(base, "lib"), 0o755))
+
+	ws, err := New(base)
+	require.NoError(t, err)
+
+	err = ws.AddSpecs([]string{filepath.Join(base, "lib") + ":ro", filepath.Join(base, "missing")})
+	assert.Error(t, err, "missing directories are reported")
+
+	roots := ws.Roots()
+	require.Len(t, roots, 2, "valid roots are still added")
+	assert.Equal(t, "lib", roots[1].Label)
+	assert.True(t, roots[1].ReadOnly)
+}
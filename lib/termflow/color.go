@@ -0,0 +1,222 @@
+package termflow
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// ColorProfile describes how many colors the terminal supports
+type ColorProfile int
+
+const (
+	// ProfileNoColor disables all styling
+	ProfileNoColor ColorProfile = iota
+	// ProfileANSI uses the basic 16 colors
+	ProfileANSI
+	// ProfileANSI256 uses the 256 color palette
+	ProfileANSI256
+	// ProfileTrueColor uses 24-bit colors
+	ProfileTrueColor
+)
+
+// Color is a terminal color with fallbacks for less capable terminals
+type Color struct {
+	ANSI    int    // Basic color index (0-15)
+	ANSI256 int    // 256 color palette index
+	Hex     string // Optional 24-bit color such as "#5793ff"; falls back to ANSI256
+}
+
+// Style is a text style with separate colors for dark and light backgrounds
+type Style struct {
+	Bold   bool
+	Italic bool
+	Dark   Color
+	Light  Color
+}
+
+// Role identifies what a piece of text is, so its style can follow the palette
+type Role int
+
+const (
+	RoleAccent   Role = iota // Prompt symbol and spinner
+	RoleInput                // Echoed user input
+	RoleOutput               // Assistant responses
+	RoleThinking             // Thinking indicator
+	RoleInfo                 // Info and hint messages
+	RoleMuted                // Labels in the welcome banner
+	RoleError                // Error messages
+	RoleRepo                 // Repository name
+	RoleBranch               // Branch name
+	RoleHeading              // Bold headings
+	RoleAdded                // Lines added in a diff
+	RoleRemoved              // Lines removed in a diff
+)
+
+// DefaultStyles is the termflow palette, matching the Bubbletea UI
+var DefaultStyles = map[Role]Style{
+	RoleAccent:   {Bold: true, Dark: Color{ANSI: 14, ANSI256: 87}, Light: Color{ANSI: 4, ANSI256: 25}},
+	RoleInput:    {Dark: Color{ANSI: 15, ANSI256: 195}, Light: Color{ANSI: 4, ANSI256: 18}},
+	RoleOutput:   {Dark: Color{ANSI: 7, ANSI256: 252}, Light: Color{ANSI: 0, ANSI256: 235}},
+	RoleThinking: {Italic: true, Dark: Color{ANSI: 6, ANSI256: 117}, Light: Color{ANSI: 6, ANSI256: 31}},
+	RoleInfo:     {Dark: Color{ANSI: 8, ANSI256: 240}, Light: Color{ANSI: 8, ANSI256: 244}},
+	RoleMuted:    {Dark: Color{ANSI: 8, ANSI256: 244}, Light: Color{ANSI: 8, ANSI256: 244}},
+	RoleError:    {Dark: Color{ANSI: 9, ANSI256: 196}, Light: Color{ANSI: 1, ANSI256: 160}},
+	RoleRepo:     {Dark: Color{ANSI: 12, ANSI256: 69, Hex: "#5793ff"}, Light: Color{ANSI: 4, ANSI256: 26, Hex: "#1f5fd1"}},
+	RoleBranch:   {Dark: Color{ANSI: 6, ANSI256: 117}, Light: Color{ANSI: 6, ANSI256: 31}},
+	RoleHeading:  {Bold: true},
+	RoleAdded:    {Dark: Color{ANSI: 10, ANSI256: 82}, Light: Color{ANSI: 2, ANSI256: 28}},
+	RoleRemoved:  {Dark: Color{ANSI: 9, ANSI256: 203}, Light: Color{ANSI: 1, ANSI256: 160}},
+}
+
+// Colors renders styled text for a given color profile and background
+type Colors struct {
+	profile ColorProfile
+	light   bool
+	styles  map[Role]Style
+}
+
+// NewColors creates a colorizer with an explicit profile and background
+func NewColors(profile ColorProfile, lightBackground bool) *Colors {
+	return &Colors{
+		profile: profile,
+		light:   lightBackground,
+		styles:  DefaultStyles,
+	}
+}
+
+// DetectColors inspects the environment and output to pick a color profile.
+// NO_COLOR, TERM=dumb and non-terminal outputs disable colors; COLORTERM and
+// TERM select between 16, 256 and 24-bit colors; COLORFGBG picks the background.
+func DetectColors(out io.Writer) *Colors {
+	return NewColors(detectProfile(out), detectLightBackground())
+}
+
+func detectProfile(out io.Writer) ColorProfile {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return ProfileNoColor
+	}
+
+	// Windows consoles interpret escape sequences only once asked to
+	if f, ok := out.(*os.File); ok && (!term.IsTerminal(int(f.Fd())) || !enableVirtualTerminal(f)) {
+		return ProfileNoColor
+	}
+
+	termEnv := os.Getenv("TERM")
+	if termEnv == "dumb" {
+		return ProfileNoColor
+	}
+
+	switch strings.ToLower(os.Getenv("COLORTERM")) {
+	case "truecolor", "24bit":
+		return ProfileTrueColor
+	}
+
+	// Windows Terminal sets no TERM but supports 24-bit colors
+	if os.Getenv("WT_SESSION") != "" {
+		return ProfileTrueColor
+	}
+
+	if strings.Contains(termEnv, "256color") {
+		return ProfileANSI256
+	}
+	return ProfileANSI
+}
+
+// detectLightBackground reads COLORFGBG ("fg;bg"), set by many terminals
+func detectLightBackground() bool {
+	parts := strings.Split(os.Getenv("COLORFGBG"), ";")
+	bg, err := strconv.Atoi(parts[len(parts)-1])
+	if err != nil {
+		return false
+	}
+	// Colors 0-6 and 8 are dark backgrounds
+	return bg == 7 || bg > 8
+}
+
+// Profile returns the active color profile
+func (c *Colors) Profile() ColorProfile {
+	return c.profile
+}
+
+// SetProfile overrides the detected color profile
+func (c *Colors) SetProfile(profile ColorProfile) {
+	c.profile = profile
+}
+
+// SetLightBackground overrides the detected background
+func (c *Colors) SetLightBackground(light bool) {
+	c.light = light
+}
+
+// Paint renders text in the style for the given role
+func (c *Colors) Paint(role Role, text string) string {
+	if c.profile == ProfileNoColor {
+		return text
+	}
+
+	style := c.styles[role]
+	color := style.Dark
+	if c.light {
+		color = style.Light
+	}
+
+	var codes []string
+	if style.Bold {
+		codes = append(codes, "1")
+	}
+	if style.Italic {
+		codes = append(codes, "3")
+	}
+	if fg := c.foreground(color); fg != "" {
+		codes = append(codes, fg)
+	}
+	if len(codes) == 0 {
+		return text
+	}
+
+	return "\033[" + strings.Join(codes, ";") + "m" + text + "\033[0m"
+}
+
+// Sprintf formats and paints text in the style for the given role
+func (c *Colors) Sprintf(role Role, format string, args ...interface{}) string {
+	return c.Paint(role, fmt.Sprintf(format, args...))
+}
+
+// foreground returns the SGR parameters selecting the color
+func (c *Colors) foreground(color Color) string {
+	if color == (Color{}) {
+		return ""
+	}
+
+	switch c.profile {
+	case ProfileTrueColor:
+		if r, g, b, ok := parseHex(color.Hex); ok {
+			return fmt.Sprintf("38;2;%d;%d;%d", r, g, b)
+		}
+		return fmt.Sprintf("38;5;%d", color.ANSI256)
+	case ProfileANSI256:
+		return fmt.Sprintf("38;5;%d", color.ANSI256)
+	default:
+		if color.ANSI >= 8 {
+			return strconv.Itoa(90 + color.ANSI - 8)
+		}
+		return strconv.Itoa(30 + color.ANSI)
+	}
+}
+
+// parseHex parses a "#rrggbb" color
+func parseHex(hex string) (r, g, b uint8, ok bool) {
+	if len(hex) != 7 || hex[0] != '#' {
+		return 0, 0, 0, false
+	}
+	v, err := strconv.ParseUint(hex[1:], 16, 32)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	return uint8(v >> 16), uint8(v >> 8), uint8(v), true
+}
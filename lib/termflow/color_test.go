@@ -0,0 +1,84 @@
+package termflow
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestColorsPaint(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile ColorProfile
+		light   bool
+		role    Role
+		want    string
+	}{
+		{name: "no color", profile: ProfileNoColor, role: RoleAccent, want: "x"},
+		{name: "256 colors", profile: ProfileANSI256, role: RoleAccent, want: "\033[1;38;5;87mx\033[0m"},
+		{name: "256 colors light", profile: ProfileANSI256, light: true, role: RoleAccent, want: "\033[1;38;5;25mx\033[0m"},
+		{name: "basic colors", profile: ProfileANSI, role: RoleError, want: "\033[91mx\033[0m"},
+		{name: "true color hex", profile: ProfileTrueColor, role: RoleRepo, want: "\033[38;2;87;147;255mx\033[0m"},
+		{name: "true color without hex", profile: ProfileTrueColor, role: RoleOutput, want: "\033[38;5;252mx\033[0m"},
+		{name: "bold only", profile: ProfileANSI256, role: RoleHeading, want: "\033[1mx\033[0m"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewColors(tt.profile, tt.light).Paint(tt.role, "x")
+			if got != tt.want {
+				t.Errorf("Paint() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectProfile(t *testing.T) {
+	tests := []struct {
+		name      string
+		noColor   bool
+		term      string
+		colorterm string
+		wtSession string
+		want      ColorProfile
+	}{
+		{name: "NO_COLOR", noColor: true, term: "xterm-256color", want: ProfileNoColor},
+		{name: "dumb terminal", term: "dumb", want: ProfileNoColor},
+		{name: "truecolor", term: "xterm-256color", colorterm: "truecolor", want: ProfileTrueColor},
+		{name: "256 colors", term: "xterm-256color", want: ProfileANSI256},
+		{name: "basic", term: "xterm", want: ProfileANSI},
+		{name: "Windows Terminal", wtSession: "1", want: ProfileTrueColor},
+	}
+
+	// Start from a clean environment even if NO_COLOR is set by the caller
+	if value, ok := os.LookupEnv("NO_COLOR"); ok {
+		os.Unsetenv("NO_COLOR")
+		t.Cleanup(func() { os.Setenv("NO_COLOR", value) })
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.noColor {
+				t.Setenv("NO_COLOR", "1")
+			}
+			t.Setenv("TERM", tt.term)
+			t.Setenv("COLORTERM", tt.colorterm)
+			t.Setenv("WT_SESSION", tt.wtSession)
+
+			// A buffer is not an *os.File, so the terminal check is skipped
+			if got := detectProfile(&bytes.Buffer{}); got != tt.want {
+				t.Errorf("detectProfile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectLightBackground(t *testing.T) {
+	tests := map[string]bool{"15;0": false, "0;15": true, "0;7": true, "": false, "default;default": false}
+	for value, want := range tests {
+		t.Setenv("COLORFGBG", value)
+		if got := detectLightBackground(); got != want {
+			t.Errorf("COLORFGBG=%q: got %v, want %v", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package termflow
+
+import "os"
+
+// enableVirtualTerminal reports whether the terminal f interprets escape
+// sequences, which terminals other than Windows consoles always do
+func enableVirtualTerminal(f *os.File) bool {
+	return true
+}
@@ -0,0 +1,33 @@
+package termflow
+
+import "strings"
+
+// paintResponse paints an AI response in the output color, except for the
+// lines of ```diff code blocks, which are colored as a diff
+func (c *Client) paintResponse(response string) string {
+	if !strings.Contains(response, "```diff") {
+		return c.colors.Paint(RoleOutput, response)
+	}
+
+	lines := strings.Split(response, "\n")
+	inDiff, inHeader := false, false
+	for i, line := range lines {
+		role := RoleOutput
+		switch {
+		case strings.HasPrefix(line, "```"):
+			inDiff = !inDiff && strings.TrimSpace(strings.TrimPrefix(line, "```")) == "diff"
+			inHeader = inDiff
+		case !inDiff:
+		case strings.HasPrefix(line, "@@"):
+			role, inHeader = RoleInfo, false
+		case inHeader:
+			role = RoleHeading
+		case strings.HasPrefix(line, "+"):
+			role = RoleAdded
+		case strings.HasPrefix(line, "-"):
+			role = RoleRemoved
+		}
+		lines[i] = c.colors.Paint(role, line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,43 @@
+package termflow
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPrintResponseColorsDiffs(t *testing.T) {
+	var out bytes.Buffer
+	c := New()
+	c.output = &out
+	c.SetColors(NewColors(ProfileANSI256, false))
+
+	c.PrintResponse("Changed:\n```diff\n--- a/x\n+++ b/x\n@@ -1,2 +1,2 @@\n same\n-old\n+new\n```\n-not a diff")
+
+	want := "\n" +
+		"\033[38;5;252mChanged:\033[0m\n" +
+		"\033[38;5;252m```diff\033[0m\n" +
+		"\033[1m--- a/x\033[0m\n" +
+		"\033[1m+++ b/x\033[0m\n" +
+		"\033[38;5;240m@@ -1,2 +1,2 @@\033[0m\n" +
+		"\033[38;5;252m same\033[0m\n" +
+		"\033[38;5;203m-old\033[0m\n" +
+		"\033[38;5;82m+new\033[0m\n" +
+		"\033[38;5;252m```\033[0m\n" +
+		"\033[38;5;252m-not a diff\033[0m\n\n"
+	if got := out.String(); got != want {
+		t.Errorf("PrintResponse() = %q, want %q", got, want)
+	}
+}
+
+func TestPrintResponseWithoutDiff(t *testing.T) {
+	var out bytes.Buffer
+	c := New()
+	c.output = &out
+	c.SetColors(NewColors(ProfileANSI256, false))
+
+	c.PrintResponse("-1\n+1")
+
+	if got, want := out.String(), "\n\033[38;5;252m-1\n+1\033[0m\n\n"; got != want {
+		t.Errorf("PrintResponse() = %q, want %q", got, want)
+	}
+}
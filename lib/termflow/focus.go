@@ -0,0 +1,19 @@
+package termflow
+
+import "fmt"
+
+// ReportFocus asks the terminal to report when it gains or loses focus, or
+// to stop. Reports are picked up while keys are read.
+func (ic *InteractiveClient) ReportFocus(enabled bool) {
+	if enabled {
+		fmt.Fprint(ic.output, "\033[?1004h")
+	} else {
+		fmt.Fprint(ic.output, "\033[?1004l")
+	}
+}
+
+// Focused reports whether the terminal has focus. Terminals that don't
+// report focus always count as focused.
+func (ic *InteractiveClient) Focused() bool {
+	return !ic.lineEditor.keyboard.unfocused.Load()
+}
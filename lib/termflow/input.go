@@ -138,6 +138,17 @@ func (ic *InteractiveClient) ReadLineOrMultiLineWithoutPrompt() (string, error)
 	return line, nil
 }
 
+// SetInitialInput pre-fills the next line read with text the user can edit
+func (ic *InteractiveClient) SetInitialInput(text string) {
+	ic.lineEditor.SetInitialLine(text)
+}
+
+// OnInputChange sets a function called with the line being typed each time
+// a key is handled, e.g. to save it as a draft, or clears it with nil
+func (ic *InteractiveClient) OnInputChange(fn func(line string)) {
+	ic.lineEditor.onChange = fn
+}
+
 // Close cleans up the interactive client
 func (ic *InteractiveClient) Close() error {
 	if ic.rawMode {
@@ -170,12 +181,12 @@ func (ic *InteractiveClient) ShowCompletions(input string, completions []string)
 
 // ShowError displays an error message
 func (ic *InteractiveClient) ShowError(err error) {
-	ic.Printf("\n\n\033[38;5;196mError: %v\033[0m", err)
+	ic.Printf("\n\n%s", ic.colors.Sprintf(RoleError, "Error: %v", err))
 }
 
 // ShowInfo displays an info message
 func (ic *InteractiveClient) ShowInfo(message string) {
-	ic.Printf("\n\n\033[38;5;240m%s\033[0m\n", message)
+	ic.Printf("\n\n%s\n", ic.colors.Paint(RoleInfo, message))
 }
 
 // ShowInfoInline displays an info message while preserving current cursor position
@@ -185,7 +196,7 @@ func (ic *InteractiveClient) ShowInfoInline(message string) {
 	fmt.Fprintf(ic.output, "\0337") // Save cursor position (ESC 7)
 
 	// Move to next line and show the message
-	fmt.Fprintf(ic.output, "\n\033[38;5;240m%s\033[0m", message)
+	fmt.Fprintf(ic.output, "\n%s", ic.colors.Paint(RoleInfo, message))
 
 	// Restore cursor to original position
 	fmt.Fprintf(ic.output, "\0338") // Restore cursor position (ESC 8)
@@ -193,7 +204,7 @@ func (ic *InteractiveClient) ShowInfoInline(message string) {
 
 // ShowThinking displays a thinking indicator
 func (ic *InteractiveClient) ShowThinking(message string) {
-	ic.Printf("\n\033[3;38;5;117m%s\033[0m\n", message)
+	ic.Printf("\n%s\n", ic.colors.Paint(RoleThinking, message))
 }
 
 // ShowThinkingWithSpinner displays a thinking indicator with animated spinner
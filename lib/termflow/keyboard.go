@@ -1,16 +1,31 @@
 package termflow
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"golang.org/x/term"
 )
 
+// escapeTimeout is how long to wait for the rest of an escape sequence
+// before treating Esc as a key of its own
+const escapeTimeout = 50 * time.Millisecond
+
+// ErrStopped is returned by ReadKeyUntil when it is stopped before a key
+// is pressed
+var ErrStopped = errors.New("stopped")
+
 // Key represents a keyboard key
 type Key struct {
 	Type KeyType
 	Rune rune
+	Text string // Pasted text for KeyPaste, with newlines as "\n"
 }
 
 // KeyType represents the type of key pressed
@@ -31,6 +46,14 @@ const (
 	KeyCtrlD
 	KeyCtrlJ
 	KeyEscape
+	KeyPageUp
+	KeyPageDown
+	KeyPaste
+	KeyEnd
+	KeyCtrlEnd
+	KeyHome
+	KeyCtrlUnderscore
+	KeyCtrlZ
 )
 
 // String returns a string representation of the key
@@ -62,6 +85,22 @@ func (k Key) String() string {
 		return "Ctrl+J"
 	case KeyEscape:
 		return "Escape"
+	case KeyPageUp:
+		return "PageUp"
+	case KeyPageDown:
+		return "PageDown"
+	case KeyPaste:
+		return fmt.Sprintf("Paste(%q)", k.Text)
+	case KeyEnd:
+		return "End"
+	case KeyCtrlEnd:
+		return "Ctrl+End"
+	case KeyHome:
+		return "Home"
+	case KeyCtrlUnderscore:
+		return "Ctrl+_"
+	case KeyCtrlZ:
+		return "Ctrl+Z"
 	default:
 		return "Unknown"
 	}
@@ -72,6 +111,16 @@ type KeyboardReader struct {
 	fd       int
 	oldState *term.State
 	rawMode  bool
+
+	// Stdin is read by a single goroutine so a reader waiting for a key can
+	// give up without leaving a blocked read that would steal the next one
+	startOnce sync.Once
+	input     chan byte
+	inputErr  error
+	unread    []byte // Bytes read ahead that belong to the next key
+
+	// Set when the terminal reports losing focus; see ReportFocus
+	unfocused atomic.Bool
 }
 
 // NewKeyboardReader creates a new keyboard reader
@@ -116,20 +165,70 @@ func (kr *KeyboardReader) DisableRawMode() error {
 	return err
 }
 
+// start begins reading stdin into the input channel
+func (kr *KeyboardReader) start() {
+	kr.startOnce.Do(func() {
+		kr.input = make(chan byte, 256)
+		go func() {
+			buf := make([]byte, 256)
+			for {
+				n, err := os.Stdin.Read(buf)
+				for _, b := range buf[:n] {
+					kr.input <- b
+				}
+				if err != nil {
+					kr.inputErr = err
+					close(kr.input)
+					return
+				}
+			}
+		}()
+	})
+}
+
+// readByte returns the next byte of input
+func (kr *KeyboardReader) readByte() (byte, error) {
+	return kr.readByteUntil(nil)
+}
+
+// readByteUntil returns the next byte of input, or ErrStopped if stop is
+// closed first
+func (kr *KeyboardReader) readByteUntil(stop <-chan struct{}) (byte, error) {
+	if len(kr.unread) > 0 {
+		b := kr.unread[0]
+		kr.unread = kr.unread[1:]
+		return b, nil
+	}
+
+	kr.start()
+	select {
+	case b, ok := <-kr.input:
+		if !ok {
+			return 0, kr.inputErr
+		}
+		return b, nil
+	case <-stop:
+		return 0, ErrStopped
+	}
+}
+
 // ReadKey reads a single key press
 func (kr *KeyboardReader) ReadKey() (Key, error) {
+	return kr.ReadKeyUntil(nil)
+}
+
+// ReadKeyUntil reads a single key press, or returns ErrStopped if stop is
+// closed before a key is pressed
+func (kr *KeyboardReader) ReadKeyUntil(stop <-chan struct{}) (Key, error) {
 	if !kr.rawMode {
 		return Key{}, fmt.Errorf("raw mode not enabled")
 	}
 
-	buf := make([]byte, 1)
-	_, err := os.Stdin.Read(buf)
+	b, err := kr.readByteUntil(stop)
 	if err != nil {
 		return Key{}, err
 	}
 
-	b := buf[0]
-
 	// Handle special keys
 	switch b {
 	case 3: // Ctrl+C
@@ -142,6 +241,10 @@ func (kr *KeyboardReader) ReadKey() (Key, error) {
 		return Key{Type: KeyCtrlJ}, nil
 	case 13: // Enter (CR)
 		return Key{Type: KeyEnter}, nil
+	case 26: // Ctrl+Z; it doesn't suspend in raw mode
+		return Key{Type: KeyCtrlZ}, nil
+	case 31: // Ctrl+_, also sent for Ctrl+/ and Ctrl+7 by many terminals
+		return Key{Type: KeyCtrlUnderscore}, nil
 	case 127, 8: // Backspace (DEL or BS)
 		return Key{Type: KeyBackspace}, nil
 	case 27: // Escape sequence
@@ -157,26 +260,33 @@ func (kr *KeyboardReader) ReadKey() (Key, error) {
 
 // readEscapeSequence reads and parses escape sequences (like arrow keys)
 func (kr *KeyboardReader) readEscapeSequence() (Key, error) {
-	// Read next byte to see if it's part of a sequence
-	buf := make([]byte, 1)
-	_, err := os.Stdin.Read(buf)
+	// Read next byte to see if it's part of a sequence. A sequence arrives
+	// at once; if nothing follows soon, Esc was pressed on its own.
+	timeout := make(chan struct{})
+	timer := time.AfterFunc(escapeTimeout, func() { close(timeout) })
+	b, err := kr.readByteUntil(timeout)
+	timer.Stop()
 	if err != nil {
 		return Key{Type: KeyEscape}, nil // Just escape key
 	}
 
-	if buf[0] != '[' {
-		// Not an ANSI escape sequence, just escape
+	if b == 'O' {
+		return kr.readSS3(), nil
+	}
+	if b != '[' {
+		// Not an ANSI escape sequence, just escape followed by another key
+		kr.unread = append(kr.unread, b)
 		return Key{Type: KeyEscape}, nil
 	}
 
 	// Read the final byte of the sequence
-	_, err = os.Stdin.Read(buf)
+	b, err = kr.readByte()
 	if err != nil {
 		return Key{Type: KeyEscape}, nil
 	}
 
 	// Parse arrow keys and other sequences
-	switch buf[0] {
+	switch b {
 	case 'A':
 		return Key{Type: KeyArrowUp}, nil
 	case 'B':
@@ -185,15 +295,107 @@ func (kr *KeyboardReader) readEscapeSequence() (Key, error) {
 		return Key{Type: KeyArrowRight}, nil
 	case 'D':
 		return Key{Type: KeyArrowLeft}, nil
-	case '3':
-		// Delete key sends ESC[3~, read the ~
-		buf2 := make([]byte, 1)
-		os.Stdin.Read(buf2)
-		if buf2[0] == '~' {
-			return Key{Type: KeyDelete}, nil
-		}
+	case 'I', 'O':
+		// Focus in and out, reported once enabled with ReportFocus
+		kr.unfocused.Store(b == 'O')
 		return Key{Type: KeyUnknown}, nil
+	case 'F':
+		return Key{Type: KeyEnd}, nil
+	case 'H':
+		return Key{Type: KeyHome}, nil
+	case '3', '4', '5', '6':
+		// Delete, End, Page Up and Page Down send ESC[3~, ESC[4~, ESC[5~
+		// and ESC[6~
+		if next, _ := kr.readByte(); next != '~' {
+			return Key{Type: KeyUnknown}, nil
+		}
+		return Key{Type: map[byte]KeyType{'3': KeyDelete, '4': KeyEnd, '5': KeyPageUp, '6': KeyPageDown}[b]}, nil
+	case '1':
+		// Home sends ESC[1~ in some terminals, and keys with modifiers send
+		// ESC[1;<modifiers><key>, e.g. ESC[1;5F for Ctrl+End
+		var params []byte
+		for {
+			next, err := kr.readByte()
+			if err != nil {
+				return Key{Type: KeyUnknown}, nil
+			}
+			if next >= '@' && next <= '~' {
+				switch {
+				case next == '~' && len(params) == 0, next == 'H':
+					return Key{Type: KeyHome}, nil
+				case next != 'F':
+					return Key{Type: KeyUnknown}, nil
+				case string(params) == ";5":
+					return Key{Type: KeyCtrlEnd}, nil
+				}
+				return Key{Type: KeyEnd}, nil
+			}
+			params = append(params, next)
+		}
+	case '2':
+		// Bracketed paste starts with ESC[200~; see LineEditor.bracketedPaste
+		code := []byte{b}
+		for len(code) < 4 {
+			next, err := kr.readByte()
+			if err != nil || next == '~' {
+				break
+			}
+			code = append(code, next)
+		}
+		if string(code) != "200" {
+			return Key{Type: KeyUnknown}, nil
+		}
+		return kr.readPaste()
 	default:
 		return Key{Type: KeyUnknown}, nil
 	}
 }
+
+// readSS3 reads the key of an ESC O sequence, which terminals in
+// application cursor mode, Windows consoles among them, send for the arrows,
+// Home and End. Esc followed by O typed on its own is not one.
+func (kr *KeyboardReader) readSS3() Key {
+	timeout := make(chan struct{})
+	timer := time.AfterFunc(escapeTimeout, func() { close(timeout) })
+	b, err := kr.readByteUntil(timeout)
+	timer.Stop()
+	if err != nil {
+		kr.unread = append(kr.unread, 'O')
+		return Key{Type: KeyEscape}
+	}
+
+	switch b {
+	case 'A':
+		return Key{Type: KeyArrowUp}
+	case 'B':
+		return Key{Type: KeyArrowDown}
+	case 'C':
+		return Key{Type: KeyArrowRight}
+	case 'D':
+		return Key{Type: KeyArrowLeft}
+	case 'H':
+		return Key{Type: KeyHome}
+	case 'F':
+		return Key{Type: KeyEnd}
+	default:
+		return Key{Type: KeyUnknown}
+	}
+}
+
+// pasteEnd marks the end of text pasted in bracketed paste mode
+const pasteEnd = "\x1b[201~"
+
+// readPaste reads pasted text up to the end marker, so that the newlines in
+// it don't act as Enter
+func (kr *KeyboardReader) readPaste() (Key, error) {
+	var text []byte
+	for !bytes.HasSuffix(text, []byte(pasteEnd)) {
+		b, err := kr.readByte()
+		if err != nil {
+			return Key{}, err
+		}
+		text = append(text, b)
+	}
+	pasted := strings.ReplaceAll(string(text[:len(text)-len(pasteEnd)]), "\r\n", "\n")
+	return Key{Type: KeyPaste, Text: strings.ReplaceAll(pasted, "\r", "\n")}, nil
+}
@@ -0,0 +1,85 @@
+package termflow
+
+import "testing"
+
+func TestReadKeyFocusReports(t *testing.T) {
+	kr := &KeyboardReader{rawMode: true, unread: []byte("\x1b[O\x1b[Ix")}
+
+	steps := []struct {
+		want      KeyType
+		unfocused bool
+	}{
+		{KeyUnknown, true},
+		{KeyUnknown, false},
+		{KeyRune, false},
+	}
+	for i, step := range steps {
+		key, err := kr.ReadKey()
+		if err != nil {
+			t.Fatalf("key %d: %v", i, err)
+		}
+		if key.Type != step.want {
+			t.Errorf("key %d = %v, want type %d", i, key, step.want)
+		}
+		if got := kr.unfocused.Load(); got != step.unfocused {
+			t.Errorf("after key %d unfocused = %v, want %v", i, got, step.unfocused)
+		}
+	}
+}
+
+func TestReadKeyEditingKeys(t *testing.T) {
+	kr := &KeyboardReader{rawMode: true, unread: []byte("\x1b[3~\x1b[5~\x1b[6~\x1b[F\x1b[4~\x1b[1;5F\x1b[1;2F\x1b[1;5Ax\x1f\x1a\x1b[7~")}
+
+	for i, want := range []KeyType{KeyDelete, KeyPageUp, KeyPageDown, KeyEnd, KeyEnd, KeyCtrlEnd, KeyEnd, KeyUnknown, KeyRune, KeyCtrlUnderscore, KeyCtrlZ, KeyUnknown} {
+		key, err := kr.ReadKey()
+		if err != nil {
+			t.Fatalf("key %d: %v", i, err)
+		}
+		if key.Type != want {
+			t.Errorf("key %d = %v, want type %d", i, key, want)
+		}
+	}
+}
+
+func TestReadKeyHomeAndSS3(t *testing.T) {
+	// Windows consoles send ESC O sequences in application cursor mode
+	kr := &KeyboardReader{rawMode: true, unread: []byte("\x1b[H\x1b[1~\x1b[1;5H\x1bOA\x1bOB\x1bOC\x1bOD\x1bOH\x1bOF\x1bOZ")}
+
+	for i, want := range []KeyType{KeyHome, KeyHome, KeyHome, KeyArrowUp, KeyArrowDown, KeyArrowRight, KeyArrowLeft, KeyHome, KeyEnd, KeyUnknown} {
+		key, err := kr.ReadKey()
+		if err != nil {
+			t.Fatalf("key %d: %v", i, err)
+		}
+		if key.Type != want {
+			t.Errorf("key %d = %v, want type %d", i, key, want)
+		}
+	}
+}
+
+func TestReadKeyEscapeThenO(t *testing.T) {
+	// Nothing follows ESC O: Esc was pressed, then O typed
+	kr := &KeyboardReader{rawMode: true, unread: []byte("\x1bO"), input: make(chan byte)}
+	kr.startOnce.Do(func() {})
+
+	if key, _ := kr.ReadKey(); key.Type != KeyEscape {
+		t.Errorf("first key = %v, want Escape", key)
+	}
+	if key, _ := kr.ReadKey(); key.Type != KeyRune || key.Rune != 'O' {
+		t.Errorf("second key = %v, want O", key)
+	}
+}
+
+func TestReadKeyBracketedPaste(t *testing.T) {
+	kr := &KeyboardReader{rawMode: true, unread: []byte("\x1b[200~one\r\ntwo\rthree\x1b[201~x")}
+
+	key, err := kr.ReadKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.Type != KeyPaste || key.Text != "one\ntwo\nthree" {
+		t.Errorf("ReadKey() = %v, want the pasted text", key)
+	}
+	if key, _ := kr.ReadKey(); key.Type != KeyRune || key.Rune != 'x' {
+		t.Errorf("key after the paste = %v, want x", key)
+	}
+}
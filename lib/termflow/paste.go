@@ -0,0 +1,62 @@
+package termflow
+
+import (
+	"fmt"
+	"strings"
+)
+
+// bracketedPaste asks the terminal to mark the start and end of pasted text,
+// or to stop, so that a pasted snippet isn't entered line by line
+func (le *LineEditor) bracketedPaste(enabled bool) {
+	if enabled {
+		fmt.Fprint(le.client.output, "\033[?2004h")
+	} else {
+		fmt.Fprint(le.client.output, "\033[?2004l")
+	}
+}
+
+// pastePlaceholder is shown in the input in place of a multi-line paste
+func pastePlaceholder(text string) string {
+	return fmt.Sprintf("[pasted %d lines]", strings.Count(text, "\n")+1)
+}
+
+// insertPaste inserts pasted text at the cursor. Text of a single line is
+// inserted as typed; longer text is shown as a placeholder and expanded when
+// the input is entered.
+func (le *LineEditor) insertPaste(text string) {
+	text = strings.TrimSuffix(text, "\n")
+	insert := text
+	if strings.Contains(text, "\n") {
+		insert = pastePlaceholder(text)
+		if le.pastes == nil {
+			le.pastes = map[string][]string{}
+		}
+		// Keep the texts in the order their placeholders appear
+		texts := le.pastes[insert]
+		i := strings.Count(le.line[:le.cursor], insert)
+		le.pastes[insert] = append(texts[:i:i], append([]string{text}, texts[i:]...)...)
+	}
+	le.line = le.line[:le.cursor] + insert + le.line[le.cursor:]
+	le.cursor += len(insert)
+}
+
+// expandPastes replaces the placeholders in line with the text pasted
+func (le *LineEditor) expandPastes(line string) string {
+	for placeholder, texts := range le.pastes {
+		parts := strings.Split(line, placeholder)
+		var b strings.Builder
+		for i, part := range parts {
+			if i > 0 {
+				if i <= len(texts) {
+					b.WriteString(texts[i-1])
+				} else {
+					b.WriteString(placeholder)
+				}
+			}
+			b.WriteString(part)
+		}
+		line = b.String()
+	}
+	le.pastes = nil
+	return line
+}
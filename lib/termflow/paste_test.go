@@ -0,0 +1,55 @@
+package termflow
+
+import "testing"
+
+func TestInsertPaste(t *testing.T) {
+	type paste struct {
+		cursor int
+		text   string
+	}
+	tests := []struct {
+		name    string
+		line    string
+		pastes  []paste
+		want    string
+		entered string
+	}{
+		{
+			name:    "single line is inserted as typed",
+			line:    "say ",
+			pastes:  []paste{{4, "hello\n"}},
+			want:    "say hello",
+			entered: "say hello",
+		},
+		{
+			name:    "multiple lines are shown as a placeholder",
+			line:    "explain ",
+			pastes:  []paste{{8, "a := 1\nb := 2\n"}},
+			want:    "explain [pasted 2 lines]",
+			entered: "explain a := 1\nb := 2",
+		},
+		{
+			name:    "pastes with the same placeholder keep their order",
+			line:    "diff  and ",
+			pastes:  []paste{{10, "new\nnew"}, {5, "old\nold"}},
+			want:    "diff [pasted 2 lines] and [pasted 2 lines]",
+			entered: "diff old\nold and new\nnew",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			le := &LineEditor{line: tt.line}
+			for _, p := range tt.pastes {
+				le.cursor = p.cursor
+				le.insertPaste(p.text)
+			}
+			if le.line != tt.want {
+				t.Errorf("line = %q, want %q", le.line, tt.want)
+			}
+			if got := le.expandPastes(le.line); got != tt.entered {
+				t.Errorf("expandPastes() = %q, want %q", got, tt.entered)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package termflow
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ReadKeys reads keys in raw mode, passing each to handle until it returns
+// false, for interactive pickers drawn with a LiveRegion
+func (ic *InteractiveClient) ReadKeys(handle func(Key) bool) error {
+	keyboard := ic.lineEditor.keyboard
+	if err := keyboard.EnableRawMode(); err != nil {
+		return err
+	}
+	defer keyboard.DisableRawMode()
+
+	for {
+		key, err := keyboard.ReadKey()
+		if err != nil {
+			return err
+		}
+		if !handle(key) {
+			return nil
+		}
+	}
+}
+
+// LiveRegion is a block of lines that is redrawn in place, such as a list the
+// user moves through with the arrow keys
+type LiveRegion struct {
+	client *Client
+	lines  int // Lines drawn last, which the next draw replaces
+}
+
+// NewLiveRegion creates a region drawn from the current line down
+func (c *Client) NewLiveRegion() *LiveRegion {
+	return &LiveRegion{client: c}
+}
+
+// Draw replaces what the region showed with text. Long lines are cut off
+// rather than wrapped, so that the region's height stays known.
+func (r *LiveRegion) Draw(text string) {
+	text = strings.TrimSuffix(text, "\n")
+	var b strings.Builder
+	r.rewind(&b)
+	b.WriteString("\033[?7l")
+	b.WriteString(strings.ReplaceAll(text, "\n", "\r\n"))
+	b.WriteString("\033[?7h")
+	fmt.Fprint(r.client.output, b.String())
+	r.lines = strings.Count(text, "\n") + 1
+}
+
+// Clear removes the region, leaving the cursor where it started
+func (r *LiveRegion) Clear() {
+	var b strings.Builder
+	r.rewind(&b)
+	fmt.Fprint(r.client.output, b.String())
+	r.lines = 0
+}
+
+// rewind moves to the region's first line and clears from there down
+func (r *LiveRegion) rewind(b *strings.Builder) {
+	if r.lines > 1 {
+		fmt.Fprintf(b, "\033[%dA", r.lines-1)
+	}
+	b.WriteString("\r\033[J")
+}
@@ -0,0 +1,28 @@
+package termflow
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLiveRegion(t *testing.T) {
+	var out bytes.Buffer
+	region := (&Client{output: &out}).NewLiveRegion()
+
+	region.Draw("a\nb\n")
+	if want := "\r\033[J\033[?7la\r\nb\033[?7h"; out.String() != want {
+		t.Errorf("first Draw wrote %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	region.Draw("c")
+	if want := "\033[1A\r\033[J\033[?7lc\033[?7h"; out.String() != want {
+		t.Errorf("second Draw wrote %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	region.Clear()
+	if want := "\r\033[J"; out.String() != want {
+		t.Errorf("Clear wrote %q, want %q", out.String(), want)
+	}
+}
@@ -7,6 +7,9 @@ import (
 	"strings"
 	"time"
 	"unicode/utf8"
+
+	"github.com/mizzy/rigel/lib/undo"
+	"github.com/mizzy/rigel/lib/vi"
 )
 
 // LineEditor provides line editing with history navigation
@@ -17,12 +20,20 @@ type LineEditor struct {
 	historyIndex     int
 	line             string
 	cursor           int
-	prompt           string
-	ctrlCPressed     bool        // Track first Ctrl+C press for two-press exit
-	exitMessageShown bool        // Track if exit message is shown below current line
-	cursorOnExitLine bool        // Track if cursor is positioned on the line above exit message
-	ctrlCTimer       *time.Timer // Timer to reset Ctrl+C state after 1 second
-	displayedLines   int         // Track how many lines we've displayed
+	ctrlCPressed     bool                // Track first Ctrl+C press for two-press exit
+	exitMessageShown bool                // Track if exit message is shown below current line
+	cursorOnExitLine bool                // Track if cursor is positioned on the line above exit message
+	ctrlCTimer       *time.Timer         // Timer to reset Ctrl+C state after 1 second
+	displayedLines   int                 // Track how many lines we've displayed
+	cursorRow        int                 // Line of the displayed input the cursor is on
+	drawnPrompt      string              // Prompt the displayed input follows
+	initialLine      string              // Text to start the next line with
+	vi               *vi.Editor          // Vi-style editing; nil for the default keys
+	statusLine       string              // Line drawn above the prompt
+	placeholder      string              // Hint shown while the line is empty
+	pastes           map[string][]string // Multi-line pastes by their placeholder
+	edits            undo.History        // Changes to the line that can be undone
+	onChange         func(line string)   // Called with the line after each key
 }
 
 // NewLineEditor creates a new line editor
@@ -39,7 +50,6 @@ func NewLineEditor(client *Client) (*LineEditor, error) {
 		historyIndex:   -1,
 		line:           "",
 		cursor:         0,
-		prompt:         client.prompt,
 		displayedLines: 0,
 	}, nil
 }
@@ -50,6 +60,12 @@ func (le *LineEditor) SetHistory(history []string) {
 	le.historyIndex = -1
 }
 
+// SetInitialLine sets text that the next read starts with, for editing a
+// previous input
+func (le *LineEditor) SetInitialLine(line string) {
+	le.initialLine = line
+}
+
 // ReadLineWithHistory reads a line with arrow key history navigation
 func (le *LineEditor) ReadLineWithHistory() (string, error) {
 	// Enable raw mode for key-by-key input
@@ -58,33 +74,55 @@ func (le *LineEditor) ReadLineWithHistory() (string, error) {
 		return le.client.ReadLine()
 	}
 	defer le.keyboard.DisableRawMode()
+	le.bracketedPaste(true)
+	defer le.bracketedPaste(false)
 
 	// Initialize line state
-	le.line = ""
-	le.cursor = 0
+	le.line = le.initialLine
+	le.cursor = len(le.line)
+	le.initialLine = ""
 	le.historyIndex = -1
 	le.displayedLines = 0
+	le.cursorRow = 0
+	le.pastes = nil
+	le.edits.Reset(le.state())
+	if le.vi != nil {
+		le.vi.Reset()
+	}
 
-	// Show initial prompt
+	// Show the status line and initial prompt
+	le.drawStatusLine()
 	le.refreshDisplay()
 
 	for {
+		le.keyHandled()
 		key, err := le.keyboard.ReadKey()
 		if err != nil {
 			return "", err
 		}
 
+		if le.vi != nil {
+			var pass bool
+			if key, pass = le.handleViKey(key); !pass {
+				continue
+			}
+		}
+
 		switch key.Type {
 		case KeyEnter:
-			// Finish input
+			// Finish input below its last line
+			if below := le.linesBelowCursor(); below > 0 {
+				fmt.Fprintf(le.client.output, "\033[%dB", below)
+			}
 			fmt.Fprint(le.client.output, "\n")
-			result := le.line
+			result := le.expandPastes(le.line)
 
 			// Reset flags when completing input
 			le.ctrlCPressed = false
 			le.exitMessageShown = false
 			le.cursorOnExitLine = false
 			le.displayedLines = 0
+			le.cursorRow = 0
 
 			// Stop timer if running
 			le.stopCtrlCTimer()
@@ -103,24 +141,11 @@ func (le *LineEditor) ReadLineWithHistory() (string, error) {
 				return "", fmt.Errorf("interrupted")
 			}
 			// First Ctrl+C: don't redraw the input block to avoid duplication.
-			// Simply print the exit hint on the next line and restore the cursor.
+			// Simply print the exit hint below it and restore the cursor.
 			le.ctrlCPressed = true
 			le.exitMessageShown = true
 			le.cursorOnExitLine = true
-			// Show exit message on the next line
-			fmt.Fprintf(le.client.output, "\n\r\033[38;5;240m(Press Ctrl+C again to exit)\033[0m")
-			// Move cursor back up to the input line
-			fmt.Fprintf(le.client.output, "\033[1A")
-			// Position cursor depending on whether we're on first or continuation line
-			textBeforeCursor := le.line[:le.cursor]
-			linesBeforeCursor := strings.Split(textBeforeCursor, "\n")
-			currentLineIndex := len(linesBeforeCursor) - 1
-			currentColumn := len(linesBeforeCursor[len(linesBeforeCursor)-1])
-			if currentLineIndex == 0 {
-				fmt.Fprintf(le.client.output, "\r\033[%dC", visibleLength(le.prompt)+currentColumn)
-			} else {
-				fmt.Fprintf(le.client.output, "\r\033[%dC", 2+currentColumn)
-			}
+			le.showExitHint()
 
 			// Start 1-second timer to reset Ctrl+C state and clear message
 			le.startCtrlCTimer()
@@ -161,6 +186,15 @@ func (le *LineEditor) ReadLineWithHistory() (string, error) {
 				le.refreshDisplay()
 			}
 
+		case KeyHome, KeyEnd:
+			// Move to the start or end of the whole input
+			if key.Type == KeyHome {
+				le.cursor = 0
+			} else {
+				le.cursor = len(le.line)
+			}
+			le.refreshDisplay()
+
 		case KeyArrowUp:
 			// Navigate history and refresh to show selected entry
 			le.navigateHistory(-1)
@@ -171,6 +205,12 @@ func (le *LineEditor) ReadLineWithHistory() (string, error) {
 			le.navigateHistory(1)
 			le.refreshDisplay()
 
+		case KeyCtrlUnderscore, KeyCtrlZ:
+			// Undo the last change to the line, or redo the last one undone
+			if le.undoEdit(key.Type == KeyCtrlZ) {
+				le.refreshDisplay()
+			}
+
 		case KeyTab:
 			// TODO: Implement tab completion
 			continue
@@ -181,8 +221,16 @@ func (le *LineEditor) ReadLineWithHistory() (string, error) {
 			le.refreshDisplay()
 			continue
 
+		case KeyPaste:
+			le.insertPaste(key.Text)
+			le.refreshDisplay()
+
 		case KeyRune:
 			le.insertRune(key.Rune)
+			if le.line == string(key.Rune) && le.placeholder != "" {
+				// Clear the placeholder the character replaces
+				fmt.Fprint(le.client.output, "\033[K")
+			}
 
 			// Use smart refresh: simple echo for single-line, no refresh for multiline character input
 			if strings.Contains(le.line, "\n") {
@@ -225,6 +273,34 @@ func (le *LineEditor) insertRune(r rune) {
 	le.cursor++
 }
 
+// keyHandled records the line after a key in the undo history and passes
+// it, with pastes expanded, to the change callback
+func (le *LineEditor) keyHandled() {
+	le.edits.Record(le.state())
+	if le.onChange != nil {
+		le.onChange(le.expandPastes(le.line))
+	}
+}
+
+// state returns the line and cursor for the undo history
+func (le *LineEditor) state() undo.State {
+	return undo.State{Text: le.line, Cursor: le.cursor}
+}
+
+// undoEdit undoes the last change to the line, or redoes the last change
+// undone, reporting whether there was one
+func (le *LineEditor) undoEdit(redo bool) bool {
+	step := le.edits.Undo
+	if redo {
+		step = le.edits.Redo
+	}
+	s, ok := step()
+	if ok {
+		le.line, le.cursor = s.Text, s.Cursor
+	}
+	return ok
+}
+
 // moveCursorLeft moves cursor one position left
 func (le *LineEditor) moveCursorLeft() {
 	if le.cursor > 0 {
@@ -272,115 +348,97 @@ func (le *LineEditor) navigateHistory(direction int) {
 	}
 }
 
+// continuationIndent starts the lines of a multi-line input after the
+// first, lining them up with the text after the prompt
+const continuationIndent = "  "
+
 // refreshDisplay redraws the current line(s) with multiline support
 func (le *LineEditor) refreshDisplay() {
-	lines := strings.Split(le.line, "\n")
+	le.redraw(le.prompt())
+}
 
-	// Compute cursor location in input text for final positioning
-	textBeforeCursor := le.line[:le.cursor]
-	linesBeforeCursor := strings.Split(textBeforeCursor, "\n")
-	currentLineIndex := len(linesBeforeCursor) - 1
-	currentColumn := len(linesBeforeCursor[len(linesBeforeCursor)-1])
+// refreshDisplayWithoutPrompt redraws the current line(s) without showing the prompt
+func (le *LineEditor) refreshDisplayWithoutPrompt() {
+	le.redraw("")
+}
+
+// redraw replaces the input drawn before, however many lines it took and
+// wherever the cursor was in it, with the current input: the first line
+// after prompt and each further line after the continuation indent. The
+// cursor is then placed at its position in the input.
+func (le *LineEditor) redraw(prompt string) {
+	out := le.client.output
+	lines := strings.Split(le.line, "\n")
 
 	// Move to the top of the previously drawn input block and clear it
-	n := le.displayedLines
-	if n > 0 {
-		if n > 1 {
-			fmt.Fprintf(le.client.output, "\033[%dA", n-1)
-		}
-		fmt.Fprint(le.client.output, "\r")
-		for i := 0; i < n; i++ {
-			fmt.Fprint(le.client.output, "\033[K")
-			if i < n-1 {
-				fmt.Fprint(le.client.output, "\033[1B\r")
-			}
-		}
-		if n > 1 {
-			fmt.Fprintf(le.client.output, "\033[%dA\r", n-1)
-		} else {
-			fmt.Fprint(le.client.output, "\r")
-		}
+	if le.displayedLines > 0 && le.cursorRow > 0 {
+		fmt.Fprintf(out, "\033[%dA", le.cursorRow)
 	}
+	fmt.Fprint(out, "\r\033[J")
 
 	// Draw fresh content (no leading newline; spacer is provided by welcome)
-	fmt.Fprint(le.client.output, le.prompt)
-	fmt.Fprint(le.client.output, lines[0])
-	for i := 1; i < len(lines); i++ {
-		fmt.Fprint(le.client.output, "\n\r  ")
-		fmt.Fprint(le.client.output, lines[i])
-	}
-
-	// Position cursor
-	if len(lines) > 1 {
-		fmt.Fprintf(le.client.output, "\033[%dA", len(lines)-1)
+	fmt.Fprint(out, prompt)
+	fmt.Fprint(out, lines[0])
+	if le.line == "" && le.placeholder != "" {
+		fmt.Fprint(out, le.client.colors.Paint(RoleMuted, le.placeholder))
 	}
-	fmt.Fprint(le.client.output, "\r")
-	if currentLineIndex > 0 {
-		fmt.Fprintf(le.client.output, "\033[%dB", currentLineIndex)
-		fmt.Fprintf(le.client.output, "\033[%dC", 2+currentColumn)
-	} else {
-		fmt.Fprintf(le.client.output, "\033[%dC", visibleLength(le.prompt)+currentColumn)
+	for _, line := range lines[1:] {
+		fmt.Fprint(out, "\r\n"+continuationIndent)
+		fmt.Fprint(out, line)
 	}
 
+	// Move up from the last line to the cursor's
+	row, _ := cursorPosition(le.line, le.cursor)
+	if up := len(lines) - 1 - row; up > 0 {
+		fmt.Fprintf(out, "\033[%dA", up)
+	}
 	le.displayedLines = len(lines)
+	le.cursorRow = row
+	le.drawnPrompt = prompt
+	le.moveToCursorColumn()
 }
 
-// refreshDisplayWithoutPrompt redraws the current line(s) without showing the prompt
-func (le *LineEditor) refreshDisplayWithoutPrompt() {
-	lines := strings.Split(le.line, "\n")
-
-	// Calculate cursor position
-	textBeforeCursor := le.line[:le.cursor]
-	linesBeforeCursor := strings.Split(textBeforeCursor, "\n")
-	targetLine := len(linesBeforeCursor) - 1
-	targetColumn := len(linesBeforeCursor[len(linesBeforeCursor)-1])
-
-	// Move to end of first line (after the prompt and first line content)
-	fmt.Fprint(le.client.output, "\r")
-	fmt.Fprint(le.client.output, "\033[999C") // Move to end of line
-
-	// Clear all continuation lines aggressively
-	maxLinesToClear := 10 // Clear up to 10 lines to be safe
-	for i := 0; i < maxLinesToClear; i++ {
-		fmt.Fprint(le.client.output, "\n\r\033[K") // Move down, carriage return, then clear line
-	}
-
-	// Move back to end of first line
-	fmt.Fprintf(le.client.output, "\033[%dA", maxLinesToClear) // Move back up
-	fmt.Fprint(le.client.output, "\r\033[999C")                // Move to end of first line
-
-	// Display only continuation lines (skip first line which already has prompt)
-	for i := 1; i < len(lines); i++ {
-		fmt.Fprint(le.client.output, "\n\r  ") // Newline + carriage return + 2 spaces for alignment
-		fmt.Fprint(le.client.output, lines[i])
-	}
+// cursorPosition returns the line of input the cursor at byte offset
+// cursor is on and the number of characters before it on the line
+func cursorPosition(input string, cursor int) (row, column int) {
+	before := input[:cursor]
+	row = strings.Count(before, "\n")
+	column = utf8.RuneCountInString(before[strings.LastIndex(before, "\n")+1:])
+	return row, column
+}
 
-	// Position cursor correctly
-	// Move to beginning of first line
-	if len(lines) > 1 {
-		fmt.Fprintf(le.client.output, "\033[%dA", len(lines)-1)
+// moveToCursorColumn moves to the cursor's column on its line, after the
+// prompt on the first line and after the continuation indent on the others
+func (le *LineEditor) moveToCursorColumn() {
+	row, column := cursorPosition(le.line, le.cursor)
+	if row == 0 {
+		column += visibleLength(le.drawnPrompt)
+	} else {
+		column += len(continuationIndent)
 	}
 	fmt.Fprint(le.client.output, "\r")
-
-	// Move down to target line
-	if targetLine > 0 {
-		fmt.Fprintf(le.client.output, "\033[%dB", targetLine)
+	if column > 0 {
+		fmt.Fprintf(le.client.output, "\033[%dC", column)
 	}
+}
 
-	// Move to target column, accounting for alignment on continuation lines
-	if targetLine == 0 {
-		// First line - no additional alignment
-		fmt.Fprintf(le.client.output, "\033[%dC", targetColumn)
-	} else {
-		// Subsequent lines have 2-space alignment + target column
-		totalColumn := 2 + targetColumn
-		if totalColumn > 0 {
-			fmt.Fprintf(le.client.output, "\033[%dC", totalColumn)
-		}
-	}
+// linesBelowCursor returns how many lines of the displayed input are below
+// the cursor's
+func (le *LineEditor) linesBelowCursor() int {
+	return max(le.displayedLines-1-le.cursorRow, 0)
+}
 
-	// Update displayed lines count for next refresh
-	le.displayedLines = len(lines)
+// showExitHint shows how to exit below the input, returning the cursor to
+// its position in the input
+func (le *LineEditor) showExitHint() {
+	out := le.client.output
+	below := le.linesBelowCursor()
+	if below > 0 {
+		fmt.Fprintf(out, "\033[%dB", below)
+	}
+	fmt.Fprintf(out, "\n\r%s", le.client.colors.Paint(RoleInfo, "(Press Ctrl+C again to exit)"))
+	fmt.Fprintf(out, "\033[%dA", below+1)
+	le.moveToCursorColumn()
 }
 
 // ReadLineWithoutPrompt reads input without showing the initial prompt
@@ -391,16 +449,23 @@ func (le *LineEditor) ReadLineWithoutPrompt() (string, error) {
 		return le.client.ReadLine()
 	}
 	defer le.keyboard.DisableRawMode()
+	le.bracketedPaste(true)
+	defer le.bracketedPaste(false)
 
 	// Initialize line state
 	le.line = ""
 	le.cursor = 0
 	le.historyIndex = -1
 	le.displayedLines = 0
+	le.cursorRow = 0
+	le.drawnPrompt = ""
+	le.pastes = nil
+	le.edits.Reset(le.state())
 
 	// Don't show initial prompt - this is the key difference
 
 	for {
+		le.keyHandled()
 		key, err := le.keyboard.ReadKey()
 		if err != nil {
 			return "", err
@@ -408,15 +473,19 @@ func (le *LineEditor) ReadLineWithoutPrompt() (string, error) {
 
 		switch key.Type {
 		case KeyEnter:
-			// Finish input
+			// Finish input below its last line
+			if below := le.linesBelowCursor(); below > 0 {
+				fmt.Fprintf(le.client.output, "\033[%dB", below)
+			}
 			fmt.Fprint(le.client.output, "\n")
-			result := le.line
+			result := le.expandPastes(le.line)
 
 			// Reset flags when completing input
 			le.ctrlCPressed = false
 			le.exitMessageShown = false
 			le.cursorOnExitLine = false
 			le.displayedLines = 0
+			le.cursorRow = 0
 
 			// Stop timer if running
 			le.stopCtrlCTimer()
@@ -438,20 +507,7 @@ func (le *LineEditor) ReadLineWithoutPrompt() (string, error) {
 			le.ctrlCPressed = true
 			le.exitMessageShown = true
 			le.cursorOnExitLine = true
-			// Show exit message on the next line
-			fmt.Fprintf(le.client.output, "\n\r\033[38;5;240m(Press Ctrl+C again to exit)\033[0m")
-			// Move cursor back up to the input line
-			fmt.Fprintf(le.client.output, "\033[1A")
-			// Position cursor depending on whether we're on first or continuation line
-			textBeforeCursor := le.line[:le.cursor]
-			linesBeforeCursor := strings.Split(textBeforeCursor, "\n")
-			currentLineIndex := len(linesBeforeCursor) - 1
-			currentColumn := len(linesBeforeCursor[len(linesBeforeCursor)-1])
-			if currentLineIndex == 0 {
-				fmt.Fprintf(le.client.output, "\r\033[%dC", visibleLength(le.prompt)+currentColumn)
-			} else {
-				fmt.Fprintf(le.client.output, "\r\033[%dC", 2+currentColumn)
-			}
+			le.showExitHint()
 
 			// Start 1-second timer to reset Ctrl+C state and clear message
 			le.startCtrlCTimer()
@@ -512,11 +568,20 @@ func (le *LineEditor) ReadLineWithoutPrompt() (string, error) {
 				le.refreshDisplayWithoutPrompt()
 			}
 
+		case KeyCtrlUnderscore, KeyCtrlZ:
+			if le.undoEdit(key.Type == KeyCtrlZ) {
+				le.refreshDisplayWithoutPrompt()
+			}
+
 		case KeyCtrlJ:
 			// Insert newline for multiline input
 			le.insertRune('\n')
 			le.refreshDisplayWithoutPrompt()
 
+		case KeyPaste:
+			le.insertPaste(key.Text)
+			le.refreshDisplayWithoutPrompt()
+
 		case KeyRune:
 			// Insert character at cursor position
 			if le.cursor >= len(le.line) {
@@ -566,22 +631,13 @@ func (le *LineEditor) startCtrlCTimer() {
 		le.exitMessageShown = false
 		le.cursorOnExitLine = false
 
-		// Clear the exit message by redrawing the display
-		// Move cursor down to the exit message line and clear it
-		fmt.Fprint(le.client.output, "\033[1B")  // Move down 1 line to the exit message
-		fmt.Fprint(le.client.output, "\r\033[K") // Clear the line
-
-		// Move back up to the input line and reposition cursor accurately
-		fmt.Fprint(le.client.output, "\033[1A") // Move up 1 line
-		textBeforeCursor := le.line[:le.cursor]
-		linesBeforeCursor := strings.Split(textBeforeCursor, "\n")
-		currentLineIndex := len(linesBeforeCursor) - 1
-		currentColumn := len(linesBeforeCursor[len(linesBeforeCursor)-1])
-		if currentLineIndex == 0 {
-			fmt.Fprintf(le.client.output, "\r\033[%dC", visibleLength(le.prompt)+currentColumn)
-		} else {
-			fmt.Fprintf(le.client.output, "\r\033[%dC", 2+currentColumn)
-		}
+		// Move down to the exit message below the input, clear it and move
+		// back to the cursor
+		below := le.linesBelowCursor() + 1
+		fmt.Fprintf(le.client.output, "\033[%dB", below)
+		fmt.Fprint(le.client.output, "\r\033[K")
+		fmt.Fprintf(le.client.output, "\033[%dA", below)
+		le.moveToCursorColumn()
 	})
 }
 
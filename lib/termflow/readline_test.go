@@ -0,0 +1,198 @@
+package termflow
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// screen interprets the output of the line editor as a terminal in raw
+// mode would, for the cursor movements and erasures it uses
+type screen struct {
+	rows     [][]rune
+	row, col int
+}
+
+func newScreen(rows ...string) *screen {
+	s := &screen{}
+	for _, r := range rows {
+		s.rows = append(s.rows, []rune(r))
+	}
+	s.rows = append(s.rows, nil)
+	s.row = len(s.rows) - 1
+	return s
+}
+
+func (s *screen) write(output string) {
+	text := []rune(output)
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\r':
+			s.col = 0
+		case '\n':
+			s.row++
+		case '\033':
+			// CSI: digits, then the command
+			j := i + 2
+			for j < len(text) && (text[j] >= '0' && text[j] <= '9' || text[j] == '?') {
+				j++
+			}
+			n, err := strconv.Atoi(string(text[i+2 : j]))
+			if err != nil {
+				n = 1
+			}
+			s.grow()
+			switch text[j] {
+			case 'A':
+				s.row = max(s.row-n, 0)
+			case 'B':
+				s.row += n
+			case 'C':
+				s.col += n
+			case 'D':
+				s.col = max(s.col-n, 0)
+			case 'J':
+				s.rows = s.rows[:s.row+1]
+				s.rows[s.row] = s.rows[s.row][:min(s.col, len(s.rows[s.row]))]
+			case 'K':
+				s.rows[s.row] = s.rows[s.row][:min(s.col, len(s.rows[s.row]))]
+			}
+			i = j
+		default:
+			s.grow()
+			line := s.rows[s.row]
+			for len(line) <= s.col {
+				line = append(line, ' ')
+			}
+			line[s.col] = text[i]
+			s.rows[s.row] = line
+			s.col++
+		}
+	}
+	s.grow()
+}
+
+// grow adds the rows down to the cursor's
+func (s *screen) grow() {
+	for len(s.rows) <= s.row {
+		s.rows = append(s.rows, nil)
+	}
+}
+
+// lines returns the rows on the screen, without trailing empty ones
+func (s *screen) lines() []string {
+	var lines []string
+	for _, r := range s.rows {
+		lines = append(lines, strings.TrimRight(string(r), " "))
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func TestHistoryRecallRedrawsMultiLineEntries(t *testing.T) {
+	var out bytes.Buffer
+	le := &LineEditor{client: &Client{output: &out, prompt: "> ", colors: NewColors(ProfileNoColor, false)}, historyIndex: -1}
+	le.SetHistory([]string{"one", "first line\nsecond\nthird"})
+	term := newScreen("Welcome")
+
+	check := func(name string, want []string, row, col int) {
+		t.Helper()
+		term.write(out.String())
+		out.Reset()
+		if got := term.lines(); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: screen = %q, want %q", name, got, want)
+		}
+		if term.row != row || term.col != col {
+			t.Errorf("%s: cursor at %d,%d, want %d,%d", name, term.row, term.col, row, col)
+		}
+	}
+
+	le.line, le.cursor = "draft", 5
+	le.refreshDisplay()
+	check("typed", []string{"Welcome", "> draft"}, 1, 7)
+
+	le.navigateHistory(-1)
+	le.refreshDisplay()
+	check("multi-line entry", []string{"Welcome", "> first line", "  second", "  third"}, 3, 7)
+
+	// Recalling another entry with the cursor on the first line clears
+	// every line of the one before
+	le.cursor = 0
+	le.refreshDisplay()
+	check("home", []string{"Welcome", "> first line", "  second", "  third"}, 1, 2)
+	le.navigateHistory(-1)
+	le.refreshDisplay()
+	check("single-line entry", []string{"Welcome", "> one"}, 1, 5)
+
+	le.navigateHistory(1)
+	le.refreshDisplay()
+	check("back down", []string{"Welcome", "> first line", "  second", "  third"}, 3, 7)
+
+	le.cursor = 3
+	le.refreshDisplay()
+	le.showExitHint()
+	check("exit hint", []string{"Welcome", "> first line", "  second", "  third", "(Press Ctrl+C again to exit)"}, 1, 5)
+
+	le.navigateHistory(1)
+	le.refreshDisplay()
+	check("empty line", []string{"Welcome", ">"}, 1, 2)
+}
+
+func TestCursorPosition(t *testing.T) {
+	tests := []struct {
+		input       string
+		cursor      int
+		row, column int
+	}{
+		{"", 0, 0, 0},
+		{"hello", 3, 0, 3},
+		{"first\nsecond", len("first\nsecond"), 1, 6},
+		{"first\n", len("first\n"), 1, 0},
+		{"héllo\nwörld", len("héllo\nwö"), 1, 2},
+	}
+	for _, tt := range tests {
+		row, column := cursorPosition(tt.input, tt.cursor)
+		if row != tt.row || column != tt.column {
+			t.Errorf("cursorPosition(%q, %d) = %d, %d, want %d, %d", tt.input, tt.cursor, row, column, tt.row, tt.column)
+		}
+	}
+}
+
+func TestUndoEdit(t *testing.T) {
+	le := &LineEditor{}
+	le.edits.Reset(le.state())
+	// Each key is recorded before the next one is read
+	key := func(edit func()) {
+		edit()
+		le.edits.Record(le.state())
+	}
+
+	for _, r := range "a long prompt" {
+		key(func() { le.insertRune(r) })
+	}
+	key(le.handleBackspace)
+	key(le.handleBackspace)
+	key(func() { le.insertRune('x') })
+
+	// The characters typed are undone at once, deletions one at a time
+	steps := []struct {
+		redo bool
+		want string
+	}{
+		{false, "a long prom"},
+		{false, "a long promp"},
+		{false, "a long prompt"},
+		{false, ""},
+		{true, "a long prompt"},
+		{true, "a long promp"},
+	}
+	for _, step := range steps {
+		if !le.undoEdit(step.redo) || le.line != step.want || le.cursor != len(step.want) {
+			t.Errorf("undoEdit(%v): line = %q, cursor %d, want %q", step.redo, le.line, le.cursor, step.want)
+		}
+	}
+}
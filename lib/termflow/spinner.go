@@ -1,7 +1,6 @@
 package termflow
 
 import (
-	"fmt"
 	"sync"
 	"time"
 )
@@ -122,7 +121,10 @@ func (s *Spinner) IsRunning() bool {
 type ThinkingSpinner struct {
 	spinner *Spinner
 	client  *InteractiveClient
+
+	mu      sync.RWMutex
 	message string
+	note    string
 }
 
 // NewThinkingSpinner creates a new thinking spinner
@@ -134,6 +136,42 @@ func NewThinkingSpinner(client *InteractiveClient, message string) *ThinkingSpin
 	}
 }
 
+// SetMessage changes the text shown next to the spinner
+func (ts *ThinkingSpinner) SetMessage(message string) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.message = message
+}
+
+// Message returns the text shown next to the spinner
+func (ts *ThinkingSpinner) Message() string {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	return ts.message
+}
+
+// SetNote sets muted text shown after the message, such as input typed
+// while waiting
+func (ts *ThinkingSpinner) SetNote(note string) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.note = note
+}
+
+// line renders the spinner frame, message and note
+func (ts *ThinkingSpinner) line(frame string) string {
+	ts.mu.RLock()
+	message, note := ts.message, ts.note
+	ts.mu.RUnlock()
+
+	// Colored spinner frame and italic thinking text (like bubbletea)
+	line := ts.client.colors.Paint(RoleAccent, frame) + ts.client.colors.Paint(RoleThinking, " "+message)
+	if note != "" {
+		line += "  " + ts.client.colors.Paint(RoleMuted, note)
+	}
+	return line
+}
+
 // Start begins the thinking animation
 func (ts *ThinkingSpinner) Start() {
 	ts.spinner.Start()
@@ -162,13 +200,8 @@ func (ts *ThinkingSpinner) clearThinking() {
 
 // showThinkingInitial displays the initial thinking message
 func (ts *ThinkingSpinner) showThinkingInitial() {
-	frame := ts.spinner.Frame()
-	if frame != "" {
-		// Colored spinner frame (cyan like bubbletea)
-		coloredFrame := fmt.Sprintf("\033[1;38;5;87m%s\033[0m", frame)
-		// Italic thinking text (like bubbletea)
-		coloredMessage := fmt.Sprintf("\033[3;38;5;117m %s\033[0m", ts.message)
-		ts.client.Printf("\n%s%s", coloredFrame, coloredMessage)
+	if frame := ts.spinner.Frame(); frame != "" {
+		ts.client.Printf("\n%s", ts.line(frame))
 	}
 }
 
@@ -182,13 +215,8 @@ func (ts *ThinkingSpinner) updateDisplay() {
 		if ts.spinner.IsRunning() {
 			// Move cursor up one line, clear it, and redraw with new frame
 			ts.client.Printf("\033[1A\033[2K\r")
-			frame := ts.spinner.Frame()
-			if frame != "" {
-				// Colored spinner frame (cyan like bubbletea)
-				coloredFrame := fmt.Sprintf("\033[1;38;5;87m%s\033[0m", frame)
-				// Italic thinking text (like bubbletea)
-				coloredMessage := fmt.Sprintf("\033[3;38;5;117m %s\033[0m", ts.message)
-				ts.client.Printf("\n%s%s", coloredFrame, coloredMessage)
+			if frame := ts.spinner.Frame(); frame != "" {
+				ts.client.Printf("\n%s", ts.line(frame))
 			}
 		}
 	}
@@ -0,0 +1,29 @@
+package termflow
+
+import "fmt"
+
+// SetStatusLine sets a line drawn above the prompt each time input is read,
+// or clears it with an empty string
+func (le *LineEditor) SetStatusLine(line string) {
+	le.statusLine = line
+}
+
+// SetStatusLine sets a line drawn above the prompt each time input is read,
+// such as a summary of the session, or clears it with an empty string
+func (ic *InteractiveClient) SetStatusLine(line string) {
+	ic.lineEditor.SetStatusLine(line)
+}
+
+// SetPlaceholder sets a hint shown in place of the input while it is empty,
+// or clears it with an empty string
+func (ic *InteractiveClient) SetPlaceholder(text string) {
+	ic.lineEditor.placeholder = text
+}
+
+// drawStatusLine draws the status line, if any, on the current line
+func (le *LineEditor) drawStatusLine() {
+	if le.statusLine == "" {
+		return
+	}
+	fmt.Fprintf(le.client.output, "\r\033[K%s\r\n", le.client.colors.Paint(RoleMuted, le.statusLine))
+}
@@ -15,7 +15,8 @@ import (
 type Client struct {
 	input          io.Reader
 	output         io.Writer
-	prompt         string
+	prompt         string // Custom prompt; empty uses the themed default
+	colors         *Colors
 	history        []string
 	maxHistory     int
 	completionFunc CompletionFunc
@@ -30,7 +31,7 @@ func New() *Client {
 	return &Client{
 		input:      os.Stdin,
 		output:     os.Stdout,
-		prompt:     "\033[1;38;5;87m✦\033[0m ",
+		colors:     DetectColors(os.Stdout),
 		maxHistory: 1000,
 		reader:     bufio.NewReader(os.Stdin),
 	}
@@ -41,6 +42,24 @@ func (c *Client) SetPrompt(prompt string) {
 	c.prompt = prompt
 }
 
+// Prompt returns the input prompt string
+func (c *Client) Prompt() string {
+	if c.prompt != "" {
+		return c.prompt
+	}
+	return c.colors.Paint(RoleAccent, "✦") + " "
+}
+
+// Colors returns the colorizer used for all styled output
+func (c *Client) Colors() *Colors {
+	return c.colors
+}
+
+// SetColors replaces the colorizer, e.g. to force colors off
+func (c *Client) SetColors(colors *Colors) {
+	c.colors = colors
+}
+
 // SetCompletionFunc sets the tab completion function
 func (c *Client) SetCompletionFunc(fn CompletionFunc) {
 	c.completionFunc = fn
@@ -59,21 +78,21 @@ func (c *Client) Printf(format string, args ...interface{}) {
 // PrintChat outputs a chat exchange (user input + AI response) with formatting
 func (c *Client) PrintChat(userInput, aiResponse string) {
 	// User prompt with ✦ symbol (same as bubbletea)
-	c.Printf("\033[1;38;5;87m✦\033[0m \033[38;5;195m%s\033[0m\n\n", userInput)
+	c.Printf("%s%s\n\n", c.Prompt(), c.colors.Paint(RoleInput, userInput))
 	// AI response with normal terminal color
-	c.Printf("\033[38;5;252m%s\033[0m\n\n", aiResponse)
+	c.Printf("%s\n\n", c.paintResponse(aiResponse))
 }
 
 // PrintResponse outputs only the AI response (user input is already visible)
 func (c *Client) PrintResponse(response string) {
 	// AI response with normal terminal color, preceded by newline for spacing
-	c.Printf("\n\033[38;5;252m%s\033[0m\n\n", response)
+	c.Printf("\n%s\n\n", c.paintResponse(response))
 }
 
 // ReadLine reads a line of input from the user with history support
 func (c *Client) ReadLine() (string, error) {
 	// Show prompt
-	fmt.Fprint(c.output, c.prompt)
+	fmt.Fprint(c.output, c.Prompt())
 
 	// Read input
 	line, err := c.reader.ReadString('\n')
@@ -142,7 +161,7 @@ func (c *Client) ReadMultiLine() (string, error) {
 // In interactive mode, multiline editing is supported via Ctrl+J.
 func (c *Client) ReadLineOrMultiLine() (string, error) {
 	// Show prompt
-	fmt.Fprint(c.output, c.prompt)
+	fmt.Fprint(c.output, c.Prompt())
 
 	// Read a line (base client cannot intercept Ctrl+J newlines in canonical mode)
 	line, err := c.reader.ReadString('\n')
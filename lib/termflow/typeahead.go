@@ -0,0 +1,142 @@
+package termflow
+
+import (
+	"strings"
+	"sync"
+)
+
+// Typeahead collects input typed while the application is busy, e.g.
+// waiting for a response, instead of letting it echo over the output. Each
+// line finished with Enter is queued; the line being typed is kept pending.
+type Typeahead struct {
+	keyboard    *KeyboardReader
+	onChange    func(pending string, queued []string)
+	onInterrupt func()
+	onSkip      func()
+
+	stop chan struct{}
+	done chan struct{}
+
+	mu      sync.Mutex
+	pending []rune
+	queued  []string
+}
+
+// StartTypeahead starts collecting typed input, continuing the line set
+// with SetInitialInput if any. onChange is called after each edit,
+// onInterrupt when Ctrl+C or Esc is pressed, since raw mode stops Ctrl+C
+// from raising a signal, and onSkip when End or Ctrl+End is pressed; any may
+// be nil. Stop must be called before reading input again.
+func (ic *InteractiveClient) StartTypeahead(onChange func(pending string, queued []string), onInterrupt, onSkip func()) *Typeahead {
+	t := &Typeahead{
+		keyboard:    ic.lineEditor.keyboard,
+		onChange:    onChange,
+		onInterrupt: onInterrupt,
+		onSkip:      onSkip,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+		pending:     []rune(ic.lineEditor.initialLine),
+	}
+	ic.lineEditor.initialLine = ""
+
+	if err := t.keyboard.EnableRawMode(); err != nil {
+		// Without raw mode keys can't be read one by one; leave input alone
+		close(t.done)
+		return t
+	}
+	if len(t.pending) > 0 && onChange != nil {
+		onChange(string(t.pending), nil)
+	}
+	go t.run()
+	return t
+}
+
+// run reads keys until stopped
+func (t *Typeahead) run() {
+	defer close(t.done)
+	defer t.keyboard.DisableRawMode()
+
+	for {
+		key, err := t.keyboard.ReadKeyUntil(t.stop)
+		if err != nil {
+			return
+		}
+
+		if key.Type == KeyCtrlC || key.Type == KeyEscape {
+			if t.onInterrupt != nil {
+				t.onInterrupt()
+			}
+			continue
+		}
+		if key.Type == KeyEnd || key.Type == KeyCtrlEnd {
+			if t.onSkip != nil {
+				t.onSkip()
+			}
+			continue
+		}
+		if !t.handleKey(key) {
+			continue
+		}
+		if t.onChange != nil {
+			pending, queued := t.State()
+			t.onChange(pending, queued)
+		}
+	}
+}
+
+// handleKey applies a key to the collected input and reports whether it
+// changed
+func (t *Typeahead) handleKey(key Key) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch key.Type {
+	case KeyRune:
+		t.pending = append(t.pending, key.Rune)
+	case KeyCtrlJ:
+		t.pending = append(t.pending, '\n')
+	case KeyPaste:
+		t.pending = append(t.pending, []rune(key.Text)...)
+	case KeyBackspace:
+		if len(t.pending) == 0 {
+			return false
+		}
+		t.pending = t.pending[:len(t.pending)-1]
+	case KeyEnter:
+		line := string(t.pending)
+		if strings.TrimSpace(line) == "" {
+			return false
+		}
+		t.queued = append(t.queued, line)
+		t.pending = nil
+	default:
+		return false
+	}
+	return true
+}
+
+// State returns the line being typed and the lines queued so far
+func (t *Typeahead) State() (string, []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(t.pending), append([]string(nil), t.queued...)
+}
+
+// Stop stops collecting input, restores the terminal and returns the line
+// being typed and the queued lines
+func (t *Typeahead) Stop() (string, []string) {
+	select {
+	case <-t.stop:
+	default:
+		close(t.stop)
+	}
+	<-t.done
+	return t.State()
+}
+
+// PrintInput echoes a line typed ahead as if it had just been entered at
+// the prompt and adds it to the history
+func (ic *InteractiveClient) PrintInput(line string) {
+	ic.Printf("%s%s\n", ic.Prompt(), strings.ReplaceAll(line, "\n", "\n  "))
+	ic.addToHistory(line)
+}
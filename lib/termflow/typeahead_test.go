@@ -0,0 +1,39 @@
+package termflow
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTypeaheadHandleKey(t *testing.T) {
+	typeahead := &Typeahead{}
+	keys := []Key{
+		{Type: KeyRune, Rune: 'l'},
+		{Type: KeyRune, Rune: 's'},
+		{Type: KeyRune, Rune: 'x'},
+		{Type: KeyBackspace},
+		{Type: KeyEnter},
+		{Type: KeyEnter}, // Blank lines aren't queued
+		{Type: KeyArrowUp},
+		{Type: KeyRune, Rune: 'h'},
+		{Type: KeyCtrlJ},
+		{Type: KeyRune, Rune: 'i'},
+	}
+	changes := 0
+	for _, key := range keys {
+		if typeahead.handleKey(key) {
+			changes++
+		}
+	}
+
+	pending, queued := typeahead.State()
+	if pending != "h\ni" {
+		t.Errorf("pending = %q, want %q", pending, "h\ni")
+	}
+	if want := []string{"ls"}; !reflect.DeepEqual(queued, want) {
+		t.Errorf("queued = %q, want %q", queued, want)
+	}
+	if changes != 8 {
+		t.Errorf("changes = %d, want 8", changes)
+	}
+}
@@ -0,0 +1,76 @@
+package termflow
+
+import (
+	"unicode/utf8"
+
+	"github.com/mizzy/rigel/lib/vi"
+)
+
+// viKeyNames maps keys to the names the vi editor uses
+var viKeyNames = map[KeyType]string{
+	KeyEscape:     "esc",
+	KeyEnter:      "enter",
+	KeyBackspace:  "backspace",
+	KeyDelete:     "delete",
+	KeyArrowUp:    "up",
+	KeyArrowDown:  "down",
+	KeyArrowLeft:  "left",
+	KeyArrowRight: "right",
+}
+
+// SetViMode turns vi-style modal editing on or off
+func (le *LineEditor) SetViMode(enabled bool) {
+	switch {
+	case enabled && le.vi == nil:
+		le.vi = vi.New()
+	case !enabled:
+		le.vi = nil
+	}
+}
+
+// SetViMode turns vi-style modal editing of the input line on or off
+func (ic *InteractiveClient) SetViMode(enabled bool) {
+	ic.lineEditor.SetViMode(enabled)
+}
+
+// prompt returns the prompt, which shows ❮ instead of ✦ in vi normal mode
+// unless a custom prompt is set
+func (le *LineEditor) prompt() string {
+	if le.vi != nil && le.vi.Mode() == vi.Normal && le.client.prompt == "" {
+		return le.client.colors.Paint(RoleAccent, "❮") + " "
+	}
+	return le.client.Prompt()
+}
+
+// handleViKey lets the vi editor handle a key. It returns the key the line
+// editor should handle, if any: the key itself, or one vi translated, such
+// as the up arrow for k. u undoes through the line's undo history.
+func (le *LineEditor) handleViKey(key Key) (Key, bool) {
+	name := viKeyNames[key.Type]
+	if key.Type == KeyRune {
+		name = string(key.Rune)
+	}
+	if name == "" {
+		return key, true
+	}
+
+	buf := vi.Buffer{Text: []rune(le.line), Cursor: utf8.RuneCountInString(le.line[:le.cursor])}
+	result := le.vi.HandleKey(name, &buf)
+	switch {
+	case result.Handled:
+		le.line = string(buf.Text)
+		le.cursor = len(string(buf.Text[:buf.Cursor]))
+		le.refreshDisplay()
+		return key, false
+	case result.Key == "undo":
+		if le.undoEdit(false) {
+			le.refreshDisplay()
+		}
+		return key, false
+	case result.Key == "up":
+		return Key{Type: KeyArrowUp}, true
+	case result.Key == "down":
+		return Key{Type: KeyArrowDown}, true
+	}
+	return key, true
+}
@@ -0,0 +1,93 @@
+// Package undo keeps the undo and redo history of a text input. The input
+// records its state after every key it handles; the history works out what
+// changed, so any input widget can use it without telling edits apart.
+package undo
+
+import "unicode/utf8"
+
+// maxUndo limits how many changes can be undone
+const maxUndo = 100
+
+// State is the text of the input and the cursor position in it. The cursor
+// may count bytes or runes, as long as the input always counts the same.
+type State struct {
+	Text   string
+	Cursor int
+}
+
+// History is the undo and redo history of an input. The zero value is an
+// empty history of an empty input.
+type History struct {
+	current State
+	undo    []State
+	redo    []State
+	typing  bool // The last change inserted a character; the next may join it
+}
+
+// Reset forgets the changes made, e.g. after the input was submitted, and
+// starts afresh from s
+func (h *History) Reset(s State) {
+	*h = History{current: s}
+}
+
+// Record notes the state of the input after a key. If the text changed, the
+// state before can be undone to. Characters typed one after another without
+// moving the cursor are undone as one change.
+func (h *History) Record(s State) {
+	if s.Text == h.current.Text {
+		if s.Cursor != h.current.Cursor {
+			h.typing = false
+		}
+		h.current = s
+		return
+	}
+
+	typed := inserted(h.current, s)
+	if !typed || !h.typing {
+		h.undo = push(h.undo, h.current)
+	}
+	h.redo = nil
+	h.typing = typed
+	h.current = s
+}
+
+// Undo returns the state before the last change, or false if there is
+// nothing to undo. Record the current state first.
+func (h *History) Undo() (State, bool) {
+	return h.step(&h.undo, &h.redo)
+}
+
+// Redo returns the state the last undo reverted, or false if there is
+// nothing to redo
+func (h *History) Redo() (State, bool) {
+	return h.step(&h.redo, &h.undo)
+}
+
+// step moves the current state onto to and returns the last one of from
+func (h *History) step(from, to *[]State) (State, bool) {
+	n := len(*from)
+	if n == 0 {
+		return h.current, false
+	}
+	*to = push(*to, h.current)
+	h.current = (*from)[n-1]
+	*from = (*from)[:n-1]
+	h.typing = false
+	return h.current, true
+}
+
+// push appends s to stack, dropping the oldest state beyond maxUndo
+func push(stack []State, s State) []State {
+	stack = append(stack, s)
+	if len(stack) > maxUndo {
+		stack = stack[1:]
+	}
+	return stack
+}
+
+// inserted reports whether after is before with one character typed at the
+// cursor
+func inserted(before, after State) bool {
+	return utf8.RuneCountInString(after.Text) == utf8.RuneCountInString(before.Text)+1 &&
+		after.Cursor > before.Cursor
+}
@@ -0,0 +1,92 @@
+package undo
+
+import "testing"
+
+// typeText records the states of typing text at the end of the input
+func typeText(h *History, text string) {
+	for _, r := range text {
+		s := h.current
+		s.Text += string(r)
+		s.Cursor++
+		h.Record(s)
+	}
+}
+
+func TestUndoCoalescesTyping(t *testing.T) {
+	var h History
+	typeText(&h, "hello")
+	h.Record(State{Text: "hell", Cursor: 4}) // Backspace
+	typeText(&h, "p me")
+
+	want := []State{{"hell", 4}, {"hello", 5}, {"", 0}}
+	for i, w := range want {
+		got, ok := h.Undo()
+		if !ok || got != w {
+			t.Fatalf("undo %d = %v, %v, want %v", i, got, ok, w)
+		}
+	}
+	if _, ok := h.Undo(); ok {
+		t.Error("undo past the first state")
+	}
+
+	for i, w := range []State{{"hello", 5}, {"hell", 4}, {"hellp me", 8}} {
+		got, ok := h.Redo()
+		if !ok || got != w {
+			t.Fatalf("redo %d = %v, %v, want %v", i, got, ok, w)
+		}
+	}
+	if _, ok := h.Redo(); ok {
+		t.Error("redo past the last state")
+	}
+}
+
+func TestUndoBreaksTypingOnCursorMovement(t *testing.T) {
+	var h History
+	typeText(&h, "ac")
+	h.Record(State{Text: "ac", Cursor: 1})
+	h.Record(State{Text: "abc", Cursor: 2})
+
+	if got, _ := h.Undo(); got != (State{"ac", 1}) {
+		t.Errorf("undo = %v, want the text before the cursor moved", got)
+	}
+}
+
+func TestChangeClearsRedo(t *testing.T) {
+	var h History
+	typeText(&h, "one")
+	h.Undo()
+	h.Record(State{Text: "two", Cursor: 3}) // Pasted
+	if _, ok := h.Redo(); ok {
+		t.Error("redo after a new change")
+	}
+	if got, _ := h.Undo(); got != (State{}) {
+		t.Errorf("undo = %v, want the empty input", got)
+	}
+}
+
+func TestUndoIsBounded(t *testing.T) {
+	var h History
+	for i := range maxUndo + 10 {
+		h.Record(State{Text: string(rune('a' + i%2))}) // Replaced each time
+	}
+	n := 0
+	for _, ok := h.Undo(); ok; _, ok = h.Undo() {
+		n++
+	}
+	if n != maxUndo {
+		t.Errorf("undid %d changes, want %d", n, maxUndo)
+	}
+}
+
+func TestReset(t *testing.T) {
+	var h History
+	typeText(&h, "sent")
+	h.Reset(State{Text: "draft", Cursor: 5})
+	if _, ok := h.Undo(); ok {
+		t.Error("undo after reset")
+	}
+	typeText(&h, "!")
+	if got, _ := h.Undo(); got != (State{"draft", 5}) {
+		t.Errorf("undo = %v, want the state reset to", got)
+	}
+}
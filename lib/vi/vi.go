@@ -0,0 +1,510 @@
+// Package vi implements vi-style modal editing for line-oriented input
+// fields. The editor works on a plain buffer of runes and a cursor so any
+// input widget can use it by converting its state to and from a Buffer.
+// Undoing is left to the input field, so that u and the field's undo key
+// step through the same history.
+package vi
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Mode is the editing mode
+type Mode int
+
+const (
+	// Insert passes keys to the input field, except Esc
+	Insert Mode = iota
+	// Normal interprets keys as vi commands
+	Normal
+)
+
+// Buffer is the text being edited and the cursor position in it
+type Buffer struct {
+	Text   []rune
+	Cursor int // Index into Text
+}
+
+// Result tells the input field what to do with a key
+type Result struct {
+	Handled bool   // The key was consumed and the buffer updated
+	Key     string // A key the field should handle instead, e.g. "up" for k or "undo" for u
+}
+
+// Editor interprets keys vi-style. It starts in insert mode.
+type Editor struct {
+	mode     Mode
+	pending  []rune // Count, operator and motion typed so far
+	register []rune // Last deleted or yanked text
+	linewise bool   // Whether the register holds whole lines
+}
+
+// New creates an editor in insert mode
+func New() *Editor {
+	return &Editor{}
+}
+
+// Mode returns the current editing mode
+func (e *Editor) Mode() Mode {
+	return e.mode
+}
+
+// Reset returns to insert mode, e.g. after the input was submitted. The
+// register is kept.
+func (e *Editor) Reset() {
+	e.mode = Insert
+	e.pending = nil
+}
+
+// HandleKey applies a key to buf. Keys are named like Bubbletea names them:
+// a single character for printable keys, otherwise e.g. "esc", "enter",
+// "backspace", "left" or "ctrl+c".
+func (e *Editor) HandleKey(key string, buf *Buffer) Result {
+	if e.mode == Insert {
+		if key != "esc" {
+			return Result{}
+		}
+		e.mode = Normal
+		if buf.Cursor > 0 && buf.Text[buf.Cursor-1] != '\n' {
+			buf.Cursor--
+		}
+		return Result{Handled: true}
+	}
+
+	runes := []rune(key)
+	if len(runes) != 1 {
+		e.pending = nil
+		switch key {
+		case "esc":
+			return Result{Handled: true}
+		case "left", "backspace":
+			runes = []rune{'h'}
+		case "right":
+			runes = []rune{'l'}
+		case "delete":
+			runes = []rune{'x'}
+		case "up", "down":
+			return Result{Key: key}
+		default:
+			return Result{}
+		}
+	}
+
+	e.pending = append(e.pending, runes[0])
+	result, complete := e.execute(buf)
+	if complete {
+		e.pending = nil
+	}
+	if e.mode == Normal {
+		clampCursor(buf)
+	}
+	return result
+}
+
+// execute runs the pending command if it is complete. It reports false if
+// more keys are needed.
+func (e *Editor) execute(buf *Buffer) (Result, bool) {
+	handled := Result{Handled: true}
+	p := e.pending
+	i := 0
+
+	count, i := parseCount(p, i)
+	if i == len(p) {
+		return handled, false
+	}
+	c := p[i]
+	i++
+
+	switch c {
+	case 'd', 'c', 'y':
+		count2, j := parseCount(p, i)
+		count *= count2
+		if j == len(p) {
+			return handled, false
+		}
+		target := p[j]
+		if target == c {
+			e.lineOperator(c, buf, count)
+			return handled, true
+		}
+		if target == 'i' || target == 'a' {
+			if j+1 == len(p) {
+				return handled, false
+			}
+			from, to, ok := textObject(p[j+1], target == 'a', buf.Text, buf.Cursor)
+			if ok {
+				e.operator(c, buf, from, to)
+			}
+			return handled, true
+		}
+		if c == 'c' && (target == 'w' || target == 'W') && buf.Cursor < len(buf.Text) && class(buf.Text[buf.Cursor], target == 'W') != blank {
+			// cw changes to the end of the word, like ce
+			target = target - 'w' + 'e'
+		}
+		to, inclusive, ok := motion(target, buf.Text, buf.Cursor, count)
+		if !ok {
+			return handled, true
+		}
+		from := buf.Cursor
+		if to < from {
+			from, to = to, from
+		} else if inclusive {
+			to = min(to+1, len(buf.Text))
+		}
+		e.operator(c, buf, from, to)
+		return handled, true
+
+	case 'r':
+		if i == len(p) {
+			return handled, false
+		}
+		end := buf.Cursor + count
+		if end > lineEnd(buf.Text, buf.Cursor) {
+			return handled, true
+		}
+		for k := buf.Cursor; k < end; k++ {
+			buf.Text[k] = p[i]
+		}
+		buf.Cursor = end - 1
+		return handled, true
+
+	case 'j':
+		return Result{Key: "down"}, true
+	case 'k':
+		return Result{Key: "up"}, true
+
+	case 'i':
+		e.mode = Insert
+	case 'a':
+		if buf.Cursor < lineEnd(buf.Text, buf.Cursor) {
+			buf.Cursor++
+		}
+		e.mode = Insert
+	case 'I':
+		buf.Cursor = firstNonBlank(buf.Text, buf.Cursor)
+		e.mode = Insert
+	case 'A':
+		buf.Cursor = lineEnd(buf.Text, buf.Cursor)
+		e.mode = Insert
+	case 'o':
+		buf.Cursor = lineEnd(buf.Text, buf.Cursor)
+		insertText(buf, []rune{'\n'})
+		e.mode = Insert
+	case 'O':
+		buf.Cursor = lineStart(buf.Text, buf.Cursor)
+		insertText(buf, []rune{'\n'})
+		buf.Cursor--
+		e.mode = Insert
+
+	case 'x':
+		e.operator('d', buf, buf.Cursor, min(buf.Cursor+count, lineEnd(buf.Text, buf.Cursor)))
+	case 'X':
+		e.operator('d', buf, max(buf.Cursor-count, lineStart(buf.Text, buf.Cursor)), buf.Cursor)
+	case 's':
+		e.operator('c', buf, buf.Cursor, min(buf.Cursor+count, lineEnd(buf.Text, buf.Cursor)))
+	case 'D':
+		e.operator('d', buf, buf.Cursor, lineEnd(buf.Text, buf.Cursor))
+	case 'C':
+		e.operator('c', buf, buf.Cursor, lineEnd(buf.Text, buf.Cursor))
+	case 'S':
+		e.lineOperator('c', buf, count)
+
+	case 'p', 'P':
+		e.paste(buf, c == 'p', count)
+	case 'u':
+		return Result{Key: "undo"}, true
+	case '~':
+		end := min(buf.Cursor+count, lineEnd(buf.Text, buf.Cursor))
+		for k := buf.Cursor; k < end; k++ {
+			if r := buf.Text[k]; unicode.IsUpper(r) {
+				buf.Text[k] = unicode.ToLower(r)
+			} else {
+				buf.Text[k] = unicode.ToUpper(r)
+			}
+		}
+		buf.Cursor = end
+
+	default:
+		if to, _, ok := motion(c, buf.Text, buf.Cursor, count); ok {
+			buf.Cursor = to
+		}
+	}
+	return handled, true
+}
+
+// parseCount parses a count starting at p[i]. A leading 0 is the motion to
+// the start of the line, not a count. It returns 1 if there is no count.
+func parseCount(p []rune, i int) (int, int) {
+	n := 0
+	for ; i < len(p) && p[i] >= '0' && p[i] <= '9'; i++ {
+		if p[i] == '0' && n == 0 {
+			break
+		}
+		n = n*10 + int(p[i]-'0')
+	}
+	return max(n, 1), i
+}
+
+// operator deletes, changes or yanks the text between from and to
+func (e *Editor) operator(op rune, buf *Buffer, from, to int) {
+	if from == to && op != 'c' {
+		return
+	}
+	if from < to {
+		e.register = append([]rune(nil), buf.Text[from:to]...)
+		e.linewise = false
+	}
+	if op == 'y' {
+		buf.Cursor = from
+		return
+	}
+
+	buf.Text = append(buf.Text[:from:from], buf.Text[to:]...)
+	buf.Cursor = from
+	if op == 'c' {
+		e.mode = Insert
+	}
+}
+
+// lineOperator deletes, changes or yanks count whole lines starting with
+// the cursor's (dd, cc and yy)
+func (e *Editor) lineOperator(op rune, buf *Buffer, count int) {
+	from := lineStart(buf.Text, buf.Cursor)
+	to := lineEnd(buf.Text, buf.Cursor)
+	for n := 1; n < count && to < len(buf.Text); n++ {
+		to = lineEnd(buf.Text, to+1)
+	}
+	e.register = append([]rune(nil), buf.Text[from:to]...)
+	e.linewise = true
+	if op == 'y' {
+		return
+	}
+
+	if op == 'c' {
+		buf.Text = append(buf.Text[:from:from], buf.Text[to:]...)
+		buf.Cursor = from
+		e.mode = Insert
+		return
+	}
+
+	// Remove a line break too, preferably the one after the lines
+	switch {
+	case to < len(buf.Text):
+		to++
+	case from > 0:
+		from--
+	}
+	buf.Text = append(buf.Text[:from:from], buf.Text[to:]...)
+	buf.Cursor = firstNonBlank(buf.Text, min(from, len(buf.Text)))
+}
+
+// paste puts the register after or before the cursor count times
+func (e *Editor) paste(buf *Buffer, after bool, count int) {
+	if len(e.register) == 0 {
+		return
+	}
+
+	text := []rune(strings.Repeat(string(e.register), count))
+	if e.linewise {
+		text = []rune(strings.Repeat(string(e.register)+"\n", count))
+		text = text[:len(text)-1]
+		if after {
+			buf.Cursor = lineEnd(buf.Text, buf.Cursor)
+			insertText(buf, append([]rune{'\n'}, text...))
+			buf.Cursor -= len(text)
+		} else {
+			buf.Cursor = lineStart(buf.Text, buf.Cursor)
+			insertText(buf, append(text, '\n'))
+			buf.Cursor -= len(text) + 1
+		}
+		return
+	}
+
+	if after && buf.Cursor < lineEnd(buf.Text, buf.Cursor) {
+		buf.Cursor++
+	}
+	insertText(buf, text)
+	buf.Cursor--
+}
+
+// insertText inserts text at the cursor and moves the cursor after it
+func insertText(buf *Buffer, text []rune) {
+	result := make([]rune, 0, len(buf.Text)+len(text))
+	result = append(result, buf.Text[:buf.Cursor]...)
+	result = append(result, text...)
+	result = append(result, buf.Text[buf.Cursor:]...)
+	buf.Text = result
+	buf.Cursor += len(text)
+}
+
+// clampCursor keeps the cursor on a character in normal mode rather than
+// after the last one of a line
+func clampCursor(buf *Buffer) {
+	buf.Cursor = max(0, min(buf.Cursor, len(buf.Text)))
+	if buf.Cursor > lineStart(buf.Text, buf.Cursor) && buf.Cursor == lineEnd(buf.Text, buf.Cursor) {
+		buf.Cursor--
+	}
+}
+
+// Character classes that make up words
+const (
+	blank = iota
+	word
+	punct
+)
+
+// class returns the character class of r. With big, WORDs are any
+// non-blank characters.
+func class(r rune, big bool) int {
+	switch {
+	case unicode.IsSpace(r):
+		return blank
+	case big || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+		return word
+	default:
+		return punct
+	}
+}
+
+// lineStart returns the index of the first character of pos's line
+func lineStart(text []rune, pos int) int {
+	for pos > 0 && text[pos-1] != '\n' {
+		pos--
+	}
+	return pos
+}
+
+// lineEnd returns the index just past the last character of pos's line
+func lineEnd(text []rune, pos int) int {
+	for pos < len(text) && text[pos] != '\n' {
+		pos++
+	}
+	return pos
+}
+
+// firstNonBlank returns the index of the first non-blank character of
+// pos's line
+func firstNonBlank(text []rune, pos int) int {
+	i := lineStart(text, pos)
+	end := lineEnd(text, pos)
+	for i < end && (text[i] == ' ' || text[i] == '\t') {
+		i++
+	}
+	return i
+}
+
+// motion returns where a motion repeated count times moves the cursor and
+// whether the character there is included by an operator
+func motion(m rune, text []rune, cursor, count int) (int, bool, bool) {
+	big := m == 'W' || m == 'B' || m == 'E'
+	pos := cursor
+	switch m {
+	case 'h':
+		return max(cursor-count, lineStart(text, cursor)), false, true
+	case 'l', ' ':
+		return min(cursor+count, lineEnd(text, cursor)), false, true
+	case '0':
+		return lineStart(text, cursor), false, true
+	case '^':
+		return firstNonBlank(text, cursor), false, true
+	case '$':
+		return lineEnd(text, cursor), false, true
+	case 'w', 'W':
+		for n := 0; n < count; n++ {
+			pos = nextWordStart(text, pos, big)
+		}
+		return pos, false, true
+	case 'e', 'E':
+		for n := 0; n < count; n++ {
+			pos = nextWordEnd(text, pos, big)
+		}
+		return pos, true, true
+	case 'b', 'B':
+		for n := 0; n < count; n++ {
+			pos = prevWordStart(text, pos, big)
+		}
+		return pos, false, true
+	}
+	return cursor, false, false
+}
+
+// nextWordStart returns the start of the word after pos
+func nextWordStart(text []rune, pos int, big bool) int {
+	if pos >= len(text) {
+		return len(text)
+	}
+	c := class(text[pos], big)
+	for pos < len(text) && c != blank && class(text[pos], big) == c {
+		pos++
+	}
+	for pos < len(text) && class(text[pos], big) == blank {
+		pos++
+	}
+	return pos
+}
+
+// nextWordEnd returns the last character of the word ending after pos
+func nextWordEnd(text []rune, pos int, big bool) int {
+	pos++
+	for pos < len(text) && class(text[pos], big) == blank {
+		pos++
+	}
+	if pos >= len(text) {
+		return max(len(text)-1, 0)
+	}
+	c := class(text[pos], big)
+	for pos+1 < len(text) && class(text[pos+1], big) == c {
+		pos++
+	}
+	return pos
+}
+
+// prevWordStart returns the start of the word before pos
+func prevWordStart(text []rune, pos int, big bool) int {
+	pos--
+	for pos > 0 && class(text[pos], big) == blank {
+		pos--
+	}
+	if pos <= 0 {
+		return 0
+	}
+	c := class(text[pos], big)
+	for pos > 0 && class(text[pos-1], big) == c {
+		pos--
+	}
+	return pos
+}
+
+// textObject returns the range of the word (iw, aw) or WORD (iW, aW) at
+// pos. The a variants include the blanks after the word, or before it if
+// there are none after.
+func textObject(obj rune, around bool, text []rune, pos int) (int, int, bool) {
+	if obj != 'w' && obj != 'W' || pos >= len(text) || text[pos] == '\n' {
+		return 0, 0, false
+	}
+	big := obj == 'W'
+	c := class(text[pos], big)
+	from, to := pos, pos+1
+	for from > 0 && text[from-1] != '\n' && class(text[from-1], big) == c {
+		from--
+	}
+	for to < len(text) && text[to] != '\n' && class(text[to], big) == c {
+		to++
+	}
+	if !around || c == blank {
+		return from, to, true
+	}
+
+	end := to
+	for end < len(text) && (text[end] == ' ' || text[end] == '\t') {
+		end++
+	}
+	if end > to {
+		return from, end, true
+	}
+	for from > 0 && (text[from-1] == ' ' || text[from-1] == '\t') {
+		from--
+	}
+	return from, to, true
+}
@@ -0,0 +1,125 @@
+package vi
+
+import (
+	"testing"
+)
+
+// run types keys into an editor in normal mode with the cursor at cursor
+// and returns the buffer and editor afterwards
+func run(text string, cursor int, keys ...string) (Buffer, *Editor) {
+	e := New()
+	buf := Buffer{Text: []rune(text), Cursor: cursor + 1}
+	if cursor+1 > len(buf.Text) {
+		buf.Cursor = len(buf.Text)
+	}
+	e.HandleKey("esc", &buf)
+	buf.Cursor = cursor
+	for _, key := range keys {
+		e.HandleKey(key, &buf)
+	}
+	return buf, e
+}
+
+// keys splits a key sequence into single-character keys
+func keys(s string) []string {
+	var keys []string
+	for _, r := range s {
+		keys = append(keys, string(r))
+	}
+	return keys
+}
+
+func TestHandleKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		text       string
+		cursor     int
+		keys       string
+		wantText   string
+		wantCursor int
+		wantInsert bool
+	}{
+		{name: "h and l", text: "hello", cursor: 2, keys: "hhhl", wantText: "hello", wantCursor: 1},
+		{name: "l stops at last character", text: "hello", cursor: 3, keys: "lll", wantText: "hello", wantCursor: 4},
+		{name: "count", text: "hello world", cursor: 0, keys: "3l", wantText: "hello world", wantCursor: 3},
+		{name: "w", text: "foo.bar baz", cursor: 0, keys: "w", wantText: "foo.bar baz", wantCursor: 3},
+		{name: "W", text: "foo.bar baz", cursor: 0, keys: "W", wantText: "foo.bar baz", wantCursor: 8},
+		{name: "b", text: "foo bar baz", cursor: 9, keys: "bb", wantText: "foo bar baz", wantCursor: 4},
+		{name: "e", text: "foo bar", cursor: 0, keys: "e", wantText: "foo bar", wantCursor: 2},
+		{name: "0 and $", text: "  foo bar", cursor: 4, keys: "$", wantText: "  foo bar", wantCursor: 8},
+		{name: "caret", text: "  foo bar", cursor: 8, keys: "^", wantText: "  foo bar", wantCursor: 2},
+		{name: "x", text: "hello", cursor: 1, keys: "2x", wantText: "hlo", wantCursor: 1},
+		{name: "X", text: "hello", cursor: 2, keys: "X", wantText: "hllo", wantCursor: 1},
+		{name: "dw", text: "foo bar baz", cursor: 0, keys: "dw", wantText: "bar baz", wantCursor: 0},
+		{name: "d2w", text: "foo bar baz", cursor: 0, keys: "d2w", wantText: "baz", wantCursor: 0},
+		{name: "2dw", text: "foo bar baz", cursor: 0, keys: "2dw", wantText: "baz", wantCursor: 0},
+		{name: "db", text: "foo bar", cursor: 4, keys: "db", wantText: "bar", wantCursor: 0},
+		{name: "de", text: "foo bar", cursor: 4, keys: "de", wantText: "foo ", wantCursor: 3},
+		{name: "d$", text: "foo bar", cursor: 3, keys: "d$", wantText: "foo", wantCursor: 2},
+		{name: "D", text: "foo bar", cursor: 3, keys: "D", wantText: "foo", wantCursor: 2},
+		{name: "d0", text: "foo bar", cursor: 4, keys: "d0", wantText: "bar", wantCursor: 0},
+		{name: "diw", text: "foo bar baz", cursor: 5, keys: "diw", wantText: "foo  baz", wantCursor: 4},
+		{name: "daw", text: "foo bar baz", cursor: 5, keys: "daw", wantText: "foo baz", wantCursor: 4},
+		{name: "daw at end", text: "foo bar", cursor: 5, keys: "daw", wantText: "foo", wantCursor: 2},
+		{name: "ciw", text: "foo bar baz", cursor: 5, keys: "ciw", wantText: "foo  baz", wantCursor: 4, wantInsert: true},
+		{name: "cw changes to end of word", text: "foo bar", cursor: 0, keys: "cw", wantText: " bar", wantCursor: 0, wantInsert: true},
+		{name: "cc", text: "foo bar", cursor: 3, keys: "cc", wantText: "", wantCursor: 0, wantInsert: true},
+		{name: "dd", text: "one\ntwo\nthree", cursor: 5, keys: "dd", wantText: "one\nthree", wantCursor: 4},
+		{name: "dd last line", text: "one\ntwo", cursor: 5, keys: "dd", wantText: "one", wantCursor: 0},
+		{name: "yy and p", text: "one\ntwo", cursor: 0, keys: "yyjp", wantText: "one\none\ntwo", wantCursor: 4},
+		{name: "yw and P", text: "foo bar", cursor: 4, keys: "ywP", wantText: "foo barbar", wantCursor: 6},
+		{name: "x and p swaps", text: "ab", cursor: 0, keys: "xp", wantText: "ba", wantCursor: 1},
+		{name: "r", text: "hello", cursor: 0, keys: "rj", wantText: "jello", wantCursor: 0},
+		{name: "tilde", text: "hello", cursor: 0, keys: "2~", wantText: "HEllo", wantCursor: 2},
+		{name: "A", text: "foo", cursor: 0, keys: "A", wantText: "foo", wantCursor: 3, wantInsert: true},
+		{name: "I", text: "  foo", cursor: 4, keys: "I", wantText: "  foo", wantCursor: 2, wantInsert: true},
+		{name: "a", text: "foo", cursor: 0, keys: "a", wantText: "foo", wantCursor: 1, wantInsert: true},
+		{name: "o", text: "foo\nbar", cursor: 0, keys: "o", wantText: "foo\n\nbar", wantCursor: 4, wantInsert: true},
+		{name: "O", text: "foo", cursor: 1, keys: "O", wantText: "\nfoo", wantCursor: 0, wantInsert: true},
+		{name: "unknown keys are ignored", text: "foo", cursor: 1, keys: "zq", wantText: "foo", wantCursor: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf, e := run(tt.text, tt.cursor, keys(tt.keys)...)
+			if got := string(buf.Text); got != tt.wantText {
+				t.Errorf("text = %q, want %q", got, tt.wantText)
+			}
+			if buf.Cursor != tt.wantCursor {
+				t.Errorf("cursor = %d, want %d", buf.Cursor, tt.wantCursor)
+			}
+			if got := e.Mode() == Insert; got != tt.wantInsert {
+				t.Errorf("insert mode = %v, want %v", got, tt.wantInsert)
+			}
+		})
+	}
+}
+
+func TestInsertMode(t *testing.T) {
+	e := New()
+	buf := Buffer{Text: []rune("foo"), Cursor: 3}
+
+	if result := e.HandleKey("x", &buf); result.Handled {
+		t.Error("keys in insert mode should be left to the input field")
+	}
+
+	result := e.HandleKey("esc", &buf)
+	if !result.Handled || e.Mode() != Normal || buf.Cursor != 2 {
+		t.Errorf("esc: handled = %v, mode = %v, cursor = %d", result.Handled, e.Mode(), buf.Cursor)
+	}
+
+	if result := e.HandleKey("k", &buf); result.Key != "up" {
+		t.Errorf("k forwards %q, want up", result.Key)
+	}
+	if result := e.HandleKey("u", &buf); result.Key != "undo" {
+		t.Errorf("u forwards %q, want undo", result.Key)
+	}
+	if result := e.HandleKey("enter", &buf); result.Handled || result.Key != "" {
+		t.Error("enter should be left to the input field")
+	}
+
+	e.Reset()
+	if e.Mode() != Insert {
+		t.Error("Reset should return to insert mode")
+	}
+}